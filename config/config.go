@@ -2,40 +2,77 @@ package config
 
 import (
 	"fmt"
+	"net/http"
 	"os"
+	"regexp"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/sebaespinosa/test_NF/model"
+	"gorm.io/gorm/schema"
 )
 
 // Config holds all application configuration
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Jaeger   JaegerConfig
-	Loki     LokiConfig
-	Service  ServiceConfig
+	Server    ServerConfig
+	Database  DatabaseConfig
+	Jaeger    JaegerConfig
+	Loki      LokiConfig
+	Service   ServiceConfig
+	Analytics AnalyticsConfig
+	Health    HealthConfig
+	Retention RetentionConfig
+	Import    ImportConfig
+	Auth      AuthConfig
 }
 
 // ServerConfig holds server-related configuration
 type ServerConfig struct {
-	Port uint16
-	Env  string
+	Port                   int
+	Env                    string
+	ShutdownTimeout        time.Duration
+	RequestTimeout         time.Duration
+	TrustedProxies         []string
+	RequireJSONContentType bool
+	MaxDecompressedBytes   int64
+	SecurityHeaders        bool
+	CacheControl           string
+	BodySampleRate         float64
+	BodySampleMaxBytes     int
+	BodySampleRedactFields []string
+	RequestIDHeader        string
 }
 
 // DatabaseConfig holds database-related configuration
 type DatabaseConfig struct {
-	Host            string
-	Port            uint16
-	User            string
-	Password        string
-	Name            string
-	SSLMode         string
-	MaxOpenConns    int
-	MaxIdleConns    int
-	ConnMaxLifetime time.Duration
-	DSN             string
+	Host             string
+	Port             int
+	User             string
+	Password         string
+	Name             string
+	SSLMode          string
+	MaxOpenConns     int
+	MaxIdleConns     int
+	ConnMaxLifetime  time.Duration
+	StatementTimeout time.Duration
+	MinWarmConns     int
+	ReplicaHost      string
+	// ExtraIndexes are irrigation_data indexes created or dropped at startup on top of the model's own
+	ExtraIndexes []IndexSpec
+	DSN          string
+	ReplicaDSN   string
+
+	invalidExtraIndexes []string
+}
+
+// IndexSpec is one DB_EXTRA_INDEXES entry: an irrigation_data index on Columns, or one to drop
+type IndexSpec struct {
+	Name    string
+	Columns []string
+	Drop    bool
 }
 
 // JaegerConfig holds Jaeger tracing configuration
@@ -44,6 +81,8 @@ type JaegerConfig struct {
 	AgentPort    uint16
 	SamplerType  string
 	SamplerParam float64
+	// Required makes an exporter init failure fatal; otherwise the service starts with a no-op tracer
+	Required bool
 }
 
 // LokiConfig holds Loki logging configuration
@@ -57,6 +96,74 @@ type ServiceConfig struct {
 	Version string
 }
 
+// HealthConfig holds health check configuration
+// FailureThreshold is how many consecutive failed database checks it takes for readiness to report unhealthy
+type HealthConfig struct {
+	CacheTTL         time.Duration
+	FailureThreshold int
+}
+
+// RetentionConfig holds raw irrigation data retention configuration
+// Days of 0 disables the purge job; with Archive, expired days are rolled up into daily summaries first
+type RetentionConfig struct {
+	Days     int
+	Interval time.Duration
+	Archive  bool
+}
+
+// ImportConfig holds limits for the import endpoint
+type ImportConfig struct {
+	MaxRecordsPerSection int
+}
+
+// AuthConfig holds API key configuration; with no keys, authentication is disabled
+// APIKeys maps each key to the farm IDs it may access; keys mapped to no farms are global
+type AuthConfig struct {
+	APIKeys map[string][]uint
+
+	invalidAPIKeys []string
+}
+
+// AnalyticsConfig holds analytics endpoint configuration
+type AnalyticsConfig struct {
+	DefaultAggregation         model.Aggregation
+	DefaultLimit               int
+	MaxLimit                   int
+	AlertDeficitThresholdMM    float64
+	AlertCriticalEfficiencyGap float64
+	SparklineMaxPoints         int
+	MaxResponseBytes           int
+	ConfidenceMediumMinEvents  int
+	ConfidenceHighMinEvents    int
+	ZeroNominalPolicy          model.ZeroNominalPolicy
+	DefaultFields              model.AnalyticsFields
+	YoYParallel                bool
+	ExactSums                  bool
+	MaxBuckets                 int
+	WarnUnboundedLimit         bool
+	StrictQueryParams          bool
+	YoYCacheFarmIDs            []uint
+	YoYCacheInterval           time.Duration
+	YoYCacheJitter             time.Duration
+	ExcludedFarmIDs            []uint
+	HealthyEfficiency          float64
+	PartialStatus              int
+	// LogSQL logs every analytics statement with its bound values; ignored in production
+	LogSQL bool
+	// Presets are named date ranges dashboards request with ?preset= instead of explicit dates
+	Presets map[string]DateRange
+
+	invalidYoYCacheFarms []string
+	invalidExcludedFarms []string
+	invalidPresets       []string
+}
+
+// DateRange is an inclusive range of UTC days, both ends at midnight like parsed start_date/end_date values
+type DateRange struct {
+	Start time.Time
+	End   time.Time
+}
+
 // Load loads configuration from environment variables
 func Load() (*Config, error) {
 	// Load .env file if it exists (for local development)
@@ -64,25 +171,40 @@ func Load() (*Config, error) {
 
 	cfg := &Config{
 		Server: ServerConfig{
-			Port: parseUint16(os.Getenv("SERVER_PORT"), 8080),
-			Env:  getEnv("ENV", "development"),
+			Port:                   parseInt(os.Getenv("SERVER_PORT"), 8080),
+			Env:                    getEnv("ENV", "development"),
+			ShutdownTimeout:        parseDuration(os.Getenv("SERVER_SHUTDOWN_TIMEOUT"), "30s"),
+			RequestTimeout:         parseDuration(os.Getenv("SERVER_REQUEST_TIMEOUT"), "10s"),
+			TrustedProxies:         parseList(os.Getenv("TRUSTED_PROXIES")),
+			RequireJSONContentType: parseBool(os.Getenv("REQUIRE_JSON_CONTENT_TYPE"), false),
+			MaxDecompressedBytes:   int64(parseInt(os.Getenv("REQUEST_MAX_DECOMPRESSED_BYTES"), 32*1024*1024)),
+			SecurityHeaders:        parseBool(os.Getenv("SECURITY_HEADERS_ENABLED"), true),
+			CacheControl:           getEnv("RESPONSE_CACHE_CONTROL", "no-cache"),
+			BodySampleRate:         parseFloat64(os.Getenv("DEBUG_BODY_SAMPLE_RATE"), 0),
+			BodySampleMaxBytes:     parseInt(os.Getenv("DEBUG_BODY_MAX_BYTES"), 2048),
+			BodySampleRedactFields: parseList(getEnv("DEBUG_BODY_REDACT_FIELDS", "password,token,api_key,secret,authorization")),
+			RequestIDHeader:        getEnv("REQUEST_ID_HEADER", "X-Request-ID"),
 		},
 		Database: DatabaseConfig{
-			Host:            getEnv("DB_HOST", "localhost"),
-			Port:            parseUint16(os.Getenv("DB_PORT"), 5432),
-			User:            getEnv("DB_USER", "irrigationuser"),
-			Password:        getEnv("DB_PASSWORD", "irrigationpass"),
-			Name:            getEnv("DB_NAME", "irrigation_db"),
-			SSLMode:         getEnv("DB_SSL_MODE", "disable"),
-			MaxOpenConns:    parseInt(os.Getenv("DB_MAX_OPEN_CONNS"), 25),
-			MaxIdleConns:    parseInt(os.Getenv("DB_MAX_IDLE_CONNS"), 5),
-			ConnMaxLifetime: parseDuration(os.Getenv("DB_CONN_MAX_LIFETIME"), "5m"),
+			Host:             getEnv("DB_HOST", "localhost"),
+			Port:             parseInt(os.Getenv("DB_PORT"), 5432),
+			User:             getEnv("DB_USER", "irrigationuser"),
+			Password:         getEnv("DB_PASSWORD", "irrigationpass"),
+			Name:             getEnv("DB_NAME", "irrigation_db"),
+			SSLMode:          getEnv("DB_SSL_MODE", "disable"),
+			MaxOpenConns:     parseInt(os.Getenv("DB_MAX_OPEN_CONNS"), 25),
+			MaxIdleConns:     parseInt(os.Getenv("DB_MAX_IDLE_CONNS"), 5),
+			ConnMaxLifetime:  parseDuration(os.Getenv("DB_CONN_MAX_LIFETIME"), "5m"),
+			StatementTimeout: parseDuration(os.Getenv("DB_STATEMENT_TIMEOUT"), "5s"),
+			MinWarmConns:     parseInt(os.Getenv("DB_MIN_WARM_CONNS"), 0),
+			ReplicaHost:      os.Getenv("DB_REPLICA_HOST"),
 		},
 		Jaeger: JaegerConfig{
 			AgentHost:    getEnv("JAEGER_AGENT_HOST", "localhost"),
 			AgentPort:    parseUint16(os.Getenv("JAEGER_AGENT_PORT"), 6831),
 			SamplerType:  getEnv("JAEGER_SAMPLER_TYPE", "const"),
 			SamplerParam: parseFloat64(os.Getenv("JAEGER_SAMPLER_PARAM"), 1.0),
+			Required:     parseBool(os.Getenv("TRACING_REQUIRED"), false),
 		},
 		Loki: LokiConfig{
 			URL: getEnv("LOKI_URL", "http://localhost:3100"),
@@ -91,20 +213,251 @@ func Load() (*Config, error) {
 			Name:    getEnv("SERVICE_NAME", "irrigation-api"),
 			Version: getEnv("SERVICE_VERSION", "0.0.1"),
 		},
+		Health: HealthConfig{
+			CacheTTL:         parseDuration(os.Getenv("HEALTH_CACHE_TTL"), "5s"),
+			FailureThreshold: parseInt(os.Getenv("HEALTH_FAILURE_THRESHOLD"), 1),
+		},
+		Retention: RetentionConfig{
+			Days:     parseInt(os.Getenv("DATA_RETENTION_DAYS"), 0),
+			Interval: parseDuration(os.Getenv("DATA_RETENTION_INTERVAL"), "24h"),
+			Archive:  parseBool(os.Getenv("DATA_RETENTION_ARCHIVE"), true),
+		},
+		Import: ImportConfig{
+			MaxRecordsPerSection: parseInt(os.Getenv("IMPORT_MAX_RECORDS_PER_SECTION"), 10000),
+		},
+		Auth: parseAuth(os.Getenv("API_KEYS")),
+		Analytics: AnalyticsConfig{
+			DefaultAggregation:         model.Aggregation(getEnv("ANALYTICS_DEFAULT_AGGREGATION", string(model.AggregationDaily))),
+			DefaultLimit:               parseInt(os.Getenv("ANALYTICS_DEFAULT_LIMIT"), 50),
+			MaxLimit:                   parseInt(os.Getenv("ANALYTICS_MAX_LIMIT"), 1000),
+			AlertDeficitThresholdMM:    parseFloat64(os.Getenv("ANALYTICS_ALERT_DEFICIT_THRESHOLD_MM"), 50),
+			AlertCriticalEfficiencyGap: parseFloat64(os.Getenv("ANALYTICS_ALERT_CRITICAL_EFFICIENCY_GAP"), 0.1),
+			SparklineMaxPoints:         parseInt(os.Getenv("ANALYTICS_SPARKLINE_MAX_POINTS"), 30),
+			MaxResponseBytes:           parseInt(os.Getenv("ANALYTICS_MAX_RESPONSE_BYTES"), 5*1024*1024),
+			ConfidenceMediumMinEvents:  parseInt(os.Getenv("ANALYTICS_CONFIDENCE_MEDIUM_MIN_EVENTS"), 10),
+			ConfidenceHighMinEvents:    parseInt(os.Getenv("ANALYTICS_CONFIDENCE_HIGH_MIN_EVENTS"), 50),
+			ZeroNominalPolicy:          model.ZeroNominalPolicy(getEnv("EFFICIENCY_ZERO_NOMINAL_POLICY", string(model.ZeroNominalExclude))),
+			DefaultFields:              parseAnalyticsFields(os.Getenv("ANALYTICS_DEFAULT_FIELDS")),
+			YoYParallel:                parseBool(os.Getenv("ANALYTICS_YOY_PARALLEL"), false),
+			ExactSums:                  parseBool(os.Getenv("ANALYTICS_EXACT_SUMS"), true),
+			MaxBuckets:                 parseInt(os.Getenv("ANALYTICS_MAX_BUCKETS"), 10000),
+			WarnUnboundedLimit:         parseBool(os.Getenv("ANALYTICS_WARN_UNBOUNDED_LIMIT"), true),
+			StrictQueryParams:          parseBool(os.Getenv("ANALYTICS_STRICT_QUERY_PARAMS"), false),
+			YoYCacheInterval:           parseDuration(os.Getenv("ANALYTICS_YOY_CACHE_INTERVAL"), "24h"),
+			YoYCacheJitter:             parseDuration(os.Getenv("ANALYTICS_YOY_CACHE_JITTER"), "10m"),
+			HealthyEfficiency:          parseFloat64(os.Getenv("ANALYTICS_HEALTHY_EFFICIENCY_THRESHOLD"), 0),
+			PartialStatus:              parseInt(os.Getenv("ANALYTICS_PARTIAL_STATUS"), http.StatusPartialContent),
+			LogSQL:                     parseBool(os.Getenv("ANALYTICS_DEBUG_SQL"), false),
+		},
+	}
+	cfg.Analytics.YoYCacheFarmIDs, cfg.Analytics.invalidYoYCacheFarms = parseFarmIDs(os.Getenv("ANALYTICS_YOY_CACHE_FARMS"))
+	cfg.Analytics.ExcludedFarmIDs, cfg.Analytics.invalidExcludedFarms = parseFarmIDs(os.Getenv("ANALYTICS_EXCLUDED_FARMS"))
+	cfg.Database.ExtraIndexes, cfg.Database.invalidExtraIndexes = parseIndexSpecs(os.Getenv("DB_EXTRA_INDEXES"))
+	cfg.Analytics.Presets, cfg.Analytics.invalidPresets = parsePresets(os.Getenv("ANALYTICS_PRESETS"))
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
 	}
 
-	// Build PostgreSQL DSN
-	cfg.Database.DSN = fmt.Sprintf(
+	// Build PostgreSQL DSNs; the replica shares every setting but the host
+	cfg.Database.DSN = cfg.Database.dsn(cfg.Database.Host)
+	if cfg.Database.ReplicaHost != "" {
+		cfg.Database.ReplicaDSN = cfg.Database.dsn(cfg.Database.ReplicaHost)
+	}
+
+	return cfg, nil
+}
+
+// dsn builds the PostgreSQL DSN for host from the other database settings
+func (c *DatabaseConfig) dsn(host string) string {
+	dsn := fmt.Sprintf(
 		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-		cfg.Database.Host,
-		cfg.Database.Port,
-		cfg.Database.User,
-		cfg.Database.Password,
-		cfg.Database.Name,
-		cfg.Database.SSLMode,
+		host,
+		c.Port,
+		c.User,
+		c.Password,
+		c.Name,
+		c.SSLMode,
 	)
+	if c.StatementTimeout > 0 {
+		dsn += fmt.Sprintf(" statement_timeout=%d", c.StatementTimeout.Milliseconds())
+	}
+	return dsn
+}
 
-	return cfg, nil
+// ValidationError lists every configuration problem found at startup
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration: " + strings.Join(e.Problems, "; ")
+}
+
+// maxPort is the largest TCP port; ports are parsed as int so larger values are reported instead of
+// being dropped for the default
+const maxPort = 65535
+
+// Validate checks the loaded configuration and reports all problems at once, so a
+// misconfigured deployment can be fixed in one pass instead of one restart per variable
+func (c *Config) Validate() error {
+	var problems []string
+	addf := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	// Server
+	if c.Server.Port < 1 || c.Server.Port > maxPort {
+		addf("SERVER_PORT must be between 1 and 65535, got %d", c.Server.Port)
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		addf("SERVER_SHUTDOWN_TIMEOUT must be positive, got %s", c.Server.ShutdownTimeout)
+	}
+	if c.Server.MaxDecompressedBytes <= 0 {
+		addf("REQUEST_MAX_DECOMPRESSED_BYTES must be positive, got %d", c.Server.MaxDecompressedBytes)
+	}
+	if c.Server.RequestTimeout < 0 {
+		addf("SERVER_REQUEST_TIMEOUT must not be negative, got %s", c.Server.RequestTimeout)
+	}
+	if c.Server.BodySampleRate < 0 || c.Server.BodySampleRate > 1 {
+		addf("DEBUG_BODY_SAMPLE_RATE must be between 0 and 1, got %g", c.Server.BodySampleRate)
+	}
+	if c.Server.BodySampleMaxBytes <= 0 {
+		addf("DEBUG_BODY_MAX_BYTES must be positive, got %d", c.Server.BodySampleMaxBytes)
+	}
+	if !headerName.MatchString(c.Server.RequestIDHeader) {
+		addf("invalid REQUEST_ID_HEADER %q; must be a header name of letters, digits and dashes", c.Server.RequestIDHeader)
+	}
+
+	// Database
+	if c.Database.Port < 1 || c.Database.Port > maxPort {
+		addf("DB_PORT must be between 1 and 65535, got %d", c.Database.Port)
+	}
+	if strings.TrimSpace(c.Database.Name) == "" {
+		addf("DB_NAME must not be empty")
+	}
+	if strings.TrimSpace(c.Database.User) == "" {
+		addf("DB_USER must not be empty")
+	}
+	if c.Database.MaxOpenConns <= 0 {
+		addf("DB_MAX_OPEN_CONNS must be positive, got %d", c.Database.MaxOpenConns)
+	}
+	if c.Database.MaxIdleConns <= 0 {
+		addf("DB_MAX_IDLE_CONNS must be positive, got %d", c.Database.MaxIdleConns)
+	}
+	if c.Database.ConnMaxLifetime <= 0 {
+		addf("DB_CONN_MAX_LIFETIME must be positive, got %s", c.Database.ConnMaxLifetime)
+	}
+	// Warmed connections beyond the idle limit would be closed as soon as they are returned
+	if c.Database.MinWarmConns < 0 || (c.Database.MaxIdleConns > 0 && c.Database.MinWarmConns > c.Database.MaxIdleConns) {
+		addf("DB_MIN_WARM_CONNS must be between 0 and DB_MAX_IDLE_CONNS (%d), got %d", c.Database.MaxIdleConns, c.Database.MinWarmConns)
+	}
+	for _, entry := range c.Database.invalidExtraIndexes {
+		addf("invalid DB_EXTRA_INDEXES entry %q; use name=column+column to create or -name to drop, with lowercase identifiers and unique names the models do not declare", entry)
+	}
+	if c.Database.StatementTimeout < 0 {
+		addf("DB_STATEMENT_TIMEOUT must not be negative, got %s", c.Database.StatementTimeout)
+	}
+	// A statement timeout at or above the request timeout would never surface as a database error
+	if c.Database.StatementTimeout > 0 && c.Server.RequestTimeout > 0 && c.Database.StatementTimeout >= c.Server.RequestTimeout {
+		addf("DB_STATEMENT_TIMEOUT (%s) must be shorter than SERVER_REQUEST_TIMEOUT (%s)", c.Database.StatementTimeout, c.Server.RequestTimeout)
+	}
+
+	// Health
+	if c.Health.FailureThreshold < 1 {
+		addf("HEALTH_FAILURE_THRESHOLD must be at least 1, got %d", c.Health.FailureThreshold)
+	}
+
+	// Jaeger sampler: const takes 0 or 1, probabilistic a ratio, ratelimiting traces per second
+	switch c.Jaeger.SamplerType {
+	case "const":
+		if c.Jaeger.SamplerParam != 0 && c.Jaeger.SamplerParam != 1 {
+			addf("JAEGER_SAMPLER_PARAM must be 0 or 1 for the const sampler, got %g", c.Jaeger.SamplerParam)
+		}
+	case "probabilistic":
+		if c.Jaeger.SamplerParam < 0 || c.Jaeger.SamplerParam > 1 {
+			addf("JAEGER_SAMPLER_PARAM must be between 0 and 1 for the probabilistic sampler, got %g", c.Jaeger.SamplerParam)
+		}
+	case "ratelimiting":
+		if c.Jaeger.SamplerParam <= 0 {
+			addf("JAEGER_SAMPLER_PARAM must be positive for the ratelimiting sampler, got %g", c.Jaeger.SamplerParam)
+		}
+	case "remote":
+	default:
+		addf("invalid JAEGER_SAMPLER_TYPE %q; must be const, probabilistic, ratelimiting, or remote", c.Jaeger.SamplerType)
+	}
+
+	// Analytics
+	if !c.Analytics.DefaultAggregation.Valid() {
+		addf("invalid ANALYTICS_DEFAULT_AGGREGATION %q; must be daily, weekly, or monthly", c.Analytics.DefaultAggregation)
+	}
+	if c.Analytics.MaxLimit < 1 {
+		addf("ANALYTICS_MAX_LIMIT must be positive, got %d", c.Analytics.MaxLimit)
+	} else if c.Analytics.DefaultLimit < 1 || c.Analytics.DefaultLimit > c.Analytics.MaxLimit {
+		addf("ANALYTICS_DEFAULT_LIMIT must be between 1 and ANALYTICS_MAX_LIMIT (%d), got %d", c.Analytics.MaxLimit, c.Analytics.DefaultLimit)
+	}
+	if !c.Analytics.ZeroNominalPolicy.Valid() {
+		addf("invalid EFFICIENCY_ZERO_NOMINAL_POLICY %q; must be exclude or zero", c.Analytics.ZeroNominalPolicy)
+	}
+	if len(c.Analytics.DefaultFields) == 0 {
+		addf("ANALYTICS_DEFAULT_FIELDS must name at least one of metrics, yoy, or sectors")
+	}
+	for _, field := range c.Analytics.DefaultFields {
+		if !field.Valid() {
+			addf("invalid ANALYTICS_DEFAULT_FIELDS entry %q; must be metrics, yoy, or sectors", field)
+		}
+	}
+	if c.Analytics.MaxBuckets < 0 {
+		addf("ANALYTICS_MAX_BUCKETS must not be negative, got %d", c.Analytics.MaxBuckets)
+	}
+	for _, entry := range c.Analytics.invalidYoYCacheFarms {
+		addf("invalid ANALYTICS_YOY_CACHE_FARMS entry %q; must be a positive farm ID", entry)
+	}
+	if len(c.Analytics.YoYCacheFarmIDs) > 0 && c.Analytics.YoYCacheInterval <= 0 {
+		addf("ANALYTICS_YOY_CACHE_INTERVAL must be positive when ANALYTICS_YOY_CACHE_FARMS is set, got %s", c.Analytics.YoYCacheInterval)
+	}
+	if c.Analytics.YoYCacheJitter < 0 {
+		addf("ANALYTICS_YOY_CACHE_JITTER must not be negative, got %s", c.Analytics.YoYCacheJitter)
+	}
+	for _, entry := range c.Analytics.invalidExcludedFarms {
+		addf("invalid ANALYTICS_EXCLUDED_FARMS entry %q; must be a positive farm ID", entry)
+	}
+	for _, entry := range c.Analytics.invalidPresets {
+		addf("invalid ANALYTICS_PRESETS entry %q; use name=YYYY-MM-DD..YYYY-MM-DD with start not after end and unique names", entry)
+	}
+	if c.Analytics.PartialStatus != http.StatusPartialContent && c.Analytics.PartialStatus != http.StatusOK {
+		addf("invalid ANALYTICS_PARTIAL_STATUS %d; must be 206 or 200", c.Analytics.PartialStatus)
+	}
+	if c.Analytics.HealthyEfficiency < 0 {
+		addf("ANALYTICS_HEALTHY_EFFICIENCY_THRESHOLD must not be negative, got %g", c.Analytics.HealthyEfficiency)
+	}
+	if c.Analytics.ConfidenceHighMinEvents < c.Analytics.ConfidenceMediumMinEvents {
+		addf("ANALYTICS_CONFIDENCE_HIGH_MIN_EVENTS (%d) must not be below ANALYTICS_CONFIDENCE_MEDIUM_MIN_EVENTS (%d)",
+			c.Analytics.ConfidenceHighMinEvents, c.Analytics.ConfidenceMediumMinEvents)
+	}
+
+	// Retention
+	if c.Retention.Days < 0 {
+		addf("DATA_RETENTION_DAYS must not be negative, got %d", c.Retention.Days)
+	}
+	if c.Retention.Days > 0 && c.Retention.Interval <= 0 {
+		addf("DATA_RETENTION_INTERVAL must be positive when retention is enabled, got %s", c.Retention.Interval)
+	}
+
+	// Import
+	if c.Import.MaxRecordsPerSection < 0 {
+		addf("IMPORT_MAX_RECORDS_PER_SECTION must not be negative, got %d", c.Import.MaxRecordsPerSection)
+	}
+
+	// Auth
+	for _, entry := range c.Auth.invalidAPIKeys {
+		addf("invalid API_KEYS entry %q; must be key or key:farm_id|farm_id", entry)
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
 }
 
 // Helper functions
@@ -148,6 +501,17 @@ func parseFloat64(value string, defaultVal float64) float64 {
 	return parsed
 }
 
+func parseBool(value string, defaultVal bool) bool {
+	if value == "" {
+		return defaultVal
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return defaultVal
+	}
+	return parsed
+}
+
 func parseDuration(value string, defaultVal string) time.Duration {
 	if value == "" {
 		value = defaultVal
@@ -159,3 +523,155 @@ func parseDuration(value string, defaultVal string) time.Duration {
 	}
 	return duration
 }
+
+// parseAnalyticsFields splits a comma-separated list of response sections; unset means every section
+// Entries are not checked here so that Validate can report each unknown one
+func parseAnalyticsFields(value string) model.AnalyticsFields {
+	if value == "" {
+		return model.AllAnalyticsFields
+	}
+	var fields model.AnalyticsFields
+	for _, item := range parseList(value) {
+		fields = append(fields, model.AnalyticsField(item))
+	}
+	return fields
+}
+
+// parseAuth reads API_KEYS entries of the form key (global) or key:1|2 (limited to farms 1 and 2)
+// Malformed entries are kept aside so that Validate can report each one
+func parseAuth(value string) AuthConfig {
+	auth := AuthConfig{APIKeys: map[string][]uint{}}
+	for _, entry := range parseList(value) {
+		key, scope, scoped := strings.Cut(entry, ":")
+		key = strings.TrimSpace(key)
+		if key == "" {
+			auth.invalidAPIKeys = append(auth.invalidAPIKeys, entry)
+			continue
+		}
+
+		var farmIDs []uint
+		valid := true
+		if scoped {
+			for _, item := range strings.Split(scope, "|") {
+				farmID, err := strconv.ParseUint(strings.TrimSpace(item), 10, 32)
+				if err != nil || farmID == 0 {
+					valid = false
+					break
+				}
+				farmIDs = append(farmIDs, uint(farmID))
+			}
+		}
+		if !valid {
+			auth.invalidAPIKeys = append(auth.invalidAPIKeys, entry)
+			continue
+		}
+		auth.APIKeys[key] = farmIDs
+	}
+	return auth
+}
+
+// parseFarmIDs reads a comma-separated list of farm IDs
+// Malformed entries are returned separately so that Validate can report each one
+func parseFarmIDs(value string) ([]uint, []string) {
+	var farmIDs []uint
+	var invalid []string
+	for _, item := range parseList(value) {
+		farmID, err := strconv.ParseUint(item, 10, 32)
+		if err != nil || farmID == 0 {
+			invalid = append(invalid, item)
+			continue
+		}
+		farmIDs = append(farmIDs, uint(farmID))
+	}
+	return farmIDs, invalid
+}
+
+// parsePresets parses comma-separated name=YYYY-MM-DD..YYYY-MM-DD entries into named date ranges
+// Returns the valid presets and the raw entries that are malformed, reversed or repeat a name
+func parsePresets(value string) (map[string]DateRange, []string) {
+	presets := make(map[string]DateRange)
+	var invalid []string
+	for _, item := range parseList(value) {
+		name, bounds, ok := strings.Cut(item, "=")
+		name = strings.TrimSpace(name)
+		startStr, endStr, ok2 := strings.Cut(bounds, "..")
+		if !ok || !ok2 || name == "" {
+			invalid = append(invalid, item)
+			continue
+		}
+		start, errStart := time.Parse("2006-01-02", strings.TrimSpace(startStr))
+		end, errEnd := time.Parse("2006-01-02", strings.TrimSpace(endStr))
+		if _, exists := presets[name]; errStart != nil || errEnd != nil || start.After(end) || exists {
+			invalid = append(invalid, item)
+			continue
+		}
+		presets[name] = DateRange{Start: start, End: end}
+	}
+	return presets, invalid
+}
+
+// headerName matches the HTTP header names REQUEST_ID_HEADER accepts
+var headerName = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+
+// sqlIdentifier matches the index and column names DB_EXTRA_INDEXES accepts; they are spliced into DDL
+var sqlIdentifier = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// modelIndexNames returns the index names the models declare; AutoMigrate creates them on every start,
+// so a DB_EXTRA_INDEXES entry reusing one would be rebuilt and dropped again (or silently skipped)
+func modelIndexNames() map[string]bool {
+	names := make(map[string]bool)
+	cache := &sync.Map{}
+	for _, m := range []any{&model.Farm{}, &model.IrrigationSector{}, &model.IrrigationData{}, &model.IrrigationDailySummary{}} {
+		sch, err := schema.Parse(m, cache, schema.NamingStrategy{})
+		if err != nil {
+			continue // AutoMigrate reports the same model error at startup
+		}
+		for _, index := range sch.ParseIndexes() {
+			names[index.Name] = true
+		}
+	}
+	return names
+}
+
+// parseIndexSpecs parses comma-separated name=column+column entries (create) and -name entries (drop)
+// Invalid entries, including repeated names and names the models declare, are returned separately for Validate to report
+func parseIndexSpecs(value string) ([]IndexSpec, []string) {
+	var specs []IndexSpec
+	var invalid []string
+	seen := modelIndexNames()
+	for _, item := range parseList(value) {
+		var spec IndexSpec
+		if name, ok := strings.CutPrefix(item, "-"); ok {
+			spec = IndexSpec{Name: strings.TrimSpace(name), Drop: true}
+		} else {
+			name, columns, _ := strings.Cut(item, "=")
+			spec.Name = strings.TrimSpace(name)
+			for _, column := range strings.Split(columns, "+") {
+				spec.Columns = append(spec.Columns, strings.TrimSpace(column))
+			}
+		}
+
+		valid := sqlIdentifier.MatchString(spec.Name) && !seen[spec.Name]
+		for _, column := range spec.Columns {
+			valid = valid && sqlIdentifier.MatchString(column)
+		}
+		if !valid {
+			invalid = append(invalid, item)
+			continue
+		}
+		seen[spec.Name] = true
+		specs = append(specs, spec)
+	}
+	return specs, invalid
+}
+
+// parseList splits a comma-separated value, trimming whitespace and dropping empty entries
+func parseList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
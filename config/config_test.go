@@ -0,0 +1,397 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sebaespinosa/test_NF/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad_DefaultAggregation(t *testing.T) {
+	t.Setenv("ANALYTICS_DEFAULT_AGGREGATION", "")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, model.AggregationDaily, cfg.Analytics.DefaultAggregation)
+}
+
+func TestLoad_ConfiguredAggregation(t *testing.T) {
+	t.Setenv("ANALYTICS_DEFAULT_AGGREGATION", "weekly")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, model.AggregationWeekly, cfg.Analytics.DefaultAggregation)
+}
+
+func TestLoad_InvalidAggregation(t *testing.T) {
+	t.Setenv("ANALYTICS_DEFAULT_AGGREGATION", "hourly")
+
+	_, err := Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ANALYTICS_DEFAULT_AGGREGATION")
+}
+
+func TestLoad_DefaultFields(t *testing.T) {
+	t.Setenv("ANALYTICS_DEFAULT_FIELDS", "")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, model.AllAnalyticsFields, cfg.Analytics.DefaultFields)
+
+	t.Setenv("ANALYTICS_DEFAULT_FIELDS", "metrics")
+
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, model.AnalyticsFields{model.AnalyticsFieldMetrics}, cfg.Analytics.DefaultFields)
+}
+
+func TestLoad_ShutdownTimeout(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected time.Duration
+	}{
+		{name: "default", value: "", expected: 30 * time.Second},
+		{name: "configured", value: "90s", expected: 90 * time.Second},
+		{name: "invalid falls back", value: "soon", expected: 30 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SERVER_SHUTDOWN_TIMEOUT", tt.value)
+
+			cfg, err := Load()
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, cfg.Server.ShutdownTimeout)
+		})
+	}
+}
+
+func TestLoad_SecurityHeaders(t *testing.T) {
+	t.Setenv("SECURITY_HEADERS_ENABLED", "")
+	t.Setenv("RESPONSE_CACHE_CONTROL", "")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.True(t, cfg.Server.SecurityHeaders)
+	assert.Equal(t, "no-cache", cfg.Server.CacheControl)
+
+	t.Setenv("SECURITY_HEADERS_ENABLED", "false")
+	t.Setenv("RESPONSE_CACHE_CONTROL", "private, max-age=60")
+
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.False(t, cfg.Server.SecurityHeaders)
+	assert.Equal(t, "private, max-age=60", cfg.Server.CacheControl)
+}
+
+func TestLoad_TracingRequired(t *testing.T) {
+	t.Setenv("TRACING_REQUIRED", "")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.False(t, cfg.Jaeger.Required)
+
+	t.Setenv("TRACING_REQUIRED", "true")
+
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.True(t, cfg.Jaeger.Required)
+}
+
+func TestLoad_AnalyticsDebugSQL(t *testing.T) {
+	t.Setenv("ANALYTICS_DEBUG_SQL", "")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.False(t, cfg.Analytics.LogSQL)
+
+	t.Setenv("ANALYTICS_DEBUG_SQL", "true")
+
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.True(t, cfg.Analytics.LogSQL)
+}
+
+func TestLoad_Timeouts(t *testing.T) {
+	t.Setenv("SERVER_REQUEST_TIMEOUT", "")
+	t.Setenv("DB_STATEMENT_TIMEOUT", "")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Second, cfg.Server.RequestTimeout)
+	assert.Equal(t, 5*time.Second, cfg.Database.StatementTimeout)
+	assert.Contains(t, cfg.Database.DSN, "statement_timeout=5000")
+
+	t.Setenv("DB_STATEMENT_TIMEOUT", "0s")
+
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.NotContains(t, cfg.Database.DSN, "statement_timeout")
+}
+
+func TestLoad_Replica(t *testing.T) {
+	t.Setenv("DB_HOST", "primary.db")
+	t.Setenv("DB_REPLICA_HOST", "")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Database.ReplicaDSN)
+
+	t.Setenv("DB_REPLICA_HOST", "replica.db")
+
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Contains(t, cfg.Database.DSN, "host=primary.db ")
+	assert.Equal(t, strings.Replace(cfg.Database.DSN, "host=primary.db ", "host=replica.db ", 1), cfg.Database.ReplicaDSN)
+}
+
+func TestLoad_Presets(t *testing.T) {
+	t.Setenv("ANALYTICS_PRESETS", "growing_season_2024=2024-03-01..2024-09-30, winter_2023 = 2023-06-01..2023-08-31")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]DateRange{
+		"growing_season_2024": {Start: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2024, 9, 30, 0, 0, 0, 0, time.UTC)},
+		"winter_2023":         {Start: time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2023, 8, 31, 0, 0, 0, 0, time.UTC)},
+	}, cfg.Analytics.Presets)
+}
+
+func TestLoad_ExtraIndexes(t *testing.T) {
+	t.Setenv("DB_EXTRA_INDEXES", "idx_data_sector_start=irrigation_sector_id+start_time, -idx_old_farm")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, []IndexSpec{
+		{Name: "idx_data_sector_start", Columns: []string{"irrigation_sector_id", "start_time"}},
+		{Name: "idx_old_farm", Drop: true},
+	}, cfg.Database.ExtraIndexes)
+}
+
+func TestLoad_TrustedProxies(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.0/8, 192.168.1.10,,")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.0/8", "192.168.1.10"}, cfg.Server.TrustedProxies)
+}
+
+func TestLoad_NoTrustedProxies(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Server.TrustedProxies)
+}
+
+func TestLoad_APIKeys(t *testing.T) {
+	t.Setenv("API_KEYS", "ops-key, acme-key:1|4,,")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]uint{"ops-key": nil, "acme-key": {1, 4}}, cfg.Auth.APIKeys)
+
+	t.Setenv("API_KEYS", "")
+
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Auth.APIKeys)
+}
+
+func TestLoad_ValidBaseline(t *testing.T) {
+	t.Setenv("SERVER_PORT", "9090")
+	t.Setenv("DB_NAME", "irrigation_test")
+	t.Setenv("DB_USER", "tester")
+	t.Setenv("DB_MAX_OPEN_CONNS", "10")
+	t.Setenv("DB_MAX_IDLE_CONNS", "2")
+	t.Setenv("DB_CONN_MAX_LIFETIME", "1m")
+	t.Setenv("JAEGER_SAMPLER_TYPE", "probabilistic")
+	t.Setenv("JAEGER_SAMPLER_PARAM", "0.25")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, 9090, cfg.Server.Port)
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestLoad_InvalidCombinations(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		problems []string
+	}{
+		{
+			name:     "zero port",
+			env:      map[string]string{"SERVER_PORT": "0"},
+			problems: []string{"SERVER_PORT"},
+		},
+		{
+			name:     "ports out of range",
+			env:      map[string]string{"SERVER_PORT": "70000", "DB_PORT": "-1"},
+			problems: []string{"SERVER_PORT must be between 1 and 65535, got 70000", "DB_PORT must be between 1 and 65535, got -1"},
+		},
+		{
+			name:     "empty pool and lifetime",
+			env:      map[string]string{"DB_MAX_OPEN_CONNS": "0", "DB_MAX_IDLE_CONNS": "-1", "DB_CONN_MAX_LIFETIME": "0s"},
+			problems: []string{"DB_MAX_OPEN_CONNS", "DB_MAX_IDLE_CONNS", "DB_CONN_MAX_LIFETIME"},
+		},
+		{
+			name:     "statement timeout not below request timeout",
+			env:      map[string]string{"DB_STATEMENT_TIMEOUT": "30s", "SERVER_REQUEST_TIMEOUT": "30s"},
+			problems: []string{"DB_STATEMENT_TIMEOUT"},
+		},
+		{
+			name:     "negative timeouts",
+			env:      map[string]string{"SERVER_REQUEST_TIMEOUT": "-1s", "DB_STATEMENT_TIMEOUT": "-1s"},
+			problems: []string{"SERVER_REQUEST_TIMEOUT", "DB_STATEMENT_TIMEOUT"},
+		},
+		{
+			name:     "more warm connections than idle ones",
+			env:      map[string]string{"DB_MAX_IDLE_CONNS": "2", "DB_MIN_WARM_CONNS": "3"},
+			problems: []string{"DB_MIN_WARM_CONNS"},
+		},
+		{
+			name:     "no health failure threshold",
+			env:      map[string]string{"HEALTH_FAILURE_THRESHOLD": "0"},
+			problems: []string{"HEALTH_FAILURE_THRESHOLD"},
+		},
+		{
+			name:     "body sample rate above one and no body allowance",
+			env:      map[string]string{"DEBUG_BODY_SAMPLE_RATE": "1.5", "DEBUG_BODY_MAX_BYTES": "0"},
+			problems: []string{"DEBUG_BODY_SAMPLE_RATE", "DEBUG_BODY_MAX_BYTES"},
+		},
+		{
+			name:     "malformed YoY cache farms and no refresh interval",
+			env:      map[string]string{"ANALYTICS_YOY_CACHE_FARMS": "1,abc,0", "ANALYTICS_YOY_CACHE_INTERVAL": "0s", "ANALYTICS_YOY_CACHE_JITTER": "-1m"},
+			problems: []string{`"abc"`, `"0"`, "ANALYTICS_YOY_CACHE_INTERVAL", "ANALYTICS_YOY_CACHE_JITTER"},
+		},
+		{
+			name:     "malformed, reversed and repeated presets",
+			env:      map[string]string{"ANALYTICS_PRESETS": "a=2024-01-01,b=2024-05-01..2024-04-01,c=2024-01-01..2024-01-31,c=2024-02-01..2024-02-29"},
+			problems: []string{`"a=2024-01-01"`, `"b=2024-05-01..2024-04-01"`, `"c=2024-02-01..2024-02-29"`},
+		},
+		{
+			name:     "request ID header with a space",
+			env:      map[string]string{"REQUEST_ID_HEADER": "X Correlation"},
+			problems: []string{"REQUEST_ID_HEADER"},
+		},
+		{
+			name:     "malformed and repeated extra indexes",
+			env:      map[string]string{"DB_EXTRA_INDEXES": "idx_a,idx_b=start_time;drop table,idx_c=farm_id,idx_c=start_time,-"},
+			problems: []string{`"idx_a"`, `"idx_b=start_time;drop table"`, `"idx_c=start_time"`, `"-"`},
+		},
+		{
+			name:     "extra indexes reusing model index names",
+			env:      map[string]string{"DB_EXTRA_INDEXES": "-idx_irrigation_farm_time,-idx_sector_farm,idx_irrigation_time=start_time"},
+			problems: []string{`"-idx_irrigation_farm_time"`, `"-idx_sector_farm"`, `"idx_irrigation_time=start_time"`},
+		},
+		{
+			name:     "malformed excluded farms",
+			env:      map[string]string{"ANALYTICS_EXCLUDED_FARMS": "3,x"},
+			problems: []string{"ANALYTICS_EXCLUDED_FARMS"},
+		},
+		{
+			name:     "unsupported partial status",
+			env:      map[string]string{"ANALYTICS_PARTIAL_STATUS": "203"},
+			problems: []string{"ANALYTICS_PARTIAL_STATUS"},
+		},
+		{
+			name:     "negative healthy efficiency threshold",
+			env:      map[string]string{"ANALYTICS_HEALTHY_EFFICIENCY_THRESHOLD": "-0.1"},
+			problems: []string{"ANALYTICS_HEALTHY_EFFICIENCY_THRESHOLD"},
+		},
+		{
+			name:     "no decompressed body allowance",
+			env:      map[string]string{"REQUEST_MAX_DECOMPRESSED_BYTES": "0"},
+			problems: []string{"REQUEST_MAX_DECOMPRESSED_BYTES"},
+		},
+		{
+			name:     "blank database identity",
+			env:      map[string]string{"DB_NAME": " ", "DB_USER": " "},
+			problems: []string{"DB_NAME", "DB_USER"},
+		},
+		{
+			name:     "const sampler with a ratio",
+			env:      map[string]string{"JAEGER_SAMPLER_TYPE": "const", "JAEGER_SAMPLER_PARAM": "0.5"},
+			problems: []string{"JAEGER_SAMPLER_PARAM"},
+		},
+		{
+			name:     "probabilistic sampler above 1",
+			env:      map[string]string{"JAEGER_SAMPLER_TYPE": "probabilistic", "JAEGER_SAMPLER_PARAM": "2"},
+			problems: []string{"JAEGER_SAMPLER_PARAM"},
+		},
+		{
+			name:     "unknown sampler and aggregation together",
+			env:      map[string]string{"JAEGER_SAMPLER_TYPE": "sometimes", "ANALYTICS_DEFAULT_AGGREGATION": "hourly"},
+			problems: []string{"JAEGER_SAMPLER_TYPE", "ANALYTICS_DEFAULT_AGGREGATION"},
+		},
+		{
+			name:     "unknown default field",
+			env:      map[string]string{"ANALYTICS_DEFAULT_FIELDS": "metrics,forecast"},
+			problems: []string{"ANALYTICS_DEFAULT_FIELDS"},
+		},
+		{
+			name:     "empty default fields",
+			env:      map[string]string{"ANALYTICS_DEFAULT_FIELDS": " , "},
+			problems: []string{"ANALYTICS_DEFAULT_FIELDS"},
+		},
+		{
+			name:     "negative bucket cap",
+			env:      map[string]string{"ANALYTICS_MAX_BUCKETS": "-1"},
+			problems: []string{"ANALYTICS_MAX_BUCKETS"},
+		},
+		{
+			name:     "default limit above the max limit",
+			env:      map[string]string{"ANALYTICS_MAX_LIMIT": "100", "ANALYTICS_DEFAULT_LIMIT": "200"},
+			problems: []string{"ANALYTICS_DEFAULT_LIMIT"},
+		},
+		{
+			name:     "no max limit",
+			env:      map[string]string{"ANALYTICS_MAX_LIMIT": "0"},
+			problems: []string{"ANALYTICS_MAX_LIMIT"},
+		},
+		{
+			name:     "negative import limit",
+			env:      map[string]string{"IMPORT_MAX_RECORDS_PER_SECTION": "-1"},
+			problems: []string{"IMPORT_MAX_RECORDS_PER_SECTION"},
+		},
+		{
+			name:     "malformed API keys",
+			env:      map[string]string{"API_KEYS": "ok-key,:1,bad-key:1|x"},
+			problems: []string{"API_KEYS", "API_KEYS"},
+		},
+		{
+			name:     "negative retention",
+			env:      map[string]string{"DATA_RETENTION_DAYS": "-30"},
+			problems: []string{"DATA_RETENTION_DAYS"},
+		},
+		{
+			name:     "retention without an interval",
+			env:      map[string]string{"DATA_RETENTION_DAYS": "30", "DATA_RETENTION_INTERVAL": "0s"},
+			problems: []string{"DATA_RETENTION_INTERVAL"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			_, err := Load()
+			require.Error(t, err)
+
+			var validationErr *ValidationError
+			require.True(t, errors.As(err, &validationErr))
+			require.Len(t, validationErr.Problems, len(tt.problems))
+			for i, variable := range tt.problems {
+				assert.Contains(t, validationErr.Problems[i], variable)
+				assert.Contains(t, err.Error(), variable)
+			}
+		})
+	}
+}
@@ -2,31 +2,66 @@ package controller
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sebaespinosa/test_NF/config"
+	"github.com/sebaespinosa/test_NF/internal/database"
+	"github.com/sebaespinosa/test_NF/internal/features"
+	"github.com/sebaespinosa/test_NF/internal/middleware"
 	"github.com/sebaespinosa/test_NF/model"
 	"github.com/sebaespinosa/test_NF/service"
 )
 
 // AnalyticsService is the contract the controller depends on (facilitates mocking in tests).
 type AnalyticsService interface {
-	GetAnalytics(ctx context.Context, farmID uint, startDate, endDate *time.Time, sectorID *uint, aggregation string, page, limit int) (*model.IrrigationAnalyticsResponse, error)
+	GetAnalytics(ctx context.Context, farmID uint, startDate, endDate *time.Time, sectorID *uint, aggregation model.Aggregation, page, limit int, opts model.AnalyticsOptions) (*model.IrrigationAnalyticsResponse, error)
+	GetEfficiencyHeatmap(ctx context.Context, farmID uint, startDate, endDate *time.Time, aggregation model.Aggregation) (*model.EfficiencyHeatmapResponse, error)
+	GetAlerts(ctx context.Context, farmID uint, startDate, endDate *time.Time) (*model.IrrigationAlertsResponse, error)
+	GetEfficiencyByAmount(ctx context.Context, farmID uint, startDate, endDate *time.Time, bins int) (*model.EfficiencyByAmountResponse, error)
+	GetWaterSavings(ctx context.Context, farmID uint, startDate, endDate *time.Time) (*model.WaterSavingsResponse, error)
+	GetTopIrrigationDays(ctx context.Context, farmID uint, startDate, endDate *time.Time, n int) (*model.TopIrrigationDaysResponse, error)
+	GetSectorRanking(ctx context.Context, farmID uint, startDate, endDate *time.Time, page, limit int) (*model.SectorRankingResponse, error)
+	GetMultiFarmSectorBreakdown(ctx context.Context, farmIDs []uint, startDate, endDate *time.Time) (*model.MultiFarmSectorBreakdownResponse, error)
+	GetDayOfWeekDistribution(ctx context.Context, farmID uint, startDate, endDate *time.Time) (*model.DayOfWeekDistributionResponse, error)
+	GetScheduleAdherence(ctx context.Context, farmID uint, startDate, endDate *time.Time) (*model.ScheduleAdherenceResponse, error)
+	RecommendAggregation(ctx context.Context, farmID uint, startDate, endDate *time.Time) *model.AggregationRecommendationResponse
 }
 
+// analyticsQueryParams are the query parameters GetAnalytics understands
+var analyticsQueryParams = []string{
+	"start_date", "end_date", "sector_id", "aggregation", "page", "limit",
+	"whole_days_only", "empty", "forecast", "cumulative", "exclude_today", "anomalies_only",
+	"sector_page", "sector_limit", "compare", "efficiency_basis", "fields", "min_real", "max_real", "include", "consistency",
+	"group_by", "preset", "sector_sort", "sector_order",
+}
+
+// globalQueryParams are accepted on every route: strict itself and those read by middleware
+var globalQueryParams = []string{"strict", "debug_timing"}
+
 // AnalyticsController handles HTTP requests for irrigation analytics
 type AnalyticsController struct {
-	service AnalyticsService
+	service  AnalyticsService
+	cfg      *config.AnalyticsConfig
+	features features.Flags
 }
 
 // NewAnalyticsController creates a new AnalyticsController instance
-func NewAnalyticsController(service *service.IrrigationAnalyticsService) *AnalyticsController {
-	return &AnalyticsController{service: service}
+func NewAnalyticsController(service *service.IrrigationAnalyticsService, cfg *config.AnalyticsConfig, flags features.Flags) *AnalyticsController {
+	return &AnalyticsController{service: service, cfg: cfg, features: flags}
 }
 
-// GetAnalytics handles GET /v1/farms/:farm_id/irrigation/analytics requests
+// GetAnalytics handles GET and HEAD /v1/farms/:farm_id/irrigation/analytics requests
 // @Summary Get irrigation analytics for a farm
 // @Description Returns comprehensive irrigation analytics with year-over-year comparison, time-series data, and sector breakdown
 // @Tags analytics
@@ -34,76 +69,93 @@ func NewAnalyticsController(service *service.IrrigationAnalyticsService) *Analyt
 // @Param farm_id path int true "Farm ID" example(1)
 // @Param start_date query string false "Start date (YYYY-MM-DD format, defaults to 90 days ago)" example(2024-01-01)
 // @Param end_date query string false "End date (YYYY-MM-DD format, defaults to today)" example(2024-01-31)
+// @Param preset query string false "Named date range configured in ANALYTICS_PRESETS, instead of start_date and end_date; unknown names are a 400" example(growing_season_2024)
 // @Param sector_id query int false "Filter by specific irrigation sector (optional)" example(5)
-// @Param aggregation query string false "Aggregation granularity: daily, weekly, monthly (default: daily)" example(daily) enums(daily,weekly,monthly)
+// @Param aggregation query string false "Aggregation granularity: daily, weekly, monthly (default: ANALYTICS_DEFAULT_AGGREGATION, daily)" example(daily) enums(daily,weekly,monthly)
 // @Param page query int false "Page number for time-series results (1-indexed, default: 1)" example(1)
-// @Param limit query int false "Results per page (default: 50, max: 1000, use 'all' for all results)" example(50)
+// @Param limit query string false "Results per page (default: ANALYTICS_DEFAULT_LIMIT, 50; capped at ANALYTICS_MAX_LIMIT, 1000; 'all' for all results up to ANALYTICS_MAX_BUCKETS, which adds a warnings entry with the bucket count; 0 or negative is a 400)" example(50)
+// @Param whole_days_only query bool false "End the range before the in-progress UTC day, in every section (default: false)" example(true)
+// @Param empty query string false "Set to 204 to answer 204 No Content when the range has no events (default: 200 with has_data=false)" example(204)
+// @Param forecast query bool false "Add a linear projection of the next bucket's real amount (default: false)" example(true)
+// @Param cumulative query bool false "Make each time-series bucket's amounts running totals from the period start; per-bucket values move to bucket_*_amount_mm (default: false)" example(true)
+// @Param exclude_today query bool false "End the range before the current (still incomplete) day, week or month bucket; metrics, sectors and YoY follow the shortened range (default: false)" example(true)
+// @Param anomalies_only query bool false "Return only the time-series buckets flagged anomalous (efficiency more than two standard deviations from the period mean); pagination counts the anomalous buckets, metrics still cover every bucket of the period, whichever page (default: false)" example(true)
+// @Param sector_page query int false "Sector breakdown page (1-indexed, default: 1); paginates sectors independently of the time-series" example(1)
+// @Param sector_limit query int false "Sectors per page (default: ANALYTICS_DEFAULT_LIMIT, 50; capped at ANALYTICS_MAX_LIMIT, 1000; 0 or negative is a 400); all sectors are returned when neither sector param is given" example(20)
+// @Param compare query string false "Comparison baseline: yoy (default) or prev_window, which adds the preceding window of equal length and bases 206 on it" example(prev_window) enums(yoy,prev_window)
+// @Param efficiency_basis query string false "Efficiency behind efficiency_change_percent: average (default, mean of bucket efficiencies) or weighted (total real / total nominal)" example(weighted) enums(average,weighted)
+// @Param fields query string false "Comma-separated sections: metrics, yoy, sectors; sections left out are not queried and come back null (default: ANALYTICS_DEFAULT_FIELDS, all)" example(metrics,sectors)
+// @Param min_real query number false "Only include events whose real_amount is at least this many mm; changes every total, metric and comparison" example(10)
+// @Param max_real query number false "Only include events whose real_amount is at most this many mm; must not be below min_real" example(50)
+// @Param include query string false "Comma-separated extra sections: quality adds data_quality (zero-nominal, over-irrigation and duplicate-suspect counts, completeness); stacked_timeseries adds stacked_timeseries (real and nominal sums per sector within each bucket)" example(quality,stacked_timeseries)
+// @Param consistency query string false "Where to read from when a replica is configured: replica (default, may lag) or strong (primary; skips the YoY cache)" example(strong) enums(replica,strong)
+// @Param group_by query string false "Sector breakdown unit: sector (default) or zone, which rolls child sectors up into their top-level parent; sector_id then selects a zone" example(zone) enums(sector,zone)
+// @Param sector_sort query string false "Sector breakdown order: id (default), name, volume or efficiency; sectors without an efficiency come last" example(volume) enums(id,name,volume,efficiency)
+// @Param sector_order query string false "Sort direction: asc or desc (default: desc for volume and efficiency, asc otherwise)" example(desc) enums(asc,desc)
+// @Param strict query bool false "Reject unknown query parameters with 400 (default: ANALYTICS_STRICT_QUERY_PARAMS)" example(true)
 // @Success 200 {object} model.IrrigationAnalyticsResponse "Analytics data with complete year-over-year comparison"
-// @Success 206 {object} model.IrrigationAnalyticsResponse "Partial content - previous year data incomplete or missing"
-// @Failure 400 {object} map[string]string "Invalid request parameters or date format"
-// @Failure 404 {object} map[string]string "Farm not found"
-// @Failure 500 {object} map[string]string "Internal server error"
+// @Success 206 {object} model.IrrigationAnalyticsResponse "Partial content - previous year data (or previous window with compare=prev_window) incomplete or missing; 200 instead with ANALYTICS_PARTIAL_STATUS=200"
+// @Header 200,206 {string} X-Data-Complete "false when the comparison baseline is incomplete (the status is 206 unless ANALYTICS_PARTIAL_STATUS=200), true otherwise"
+// @Header 200,206 {string} ETag "Hash of the response body; HEAD still runs every query and encodes the whole response to compute it, then returns it with the other headers and no body"
+// @Param If-None-Match header string false "ETag of a previous response; returns 304 without a body when it still matches (the queries still run)"
+// @Success 304 "Response unchanged since the If-None-Match ETag"
+// @Success 204 "No events in the range (only with empty=204)"
+// @Failure 400 {object} model.APIError "Invalid request parameters or date format, or sector_id belongs to another farm"
+// @Failure 404 {object} model.APIError "Farm excluded from analytics, or sector_id not found"
+// @Failure 413 {object} model.APIError "Estimated response (time-series page, sector breakdown and sparklines, stacked time-series) exceeds ANALYTICS_MAX_RESPONSE_BYTES"
+// @Failure 500 {object} model.APIError "Internal server error"
+// @Failure 501 {object} model.APIError "forecast=true while FEATURE_FORECAST is off"
+// @Failure 504 {object} model.APIError "Database query timed out"
 // @Router /v1/farms/{farm_id}/irrigation/analytics [get]
+// @Router /v1/farms/{farm_id}/irrigation/analytics [head]
 func (c *AnalyticsController) GetAnalytics(ctx *gin.Context) {
+	if !c.checkQueryParams(ctx, analyticsQueryParams...) {
+		return
+	}
+
 	// Parse farm_id from path
-	farmIDStr := ctx.Param("farm_id")
-	farmID, err := strconv.ParseUint(farmIDStr, 10, 32)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid farm_id format"})
+	farmID, ok := parseFarmID(ctx)
+	if !ok {
 		return
 	}
 
 	// Parse optional query parameters
-	startDateStr := ctx.Query("start_date")
-	endDateStr := ctx.Query("end_date")
 	sectorIDStr := ctx.Query("sector_id")
-	aggregation := ctx.DefaultQuery("aggregation", "daily")
-	pageStr := ctx.DefaultQuery("page", "1")
-	limitStr := ctx.DefaultQuery("limit", "50")
 
 	// Validate aggregation parameter
-	if aggregation != "daily" && aggregation != "weekly" && aggregation != "monthly" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid aggregation type; must be daily, weekly, or monthly"})
+	aggregation, ok := c.parseAggregation(ctx)
+	if !ok {
 		return
 	}
 
 	// Parse page and limit
-	page, err := strconv.Atoi(pageStr)
-	if err != nil || page < 1 {
-		page = 1
+	page, limit, ok := newPageLimits(c.cfg).parsePagination(ctx)
+	if !ok {
+		return
 	}
 
-	limit := 50
-	if limitStr == "all" {
-		limit = 10000 // High limit for "all" results
-	} else {
-		limInt, err := strconv.Atoi(limitStr)
-		if err == nil && limInt > 0 {
-			if limInt > 1000 {
-				limInt = 1000 // Cap at 1000
-			}
-			limit = limInt
-		}
+	// Parse dates if provided (format: YYYY-MM-DD)
+	startDate, ok := parseDateQuery(ctx, "start_date")
+	if !ok {
+		return
+	}
+	endDate, ok := parseDateQuery(ctx, "end_date")
+	if !ok {
+		return
 	}
 
-	// Parse dates if provided (format: YYYY-MM-DD)
-	var startDate, endDate *time.Time
-	if startDateStr != "" {
-		parsedStart, err := time.Parse("2006-01-02", startDateStr)
-		if err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid start_date format; use YYYY-MM-DD"})
+	// Resolve an optional named preset into its configured date range
+	if presetName := ctx.Query("preset"); presetName != "" {
+		if startDate != nil || endDate != nil {
+			respondError(ctx, http.StatusBadRequest, "preset cannot be combined with start_date or end_date")
 			return
 		}
-		startDate = &parsedStart
-	}
-
-	if endDateStr != "" {
-		parsedEnd, err := time.Parse("2006-01-02", endDateStr)
-		if err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid end_date format; use YYYY-MM-DD"})
+		preset, found := c.cfg.Presets[presetName]
+		if !found {
+			respondError(ctx, http.StatusBadRequest, "unknown preset "+strconv.Quote(presetName))
 			return
 		}
-		endDate = &parsedEnd
+		startDate, endDate = &preset.Start, &preset.End
 	}
 
 	// Parse optional sector_id filter
@@ -111,34 +163,861 @@ func (c *AnalyticsController) GetAnalytics(ctx *gin.Context) {
 	if sectorIDStr != "" {
 		sectorIDUint, err := strconv.ParseUint(sectorIDStr, 10, 32)
 		if err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid sector_id format"})
+			respondError(ctx, http.StatusBadRequest, "invalid sector_id format")
 			return
 		}
 		sectorID = (*uint)(&[]uint{uint(sectorIDUint)}[0])
 	}
 
+	var opts model.AnalyticsOptions
+	opts.UnboundedLimit = ctx.Query("limit") == "all"
+
+	// Parse optional whole_days_only flag
+	if wholeDaysStr := ctx.Query("whole_days_only"); wholeDaysStr != "" {
+		wholeDaysOnly, err := strconv.ParseBool(wholeDaysStr)
+		if err != nil {
+			respondError(ctx, http.StatusBadRequest, "invalid whole_days_only; use true or false")
+			return
+		}
+		opts.WholeDaysOnly = wholeDaysOnly
+	}
+
+	// Parse optional empty-range behavior: empty=204 answers 204 No Content instead of zeroed metrics
+	emptyNoContent := false
+	if emptyStr := ctx.Query("empty"); emptyStr != "" {
+		if emptyStr != "204" {
+			respondError(ctx, http.StatusBadRequest, "invalid empty; the only supported value is 204")
+			return
+		}
+		emptyNoContent = true
+	}
+
+	// Parse optional forecast flag
+	if forecastStr := ctx.Query("forecast"); forecastStr != "" {
+		forecast, err := strconv.ParseBool(forecastStr)
+		if err != nil {
+			respondError(ctx, http.StatusBadRequest, "invalid forecast; use true or false")
+			return
+		}
+		if forecast && !c.features.Forecast {
+			respondError(ctx, http.StatusNotImplemented, "forecast is disabled on this server")
+			return
+		}
+		opts.Forecast = forecast
+	}
+
+	// Parse optional cumulative flag
+	if cumulativeStr := ctx.Query("cumulative"); cumulativeStr != "" {
+		cumulative, err := strconv.ParseBool(cumulativeStr)
+		if err != nil {
+			respondError(ctx, http.StatusBadRequest, "invalid cumulative; use true or false")
+			return
+		}
+		opts.Cumulative = cumulative
+	}
+
+	// Parse optional flag dropping the in-progress bucket
+	if excludeTodayStr := ctx.Query("exclude_today"); excludeTodayStr != "" {
+		excludeToday, err := strconv.ParseBool(excludeTodayStr)
+		if err != nil {
+			respondError(ctx, http.StatusBadRequest, "invalid exclude_today; use true or false")
+			return
+		}
+		opts.ExcludeToday = excludeToday
+	}
+
+	// Parse optional flag keeping only the anomalous buckets
+	if anomaliesOnlyStr := ctx.Query("anomalies_only"); anomaliesOnlyStr != "" {
+		anomaliesOnly, err := strconv.ParseBool(anomaliesOnlyStr)
+		if err != nil {
+			respondError(ctx, http.StatusBadRequest, "invalid anomalies_only; use true or false")
+			return
+		}
+		opts.AnomaliesOnly = anomaliesOnly
+	}
+
+	// Parse optional real amount bounds restricting which events are aggregated
+	if opts.MinReal, ok = parseAmountQuery(ctx, "min_real"); !ok {
+		return
+	}
+	if opts.MaxReal, ok = parseAmountQuery(ctx, "max_real"); !ok {
+		return
+	}
+	if opts.MinReal != nil && opts.MaxReal != nil && *opts.MinReal > *opts.MaxReal {
+		respondError(ctx, http.StatusBadRequest, "min_real must not be greater than max_real")
+		return
+	}
+
+	// Parse optional extra sections
+	for _, section := range strings.Split(ctx.Query("include"), ",") {
+		switch strings.TrimSpace(section) {
+		case "":
+		case "quality":
+			opts.IncludeQuality = true
+		case "stacked_timeseries":
+			opts.IncludeStackedTimeSeries = true
+		default:
+			respondError(ctx, http.StatusBadRequest, "invalid include; must be a comma-separated list of quality, stacked_timeseries")
+			return
+		}
+	}
+
+	// Parse optional comparison baseline
+	opts.Compare = model.ComparisonMode(ctx.DefaultQuery("compare", string(model.ComparisonYoY)))
+	if !opts.Compare.Valid() {
+		respondError(ctx, http.StatusBadRequest, "invalid compare; must be yoy or prev_window")
+		return
+	}
+
+	// Parse optional efficiency basis of the period comparisons
+	opts.EfficiencyBasis = model.EfficiencyBasis(ctx.DefaultQuery("efficiency_basis", string(model.EfficiencyBasisAverage)))
+	if !opts.EfficiencyBasis.Valid() {
+		respondError(ctx, http.StatusBadRequest, "invalid efficiency_basis; must be average or weighted")
+		return
+	}
+
+	// Parse optional read consistency; replicas serve reads unless freshness matters
+	opts.Consistency = model.Consistency(ctx.DefaultQuery("consistency", string(model.ConsistencyReplica)))
+	if !opts.Consistency.Valid() {
+		respondError(ctx, http.StatusBadRequest, "invalid consistency; must be replica or strong")
+		return
+	}
+
+	// Parse optional sector breakdown grouping
+	opts.SectorGroupBy = model.SectorGroupBy(ctx.DefaultQuery("group_by", string(model.SectorGroupBySector)))
+	if !opts.SectorGroupBy.Valid() {
+		respondError(ctx, http.StatusBadRequest, "invalid group_by; must be sector or zone")
+		return
+	}
+
+	// Parse optional sector breakdown order; volume and efficiency default to largest first
+	opts.SectorSort.Field = model.SectorSortField(ctx.DefaultQuery("sector_sort", string(model.SectorSortID)))
+	if !opts.SectorSort.Field.Valid() {
+		respondError(ctx, http.StatusBadRequest, "invalid sector_sort; must be id, name, volume or efficiency")
+		return
+	}
+	switch ctx.Query("sector_order") {
+	case "":
+		opts.SectorSort.Descending = opts.SectorSort.Field.DescendingByDefault()
+	case "asc":
+		opts.SectorSort.Descending = false
+	case "desc":
+		opts.SectorSort.Descending = true
+	default:
+		respondError(ctx, http.StatusBadRequest, "invalid sector_order; must be asc or desc")
+		return
+	}
+
+	// Parse optional response sections; without fields the deployment default applies
+	if fieldsStr := ctx.Query("fields"); fieldsStr != "" {
+		fields, err := model.ParseAnalyticsFields(fieldsStr)
+		if err != nil {
+			respondError(ctx, http.StatusBadRequest, err.Error())
+			return
+		}
+		opts.Fields = fields
+	}
+
+	// Parse optional sector breakdown pagination (unpaginated unless either param is given)
+	if ctx.Query("sector_page") != "" || ctx.Query("sector_limit") != "" {
+		sectorPage, err := strconv.Atoi(ctx.DefaultQuery("sector_page", "1"))
+		if err != nil || sectorPage < 1 {
+			respondError(ctx, http.StatusBadRequest, "invalid sector_page; must be a positive integer")
+			return
+		}
+		sectorLimit, ok := newPageLimits(c.cfg).parseLimit(ctx, "sector_limit", false)
+		if !ok {
+			return
+		}
+		opts.SectorPage, opts.SectorLimit = sectorPage, sectorLimit
+	}
+
 	// Call service with request context
 	analytics, err := c.service.GetAnalytics(
 		ctx.Request.Context(),
-		uint(farmID),
+		farmID,
 		startDate,
 		endDate,
 		sectorID,
 		aggregation,
 		page,
 		limit,
+		opts,
 	)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch analytics: " + err.Error()})
+		var tooLargeErr *service.ResponseTooLargeError
+		if errors.As(err, &tooLargeErr) {
+			respondError(ctx, http.StatusRequestEntityTooLarge, tooLargeErr.Error())
+			return
+		}
+		var notInFarmErr *service.SectorNotInFarmError
+		if errors.As(err, &notInFarmErr) {
+			respondError(ctx, http.StatusBadRequest, notInFarmErr.Error())
+			return
+		}
+		if errors.Is(err, service.ErrSectorNotFound) {
+			respondError(ctx, http.StatusNotFound, err.Error())
+			return
+		}
+		respondServiceError(ctx, "failed to fetch analytics", err)
+		return
+	}
+
+	if emptyNoContent && !analytics.HasData {
+		ctx.Status(http.StatusNoContent)
 		return
 	}
 
-	// Determine status code based on YoY data availability
+	// Determine status code based on the availability of the requested baseline
+	var incomplete bool
+	if opts.Compare == model.ComparisonPrevWindow {
+		incomplete = analytics.PrevWindow != nil && analytics.PrevWindow.DataIncomplete
+	} else {
+		incomplete = (analytics.SamePeriod1Y != nil && analytics.SamePeriod1Y.DataIncomplete) ||
+			(analytics.SamePeriod2Y != nil && analytics.SamePeriod2Y.DataIncomplete)
+	}
+
+	// 206 conventionally means a byte range, so X-Data-Complete carries the signal for clients and
+	// proxies that misread it, and ANALYTICS_PARTIAL_STATUS=200 drops the 206 altogether
 	statusCode := http.StatusOK
-	if (analytics.SamePeriod1Y != nil && analytics.SamePeriod1Y.DataIncomplete) ||
-		(analytics.SamePeriod2Y != nil && analytics.SamePeriod2Y.DataIncomplete) {
-		statusCode = http.StatusPartialContent // 206
+	ctx.Header("X-Data-Complete", strconv.FormatBool(!incomplete))
+	if incomplete {
+		statusCode = c.partialStatus()
+	}
+
+	respondJSONWithETag(ctx, statusCode, analytics)
+}
+
+// GetHeatmap handles GET /v1/farms/:farm_id/irrigation/heatmap requests
+// @Summary Get efficiency heatmap for a farm
+// @Description Returns a sector x time-bucket matrix of average efficiency; buckets without data are null
+// @Tags analytics
+// @Produce json
+// @Param farm_id path int true "Farm ID" example(1)
+// @Param start query string false "Start date (YYYY-MM-DD format, defaults to 90 days ago)" example(2024-01-01)
+// @Param end query string false "End date (YYYY-MM-DD format, defaults to today)" example(2024-01-31)
+// @Param aggregation query string false "Aggregation granularity: daily, weekly, monthly (default: ANALYTICS_DEFAULT_AGGREGATION, daily)" example(weekly) enums(daily,weekly,monthly)
+// @Param strict query bool false "Reject unknown query parameters with 400 (default: ANALYTICS_STRICT_QUERY_PARAMS)" example(true)
+// @Success 200 {object} model.EfficiencyHeatmapResponse "Efficiency matrix"
+// @Failure 400 {object} model.APIError "Invalid request parameters or date format"
+// @Failure 404 {object} model.APIError "Farm excluded from analytics"
+// @Failure 500 {object} model.APIError "Internal server error"
+// @Failure 504 {object} model.APIError "Database query timed out"
+// @Router /v1/farms/{farm_id}/irrigation/heatmap [get]
+func (c *AnalyticsController) GetHeatmap(ctx *gin.Context) {
+	if !c.checkQueryParams(ctx, "aggregation", "start", "end") {
+		return
+	}
+
+	farmID, ok := parseFarmID(ctx)
+	if !ok {
+		return
+	}
+
+	aggregation, ok := c.parseAggregation(ctx)
+	if !ok {
+		return
+	}
+
+	startDate, ok := parseDateQuery(ctx, "start")
+	if !ok {
+		return
+	}
+	endDate, ok := parseDateQuery(ctx, "end")
+	if !ok {
+		return
+	}
+
+	heatmap, err := c.service.GetEfficiencyHeatmap(ctx.Request.Context(), farmID, startDate, endDate, aggregation)
+	if err != nil {
+		respondServiceError(ctx, "failed to fetch heatmap", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, heatmap)
+}
+
+// GetAlerts handles GET /v1/farms/:farm_id/irrigation/alerts requests
+// @Summary Get sector irrigation alerts for a farm
+// @Description Returns sectors whose average efficiency fell below their target efficiency or whose deficit (nominal - real) exceeded the configured threshold
+// @Tags analytics
+// @Produce json
+// @Param farm_id path int true "Farm ID" example(1)
+// @Param start query string false "Start date (YYYY-MM-DD format, defaults to 90 days ago)" example(2024-01-01)
+// @Param end query string false "End date (YYYY-MM-DD format, defaults to today)" example(2024-01-31)
+// @Param strict query bool false "Reject unknown query parameters with 400 (default: ANALYTICS_STRICT_QUERY_PARAMS)" example(true)
+// @Success 200 {object} model.IrrigationAlertsResponse "Triggered alerts"
+// @Failure 400 {object} model.APIError "Invalid request parameters or date format"
+// @Failure 404 {object} model.APIError "Farm excluded from analytics"
+// @Failure 500 {object} model.APIError "Internal server error"
+// @Failure 504 {object} model.APIError "Database query timed out"
+// @Router /v1/farms/{farm_id}/irrigation/alerts [get]
+func (c *AnalyticsController) GetAlerts(ctx *gin.Context) {
+	if !c.checkQueryParams(ctx, "start", "end") {
+		return
+	}
+
+	farmID, ok := parseFarmID(ctx)
+	if !ok {
+		return
+	}
+
+	startDate, ok := parseDateQuery(ctx, "start")
+	if !ok {
+		return
+	}
+	endDate, ok := parseDateQuery(ctx, "end")
+	if !ok {
+		return
 	}
 
-	ctx.JSON(statusCode, analytics)
+	alerts, err := c.service.GetAlerts(ctx.Request.Context(), farmID, startDate, endDate)
+	if err != nil {
+		respondServiceError(ctx, "failed to fetch alerts", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, alerts)
+}
+
+// GetWaterSavings handles GET /v1/farms/:farm_id/irrigation/water-savings requests
+// @Summary Estimate potential water savings for a farm
+// @Description Estimates the volume each sector would have saved by irrigating at its target efficiency (real * (1 - average/target)); sectors without a target or valid efficiency data are listed with a note and excluded from the total
+// @Tags analytics
+// @Produce json
+// @Param farm_id path int true "Farm ID" example(1)
+// @Param start query string false "Start date (YYYY-MM-DD format, defaults to 90 days ago)" example(2024-01-01)
+// @Param end query string false "End date (YYYY-MM-DD format, defaults to today)" example(2024-01-31)
+// @Param strict query bool false "Reject unknown query parameters with 400 (default: ANALYTICS_STRICT_QUERY_PARAMS)" example(true)
+// @Success 200 {object} model.WaterSavingsResponse "Potential savings"
+// @Failure 400 {object} model.APIError "Invalid request parameters or date format"
+// @Failure 404 {object} model.APIError "Farm excluded from analytics"
+// @Failure 500 {object} model.APIError "Internal server error"
+// @Failure 504 {object} model.APIError "Database query timed out"
+// @Router /v1/farms/{farm_id}/irrigation/water-savings [get]
+func (c *AnalyticsController) GetWaterSavings(ctx *gin.Context) {
+	if !c.checkQueryParams(ctx, "start", "end") {
+		return
+	}
+
+	farmID, ok := parseFarmID(ctx)
+	if !ok {
+		return
+	}
+
+	startDate, ok := parseDateQuery(ctx, "start")
+	if !ok {
+		return
+	}
+	endDate, ok := parseDateQuery(ctx, "end")
+	if !ok {
+		return
+	}
+
+	savings, err := c.service.GetWaterSavings(ctx.Request.Context(), farmID, startDate, endDate)
+	if err != nil {
+		respondServiceError(ctx, "failed to estimate water savings", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, savings)
+}
+
+// GetTopDays handles GET /v1/farms/:farm_id/irrigation/top-days requests
+// @Summary Get the largest irrigation days for a farm
+// @Description Returns the N days with the highest total real irrigation amount, ordered descending
+// @Tags analytics
+// @Produce json
+// @Param farm_id path int true "Farm ID" example(1)
+// @Param start query string false "Start date (YYYY-MM-DD format, defaults to 90 days ago)" example(2024-01-01)
+// @Param end query string false "End date (YYYY-MM-DD format, defaults to today)" example(2024-01-31)
+// @Param n query int false "Number of days to return (default: 5, max: 100)" example(5)
+// @Param strict query bool false "Reject unknown query parameters with 400 (default: ANALYTICS_STRICT_QUERY_PARAMS)" example(true)
+// @Success 200 {object} model.TopIrrigationDaysResponse "Top irrigation days"
+// @Failure 400 {object} model.APIError "Invalid request parameters or date format"
+// @Failure 404 {object} model.APIError "Farm excluded from analytics"
+// @Failure 500 {object} model.APIError "Internal server error"
+// @Failure 504 {object} model.APIError "Database query timed out"
+// @Router /v1/farms/{farm_id}/irrigation/top-days [get]
+func (c *AnalyticsController) GetTopDays(ctx *gin.Context) {
+	if !c.checkQueryParams(ctx, "start", "end", "n") {
+		return
+	}
+
+	farmID, ok := parseFarmID(ctx)
+	if !ok {
+		return
+	}
+
+	startDate, ok := parseDateQuery(ctx, "start")
+	if !ok {
+		return
+	}
+	endDate, ok := parseDateQuery(ctx, "end")
+	if !ok {
+		return
+	}
+
+	n, err := strconv.Atoi(ctx.DefaultQuery("n", "5"))
+	if err != nil || n < 1 || n > 100 {
+		respondError(ctx, http.StatusBadRequest, "invalid n; must be between 1 and 100")
+		return
+	}
+
+	topDays, err := c.service.GetTopIrrigationDays(ctx.Request.Context(), farmID, startDate, endDate, n)
+	if err != nil {
+		respondServiceError(ctx, "failed to fetch top irrigation days", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, topDays)
+}
+
+// GetEfficiencyByAmount handles GET /v1/farms/:farm_id/irrigation/efficiency-vs-amount requests
+// @Summary Get efficiency by irrigation amount for a farm
+// @Description Splits the range from the smallest to the largest real amount into equal-width bins and returns each bin's event count and average efficiency, to show whether larger waterings are less efficient
+// @Tags analytics
+// @Produce json
+// @Param farm_id path int true "Farm ID" example(1)
+// @Param start query string false "Start date (YYYY-MM-DD format, defaults to 90 days ago)" example(2024-01-01)
+// @Param end query string false "End date (YYYY-MM-DD format, defaults to today)" example(2024-01-31)
+// @Param bins query int false "Number of amount bins (default: 10, max: 100)" example(10)
+// @Param strict query bool false "Reject unknown query parameters with 400 (default: ANALYTICS_STRICT_QUERY_PARAMS)" example(true)
+// @Success 200 {object} model.EfficiencyByAmountResponse "Efficiency per amount bin"
+// @Failure 400 {object} model.APIError "Invalid request parameters or date format"
+// @Failure 404 {object} model.APIError "Farm excluded from analytics"
+// @Failure 500 {object} model.APIError "Internal server error"
+// @Failure 504 {object} model.APIError "Database query timed out"
+// @Router /v1/farms/{farm_id}/irrigation/efficiency-vs-amount [get]
+func (c *AnalyticsController) GetEfficiencyByAmount(ctx *gin.Context) {
+	if !c.checkQueryParams(ctx, "start", "end", "bins") {
+		return
+	}
+
+	farmID, ok := parseFarmID(ctx)
+	if !ok {
+		return
+	}
+
+	startDate, ok := parseDateQuery(ctx, "start")
+	if !ok {
+		return
+	}
+	endDate, ok := parseDateQuery(ctx, "end")
+	if !ok {
+		return
+	}
+
+	bins, err := strconv.Atoi(ctx.DefaultQuery("bins", "10"))
+	if err != nil || bins < 1 || bins > 100 {
+		respondError(ctx, http.StatusBadRequest, "invalid bins; must be between 1 and 100")
+		return
+	}
+
+	distribution, err := c.service.GetEfficiencyByAmount(ctx.Request.Context(), farmID, startDate, endDate, bins)
+	if err != nil {
+		respondServiceError(ctx, "failed to fetch efficiency by amount", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, distribution)
+}
+
+// GetSectorRanking handles GET /v1/farms/:farm_id/irrigation/sectors/ranking requests
+// @Summary Rank a farm's sectors by efficiency
+// @Description Returns a paginated leaderboard of sectors ordered by average efficiency (best first). Ties are broken by total real volume (largest first) and then by sector ID, so the order is stable across requests and pages; sectors without a measurable efficiency rank last.
+// @Tags analytics
+// @Produce json
+// @Param farm_id path int true "Farm ID" example(1)
+// @Param start query string false "Start date (YYYY-MM-DD format, defaults to 90 days ago)" example(2024-01-01)
+// @Param end query string false "End date (YYYY-MM-DD format, defaults to today)" example(2024-01-31)
+// @Param page query int false "Page number (1-indexed, default: 1)" example(1)
+// @Param limit query string false "Sectors per page (default: ANALYTICS_DEFAULT_LIMIT, 50; capped at ANALYTICS_MAX_LIMIT, 1000; or 'all'; 0 or negative is a 400)" example(20)
+// @Param strict query bool false "Reject unknown query parameters with 400 (default: ANALYTICS_STRICT_QUERY_PARAMS)" example(true)
+// @Success 200 {object} model.SectorRankingResponse "Sector leaderboard"
+// @Failure 400 {object} model.APIError "Invalid request parameters or date format"
+// @Failure 404 {object} model.APIError "Farm excluded from analytics"
+// @Failure 500 {object} model.APIError "Internal server error"
+// @Failure 504 {object} model.APIError "Database query timed out"
+// @Router /v1/farms/{farm_id}/irrigation/sectors/ranking [get]
+func (c *AnalyticsController) GetSectorRanking(ctx *gin.Context) {
+	if !c.checkQueryParams(ctx, "start", "end", "page", "limit") {
+		return
+	}
+
+	farmID, ok := parseFarmID(ctx)
+	if !ok {
+		return
+	}
+
+	startDate, ok := parseDateQuery(ctx, "start")
+	if !ok {
+		return
+	}
+	endDate, ok := parseDateQuery(ctx, "end")
+	if !ok {
+		return
+	}
+
+	page, limit, ok := newPageLimits(c.cfg).parsePagination(ctx)
+	if !ok {
+		return
+	}
+
+	ranking, err := c.service.GetSectorRanking(ctx.Request.Context(), farmID, startDate, endDate, page, limit)
+	if err != nil {
+		respondServiceError(ctx, "failed to fetch sector ranking", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, ranking)
+}
+
+// GetMultiFarmSectorBreakdown handles GET /v1/irrigation/analytics/sectors requests
+// @Summary Get sector metrics across several farms
+// @Description Rolls sector totals up across the requested farms for regional views. Each sector row carries its farm; farms without events in the range contribute no rows.
+// @Tags analytics
+// @Produce json
+// @Param farm_ids query string true "Comma-separated farm IDs (at most 100)" example(1,2)
+// @Param start query string false "Start date (YYYY-MM-DD format, defaults to 90 days ago)" example(2024-01-01)
+// @Param end query string false "End date (YYYY-MM-DD format, defaults to today)" example(2024-01-31)
+// @Param strict query bool false "Reject unknown query parameters with 400 (default: ANALYTICS_STRICT_QUERY_PARAMS)" example(true)
+// @Success 200 {object} model.MultiFarmSectorBreakdownResponse "Sector metrics per farm"
+// @Failure 400 {object} model.APIError "Missing or invalid farm_ids, or invalid date format"
+// @Failure 500 {object} model.APIError "Internal server error"
+// @Failure 504 {object} model.APIError "Database query timed out"
+// @Router /v1/irrigation/analytics/sectors [get]
+func (c *AnalyticsController) GetMultiFarmSectorBreakdown(ctx *gin.Context) {
+	if !c.checkQueryParams(ctx, "farm_ids", "start", "end") {
+		return
+	}
+
+	farmIDs, ok := parseFarmIDsQuery(ctx)
+	if !ok {
+		return
+	}
+
+	startDate, ok := parseDateQuery(ctx, "start")
+	if !ok {
+		return
+	}
+	endDate, ok := parseDateQuery(ctx, "end")
+	if !ok {
+		return
+	}
+
+	breakdown, err := c.service.GetMultiFarmSectorBreakdown(ctx.Request.Context(), farmIDs, startDate, endDate)
+	if err != nil {
+		respondServiceError(ctx, "failed to fetch sector breakdown", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, breakdown)
+}
+
+// GetDayOfWeek handles GET /v1/farms/:farm_id/irrigation/dow requests
+// @Summary Get irrigation totals per day of the week
+// @Description Returns event counts and real/nominal sums grouped by the UTC weekday of each event's start time. All seven days are listed from Sunday (day_of_week 0), with zeros for days without events.
+// @Tags analytics
+// @Produce json
+// @Param farm_id path int true "Farm ID" example(1)
+// @Param start query string false "Start date (YYYY-MM-DD format, defaults to 90 days ago)" example(2024-01-01)
+// @Param end query string false "End date (YYYY-MM-DD format, defaults to today)" example(2024-01-31)
+// @Param strict query bool false "Reject unknown query parameters with 400 (default: ANALYTICS_STRICT_QUERY_PARAMS)" example(true)
+// @Success 200 {object} model.DayOfWeekDistributionResponse "Totals per weekday"
+// @Failure 400 {object} model.APIError "Invalid request parameters or date format"
+// @Failure 404 {object} model.APIError "Farm excluded from analytics"
+// @Failure 500 {object} model.APIError "Internal server error"
+// @Failure 504 {object} model.APIError "Database query timed out"
+// @Router /v1/farms/{farm_id}/irrigation/dow [get]
+func (c *AnalyticsController) GetDayOfWeek(ctx *gin.Context) {
+	if !c.checkQueryParams(ctx, "start", "end") {
+		return
+	}
+
+	farmID, ok := parseFarmID(ctx)
+	if !ok {
+		return
+	}
+
+	startDate, ok := parseDateQuery(ctx, "start")
+	if !ok {
+		return
+	}
+	endDate, ok := parseDateQuery(ctx, "end")
+	if !ok {
+		return
+	}
+
+	distribution, err := c.service.GetDayOfWeekDistribution(ctx.Request.Context(), farmID, startDate, endDate)
+	if err != nil {
+		respondServiceError(ctx, "failed to fetch day-of-week distribution", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, distribution)
+}
+
+// GetScheduleAdherence handles GET /v1/farms/:farm_id/irrigation/schedule-adherence requests
+// @Summary Compare actual vs expected irrigation schedule
+// @Description Returns every sector with an expected_frequency_days and flags those whose longest stretch without an irrigation event exceeded it. The stretches before the first and after the last event in the range count; the range end is capped at the current time.
+// @Tags analytics
+// @Produce json
+// @Param farm_id path int true "Farm ID" example(1)
+// @Param start query string false "Start date (YYYY-MM-DD format, defaults to 90 days ago)" example(2024-01-01)
+// @Param end query string false "End date (YYYY-MM-DD format, defaults to today)" example(2024-01-31)
+// @Param strict query bool false "Reject unknown query parameters with 400 (default: ANALYTICS_STRICT_QUERY_PARAMS)" example(true)
+// @Success 200 {object} model.ScheduleAdherenceResponse "Schedule adherence by sector"
+// @Failure 400 {object} model.APIError "Invalid request parameters or date format"
+// @Failure 404 {object} model.APIError "Farm excluded from analytics"
+// @Failure 500 {object} model.APIError "Internal server error"
+// @Failure 504 {object} model.APIError "Database query timed out"
+// @Router /v1/farms/{farm_id}/irrigation/schedule-adherence [get]
+func (c *AnalyticsController) GetScheduleAdherence(ctx *gin.Context) {
+	if !c.checkQueryParams(ctx, "start", "end") {
+		return
+	}
+
+	farmID, ok := parseFarmID(ctx)
+	if !ok {
+		return
+	}
+
+	startDate, ok := parseDateQuery(ctx, "start")
+	if !ok {
+		return
+	}
+	endDate, ok := parseDateQuery(ctx, "end")
+	if !ok {
+		return
+	}
+
+	adherence, err := c.service.GetScheduleAdherence(ctx.Request.Context(), farmID, startDate, endDate)
+	if err != nil {
+		respondServiceError(ctx, "failed to fetch schedule adherence", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, adherence)
+}
+
+// RecommendAggregation handles GET /v1/farms/:farm_id/irrigation/recommend-aggregation requests
+// @Summary Recommend an aggregation for a date range
+// @Description Returns the finest aggregation whose bucket count for the range stays within max_buckets (120), so a UI can pick one automatically; ranges over 120 days get weekly. Computed from the dates alone.
+// @Tags analytics
+// @Produce json
+// @Param farm_id path int true "Farm ID" example(1)
+// @Param start query string false "Start date (YYYY-MM-DD format, defaults to 90 days ago)" example(2024-01-01)
+// @Param end query string false "End date (YYYY-MM-DD format, defaults to today)" example(2024-12-31)
+// @Param strict query bool false "Reject unknown query parameters with 400 (default: ANALYTICS_STRICT_QUERY_PARAMS)" example(true)
+// @Success 200 {object} model.AggregationRecommendationResponse "Recommended aggregation and the bucket count of each option"
+// @Failure 400 {object} model.APIError "Invalid request parameters or date format"
+// @Router /v1/farms/{farm_id}/irrigation/recommend-aggregation [get]
+func (c *AnalyticsController) RecommendAggregation(ctx *gin.Context) {
+	if !c.checkQueryParams(ctx, "start", "end") {
+		return
+	}
+
+	farmID, ok := parseFarmID(ctx)
+	if !ok {
+		return
+	}
+
+	startDate, ok := parseDateQuery(ctx, "start")
+	if !ok {
+		return
+	}
+	endDate, ok := parseDateQuery(ctx, "end")
+	if !ok {
+		return
+	}
+	if startDate != nil && endDate != nil && endDate.Before(*startDate) {
+		respondError(ctx, http.StatusBadRequest, "end must not be before start")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, c.service.RecommendAggregation(ctx.Request.Context(), farmID, startDate, endDate))
+}
+
+// respondError writes an APIError body carrying the request's correlation ID, so a client
+// reporting a failure can quote the ID from the request ID header (REQUEST_ID_HEADER, X-Request-ID by
+// default) that also appears in the logs
+func respondError(ctx *gin.Context, status int, message string) {
+	ctx.JSON(status, middleware.NewAPIError(ctx, message))
+}
+
+// respondJSONWithETag writes body as JSON with a strong ETag hashed from the encoded bytes, so clients
+// polling for changes can compare it; a request whose If-None-Match lists that ETag gets 304 without a body,
+// and HEAD requests get the same headers, Content-Length included, but no body
+func respondJSONWithETag(ctx *gin.Context, status int, body any) {
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		respondError(ctx, http.StatusInternalServerError, "failed to encode response: "+err.Error())
+		return
+	}
+	sum := sha256.Sum256(encoded)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	ctx.Header("ETag", etag)
+
+	if etagMatches(ctx.GetHeader("If-None-Match"), etag) {
+		ctx.Status(http.StatusNotModified)
+		return
+	}
+
+	if ctx.Request.Method == http.MethodHead {
+		ctx.Header("Content-Type", "application/json; charset=utf-8")
+		ctx.Header("Content-Length", strconv.Itoa(len(encoded)))
+		ctx.Status(status)
+		return
+	}
+	ctx.Data(status, "application/json; charset=utf-8", encoded)
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, or is "*"
+// If-None-Match uses weak comparison, so a W/ prefix on a listed tag is ignored
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// respondServiceError reports a failed service call: 404 for a farm excluded from analytics, 504 when
+// the database cancelled a statement for exceeding DB_STATEMENT_TIMEOUT, 500 otherwise
+func respondServiceError(ctx *gin.Context, message string, err error) {
+	if errors.Is(err, service.ErrFarmExcluded) {
+		respondError(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+	if database.IsQueryTimeout(err) {
+		respondError(ctx, http.StatusGatewayTimeout, message+": database query timed out")
+		return
+	}
+	respondError(ctx, http.StatusInternalServerError, message+": "+err.Error())
+}
+
+// parseFarmID parses the farm_id path parameter, responding with 400 when invalid
+func parseFarmID(ctx *gin.Context) (uint, bool) {
+	farmID, err := strconv.ParseUint(ctx.Param("farm_id"), 10, 32)
+	if err != nil {
+		respondError(ctx, http.StatusBadRequest, "invalid farm_id format")
+		return 0, false
+	}
+	return uint(farmID), true
+}
+
+// checkQueryParams rejects query parameters outside known (and globalQueryParams) with 400 in strict mode,
+// listing them so typos such as aggreation=weekly do not silently fall back to defaults
+// Strict mode follows ANALYTICS_STRICT_QUERY_PARAMS unless the request sets strict=true or strict=false
+func (c *AnalyticsController) checkQueryParams(ctx *gin.Context, known ...string) bool {
+	strict := c.cfg.StrictQueryParams
+	if strictStr := ctx.Query("strict"); strictStr != "" {
+		parsed, err := strconv.ParseBool(strictStr)
+		if err != nil {
+			respondError(ctx, http.StatusBadRequest, "invalid strict; use true or false")
+			return false
+		}
+		strict = parsed
+	}
+	if !strict {
+		return true
+	}
+
+	var unknown []string
+	for name := range ctx.Request.URL.Query() {
+		if !slices.Contains(known, name) && !slices.Contains(globalQueryParams, name) {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		slices.Sort(unknown)
+		respondError(ctx, http.StatusBadRequest, "unknown query parameters: "+strings.Join(unknown, ", "))
+		return false
+	}
+	return true
+}
+
+// maxMultiFarmIDs caps how many farms one multi-farm request may roll up
+const maxMultiFarmIDs = 100
+
+// parseFarmIDsQuery reads the required comma-separated farm_ids query parameter, responding with 400
+// when it is missing, malformed, or lists more than maxMultiFarmIDs farms
+// Duplicates are dropped and the IDs are returned in ascending order
+func parseFarmIDsQuery(ctx *gin.Context) ([]uint, bool) {
+	raw := ctx.Query("farm_ids")
+	if strings.TrimSpace(raw) == "" {
+		respondError(ctx, http.StatusBadRequest, "farm_ids is required")
+		return nil, false
+	}
+
+	var farmIDs []uint
+	for _, item := range strings.Split(raw, ",") {
+		farmID, err := strconv.ParseUint(strings.TrimSpace(item), 10, 32)
+		if err != nil || farmID == 0 {
+			respondError(ctx, http.StatusBadRequest, "invalid farm_ids; use comma-separated positive integers")
+			return nil, false
+		}
+		farmIDs = append(farmIDs, uint(farmID))
+	}
+
+	slices.Sort(farmIDs)
+	farmIDs = slices.Compact(farmIDs)
+	if len(farmIDs) > maxMultiFarmIDs {
+		respondError(ctx, http.StatusBadRequest, fmt.Sprintf("too many farm_ids; at most %d are allowed", maxMultiFarmIDs))
+		return nil, false
+	}
+	return farmIDs, true
+}
+
+// parseAggregation reads the aggregation query parameter (falling back to the configured default)
+// and responds with 400 when it is not daily, weekly, or monthly
+func (c *AnalyticsController) parseAggregation(ctx *gin.Context) (model.Aggregation, bool) {
+	aggregation, err := model.ParseAggregation(ctx.DefaultQuery("aggregation", string(c.cfg.DefaultAggregation)))
+	if err != nil {
+		respondError(ctx, http.StatusBadRequest, "invalid aggregation type; must be daily, weekly, or monthly")
+		return "", false
+	}
+	return aggregation, true
+}
+
+// partialStatus is the status of an analytics response whose comparison baseline is incomplete:
+// ANALYTICS_PARTIAL_STATUS, or 206 when unset
+func (c *AnalyticsController) partialStatus() int {
+	if c.cfg.PartialStatus == 0 {
+		return http.StatusPartialContent
+	}
+	return c.cfg.PartialStatus
+}
+
+// parseDateQuery parses an optional YYYY-MM-DD query parameter, responding with 400 when malformed
+// Returns nil when the parameter is absent
+func parseDateQuery(ctx *gin.Context, name string) (*time.Time, bool) {
+	value := ctx.Query(name)
+	if value == "" {
+		return nil, true
+	}
+	parsed, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		respondError(ctx, http.StatusBadRequest, "invalid "+name+" format; use YYYY-MM-DD")
+		return nil, false
+	}
+	return &parsed, true
+}
+
+// parseAmountQuery parses an optional amount in mm, responding with 400 when it is not a finite number
+// Returns nil when the parameter is absent
+func parseAmountQuery(ctx *gin.Context, name string) (*float64, bool) {
+	value := ctx.Query(name)
+	if value == "" {
+		return nil, true
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(parsed) || math.IsInf(parsed, 0) {
+		respondError(ctx, http.StatusBadRequest, "invalid "+name+"; must be a number")
+		return nil, false
+	}
+	return &parsed, true
 }
@@ -2,34 +2,117 @@ package controller
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sebaespinosa/test_NF/config"
+	"github.com/sebaespinosa/test_NF/internal/database"
+	"github.com/sebaespinosa/test_NF/internal/features"
+	"github.com/sebaespinosa/test_NF/internal/logging"
+	"github.com/sebaespinosa/test_NF/internal/middleware"
 	"github.com/sebaespinosa/test_NF/model"
+	"github.com/sebaespinosa/test_NF/service"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
 )
 
 type stubAnalyticsService struct {
-	resp      *model.IrrigationAnalyticsResponse
-	err       error
-	lastLimit int
-	lastPage  int
+	resp            *model.IrrigationAnalyticsResponse
+	heatmap         *model.EfficiencyHeatmapResponse
+	err             error
+	lastLimit       int
+	lastPage        int
+	lastAggregation model.Aggregation
+	lastOpts        model.AnalyticsOptions
+	lastStart       *time.Time
+	lastEnd         *time.Time
+	lastFarmIDs     []uint
+	delay           time.Duration
 }
 
-func (s *stubAnalyticsService) GetAnalytics(ctx context.Context, farmID uint, startDate, endDate *time.Time, sectorID *uint, aggregation string, page, limit int) (*model.IrrigationAnalyticsResponse, error) {
+func (s *stubAnalyticsService) GetAnalytics(ctx context.Context, farmID uint, startDate, endDate *time.Time, sectorID *uint, aggregation model.Aggregation, page, limit int, opts model.AnalyticsOptions) (*model.IrrigationAnalyticsResponse, error) {
 	s.lastLimit = limit
 	s.lastPage = page
+	s.lastAggregation = aggregation
+	s.lastOpts = opts
+	s.lastStart, s.lastEnd = startDate, endDate
+	time.Sleep(s.delay)
 	return s.resp, s.err
 }
 
+func (s *stubAnalyticsService) GetEfficiencyHeatmap(ctx context.Context, farmID uint, startDate, endDate *time.Time, aggregation model.Aggregation) (*model.EfficiencyHeatmapResponse, error) {
+	s.lastAggregation = aggregation
+	return s.heatmap, s.err
+}
+
+func (s *stubAnalyticsService) GetAlerts(ctx context.Context, farmID uint, startDate, endDate *time.Time) (*model.IrrigationAlertsResponse, error) {
+	return &model.IrrigationAlertsResponse{FarmID: farmID}, s.err
+}
+
+func (s *stubAnalyticsService) GetEfficiencyByAmount(ctx context.Context, farmID uint, startDate, endDate *time.Time, bins int) (*model.EfficiencyByAmountResponse, error) {
+	return &model.EfficiencyByAmountResponse{FarmID: farmID}, s.err
+}
+
+func (s *stubAnalyticsService) GetWaterSavings(ctx context.Context, farmID uint, startDate, endDate *time.Time) (*model.WaterSavingsResponse, error) {
+	return &model.WaterSavingsResponse{FarmID: farmID}, s.err
+}
+
+func (s *stubAnalyticsService) GetTopIrrigationDays(ctx context.Context, farmID uint, startDate, endDate *time.Time, n int) (*model.TopIrrigationDaysResponse, error) {
+	return &model.TopIrrigationDaysResponse{FarmID: farmID}, s.err
+}
+
+func (s *stubAnalyticsService) GetSectorRanking(ctx context.Context, farmID uint, startDate, endDate *time.Time, page, limit int) (*model.SectorRankingResponse, error) {
+	s.lastPage = page
+	s.lastLimit = limit
+	return &model.SectorRankingResponse{FarmID: farmID}, s.err
+}
+
+func (s *stubAnalyticsService) GetMultiFarmSectorBreakdown(ctx context.Context, farmIDs []uint, startDate, endDate *time.Time) (*model.MultiFarmSectorBreakdownResponse, error) {
+	s.lastFarmIDs = farmIDs
+	return &model.MultiFarmSectorBreakdownResponse{FarmIDs: farmIDs}, s.err
+}
+
+func (s *stubAnalyticsService) GetDayOfWeekDistribution(ctx context.Context, farmID uint, startDate, endDate *time.Time) (*model.DayOfWeekDistributionResponse, error) {
+	return &model.DayOfWeekDistributionResponse{FarmID: farmID}, s.err
+}
+
+func (s *stubAnalyticsService) GetScheduleAdherence(ctx context.Context, farmID uint, startDate, endDate *time.Time) (*model.ScheduleAdherenceResponse, error) {
+	return &model.ScheduleAdherenceResponse{FarmID: farmID, MissedCount: 1}, s.err
+}
+
+func (s *stubAnalyticsService) RecommendAggregation(ctx context.Context, farmID uint, startDate, endDate *time.Time) *model.AggregationRecommendationResponse {
+	return &model.AggregationRecommendationResponse{FarmID: farmID, Recommended: model.AggregationWeekly}
+}
+
+func newTestConfig() *config.AnalyticsConfig {
+	return &config.AnalyticsConfig{DefaultAggregation: model.AggregationDaily, DefaultLimit: 50}
+}
+
 func newTestRouter(svc AnalyticsService) *gin.Engine {
+	return newTestRouterWithConfig(svc, newTestConfig())
+}
+
+func newTestRouterWithConfig(svc AnalyticsService, cfg *config.AnalyticsConfig) *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
-	ctrl := &AnalyticsController{service: svc}
+	ctrl := &AnalyticsController{service: svc, cfg: cfg, features: features.Default()}
 	r.GET("/v1/farms/:farm_id/irrigation/analytics", ctrl.GetAnalytics)
+	r.HEAD("/v1/farms/:farm_id/irrigation/analytics", ctrl.GetAnalytics)
+	r.GET("/v1/farms/:farm_id/irrigation/heatmap", ctrl.GetHeatmap)
+	r.GET("/v1/farms/:farm_id/irrigation/sectors/ranking", ctrl.GetSectorRanking)
+	r.GET("/v1/irrigation/analytics/sectors", ctrl.GetMultiFarmSectorBreakdown)
+	r.GET("/v1/farms/:farm_id/irrigation/schedule-adherence", ctrl.GetScheduleAdherence)
+	r.GET("/v1/farms/:farm_id/irrigation/recommend-aggregation", ctrl.RecommendAggregation)
 	return r
 }
 
@@ -80,3 +163,811 @@ func TestGetAnalytics_InvalidDate(t *testing.T) {
 
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 }
+
+func TestGetAnalytics_ConfiguredDefaultAggregation(t *testing.T) {
+	svc := &stubAnalyticsService{
+		resp: &model.IrrigationAnalyticsResponse{PeriodComparison: &model.PeriodComparisonSet{}},
+	}
+	cfg := newTestConfig()
+	cfg.DefaultAggregation = model.AggregationMonthly
+	router := newTestRouterWithConfig(svc, cfg)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, model.AggregationMonthly, svc.lastAggregation)
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics?aggregation=weekly", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, model.AggregationWeekly, svc.lastAggregation)
+}
+
+func TestGetAnalytics_EmptyRange(t *testing.T) {
+	svc := &stubAnalyticsService{
+		resp: &model.IrrigationAnalyticsResponse{HasData: false, PeriodComparison: &model.PeriodComparisonSet{}},
+	}
+	router := newTestRouter(svc)
+
+	// Default: 200 with has_data=false
+	req := httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"has_data":false`)
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics?empty=204", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics?empty=404", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// A range with data is unaffected by empty=204
+	svc.resp.HasData = true
+	req = httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics?empty=204", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestGetAnalytics_SectorPagination(t *testing.T) {
+	svc := &stubAnalyticsService{
+		resp: &model.IrrigationAnalyticsResponse{PeriodComparison: &model.PeriodComparisonSet{}},
+	}
+	router := newTestRouter(svc)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics?sector_page=2&sector_limit=20", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 2, svc.lastOpts.SectorPage)
+	assert.Equal(t, 20, svc.lastOpts.SectorLimit)
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Zero(t, svc.lastOpts.SectorLimit)
+
+	// sector_limit is capped at the configured maximum like limit
+	req = httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics?sector_limit=5000", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1000, svc.lastOpts.SectorLimit)
+
+	for _, query := range []string{"sector_page=0", "sector_limit=abc", "sector_limit=0", "sector_limit=-5", "sector_limit=all"} {
+		req = httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics?"+query, nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestGetAnalytics_ConfiguredDefaultLimit(t *testing.T) {
+	svc := &stubAnalyticsService{
+		resp: &model.IrrigationAnalyticsResponse{PeriodComparison: &model.PeriodComparisonSet{}},
+	}
+	cfg := newTestConfig()
+	cfg.DefaultLimit = 20
+	router := newTestRouterWithConfig(svc, cfg)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 20, svc.lastLimit)
+
+	// An explicit limit still wins and the cap still applies
+	req = httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics?limit=5000", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 1000, svc.lastLimit)
+	assert.False(t, svc.lastOpts.UnboundedLimit)
+}
+
+func TestGetAnalytics_LimitPolicy(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		maxLimit   int
+		maxBuckets int
+		wantCode   int
+		wantLimit  int
+		unbounded  bool
+	}{
+		{name: "omitted uses the default", query: "", maxLimit: 200, wantCode: http.StatusOK, wantLimit: 50},
+		{name: "normal", query: "?limit=120", maxLimit: 200, wantCode: http.StatusOK, wantLimit: 120},
+		{name: "over the configured cap", query: "?limit=500", maxLimit: 200, wantCode: http.StatusOK, wantLimit: 200},
+		{name: "over the cap when unset", query: "?limit=5000", wantCode: http.StatusOK, wantLimit: 1000},
+		{name: "configured cap above 1000", query: "?limit=5000", maxLimit: 2000, wantCode: http.StatusOK, wantLimit: 2000},
+		{name: "all bounded by max buckets", query: "?limit=all", maxLimit: 200, maxBuckets: 3000, wantCode: http.StatusOK, wantLimit: 3000, unbounded: true},
+		{name: "all without a bucket cap", query: "?limit=all", maxLimit: 200, wantCode: http.StatusOK, wantLimit: math.MaxInt32, unbounded: true},
+		{name: "zero", query: "?limit=0", maxLimit: 200, wantCode: http.StatusBadRequest},
+		{name: "negative", query: "?limit=-5", maxLimit: 200, wantCode: http.StatusBadRequest},
+		{name: "not a number", query: "?limit=ten", maxLimit: 200, wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &stubAnalyticsService{
+				resp: &model.IrrigationAnalyticsResponse{PeriodComparison: &model.PeriodComparisonSet{}},
+			}
+			cfg := newTestConfig()
+			cfg.MaxLimit = tt.maxLimit
+			cfg.MaxBuckets = tt.maxBuckets
+			router := newTestRouterWithConfig(svc, cfg)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics"+tt.query, nil))
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantCode != http.StatusOK {
+				assert.Contains(t, w.Body.String(), "invalid limit")
+				assert.Zero(t, svc.lastLimit, "service must not be called")
+				return
+			}
+			assert.Equal(t, tt.wantLimit, svc.lastLimit)
+			// "all" is flagged so the service can warn about the response size
+			assert.Equal(t, tt.unbounded, svc.lastOpts.UnboundedLimit)
+		})
+	}
+}
+
+func TestGetHeatmap_Shape(t *testing.T) {
+	eff, deficit := 0.85, 6.5
+	svc := &stubAnalyticsService{
+		heatmap: &model.EfficiencyHeatmapResponse{
+			FarmID:      1,
+			Aggregation: model.AggregationWeekly,
+			Buckets:     []string{"2024-03-04", "2024-03-11"},
+			Rows: []model.HeatmapRow{
+				{SectorID: 1, SectorName: "North Field", Cells: []*float64{&eff, nil}, DeficitMM: []*float64{&deficit, nil}},
+			},
+		},
+	}
+	router := newTestRouter(svc)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/heatmap?start=2024-03-04&end=2024-03-17&aggregation=weekly", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, model.AggregationWeekly, svc.lastAggregation)
+	assert.JSONEq(t, `{
+		"farm_id": 1,
+		"period": {"start": "0001-01-01T00:00:00Z", "end": "0001-01-01T00:00:00Z"},
+		"aggregation": "weekly",
+		"buckets": ["2024-03-04", "2024-03-11"],
+		"rows": [{"sector_id": 1, "sector_name": "North Field", "cells": [0.85, null], "deficit_mm": [6.5, null]}]
+	}`, w.Body.String())
+}
+
+func TestGetHeatmap_InvalidParams(t *testing.T) {
+	router := newTestRouter(&stubAnalyticsService{})
+
+	for _, url := range []string{
+		"/v1/farms/abc/irrigation/heatmap",
+		"/v1/farms/1/irrigation/heatmap?start=03-04-2024",
+		"/v1/farms/1/irrigation/heatmap?aggregation=hourly",
+	} {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, url)
+	}
+}
+
+func TestGetScheduleAdherence_Endpoint(t *testing.T) {
+	router := newTestRouter(&stubAnalyticsService{})
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/schedule-adherence?start=2024-03-01&end=2024-03-31", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"missed_count":1`)
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/schedule-adherence?start=03-01-2024", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetSectorRanking_Endpoint(t *testing.T) {
+	svc := &stubAnalyticsService{}
+	router := newTestRouter(svc)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/sectors/ranking?page=3&limit=10", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 3, svc.lastPage)
+	assert.Equal(t, 10, svc.lastLimit)
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/sectors/ranking?end=2024-13-01", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetMultiFarmSectorBreakdown_FarmIDs(t *testing.T) {
+	ids := make([]string, 0, maxMultiFarmIDs+1)
+	for i := 1; i <= maxMultiFarmIDs+1; i++ {
+		ids = append(ids, strconv.Itoa(i))
+	}
+	tooMany := strings.Join(ids, ",")
+
+	tests := []struct {
+		name     string
+		query    string
+		wantCode int
+		wantIDs  []uint
+	}{
+		{name: "sorted and deduplicated", query: "?farm_ids=2,%201,2", wantCode: http.StatusOK, wantIDs: []uint{1, 2}},
+		{name: "missing", query: "", wantCode: http.StatusBadRequest},
+		{name: "not a number", query: "?farm_ids=1,abc", wantCode: http.StatusBadRequest},
+		{name: "zero", query: "?farm_ids=0", wantCode: http.StatusBadRequest},
+		{name: "too many", query: "?farm_ids=" + tooMany, wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &stubAnalyticsService{}
+			router := newTestRouter(svc)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/irrigation/analytics/sectors"+tt.query, nil))
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, tt.wantIDs, svc.lastFarmIDs)
+		})
+	}
+}
+
+func TestGetAnalytics_StrictQueryParams(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured bool
+		query      string
+		wantCode   int
+		wantError  string
+	}{
+		{name: "lenient ignores typos", query: "?aggreation=weekly", wantCode: http.StatusOK},
+		{name: "strict param rejects typos", query: "?strict=true&aggreation=weekly&pgae=2&limit=5", wantCode: http.StatusBadRequest, wantError: "unknown query parameters: aggreation, pgae"},
+		{name: "strict param accepts known params", query: "?strict=true&aggregation=weekly&limit=5&debug_timing=true", wantCode: http.StatusOK},
+		{name: "configured strict rejects typos", configured: true, query: "?aggreation=weekly", wantCode: http.StatusBadRequest, wantError: "unknown query parameters: aggreation"},
+		{name: "strict=false overrides configuration", configured: true, query: "?strict=false&aggreation=weekly", wantCode: http.StatusOK},
+		{name: "invalid strict value", query: "?strict=maybe", wantCode: http.StatusBadRequest, wantError: "invalid strict; use true or false"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &stubAnalyticsService{resp: &model.IrrigationAnalyticsResponse{PeriodComparison: &model.PeriodComparisonSet{}}}
+			cfg := newTestConfig()
+			cfg.StrictQueryParams = tt.configured
+			router := newTestRouterWithConfig(svc, cfg)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics"+tt.query, nil))
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantError != "" {
+				var apiErr model.APIError
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &apiErr))
+				assert.Equal(t, tt.wantError, apiErr.Error)
+			}
+		})
+	}
+}
+
+func TestGetAnalytics_Compare(t *testing.T) {
+	svc := &stubAnalyticsService{
+		resp: &model.IrrigationAnalyticsResponse{
+			SamePeriod1Y: &model.YoYComparison{DataIncomplete: true},
+			PrevWindow:   &model.PreviousWindow{DataIncomplete: false},
+		},
+	}
+	router := newTestRouter(svc)
+
+	// The previous window decides the status instead of the incomplete year-over-year data
+	req := httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics?compare=prev_window", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, model.ComparisonPrevWindow, svc.lastOpts.Compare)
+
+	svc.resp.PrevWindow.DataIncomplete = true
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics?compare=prev_window", nil))
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+
+	// The default keeps the year-over-year baseline
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics", nil))
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, model.ComparisonYoY, svc.lastOpts.Compare)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics?compare=quarter", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetAnalytics_Fields(t *testing.T) {
+	svc := &stubAnalyticsService{resp: &model.IrrigationAnalyticsResponse{}}
+	router := newTestRouter(svc)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics?fields=metrics,+sectors,metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, model.AnalyticsFields{model.AnalyticsFieldMetrics, model.AnalyticsFieldSectors}, svc.lastOpts.Fields)
+
+	// Without fields the service applies the deployment default
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Nil(t, svc.lastOpts.Fields)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics?fields=metrics,forecast", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "forecast")
+}
+
+func TestGetAnalytics_ForecastFeatureDisabled(t *testing.T) {
+	svc := &stubAnalyticsService{resp: &model.IrrigationAnalyticsResponse{}}
+	gin.SetMode(gin.TestMode)
+	flags := features.Default()
+	flags.Forecast = false
+	r := gin.New()
+	r.GET("/v1/farms/:farm_id/irrigation/analytics", (&AnalyticsController{service: svc, cfg: newTestConfig(), features: flags}).GetAnalytics)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics?forecast=true", nil))
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+
+	// The rest of the endpoint keeps working
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics?forecast=false", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.False(t, svc.lastOpts.Forecast)
+}
+
+func TestGetAnalytics_PartialStatus(t *testing.T) {
+	incomplete := &model.IrrigationAnalyticsResponse{SamePeriod1Y: &model.YoYComparison{DataIncomplete: true}}
+	complete := &model.IrrigationAnalyticsResponse{SamePeriod1Y: &model.YoYComparison{}, SamePeriod2Y: &model.YoYComparison{}}
+
+	tests := []struct {
+		name          string
+		partialStatus int
+		resp          *model.IrrigationAnalyticsResponse
+		wantStatus    int
+		wantComplete  string
+	}{
+		{name: "incomplete, default", resp: incomplete, wantStatus: http.StatusPartialContent, wantComplete: "false"},
+		{name: "incomplete, 206 configured", partialStatus: http.StatusPartialContent, resp: incomplete, wantStatus: http.StatusPartialContent, wantComplete: "false"},
+		{name: "incomplete, 200 configured", partialStatus: http.StatusOK, resp: incomplete, wantStatus: http.StatusOK, wantComplete: "false"},
+		{name: "complete, 200 configured", partialStatus: http.StatusOK, resp: complete, wantStatus: http.StatusOK, wantComplete: "true"},
+		{name: "complete, 206 configured", partialStatus: http.StatusPartialContent, resp: complete, wantStatus: http.StatusOK, wantComplete: "true"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			cfg.PartialStatus = tt.partialStatus
+			router := newTestRouterWithConfig(&stubAnalyticsService{resp: tt.resp}, cfg)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics", nil))
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Equal(t, tt.wantComplete, w.Header().Get("X-Data-Complete"))
+
+			// The body keeps its per-period flag either way
+			var body model.IrrigationAnalyticsResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, tt.resp.SamePeriod1Y.DataIncomplete, body.SamePeriod1Y.DataIncomplete)
+		})
+	}
+}
+
+func TestGetAnalytics_Consistency(t *testing.T) {
+	svc := &stubAnalyticsService{resp: &model.IrrigationAnalyticsResponse{}}
+	router := newTestRouter(svc)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics?consistency=strong", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, model.ConsistencyStrong, svc.lastOpts.Consistency)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, model.ConsistencyReplica, svc.lastOpts.Consistency)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics?consistency=eventual", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetAnalytics_EfficiencyBasis(t *testing.T) {
+	svc := &stubAnalyticsService{resp: &model.IrrigationAnalyticsResponse{}}
+	router := newTestRouter(svc)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics?efficiency_basis=weighted", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, model.EfficiencyBasisWeighted, svc.lastOpts.EfficiencyBasis)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, model.EfficiencyBasisAverage, svc.lastOpts.EfficiencyBasis)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics?efficiency_basis=median", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetAnalytics_Head(t *testing.T) {
+	svc := &stubAnalyticsService{resp: &model.IrrigationAnalyticsResponse{FarmID: 1, HasData: true}}
+	router := newTestRouter(svc)
+
+	get := httptest.NewRecorder()
+	router.ServeHTTP(get, httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics", nil))
+	require.Equal(t, http.StatusOK, get.Code)
+	etag := get.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	head := httptest.NewRecorder()
+	router.ServeHTTP(head, httptest.NewRequest(http.MethodHead, "/v1/farms/1/irrigation/analytics", nil))
+	assert.Equal(t, http.StatusOK, head.Code)
+	assert.Equal(t, etag, head.Header().Get("ETag"))
+	assert.Equal(t, strconv.Itoa(get.Body.Len()), head.Header().Get("Content-Length"))
+	assert.Equal(t, "true", head.Header().Get("X-Data-Complete"))
+	assert.Contains(t, head.Header().Get("Content-Type"), "application/json")
+	assert.Zero(t, head.Body.Len())
+
+	// A different response gets a different ETag
+	svc.resp = &model.IrrigationAnalyticsResponse{FarmID: 1, HasData: true, Aggregation: model.AggregationWeekly}
+	head = httptest.NewRecorder()
+	router.ServeHTTP(head, httptest.NewRequest(http.MethodHead, "/v1/farms/1/irrigation/analytics", nil))
+	assert.NotEqual(t, etag, head.Header().Get("ETag"))
+}
+
+func TestGetAnalytics_IfNoneMatch(t *testing.T) {
+	svc := &stubAnalyticsService{resp: &model.IrrigationAnalyticsResponse{FarmID: 1, HasData: true}}
+	router := newTestRouter(svc)
+
+	get := httptest.NewRecorder()
+	router.ServeHTTP(get, httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics", nil))
+	require.Equal(t, http.StatusOK, get.Code)
+	etag := get.Header().Get("ETag")
+
+	tests := []struct {
+		name        string
+		method      string
+		ifNoneMatch string
+		wantStatus  int
+	}{
+		{name: "matching ETag", method: http.MethodGet, ifNoneMatch: etag, wantStatus: http.StatusNotModified},
+		{name: "matching ETag in a list", method: http.MethodGet, ifNoneMatch: `"stale", ` + etag, wantStatus: http.StatusNotModified},
+		{name: "weak form of the ETag", method: http.MethodGet, ifNoneMatch: "W/" + etag, wantStatus: http.StatusNotModified},
+		{name: "any", method: http.MethodGet, ifNoneMatch: "*", wantStatus: http.StatusNotModified},
+		{name: "matching ETag on HEAD", method: http.MethodHead, ifNoneMatch: etag, wantStatus: http.StatusNotModified},
+		{name: "stale ETag", method: http.MethodGet, ifNoneMatch: `"stale"`, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/v1/farms/1/irrigation/analytics", nil)
+			req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Equal(t, etag, w.Header().Get("ETag"))
+			if tt.wantStatus == http.StatusNotModified {
+				assert.Zero(t, w.Body.Len())
+			}
+		})
+	}
+}
+
+func TestGetAnalytics_Include(t *testing.T) {
+	svc := &stubAnalyticsService{resp: &model.IrrigationAnalyticsResponse{}}
+	router := newTestRouter(svc)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics?include=quality", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, svc.lastOpts.IncludeQuality)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.False(t, svc.lastOpts.IncludeQuality)
+	assert.False(t, svc.lastOpts.IncludeStackedTimeSeries)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics?include=stacked_timeseries,quality", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, svc.lastOpts.IncludeQuality)
+	assert.True(t, svc.lastOpts.IncludeStackedTimeSeries)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics?include=quality,anomalies", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetAnalytics_ExcludeToday(t *testing.T) {
+	svc := &stubAnalyticsService{resp: &model.IrrigationAnalyticsResponse{}}
+	router := newTestRouter(svc)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics?exclude_today=true", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, svc.lastOpts.ExcludeToday)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics?exclude_today=soon", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetAnalytics_AnomaliesOnly(t *testing.T) {
+	svc := &stubAnalyticsService{resp: &model.IrrigationAnalyticsResponse{}}
+	router := newTestRouter(svc)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics?anomalies_only=true", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, svc.lastOpts.AnomaliesOnly)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics?anomalies_only=maybe", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetAnalytics_SectorSort(t *testing.T) {
+	tests := []struct {
+		query string
+		code  int
+		want  model.SectorSort
+	}{
+		{"", http.StatusOK, model.SectorSort{Field: model.SectorSortID}},
+		{"?sector_sort=volume", http.StatusOK, model.SectorSort{Field: model.SectorSortVolume, Descending: true}},
+		{"?sector_sort=efficiency&sector_order=asc", http.StatusOK, model.SectorSort{Field: model.SectorSortEfficiency}},
+		{"?sector_sort=name&sector_order=desc", http.StatusOK, model.SectorSort{Field: model.SectorSortName, Descending: true}},
+		{"?sector_sort=size", http.StatusBadRequest, model.SectorSort{}},
+		{"?sector_order=up", http.StatusBadRequest, model.SectorSort{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			svc := &stubAnalyticsService{resp: &model.IrrigationAnalyticsResponse{}}
+			w := httptest.NewRecorder()
+			newTestRouter(svc).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics"+tt.query, nil))
+			assert.Equal(t, tt.code, w.Code)
+			assert.Equal(t, tt.want, svc.lastOpts.SectorSort)
+		})
+	}
+}
+
+func TestGetAnalytics_GroupBy(t *testing.T) {
+	svc := &stubAnalyticsService{resp: &model.IrrigationAnalyticsResponse{}}
+	router := newTestRouter(svc)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, model.SectorGroupBySector, svc.lastOpts.SectorGroupBy)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics?group_by=zone", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, model.SectorGroupByZone, svc.lastOpts.SectorGroupBy)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics?group_by=farm", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetAnalytics_Preset(t *testing.T) {
+	svc := &stubAnalyticsService{resp: &model.IrrigationAnalyticsResponse{}}
+	cfg := newTestConfig()
+	cfg.Presets = map[string]config.DateRange{
+		"growing_season_2024": {Start: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2024, 9, 30, 0, 0, 0, 0, time.UTC)},
+	}
+	router := newTestRouterWithConfig(svc, cfg)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics?preset=growing_season_2024", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	require.NotNil(t, svc.lastStart)
+	require.NotNil(t, svc.lastEnd)
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), *svc.lastStart)
+	assert.Equal(t, time.Date(2024, 9, 30, 0, 0, 0, 0, time.UTC), *svc.lastEnd)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics?preset=winter_2024", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "unknown preset")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics?preset=growing_season_2024&start_date=2024-01-01", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetAnalytics_ErrorCarriesCorrelationID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.TraceMiddleware(&logging.Logger{Logger: zap.NewNop()}, middleware.DefaultRequestIDHeader))
+	ctrl := &AnalyticsController{service: &stubAnalyticsService{err: errors.New("db down")}, cfg: newTestConfig()}
+	r.GET("/v1/farms/:farm_id/irrigation/analytics", ctrl.GetAnalytics)
+
+	tests := []struct {
+		name      string
+		requestID string
+		url       string
+		status    int
+	}{
+		{name: "generated id on 500", url: "/v1/farms/1/irrigation/analytics", status: http.StatusInternalServerError},
+		{name: "client id on 400", requestID: "req-123", url: "/v1/farms/abc/irrigation/analytics", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.requestID != "" {
+				req.Header.Set("X-Request-ID", tt.requestID)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, tt.status, w.Code)
+
+			var body model.APIError
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.NotEmpty(t, body.Error)
+			assert.NotEmpty(t, body.CorrelationID)
+			assert.Equal(t, w.Header().Get("X-Request-ID"), body.CorrelationID)
+			if tt.requestID != "" {
+				assert.Equal(t, tt.requestID, body.CorrelationID)
+			}
+		})
+	}
+}
+
+func TestGetAnalytics_SectorErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{name: "unknown sector", err: service.ErrSectorNotFound, status: http.StatusNotFound},
+		{name: "sector of another farm", err: &service.SectorNotInFarmError{SectorID: 2, FarmID: 1}, status: http.StatusBadRequest},
+		{name: "excluded farm", err: service.ErrFarmExcluded, status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newTestRouter(&stubAnalyticsService{err: tt.err})
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics?sector_id=2", nil))
+			assert.Equal(t, tt.status, w.Code)
+			assert.Contains(t, w.Body.String(), tt.err.Error())
+		})
+	}
+}
+
+func TestRecommendAggregation(t *testing.T) {
+	router := newTestRouter(&stubAnalyticsService{})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/recommend-aggregation?start=2024-01-01&end=2024-12-31", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"recommended":"weekly"`)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/recommend-aggregation?start=2024-12-31&end=2024-01-01", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/recommend-aggregation?start=2024-13-01", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetAnalytics_Timeouts(t *testing.T) {
+	tests := []struct {
+		name    string
+		svc     *stubAnalyticsService
+		status  int
+		message string
+	}{
+		{
+			name:    "slow database",
+			svc:     &stubAnalyticsService{err: fmt.Errorf("failed to get analytics: %w", database.ErrQueryTimeout)},
+			status:  http.StatusGatewayTimeout,
+			message: "failed to fetch analytics: database query timed out",
+		},
+		{
+			name:    "slow handler",
+			svc:     &stubAnalyticsService{resp: &model.IrrigationAnalyticsResponse{}, delay: 50 * time.Millisecond},
+			status:  http.StatusServiceUnavailable,
+			message: "request timed out",
+		},
+		{
+			name:    "other failure",
+			svc:     &stubAnalyticsService{err: errors.New("db down")},
+			status:  http.StatusInternalServerError,
+			message: "failed to fetch analytics: db down",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			r.Use(middleware.RequestTimeout(20*time.Millisecond, &logging.Logger{Logger: zap.NewNop()}))
+			ctrl := &AnalyticsController{service: tt.svc, cfg: newTestConfig()}
+			r.GET("/v1/farms/:farm_id/irrigation/analytics", ctrl.GetAnalytics)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics", nil))
+
+			assert.Equal(t, tt.status, w.Code)
+			var body model.APIError
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, tt.message, body.Error)
+		})
+	}
+}
+
+func TestGetAnalytics_RealAmountBounds(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		status   int
+		min, max *float64
+	}{
+		{name: "no bounds", query: "", status: http.StatusOK},
+		{name: "both bounds", query: "?min_real=10&max_real=25.5", status: http.StatusOK, min: floatPtr(10), max: floatPtr(25.5)},
+		{name: "equal bounds", query: "?min_real=10&max_real=10", status: http.StatusOK, min: floatPtr(10), max: floatPtr(10)},
+		{name: "minimum only", query: "?min_real=10", status: http.StatusOK, min: floatPtr(10)},
+		{name: "minimum above maximum", query: "?min_real=30&max_real=10", status: http.StatusBadRequest},
+		{name: "not a number", query: "?max_real=lots", status: http.StatusBadRequest},
+		{name: "not finite", query: "?min_real=NaN", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &stubAnalyticsService{resp: &model.IrrigationAnalyticsResponse{}}
+			router := newTestRouter(svc)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/analytics"+tt.query, nil))
+			assert.Equal(t, tt.status, w.Code)
+			if tt.status == http.StatusOK {
+				assert.Equal(t, tt.min, svc.lastOpts.MinReal)
+				assert.Equal(t, tt.max, svc.lastOpts.MaxReal)
+			}
+		})
+	}
+}
+
+func floatPtr(v float64) *float64 { return &v }
@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebaespinosa/test_NF/model"
+	"github.com/sebaespinosa/test_NF/service"
+)
+
+// maxHealthyThreshold is the largest threshold the farms.healthy_efficiency_threshold column (numeric(4,3)) holds
+const maxHealthyThreshold = 9.999
+
+// FarmSettingsService is the contract the farm controller depends on (facilitates mocking in tests).
+type FarmSettingsService interface {
+	SetHealthyThreshold(ctx context.Context, id uint, threshold *float64) (*model.Farm, error)
+}
+
+// FarmController handles HTTP requests that change a farm's settings
+type FarmController struct {
+	service FarmSettingsService
+}
+
+// NewFarmController creates a new FarmController instance
+func NewFarmController(service *service.FarmService) *FarmController {
+	return &FarmController{service: service}
+}
+
+// SetHealthyThreshold handles PUT /v1/farms/:farm_id/healthy-efficiency-threshold requests
+// @Summary Set a farm's healthy efficiency threshold
+// @Description Sets the minimum weighted efficiency for the analytics status healthy, or clears it with null so ANALYTICS_HEALTHY_EFFICIENCY_THRESHOLD applies. Analytics pick up the change within a minute.
+// @Tags farms
+// @Accept json
+// @Produce json
+// @Param farm_id path int true "Farm ID" example(1)
+// @Param threshold body model.HealthyThresholdInput true "New threshold"
+// @Success 200 {object} model.Farm "Updated farm"
+// @Failure 400 {object} model.APIError "Invalid farm_id, malformed body or threshold out of range"
+// @Failure 404 {object} model.APIError "Farm not found"
+// @Failure 500 {object} model.APIError "Internal server error"
+// @Failure 504 {object} model.APIError "Database query timed out"
+// @Router /v1/farms/{farm_id}/healthy-efficiency-threshold [put]
+func (c *FarmController) SetHealthyThreshold(ctx *gin.Context) {
+	farmID, ok := parseFarmID(ctx)
+	if !ok {
+		return
+	}
+
+	var input model.HealthyThresholdInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		respondError(ctx, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if threshold := input.HealthyEfficiencyThreshold; threshold != nil && (*threshold < 0 || *threshold > maxHealthyThreshold) {
+		respondError(ctx, http.StatusBadRequest, "healthy_efficiency_threshold must be between 0 and 9.999, or null")
+		return
+	}
+
+	farm, err := c.service.SetHealthyThreshold(ctx.Request.Context(), farmID, input.HealthyEfficiencyThreshold)
+	if err != nil {
+		if errors.Is(err, service.ErrFarmNotFound) {
+			respondError(ctx, http.StatusNotFound, err.Error())
+			return
+		}
+		respondServiceError(ctx, "failed to update farm", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, farm)
+}
@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebaespinosa/test_NF/model"
+	"github.com/sebaespinosa/test_NF/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubFarmSettingsService struct {
+	err           error
+	called        bool
+	lastThreshold *float64
+}
+
+func (s *stubFarmSettingsService) SetHealthyThreshold(ctx context.Context, id uint, threshold *float64) (*model.Farm, error) {
+	s.called = true
+	s.lastThreshold = threshold
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &model.Farm{ID: id, Name: "Farm A", HealthyEfficiencyThreshold: threshold}, nil
+}
+
+func newFarmTestRouter(svc FarmSettingsService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	ctrl := &FarmController{service: svc}
+	r.PUT("/v1/farms/:farm_id/healthy-efficiency-threshold", ctrl.SetHealthyThreshold)
+	return r
+}
+
+func TestSetHealthyThreshold(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		svcErr        error
+		wantStatus    int
+		wantThreshold *float64
+	}{
+		{name: "sets threshold", body: `{"healthy_efficiency_threshold": 0.85}`, wantStatus: http.StatusOK, wantThreshold: floatPtr(0.85)},
+		{name: "null clears threshold", body: `{"healthy_efficiency_threshold": null}`, wantStatus: http.StatusOK},
+		{name: "negative threshold", body: `{"healthy_efficiency_threshold": -0.1}`, wantStatus: http.StatusBadRequest},
+		{name: "threshold beyond column precision", body: `{"healthy_efficiency_threshold": 10}`, wantStatus: http.StatusBadRequest},
+		{name: "malformed body", body: `{"healthy_efficiency_threshold": "high"}`, wantStatus: http.StatusBadRequest},
+		{name: "unknown farm", body: `{"healthy_efficiency_threshold": 0.85}`, svcErr: service.ErrFarmNotFound, wantStatus: http.StatusNotFound, wantThreshold: floatPtr(0.85)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &stubFarmSettingsService{err: tt.svcErr}
+			router := newFarmTestRouter(svc)
+
+			req := httptest.NewRequest(http.MethodPut, "/v1/farms/1/healthy-efficiency-threshold", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			assert.Equal(t, tt.wantThreshold, svc.lastThreshold)
+			assert.Equal(t, tt.wantStatus != http.StatusBadRequest, svc.called)
+		})
+	}
+}
@@ -23,14 +23,66 @@ func NewHealthController(service *service.HealthService) *HealthController {
 // @Tags health
 // @Produce json
 // @Success 200 {object} model.HealthResponse
-// @Failure 500 {object} map[string]string
+// @Failure 500 {object} model.APIError
 // @Router /health [get]
 func (c *HealthController) GetHealth(ctx *gin.Context) {
 	health, err := c.service.GetHealth(ctx.Request.Context())
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, http.StatusInternalServerError, err.Error())
 		return
 	}
 
 	ctx.JSON(http.StatusOK, health)
 }
+
+// GetLiveness handles GET /health/live requests
+// @Summary Liveness probe
+// @Description Reports that the process is running without checking dependencies; always fast
+// @Tags health
+// @Produce json
+// @Success 200 {object} model.HealthResponse
+// @Router /health/live [get]
+func (c *HealthController) GetLiveness(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, c.service.GetLiveness())
+}
+
+// GetComponents handles GET /health/components requests
+// @Summary Per-dependency health
+// @Description Checks each dependency without the readiness cache. The database is required: its failure makes the status unhealthy and the response 503. An unreachable cache only degrades the status, still with 200, since analytics fall back to direct computation. The cache is listed only when one is configured (the YoY cache, with ANALYTICS_YOY_CACHE_FARMS set).
+// @Tags health
+// @Produce json
+// @Success 200 {object} model.HealthComponentsResponse
+// @Failure 503 {object} model.HealthComponentsResponse
+// @Router /health/components [get]
+func (c *HealthController) GetComponents(ctx *gin.Context) {
+	health := c.service.GetComponents(ctx.Request.Context())
+
+	statusCode := http.StatusOK
+	if health.Status == "unhealthy" {
+		statusCode = http.StatusServiceUnavailable
+	}
+	ctx.JSON(statusCode, health)
+}
+
+// GetReadiness handles GET /health/ready requests
+// @Summary Readiness probe
+// @Description Checks the database (result cached for HEALTH_CACHE_TTL) and returns 503 while it is unavailable
+// @Tags health
+// @Produce json
+// @Success 200 {object} model.HealthResponse
+// @Failure 503 {object} model.HealthResponse
+// @Failure 500 {object} model.APIError
+// @Router /health/ready [get]
+func (c *HealthController) GetReadiness(ctx *gin.Context) {
+	health, err := c.service.GetHealth(ctx.Request.Context())
+	if err != nil {
+		respondError(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	statusCode := http.StatusOK
+	if health.Status != "healthy" {
+		statusCode = http.StatusServiceUnavailable
+	}
+	ctx.JSON(statusCode, health)
+}
@@ -0,0 +1,395 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebaespinosa/test_NF/config"
+	"github.com/sebaespinosa/test_NF/internal/features"
+	"github.com/sebaespinosa/test_NF/internal/middleware"
+	"github.com/sebaespinosa/test_NF/model"
+	"github.com/sebaespinosa/test_NF/service"
+)
+
+// IrrigationEventsService is the contract the irrigation controller depends on (facilitates mocking in tests).
+type IrrigationEventsService interface {
+	ListFarmEvents(ctx context.Context, farmID uint, startDate, endDate *time.Time, page, limit int, expand model.EventExpansions) (*model.IrrigationEventsResponse, error)
+	ListSectorEvents(ctx context.Context, sectorID uint, startDate, endDate *time.Time, page, limit int, expand model.EventExpansions) (*model.IrrigationEventsResponse, error)
+	GetFarmEventsLastModified(ctx context.Context, farmID uint, startDate, endDate *time.Time) (*time.Time, int64, error)
+	CreateBatch(ctx context.Context, farmID uint, inputs []model.IrrigationEventInput) (int, error)
+	AggregateByFarm(ctx context.Context, startDate, endDate *time.Time) (*model.FarmAggregatesResponse, error)
+	AggregateBySector(ctx context.Context, startDate, endDate *time.Time) (*model.SectorAggregatesResponse, error)
+	AggregateSector(ctx context.Context, sectorID uint, startDate, endDate *time.Time) (*model.SectorAggregateResponse, error)
+	ListChanges(ctx context.Context, since model.ChangeCursor, limit int) (*model.IrrigationChangesResponse, error)
+}
+
+// IrrigationController handles HTTP requests for raw irrigation events
+type IrrigationController struct {
+	service  IrrigationEventsService
+	cfg      *config.AnalyticsConfig
+	features features.Flags
+}
+
+// NewIrrigationController creates a new IrrigationController instance; cfg supplies the page size policy
+func NewIrrigationController(service *service.IrrigationDataService, cfg *config.AnalyticsConfig, flags features.Flags) *IrrigationController {
+	return &IrrigationController{service: service, cfg: cfg, features: flags}
+}
+
+// GetFarmEvents handles GET /v1/farms/:farm_id/irrigation/events requests
+// @Summary List raw irrigation events for a farm
+// @Description Returns paginated irrigation events ordered by start time. Supports If-None-Match against an ETag built from the latest updated_at and the number of matching events, and If-Modified-Since against the latest updated_at. If-Modified-Since cannot notice a deleted event, so clients that must see deletions should send If-None-Match, which takes precedence.
+// @Tags irrigation
+// @Produce json
+// @Param farm_id path int true "Farm ID" example(1)
+// @Param start query string false "Start date (YYYY-MM-DD format, defaults to 90 days ago)" example(2024-01-01)
+// @Param end query string false "End date (YYYY-MM-DD format, defaults to today)" example(2024-01-31)
+// @Param page query int false "Page number (1-indexed, default: 1)" example(1)
+// @Param limit query int false "Results per page (default: ANALYTICS_DEFAULT_LIMIT, 50; capped at ANALYTICS_MAX_LIMIT, 1000; 'all' for all results up to ANALYTICS_MAX_BUCKETS; 0 or negative is a 400)" example(50)
+// @Param expand query string false "Comma-separated associations to embed in each event: farm, sector" example(farm,sector)
+// @Param If-None-Match header string false "ETag of a previous response; returns 304 when no matching event was added, changed or deleted since"
+// @Param If-Modified-Since header string false "HTTP date; returns 304 when no matching event changed since (deletions go unnoticed); ignored with If-None-Match"
+// @Success 200 {object} model.IrrigationEventsResponse "Irrigation events"
+// @Success 304 "Not modified since If-None-Match or If-Modified-Since"
+// @Failure 400 {object} model.APIError "Invalid request parameters or date format"
+// @Failure 500 {object} model.APIError "Internal server error"
+// @Failure 504 {object} model.APIError "Database query timed out"
+// @Router /v1/farms/{farm_id}/irrigation/events [get]
+func (c *IrrigationController) GetFarmEvents(ctx *gin.Context) {
+	farmID, ok := parseFarmID(ctx)
+	if !ok {
+		return
+	}
+
+	startDate, ok := parseDateQuery(ctx, "start")
+	if !ok {
+		return
+	}
+	endDate, ok := parseDateQuery(ctx, "end")
+	if !ok {
+		return
+	}
+
+	page, limit, ok := newPageLimits(c.cfg).parsePagination(ctx)
+	if !ok {
+		return
+	}
+
+	expand, ok := parseExpandQuery(ctx)
+	if !ok {
+		return
+	}
+
+	lastModified, eventCount, err := c.service.GetFarmEventsLastModified(ctx.Request.Context(), farmID, startDate, endDate)
+	if err != nil {
+		respondServiceError(ctx, "failed to fetch irrigation events", err)
+		return
+	}
+
+	if lastModified != nil {
+		// HTTP dates have second precision, so compare truncated timestamps
+		modified := lastModified.UTC().Truncate(time.Second)
+		// updated_at alone misses deleted events, so the ETag also carries the event count
+		etag := fmt.Sprintf(`"%d-%d"`, lastModified.UnixNano(), eventCount)
+		ctx.Header("ETag", etag)
+		ctx.Header("Last-Modified", modified.Format(http.TimeFormat))
+
+		if ifNoneMatch := ctx.GetHeader("If-None-Match"); ifNoneMatch != "" {
+			if etagMatches(ifNoneMatch, etag) {
+				ctx.Status(http.StatusNotModified)
+				return
+			}
+		} else if since, err := http.ParseTime(ctx.GetHeader("If-Modified-Since")); err == nil && !modified.After(since) {
+			ctx.Status(http.StatusNotModified)
+			return
+		}
+	}
+
+	events, err := c.service.ListFarmEvents(ctx.Request.Context(), farmID, startDate, endDate, page, limit, expand)
+	if err != nil {
+		respondServiceError(ctx, "failed to fetch irrigation events", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, events)
+}
+
+// GetSectorEvents handles GET /v1/sectors/:id/irrigation/events requests
+// @Summary List raw irrigation events for a sector
+// @Description Returns paginated irrigation events of one sector ordered by start time, without requiring its farm
+// @Tags irrigation
+// @Produce json
+// @Param id path int true "Irrigation sector ID" example(1)
+// @Param start query string false "Start date (YYYY-MM-DD format, defaults to 90 days ago)" example(2024-01-01)
+// @Param end query string false "End date (YYYY-MM-DD format, defaults to today)" example(2024-01-31)
+// @Param page query int false "Page number (1-indexed, default: 1)" example(1)
+// @Param limit query int false "Results per page (default: ANALYTICS_DEFAULT_LIMIT, 50; capped at ANALYTICS_MAX_LIMIT, 1000; 'all' for all results up to ANALYTICS_MAX_BUCKETS; 0 or negative is a 400)" example(50)
+// @Param expand query string false "Comma-separated associations to embed in each event: farm, sector" example(farm,sector)
+// @Success 200 {object} model.IrrigationEventsResponse "Irrigation events"
+// @Failure 400 {object} model.APIError "Invalid request parameters or date format"
+// @Failure 404 {object} model.APIError "Sector not found"
+// @Failure 500 {object} model.APIError "Internal server error"
+// @Failure 504 {object} model.APIError "Database query timed out"
+// @Router /v1/sectors/{id}/irrigation/events [get]
+func (c *IrrigationController) GetSectorEvents(ctx *gin.Context) {
+	sectorID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		respondError(ctx, http.StatusBadRequest, "invalid sector id format")
+		return
+	}
+
+	startDate, ok := parseDateQuery(ctx, "start")
+	if !ok {
+		return
+	}
+	endDate, ok := parseDateQuery(ctx, "end")
+	if !ok {
+		return
+	}
+
+	page, limit, ok := newPageLimits(c.cfg).parsePagination(ctx)
+	if !ok {
+		return
+	}
+
+	expand, ok := parseExpandQuery(ctx)
+	if !ok {
+		return
+	}
+
+	events, err := c.service.ListSectorEvents(ctx.Request.Context(), uint(sectorID), startDate, endDate, page, limit, expand)
+	if err != nil {
+		if errors.Is(err, service.ErrSectorNotFound) {
+			respondError(ctx, http.StatusNotFound, err.Error())
+			return
+		}
+		respondServiceError(ctx, "failed to fetch irrigation events", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, events)
+}
+
+// CreateFarmEventsBatch handles POST /v1/farms/:farm_id/irrigation/events/batch requests
+// @Summary Create irrigation events in batch
+// @Description Validates every record and inserts them atomically. Each irrigation_sector_id must belong to the farm in the path. When any record is invalid nothing is inserted and a 422 lists each offending index, field, and reason.
+// @Tags irrigation
+// @Accept json
+// @Produce json
+// @Param farm_id path int true "Farm ID" example(1)
+// @Param events body []model.IrrigationEventInput true "Irrigation events to create"
+// @Success 201 {object} model.BatchCreateResponse "Events created"
+// @Failure 400 {object} model.APIError "Malformed body or empty batch"
+// @Failure 422 {object} model.ValidationErrorResponse "One or more records are invalid"
+// @Failure 500 {object} model.APIError "Internal server error"
+// @Failure 504 {object} model.APIError "Database query timed out"
+// @Router /v1/farms/{farm_id}/irrigation/events/batch [post]
+func (c *IrrigationController) CreateFarmEventsBatch(ctx *gin.Context) {
+	farmID, ok := parseFarmID(ctx)
+	if !ok {
+		return
+	}
+
+	var inputs []model.IrrigationEventInput
+	if err := ctx.ShouldBindJSON(&inputs); err != nil {
+		respondError(ctx, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if len(inputs) == 0 {
+		respondError(ctx, http.StatusBadRequest, "batch must contain at least one record")
+		return
+	}
+
+	created, err := c.service.CreateBatch(ctx.Request.Context(), farmID, inputs)
+	if err != nil {
+		var validationErr *service.BatchValidationError
+		if errors.As(err, &validationErr) {
+			ctx.JSON(http.StatusUnprocessableEntity, model.ValidationErrorResponse{
+				Error:         "batch contains invalid records",
+				Details:       validationErr.Violations,
+				CorrelationID: middleware.CorrelationID(ctx),
+			})
+			return
+		}
+		respondServiceError(ctx, "failed to create irrigation events", err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, model.BatchCreateResponse{Created: created})
+}
+
+// GetChanges handles GET /v1/irrigation/changes requests
+// @Summary List irrigation events changed since a position
+// @Description Returns events created or updated after since (or after cursor), across all farms, ordered by updated_at then id, for incremental sync. Keep next_cursor and pass it as cursor to continue; deleted events are not reported. Not farm-scoped, so API keys limited to specific farms get 403.
+// @Tags irrigation
+// @Produce json
+// @Param since query string false "RFC 3339 timestamp; events updated strictly after it are returned. Required unless cursor is given" example(2024-03-01T00:00:00Z)
+// @Param cursor query string false "next_cursor of a previous response; takes precedence over since"
+// @Param limit query int false "Events per page (default: ANALYTICS_DEFAULT_LIMIT, 50; capped at ANALYTICS_MAX_LIMIT, 1000; 0 or negative is a 400)" example(100)
+// @Success 200 {object} model.IrrigationChangesResponse "Changed events"
+// @Failure 400 {object} model.APIError "Missing or invalid since, cursor or limit"
+// @Failure 403 {object} model.APIError "API key limited to specific farms"
+// @Failure 404 {object} model.APIError "Changes feed disabled (FEATURE_CHANGES_FEED=false)"
+// @Failure 500 {object} model.APIError "Internal server error"
+// @Failure 504 {object} model.APIError "Database query timed out"
+// @Router /v1/irrigation/changes [get]
+func (c *IrrigationController) GetChanges(ctx *gin.Context) {
+	if !c.features.ChangesFeed {
+		respondError(ctx, http.StatusNotFound, "changes feed is disabled on this server")
+		return
+	}
+
+	var since model.ChangeCursor
+	if token := ctx.Query("cursor"); token != "" {
+		cursor, err := model.ParseChangeCursor(token)
+		if err != nil {
+			respondError(ctx, http.StatusBadRequest, "invalid cursor; pass next_cursor from a previous response")
+			return
+		}
+		since = cursor
+	} else {
+		sinceStr := ctx.Query("since")
+		if sinceStr == "" {
+			respondError(ctx, http.StatusBadRequest, "since or cursor is required")
+			return
+		}
+		sinceTime, err := time.Parse(time.RFC3339Nano, sinceStr)
+		if err != nil {
+			respondError(ctx, http.StatusBadRequest, "invalid since; use an RFC 3339 timestamp such as 2024-03-01T00:00:00Z")
+			return
+		}
+		since = model.ChangeCursor{UpdatedAt: sinceTime}
+	}
+
+	limit, ok := newPageLimits(c.cfg).parseLimit(ctx, "limit", false)
+	if !ok {
+		return
+	}
+
+	response, err := c.service.ListChanges(ctx.Request.Context(), since, limit)
+	if err != nil {
+		respondServiceError(ctx, "failed to list changed irrigation events", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// GetFarmAggregates handles GET /v1/irrigation/aggregates/farms requests
+// @Summary Aggregate irrigation totals per farm
+// @Description Returns event counts, sums, and averages of every farm with events in the date range
+// @Tags irrigation
+// @Produce json
+// @Param start query string false "Start date (YYYY-MM-DD format, defaults to 90 days ago)" example(2024-01-01)
+// @Param end query string false "End date (YYYY-MM-DD format, defaults to today)" example(2024-01-31)
+// @Success 200 {object} model.FarmAggregatesResponse "Per-farm totals"
+// @Failure 400 {object} model.APIError "Invalid date format"
+// @Failure 500 {object} model.APIError "Internal server error"
+// @Failure 504 {object} model.APIError "Database query timed out"
+// @Router /v1/irrigation/aggregates/farms [get]
+func (c *IrrigationController) GetFarmAggregates(ctx *gin.Context) {
+	startDate, ok := parseDateQuery(ctx, "start")
+	if !ok {
+		return
+	}
+	endDate, ok := parseDateQuery(ctx, "end")
+	if !ok {
+		return
+	}
+
+	response, err := c.service.AggregateByFarm(ctx.Request.Context(), startDate, endDate)
+	if err != nil {
+		respondServiceError(ctx, "failed to aggregate irrigation data", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// GetSectorAggregates handles GET /v1/irrigation/aggregates/sectors requests
+// @Summary Aggregate irrigation totals per sector
+// @Description Returns event counts, sums, and averages of every sector with events in the date range
+// @Tags irrigation
+// @Produce json
+// @Param start query string false "Start date (YYYY-MM-DD format, defaults to 90 days ago)" example(2024-01-01)
+// @Param end query string false "End date (YYYY-MM-DD format, defaults to today)" example(2024-01-31)
+// @Success 200 {object} model.SectorAggregatesResponse "Per-sector totals"
+// @Failure 400 {object} model.APIError "Invalid date format"
+// @Failure 500 {object} model.APIError "Internal server error"
+// @Failure 504 {object} model.APIError "Database query timed out"
+// @Router /v1/irrigation/aggregates/sectors [get]
+func (c *IrrigationController) GetSectorAggregates(ctx *gin.Context) {
+	startDate, ok := parseDateQuery(ctx, "start")
+	if !ok {
+		return
+	}
+	endDate, ok := parseDateQuery(ctx, "end")
+	if !ok {
+		return
+	}
+
+	response, err := c.service.AggregateBySector(ctx.Request.Context(), startDate, endDate)
+	if err != nil {
+		respondServiceError(ctx, "failed to aggregate irrigation data", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// GetSectorAggregate handles GET /v1/sectors/:id/aggregate requests
+// @Summary Aggregate one sector's irrigation totals
+// @Description Returns the event count, sums, and averages of a single sector in the date range without aggregating any other sector
+// @Tags irrigation
+// @Produce json
+// @Param id path int true "Irrigation sector ID" example(1)
+// @Param start query string false "Start date (YYYY-MM-DD format, defaults to 90 days ago)" example(2024-01-01)
+// @Param end query string false "End date (YYYY-MM-DD format, defaults to today)" example(2024-01-31)
+// @Success 200 {object} model.SectorAggregateResponse "Sector totals"
+// @Failure 400 {object} model.APIError "Invalid sector id or date format"
+// @Failure 404 {object} model.APIError "Sector not found"
+// @Failure 500 {object} model.APIError "Internal server error"
+// @Failure 504 {object} model.APIError "Database query timed out"
+// @Router /v1/sectors/{id}/aggregate [get]
+func (c *IrrigationController) GetSectorAggregate(ctx *gin.Context) {
+	sectorID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		respondError(ctx, http.StatusBadRequest, "invalid sector id format")
+		return
+	}
+
+	startDate, ok := parseDateQuery(ctx, "start")
+	if !ok {
+		return
+	}
+	endDate, ok := parseDateQuery(ctx, "end")
+	if !ok {
+		return
+	}
+
+	response, err := c.service.AggregateSector(ctx.Request.Context(), uint(sectorID), startDate, endDate)
+	if err != nil {
+		if errors.Is(err, service.ErrSectorNotFound) {
+			respondError(ctx, http.StatusNotFound, err.Error())
+			return
+		}
+		respondServiceError(ctx, "failed to aggregate irrigation data", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// parseExpandQuery parses the optional expand parameter of the raw event listings, responding with 400 when invalid
+func parseExpandQuery(ctx *gin.Context) (model.EventExpansions, bool) {
+	expand, err := model.ParseEventExpansions(ctx.Query("expand"))
+	if err != nil {
+		respondError(ctx, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	return expand, true
+}
@@ -0,0 +1,389 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebaespinosa/test_NF/config"
+	"github.com/sebaespinosa/test_NF/internal/features"
+	"github.com/sebaespinosa/test_NF/model"
+	"github.com/sebaespinosa/test_NF/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubIrrigationService struct {
+	events       *model.IrrigationEventsResponse
+	lastModified *time.Time
+	eventCount   int64
+	err          error
+	listCalls    int
+	batchInputs  []model.IrrigationEventInput
+	lastSectorID uint
+	lastPage     int
+	lastLimit    int
+	lastExpand   model.EventExpansions
+	aggregate    *model.SectorAggregateResponse
+	changes      *model.IrrigationChangesResponse
+	lastSince    model.ChangeCursor
+}
+
+func (s *stubIrrigationService) ListFarmEvents(ctx context.Context, farmID uint, startDate, endDate *time.Time, page, limit int, expand model.EventExpansions) (*model.IrrigationEventsResponse, error) {
+	s.listCalls++
+	s.lastPage, s.lastLimit, s.lastExpand = page, limit, expand
+	return s.events, s.err
+}
+
+func (s *stubIrrigationService) ListSectorEvents(ctx context.Context, sectorID uint, startDate, endDate *time.Time, page, limit int, expand model.EventExpansions) (*model.IrrigationEventsResponse, error) {
+	s.lastSectorID, s.lastPage, s.lastLimit, s.lastExpand = sectorID, page, limit, expand
+	return s.events, s.err
+}
+
+func (s *stubIrrigationService) GetFarmEventsLastModified(ctx context.Context, farmID uint, startDate, endDate *time.Time) (*time.Time, int64, error) {
+	return s.lastModified, s.eventCount, s.err
+}
+
+func (s *stubIrrigationService) CreateBatch(ctx context.Context, farmID uint, inputs []model.IrrigationEventInput) (int, error) {
+	s.batchInputs = inputs
+	if s.err != nil {
+		return 0, s.err
+	}
+	return len(inputs), nil
+}
+
+func (s *stubIrrigationService) AggregateByFarm(ctx context.Context, startDate, endDate *time.Time) (*model.FarmAggregatesResponse, error) {
+	return &model.FarmAggregatesResponse{}, s.err
+}
+
+func (s *stubIrrigationService) AggregateBySector(ctx context.Context, startDate, endDate *time.Time) (*model.SectorAggregatesResponse, error) {
+	return &model.SectorAggregatesResponse{}, s.err
+}
+
+func (s *stubIrrigationService) AggregateSector(ctx context.Context, sectorID uint, startDate, endDate *time.Time) (*model.SectorAggregateResponse, error) {
+	s.lastSectorID = sectorID
+	return s.aggregate, s.err
+}
+
+func (s *stubIrrigationService) ListChanges(ctx context.Context, since model.ChangeCursor, limit int) (*model.IrrigationChangesResponse, error) {
+	s.lastSince, s.lastLimit = since, limit
+	return s.changes, s.err
+}
+
+func newIrrigationTestRouter(svc IrrigationEventsService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	ctrl := &IrrigationController{service: svc, features: features.Default()}
+	r.GET("/v1/farms/:farm_id/irrigation/events", ctrl.GetFarmEvents)
+	r.POST("/v1/farms/:farm_id/irrigation/events/batch", ctrl.CreateFarmEventsBatch)
+	r.GET("/v1/sectors/:id/irrigation/events", ctrl.GetSectorEvents)
+	r.GET("/v1/sectors/:id/aggregate", ctrl.GetSectorAggregate)
+	r.GET("/v1/irrigation/changes", ctrl.GetChanges)
+	return r
+}
+
+func TestGetFarmEvents_NotModified(t *testing.T) {
+	lastModified := time.Date(2024, 3, 1, 12, 0, 0, 500, time.UTC)
+	svc := &stubIrrigationService{lastModified: &lastModified}
+	router := newIrrigationTestRouter(svc)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/events", nil)
+	req.Header.Set("If-Modified-Since", lastModified.Format(http.TimeFormat))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+	assert.Zero(t, svc.listCalls)
+}
+
+func TestGetFarmEvents_Modified(t *testing.T) {
+	lastModified := time.Date(2024, 3, 2, 8, 30, 0, 0, time.UTC)
+	svc := &stubIrrigationService{
+		lastModified: &lastModified,
+		events: &model.IrrigationEventsResponse{
+			Data:       []model.IrrigationData{{ID: 1, FarmID: 1}},
+			Pagination: model.PaginationMetadata{Page: 1, Limit: 50, TotalCount: 1, TotalPages: 1},
+		},
+	}
+	router := newIrrigationTestRouter(svc)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/events?start=2024-03-01&end=2024-03-31", nil)
+	req.Header.Set("If-Modified-Since", lastModified.Add(-time.Hour).Format(http.TimeFormat))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, lastModified.Format(http.TimeFormat), w.Header().Get("Last-Modified"))
+	assert.Equal(t, 1, svc.listCalls)
+	assert.Contains(t, w.Body.String(), `"total_count":1`)
+}
+
+func TestGetFarmEvents_IfNoneMatch(t *testing.T) {
+	lastModified := time.Date(2024, 3, 2, 8, 30, 0, 0, time.UTC)
+	svc := &stubIrrigationService{
+		lastModified: &lastModified,
+		eventCount:   2,
+		events:       &model.IrrigationEventsResponse{Data: []model.IrrigationData{{ID: 1, FarmID: 1}}},
+	}
+	router := newIrrigationTestRouter(svc)
+
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/events", nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		// If-None-Match takes precedence over this otherwise matching date
+		req.Header.Set("If-Modified-Since", lastModified.Format(http.TimeFormat))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	etag := get("").Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	w := get(etag)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Equal(t, 0, svc.listCalls)
+
+	// A deleted event leaves updated_at unchanged but changes the ETag
+	svc.eventCount = 1
+	w = get(etag)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+	assert.Equal(t, 1, svc.listCalls)
+}
+
+func TestGetSectorEvents(t *testing.T) {
+	svc := &stubIrrigationService{
+		events: &model.IrrigationEventsResponse{
+			Data:       []model.IrrigationData{{ID: 7, FarmID: 2, IrrigationSectorID: 3}},
+			Pagination: model.PaginationMetadata{Page: 2, Limit: 10, TotalCount: 11, TotalPages: 2},
+		},
+	}
+	router := newIrrigationTestRouter(svc)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/sectors/3/irrigation/events?start=2024-03-01&end=2024-03-31&page=2&limit=10", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, uint(3), svc.lastSectorID)
+	assert.Equal(t, 2, svc.lastPage)
+	assert.Equal(t, 10, svc.lastLimit)
+	assert.Contains(t, w.Body.String(), `"irrigation_sector_id":3`)
+}
+
+func TestGetEvents_LimitPolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		wantCode  int
+		wantLimit int
+	}{
+		{name: "omitted uses the default", query: "", wantCode: http.StatusOK, wantLimit: 20},
+		{name: "over the configured cap", query: "?limit=500", wantCode: http.StatusOK, wantLimit: 200},
+		{name: "all bounded by max buckets", query: "?limit=all", wantCode: http.StatusOK, wantLimit: 3000},
+		{name: "zero", query: "?limit=0", wantCode: http.StatusBadRequest},
+		{name: "negative", query: "?limit=-5", wantCode: http.StatusBadRequest},
+	}
+
+	for _, path := range []string{"/v1/farms/1/irrigation/events", "/v1/sectors/3/irrigation/events"} {
+		for _, tt := range tests {
+			t.Run(path+" "+tt.name, func(t *testing.T) {
+				gin.SetMode(gin.TestMode)
+				svc := &stubIrrigationService{events: &model.IrrigationEventsResponse{}}
+				ctrl := &IrrigationController{
+					service:  svc,
+					cfg:      &config.AnalyticsConfig{DefaultLimit: 20, MaxLimit: 200, MaxBuckets: 3000},
+					features: features.Default(),
+				}
+				r := gin.New()
+				r.GET("/v1/farms/:farm_id/irrigation/events", ctrl.GetFarmEvents)
+				r.GET("/v1/sectors/:id/irrigation/events", ctrl.GetSectorEvents)
+
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path+tt.query, nil))
+
+				require.Equal(t, tt.wantCode, w.Code, w.Body.String())
+				if tt.wantCode != http.StatusOK {
+					assert.Contains(t, w.Body.String(), "invalid limit")
+					assert.Zero(t, svc.lastLimit, "service must not be called")
+					return
+				}
+				assert.Equal(t, tt.wantLimit, svc.lastLimit)
+			})
+		}
+	}
+}
+
+func TestGetSectorEvents_NotFound(t *testing.T) {
+	router := newIrrigationTestRouter(&stubIrrigationService{err: service.ErrSectorNotFound})
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/sectors/99/irrigation/events", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGetSectorEvents_InvalidParams(t *testing.T) {
+	router := newIrrigationTestRouter(&stubIrrigationService{})
+
+	for _, path := range []string{
+		"/v1/sectors/abc/irrigation/events",
+		"/v1/sectors/1/irrigation/events?start=03-01-2024",
+	} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, path)
+	}
+}
+
+func TestCreateFarmEventsBatch_Created(t *testing.T) {
+	svc := &stubIrrigationService{}
+	router := newIrrigationTestRouter(svc)
+
+	body := `[{"irrigation_sector_id": 1, "start_time": "2024-03-01T06:00:00Z", "end_time": "2024-03-01T07:00:00Z", "nominal_amount": 20, "real_amount": 18}]`
+	req := httptest.NewRequest(http.MethodPost, "/v1/farms/1/irrigation/events/batch", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.JSONEq(t, `{"created": 1}`, w.Body.String())
+	require.Len(t, svc.batchInputs, 1)
+}
+
+func TestCreateFarmEventsBatch_ValidationErrors(t *testing.T) {
+	svc := &stubIrrigationService{err: &service.BatchValidationError{Violations: []model.ValidationViolation{
+		{Index: 1, Field: "end_time", Reason: "must be after start_time"},
+	}}}
+	router := newIrrigationTestRouter(svc)
+
+	body := `[{"irrigation_sector_id": 1}, {"irrigation_sector_id": 1}]`
+	req := httptest.NewRequest(http.MethodPost, "/v1/farms/1/irrigation/events/batch", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	var resp model.ValidationErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Details, 1)
+	assert.Equal(t, 1, resp.Details[0].Index)
+	assert.Equal(t, "end_time", resp.Details[0].Field)
+}
+
+func TestGetSectorAggregate(t *testing.T) {
+	svc := &stubIrrigationService{aggregate: &model.SectorAggregateResponse{
+		Sector: model.SectorAggregate{FarmID: 1, SectorID: 7, SectorName: "North", EventCount: 3, RealAmountMM: 50},
+	}}
+	router := newIrrigationTestRouter(svc)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/sectors/7/aggregate?start=2024-03-01&end=2024-03-31", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, uint(7), svc.lastSectorID)
+
+	var resp model.SectorAggregateResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, uint(7), resp.Sector.SectorID)
+	assert.Equal(t, int64(3), resp.Sector.EventCount)
+	assert.Equal(t, 50.0, resp.Sector.RealAmountMM)
+}
+
+func TestGetSectorAggregate_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		url    string
+		err    error
+		status int
+	}{
+		{name: "invalid id", url: "/v1/sectors/abc/aggregate", status: http.StatusBadRequest},
+		{name: "invalid date", url: "/v1/sectors/7/aggregate?end=31-03-2024", status: http.StatusBadRequest},
+		{name: "unknown sector", url: "/v1/sectors/99/aggregate", err: service.ErrSectorNotFound, status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newIrrigationTestRouter(&stubIrrigationService{err: tt.err})
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+}
+
+func TestGetEvents_Expand(t *testing.T) {
+	svc := &stubIrrigationService{events: &model.IrrigationEventsResponse{}}
+	router := newIrrigationTestRouter(svc)
+
+	for _, url := range []string{"/v1/farms/1/irrigation/events?expand=sector,farm,sector", "/v1/sectors/1/irrigation/events?expand=sector,farm"} {
+		svc.lastExpand = nil
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+		assert.Equal(t, http.StatusOK, w.Code, url)
+		assert.Equal(t, model.EventExpansions{model.EventExpandSector, model.EventExpandFarm}, svc.lastExpand, url)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/irrigation/events?expand=owner", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `invalid expand \"owner\"`)
+}
+
+func TestGetChanges(t *testing.T) {
+	svc := &stubIrrigationService{changes: &model.IrrigationChangesResponse{Data: []model.IrrigationData{{ID: 7}}}}
+	router := newIrrigationTestRouter(svc)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/irrigation/changes?since=2024-03-01T00:00:00Z&limit=100", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, model.ChangeCursor{UpdatedAt: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)}, svc.lastSince)
+	assert.Equal(t, 100, svc.lastLimit)
+
+	// A cursor resumes exactly where the previous page stopped and takes precedence over since
+	cursor := model.ChangeCursor{UpdatedAt: time.Date(2024, 3, 2, 8, 30, 0, 123456000, time.UTC), ID: 42}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/irrigation/changes?since=2024-03-01T00:00:00Z&cursor="+cursor.String(), nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, cursor.UpdatedAt.Equal(svc.lastSince.UpdatedAt))
+	assert.Equal(t, uint(42), svc.lastSince.ID)
+	assert.Equal(t, defaultPageLimit, svc.lastLimit)
+
+	// Larger limits are capped at the maximum
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/irrigation/changes?since=2024-03-01T00:00:00Z&limit=5000", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, maxPageLimit, svc.lastLimit)
+
+	for _, query := range []string{"", "?since=2024-03-01", "?cursor=not-a-cursor", "?since=2024-03-01T00:00:00Z&limit=0", "?since=2024-03-01T00:00:00Z&limit=-5", "?since=2024-03-01T00:00:00Z&limit=all"} {
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/irrigation/changes"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestGetChanges_FeatureDisabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	svc := &stubIrrigationService{changes: &model.IrrigationChangesResponse{}}
+	flags := features.Default()
+	flags.ChangesFeed = false
+	r := gin.New()
+	r.GET("/v1/irrigation/changes", (&IrrigationController{service: svc, features: flags}).GetChanges)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/irrigation/changes?since=2024-03-01T00:00:00Z", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Zero(t, svc.lastLimit)
+}
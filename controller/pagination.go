@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebaespinosa/test_NF/config"
+)
+
+// defaultPageLimit is the page size used when limit is omitted and no other default is configured
+const defaultPageLimit = 50
+
+// maxPageLimit caps the page size when ANALYTICS_MAX_LIMIT is not configured
+const maxPageLimit = 1000
+
+// unboundedPageLimit stands in for limit=all when ANALYTICS_MAX_BUCKETS does not bound it
+const unboundedPageLimit = math.MaxInt32
+
+// pageLimits is the page size policy every paginated endpoint applies, from ANALYTICS_DEFAULT_LIMIT,
+// ANALYTICS_MAX_LIMIT and ANALYTICS_MAX_BUCKETS
+type pageLimits struct {
+	defaultLimit int
+	maxLimit     int
+	maxBuckets   int
+}
+
+// newPageLimits reads the policy from cfg, falling back to defaultPageLimit and maxPageLimit for
+// unset values or a nil cfg
+func newPageLimits(cfg *config.AnalyticsConfig) pageLimits {
+	limits := pageLimits{defaultLimit: defaultPageLimit, maxLimit: maxPageLimit}
+	if cfg != nil {
+		if cfg.MaxLimit > 0 {
+			limits.maxLimit = cfg.MaxLimit
+		}
+		if cfg.DefaultLimit > 0 {
+			limits.defaultLimit = cfg.DefaultLimit
+		}
+		limits.maxBuckets = cfg.MaxBuckets
+	}
+	limits.defaultLimit = min(limits.defaultLimit, limits.maxLimit)
+	return limits
+}
+
+// parseLimit reads the page size from the name query parameter, responding with 400 when it is invalid:
+//   - omitted: ANALYTICS_DEFAULT_LIMIT
+//   - a positive integer: capped at ANALYTICS_MAX_LIMIT
+//   - "all", where allowAll: every result, bounded only by ANALYTICS_MAX_BUCKETS (unbounded when that is 0)
+//   - zero, negative or not an integer: 400
+func (l pageLimits) parseLimit(ctx *gin.Context, name string, allowAll bool) (int, bool) {
+	switch value := ctx.Query(name); {
+	case value == "":
+		return l.defaultLimit, true
+	case value == "all" && allowAll:
+		if l.maxBuckets > 0 {
+			return l.maxBuckets, true
+		}
+		return unboundedPageLimit, true
+	default:
+		requested, err := strconv.Atoi(value)
+		if err != nil || requested < 1 {
+			message := "invalid " + name + "; must be a positive integer"
+			if allowAll {
+				message += " or 'all'"
+			}
+			respondError(ctx, http.StatusBadRequest, message)
+			return 0, false
+		}
+		return min(requested, l.maxLimit), true
+	}
+}
+
+// parsePagination reads page (falling back to 1 when missing or invalid) and limit, which accepts "all"
+func (l pageLimits) parsePagination(ctx *gin.Context) (int, int, bool) {
+	page, err := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, ok := l.parseLimit(ctx, "limit", true)
+	return page, limit, ok
+}
@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebaespinosa/test_NF/model"
+	"github.com/sebaespinosa/test_NF/service"
+)
+
+// SectorService is the contract the sector controller depends on (facilitates mocking in tests).
+type SectorService interface {
+	SearchByFarm(ctx context.Context, farmID uint, query string) (*model.IrrigationSectorsResponse, error)
+	ListInactiveByFarm(ctx context.Context, farmID uint, startDate, endDate *time.Time) (*model.InactiveSectorsResponse, error)
+}
+
+// SectorController handles HTTP requests for irrigation sectors
+type SectorController struct {
+	service SectorService
+}
+
+// NewSectorController creates a new SectorController instance
+func NewSectorController(service *service.IrrigationSectorService) *SectorController {
+	return &SectorController{service: service}
+}
+
+// ListFarmSectors handles GET /v1/farms/:farm_id/sectors requests
+// @Summary List or search a farm's irrigation sectors
+// @Description Returns the farm's sectors, filtered by a case-insensitive substring match on name when q is given
+// @Tags sectors
+// @Produce json
+// @Param farm_id path int true "Farm ID" example(1)
+// @Param q query string false "Case-insensitive name substring (omit to list all sectors)" example(north)
+// @Success 200 {object} model.IrrigationSectorsResponse "Matching sectors"
+// @Failure 400 {object} model.APIError "Invalid farm_id"
+// @Failure 500 {object} model.APIError "Internal server error"
+// @Failure 504 {object} model.APIError "Database query timed out"
+// @Router /v1/farms/{farm_id}/sectors [get]
+func (c *SectorController) ListFarmSectors(ctx *gin.Context) {
+	farmID, ok := parseFarmID(ctx)
+	if !ok {
+		return
+	}
+
+	sectors, err := c.service.SearchByFarm(ctx.Request.Context(), farmID, strings.TrimSpace(ctx.Query("q")))
+	if err != nil {
+		respondServiceError(ctx, "failed to fetch irrigation sectors", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, sectors)
+}
+
+// ListInactiveFarmSectors handles GET /v1/farms/:farm_id/sectors/inactive requests
+// @Summary List a farm's sectors without irrigation events
+// @Description For coverage audits: returns the farm's sectors with no event starting in the date range, which the analytics sector breakdown leaves out
+// @Tags sectors
+// @Produce json
+// @Param farm_id path int true "Farm ID" example(1)
+// @Param start query string false "Start date (YYYY-MM-DD format, defaults to 90 days ago)" example(2024-01-01)
+// @Param end query string false "End date (YYYY-MM-DD format, defaults to today)" example(2024-01-31)
+// @Success 200 {object} model.InactiveSectorsResponse "Sectors without events"
+// @Failure 400 {object} model.APIError "Invalid farm_id or date format"
+// @Failure 500 {object} model.APIError "Internal server error"
+// @Failure 504 {object} model.APIError "Database query timed out"
+// @Router /v1/farms/{farm_id}/sectors/inactive [get]
+func (c *SectorController) ListInactiveFarmSectors(ctx *gin.Context) {
+	farmID, ok := parseFarmID(ctx)
+	if !ok {
+		return
+	}
+
+	startDate, ok := parseDateQuery(ctx, "start")
+	if !ok {
+		return
+	}
+	endDate, ok := parseDateQuery(ctx, "end")
+	if !ok {
+		return
+	}
+
+	sectors, err := c.service.ListInactiveByFarm(ctx.Request.Context(), farmID, startDate, endDate)
+	if err != nil {
+		respondServiceError(ctx, "failed to fetch inactive irrigation sectors", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, sectors)
+}
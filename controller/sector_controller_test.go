@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebaespinosa/test_NF/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubSectorService struct {
+	sectors   []model.IrrigationSector
+	lastQuery string
+	lastStart *time.Time
+}
+
+func (s *stubSectorService) SearchByFarm(ctx context.Context, farmID uint, query string) (*model.IrrigationSectorsResponse, error) {
+	s.lastQuery = query
+	return &model.IrrigationSectorsResponse{FarmID: farmID, Query: query, Data: s.sectors}, nil
+}
+
+func (s *stubSectorService) ListInactiveByFarm(ctx context.Context, farmID uint, startDate, endDate *time.Time) (*model.InactiveSectorsResponse, error) {
+	s.lastStart = startDate
+	return &model.InactiveSectorsResponse{FarmID: farmID, Data: s.sectors}, nil
+}
+
+func newSectorTestRouter(svc SectorService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	ctrl := &SectorController{service: svc}
+	r.GET("/v1/farms/:farm_id/sectors", ctrl.ListFarmSectors)
+	r.GET("/v1/farms/:farm_id/sectors/inactive", ctrl.ListInactiveFarmSectors)
+	return r
+}
+
+func TestListFarmSectors_Query(t *testing.T) {
+	svc := &stubSectorService{sectors: []model.IrrigationSector{{ID: 1, FarmID: 1, Name: "North Field"}}}
+	router := newSectorTestRouter(svc)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/farms/1/sectors?q=%20north%20", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "north", svc.lastQuery)
+
+	var resp model.IrrigationSectorsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, uint(1), resp.FarmID)
+	require.Len(t, resp.Data, 1)
+	assert.Equal(t, "North Field", resp.Data[0].Name)
+}
+
+func TestListFarmSectors_InvalidFarmID(t *testing.T) {
+	router := newSectorTestRouter(&stubSectorService{})
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/farms/abc/sectors", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestListInactiveFarmSectors(t *testing.T) {
+	svc := &stubSectorService{sectors: []model.IrrigationSector{{ID: 2, FarmID: 1, Name: "South Field"}}}
+	router := newSectorTestRouter(svc)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/sectors/inactive?start=2024-03-01&end=2024-03-31", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NotNil(t, svc.lastStart)
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), *svc.lastStart)
+
+	var resp model.InactiveSectorsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Data, 1)
+	assert.Equal(t, "South Field", resp.Data[0].Name)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/sectors/inactive?start=March", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
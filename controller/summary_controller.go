@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebaespinosa/test_NF/model"
+	"github.com/sebaespinosa/test_NF/service"
+)
+
+// DailySummaryService is the contract the summary controller depends on (facilitates mocking in tests).
+type DailySummaryService interface {
+	RebuildSummaries(ctx context.Context, farmID uint, startDay, endDay time.Time) (*model.SummaryRebuildResponse, error)
+}
+
+// SummaryController handles administrative requests on the daily summaries
+type SummaryController struct {
+	service DailySummaryService
+}
+
+// NewSummaryController creates a new SummaryController instance
+func NewSummaryController(service *service.SummaryService) *SummaryController {
+	return &SummaryController{service: service}
+}
+
+// RebuildSummaries handles POST /v1/admin/summaries/rebuild requests
+// @Summary Rebuild daily summaries
+// @Description Recomputes a farm's per-sector daily summaries for a range of UTC days from the raw irrigation events, a week per transaction so no long lock is held. Summaries of days whose raw events were archived are kept. Needs an API key that is not limited to specific farms.
+// @Tags admin
+// @Produce json
+// @Param farm_id query int true "Farm whose summaries are rebuilt" example(1)
+// @Param start_date query string true "First day to rebuild (YYYY-MM-DD)" example(2024-01-01)
+// @Param end_date query string true "Last day to rebuild, inclusive (YYYY-MM-DD)" example(2024-03-31)
+// @Success 200 {object} model.SummaryRebuildResponse "Rebuild progress counts"
+// @Failure 400 {object} model.APIError "Missing or invalid parameters"
+// @Failure 403 {object} model.APIError "API key is limited to specific farms"
+// @Failure 404 {object} model.APIError "Farm not found"
+// @Failure 500 {object} model.APIError "Internal server error"
+// @Failure 504 {object} model.APIError "Database query timed out"
+// @Router /v1/admin/summaries/rebuild [post]
+func (c *SummaryController) RebuildSummaries(ctx *gin.Context) {
+	farmID, err := strconv.ParseUint(ctx.Query("farm_id"), 10, 32)
+	if err != nil || farmID == 0 {
+		respondError(ctx, http.StatusBadRequest, "invalid farm_id; must be a positive integer")
+		return
+	}
+
+	startDate, ok := parseDateQuery(ctx, "start_date")
+	if !ok {
+		return
+	}
+	endDate, ok := parseDateQuery(ctx, "end_date")
+	if !ok {
+		return
+	}
+	if startDate == nil || endDate == nil {
+		respondError(ctx, http.StatusBadRequest, "start_date and end_date are required")
+		return
+	}
+	if endDate.Before(*startDate) {
+		respondError(ctx, http.StatusBadRequest, "end_date must not be before start_date")
+		return
+	}
+
+	result, err := c.service.RebuildSummaries(ctx.Request.Context(), uint(farmID), *startDate, *endDate)
+	if err != nil {
+		if errors.Is(err, service.ErrFarmNotFound) {
+			respondError(ctx, http.StatusNotFound, err.Error())
+			return
+		}
+		respondServiceError(ctx, "failed to rebuild daily summaries", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebaespinosa/test_NF/model"
+	"github.com/sebaespinosa/test_NF/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubSummaryService struct {
+	err       error
+	called    bool
+	lastFarm  uint
+	lastStart time.Time
+	lastEnd   time.Time
+}
+
+func (s *stubSummaryService) RebuildSummaries(ctx context.Context, farmID uint, startDay, endDay time.Time) (*model.SummaryRebuildResponse, error) {
+	s.called = true
+	s.lastFarm, s.lastStart, s.lastEnd = farmID, startDay, endDay
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &model.SummaryRebuildResponse{
+		FarmID:    farmID,
+		StartDate: startDay.Format("2006-01-02"),
+		EndDate:   endDay.Format("2006-01-02"),
+		Days:      31,
+		Chunks:    5,
+		Summaries: 12,
+		Events:    40,
+	}, nil
+}
+
+func newSummaryTestRouter(svc DailySummaryService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	ctrl := &SummaryController{service: svc}
+	r.POST("/v1/admin/summaries/rebuild", ctrl.RebuildSummaries)
+	return r
+}
+
+func TestRebuildSummaries_Success(t *testing.T) {
+	svc := &stubSummaryService{}
+	router := newSummaryTestRouter(svc)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/admin/summaries/rebuild?farm_id=1&start_date=2024-03-01&end_date=2024-03-31", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, uint(1), svc.lastFarm)
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), svc.lastStart)
+	assert.Equal(t, time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC), svc.lastEnd)
+
+	var body model.SummaryRebuildResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, 5, body.Chunks)
+	assert.Equal(t, int64(40), body.Events)
+}
+
+func TestRebuildSummaries_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		err      error
+		expected int
+	}{
+		{name: "missing farm", query: "start_date=2024-03-01&end_date=2024-03-31", expected: http.StatusBadRequest},
+		{name: "missing dates", query: "farm_id=1", expected: http.StatusBadRequest},
+		{name: "malformed date", query: "farm_id=1&start_date=03/01/2024&end_date=2024-03-31", expected: http.StatusBadRequest},
+		{name: "reversed range", query: "farm_id=1&start_date=2024-03-31&end_date=2024-03-01", expected: http.StatusBadRequest},
+		{name: "unknown farm", query: "farm_id=99&start_date=2024-03-01&end_date=2024-03-31", err: service.ErrFarmNotFound, expected: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &stubSummaryService{err: tt.err}
+			router := newSummaryTestRouter(svc)
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/admin/summaries/rebuild?"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expected, w.Code)
+			assert.Equal(t, tt.err != nil, svc.called)
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebaespinosa/test_NF/internal/features"
+	"github.com/sebaespinosa/test_NF/internal/middleware"
+	"github.com/sebaespinosa/test_NF/model"
+	"github.com/sebaespinosa/test_NF/service"
+)
+
+// FarmTransferService is the contract the transfer controller depends on (facilitates mocking in tests).
+type FarmTransferService interface {
+	ExportFarm(ctx context.Context, farmID uint, includeData bool, startDate, endDate *time.Time) (*service.SeedData, error)
+	Import(ctx context.Context, seed *service.SeedData, overwrite bool) (*model.ImportResponse, error)
+}
+
+// TransferController handles HTTP requests for exporting and importing farm data
+type TransferController struct {
+	service          FarmTransferService
+	maxImportRecords int
+	features         features.Flags
+}
+
+// NewTransferController creates a new TransferController instance
+// maxImportRecords caps the records per import section; 0 means no limit
+func NewTransferController(service *service.TransferService, maxImportRecords int, flags features.Flags) *TransferController {
+	return &TransferController{service: service, maxImportRecords: maxImportRecords, features: flags}
+}
+
+// ExportFarm handles GET /v1/farms/:farm_id/export requests
+// @Summary Export a farm's configuration
+// @Description Returns the farm, its sectors and optionally its irrigation data in the seed file format, so it can be re-imported
+// @Tags transfer
+// @Produce json
+// @Param farm_id path int true "Farm ID" example(1)
+// @Param include_data query bool false "Include irrigation data within the date range (default: false)" example(true)
+// @Param start query string false "Start date for irrigation data (YYYY-MM-DD format, defaults to 90 days ago)" example(2024-01-01)
+// @Param end query string false "End date for irrigation data (YYYY-MM-DD format, defaults to today)" example(2024-01-31)
+// @Success 200 {object} service.SeedData "Farm export"
+// @Failure 400 {object} model.APIError "Invalid request parameters or date format"
+// @Failure 404 {object} model.APIError "Farm not found, or export disabled (FEATURE_FARM_EXPORT=false)"
+// @Failure 500 {object} model.APIError "Internal server error"
+// @Failure 504 {object} model.APIError "Database query timed out"
+// @Router /v1/farms/{farm_id}/export [get]
+func (c *TransferController) ExportFarm(ctx *gin.Context) {
+	if !c.features.FarmExport {
+		respondError(ctx, http.StatusNotFound, "farm export is disabled on this server")
+		return
+	}
+
+	farmID, ok := parseFarmID(ctx)
+	if !ok {
+		return
+	}
+
+	includeData := false
+	if includeDataStr := ctx.Query("include_data"); includeDataStr != "" {
+		parsed, err := strconv.ParseBool(includeDataStr)
+		if err != nil {
+			respondError(ctx, http.StatusBadRequest, "invalid include_data; use true or false")
+			return
+		}
+		includeData = parsed
+	}
+
+	startDate, ok := parseDateQuery(ctx, "start")
+	if !ok {
+		return
+	}
+	endDate, ok := parseDateQuery(ctx, "end")
+	if !ok {
+		return
+	}
+
+	export, err := c.service.ExportFarm(ctx.Request.Context(), farmID, includeData, startDate, endDate)
+	if err != nil {
+		if errors.Is(err, service.ErrFarmNotFound) {
+			respondError(ctx, http.StatusNotFound, err.Error())
+			return
+		}
+		respondServiceError(ctx, "failed to export farm", err)
+		return
+	}
+
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="farm-%d-export.json"`, farmID))
+	ctx.JSON(http.StatusOK, export)
+}
+
+// ImportSeed handles POST /v1/import requests
+// @Summary Import farm data
+// @Description Validates a body in the seed file format (as produced by the export endpoint) and writes it in a single transaction. By default existing IDs are a conflict; overwrite=true updates them instead.
+// @Tags transfer
+// @Accept json
+// @Produce json
+// @Param overwrite query bool false "Update records whose IDs already exist instead of failing (default: false)" example(false)
+// @Param seed body service.SeedData true "Farms, irrigation sectors and irrigation data to import"
+// @Success 201 {object} model.ImportResponse "Import counts"
+// @Failure 400 {object} model.APIError "Malformed body, unknown fields, or empty import"
+// @Failure 404 {object} model.APIError "Import disabled (FEATURE_IMPORT=false)"
+// @Failure 409 {object} model.APIError "Records with the same IDs already exist"
+// @Failure 413 {object} model.APIError "A section has more records than IMPORT_MAX_RECORDS_PER_SECTION"
+// @Failure 422 {object} model.ValidationErrorResponse "Missing fields or broken references, including a parent_sector_id outside the sector's farm"
+// @Failure 500 {object} model.APIError "Internal server error"
+// @Failure 504 {object} model.APIError "Database query timed out"
+// @Router /v1/import [post]
+func (c *TransferController) ImportSeed(ctx *gin.Context) {
+	if !c.features.Import {
+		respondError(ctx, http.StatusNotFound, "import is disabled on this server")
+		return
+	}
+
+	overwrite := false
+	if overwriteStr := ctx.Query("overwrite"); overwriteStr != "" {
+		parsed, err := strconv.ParseBool(overwriteStr)
+		if err != nil {
+			respondError(ctx, http.StatusBadRequest, "invalid overwrite; use true or false")
+			return
+		}
+		overwrite = parsed
+	}
+
+	// Decode strictly, like seed files, so misspelled fields are reported instead of dropped,
+	// and stop reading a section once it exceeds the record limit
+	seed, err := service.DecodeSeedData(ctx.Request.Body, c.maxImportRecords)
+	if err != nil {
+		var tooLargeErr *service.SeedTooLargeError
+		if errors.As(err, &tooLargeErr) {
+			respondError(ctx, http.StatusRequestEntityTooLarge, tooLargeErr.Error())
+			return
+		}
+		respondError(ctx, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if len(seed.Farms) == 0 && len(seed.IrrigationSectors) == 0 && len(seed.IrrigationData) == 0 {
+		respondError(ctx, http.StatusBadRequest, "import must contain at least one record")
+		return
+	}
+
+	result, err := c.service.Import(ctx.Request.Context(), seed, overwrite)
+	if err != nil {
+		var validationErr *service.SeedValidationError
+		switch {
+		case errors.As(err, &validationErr):
+			ctx.JSON(http.StatusUnprocessableEntity, model.ValidationErrorResponse{
+				Error:         "import contains invalid records",
+				Details:       validationErr.Violations,
+				CorrelationID: middleware.CorrelationID(ctx),
+			})
+		case errors.Is(err, service.ErrImportConflict):
+			respondError(ctx, http.StatusConflict, err.Error())
+		default:
+			respondServiceError(ctx, "failed to import data", err)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, result)
+}
@@ -0,0 +1,228 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebaespinosa/test_NF/internal/features"
+	"github.com/sebaespinosa/test_NF/model"
+	"github.com/sebaespinosa/test_NF/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubTransferService struct {
+	export          *service.SeedData
+	err             error
+	lastIncludeData bool
+	lastOverwrite   bool
+	imported        *service.SeedData
+}
+
+func (s *stubTransferService) ExportFarm(ctx context.Context, farmID uint, includeData bool, startDate, endDate *time.Time) (*service.SeedData, error) {
+	s.lastIncludeData = includeData
+	return s.export, s.err
+}
+
+func (s *stubTransferService) Import(ctx context.Context, seed *service.SeedData, overwrite bool) (*model.ImportResponse, error) {
+	s.imported = seed
+	s.lastOverwrite = overwrite
+	if s.err != nil {
+		return nil, s.err
+	}
+	if err := seed.Validate(); err != nil {
+		return nil, err
+	}
+	return &model.ImportResponse{
+		Farms:             len(seed.Farms),
+		IrrigationSectors: len(seed.IrrigationSectors),
+		IrrigationData:    len(seed.IrrigationData),
+	}, nil
+}
+
+func newTransferTestRouter(svc FarmTransferService) *gin.Engine {
+	return newTransferTestRouterWithLimit(svc, 0)
+}
+
+func newTransferTestRouterWithLimit(svc FarmTransferService, maxImportRecords int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	ctrl := &TransferController{service: svc, maxImportRecords: maxImportRecords, features: features.Default()}
+	r.GET("/v1/farms/:farm_id/export", ctrl.ExportFarm)
+	r.POST("/v1/import", ctrl.ImportSeed)
+	return r
+}
+
+const importBody = `{
+	"farms": [{"id": 1, "name": "Farm A"}],
+	"irrigation_sectors": [{"id": 1, "farm_id": 1, "name": "Sector A"}],
+	"irrigation_data": [{"id": 1, "farm_id": 1, "irrigation_sector_id": 1, "start_time": "2024-03-01T06:00:00Z", "end_time": "2024-03-01T07:00:00Z", "nominal_amount": 20, "real_amount": 18}]
+}`
+
+func TestExportFarm_Shape(t *testing.T) {
+	svc := &stubTransferService{export: &service.SeedData{
+		Farms:             []model.Farm{{ID: 1, Name: "Farm A"}},
+		IrrigationSectors: []model.IrrigationSector{{ID: 1, FarmID: 1, Name: "Sector A"}},
+		IrrigationData: []model.IrrigationData{{
+			ID:                 1,
+			FarmID:             1,
+			IrrigationSectorID: 1,
+			StartTime:          time.Date(2024, 3, 1, 6, 0, 0, 0, time.UTC),
+			EndTime:            time.Date(2024, 3, 1, 7, 0, 0, 0, time.UTC),
+			NominalAmount:      20,
+			RealAmount:         18,
+		}},
+	}}
+	router := newTransferTestRouter(svc)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/farms/1/export?include_data=true&start=2024-03-01&end=2024-03-31", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, svc.lastIncludeData)
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "farm-1-export.json")
+
+	var body map[string][]map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Len(t, body, 3)
+	require.Len(t, body["farms"], 1)
+	require.Len(t, body["irrigation_sectors"], 1)
+	require.Len(t, body["irrigation_data"], 1)
+	assert.Equal(t, "Farm A", body["farms"][0]["name"])
+	// Zero-valued associations are not exported, keeping the seed format
+	assert.NotContains(t, body["irrigation_sectors"][0], "farm")
+	assert.NotContains(t, body["irrigation_data"][0], "irrigation_sector")
+
+	// The export decodes back into SeedData with unknown fields rejected, like seed files
+	decoder := json.NewDecoder(w.Body)
+	decoder.DisallowUnknownFields()
+	var seed service.SeedData
+	require.NoError(t, decoder.Decode(&seed))
+}
+
+func TestExportFarm_NotFound(t *testing.T) {
+	router := newTransferTestRouter(&stubTransferService{err: service.ErrFarmNotFound})
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/farms/99/export", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestImportSeed_Clean(t *testing.T) {
+	svc := &stubTransferService{}
+	router := newTransferTestRouter(svc)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/import", strings.NewReader(importBody))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.JSONEq(t, `{"farms": 1, "irrigation_sectors": 1, "irrigation_data": 1}`, w.Body.String())
+	assert.False(t, svc.lastOverwrite)
+	require.NotNil(t, svc.imported)
+	assert.Equal(t, "Sector A", svc.imported.IrrigationSectors[0].Name)
+}
+
+func TestImportSeed_Conflict(t *testing.T) {
+	svc := &stubTransferService{err: fmt.Errorf("%w: farms already contains id(s) [1]", service.ErrImportConflict)}
+	router := newTransferTestRouter(svc)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/import?overwrite=false", strings.NewReader(importBody))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "farms already contains id(s) [1]")
+}
+
+func TestImportSeed_BrokenReference(t *testing.T) {
+	svc := &stubTransferService{}
+	router := newTransferTestRouter(svc)
+
+	body := `{"farms": [{"id": 1, "name": "Farm A"}], "irrigation_sectors": [{"id": 1, "farm_id": 2, "name": "Orphan"}], "irrigation_data": []}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/import?overwrite=true", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.True(t, svc.lastOverwrite)
+	var resp model.ValidationErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Details, 1)
+	assert.Equal(t, "irrigation_sectors.farm_id", resp.Details[0].Field)
+}
+
+func TestImportSeed_TooManyRecords(t *testing.T) {
+	svc := &stubTransferService{}
+	router := newTransferTestRouterWithLimit(svc, 2)
+
+	// The third event is over the limit; the events after it are never decoded
+	event := `{"id": %d, "farm_id": 1, "irrigation_sector_id": 1, "start_time": "2024-03-01T06:00:00Z", "end_time": "2024-03-01T07:00:00Z", "nominal_amount": 20, "real_amount": 18}`
+	events := make([]string, 5)
+	for i := range events {
+		events[i] = fmt.Sprintf(event, i+1)
+	}
+	body := `{"farms": [{"id": 1, "name": "Farm A"}], "irrigation_data": [` + strings.Join(events, ",") + `]}`
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/import", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Contains(t, w.Body.String(), "irrigation_data has more than 2 records")
+	assert.Nil(t, svc.imported)
+
+	// At the limit the import goes through
+	req = httptest.NewRequest(http.MethodPost, "/v1/import", strings.NewReader(importBody))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+func TestImportSeed_MalformedBody(t *testing.T) {
+	router := newTransferTestRouter(&stubTransferService{})
+
+	for _, body := range []string{`[]`, `{"farms": {"id": 1}}`, `{"farm": []}`, `{"farms": [{"id": 1, "nmae": "x"}]}`} {
+		req := httptest.NewRequest(http.MethodPost, "/v1/import", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+}
+
+func TestTransfer_FeaturesDisabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	svc := &stubTransferService{export: &service.SeedData{}}
+	flags := features.Default()
+	flags.FarmExport, flags.Import = false, false
+	ctrl := &TransferController{service: svc, features: flags}
+	r := gin.New()
+	r.GET("/v1/farms/:farm_id/export", ctrl.ExportFarm)
+	r.POST("/v1/import", ctrl.ImportSeed)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/farms/1/export", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "farm export is disabled")
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/import", strings.NewReader(importBody)))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Nil(t, svc.imported)
+}
@@ -0,0 +1,29 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebaespinosa/test_NF/model"
+)
+
+// VersionController serves the build metadata of the running binary
+type VersionController struct {
+	info model.VersionResponse
+}
+
+// NewVersionController creates a new VersionController instance
+func NewVersionController(info model.VersionResponse) *VersionController {
+	return &VersionController{info: info}
+}
+
+// GetVersion handles GET /version requests
+// @Summary Build metadata
+// @Description Returns the service name, version, git commit, and build time; fields not injected at build time read "unknown"
+// @Tags health
+// @Produce json
+// @Success 200 {object} model.VersionResponse
+// @Router /version [get]
+func (c *VersionController) GetVersion(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, c.info)
+}
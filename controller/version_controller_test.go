@@ -0,0 +1,38 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebaespinosa/test_NF/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetVersion(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	ctrl := NewVersionController(model.VersionResponse{
+		Service:   "irrigation-api",
+		Version:   "1.2.0",
+		GitCommit: "abc1234",
+		BuildTime: "unknown",
+	})
+	r.GET("/version", ctrl.GetVersion)
+
+	req := httptest.NewRequest(http.MethodGet, "/version", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var body map[string]string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "irrigation-api", body["service"])
+	assert.Equal(t, "1.2.0", body["version"])
+	assert.Equal(t, "abc1234", body["git_commit"])
+	assert.Equal(t, "unknown", body["build_time"])
+}
@@ -1,18 +1,24 @@
 package database
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/sebaespinosa/test_NF/config"
+	"github.com/sebaespinosa/test_NF/internal/logging"
 	"github.com/sebaespinosa/test_NF/model"
 	"go.opentelemetry.io/otel"
+	"go.uber.org/zap"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
 	otelgorm "gorm.io/plugin/opentelemetry/tracing"
 )
 
 // Initialize initializes the database connection with GORM and runs migrations
-func Initialize(cfg *config.DatabaseConfig) (*gorm.DB, error) {
+// With cfg.MinWarmConns set, that many pooled connections are opened up front; failures are only logged
+// The returned handle always reads from the primary; analytics reads get their own handle from OpenAnalytics
+func Initialize(cfg *config.DatabaseConfig, logger *logging.Logger) (*gorm.DB, error) {
 	db, err := gorm.Open(postgres.Open(cfg.DSN), &gorm.Config{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
@@ -36,14 +42,95 @@ func Initialize(cfg *config.DatabaseConfig) (*gorm.DB, error) {
 	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
 	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
 
+	// Prime the pool so the first requests don't pay for dialing; a partial warmup is not fatal
+	if cfg.MinWarmConns > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), warmupTimeout)
+		warmed, err := WarmPool(ctx, sqlDB, cfg.MinWarmConns)
+		cancel()
+		if err != nil {
+			logger.Warn("database pool warmup incomplete",
+				zap.Int("requested", cfg.MinWarmConns),
+				zap.Int("warmed", warmed),
+				zap.Error(err),
+			)
+		} else {
+			logger.Info("database pool warmed", zap.Int("connections", warmed))
+		}
+	}
+
+	// Bring amount columns of older schemas to numeric(10,2) before AutoMigrate compares them
+	if err := migrateAmountPrecision(db); err != nil {
+		return nil, fmt.Errorf("failed to run migrations: %w", err)
+	}
+
 	// Run AutoMigrate for schema creation
 	if err := db.AutoMigrate(
 		&model.Farm{},
 		&model.IrrigationSector{},
 		&model.IrrigationData{},
+		&model.IrrigationDailySummary{},
 	); err != nil {
 		return nil, fmt.Errorf("failed to run migrations: %w", err)
 	}
 
+	// Let the farm time-series aggregation read the amounts from the index alone
+	if err := coverFarmTimeIndex(db); err != nil {
+		return nil, fmt.Errorf("failed to run migrations: %w", err)
+	}
+
+	// Tune indexes for the deployment's query patterns without a code change
+	if err := applyExtraIndexes(db, cfg.ExtraIndexes); err != nil {
+		return nil, fmt.Errorf("failed to run migrations: %w", err)
+	}
+	if len(cfg.ExtraIndexes) > 0 {
+		logger.Info("extra database indexes applied", zap.Int("count", len(cfg.ExtraIndexes)))
+	}
+
+	return db, nil
+}
+
+// OpenAnalytics returns the handle analytics reads go through: db itself without cfg.ReplicaDSN,
+// otherwise a separate handle sharing db's connection pool whose reads go to the replica; see UseReplica
+// Only the handle returned here routes to the replica, so every other read keeps seeing the primary
+func OpenAnalytics(db *gorm.DB, cfg *config.DatabaseConfig, logger *logging.Logger) (*gorm.DB, error) {
+	if cfg.ReplicaDSN == "" {
+		return db, nil
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database instance: %w", err)
+	}
+	analyticsDB, err := UseReplica(postgres.New(postgres.Config{Conn: sqlDB}), postgres.Open(cfg.ReplicaDSN), cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := analyticsDB.Use(otelgorm.NewPlugin(
+		otelgorm.WithTracerProvider(otel.GetTracerProvider()),
+		otelgorm.WithDBSystem("postgresql"),
+	)); err != nil {
+		return nil, fmt.Errorf("failed to init otel gorm plugin: %w", err)
+	}
+
+	logger.Info("database read replica configured for analytics")
+	return analyticsDB, nil
+}
+
+// UseReplica opens a handle on primary that routes its reads to replica and keeps writes, and reads
+// marked with dbresolver.Write, on the primary; the replica's pool follows the primary's settings
+// Handles opened elsewhere on the same primary are not affected
+func UseReplica(primary, replica gorm.Dialector, cfg *config.DatabaseConfig) (*gorm.DB, error) {
+	db, err := gorm.Open(primary, &gorm.Config{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open analytics database handle: %w", err)
+	}
+
+	resolver := dbresolver.Register(dbresolver.Config{Replicas: []gorm.Dialector{replica}}).
+		SetMaxOpenConns(cfg.MaxOpenConns).
+		SetMaxIdleConns(cfg.MaxIdleConns).
+		SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	if err := db.Use(resolver); err != nil {
+		return nil, fmt.Errorf("failed to configure read replica: %w", err)
+	}
 	return db, nil
 }
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sebaespinosa/test_NF/config"
+	"github.com/sebaespinosa/test_NF/model"
+	"gorm.io/gorm"
+)
+
+// Irrigation amounts are stored as numeric(amountPrecision, amountScale), matching model.IrrigationData
+const (
+	amountPrecision = 10
+	amountScale     = 2
+)
+
+// amountColumns are the irrigation_data columns holding amounts in mm
+var amountColumns = []string{"nominal_amount", "real_amount"}
+
+// amountColumnInfo is the information_schema description of one amount column
+type amountColumnInfo struct {
+	DataType         string `gorm:"column:data_type"`
+	NumericPrecision *int   `gorm:"column:numeric_precision"`
+	NumericScale     *int   `gorm:"column:numeric_scale"`
+}
+
+// needsAmountMigration reports whether a column differs from numeric(10,2)
+func needsAmountMigration(info amountColumnInfo) bool {
+	return info.DataType != "numeric" ||
+		info.NumericPrecision == nil || *info.NumericPrecision != amountPrecision ||
+		info.NumericScale == nil || *info.NumericScale != amountScale
+}
+
+// migrateAmountPrecision converts irrigation_data amount columns of another type (e.g. real or
+// double precision from an older schema) to numeric(10,2), rounding stored values to 2 decimals
+// Columns already numeric(10,2) and a missing table are left alone, so it is safe to run on every start;
+// it runs before AutoMigrate so the rounding is explicit rather than left to an implicit cast
+func migrateAmountPrecision(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, column := range amountColumns {
+			var info amountColumnInfo
+			result := tx.Raw(`
+				SELECT data_type, numeric_precision, numeric_scale
+				FROM information_schema.columns
+				WHERE table_schema = current_schema() AND table_name = 'irrigation_data' AND column_name = ?
+			`, column).Scan(&info)
+			if result.Error != nil {
+				return fmt.Errorf("failed to inspect irrigation_data.%s: %w", column, result.Error)
+			}
+			if result.RowsAffected == 0 || !needsAmountMigration(info) {
+				continue
+			}
+
+			if err := tx.Exec(fmt.Sprintf(
+				"ALTER TABLE irrigation_data ALTER COLUMN %[1]s TYPE numeric(%[2]d,%[3]d) USING ROUND(%[1]s::numeric, %[3]d)",
+				column, amountPrecision, amountScale,
+			)).Error; err != nil {
+				return fmt.Errorf("failed to convert irrigation_data.%s to numeric(%d,%d): %w", column, amountPrecision, amountScale, err)
+			}
+		}
+		return nil
+	})
+}
+
+// farmTimeIndex is the (farm_id, start_time) index the farm analytics queries range-scan, declared on model.IrrigationData
+const farmTimeIndex = "idx_irrigation_farm_time"
+
+// coverFarmTimeIndex rebuilds idx_irrigation_farm_time, created by AutoMigrate, with
+// INCLUDE (nominal_amount, real_amount) so the farm time-series aggregation is answered by an index-only
+// scan instead of fetching both amounts from the table for every event in the range
+// It runs after AutoMigrate and does nothing once the index includes the amounts (or does not exist);
+// the rebuild blocks writes to irrigation_data while the index is built, on the first start only
+func coverFarmTimeIndex(db *gorm.DB) error {
+	var definition string
+	result := db.Raw(`
+		SELECT indexdef
+		FROM pg_indexes
+		WHERE schemaname = current_schema() AND indexname = ?
+	`, farmTimeIndex).Scan(&definition)
+	if result.Error != nil {
+		return fmt.Errorf("failed to inspect index %s: %w", farmTimeIndex, result.Error)
+	}
+	if result.RowsAffected == 0 || strings.Contains(definition, "INCLUDE") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DROP INDEX " + farmTimeIndex).Error; err != nil {
+			return fmt.Errorf("failed to drop index %s: %w", farmTimeIndex, err)
+		}
+		if err := tx.Exec(fmt.Sprintf(
+			"CREATE INDEX %s ON irrigation_data (farm_id, start_time) INCLUDE (%s)",
+			farmTimeIndex, strings.Join(amountColumns, ", "),
+		)).Error; err != nil {
+			return fmt.Errorf("failed to create covering index %s: %w", farmTimeIndex, err)
+		}
+		return nil
+	})
+}
+
+// applyExtraIndexes creates and drops the DB_EXTRA_INDEXES indexes on irrigation_data, after AutoMigrate
+// IF [NOT] EXISTS makes it safe to run on every start. Config rejects names the models declare, and a
+// drop only touches an index on irrigation_data, so an entry can never remove another table's index
+func applyExtraIndexes(db *gorm.DB, specs []config.IndexSpec) error {
+	for _, spec := range specs {
+		if spec.Drop {
+			if !db.Migrator().HasIndex(&model.IrrigationData{}, spec.Name) {
+				continue
+			}
+			if err := db.Exec("DROP INDEX IF EXISTS " + spec.Name).Error; err != nil {
+				return fmt.Errorf("failed to drop index %s: %w", spec.Name, err)
+			}
+			continue
+		}
+		if err := db.Exec(fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON irrigation_data (%s)", spec.Name, strings.Join(spec.Columns, ", "))).Error; err != nil {
+			return fmt.Errorf("failed to create index %s: %w", spec.Name, err)
+		}
+	}
+	return nil
+}
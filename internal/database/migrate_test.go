@@ -0,0 +1,59 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/sebaespinosa/test_NF/config"
+	"github.com/sebaespinosa/test_NF/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestNeedsAmountMigration(t *testing.T) {
+	intPtr := func(v int) *int { return &v }
+
+	tests := []struct {
+		name string
+		info amountColumnInfo
+		want bool
+	}{
+		{"already numeric(10,2)", amountColumnInfo{DataType: "numeric", NumericPrecision: intPtr(10), NumericScale: intPtr(2)}, false},
+		{"float4", amountColumnInfo{DataType: "real", NumericPrecision: intPtr(24)}, true},
+		{"float8", amountColumnInfo{DataType: "double precision", NumericPrecision: intPtr(53)}, true},
+		{"unbounded numeric", amountColumnInfo{DataType: "numeric"}, true},
+		{"other scale", amountColumnInfo{DataType: "numeric", NumericPrecision: intPtr(10), NumericScale: intPtr(3)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, needsAmountMigration(tt.info))
+		})
+	}
+}
+
+func TestApplyExtraIndexes(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&model.Farm{}, &model.IrrigationSector{}, &model.IrrigationData{}))
+
+	create := []config.IndexSpec{{Name: "idx_data_sector_start", Columns: []string{"irrigation_sector_id", "start_time"}}}
+	require.NoError(t, applyExtraIndexes(db, create))
+	assert.True(t, db.Migrator().HasIndex(&model.IrrigationData{}, "idx_data_sector_start"))
+
+	// Running again on every start is harmless
+	require.NoError(t, applyExtraIndexes(db, create))
+
+	require.NoError(t, applyExtraIndexes(db, []config.IndexSpec{{Name: "idx_data_sector_start", Drop: true}, {Name: "idx_never_created", Drop: true}}))
+	assert.False(t, db.Migrator().HasIndex(&model.IrrigationData{}, "idx_data_sector_start"))
+
+	// A drop is scoped to irrigation_data and leaves other tables' indexes alone
+	require.NoError(t, db.Exec("CREATE INDEX idx_farm_name ON farms (name)").Error)
+	require.NoError(t, applyExtraIndexes(db, []config.IndexSpec{{Name: "idx_farm_name", Drop: true}}))
+	assert.True(t, db.Migrator().HasIndex(&model.Farm{}, "idx_farm_name"))
+
+	// An unknown column fails the startup instead of being skipped
+	err = applyExtraIndexes(db, []config.IndexSpec{{Name: "idx_bad", Columns: []string{"no_such_column"}}})
+	assert.ErrorContains(t, err, "failed to create index idx_bad")
+}
@@ -0,0 +1,19 @@
+package database
+
+import "errors"
+
+// ErrQueryTimeout marks a database statement cancelled by DB_STATEMENT_TIMEOUT
+var ErrQueryTimeout = errors.New("database query timed out")
+
+// queryCanceledCode is the PostgreSQL SQLSTATE for a statement cancelled by statement_timeout
+const queryCanceledCode = "57014"
+
+// IsQueryTimeout reports whether err comes from a statement the database cancelled for running
+// longer than its statement timeout
+func IsQueryTimeout(err error) bool {
+	if errors.Is(err, ErrQueryTimeout) {
+		return true
+	}
+	var pgErr interface{ SQLState() string }
+	return errors.As(err, &pgErr) && pgErr.SQLState() == queryCanceledCode
+}
@@ -0,0 +1,37 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sqlStateError mimics the driver error type, which exposes its SQLSTATE code
+type sqlStateError struct {
+	code string
+}
+
+func (e *sqlStateError) Error() string    { return "pg error " + e.code }
+func (e *sqlStateError) SQLState() string { return e.code }
+
+func TestIsQueryTimeout(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"statement timeout", fmt.Errorf("failed to get analytics: %w", &sqlStateError{code: "57014"}), true},
+		{"sentinel", fmt.Errorf("failed to get analytics: %w", ErrQueryTimeout), true},
+		{"other database error", &sqlStateError{code: "42P01"}, false},
+		{"plain error", errors.New("connection refused"), false},
+		{"nil", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsQueryTimeout(tt.err))
+		})
+	}
+}
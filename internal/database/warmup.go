@@ -0,0 +1,65 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// warmupTimeout bounds the whole pool warmup so a slow database cannot hold up startup
+const warmupTimeout = 10 * time.Second
+
+// WarmPool opens n connections concurrently, pings each, and returns them to the pool together,
+// so they stay idle for the first requests instead of being dialed on demand
+// Connections are held until every attempt finishes; otherwise the pool would hand the same one out again
+// Returns how many connections were warmed and the joined errors of the attempts that failed
+// Only up to the pool's MaxIdleConns survive being returned
+func WarmPool(ctx context.Context, db *sql.DB, n int) (int, error) {
+	if n <= 0 {
+		return 0, nil
+	}
+
+	var (
+		mu     sync.Mutex
+		conns  []*sql.Conn
+		errs   []error
+		wg     sync.WaitGroup
+		record = func(conn *sql.Conn, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			conns = append(conns, conn)
+		}
+	)
+
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, err := db.Conn(ctx)
+			if err != nil {
+				record(nil, fmt.Errorf("failed to open connection: %w", err))
+				return
+			}
+			if err := conn.PingContext(ctx); err != nil {
+				conn.Close()
+				record(nil, fmt.Errorf("failed to ping connection: %w", err))
+				return
+			}
+			record(conn, nil)
+		}()
+	}
+	wg.Wait()
+
+	for _, conn := range conns {
+		conn.Close()
+	}
+
+	return len(conns), errors.Join(errs...)
+}
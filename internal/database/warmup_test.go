@@ -0,0 +1,52 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestWarmPool(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	sqlDB.SetMaxOpenConns(10)
+	sqlDB.SetMaxIdleConns(10)
+
+	// gorm.Open leaves its own ping connection idle; start from an empty pool
+	sqlDB.SetMaxIdleConns(0)
+	sqlDB.SetMaxIdleConns(10)
+	require.Zero(t, sqlDB.Stats().OpenConnections)
+
+	warmed, err := WarmPool(context.Background(), sqlDB, 4)
+	require.NoError(t, err)
+	assert.Equal(t, 4, warmed)
+
+	stats := sqlDB.Stats()
+	assert.Equal(t, 4, stats.OpenConnections)
+	assert.Equal(t, 4, stats.Idle)
+}
+
+func TestWarmPool_Disabled(t *testing.T) {
+	warmed, err := WarmPool(context.Background(), nil, 0)
+	require.NoError(t, err)
+	assert.Zero(t, warmed)
+}
+
+func TestWarmPool_Failure(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	require.NoError(t, sqlDB.Close())
+
+	warmed, err := WarmPool(context.Background(), sqlDB, 3)
+	assert.Error(t, err)
+	assert.Zero(t, warmed)
+}
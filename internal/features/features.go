@@ -0,0 +1,62 @@
+package features
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Flags switches optional features on or off per deployment, without removing their code paths
+// Every feature is enabled by default; set its FEATURE_* variable to false to turn it off
+type Flags struct {
+	// Forecast allows forecast=true on the analytics endpoint (FEATURE_FORECAST); disabled, it answers 501
+	Forecast bool
+	// FarmExport serves GET /v1/farms/:farm_id/export (FEATURE_FARM_EXPORT); disabled, it answers 404
+	FarmExport bool
+	// Import serves POST /v1/import (FEATURE_IMPORT); disabled, it answers 404
+	Import bool
+	// ChangesFeed serves GET /v1/irrigation/changes (FEATURE_CHANGES_FEED); disabled, it answers 404
+	ChangesFeed bool
+}
+
+// Default returns the flags with every feature enabled
+func Default() Flags {
+	return Flags{
+		Forecast:    true,
+		FarmExport:  true,
+		Import:      true,
+		ChangesFeed: true,
+	}
+}
+
+// Load reads the flags from FEATURE_* environment variables, keeping the default for unset ones
+// Every malformed value is reported in a single error
+func Load() (Flags, error) {
+	flags := Default()
+	var problems []string
+	for name, flag := range map[string]*bool{
+		"FEATURE_FORECAST":     &flags.Forecast,
+		"FEATURE_FARM_EXPORT":  &flags.FarmExport,
+		"FEATURE_IMPORT":       &flags.Import,
+		"FEATURE_CHANGES_FEED": &flags.ChangesFeed,
+	} {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("invalid %s %q; use true or false", name, value))
+			continue
+		}
+		*flag = enabled
+	}
+	if len(problems) > 0 {
+		// Map iteration order is random; keep the message stable
+		slices.Sort(problems)
+		return Flags{}, fmt.Errorf("invalid feature flags: %s", strings.Join(problems, "; "))
+	}
+	return flags, nil
+}
@@ -0,0 +1,38 @@
+package features
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad_Defaults(t *testing.T) {
+	for _, name := range []string{"FEATURE_FORECAST", "FEATURE_FARM_EXPORT", "FEATURE_IMPORT", "FEATURE_CHANGES_FEED"} {
+		t.Setenv(name, "")
+	}
+
+	flags, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, Default(), flags)
+}
+
+func TestLoad_Overrides(t *testing.T) {
+	t.Setenv("FEATURE_FORECAST", "false")
+	t.Setenv("FEATURE_FARM_EXPORT", "0")
+	t.Setenv("FEATURE_IMPORT", "true")
+	t.Setenv("FEATURE_CHANGES_FEED", "")
+
+	flags, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, Flags{Forecast: false, FarmExport: false, Import: true, ChangesFeed: true}, flags)
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	t.Setenv("FEATURE_FORECAST", "maybe")
+	t.Setenv("FEATURE_IMPORT", "off")
+
+	_, err := Load()
+	require.Error(t, err)
+	assert.Equal(t, `invalid feature flags: invalid FEATURE_FORECAST "maybe"; use true or false; invalid FEATURE_IMPORT "off"; use true or false`, err.Error())
+}
@@ -15,6 +15,8 @@ const (
 	RequestIDKey = "request_id"
 	// SpanIDKey is the key for span ID in logs
 	SpanIDKey = "span_id"
+	// TenantIDKey is the key for tenant ID in logs
+	TenantIDKey = "tenant_id"
 )
 
 // Logger wraps zap logger with context awareness
@@ -65,6 +67,11 @@ func (l *Logger) WithContext(ctx context.Context) *Logger {
 		fields = append(fields, zap.String(SpanIDKey, fmt.Sprintf("%v", spanID)))
 	}
 
+	// Add tenant ID if present in context
+	if tenantID := ctx.Value(TenantIDKey); tenantID != nil {
+		fields = append(fields, zap.String(TenantIDKey, fmt.Sprintf("%v", tenantID)))
+	}
+
 	if len(fields) == 0 {
 		return l
 	}
@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyHeader carries the client's API key
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyAuth rejects requests without a known API key with 401 Unauthorized. It is a no-op
+// when no keys are configured.
+// Keys mapped to farm IDs are scoped: a request for /farms/:farm_id outside that list, or for a
+// route not under a farm, gets 403 Forbidden. Keys mapped to no farms are global.
+// The presented key is compared in constant time against every configured key, so response timing
+// does not reveal how much of a key matched.
+func APIKeyAuth(keys map[string][]uint) gin.HandlerFunc {
+	if len(keys) == 0 {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	// Comparing fixed-length digests keeps the comparison time independent of the keys' lengths too
+	configured := make([]apiKey, 0, len(keys))
+	for key, farmIDs := range keys {
+		configured = append(configured, apiKey{digest: sha256.Sum256([]byte(key)), farmIDs: farmIDs})
+	}
+
+	return func(c *gin.Context) {
+		farmIDs, ok := matchAPIKey(configured, c.GetHeader(APIKeyHeader))
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, NewAPIError(c, "missing or invalid API key"))
+			return
+		}
+
+		if len(farmIDs) == 0 {
+			c.Next()
+			return
+		}
+
+		param := c.Param("farm_id")
+		if param == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, NewAPIError(c, "API key is limited to specific farms"))
+			return
+		}
+
+		// Malformed IDs are left for the handler to reject with 400
+		farmID, err := strconv.ParseUint(param, 10, 32)
+		if err == nil && !slices.Contains(farmIDs, uint(farmID)) {
+			c.AbortWithStatusJSON(http.StatusForbidden, NewAPIError(c, "API key is not authorized for this farm"))
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// apiKey is a configured key's SHA-256 digest and the farms it is limited to
+type apiKey struct {
+	digest  [sha256.Size]byte
+	farmIDs []uint
+}
+
+// matchAPIKey returns the farms of the configured key equal to presented, checking every key without
+// stopping early
+func matchAPIKey(configured []apiKey, presented string) ([]uint, bool) {
+	digest := sha256.Sum256([]byte(presented))
+
+	var farmIDs []uint
+	matched := false
+	for _, key := range configured {
+		if subtle.ConstantTimeCompare(digest[:], key.digest[:]) == 1 {
+			farmIDs, matched = key.farmIDs, true
+		}
+	}
+	return farmIDs, matched
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newAPIKeyRouter(keys map[string][]uint) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(APIKeyAuth(keys))
+	r.GET("/v1/farms/:farm_id/irrigation/analytics", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	r.POST("/v1/import", func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+	return r
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	keys := map[string][]uint{
+		"global-key": nil,
+		"farm-key":   {1, 3},
+	}
+
+	tests := []struct {
+		name   string
+		keys   map[string][]uint
+		key    string
+		method string
+		path   string
+		want   int
+	}{
+		{"authorized farm", keys, "farm-key", http.MethodGet, "/v1/farms/3/irrigation/analytics", http.StatusOK},
+		{"unauthorized farm", keys, "farm-key", http.MethodGet, "/v1/farms/2/irrigation/analytics", http.StatusForbidden},
+		{"scoped key outside a farm", keys, "farm-key", http.MethodPost, "/v1/import", http.StatusForbidden},
+		{"malformed farm left to the handler", keys, "farm-key", http.MethodGet, "/v1/farms/abc/irrigation/analytics", http.StatusOK},
+		{"global key", keys, "global-key", http.MethodGet, "/v1/farms/2/irrigation/analytics", http.StatusOK},
+		{"global key outside a farm", keys, "global-key", http.MethodPost, "/v1/import", http.StatusCreated},
+		{"unknown key", keys, "other-key", http.MethodGet, "/v1/farms/1/irrigation/analytics", http.StatusUnauthorized},
+		{"prefix of a key", keys, "farm-ke", http.MethodGet, "/v1/farms/1/irrigation/analytics", http.StatusUnauthorized},
+		{"missing key", keys, "", http.MethodGet, "/v1/farms/1/irrigation/analytics", http.StatusUnauthorized},
+		{"disabled", nil, "", http.MethodGet, "/v1/farms/1/irrigation/analytics", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newAPIKeyRouter(tt.keys)
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.key != "" {
+				req.Header.Set(APIKeyHeader, tt.key)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebaespinosa/test_NF/internal/logging"
+	"go.uber.org/zap"
+)
+
+const redactedValue = "[REDACTED]"
+
+// BodySamplingMiddleware logs the request and response bodies of a sampled fraction (rate, 0-1) of
+// requests to debug integration issues. Only JSON bodies are logged, with object keys matching
+// redactFields (case-insensitive, at any depth) replaced; other content types cannot be redacted and
+// are omitted.
+// At most maxBytes+1 bytes of each body are buffered: a partial JSON document cannot be redacted
+// reliably, so a longer body is omitted rather than logged partially.
+// The request body is handed on so handlers read it intact, and the response is passed through unchanged.
+// It is a no-op in production or when rate is not positive.
+func BodySamplingMiddleware(env string, rate float64, maxBytes int, redactFields []string, logger *logging.Logger) gin.HandlerFunc {
+	if env == "production" || rate <= 0 {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	redact := make(map[string]struct{}, len(redactFields))
+	for _, field := range redactFields {
+		redact[strings.ToLower(field)] = struct{}{}
+	}
+
+	return func(c *gin.Context) {
+		if rate < 1 && rand.Float64() >= rate {
+			c.Next()
+			return
+		}
+
+		var requestBody []byte
+		requestOverflow := false
+		if c.Request.Body != nil && c.Request.Body != http.NoBody {
+			original := c.Request.Body
+			body, err := io.ReadAll(io.LimitReader(original, int64(maxBytes)+1))
+			// Hand the handler the sampled bytes followed by the rest of the stream
+			c.Request.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(body), original), original}
+			if err != nil {
+				logger.WithContext(c.Request.Context()).Warn("failed to sample request body", zap.Error(err))
+			}
+			requestBody, requestOverflow = body[:min(len(body), maxBytes)], len(body) > maxBytes
+		}
+
+		writer := &sampleWriter{ResponseWriter: c.Writer, limit: maxBytes}
+		c.Writer = writer
+
+		c.Next()
+
+		logger.WithContext(c.Request.Context()).Debug(
+			"sampled request bodies",
+			zap.String("method", c.Request.Method),
+			zap.String("path", c.Request.URL.Path),
+			zap.Int("status", writer.Status()),
+			zap.String("request_body", sampleBody(requestBody, c.GetHeader("Content-Type"), c.GetHeader("Content-Encoding"), requestOverflow, redact, maxBytes)),
+			zap.String("response_body", sampleBody(writer.body.Bytes(), writer.Header().Get("Content-Type"), writer.Header().Get("Content-Encoding"), writer.overflow, redact, maxBytes)),
+		)
+	}
+}
+
+// sampleBody renders body for the log: redacted when it is JSON, omitted otherwise; the redacted
+// document is truncated to maxBytes, as redaction can make it longer than the captured body
+func sampleBody(body []byte, contentType, contentEncoding string, overflow bool, redact map[string]struct{}, maxBytes int) string {
+	if len(body) == 0 {
+		return ""
+	}
+	if contentEncoding != "" && contentEncoding != "identity" {
+		return "[omitted: " + contentEncoding + " encoded body]"
+	}
+	if !isJSONMediaType(contentType) {
+		return "[omitted: non-JSON body]"
+	}
+	if overflow {
+		return "[omitted: JSON body exceeds DEBUG_BODY_MAX_BYTES]"
+	}
+
+	text, ok := redactJSON(body, redact)
+	if !ok {
+		return "[omitted: malformed JSON body]"
+	}
+	if len(text) > maxBytes {
+		return text[:maxBytes] + "...(truncated)"
+	}
+	return text
+}
+
+// isJSONMediaType reports whether contentType is application/json or a +json type
+func isJSONMediaType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+}
+
+// redactJSON replaces the values of redacted keys and re-encodes the document
+func redactJSON(body []byte, redact map[string]struct{}) (string, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var document any
+	if err := decoder.Decode(&document); err != nil {
+		return "", false
+	}
+	encoded, err := json.Marshal(redactValue(document, redact))
+	if err != nil {
+		return "", false
+	}
+	return string(encoded), true
+}
+
+func redactValue(value any, redact map[string]struct{}) any {
+	switch typed := value.(type) {
+	case map[string]any:
+		for key, nested := range typed {
+			if _, ok := redact[strings.ToLower(key)]; ok {
+				typed[key] = redactedValue
+				continue
+			}
+			typed[key] = redactValue(nested, redact)
+		}
+	case []any:
+		for i, nested := range typed {
+			typed[i] = redactValue(nested, redact)
+		}
+	}
+	return value
+}
+
+// sampleWriter passes the response through while keeping a copy of up to limit bytes
+type sampleWriter struct {
+	gin.ResponseWriter
+	limit    int
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (w *sampleWriter) capture(data []byte) {
+	if room := w.limit - w.body.Len(); room < len(data) {
+		w.overflow = true
+		data = data[:max(room, 0)]
+	}
+	w.body.Write(data)
+}
+
+func (w *sampleWriter) Write(data []byte) (int, error) {
+	w.capture(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *sampleWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebaespinosa/test_NF/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func newBodySamplingRouter(env string, rate float64, maxBytes int) (*gin.Engine, *observer.ObservedLogs, *string) {
+	gin.SetMode(gin.TestMode)
+
+	core, logs := observer.New(zap.DebugLevel)
+	logger := &logging.Logger{Logger: zap.New(core)}
+
+	var received string
+	r := gin.New()
+	r.Use(BodySamplingMiddleware(env, rate, maxBytes, []string{"password"}, logger))
+	r.POST("/echo", func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		received = string(body)
+		c.JSON(http.StatusCreated, gin.H{"token": "abc", "ok": true})
+	})
+	return r, logs, &received
+}
+
+func TestBodySampling_LogsSampledBodies(t *testing.T) {
+	router, logs, received := newBodySamplingRouter("development", 1, 2048)
+
+	payload := `{"user":"ana","password":"hunter2","nested":[{"Password":"x"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, payload, *received, "handler must read the original body")
+	assert.JSONEq(t, `{"token":"abc","ok":true}`, w.Body.String())
+
+	entries := logs.FilterMessage("sampled request bodies").All()
+	require.Len(t, entries, 1)
+	fields := entries[0].ContextMap()
+	assert.Equal(t, int64(http.StatusCreated), fields["status"])
+	assert.JSONEq(t, `{"user":"ana","password":"[REDACTED]","nested":[{"Password":"[REDACTED]"}]}`, fields["request_body"].(string))
+	assert.JSONEq(t, `{"token":"abc","ok":true}`, fields["response_body"].(string))
+	assert.NotContains(t, fields["request_body"], "hunter2")
+}
+
+func TestBodySampling_OmitsBodies(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		payload     string
+		want        string
+	}{
+		{name: "JSON over max bytes", contentType: "application/json", payload: `{"password":"` + strings.Repeat("a", 50) + `"}`, want: "[omitted: JSON body exceeds DEBUG_BODY_MAX_BYTES]"},
+		{name: "non-JSON body", contentType: "text/plain", payload: "password=hunter2", want: "[omitted: non-JSON body]"},
+		{name: "missing content type", payload: `{"password":"x"}`, want: "[omitted: non-JSON body]"},
+		{name: "malformed JSON", contentType: "application/json", payload: `{"password":`, want: "[omitted: malformed JSON body]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, logs, received := newBodySamplingRouter("development", 1, 20)
+
+			req := httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(tt.payload))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			router.ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.Equal(t, tt.payload, *received, "handler must read the whole body")
+			entries := logs.FilterMessage("sampled request bodies").All()
+			require.Len(t, entries, 1)
+			assert.Equal(t, tt.want, entries[0].ContextMap()["request_body"])
+		})
+	}
+}
+
+func TestBodySampling_TruncatesRedactedBodies(t *testing.T) {
+	router, logs, _ := newBodySamplingRouter("development", 1, 20)
+
+	// Redaction makes the 18-byte body longer than the 20-byte limit
+	req := httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(`{"password":"abc"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := logs.FilterMessage("sampled request bodies").All()
+	require.Len(t, entries, 1)
+	assert.Equal(t, `{"password":"[REDACT...(truncated)`, entries[0].ContextMap()["request_body"])
+}
+
+func TestBodySampling_Disabled(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+		rate float64
+	}{
+		{name: "production", env: "production", rate: 1},
+		{name: "zero rate", env: "development", rate: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, logs, received := newBodySamplingRouter(tt.env, tt.rate, 2048)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(`{"a":1}`)))
+
+			assert.Equal(t, `{"a":1}`, *received)
+			assert.Zero(t, logs.FilterMessage("sampled request bodies").Len())
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"net"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ClientIP resolves the real client IP for logging and request attribution
+// Forwarded headers (X-Forwarded-For, X-Real-IP) are only honored when the direct peer is
+// one of the engine's trusted proxies (see TRUSTED_PROXIES); otherwise the socket address is used
+func ClientIP(c *gin.Context) string {
+	if ip := c.ClientIP(); ip != "" {
+		return ip
+	}
+
+	// ClientIP returns "" when RemoteAddr cannot be parsed; fall back to the raw peer address
+	host, _, err := net.SplitHostPort(c.Request.RemoteAddr)
+	if err != nil {
+		return c.Request.RemoteAddr
+	}
+	return host
+}
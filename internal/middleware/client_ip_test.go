@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newClientIPRouter(t *testing.T, trustedProxies []string) (*gin.Engine, *string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	require.NoError(t, r.SetTrustedProxies(trustedProxies))
+
+	var resolved string
+	r.GET("/ip", func(c *gin.Context) {
+		resolved = ClientIP(c)
+		c.Status(http.StatusNoContent)
+	})
+	return r, &resolved
+}
+
+func TestClientIP_TrustedProxy(t *testing.T) {
+	router, resolved := newClientIPRouter(t, []string{"10.0.0.0/8"})
+
+	req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+	req.RemoteAddr = "10.1.2.3:52000"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7, 10.4.5.6")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, "203.0.113.7", *resolved)
+}
+
+func TestClientIP_UntrustedPeerIgnoresForwardedHeader(t *testing.T) {
+	router, resolved := newClientIPRouter(t, []string{"10.0.0.0/8"})
+
+	req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+	req.RemoteAddr = "198.51.100.20:52000"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, "198.51.100.20", *resolved)
+}
+
+func TestClientIP_NoTrustedProxies(t *testing.T) {
+	router, resolved := newClientIPRouter(t, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+	req.RemoteAddr = "10.1.2.3:52000"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, "10.1.2.3", *resolved)
+}
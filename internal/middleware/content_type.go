@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireJSONContentType rejects POST, PUT and PATCH requests whose body is not declared as
+// application/json with 415 Unsupported Media Type. It is a no-op when disabled.
+// Requests without a body pass through so handlers can report the missing payload themselves.
+func RequireJSONContentType(enabled bool) gin.HandlerFunc {
+	if !enabled {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch:
+		default:
+			c.Next()
+			return
+		}
+
+		if c.Request.ContentLength == 0 {
+			c.Next()
+			return
+		}
+
+		mediaType, _, err := mime.ParseMediaType(c.GetHeader("Content-Type"))
+		if err != nil || mediaType != "application/json" {
+			c.AbortWithStatusJSON(http.StatusUnsupportedMediaType, NewAPIError(c, "Content-Type must be application/json"))
+			return
+		}
+
+		c.Next()
+	}
+}
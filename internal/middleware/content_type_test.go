@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newContentTypeRouter(enabled bool) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(RequireJSONContentType(enabled))
+	r.POST("/items", func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+	r.GET("/items", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return r
+}
+
+func TestRequireJSONContentType(t *testing.T) {
+	tests := []struct {
+		name        string
+		enabled     bool
+		method      string
+		contentType string
+		body        string
+		want        int
+	}{
+		{"json", true, http.MethodPost, "application/json", `{"a":1}`, http.StatusCreated},
+		{"json with charset", true, http.MethodPost, "application/json; charset=utf-8", `{"a":1}`, http.StatusCreated},
+		{"form", true, http.MethodPost, "application/x-www-form-urlencoded", "a=1", http.StatusUnsupportedMediaType},
+		{"missing content type", true, http.MethodPost, "", `{"a":1}`, http.StatusUnsupportedMediaType},
+		{"empty body", true, http.MethodPost, "", "", http.StatusCreated},
+		{"read request", true, http.MethodGet, "text/plain", "", http.StatusOK},
+		{"disabled", false, http.MethodPost, "application/x-www-form-urlencoded", "a=1", http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newContentTypeRouter(tt.enabled)
+
+			req := httptest.NewRequest(tt.method, "/items", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DebugTimingHeader carries the handler duration in milliseconds
+const DebugTimingHeader = "X-Response-Time-Ms"
+
+// DebugTimingMiddleware reports total handler duration in the X-Response-Time-Ms header
+// when the request carries ?debug_timing=true. It is a no-op in production.
+func DebugTimingMiddleware(env string) gin.HandlerFunc {
+	if env == "production" {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	return func(c *gin.Context) {
+		if c.Query("debug_timing") != "true" {
+			c.Next()
+			return
+		}
+
+		// Headers must be set before the first byte is written, so stamp them from the writer
+		writer := &timingWriter{ResponseWriter: c.Writer, start: time.Now()}
+		c.Writer = writer
+
+		c.Next()
+
+		// Handlers that never wrote a body (e.g. 304) flush headers after the chain returns
+		writer.setHeader()
+	}
+}
+
+// timingWriter sets the timing header just before the response headers are flushed
+type timingWriter struct {
+	gin.ResponseWriter
+	start time.Time
+	done  bool
+}
+
+func (w *timingWriter) setHeader() {
+	if w.done || w.ResponseWriter.Written() {
+		return
+	}
+	w.done = true
+	elapsed := float64(time.Since(w.start).Microseconds()) / 1000
+	w.Header().Set(DebugTimingHeader, strconv.FormatFloat(elapsed, 'f', 3, 64))
+}
+
+func (w *timingWriter) WriteHeaderNow() {
+	w.setHeader()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *timingWriter) Write(data []byte) (int, error) {
+	w.setHeader()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *timingWriter) WriteString(s string) (int, error) {
+	w.setHeader()
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newDebugTimingRouter(env string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(DebugTimingMiddleware(env))
+	r.GET("/ping", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+	r.GET("/empty", func(c *gin.Context) {
+		c.Status(http.StatusNotModified)
+	})
+	return r
+}
+
+func TestDebugTiming_DevelopmentWithFlag(t *testing.T) {
+	router := newDebugTimingRouter("development")
+
+	for _, path := range []string{"/ping?debug_timing=true", "/empty?debug_timing=true"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+		value := w.Header().Get(DebugTimingHeader)
+		require.NotEmpty(t, value, path)
+		ms, err := strconv.ParseFloat(value, 64)
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, ms, 0.0)
+	}
+}
+
+func TestDebugTiming_DevelopmentWithoutFlag(t *testing.T) {
+	router := newDebugTimingRouter("development")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ping", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get(DebugTimingHeader))
+}
+
+func TestDebugTiming_ProductionIgnoresFlag(t *testing.T) {
+	router := newDebugTimingRouter("production")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ping?debug_timing=true", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get(DebugTimingHeader))
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebaespinosa/test_NF/internal/logging"
+	"github.com/sebaespinosa/test_NF/model"
+	"go.uber.org/zap"
+)
+
+// CorrelationID returns the request ID TraceMiddleware assigned to c, or "" outside a traced request
+// It is the same value as the request ID response header (REQUEST_ID_HEADER) and the request_id log field
+func CorrelationID(c *gin.Context) string {
+	return c.GetString(logging.RequestIDKey)
+}
+
+// NewAPIError builds an error body stamped with the request's correlation ID
+func NewAPIError(c *gin.Context, message string) model.APIError {
+	return model.APIError{Error: message, CorrelationID: CorrelationID(c)}
+}
+
+// NoRoute answers requests for unknown paths with a 404 APIError instead of gin's plain-text default
+func NoRoute(c *gin.Context) {
+	c.JSON(http.StatusNotFound, NewAPIError(c, "route not found"))
+}
+
+// NoMethod answers requests whose path exists under other methods with a 405 APIError; gin sets the
+// Allow header before it runs
+func NoMethod(c *gin.Context) {
+	c.JSON(http.StatusMethodNotAllowed, NewAPIError(c, "method not allowed"))
+}
+
+// Recovery turns a handler panic into a 500 APIError and logs it with the request's fields and a stack
+// trace. Register it after TraceMiddleware so the body carries the correlation ID and the trace records
+// the 500.
+func Recovery(logger *logging.Logger) gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, recovered any) {
+		logger.WithContext(c.Request.Context()).Error(
+			"handler panicked",
+			zap.String("method", c.Request.Method),
+			zap.String("path", c.Request.URL.Path),
+			zap.Any("panic", recovered),
+			zap.Stack("stack"),
+		)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, NewAPIError(c, "internal server error"))
+	})
+}
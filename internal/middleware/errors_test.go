@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebaespinosa/test_NF/internal/logging"
+	"github.com/sebaespinosa/test_NF/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestErrorHandlersCarryCorrelationID(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	logger := &logging.Logger{Logger: zap.New(core)}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.HandleMethodNotAllowed = true
+	router.NoRoute(NoRoute)
+	router.NoMethod(NoMethod)
+	router.Use(TraceMiddleware(logger, ""))
+	router.Use(Recovery(logger))
+	router.GET("/ping", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	router.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantError  string
+	}{
+		{name: "unknown route", method: http.MethodGet, path: "/missing", wantStatus: http.StatusNotFound, wantError: "route not found"},
+		{name: "unsupported method", method: http.MethodDelete, path: "/ping", wantStatus: http.StatusMethodNotAllowed, wantError: "method not allowed"},
+		{name: "handler panic", method: http.MethodGet, path: "/panic", wantStatus: http.StatusInternalServerError, wantError: "internal server error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set(DefaultRequestIDHeader, "req-"+tt.name)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, tt.wantStatus, w.Code)
+			var body model.APIError
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, tt.wantError, body.Error)
+			assert.Equal(t, "req-"+tt.name, body.CorrelationID)
+		})
+	}
+
+	panics := logs.FilterMessage("handler panicked").All()
+	require.Len(t, panics, 1)
+	assert.Equal(t, "/panic", panics[0].ContextMap()["path"])
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DecompressGzipBody transparently inflates request bodies sent with Content-Encoding: gzip so
+// handlers read plain JSON. Bodies that inflate beyond maxBytes get 413 Request Entity Too Large,
+// which stops small compressed payloads from expanding into gigabytes (zip bombs); malformed gzip
+// gets 400 and any other encoding 415. Requests without a Content-Encoding pass through.
+func DecompressGzipBody(maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		encoding := strings.ToLower(strings.TrimSpace(c.GetHeader("Content-Encoding")))
+		switch encoding {
+		case "", "identity":
+			c.Next()
+			return
+		case "gzip":
+		default:
+			c.AbortWithStatusJSON(http.StatusUnsupportedMediaType, NewAPIError(c, "unsupported Content-Encoding; only gzip is accepted"))
+			return
+		}
+
+		reader, err := gzip.NewReader(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, NewAPIError(c, "invalid gzip body"))
+			return
+		}
+		defer reader.Close()
+
+		// Read one byte past the limit to tell "exactly at the limit" from "over it"
+		body, err := io.ReadAll(io.LimitReader(reader, maxBytes+1))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, NewAPIError(c, "invalid gzip body"))
+			return
+		}
+		if int64(len(body)) > maxBytes {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge,
+				NewAPIError(c, fmt.Sprintf("decompressed body exceeds %d bytes", maxBytes)))
+			return
+		}
+
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Request.ContentLength = int64(len(body))
+		c.Request.Header.Del("Content-Encoding")
+		c.Request.Header.Set("Content-Length", fmt.Sprint(len(body)))
+
+		c.Next()
+	}
+}
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newGzipBodyRouter(maxBytes int64) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.POST("/items", DecompressGzipBody(maxBytes), func(c *gin.Context) {
+		var items []map[string]any
+		if err := c.ShouldBindJSON(&items); err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"count": len(items)})
+	})
+	return r
+}
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	_, err := writer.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	return buf.Bytes()
+}
+
+func TestDecompressGzipBody(t *testing.T) {
+	payload := []byte(`[{"sector_id":1,"real_amount":18},{"sector_id":2,"real_amount":12}]`)
+
+	tests := []struct {
+		name     string
+		body     []byte
+		encoding string
+		want     int
+		wantBody string
+	}{
+		{"gzipped array", gzipBytes(t, payload), "gzip", http.StatusCreated, `{"count":2}`},
+		{"plain array", payload, "", http.StatusCreated, `{"count":2}`},
+		{"malformed gzip", payload, "gzip", http.StatusBadRequest, ""},
+		{"unsupported encoding", payload, "br", http.StatusUnsupportedMediaType, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newGzipBodyRouter(1 << 20)
+
+			req := httptest.NewRequest(http.MethodPost, "/items", bytes.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.encoding != "" {
+				req.Header.Set("Content-Encoding", tt.encoding)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.want, w.Code)
+			if tt.wantBody != "" {
+				assert.JSONEq(t, tt.wantBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestDecompressGzipBody_Bomb(t *testing.T) {
+	// 10 MiB of a repeated record compresses to a few KiB
+	bomb := gzipBytes(t, []byte("["+strings.Repeat(`{"a":0},`, 10<<20/8)+`{"a":0}]`))
+	require.Less(t, len(bomb), 64<<10)
+
+	router := newGzipBodyRouter(1 << 20)
+
+	req := httptest.NewRequest(http.MethodPost, "/items", bytes.NewReader(bomb))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Contains(t, w.Body.String(), "1048576")
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newSecurityHeadersRouter(enabled bool, cacheControl string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(SecurityHeadersMiddleware(enabled, cacheControl))
+	r.GET("/items", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+	r.GET("/stream", func(c *gin.Context) {
+		c.Header("Cache-Control", "no-transform")
+		c.Data(http.StatusOK, "text/event-stream", []byte("data: {}\n\n"))
+	})
+	return r
+}
+
+func TestSecurityHeadersMiddleware(t *testing.T) {
+	r := newSecurityHeadersRouter(true, "no-cache")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+	assert.Equal(t, "DENY", w.Header().Get("X-Frame-Options"))
+	assert.Equal(t, "no-cache", w.Header().Get("Cache-Control"))
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+}
+
+func TestSecurityHeadersMiddleware_HandlerOverrides(t *testing.T) {
+	r := newSecurityHeadersRouter(true, "no-cache")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stream", nil))
+
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	assert.Equal(t, "no-transform", w.Header().Get("Cache-Control"))
+	assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+}
+
+func TestSecurityHeadersMiddleware_Configuration(t *testing.T) {
+	w := httptest.NewRecorder()
+	newSecurityHeadersRouter(true, "").ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items", nil))
+	assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+	assert.Empty(t, w.Header().Get("Cache-Control"))
+
+	w = httptest.NewRecorder()
+	newSecurityHeadersRouter(false, "no-cache").ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items", nil))
+	assert.Empty(t, w.Header().Get("X-Content-Type-Options"))
+	assert.Empty(t, w.Header().Get("X-Frame-Options"))
+	assert.Empty(t, w.Header().Get("Cache-Control"))
+}
@@ -0,0 +1,192 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebaespinosa/test_NF/internal/logging"
+	"github.com/sebaespinosa/test_NF/model"
+	"go.uber.org/zap"
+)
+
+// ErrRequestTimeout is the cause of a request context cancelled by RequestTimeout
+var ErrRequestTimeout = errors.New("request timed out")
+
+// RequestTimeout bounds the whole handler, including serialization, to timeout. It is a no-op
+// when timeout is 0.
+// The handler runs in its own goroutine against a buffered writer with its own header map. If the
+// deadline passes first, the client gets 503 Service Unavailable right away, built from the headers
+// set before the handler ran; whatever the handler wrote, headers included, is discarded. Database
+// work stops early because the request context is cancelled; a statement timing out on its own
+// (DB_STATEMENT_TIMEOUT) is reported by the handler instead, as 504.
+// The middleware still waits for the handler to return before handing the gin context back, so a
+// handler that ignores its context holds a goroutine, but not the client, until it finishes.
+func RequestTimeout(timeout time.Duration, logger *logging.Logger) gin.HandlerFunc {
+	if timeout <= 0 {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeoutCause(c.Request.Context(), timeout, ErrRequestTimeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		method, path := c.Request.Method, c.Request.URL.Path
+		timeoutBody := NewAPIError(c, "request timed out")
+
+		underlying := c.Writer
+		writer := &bufferedWriter{ResponseWriter: underlying, header: underlying.Header().Clone(), status: http.StatusOK}
+		c.Writer = writer
+
+		done := make(chan struct{})
+		var panicked any
+		go func() {
+			defer close(done)
+			defer func() { panicked = recover() }()
+			c.Next()
+		}()
+
+		select {
+		case <-done:
+			c.Writer = underlying
+			if panicked != nil {
+				panic(panicked)
+			}
+			writer.flush()
+		case <-ctx.Done():
+			discarded := writer.discard()
+			if errors.Is(context.Cause(ctx), ErrRequestTimeout) {
+				logger.WithContext(ctx).Warn(
+					"request timed out",
+					zap.String("method", method),
+					zap.String("path", path),
+					zap.Duration("timeout", timeout),
+					zap.Int("discarded_status", discarded),
+				)
+			}
+			writeTimeout(underlying, timeoutBody)
+
+			<-done
+			c.Writer = underlying
+		}
+	}
+}
+
+// writeTimeout sends the 503 with a Content-Length and flushes it, so the client has the whole
+// response while the handler is still running
+func writeTimeout(w gin.ResponseWriter, apiErr model.APIError) {
+	body, _ := json.Marshal(apiErr)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusServiceUnavailable)
+	_, _ = w.Write(body)
+	w.Flush()
+}
+
+// bufferedWriter holds the headers, status and body until the handler has finished within its
+// deadline. Once discarded, the handler's later writes go nowhere.
+type bufferedWriter struct {
+	gin.ResponseWriter
+	mu        sync.Mutex
+	header    http.Header
+	body      bytes.Buffer
+	status    int
+	written   bool
+	discarded bool
+}
+
+func (w *bufferedWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if code > 0 && !w.written {
+		w.status = code
+	}
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.written = true
+}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.written = true
+	if w.discarded {
+		return len(data), nil
+	}
+	return w.body.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.written = true
+	if w.discarded {
+		return len(s), nil
+	}
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedWriter) Status() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.written {
+		return -1
+	}
+	return w.body.Len()
+}
+
+func (w *bufferedWriter) Written() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.written
+}
+
+// Flush is deferred until the handler completes so a timeout can still replace the response
+func (w *bufferedWriter) Flush() {}
+
+// discard drops the buffered response and returns the status the handler had set
+func (w *bufferedWriter) discard() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.discarded = true
+	w.body.Reset()
+	return w.status
+}
+
+func (w *bufferedWriter) flush() {
+	target := w.ResponseWriter.Header()
+	for key := range target {
+		delete(target, key)
+	}
+	for key, values := range w.header {
+		target[key] = values
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+	if w.body.Len() == 0 {
+		w.ResponseWriter.WriteHeaderNow()
+		return
+	}
+	_, _ = w.ResponseWriter.Write(w.body.Bytes())
+}
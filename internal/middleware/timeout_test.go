@@ -0,0 +1,145 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebaespinosa/test_NF/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// flushRecorder signals the first Flush, which is when the timeout response is complete
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushed chan struct{}
+}
+
+func newFlushRecorder() *flushRecorder {
+	return &flushRecorder{ResponseRecorder: httptest.NewRecorder(), flushed: make(chan struct{})}
+}
+
+func (r *flushRecorder) Flush() {
+	r.ResponseRecorder.Flush()
+	select {
+	case <-r.flushed:
+	default:
+		close(r.flushed)
+	}
+}
+
+// newTimeoutRouter serves /items from a handler that waits for release, ignoring its context
+func newTimeoutRouter(timeout time.Duration, release <-chan struct{}) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Header("X-Before", "kept")
+		c.Next()
+	})
+	r.Use(RequestTimeout(timeout, &logging.Logger{Logger: zap.NewNop()}))
+	r.GET("/items", func(c *gin.Context) {
+		c.Header("X-Items", "2")
+		<-release
+		c.JSON(http.StatusCreated, gin.H{"items": 2})
+	})
+	return r
+}
+
+func TestRequestTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+	}{
+		{"within deadline", time.Second},
+		{"disabled", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release := make(chan struct{})
+			close(release)
+			router := newTimeoutRouter(tt.timeout, release)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items", nil))
+
+			assert.Equal(t, http.StatusCreated, w.Code)
+			assert.JSONEq(t, `{"items":2}`, w.Body.String())
+			assert.Equal(t, "2", w.Header().Get("X-Items"))
+			assert.Equal(t, "kept", w.Header().Get("X-Before"))
+		})
+	}
+}
+
+func TestRequestTimeout_RespondsBeforeHandlerReturns(t *testing.T) {
+	release := make(chan struct{})
+	router := newTimeoutRouter(20*time.Millisecond, release)
+
+	w := newFlushRecorder()
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items", nil))
+	}()
+
+	select {
+	case <-w.flushed:
+	case <-time.After(time.Second):
+		t.Fatal("timeout response was not sent while the handler was blocked")
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.JSONEq(t, `{"error":"request timed out"}`, w.Body.String())
+	assert.Equal(t, "kept", w.Header().Get("X-Before"))
+	assert.Empty(t, w.Header().Get("X-Items"), "headers set by the handler are discarded")
+
+	close(release)
+	select {
+	case <-served:
+	case <-time.After(time.Second):
+		t.Fatal("middleware did not return after the handler finished")
+	}
+	assert.JSONEq(t, `{"error":"request timed out"}`, w.Body.String(), "late writes are discarded")
+}
+
+func TestRequestTimeout_CancelsRequestContext(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	causes := make(chan error, 1)
+	r := gin.New()
+	r.Use(RequestTimeout(10*time.Millisecond, &logging.Logger{Logger: zap.NewNop()}))
+	r.GET("/items", func(c *gin.Context) {
+		<-c.Request.Context().Done()
+		causes <- context.Cause(c.Request.Context())
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Len(t, causes, 1)
+	assert.ErrorIs(t, <-causes, ErrRequestTimeout)
+}
+
+func TestRequestTimeout_PropagatesPanic(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.Use(RequestTimeout(time.Second, &logging.Logger{Logger: zap.NewNop()}))
+	r.GET("/items", func(c *gin.Context) {
+		panic("boom")
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
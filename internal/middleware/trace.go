@@ -13,14 +13,36 @@ import (
 	"go.uber.org/zap"
 )
 
-// TraceMiddleware adds trace and request IDs to context for all requests
+const (
+	// DefaultRequestIDHeader carries the request ID unless REQUEST_ID_HEADER names another header
+	DefaultRequestIDHeader = "X-Request-ID"
+	// TenantIDHeader identifies the tenant (customer) a request belongs to in multi-tenant deployments
+	TenantIDHeader = "X-Tenant-ID"
+	// UnknownTenantID is used when a request carries no TenantIDHeader
+	UnknownTenantID = "unknown"
+)
+
+// TenantID returns the tenant ID TraceMiddleware stored in ctx, or UnknownTenantID outside a traced request
+func TenantID(ctx context.Context) string {
+	if tenantID, ok := ctx.Value(logging.TenantIDKey).(string); ok && tenantID != "" {
+		return tenantID
+	}
+	return UnknownTenantID
+}
+
+// TraceMiddleware adds trace, request and tenant IDs to context for all requests
 // and creates OpenTelemetry spans for distributed tracing
-func TraceMiddleware(logger *logging.Logger) gin.HandlerFunc {
+// The request ID is read from and echoed in requestIDHeader (DefaultRequestIDHeader when empty), e.g.
+// X-Correlation-ID where the infrastructure uses that; it is stored under logging.RequestIDKey either way
+func TraceMiddleware(logger *logging.Logger, requestIDHeader string) gin.HandlerFunc {
 	tracer := otel.Tracer("gin-server")
+	if requestIDHeader == "" {
+		requestIDHeader = DefaultRequestIDHeader
+	}
 
 	return func(c *gin.Context) {
 		// Generate request ID if not provided
-		requestID := c.GetHeader("X-Request-ID")
+		requestID := c.GetHeader(requestIDHeader)
 		if requestID == "" {
 			requestID = uuid.New().String()
 		}
@@ -31,6 +53,13 @@ func TraceMiddleware(logger *logging.Logger) gin.HandlerFunc {
 			traceID = uuid.New().String()
 		}
 
+		tenantID := c.GetHeader(TenantIDHeader)
+		if tenantID == "" {
+			tenantID = UnknownTenantID
+		}
+
+		clientIP := ClientIP(c)
+
 		// Create OpenTelemetry span for this request
 		ctx, span := tracer.Start(
 			c.Request.Context(),
@@ -43,27 +72,32 @@ func TraceMiddleware(logger *logging.Logger) gin.HandlerFunc {
 			attribute.String("http.method", c.Request.Method),
 			attribute.String("http.url", c.Request.URL.String()),
 			attribute.String("http.route", c.Request.URL.Path),
+			attribute.String("http.client_ip", clientIP),
 			attribute.String("request_id", requestID),
 			attribute.String("trace_id", traceID),
+			attribute.String("tenant_id", tenantID),
 		)
 
 		// Store in context for downstream handlers
 		c.Set(logging.RequestIDKey, requestID)
 		c.Set(logging.TraceIDKey, traceID)
+		c.Set(logging.TenantIDKey, tenantID)
 
 		// Add to response headers
-		c.Header("X-Request-ID", requestID)
+		c.Header(requestIDHeader, requestID)
 		c.Header("X-Trace-ID", traceID)
 
 		// Create request-scoped context with correlation IDs and span
 		ctxWithValues := context.WithValue(ctx, logging.RequestIDKey, requestID)
 		ctxWithValues = context.WithValue(ctxWithValues, logging.TraceIDKey, traceID)
+		ctxWithValues = context.WithValue(ctxWithValues, logging.TenantIDKey, tenantID)
 
 		// Log request
 		logger.WithContext(ctxWithValues).Info(
 			"incoming request",
 			zap.String("method", c.Request.Method),
 			zap.String("path", c.Request.URL.Path),
+			zap.String("client_ip", clientIP),
 		)
 
 		c.Request = c.Request.WithContext(ctxWithValues)
@@ -86,6 +120,7 @@ func TraceMiddleware(logger *logging.Logger) gin.HandlerFunc {
 			zap.String("method", c.Request.Method),
 			zap.String("path", c.Request.URL.Path),
 			zap.Int("status", statusCode),
+			zap.String("client_ip", clientIP),
 		)
 	}
 }
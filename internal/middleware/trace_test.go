@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebaespinosa/test_NF/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// newTracedRouter installs a recording tracer provider and an observed logger around TraceMiddleware
+// /ping echoes the tenant it sees through both the gin context and the request context; /correlation
+// echoes the request ID error bodies would carry
+func newTracedRouter(t *testing.T, requestIDHeader string) (*gin.Engine, *tracetest.SpanRecorder, *observer.ObservedLogs) {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	core, logs := observer.New(zap.InfoLevel)
+	logger := &logging.Logger{Logger: zap.New(core)}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(TraceMiddleware(logger, requestIDHeader))
+	r.GET("/ping", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"gin_tenant": c.GetString(logging.TenantIDKey),
+			"ctx_tenant": TenantID(c.Request.Context()),
+		})
+	})
+	r.GET("/correlation", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"correlation_id": CorrelationID(c)})
+	})
+	return r, recorder, logs
+}
+
+func spanAttribute(t *testing.T, recorder *tracetest.SpanRecorder, key attribute.Key) string {
+	t.Helper()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	for _, attr := range spans[0].Attributes() {
+		if attr.Key == key {
+			return attr.Value.AsString()
+		}
+	}
+	t.Fatalf("span has no %q attribute", key)
+	return ""
+}
+
+func TestTraceMiddleware_TenantID(t *testing.T) {
+	router, recorder, logs := newTracedRouter(t, "")
+
+	req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+	req.Header.Set(TenantIDHeader, "acme")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"gin_tenant":"acme","ctx_tenant":"acme"}`, w.Body.String())
+	assert.Equal(t, "acme", spanAttribute(t, recorder, "tenant_id"))
+
+	entries := logs.All()
+	require.NotEmpty(t, entries)
+	for _, entry := range entries {
+		assert.Equal(t, "acme", entry.ContextMap()[logging.TenantIDKey], entry.Message)
+	}
+}
+
+func TestTraceMiddleware_TenantIDDefaultsToUnknown(t *testing.T) {
+	router, recorder, logs := newTracedRouter(t, "")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ping", nil))
+
+	assert.JSONEq(t, `{"gin_tenant":"unknown","ctx_tenant":"unknown"}`, w.Body.String())
+	assert.Equal(t, UnknownTenantID, spanAttribute(t, recorder, "tenant_id"))
+	require.NotEmpty(t, logs.All())
+	assert.Equal(t, UnknownTenantID, logs.All()[0].ContextMap()[logging.TenantIDKey])
+}
+
+func decodeBody(t *testing.T, w *httptest.ResponseRecorder) map[string]string {
+	t.Helper()
+
+	var body map[string]string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	return body
+}
+
+func TestTraceMiddleware_RequestIDHeader(t *testing.T) {
+	router, recorder, logs := newTracedRouter(t, "X-Correlation-ID")
+
+	req := httptest.NewRequest(http.MethodGet, "/correlation", nil)
+	req.Header.Set("X-Correlation-ID", "corr-123")
+	req.Header.Set(DefaultRequestIDHeader, "ignored")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, "corr-123", w.Header().Get("X-Correlation-ID"))
+	assert.Empty(t, w.Header().Get(DefaultRequestIDHeader))
+	assert.Equal(t, "corr-123", decodeBody(t, w)["correlation_id"])
+	assert.Equal(t, "corr-123", spanAttribute(t, recorder, "request_id"))
+	require.NotEmpty(t, logs.All())
+	assert.Equal(t, "corr-123", logs.All()[0].ContextMap()[logging.RequestIDKey])
+
+	// Without the header a generated ID is emitted under the configured name
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/correlation", nil))
+	assert.NotEmpty(t, w.Header().Get("X-Correlation-ID"))
+	assert.Equal(t, w.Header().Get("X-Correlation-ID"), decodeBody(t, w)["correlation_id"])
+}
@@ -0,0 +1,46 @@
+package observability
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics holds the Prometheus collectors exposed on /metrics
+// They live in their own registry rather than the global default, so tests can create fresh instances
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	// AnalyticsRepositoryDuration observes every analytics repository call, labeled by method and
+	// outcome (ok or error), to show which query dominates an endpoint's latency; recorded by
+	// service.ObservedAnalyticsRepository
+	AnalyticsRepositoryDuration *prometheus.HistogramVec
+}
+
+// NewMetrics creates and registers the service's metrics, along with the Go runtime and process collectors
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		AnalyticsRepositoryDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "analytics_repository_call_duration_seconds",
+				Help:    "Duration of analytics repository calls by method and outcome.",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"method", "outcome"},
+		),
+	}
+	m.Registry.MustRegister(
+		m.AnalyticsRepositoryDuration,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Handler serves the registered metrics in the Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.Registry, promhttp.HandlerOpts{})
+}
@@ -6,15 +6,41 @@ import (
 	"net"
 
 	"github.com/sebaespinosa/test_NF/config"
+	"github.com/sebaespinosa/test_NF/internal/logging"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 )
 
+// newTraceExporter creates the OTLP gRPC exporter for endpoint; a variable so tests can make it fail
+var newTraceExporter = func(ctx context.Context, endpoint string) (sdktrace.SpanExporter, error) {
+	return otlptracegrpc.New(
+		ctx,
+		otlptracegrpc.WithEndpoint(endpoint),
+		otlptracegrpc.WithInsecure(),
+		otlptracegrpc.WithDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+	)
+}
+
+// InitTracing initializes Jaeger tracing like InitJaeger, but unless cfg.Required an init failure is logged
+// and a no-op tracer provider is installed instead, so a down collector does not keep the service from starting
+func InitTracing(ctx context.Context, cfg *config.JaegerConfig, serviceCfg *config.ServiceConfig, logger *logging.Logger) (func(context.Context) error, error) {
+	shutdown, err := InitJaeger(ctx, cfg, serviceCfg)
+	if err == nil || cfg.Required {
+		return shutdown, err
+	}
+
+	logger.Warn("tracing disabled: failed to initialize jaeger", zap.Error(err))
+	otel.SetTracerProvider(noop.NewTracerProvider())
+	return func(context.Context) error { return nil }, nil
+}
+
 // InitJaeger initializes OpenTelemetry with OTLP exporter for Jaeger
 func InitJaeger(ctx context.Context, cfg *config.JaegerConfig, serviceCfg *config.ServiceConfig) (func(context.Context) error, error) {
 	// Create OTLP gRPC exporter
@@ -25,12 +51,7 @@ func InitJaeger(ctx context.Context, cfg *config.JaegerConfig, serviceCfg *confi
 	}
 	endpoint := net.JoinHostPort(host, "4317")
 
-	exporter, err := otlptracegrpc.New(
-		ctx,
-		otlptracegrpc.WithEndpoint(endpoint),
-		otlptracegrpc.WithInsecure(),
-		otlptracegrpc.WithDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
-	)
+	exporter, err := newTraceExporter(ctx, endpoint)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
 	}
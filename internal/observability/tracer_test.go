@@ -0,0 +1,59 @@
+package observability
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sebaespinosa/test_NF/config"
+	"github.com/sebaespinosa/test_NF/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// failExporter makes newTraceExporter return errExporter for the rest of the test
+func failExporter(t *testing.T, errExporter error) {
+	t.Helper()
+	original := newTraceExporter
+	previous := otel.GetTracerProvider()
+	newTraceExporter = func(context.Context, string) (sdktrace.SpanExporter, error) {
+		return nil, errExporter
+	}
+	t.Cleanup(func() {
+		newTraceExporter = original
+		otel.SetTracerProvider(previous)
+	})
+}
+
+func TestInitTracing_ExporterFailure(t *testing.T) {
+	errExporter := errors.New("collector unreachable")
+	logger, err := logging.New("test")
+	require.NoError(t, err)
+	serviceCfg := &config.ServiceConfig{Name: "test", Version: "0.0.0"}
+
+	t.Run("NotRequired", func(t *testing.T) {
+		failExporter(t, errExporter)
+
+		shutdown, err := InitTracing(context.Background(), &config.JaegerConfig{AgentHost: "localhost"}, serviceCfg, logger)
+		require.NoError(t, err)
+		require.NotNil(t, shutdown)
+
+		provider := otel.GetTracerProvider()
+		assert.IsType(t, noop.TracerProvider{}, provider)
+		_, span := provider.Tracer("test").Start(context.Background(), "operation")
+		span.End()
+		assert.False(t, span.SpanContext().IsValid())
+		assert.NoError(t, shutdown(context.Background()))
+	})
+
+	t.Run("Required", func(t *testing.T) {
+		failExporter(t, errExporter)
+
+		shutdown, err := InitTracing(context.Background(), &config.JaegerConfig{AgentHost: "localhost", Required: true}, serviceCfg, logger)
+		require.ErrorIs(t, err, errExporter)
+		assert.Nil(t, shutdown)
+	})
+}
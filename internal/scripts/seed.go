@@ -29,7 +29,7 @@ func main() {
 
 	logger.Info("starting database seeding", zap.String("service", cfg.Service.Name))
 
-	db, err := database.Initialize(&cfg.Database)
+	db, err := database.Initialize(&cfg.Database, logger)
 	if err != nil {
 		logger.Fatal("failed to initialize database", zap.Error(err))
 	}
@@ -40,7 +40,7 @@ func main() {
 
 	farmService := service.NewFarmService(farmRepo, logger)
 	sectorService := service.NewIrrigationSectorService(sectorRepo, logger)
-	dataService := service.NewIrrigationDataService(dataRepo, logger)
+	dataService := service.NewIrrigationDataService(dataRepo, sectorRepo, logger)
 
 	seedFilePath := "./internal/seeds/irrigation_seed.json"
 	seedData, err := farmService.LoadSeedData(seedFilePath)
@@ -12,6 +12,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
@@ -19,9 +20,11 @@ import (
 	"github.com/sebaespinosa/test_NF/config"
 	"github.com/sebaespinosa/test_NF/controller"
 	"github.com/sebaespinosa/test_NF/internal/database"
+	"github.com/sebaespinosa/test_NF/internal/features"
 	"github.com/sebaespinosa/test_NF/internal/logging"
 	"github.com/sebaespinosa/test_NF/internal/middleware"
 	"github.com/sebaespinosa/test_NF/internal/observability"
+	"github.com/sebaespinosa/test_NF/model"
 	"github.com/sebaespinosa/test_NF/repository"
 	"github.com/sebaespinosa/test_NF/service"
 	swaggerFiles "github.com/swaggo/files"
@@ -29,12 +32,25 @@ import (
 	"go.uber.org/zap"
 )
 
+// Build metadata, injected at build time:
+//
+//	go build -ldflags "-X main.version=0.0.1 -X main.gitCommit=$(git rev-parse --short HEAD) -X main.buildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	version   = "unknown"
+	gitCommit = "unknown"
+	buildTime = "unknown"
+)
+
 func main() {
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
 		log.Fatalf("failed to load configuration: %v", err)
 	}
+	flags, err := features.Load()
+	if err != nil {
+		log.Fatalf("failed to load feature flags: %v", err)
+	}
 
 	// Initialize logger
 	logger, err := logging.New(cfg.Server.Env)
@@ -56,7 +72,7 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	shutdown, err := observability.InitJaeger(ctx, &cfg.Jaeger, &cfg.Service)
+	shutdown, err := observability.InitTracing(ctx, &cfg.Jaeger, &cfg.Service, logger)
 	if err != nil {
 		logger.Fatal("failed to initialize jaeger", zap.Error(err))
 	}
@@ -71,32 +87,136 @@ func main() {
 	}()
 
 	// Initialize database
-	db, err := database.Initialize(&cfg.Database)
+	db, err := database.Initialize(&cfg.Database, logger)
 	if err != nil {
 		logger.Fatal("failed to initialize database", zap.Error(err))
 	}
 
+	// Analytics reads alone go to the replica when one is configured
+	analyticsDB, err := database.OpenAnalytics(db, &cfg.Database, logger)
+	if err != nil {
+		logger.Fatal("failed to initialize analytics database", zap.Error(err))
+	}
+
+	metrics := observability.NewMetrics()
+
 	// Initialize repositories
 	healthRepo := repository.NewHealthRepository(db)
-	irrigationDataRepo := repository.NewIrrigationDataRepository(db)
+	farmRepo := repository.NewFarmRepository(db)
+	sectorRepo := repository.NewIrrigationSectorRepository(db)
+	irrigationDataRepo := repository.NewIrrigationDataRepository(db).WithExactSums(cfg.Analytics.ExactSums)
+	analyticsRepo := repository.NewAnalyticsRepository(analyticsDB).
+		WithZeroNominalPolicy(cfg.Analytics.ZeroNominalPolicy).
+		WithParallelYoY(cfg.Analytics.YoYParallel).
+		WithExactSums(cfg.Analytics.ExactSums).
+		WithMaxBuckets(cfg.Analytics.MaxBuckets)
+	if cfg.Analytics.LogSQL {
+		analyticsRepo = analyticsRepo.WithSQLLogging(logger, cfg.Server.Env)
+	}
+	transferRepo := repository.NewTransferRepository(db)
 
 	// Initialize services
-	healthService := service.NewHealthService(healthRepo, logger, cfg.Service.Version)
-	analyticsService := service.NewIrrigationAnalyticsService(irrigationDataRepo, logger)
+	yoyCache := service.NewYoYCache()
+	healthService := service.NewHealthService(healthRepo, logger, cfg.Service.Version, cfg.Health.CacheTTL).
+		WithFailureThreshold(cfg.Health.FailureThreshold)
+	if len(cfg.Analytics.YoYCacheFarmIDs) > 0 {
+		healthService = healthService.WithCache(yoyCache)
+	}
+	observedAnalyticsRepo := service.NewObservedAnalyticsRepository(analyticsRepo, metrics.AnalyticsRepositoryDuration)
+	yoyCacheJob := service.NewYoYCacheJob(observedAnalyticsRepo, yoyCache, logger, cfg.Analytics.YoYCacheFarmIDs, cfg.Analytics.DefaultAggregation)
+	analyticsService := service.NewIrrigationAnalyticsService(observedAnalyticsRepo, logger, &cfg.Analytics).
+		WithSectorFinder(sectorRepo).
+		WithFarmFinder(farmRepo).
+		WithYoYCache(yoyCache)
+	irrigationDataService := service.NewIrrigationDataService(irrigationDataRepo, sectorRepo, logger)
+	sectorService := service.NewIrrigationSectorService(sectorRepo, logger)
+	transferService := service.NewTransferService(farmRepo, sectorRepo, irrigationDataRepo, transferRepo, logger)
+	retentionService := service.NewRetentionService(irrigationDataRepo, logger, cfg.Retention.Days)
+	dailySummaryRepo := repository.NewDailySummaryRepository(db).WithExactSums(cfg.Analytics.ExactSums)
+	if cfg.Retention.Archive {
+		retentionService = retentionService.WithArchive(dailySummaryRepo)
+	}
+	summaryService := service.NewSummaryService(dailySummaryRepo, farmRepo, logger)
+	farmService := service.NewFarmService(farmRepo, logger)
 
 	// Initialize controllers
 	healthController := controller.NewHealthController(healthService)
-	analyticsController := controller.NewAnalyticsController(analyticsService)
+	analyticsController := controller.NewAnalyticsController(analyticsService, &cfg.Analytics, flags)
+	irrigationController := controller.NewIrrigationController(irrigationDataService, &cfg.Analytics, flags)
+	transferController := controller.NewTransferController(transferService, cfg.Import.MaxRecordsPerSection, flags)
+	sectorController := controller.NewSectorController(sectorService)
+	summaryController := controller.NewSummaryController(summaryService)
+	farmController := controller.NewFarmController(farmService)
+	versionController := controller.NewVersionController(model.VersionResponse{
+		Service:   cfg.Service.Name,
+		Version:   buildVersion(cfg.Service.Version),
+		GitCommit: gitCommit,
+		BuildTime: buildTime,
+	})
+
+	// Setup Gin router; recovery is registered after tracing so panics answer with a correlation_id
+	router := gin.New()
+	router.Use(gin.Logger())
+	router.HandleMethodNotAllowed = true
+	router.NoRoute(middleware.NoRoute)
+	router.NoMethod(middleware.NoMethod)
 
-	// Setup Gin router
-	router := gin.Default()
+	// Only honor X-Forwarded-For / X-Real-IP from configured proxies; with none, the socket address is used
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		logger.Fatal("invalid TRUSTED_PROXIES", zap.Error(err))
+	}
 
 	// Apply observability middleware
-	router.Use(middleware.TraceMiddleware(logger))
+	router.Use(middleware.TraceMiddleware(logger, cfg.Server.RequestIDHeader))
+	router.Use(middleware.Recovery(logger))
+	router.Use(middleware.DebugTimingMiddleware(cfg.Server.Env))
+	router.Use(middleware.BodySamplingMiddleware(
+		cfg.Server.Env,
+		cfg.Server.BodySampleRate,
+		cfg.Server.BodySampleMaxBytes,
+		cfg.Server.BodySampleRedactFields,
+		logger,
+	))
+	router.Use(middleware.SecurityHeadersMiddleware(cfg.Server.SecurityHeaders, cfg.Server.CacheControl))
+	router.Use(middleware.RequestTimeout(cfg.Server.RequestTimeout, logger))
+	router.Use(middleware.RequireJSONContentType(cfg.Server.RequireJSONContentType))
 
 	// Register routes
 	router.GET("/health", healthController.GetHealth)
-	router.GET("/v1/farms/:farm_id/irrigation/analytics", analyticsController.GetAnalytics)
+	router.GET("/health/live", healthController.GetLiveness)
+	router.GET("/health/ready", healthController.GetReadiness)
+	router.GET("/health/components", healthController.GetComponents)
+	router.GET("/version", versionController.GetVersion)
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	// API routes; with API_KEYS set, each request needs a key authorized for the farm it targets
+	v1 := router.Group("/v1", middleware.APIKeyAuth(cfg.Auth.APIKeys))
+	decompress := middleware.DecompressGzipBody(cfg.Server.MaxDecompressedBytes)
+	v1.GET("/farms/:farm_id/irrigation/analytics", analyticsController.GetAnalytics)
+	v1.HEAD("/farms/:farm_id/irrigation/analytics", analyticsController.GetAnalytics)
+	v1.GET("/farms/:farm_id/irrigation/heatmap", analyticsController.GetHeatmap)
+	v1.GET("/farms/:farm_id/irrigation/alerts", analyticsController.GetAlerts)
+	v1.GET("/farms/:farm_id/irrigation/water-savings", analyticsController.GetWaterSavings)
+	v1.GET("/farms/:farm_id/irrigation/top-days", analyticsController.GetTopDays)
+	v1.GET("/farms/:farm_id/irrigation/dow", analyticsController.GetDayOfWeek)
+	v1.GET("/farms/:farm_id/irrigation/efficiency-vs-amount", analyticsController.GetEfficiencyByAmount)
+	v1.GET("/farms/:farm_id/irrigation/sectors/ranking", analyticsController.GetSectorRanking)
+	v1.GET("/farms/:farm_id/irrigation/schedule-adherence", analyticsController.GetScheduleAdherence)
+	v1.GET("/farms/:farm_id/irrigation/recommend-aggregation", analyticsController.RecommendAggregation)
+	v1.GET("/farms/:farm_id/irrigation/events", irrigationController.GetFarmEvents)
+	v1.POST("/farms/:farm_id/irrigation/events/batch", decompress, irrigationController.CreateFarmEventsBatch)
+	v1.GET("/sectors/:id/irrigation/events", irrigationController.GetSectorEvents)
+	v1.GET("/sectors/:id/aggregate", irrigationController.GetSectorAggregate)
+	v1.GET("/irrigation/aggregates/farms", irrigationController.GetFarmAggregates)
+	v1.GET("/irrigation/aggregates/sectors", irrigationController.GetSectorAggregates)
+	v1.GET("/irrigation/changes", irrigationController.GetChanges)
+	v1.GET("/irrigation/analytics/sectors", analyticsController.GetMultiFarmSectorBreakdown)
+	v1.GET("/farms/:farm_id/sectors", sectorController.ListFarmSectors)
+	v1.GET("/farms/:farm_id/sectors/inactive", sectorController.ListInactiveFarmSectors)
+	v1.GET("/farms/:farm_id/export", transferController.ExportFarm)
+	v1.PUT("/farms/:farm_id/healthy-efficiency-threshold", farmController.SetHealthyThreshold)
+	v1.POST("/import", decompress, transferController.ImportSeed)
+	v1.POST("/admin/summaries/rebuild", summaryController.RebuildSummaries)
 
 	// Swagger docs
 	router.StaticFile("/docs/swagger.json", "./swagger/swagger.json")
@@ -104,22 +224,38 @@ func main() {
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler, swaggerURL))
 
 	// Create HTTP server
+	// The write deadline must leave room for RequestTimeout's 503 to reach the client
+	writeTimeout := 15 * time.Second
+	if cfg.Server.RequestTimeout+5*time.Second > writeTimeout {
+		writeTimeout = cfg.Server.RequestTimeout + 5*time.Second
+	}
 	srv := &http.Server{
 		Addr:         fmt.Sprintf(":%d", cfg.Server.Port),
 		Handler:      router,
 		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
+		WriteTimeout: writeTimeout,
 		IdleTimeout:  60 * time.Second,
 	}
 
 	// Start server in a goroutine
 	go func() {
-		logger.Info("server starting", zap.Uint16("port", cfg.Server.Port))
+		logger.Info("server starting", zap.Int("port", cfg.Server.Port))
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			logger.Fatal("server error", zap.Error(err))
 		}
 	}()
 
+	// Start background jobs; they stop when jobsCtx is cancelled during shutdown
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	var jobs sync.WaitGroup
+	jobs.Go(func() {
+		retentionService.Run(jobsCtx, cfg.Retention.Interval)
+	})
+	jobs.Go(func() {
+		yoyCacheJob.Run(jobsCtx, cfg.Analytics.YoYCacheInterval, cfg.Analytics.YoYCacheJitter)
+	})
+
 	// Wait for interrupt signal
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
@@ -128,12 +264,23 @@ func main() {
 	logger.Info("shutting down server")
 
 	// Graceful shutdown with timeout
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer shutdownCancel()
 
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		logger.Error("server shutdown error", zap.Error(err))
 	}
 
+	stopJobs()
+	jobs.Wait()
+
 	logger.Info("server stopped")
 }
+
+// buildVersion prefers the version injected via -ldflags and falls back to SERVICE_VERSION
+func buildVersion(configured string) string {
+	if version != "unknown" {
+		return version
+	}
+	return configured
+}
@@ -0,0 +1,75 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// Aggregation is the time-series bucket granularity
+type Aggregation string
+
+const (
+	AggregationDaily   Aggregation = "daily"
+	AggregationWeekly  Aggregation = "weekly"
+	AggregationMonthly Aggregation = "monthly"
+)
+
+// ParseAggregation converts a raw query or config value into an Aggregation
+// Returns an error for anything other than daily, weekly or monthly
+func ParseAggregation(value string) (Aggregation, error) {
+	aggregation := Aggregation(value)
+	if !aggregation.Valid() {
+		return "", fmt.Errorf("invalid aggregation %q; must be daily, weekly, or monthly", value)
+	}
+	return aggregation, nil
+}
+
+// Valid reports whether the aggregation is one of the supported granularities
+func (a Aggregation) Valid() bool {
+	switch a {
+	case AggregationDaily, AggregationWeekly, AggregationMonthly:
+		return true
+	default:
+		return false
+	}
+}
+
+// TruncFormat returns the PostgreSQL DATE_TRUNC keyword for the bucket size
+func (a Aggregation) TruncFormat() string {
+	switch a {
+	case AggregationWeekly:
+		return "week"
+	case AggregationMonthly:
+		return "month"
+	default:
+		return "day"
+	}
+}
+
+// BucketStart returns the start of the bucket containing t, in UTC
+// Weeks start on Monday, matching PostgreSQL DATE_TRUNC('week')
+func (a Aggregation) BucketStart(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch a {
+	case AggregationWeekly:
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	case AggregationMonthly:
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return day
+	}
+}
+
+// NextBucket returns the start of the bucket following the one starting at bucketStart
+func (a Aggregation) NextBucket(bucketStart time.Time) time.Time {
+	switch a {
+	case AggregationWeekly:
+		return bucketStart.AddDate(0, 0, 7)
+	case AggregationMonthly:
+		return bucketStart.AddDate(0, 1, 0)
+	default:
+		return bucketStart.AddDate(0, 0, 1)
+	}
+}
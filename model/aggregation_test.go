@@ -0,0 +1,55 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAggregation(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected Aggregation
+		wantErr  bool
+	}{
+		{value: "daily", expected: AggregationDaily},
+		{value: "weekly", expected: AggregationWeekly},
+		{value: "monthly", expected: AggregationMonthly},
+		{value: "hourly", wantErr: true},
+		{value: "Daily", wantErr: true},
+		{value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			aggregation, err := ParseAggregation(tt.value)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, aggregation)
+			assert.True(t, aggregation.Valid())
+		})
+	}
+}
+
+func TestAggregation_TruncFormat(t *testing.T) {
+	assert.Equal(t, "day", AggregationDaily.TruncFormat())
+	assert.Equal(t, "week", AggregationWeekly.TruncFormat())
+	assert.Equal(t, "month", AggregationMonthly.TruncFormat())
+}
+
+func TestAggregation_Buckets(t *testing.T) {
+	// Wednesday afternoon
+	ts := time.Date(2024, 3, 6, 15, 30, 0, 0, time.UTC)
+
+	assert.Equal(t, time.Date(2024, 3, 6, 0, 0, 0, 0, time.UTC), AggregationDaily.BucketStart(ts))
+	assert.Equal(t, time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), AggregationWeekly.BucketStart(ts))
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), AggregationMonthly.BucketStart(ts))
+
+	assert.Equal(t, time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC), AggregationWeekly.NextBucket(AggregationWeekly.BucketStart(ts)))
+	assert.Equal(t, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), AggregationMonthly.NextBucket(AggregationMonthly.BucketStart(ts)))
+}
@@ -1,6 +1,12 @@
 package model
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
 
 // EfficiencyRange represents min/max efficiency values
 type EfficiencyRange struct {
@@ -13,7 +19,10 @@ type AnalyticsMetrics struct {
 	TotalIrrigationVolumeMM float64          `json:"total_irrigation_volume_mm" example:"450.5" description:"Sum of all real_amount values in mm"`
 	TotalIrrigationEvents   int              `json:"total_irrigation_events" example:"120" description:"Count of irrigation events"`
 	AverageEfficiency       *float64         `json:"average_efficiency" example:"0.85" description:"Average of (real_amount / nominal_amount); null if no valid data"`
+	WeightedEfficiency      *float64         `json:"weighted_efficiency" example:"0.82" description:"Total real_amount / total nominal_amount; null without nominal amounts"`
 	EfficiencyRange         *EfficiencyRange `json:"efficiency_range" description:"Min and max efficiency values; null if no valid data"`
+	EfficiencyStdDev        *float64         `json:"stddev_efficiency" example:"0.05" description:"Population standard deviation of per-event efficiency; null with fewer than two valid events"`
+	ActiveSectorCount       int              `json:"active_sector_count" example:"8" description:"Distinct sectors with at least one irrigation event in the period"`
 }
 
 // YoYComparison represents metrics for the same period in a previous year
@@ -22,22 +31,199 @@ type YoYComparison struct {
 	TotalIrrigationVolumeMM *float64         `json:"total_irrigation_volume_mm" description:"Sum of all real_amount values in mm; null if no data for period"`
 	TotalIrrigationEvents   *int             `json:"total_irrigation_events" description:"Count of irrigation events; null if no data for period"`
 	AverageEfficiency       *float64         `json:"average_efficiency" description:"Average efficiency; null if no valid data or period missing"`
+	WeightedEfficiency      *float64         `json:"weighted_efficiency" description:"Total real_amount / total nominal_amount; null without nominal amounts or period missing"`
 	EfficiencyRange         *EfficiencyRange `json:"efficiency_range" description:"Min and max efficiency; null if no valid data or period missing"`
 	DataIncomplete          bool             `json:"data_incomplete" description:"True if no data exists for this period"`
 	Note                    string           `json:"note,omitempty" description:"Explanation for null/missing data"`
+	Reason                  YoYReason        `json:"reason,omitempty" example:"NO_EVENTS" enums:"NO_DATA,NO_EVENTS,PARTIAL" description:"Machine-readable cause of missing data, alongside note; omitted when the period is complete"`
 }
 
+// YoYReason says why a year-over-year period is missing data, for clients to act on instead of parsing Note
+type YoYReason string
+
+const (
+	// YoYReasonNoData means the query returned nothing for the year
+	YoYReasonNoData YoYReason = "NO_DATA"
+	// YoYReasonNoEvents means the year was queried but had no irrigation events
+	YoYReasonNoEvents YoYReason = "NO_EVENTS"
+	// YoYReasonPartial means the year has events but none with a positive nominal amount, so efficiency is null
+	YoYReasonPartial YoYReason = "PARTIAL"
+)
+
 // PeriodComparison represents year-over-year percentage changes
 type PeriodComparison struct {
-	VolumeChangePercent     *float64 `json:"volume_change_percent" example:"7.2" description:"((current - previous) / previous) * 100; null if previous period missing or zero"`
-	EventsChangePercent     *float64 `json:"events_change_percent" example:"4.3" description:"((current - previous) / previous) * 100; null if previous period missing or zero"`
-	EfficiencyChangePercent *float64 `json:"efficiency_change_percent" example:"3.7" description:"((current - previous) / previous) * 100; null if previous period missing or zero"`
+	VolumeChangePercent     *float64 `json:"volume_change_percent" example:"7.2" description:"((current - previous) / previous) * 100; null if previous period missing or zero; -100 if current is zero"`
+	EventsChangePercent     *float64 `json:"events_change_percent" example:"4.3" description:"((current - previous) / previous) * 100; null if previous period missing or zero; -100 if current is zero"`
+	EfficiencyChangePercent *float64 `json:"efficiency_change_percent" example:"3.7" description:"((current - previous) / previous) * 100 of average_efficiency, or weighted_efficiency with efficiency_basis=weighted; null if previous period missing or zero; -100 if current is zero"`
 }
 
-// PeriodComparisonSet represents both year-over-year comparisons
+// PeriodComparisonSet represents both year-over-year comparisons, plus the previous window when requested
 type PeriodComparisonSet struct {
-	VsPeriod1Y *PeriodComparison `json:"vs_same_period_-1" description:"Percentage changes vs last year; null if previous year missing"`
-	VsPeriod2Y *PeriodComparison `json:"vs_same_period_-2" description:"Percentage changes vs two years ago; null if data missing"`
+	VsPeriod1Y   *PeriodComparison `json:"vs_same_period_-1" description:"Percentage changes vs last year; null if previous year missing"`
+	VsPeriod2Y   *PeriodComparison `json:"vs_same_period_-2" description:"Percentage changes vs two years ago; null if data missing"`
+	VsPrevWindow *PeriodComparison `json:"vs_prev_window,omitempty" description:"Percentage changes vs the preceding window of equal length; only with compare=prev_window, null if history does not cover it"`
+}
+
+// PreviousWindow holds metrics for the equal-length window immediately before the analyzed period
+// Null fields indicate no data was available for the window
+type PreviousWindow struct {
+	Period                  IrrigationAnalyticsPeriod `json:"period" description:"Date range of the preceding window"`
+	TotalIrrigationVolumeMM *float64                  `json:"total_irrigation_volume_mm" description:"Sum of all real_amount values in mm; null if no data for the window"`
+	TotalIrrigationEvents   *int                      `json:"total_irrigation_events" description:"Count of irrigation events; null if no data for the window"`
+	AverageEfficiency       *float64                  `json:"average_efficiency" description:"Average efficiency; null if no valid data"`
+	WeightedEfficiency      *float64                  `json:"weighted_efficiency" description:"Total real_amount / total nominal_amount; null without nominal amounts"`
+	DataIncomplete          bool                      `json:"data_incomplete" description:"True if the farm's recorded history starts after the window start, or the window has no events"`
+	Note                    string                    `json:"note,omitempty" description:"Explanation for null/missing data"`
+}
+
+// ComparisonMode selects the baseline the analyzed period is compared against
+type ComparisonMode string
+
+const (
+	// ComparisonYoY compares with the same period in the two previous years
+	ComparisonYoY ComparisonMode = "yoy"
+	// ComparisonPrevWindow additionally compares with the preceding window of equal length
+	ComparisonPrevWindow ComparisonMode = "prev_window"
+)
+
+// Valid reports whether m is a supported comparison mode
+func (m ComparisonMode) Valid() bool {
+	return m == ComparisonYoY || m == ComparisonPrevWindow
+}
+
+// EfficiencyBasis selects which efficiency period comparisons measure the change of
+type EfficiencyBasis string
+
+const (
+	// EfficiencyBasisAverage compares the average of per-bucket efficiencies
+	EfficiencyBasisAverage EfficiencyBasis = "average"
+	// EfficiencyBasisWeighted compares total real over total nominal amount, so larger events count for more
+	EfficiencyBasisWeighted EfficiencyBasis = "weighted"
+)
+
+// Valid reports whether b is a supported efficiency basis
+func (b EfficiencyBasis) Valid() bool {
+	return b == EfficiencyBasisAverage || b == EfficiencyBasisWeighted
+}
+
+// Consistency selects which database an analytics request reads from when a replica is configured
+type Consistency string
+
+const (
+	// ConsistencyReplica reads from the replica, which may lag behind the primary
+	ConsistencyReplica Consistency = "replica"
+	// ConsistencyStrong reads from the primary, so the response includes every committed event
+	ConsistencyStrong Consistency = "strong"
+)
+
+// Valid reports whether c is a supported consistency
+func (c Consistency) Valid() bool {
+	return c == ConsistencyReplica || c == ConsistencyStrong
+}
+
+// SectorGroupBy selects the unit the sector breakdown aggregates by
+type SectorGroupBy string
+
+const (
+	// SectorGroupBySector reports every sector on its own
+	SectorGroupBySector SectorGroupBy = "sector"
+	// SectorGroupByZone rolls child sectors up into their top-level parent sector (the zone)
+	SectorGroupByZone SectorGroupBy = "zone"
+)
+
+// Valid reports whether g is a supported grouping
+func (g SectorGroupBy) Valid() bool {
+	return g == SectorGroupBySector || g == SectorGroupByZone
+}
+
+// SectorSortField selects what the sector breakdown is ordered by
+type SectorSortField string
+
+const (
+	// SectorSortID orders sectors (or zones) by ID; the default
+	SectorSortID SectorSortField = "id"
+	// SectorSortName orders sectors by name
+	SectorSortName SectorSortField = "name"
+	// SectorSortVolume orders sectors by total real volume
+	SectorSortVolume SectorSortField = "volume"
+	// SectorSortEfficiency orders sectors by average efficiency; sectors without one always come last
+	SectorSortEfficiency SectorSortField = "efficiency"
+)
+
+// Valid reports whether f is a supported sort field
+func (f SectorSortField) Valid() bool {
+	switch f {
+	case SectorSortID, SectorSortName, SectorSortVolume, SectorSortEfficiency:
+		return true
+	}
+	return false
+}
+
+// DescendingByDefault reports whether f sorts largest first when no direction is given:
+// volume and efficiency do, so "highest first" needs no extra parameter
+func (f SectorSortField) DescendingByDefault() bool {
+	return f == SectorSortVolume || f == SectorSortEfficiency
+}
+
+// SectorSort orders the sector breakdown; ties fall back to ascending sector ID
+type SectorSort struct {
+	Field      SectorSortField
+	Descending bool
+}
+
+// AnalyticsField names a section of the analytics response that can be requested with ?fields=
+type AnalyticsField string
+
+const (
+	// AnalyticsFieldMetrics is metrics and time_series; they come from the same query and are always returned
+	AnalyticsFieldMetrics AnalyticsField = "metrics"
+	// AnalyticsFieldYoY is same_period_-1, same_period_-2 and their period_comparison entries
+	AnalyticsFieldYoY AnalyticsField = "yoy"
+	// AnalyticsFieldSectors is sector_breakdown and sector_pagination
+	AnalyticsFieldSectors AnalyticsField = "sectors"
+)
+
+// AllAnalyticsFields is every section; it is the default when neither the request nor the deployment picks one
+var AllAnalyticsFields = AnalyticsFields{AnalyticsFieldMetrics, AnalyticsFieldYoY, AnalyticsFieldSectors}
+
+// Valid reports whether f is a known response section
+func (f AnalyticsField) Valid() bool {
+	switch f {
+	case AnalyticsFieldMetrics, AnalyticsFieldYoY, AnalyticsFieldSectors:
+		return true
+	default:
+		return false
+	}
+}
+
+// AnalyticsFields is the set of sections to include in an analytics response
+type AnalyticsFields []AnalyticsField
+
+// ParseAnalyticsFields converts a comma-separated query or config value into AnalyticsFields
+// Returns an error for an empty list or an unknown section name; duplicates are dropped
+func ParseAnalyticsFields(value string) (AnalyticsFields, error) {
+	var fields AnalyticsFields
+	for _, item := range strings.Split(value, ",") {
+		field := AnalyticsField(strings.TrimSpace(item))
+		if field == "" {
+			continue
+		}
+		if !field.Valid() {
+			return nil, fmt.Errorf("invalid field %q; must be metrics, yoy, or sectors", field)
+		}
+		if !fields.Has(field) {
+			fields = append(fields, field)
+		}
+	}
+	if len(fields) == 0 {
+		return nil, errors.New("fields must name at least one of metrics, yoy, or sectors")
+	}
+	return fields, nil
+}
+
+// Has reports whether field is in the set
+func (fs AnalyticsFields) Has(field AnalyticsField) bool {
+	return slices.Contains(fs, field)
 }
 
 // TimeSeriesEntry represents aggregated data for a single time bucket (day/week/month)
@@ -47,14 +233,43 @@ type TimeSeriesEntry struct {
 	RealAmountMM    float64  `json:"real_amount_mm" example:"10.8" description:"Sum of real amounts for the period"`
 	Efficiency      *float64 `json:"efficiency" example:"0.864" description:"Average efficiency for the period: (sum real / sum nominal); null if no valid data"`
 	EventCount      int      `json:"event_count" example:"3" description:"Number of irrigation events in this period"`
+	Anomalous       bool     `json:"anomalous,omitempty" example:"true" description:"Efficiency lies more than two standard deviations from the mean of every bucket in the period, whichever page is returned"`
+	// Set only with cumulative=true, when the amounts above are running totals from the period start
+	BucketNominalAmountMM *float64 `json:"bucket_nominal_amount_mm,omitempty" example:"12.5" description:"This bucket's own nominal sum; only with cumulative=true"`
+	BucketRealAmountMM    *float64 `json:"bucket_real_amount_mm,omitempty" example:"10.8" description:"This bucket's own real sum; only with cumulative=true"`
 }
 
+// ZeroNominalPolicy decides how events without a positive nominal amount affect efficiency
+type ZeroNominalPolicy string
+
+const (
+	// ZeroNominalExclude leaves such events out of efficiency averages and ranges
+	ZeroNominalExclude ZeroNominalPolicy = "exclude"
+	// ZeroNominalZero counts such events as 0% efficiency
+	ZeroNominalZero ZeroNominalPolicy = "zero"
+)
+
+// Valid reports whether p is a supported policy
+func (p ZeroNominalPolicy) Valid() bool {
+	return p == ZeroNominalExclude || p == ZeroNominalZero
+}
+
+// Confidence levels for sector metrics, derived from the number of events behind them
+const (
+	ConfidenceLow    = "low"
+	ConfidenceMedium = "medium"
+	ConfidenceHigh   = "high"
+)
+
 // SectorBreakdown represents aggregated metrics by irrigation sector
 type SectorBreakdown struct {
-	SectorID          uint     `json:"sector_id" example:"1" description:"Irrigation sector ID"`
-	SectorName        string   `json:"sector_name" example:"North Field" description:"Irrigation sector name"`
-	TotalVolumeMM     float64  `json:"total_volume_mm" example:"150.2" description:"Sum of real_amount values"`
-	AverageEfficiency *float64 `json:"average_efficiency" example:"0.88" description:"Average efficiency for the sector; null if no valid data"`
+	SectorID            uint       `json:"sector_id" example:"1" description:"Irrigation sector ID"`
+	SectorName          string     `json:"sector_name" example:"North Field" description:"Irrigation sector name"`
+	TotalVolumeMM       float64    `json:"total_volume_mm" example:"150.2" description:"Sum of real_amount values"`
+	AverageEfficiency   *float64   `json:"average_efficiency" example:"0.88" description:"Average efficiency for the sector; null if no valid data"`
+	SampleSize          int        `json:"sample_size" example:"42" description:"Number of irrigation events behind the sector metrics"`
+	Confidence          string     `json:"confidence" example:"medium" description:"Trust in the sector metrics given its sample size: low, medium, or high"`
+	EfficiencySparkline []*float64 `json:"efficiency_sparkline" description:"Per-bucket efficiency over the period for inline charts; downsampled to ANALYTICS_SPARKLINE_MAX_POINTS, null for empty buckets"`
 }
 
 // PaginationMetadata represents pagination information
@@ -62,7 +277,7 @@ type PaginationMetadata struct {
 	Page       int `json:"page" example:"1" description:"Current page number (1-indexed)"`
 	Limit      int `json:"limit" example:"50" description:"Results per page"`
 	TotalCount int `json:"total_count" example:"250" description:"Total number of records available"`
-	TotalPages int `json:"total_pages" example:"5" description:"Total number of pages: ceil(total_count / limit)"`
+	TotalPages int `json:"total_pages" example:"5" description:"Total number of pages: ceil(total_count / limit); 1 for limit=all"`
 }
 
 // IrrigationAnalyticsPeriod represents the date range analyzed
@@ -75,18 +290,332 @@ type IrrigationAnalyticsPeriod struct {
 type TimeSeries struct {
 	Data       []TimeSeriesEntry  `json:"data" description:"Time-series entries for the period"`
 	Pagination PaginationMetadata `json:"pagination" description:"Pagination metadata"`
+	Truncated  bool               `json:"truncated" example:"false" description:"True when ANALYTICS_MAX_BUCKETS cut buckets this page would otherwise contain"`
+}
+
+// AnalyticsOptions carries optional toggles for the analytics query
+type AnalyticsOptions struct {
+	// WholeDaysOnly ends the range before the in-progress UTC day, the only day a date-only range can partially cover
+	WholeDaysOnly bool
+	// SectorPage and SectorLimit paginate the sector breakdown; SectorLimit 0 returns every sector
+	SectorPage  int
+	SectorLimit int
+	// Forecast projects the next bucket's real amount from the time-series
+	Forecast bool
+	// Compare selects the comparison baseline; empty means ComparisonYoY
+	Compare ComparisonMode
+	// EfficiencyBasis selects the efficiency behind EfficiencyChangePercent; empty means EfficiencyBasisAverage
+	EfficiencyBasis EfficiencyBasis
+	// Fields selects the response sections; nil means the deployment default
+	Fields AnalyticsFields
+	// Cumulative turns the time-series amounts into running totals from the period start
+	Cumulative bool
+	// ExcludeToday ends the range before the bucket that contains the current time, which is still filling up
+	ExcludeToday bool
+	// AnomaliesOnly keeps only the time-series buckets flagged as anomalous and paginates over them;
+	// metrics still cover every bucket of the period
+	AnomaliesOnly bool
+	// IncludeQuality adds the DataQuality summary
+	IncludeQuality bool
+	// IncludeStackedTimeSeries adds the time-series broken down by sector within each bucket
+	IncludeStackedTimeSeries bool
+	// MinReal and MaxReal keep only events whose real amount is within the bounds; nil bounds are open
+	MinReal *float64
+	MaxReal *float64
+	// UnboundedLimit records that the client asked for limit=all rather than a page size
+	UnboundedLimit bool
+	// Consistency routes the queries to the primary when strong; empty means ConsistencyReplica
+	Consistency Consistency
+	// SectorGroupBy rolls the sector breakdown and sparklines up by zone; empty means SectorGroupBySector
+	SectorGroupBy SectorGroupBy
+	// SectorSort orders the sector breakdown; the zero value orders by ascending sector ID
+	SectorSort SectorSort
+}
+
+// StackedSectorAmounts is one sector's layer within a stacked time-series bucket
+type StackedSectorAmounts struct {
+	SectorID        uint    `json:"sector_id" example:"3" description:"Irrigation sector ID (the zone ID with group_by=zone)"`
+	SectorName      string  `json:"sector_name" example:"East Pasture" description:"Irrigation sector name"`
+	NominalAmountMM float64 `json:"nominal_amount_mm" example:"6.5" description:"Sum of the sector's nominal amounts in the bucket"`
+	RealAmountMM    float64 `json:"real_amount_mm" example:"5.2" description:"Sum of the sector's real amounts in the bucket"`
+}
+
+// StackedTimeSeriesEntry is one time bucket of the volume-by-sector chart, with a layer per sector
+type StackedTimeSeriesEntry struct {
+	Date    string                 `json:"date" example:"2024-01-01" description:"Bucket start (YYYY-MM-DD)"`
+	Sectors []StackedSectorAmounts `json:"sectors" description:"Sectors with events in the bucket, ordered by sector ID"`
+}
+
+// DataQuality combines signals for judging how far a period's analytics can be trusted
+type DataQuality struct {
+	EventCount             int     `json:"event_count" example:"240" description:"Irrigation events in the period"`
+	ZeroNominalEvents      int     `json:"zero_nominal_events" example:"3" description:"Events with nominal_amount <= 0; their efficiency is undefined"`
+	OverIrrigationEvents   int     `json:"over_irrigation_events" example:"5" description:"Events whose real amount exceeds the nominal amount (efficiency above 1)"`
+	DuplicateSuspectEvents int     `json:"duplicate_suspect_events" example:"2" description:"Extra events sharing a sector and start time with another event; likely submitted twice"`
+	DaysWithData           int     `json:"days_with_data" example:"85" description:"UTC days in the period with at least one event"`
+	DaysInRange            int     `json:"days_in_range" example:"90" description:"UTC days in the period up to now"`
+	CompletenessPercent    float64 `json:"completeness_percent" example:"94.4" description:"days_with_data / days_in_range * 100; 0 when the period has not started"`
+}
+
+// Forecast projects the bucket after the analyzed period from a least-squares line
+// fitted over the per-bucket real amounts
+type Forecast struct {
+	Period                string  `json:"period" example:"2024-04-01" description:"Start of the projected bucket"`
+	ProjectedRealAmountMM float64 `json:"projected_real_amount_mm" example:"118.4" description:"Projected sum of real amounts for the bucket (never below 0)"`
+	LowerBoundMM          float64 `json:"lower_bound_mm" example:"96.1" description:"Lower bound of the ~95% prediction band (never below 0)"`
+	UpperBoundMM          float64 `json:"upper_bound_mm" example:"140.7" description:"Upper bound of the ~95% prediction band"`
+	SlopeMMPerBucket      float64 `json:"slope_mm_per_bucket" example:"4.2" description:"Fitted change in real amount per bucket"`
+	DataPoints            int     `json:"data_points" example:"12" description:"Buckets with data used for the fit"`
 }
 
 // IrrigationAnalyticsResponse is the complete response for irrigation analytics endpoint
 type IrrigationAnalyticsResponse struct {
-	FarmID           uint                      `json:"farm_id" example:"1" description:"Farm identifier"`
-	FarmName         string                    `json:"farm_name" example:"Green Valley Farm" description:"Farm name"`
-	Period           IrrigationAnalyticsPeriod `json:"period" description:"Date range analyzed"`
-	Aggregation      string                    `json:"aggregation" example:"daily" description:"Aggregation granularity: daily, weekly, monthly"`
-	Metrics          AnalyticsMetrics          `json:"metrics" description:"Current period metrics"`
-	SamePeriod1Y     *YoYComparison            `json:"same_period_-1" description:"Same period last year; null if no data"`
-	SamePeriod2Y     *YoYComparison            `json:"same_period_-2" description:"Same period two years ago; null if no data"`
-	PeriodComparison *PeriodComparisonSet      `json:"period_comparison" description:"Year-over-year percentage change analysis"`
-	TimeSeries       TimeSeries                `json:"time_series" description:"Aggregated metrics by time bucket with pagination"`
-	SectorBreakdown  []SectorBreakdown         `json:"sector_breakdown" description:"Aggregated metrics by sector"`
+	FarmID            uint                      `json:"farm_id" example:"1" description:"Farm identifier"`
+	FarmName          string                    `json:"farm_name" example:"Green Valley Farm" description:"Farm name"`
+	Period            IrrigationAnalyticsPeriod `json:"period" description:"Date range analyzed"`
+	HasData           bool                      `json:"has_data" example:"true" description:"False when the farm has no irrigation events in the range; metrics are then zero placeholders, not measurements"`
+	Aggregation       Aggregation               `json:"aggregation" example:"daily" description:"Aggregation granularity: daily, weekly, monthly"`
+	Metrics           AnalyticsMetrics          `json:"metrics" description:"Current period metrics"`
+	SamePeriod1Y      *YoYComparison            `json:"same_period_-1" description:"Same period last year; null if no data"`
+	SamePeriod2Y      *YoYComparison            `json:"same_period_-2" description:"Same period two years ago; null if no data"`
+	PeriodComparison  *PeriodComparisonSet      `json:"period_comparison" description:"Year-over-year percentage change analysis"`
+	PrevWindow        *PreviousWindow           `json:"prev_window,omitempty" description:"Preceding window of equal length; only with compare=prev_window"`
+	TimeSeries        TimeSeries                `json:"time_series" description:"Aggregated metrics by time bucket with pagination"`
+	SectorBreakdown   []SectorBreakdown         `json:"sector_breakdown" description:"Aggregated metrics by sector"`
+	SectorPagination  *PaginationMetadata       `json:"sector_pagination,omitempty" description:"Sector breakdown pagination; present only when sector_page or sector_limit is given"`
+	Forecast          *Forecast                 `json:"forecast" description:"Next-bucket projection; null unless forecast=true and enough buckets have data"`
+	DataQuality       *DataQuality              `json:"data_quality,omitempty" description:"Data-quality summary; only with include=quality"`
+	StackedTimeSeries []StackedTimeSeriesEntry  `json:"stacked_timeseries,omitempty" description:"Real and nominal sums per sector within each bucket of the period, for stacked charts; only with include=stacked_timeseries"`
+	ForecastNote      string                    `json:"forecast_note,omitempty" example:"forecast needs at least 4 buckets with data; got 2" description:"Why no forecast was produced"`
+	Warnings          []string                  `json:"warnings,omitempty" example:"unbounded limit requested; 365 buckets returned" description:"Non-fatal notices about the request, e.g. limit=all"`
+	Status            FarmStatus                `json:"status,omitempty" example:"healthy" description:"healthy when the weighted efficiency meets healthy_efficiency_threshold, needs_attention otherwise; omitted without a threshold or efficiency data"`
+	HealthyThreshold  *float64                  `json:"healthy_efficiency_threshold,omitempty" example:"0.85" description:"Threshold status was judged against: the farm's own, else ANALYTICS_HEALTHY_EFFICIENCY_THRESHOLD"`
+}
+
+// FarmStatus is the single red/green signal of an analytics response
+type FarmStatus string
+
+const (
+	// FarmStatusHealthy means the period's weighted efficiency met the farm's threshold
+	FarmStatusHealthy FarmStatus = "healthy"
+	// FarmStatusNeedsAttention means the period's weighted efficiency fell below the farm's threshold
+	FarmStatusNeedsAttention FarmStatus = "needs_attention"
+)
+
+// HeatmapRow represents one sector's efficiency values across all time buckets
+type HeatmapRow struct {
+	SectorID   uint       `json:"sector_id" example:"1" description:"Irrigation sector ID"`
+	SectorName string     `json:"sector_name" example:"North Field" description:"Irrigation sector name"`
+	Cells      []*float64 `json:"cells" description:"Efficiency per bucket, aligned with buckets; null when the sector had no valid data in that bucket"`
+	DeficitMM  []*float64 `json:"deficit_mm" description:"Sum of nominal minus sum of real amounts per bucket, aligned with buckets; negative for over-irrigation, null when the sector had no events in that bucket"`
+}
+
+// EfficiencyHeatmapResponse is the sector x time efficiency matrix for heatmap visualizations
+type EfficiencyHeatmapResponse struct {
+	FarmID      uint                      `json:"farm_id" example:"1" description:"Farm identifier"`
+	Period      IrrigationAnalyticsPeriod `json:"period" description:"Date range analyzed"`
+	Aggregation Aggregation               `json:"aggregation" example:"weekly" description:"Aggregation granularity: daily, weekly, monthly"`
+	Buckets     []string                  `json:"buckets" description:"Bucket start dates (YYYY-MM-DD) forming the matrix columns"`
+	Rows        []HeatmapRow              `json:"rows" description:"One row per sector with data in the period"`
+}
+
+// IrrigationAlert flags a sector that under-performed during the analyzed period
+type IrrigationAlert struct {
+	SectorID          uint     `json:"sector_id" example:"3" description:"Irrigation sector ID"`
+	SectorName        string   `json:"sector_name" example:"East Pasture" description:"Irrigation sector name"`
+	Type              string   `json:"type" example:"efficiency_below_target" description:"Alert type: efficiency_below_target or deficit_exceeded"`
+	Severity          string   `json:"severity" example:"critical" description:"Alert severity: warning or critical"`
+	AverageEfficiency *float64 `json:"average_efficiency" example:"0.75" description:"Sector average efficiency for the period; null if no valid data"`
+	TargetEfficiency  *float64 `json:"target_efficiency" example:"0.9" description:"Sector target efficiency; null if not configured"`
+	DeficitMM         float64  `json:"deficit_mm" example:"62.4" description:"Sum of nominal minus sum of real amounts for the period"`
+	Message           string   `json:"message" example:"average efficiency 0.75 below target 0.90" description:"Human-readable explanation"`
+}
+
+// IrrigationAlertsResponse lists the alerts raised for a farm over a period
+type IrrigationAlertsResponse struct {
+	FarmID uint                      `json:"farm_id" example:"1" description:"Farm identifier"`
+	Period IrrigationAnalyticsPeriod `json:"period" description:"Date range analyzed"`
+	Alerts []IrrigationAlert         `json:"alerts" description:"Triggered alerts ordered by sector; empty when all sectors are within thresholds"`
+}
+
+// SectorWaterSavings is one sector's share of a farm's potential water savings
+type SectorWaterSavings struct {
+	SectorID           uint     `json:"sector_id" example:"3" description:"Irrigation sector ID"`
+	SectorName         string   `json:"sector_name" example:"East Pasture" description:"Irrigation sector name"`
+	TotalRealAmount    float64  `json:"total_real_amount" example:"420.5" description:"Sum of real amounts for the period"`
+	AverageEfficiency  *float64 `json:"average_efficiency" example:"0.75" description:"Sector average efficiency for the period; null if no valid data"`
+	TargetEfficiency   *float64 `json:"target_efficiency" example:"0.9" description:"Sector target efficiency; null if not configured"`
+	PotentialSavingsMM *float64 `json:"potential_savings_mm" example:"70.1" description:"Volume that reaching the target efficiency would have saved; 0 when the sector already meets it, null when the sector is excluded"`
+	Note               string   `json:"note,omitempty" example:"no target efficiency configured" description:"Why the sector was excluded from the total"`
+}
+
+// WaterSavingsResponse estimates how much water a farm would have saved had every sector hit its target efficiency
+type WaterSavingsResponse struct {
+	FarmID             uint                      `json:"farm_id" example:"1" description:"Farm identifier"`
+	Period             IrrigationAnalyticsPeriod `json:"period" description:"Date range analyzed"`
+	PotentialSavingsMM float64                   `json:"potential_savings_mm" example:"182.4" description:"Sum of the included sectors' potential savings"`
+	ExcludedSectors    int                       `json:"excluded_sectors" example:"1" description:"Sectors left out of the total because they have no target or no valid efficiency data"`
+	Sectors            []SectorWaterSavings      `json:"sectors" description:"Per-sector estimates ordered by sector, including excluded sectors with a note"`
+}
+
+// TopIrrigationDay represents one of the farm's largest irrigation days
+type TopIrrigationDay struct {
+	Date            string  `json:"date" example:"2024-03-15" description:"Day (YYYY-MM-DD, UTC)"`
+	RealAmountMM    float64 `json:"real_amount_mm" example:"84.2" description:"Sum of real amounts for the day"`
+	NominalAmountMM float64 `json:"nominal_amount_mm" example:"90" description:"Sum of nominal amounts for the day"`
+	EventCount      int     `json:"event_count" example:"6" description:"Number of irrigation events on the day"`
+}
+
+// TopIrrigationDaysResponse lists the days with the highest real irrigation volume
+type TopIrrigationDaysResponse struct {
+	FarmID uint                      `json:"farm_id" example:"1" description:"Farm identifier"`
+	Period IrrigationAnalyticsPeriod `json:"period" description:"Date range analyzed"`
+	Days   []TopIrrigationDay        `json:"days" description:"Days ordered by real_amount_mm descending"`
+}
+
+// FarmSectorBreakdown is one sector's aggregated metrics in a multi-farm breakdown
+type FarmSectorBreakdown struct {
+	FarmID            uint     `json:"farm_id" example:"1" description:"Farm the sector belongs to"`
+	FarmName          string   `json:"farm_name" example:"Green Valley" description:"Farm name"`
+	SectorID          uint     `json:"sector_id" example:"1" description:"Irrigation sector ID"`
+	SectorName        string   `json:"sector_name" example:"North Field" description:"Irrigation sector name"`
+	TotalVolumeMM     float64  `json:"total_volume_mm" example:"150.2" description:"Sum of real_amount values"`
+	NominalVolumeMM   float64  `json:"nominal_volume_mm" example:"163.5" description:"Sum of nominal_amount values"`
+	AverageEfficiency *float64 `json:"average_efficiency" example:"0.88" description:"Average efficiency for the sector; null if no valid data"`
+	SampleSize        int      `json:"sample_size" example:"42" description:"Number of irrigation events behind the sector metrics"`
+	Confidence        string   `json:"confidence" example:"medium" description:"Trust in the sector metrics given its sample size: low, medium, or high"`
+}
+
+// MultiFarmSectorBreakdownResponse rolls sector metrics up across several farms
+type MultiFarmSectorBreakdownResponse struct {
+	FarmIDs []uint                    `json:"farm_ids" example:"1,2" description:"Requested farms, ascending"`
+	Period  IrrigationAnalyticsPeriod `json:"period" description:"Date range analyzed"`
+	Sectors []FarmSectorBreakdown     `json:"sectors" description:"Sectors with events in the range, ordered by farm ID then sector ID"`
+}
+
+// SectorRankingEntry is one sector's position in the farm's efficiency leaderboard
+type SectorRankingEntry struct {
+	Rank              int      `json:"rank" example:"1" description:"1-based position across all pages"`
+	SectorID          uint     `json:"sector_id" example:"3" description:"Irrigation sector identifier"`
+	SectorName        string   `json:"sector_name" example:"North Field" description:"Irrigation sector name"`
+	AverageEfficiency *float64 `json:"average_efficiency" example:"0.92" description:"Average efficiency (real / nominal); null ranks last"`
+	RealAmountMM      float64  `json:"real_amount_mm" example:"150.2" description:"Sum of real amounts; breaks efficiency ties, largest first"`
+	NominalAmountMM   float64  `json:"nominal_amount_mm" example:"163.5" description:"Sum of nominal amounts"`
+	EventCount        int      `json:"event_count" example:"42" description:"Irrigation events behind the metrics"`
+}
+
+// SectorRankingResponse lists a farm's sectors ordered by average efficiency, then volume, then sector ID
+type SectorRankingResponse struct {
+	FarmID     uint                      `json:"farm_id" example:"1" description:"Farm identifier"`
+	Period     IrrigationAnalyticsPeriod `json:"period" description:"Date range analyzed"`
+	Sectors    []SectorRankingEntry      `json:"sectors" description:"Sectors in rank order"`
+	Pagination PaginationMetadata        `json:"pagination" description:"Pagination metadata"`
+}
+
+// FarmAggregate holds one farm's irrigation totals for a period
+type FarmAggregate struct {
+	FarmID           uint    `json:"farm_id" example:"1" description:"Farm identifier"`
+	FarmName         string  `json:"farm_name" example:"Green Valley" description:"Farm name"`
+	EventCount       int64   `json:"event_count" example:"240" description:"Irrigation events in the period"`
+	NominalAmountMM  float64 `json:"nominal_amount_mm" example:"5400" description:"Sum of nominal amounts"`
+	RealAmountMM     float64 `json:"real_amount_mm" example:"4860" description:"Sum of real amounts"`
+	AvgNominalAmount float64 `json:"avg_nominal_amount_mm" example:"22.5" description:"Average nominal amount per event"`
+	AvgRealAmount    float64 `json:"avg_real_amount_mm" example:"20.25" description:"Average real amount per event"`
+}
+
+// FarmAggregatesResponse lists the totals of every farm with events in the period
+type FarmAggregatesResponse struct {
+	Period IrrigationAnalyticsPeriod `json:"period" description:"Date range analyzed"`
+	Farms  []FarmAggregate           `json:"farms" description:"Farms ordered by ID"`
+}
+
+// SectorAggregate holds one sector's irrigation totals for a period
+type SectorAggregate struct {
+	FarmID           uint    `json:"farm_id" example:"1" description:"Farm identifier"`
+	FarmName         string  `json:"farm_name" example:"Green Valley" description:"Farm name"`
+	SectorID         uint    `json:"sector_id" example:"2" description:"Irrigation sector ID"`
+	SectorName       string  `json:"sector_name" example:"South Orchard" description:"Irrigation sector name"`
+	EventCount       int64   `json:"event_count" example:"60" description:"Irrigation events in the period"`
+	NominalAmountMM  float64 `json:"nominal_amount_mm" example:"1350" description:"Sum of nominal amounts"`
+	RealAmountMM     float64 `json:"real_amount_mm" example:"1215" description:"Sum of real amounts"`
+	AvgNominalAmount float64 `json:"avg_nominal_amount_mm" example:"22.5" description:"Average nominal amount per event"`
+	AvgRealAmount    float64 `json:"avg_real_amount_mm" example:"20.25" description:"Average real amount per event"`
+}
+
+// SectorAggregatesResponse lists the totals of every sector with events in the period
+type SectorAggregatesResponse struct {
+	Period  IrrigationAnalyticsPeriod `json:"period" description:"Date range analyzed"`
+	Sectors []SectorAggregate         `json:"sectors" description:"Sectors ordered by farm ID, then sector ID"`
+}
+
+// SectorAggregateResponse holds a single sector's totals for a period
+type SectorAggregateResponse struct {
+	Period IrrigationAnalyticsPeriod `json:"period" description:"Date range analyzed"`
+	Sector SectorAggregate           `json:"sector" description:"The sector's totals; zero when it has no events in the period"`
+}
+
+// DayOfWeekTotal holds a farm's irrigation totals for one weekday
+type DayOfWeekTotal struct {
+	DayOfWeek       int     `json:"day_of_week" example:"1" description:"Weekday number, 0 = Sunday through 6 = Saturday"`
+	Day             string  `json:"day" example:"Monday" description:"Weekday name"`
+	RealAmountMM    float64 `json:"real_amount_mm" example:"412.5" description:"Sum of real amounts for events starting on this weekday"`
+	NominalAmountMM float64 `json:"nominal_amount_mm" example:"450" description:"Sum of nominal amounts for events starting on this weekday"`
+	EventCount      int     `json:"event_count" example:"32" description:"Number of irrigation events starting on this weekday"`
+}
+
+// DayOfWeekDistributionResponse shows how a farm's irrigation is spread over the week
+type DayOfWeekDistributionResponse struct {
+	FarmID uint                      `json:"farm_id" example:"1" description:"Farm identifier"`
+	Period IrrigationAnalyticsPeriod `json:"period" description:"Date range analyzed"`
+	Days   []DayOfWeekTotal          `json:"days" description:"All seven weekdays from Sunday, zero-filled (UTC)"`
+}
+
+// EfficiencyAmountBin is one real-amount bin of the efficiency-vs-amount distribution
+type EfficiencyAmountBin struct {
+	Bin               int      `json:"bin" example:"1" description:"Bin number, 1 for the smallest amounts"`
+	MinRealAmountMM   float64  `json:"min_real_amount_mm" example:"10" description:"Lower edge of the bin (inclusive)"`
+	MaxRealAmountMM   float64  `json:"max_real_amount_mm" example:"28" description:"Upper edge of the bin (exclusive, except for the last bin)"`
+	EventCount        int      `json:"event_count" example:"12" description:"Irrigation events whose real amount falls in the bin"`
+	AvgRealAmountMM   float64  `json:"avg_real_amount_mm" example:"17.4" description:"Average real amount of the bin's events; 0 for an empty bin"`
+	AverageEfficiency *float64 `json:"average_efficiency" example:"0.87" description:"Average per-event efficiency of the bin's events; null for an empty bin or without valid data"`
+}
+
+// EfficiencyByAmountResponse shows how efficiency varies with watering size, for scatter and trend charts
+type EfficiencyByAmountResponse struct {
+	FarmID uint                      `json:"farm_id" example:"1" description:"Farm identifier"`
+	Period IrrigationAnalyticsPeriod `json:"period" description:"Date range analyzed"`
+	Bins   []EfficiencyAmountBin     `json:"bins" description:"Equal-width bins from the smallest to the largest real amount in the period; empty without events, a single bin when every event has the same amount"`
+}
+
+// SectorScheduleAdherence compares a sector's actual watering rhythm with its expected frequency
+type SectorScheduleAdherence struct {
+	SectorID              uint    `json:"sector_id" example:"2" description:"Irrigation sector ID"`
+	SectorName            string  `json:"sector_name" example:"South Orchard" description:"Irrigation sector name"`
+	ExpectedFrequencyDays int     `json:"expected_frequency_days" example:"3" description:"Expected days between waterings"`
+	EventCount            int     `json:"event_count" example:"12" description:"Irrigation events in the period"`
+	LongestGapDays        float64 `json:"longest_gap_days" example:"6.5" description:"Longest stretch without an event, counting from the period start to the first event and from the last event to the period end"`
+	MissedSchedule        bool    `json:"missed_schedule" example:"true" description:"True when longest_gap_days exceeds expected_frequency_days"`
+}
+
+// ScheduleAdherenceResponse lists every sector with an expected frequency and whether it kept to it
+type ScheduleAdherenceResponse struct {
+	FarmID      uint                      `json:"farm_id" example:"1" description:"Farm identifier"`
+	Period      IrrigationAnalyticsPeriod `json:"period" description:"Date range analyzed; the end is capped at the current time"`
+	MissedCount int                       `json:"missed_count" example:"1" description:"Number of sectors that missed their schedule"`
+	Sectors     []SectorScheduleAdherence `json:"sectors" description:"Sectors with an expected frequency, ordered by sector ID"`
+}
+
+// AggregationOption is one aggregation and the number of buckets it would produce for a range
+type AggregationOption struct {
+	Aggregation Aggregation `json:"aggregation" example:"weekly" description:"Aggregation granularity"`
+	BucketCount int         `json:"bucket_count" example:"27" description:"Time-series buckets the range spans at this granularity"`
+}
+
+// AggregationRecommendationResponse suggests an aggregation for charting a date range
+type AggregationRecommendationResponse struct {
+	FarmID      uint                      `json:"farm_id" example:"1" description:"Farm identifier"`
+	Period      IrrigationAnalyticsPeriod `json:"period" description:"Date range the recommendation is for"`
+	Recommended Aggregation               `json:"recommended" example:"weekly" description:"Finest aggregation that stays within max_buckets"`
+	MaxBuckets  int                       `json:"max_buckets" example:"120" description:"Bucket count the recommendation stays within"`
+	Options     []AggregationOption       `json:"options" description:"Every aggregation with its bucket count, finest first"`
 }
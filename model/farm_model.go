@@ -4,37 +4,70 @@ import "time"
 
 // Farm represents an agricultural farm entity
 type Farm struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	Name      string    `gorm:"not null" json:"name"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID                         uint      `gorm:"primaryKey" json:"id"`
+	Name                       string    `gorm:"not null" json:"name"`
+	HealthyEfficiencyThreshold *float64  `gorm:"type:numeric(4,3)" json:"healthy_efficiency_threshold,omitempty"` // minimum weighted efficiency for "healthy" analytics status; nil uses the configured default
+	CreatedAt                  time.Time `json:"created_at"`
+	UpdatedAt                  time.Time `json:"updated_at"`
+}
+
+// HealthyThresholdInput sets or clears a farm's healthy efficiency threshold
+type HealthyThresholdInput struct {
+	HealthyEfficiencyThreshold *float64 `json:"healthy_efficiency_threshold" example:"0.85" description:"Minimum weighted efficiency for analytics status healthy, from 0 to 9.999; null clears it so ANALYTICS_HEALTHY_EFFICIENCY_THRESHOLD applies"`
 }
 
 // IrrigationSector represents a subdivision of a farm with irrigation capabilities
 type IrrigationSector struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	FarmID    uint      `gorm:"not null;index:idx_sector_farm" json:"farm_id"`
-	Name      string    `gorm:"not null" json:"name"`
-	Farm      Farm      `gorm:"foreignKey:FarmID;constraint:OnDelete:CASCADE" json:"farm,omitempty"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID                    uint              `gorm:"primaryKey" json:"id"`
+	FarmID                uint              `gorm:"not null;index:idx_sector_farm" json:"farm_id"`
+	Name                  string            `gorm:"not null" json:"name"`
+	TargetEfficiency      *float64          `gorm:"type:numeric(4,3)" json:"target_efficiency,omitempty"`      // expected real/nominal ratio; nil when unset
+	ExpectedFrequencyDays *int              `json:"expected_frequency_days,omitempty"`                         // expected days between waterings; nil when unset
+	ParentSectorID        *uint             `gorm:"index:idx_sector_parent" json:"parent_sector_id,omitempty"` // zone grouping this sector; nil for top-level sectors
+	Farm                  Farm              `gorm:"foreignKey:FarmID;constraint:OnDelete:CASCADE" json:"farm,omitzero"`
+	ParentSector          *IrrigationSector `gorm:"foreignKey:ParentSectorID;constraint:OnDelete:SET NULL" json:"-"`
+	CreatedAt             time.Time         `json:"created_at"`
+	UpdatedAt             time.Time         `json:"updated_at"`
 }
 
 // IrrigationData represents irrigation event data with time-series metrics
 // Optimized with composite indexes for common query patterns:
-// - Time-range queries by farm
+// - Time-range queries by farm (on PostgreSQL the index also INCLUDEs both amounts; see internal/database)
 // - Time-range queries by sector
 // - General time-based analytics
 type IrrigationData struct {
-	ID                 uint             `gorm:"primaryKey" json:"id"`
+	ID                 uint             `gorm:"primaryKey;index:idx_irrigation_updated,priority:2" json:"id"`
 	FarmID             uint             `gorm:"not null;index:idx_irrigation_farm_time,priority:1;index:idx_irrigation_farm" json:"farm_id"`
 	IrrigationSectorID uint             `gorm:"not null;index:idx_irrigation_sector_time,priority:1;index:idx_irrigation_sector" json:"irrigation_sector_id"`
 	StartTime          time.Time        `gorm:"not null;index:idx_irrigation_farm_time,priority:2;index:idx_irrigation_sector_time,priority:2;index:idx_irrigation_time" json:"start_time"`
 	EndTime            time.Time        `gorm:"not null" json:"end_time"`
-	NominalAmount      float32          `gorm:"type:numeric(10,2)" json:"nominal_amount"` // in mm
-	RealAmount         float32          `gorm:"type:numeric(10,2)" json:"real_amount"`    // in mm
+	NominalAmount      float64          `gorm:"type:numeric(10,2)" json:"nominal_amount"` // in mm
+	RealAmount         float64          `gorm:"type:numeric(10,2)" json:"real_amount"`    // in mm
 	CreatedAt          time.Time        `json:"created_at"`
-	UpdatedAt          time.Time        `json:"updated_at"`
-	Farm               Farm             `gorm:"foreignKey:FarmID;constraint:OnDelete:CASCADE" json:"farm,omitempty"`
-	IrrigationSector   IrrigationSector `gorm:"foreignKey:IrrigationSectorID;constraint:OnDelete:CASCADE" json:"irrigation_sector,omitempty"`
+	UpdatedAt          time.Time        `gorm:"index:idx_irrigation_updated,priority:1" json:"updated_at"`
+	Farm               Farm             `gorm:"foreignKey:FarmID;constraint:OnDelete:CASCADE" json:"farm,omitzero"`
+	IrrigationSector   IrrigationSector `gorm:"foreignKey:IrrigationSectorID;constraint:OnDelete:CASCADE" json:"irrigation_sector,omitzero"`
+}
+
+// IrrigationDailySummary holds one sector's irrigation totals for a single UTC day
+// Raw events past the retention window are rolled up here before deletion so long-term aggregates survive
+// EfficiencySum adds real/nominal over the EfficiencyEventCount events with a positive nominal amount,
+// so average efficiency can be rebuilt under either zero-nominal policy
+// Rebuilt marks a summary recomputed from raw events that are still stored, so archiving its day replaces it
+// instead of adding to it
+type IrrigationDailySummary struct {
+	ID                   uint             `gorm:"primaryKey" json:"id"`
+	FarmID               uint             `gorm:"not null;index:idx_daily_summary_farm_day,priority:1" json:"farm_id"`
+	IrrigationSectorID   uint             `gorm:"not null;uniqueIndex:idx_daily_summary_sector_day,priority:1" json:"irrigation_sector_id"`
+	Day                  time.Time        `gorm:"not null;uniqueIndex:idx_daily_summary_sector_day,priority:2;index:idx_daily_summary_farm_day,priority:2" json:"day"`
+	TotalNominalAmount   float64          `gorm:"type:numeric(14,2)" json:"total_nominal_amount"` // in mm
+	TotalRealAmount      float64          `gorm:"type:numeric(14,2)" json:"total_real_amount"`    // in mm
+	EventCount           int              `gorm:"not null" json:"event_count"`
+	EfficiencySum        float64          `json:"efficiency_sum"`
+	EfficiencyEventCount int              `gorm:"not null" json:"efficiency_event_count"`
+	Rebuilt              bool             `gorm:"not null;default:false" json:"rebuilt"`
+	CreatedAt            time.Time        `json:"created_at"`
+	UpdatedAt            time.Time        `json:"updated_at"`
+	Farm                 Farm             `gorm:"foreignKey:FarmID;constraint:OnDelete:CASCADE" json:"farm,omitzero"`
+	IrrigationSector     IrrigationSector `gorm:"foreignKey:IrrigationSectorID;constraint:OnDelete:CASCADE" json:"irrigation_sector,omitzero"`
 }
@@ -6,3 +6,26 @@ type HealthResponse struct {
 	Message string `json:"message"`
 	Version string `json:"version"`
 }
+
+// HealthComponent reports the state of one dependency
+type HealthComponent struct {
+	Name     string `json:"name" example:"cache" description:"Dependency name"`
+	Status   string `json:"status" example:"degraded" description:"healthy, degraded (optional dependency down) or unhealthy (required dependency down)"`
+	Message  string `json:"message" example:"cache unreachable; analytics computed directly"`
+	Required bool   `json:"required" example:"false" description:"Whether the service cannot work without it"`
+}
+
+// HealthComponentsResponse lists the state of each dependency
+type HealthComponentsResponse struct {
+	Status     string            `json:"status" example:"degraded" description:"Worst component status"`
+	Version    string            `json:"version" example:"1.0.0"`
+	Components []HealthComponent `json:"components"`
+}
+
+// VersionResponse represents the build metadata of the running service
+type VersionResponse struct {
+	Service   string `json:"service" example:"irrigation-api"`
+	Version   string `json:"version" example:"0.0.1"`
+	GitCommit string `json:"git_commit" example:"2c8a56e"`
+	BuildTime string `json:"build_time" example:"2024-03-01T12:00:00Z"`
+}
@@ -0,0 +1,143 @@
+package model
+
+import (
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EventExpansion names an association of raw irrigation events that can be embedded with ?expand=
+type EventExpansion string
+
+const (
+	// EventExpandFarm embeds each event's farm
+	EventExpandFarm EventExpansion = "farm"
+	// EventExpandSector embeds each event's irrigation sector
+	EventExpandSector EventExpansion = "sector"
+)
+
+// EventExpansions is the set of associations to embed in raw event responses
+type EventExpansions []EventExpansion
+
+// ParseEventExpansions converts a comma-separated expand value into EventExpansions
+// An empty value expands nothing; unknown names are an error and duplicates are dropped
+func ParseEventExpansions(value string) (EventExpansions, error) {
+	var expansions EventExpansions
+	for _, item := range strings.Split(value, ",") {
+		expansion := EventExpansion(strings.TrimSpace(item))
+		if expansion == "" {
+			continue
+		}
+		if expansion != EventExpandFarm && expansion != EventExpandSector {
+			return nil, fmt.Errorf("invalid expand %q; must be farm or sector", expansion)
+		}
+		if !expansions.Has(expansion) {
+			expansions = append(expansions, expansion)
+		}
+	}
+	return expansions, nil
+}
+
+// Has reports whether expansion is in the set
+func (es EventExpansions) Has(expansion EventExpansion) bool {
+	return slices.Contains(es, expansion)
+}
+
+// IrrigationEventsResponse wraps a page of raw irrigation events
+type IrrigationEventsResponse struct {
+	Period     IrrigationAnalyticsPeriod `json:"period" description:"Date range queried"`
+	Data       []IrrigationData          `json:"data" description:"Irrigation events ordered by start_time"`
+	Pagination PaginationMetadata        `json:"pagination" description:"Pagination metadata"`
+}
+
+// ChangeCursor is a position in the feed of modified irrigation events, ordered by (updated_at, id)
+// A cursor with ID 0 is a bare timestamp: the feed resumes with events updated strictly after it
+type ChangeCursor struct {
+	UpdatedAt time.Time
+	ID        uint
+}
+
+// String encodes the cursor as an opaque token for the next_cursor field
+func (c ChangeCursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d", c.UpdatedAt.UnixNano(), c.ID)))
+}
+
+// ParseChangeCursor decodes a token produced by ChangeCursor.String
+func ParseChangeCursor(token string) (ChangeCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return ChangeCursor{}, fmt.Errorf("invalid cursor")
+	}
+	nanos, id, found := strings.Cut(string(raw), ":")
+	if !found {
+		return ChangeCursor{}, fmt.Errorf("invalid cursor")
+	}
+	unixNano, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return ChangeCursor{}, fmt.Errorf("invalid cursor")
+	}
+	parsedID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return ChangeCursor{}, fmt.Errorf("invalid cursor")
+	}
+	return ChangeCursor{UpdatedAt: time.Unix(0, unixNano).UTC(), ID: uint(parsedID)}, nil
+}
+
+// IrrigationChangesResponse is one page of the feed of created or updated irrigation events
+type IrrigationChangesResponse struct {
+	Data       []IrrigationData `json:"data" description:"Events created or updated after the requested position, ordered by updated_at then id"`
+	NextCursor string           `json:"next_cursor" example:"MTcwOTI3MzYwMDAwMDAwMDAwMDo0Mg" description:"Pass as cursor to continue after the last event; echoes the request's position when no event changed"`
+	HasMore    bool             `json:"has_more" example:"false" description:"True when more changed events follow this page"`
+}
+
+// IrrigationSectorsResponse lists a farm's irrigation sectors, optionally filtered by name
+type IrrigationSectorsResponse struct {
+	FarmID uint               `json:"farm_id" example:"1" description:"Farm identifier"`
+	Query  string             `json:"query,omitempty" example:"north" description:"Case-insensitive name filter applied, if any"`
+	Data   []IrrigationSector `json:"data" description:"Matching sectors"`
+}
+
+// InactiveSectorsResponse lists a farm's irrigation sectors without events in a period
+type InactiveSectorsResponse struct {
+	FarmID uint                      `json:"farm_id" example:"1" description:"Farm identifier"`
+	Period IrrigationAnalyticsPeriod `json:"period" description:"Date range checked"`
+	Data   []IrrigationSector        `json:"data" description:"Sectors with no event starting in the period, ordered by ID"`
+}
+
+// IrrigationEventInput is a single irrigation event submitted for creation
+// The farm is taken from the request path
+type IrrigationEventInput struct {
+	IrrigationSectorID uint      `json:"irrigation_sector_id" example:"1" description:"Irrigation sector ID"`
+	StartTime          time.Time `json:"start_time" example:"2024-03-01T06:00:00Z" description:"Event start (RFC 3339)"`
+	EndTime            time.Time `json:"end_time" example:"2024-03-01T07:00:00Z" description:"Event end (RFC 3339); must be after start_time"`
+	NominalAmount      float64   `json:"nominal_amount" example:"20" description:"Planned amount in mm; must be >= 0"`
+	RealAmount         float64   `json:"real_amount" example:"18" description:"Delivered amount in mm; must be >= 0"`
+}
+
+// ValidationViolation describes why a single record in a batch was rejected
+type ValidationViolation struct {
+	Index  int    `json:"index" example:"2" description:"Zero-based position of the record in the batch"`
+	Field  string `json:"field" example:"end_time" description:"Offending field"`
+	Reason string `json:"reason" example:"must be after start_time" description:"Why the value was rejected"`
+}
+
+// APIError is the body of every error response
+type APIError struct {
+	Error         string `json:"error" example:"invalid farm_id" description:"What went wrong"`
+	CorrelationID string `json:"correlation_id,omitempty" example:"5f0c1a8e-3b9d-4c2e-9a51-7d2f6b8e4c10" description:"The request's X-Request-ID (or the REQUEST_ID_HEADER header); quote it to support so the error can be found in the logs"`
+}
+
+// ValidationErrorResponse is returned with 422 when one or more batch records are invalid
+type ValidationErrorResponse struct {
+	Error         string                `json:"error" example:"batch contains invalid records" description:"Summary message"`
+	Details       []ValidationViolation `json:"details" description:"One entry per rejected field"`
+	CorrelationID string                `json:"correlation_id,omitempty" example:"5f0c1a8e-3b9d-4c2e-9a51-7d2f6b8e4c10" description:"The request's X-Request-ID (or the REQUEST_ID_HEADER header)"`
+}
+
+// BatchCreateResponse reports the outcome of a successful batch insert
+type BatchCreateResponse struct {
+	Created int `json:"created" example:"120" description:"Number of events inserted"`
+}
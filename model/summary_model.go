@@ -0,0 +1,12 @@
+package model
+
+// SummaryRebuildResponse reports the progress of a daily summary rebuild
+type SummaryRebuildResponse struct {
+	FarmID    uint   `json:"farm_id" example:"1" description:"Farm whose summaries were rebuilt"`
+	StartDate string `json:"start_date" example:"2024-01-01" description:"First UTC day rebuilt (YYYY-MM-DD)"`
+	EndDate   string `json:"end_date" example:"2024-03-31" description:"Last UTC day rebuilt (YYYY-MM-DD)"`
+	Days      int    `json:"days" example:"91" description:"Days recomputed"`
+	Chunks    int    `json:"chunks" example:"13" description:"Transactions committed, one per chunk of days"`
+	Summaries int    `json:"summaries" example:"360" description:"Per-sector daily summaries written"`
+	Events    int64  `json:"events" example:"2150" description:"Raw irrigation events summarized"`
+}
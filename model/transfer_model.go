@@ -0,0 +1,8 @@
+package model
+
+// ImportResponse reports how many records of each kind an import wrote
+type ImportResponse struct {
+	Farms             int `json:"farms" example:"1" description:"Farms inserted or updated"`
+	IrrigationSectors int `json:"irrigation_sectors" example:"4" description:"Irrigation sectors inserted or updated"`
+	IrrigationData    int `json:"irrigation_data" example:"840" description:"Irrigation events inserted or updated"`
+}
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// RealAmountRange bounds the real_amount of the events analytics queries include; a nil bound is open
+type RealAmountRange struct {
+	Min *float64
+	Max *float64
+}
+
+type realAmountRangeKey struct{}
+
+// WithRealAmountRange returns a context whose analytics queries only include events with real_amount inside rng
+// It travels with the request so every query of it applies the same filter, including comparison windows
+func WithRealAmountRange(ctx context.Context, rng RealAmountRange) context.Context {
+	return context.WithValue(ctx, realAmountRangeKey{}, rng)
+}
+
+// realAmountCondition returns the SQL condition and arguments for the context's real amount range,
+// or an empty condition without one; table prefixes the column (e.g. "irrigation_data.")
+func realAmountCondition(ctx context.Context, table string) (string, []any) {
+	rng, _ := ctx.Value(realAmountRangeKey{}).(RealAmountRange)
+
+	var conditions []string
+	var args []any
+	if rng.Min != nil {
+		conditions = append(conditions, table+"real_amount >= ?")
+		args = append(args, *rng.Min)
+	}
+	if rng.Max != nil {
+		conditions = append(conditions, table+"real_amount <= ?")
+		args = append(args, *rng.Max)
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// whereRealAmount narrows query to the context's real amount range
+func whereRealAmount(ctx context.Context, query *gorm.DB, table string) *gorm.DB {
+	if condition, args := realAmountCondition(ctx, table); condition != "" {
+		return query.Where(condition, args...)
+	}
+	return query
+}
+
+// rawRealAmountCondition returns the context's real amount range as an " AND ..." suffix for raw SQL
+// WHERE clauses, with its arguments
+func rawRealAmountCondition(ctx context.Context, table string) (string, []any) {
+	condition, args := realAmountCondition(ctx, table)
+	if condition == "" {
+		return "", nil
+	}
+	return " AND " + condition, args
+}
@@ -0,0 +1,1012 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/sebaespinosa/test_NF/model"
+	"golang.org/x/sync/errgroup"
+	"gorm.io/gorm"
+)
+
+// AnalyticsRepository handles read-only analytics queries over irrigation data
+// It is kept apart from IrrigationDataRepository so reads can use a different *gorm.DB (e.g. a replica) than writes
+// Query bounds are converted to UTC before reaching SQL, so buckets follow UTC days
+// Aggregations honor a real amount range carried by the context; see WithRealAmountRange
+// Queries read from the replica, when db is the analytics handle of one (see database.OpenAnalytics), unless the
+// context asks for strong consistency; see WithConsistency
+type AnalyticsRepository struct {
+	db                *gorm.DB
+	dialect           Dialect
+	zeroNominalPolicy model.ZeroNominalPolicy
+	parallelYoY       bool
+	maxBuckets        int
+	exactSums         bool
+	sqlLogger         *sqlLogger
+}
+
+// NewAnalyticsRepository creates a new AnalyticsRepository instance
+// Events without a positive nominal amount are excluded from efficiency; see WithZeroNominalPolicy
+// Amounts are summed exactly; see WithExactSums
+func NewAnalyticsRepository(db *gorm.DB) *AnalyticsRepository {
+	return &AnalyticsRepository{db: db, dialect: dialectFor(db), zeroNominalPolicy: model.ZeroNominalExclude, exactSums: true}
+}
+
+// WithZeroNominalPolicy returns a copy of the repository applying policy to events whose nominal amount is not positive
+func (r *AnalyticsRepository) WithZeroNominalPolicy(policy model.ZeroNominalPolicy) *AnalyticsRepository {
+	clone := *r
+	clone.zeroNominalPolicy = policy
+	return &clone
+}
+
+// WithParallelYoY returns a copy of the repository that runs GetYoYComparison as one query per year,
+// concurrently, instead of a single UNION ALL; the results are identical
+func (r *AnalyticsRepository) WithParallelYoY(parallel bool) *AnalyticsRepository {
+	clone := *r
+	clone.parallelYoY = parallel
+	return &clone
+}
+
+// WithMaxBuckets returns a copy of the repository that never returns time-series buckets past
+// position max of a range, whatever the page; 0 disables the cap
+func (r *AnalyticsRepository) WithMaxBuckets(max int) *AnalyticsRepository {
+	clone := *r
+	clone.maxBuckets = max
+	return &clone
+}
+
+// WithExactSums returns a copy of the repository that, when exact is false, sums amounts with a plain SUM
+// instead of rounding the numeric sum to the columns' two decimals; see Dialect.ExactSum
+func (r *AnalyticsRepository) WithExactSums(exact bool) *AnalyticsRepository {
+	clone := *r
+	clone.exactSums = exact
+	return &clone
+}
+
+// efficiencyAggExpr applies an aggregate to per-event efficiency under the repository's zero-nominal policy
+func (r *AnalyticsRepository) efficiencyAggExpr(fn, table string) string {
+	return efficiencyAggExpr(r.dialect, r.zeroNominalPolicy, fn, table)
+}
+
+// sumExpr sums an amount column, exactly unless disabled with WithExactSums
+func (r *AnalyticsRepository) sumExpr(column string) string {
+	return sumAmountExpr(r.dialect, r.exactSums, column)
+}
+
+// AnalyticsAggregation represents aggregated analytics data for a time period
+// Period is the bucket start formatted as YYYY-MM-DD
+type AnalyticsAggregation struct {
+	Period             string   `gorm:"column:period"`
+	Year               int      `gorm:"column:year"`
+	TotalRealAmount    float64  `gorm:"column:total_real_amount"`
+	TotalNominalAmount float64  `gorm:"column:total_nominal_amount"`
+	EventCount         int      `gorm:"column:event_count"`
+	AvgEfficiency      *float64 `gorm:"column:avg_efficiency"`
+	MinEfficiency      *float64 `gorm:"column:min_efficiency"`
+	MaxEfficiency      *float64 `gorm:"column:max_efficiency"`
+	// EfficiencyCount is the number of events with a valid efficiency; StdDevEfficiency is their
+	// population standard deviation, nil when fewer than two
+	EfficiencyCount  int      `gorm:"column:efficiency_count"`
+	StdDevEfficiency *float64 `gorm:"column:stddev_efficiency"`
+}
+
+// GetAnalyticsForFarmByDateRange retrieves aggregated analytics for a farm within a time range
+// Uses SQL GROUP BY with DATE_TRUNC for efficient aggregation at database level
+// Leverages composite index (farm_id, start_time) for optimal performance
+// The total count is the number of buckets in the range, archived ones included, not of events
+// Buckets past the WithMaxBuckets cap are never returned; truncated reports that the cap dropped
+// buckets the page would otherwise have included, and the bucket total is capped the same way so
+// pagination does not advertise pages past it
+// Days archived by retention are included from their daily summaries; see archived_summaries.go
+func (r *AnalyticsRepository) GetAnalyticsForFarmByDateRange(
+	ctx context.Context,
+	farmID uint,
+	startTime, endTime time.Time,
+	aggregation model.Aggregation,
+	limit, offset int,
+) ([]AnalyticsAggregation, int64, bool, error) {
+	startTime, endTime = startTime.UTC(), endTime.UTC()
+
+	var results []AnalyticsAggregation
+	var totalCount int64
+
+	periodExpr := r.dialect.TruncExpr(aggregation, "start_time")
+
+	baseQuery := func() *gorm.DB {
+		query := r.conn(ctx).
+			Table("irrigation_data").
+			Where("farm_id = ? AND start_time >= ? AND start_time <= ?", farmID, startTime, endTime)
+		return whereRealAmount(ctx, query, "")
+	}
+
+	archived, err := r.getArchivedBuckets(ctx, farmID, startTime, endTime, aggregation)
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	// Count the buckets for pagination, grouped like the page query below; with archived buckets every
+	// bucket is fetched and merged, so they are counted after the merge instead
+	if len(archived) == 0 {
+		buckets := baseQuery().
+			Select(periodExpr + " as period, " + r.dialect.ExtractYear("start_time") + " as year").
+			Group(periodExpr + ", year")
+		if err := r.conn(ctx).Table("(?) as buckets", buckets).Count(&totalCount).Error; err != nil {
+			return nil, 0, false, fmt.Errorf("failed to count analytics buckets: %w", err)
+		}
+	}
+
+	// Clip the page to the bucket cap, fetching one bucket more to learn whether the cap cut anything
+	fetchLimit, capped := limit, false
+	if r.maxBuckets > 0 && offset+limit > r.maxBuckets {
+		capped = true
+		fetchLimit = max(r.maxBuckets-offset, 0) + 1
+		offset = min(offset, r.maxBuckets)
+	}
+
+	stdDevExpr := efficiencyStdDevExpr(r.dialect, r.zeroNominalPolicy, "")
+
+	// Fetch aggregated data grouped by period bucket; archived buckets are merged in Go, so with any
+	// the page is cut from every bucket instead of in SQL
+	query := baseQuery().
+		Select(`
+			` + periodExpr + ` as period,
+			` + r.dialect.ExtractYear("start_time") + ` as year,
+			` + r.sumExpr("real_amount") + ` as total_real_amount,
+			` + r.sumExpr("nominal_amount") + ` as total_nominal_amount,
+			COUNT(*) as event_count,
+			` + r.efficiencyAggExpr("AVG", "") + ` as avg_efficiency,
+			` + r.efficiencyAggExpr("MIN", "") + ` as min_efficiency,
+			` + r.efficiencyAggExpr("MAX", "") + ` as max_efficiency,
+			` + r.efficiencyCountExpr() + ` as efficiency_count,
+			` + cmp.Or(stdDevExpr, "NULL") + ` as stddev_efficiency
+		`).
+		Group(periodExpr + ", year").
+		Order("period ASC")
+	if len(archived) == 0 {
+		query = query.Limit(fetchLimit).Offset(offset)
+	}
+	if err := query.Scan(&results).Error; err != nil {
+		return nil, 0, false, fmt.Errorf("failed to get analytics for farm: %w", err)
+	}
+
+	if stdDevExpr == "" && len(results) > 0 {
+		if err := r.fillEfficiencyStdDev(baseQuery(), periodExpr, results); err != nil {
+			return nil, 0, false, err
+		}
+	}
+	for i := range results {
+		if results[i].EfficiencyCount < 2 {
+			results[i].StdDevEfficiency = nil
+		}
+	}
+
+	if len(archived) > 0 {
+		results = r.mergeArchivedBuckets(results, archived)
+		totalCount = int64(len(results))
+		results = results[min(offset, len(results)):min(offset+fetchLimit, len(results))]
+	}
+	if r.maxBuckets > 0 {
+		totalCount = min(totalCount, int64(r.maxBuckets))
+	}
+
+	truncated := false
+	if capped && len(results) == fetchLimit {
+		truncated = true
+		results = results[:fetchLimit-1]
+	}
+
+	return results, totalCount, truncated, nil
+}
+
+// efficiencyCountExpr counts the events contributing an efficiency under the zero-nominal policy
+func (r *AnalyticsRepository) efficiencyCountExpr() string {
+	if r.zeroNominalPolicy == model.ZeroNominalZero {
+		return "COUNT(*)"
+	}
+	return "COUNT(CASE WHEN nominal_amount > 0 THEN 1 END)"
+}
+
+// fillEfficiencyStdDev computes StdDevEfficiency in Go for dialects without STDDEV_POP,
+// reading the per-event efficiencies of the buckets in results from query
+func (r *AnalyticsRepository) fillEfficiencyStdDev(query *gorm.DB, periodExpr string, results []AnalyticsAggregation) error {
+	type bucketKey struct {
+		period string
+		year   int
+	}
+	var rows []struct {
+		Period     string  `gorm:"column:period"`
+		Year       int     `gorm:"column:year"`
+		Efficiency float64 `gorm:"column:efficiency"`
+	}
+
+	periods := make([]string, len(results))
+	for i, result := range results {
+		periods[i] = result.Period
+	}
+	efficiencyExpr := r.efficiencyAggExpr("", "")
+	if err := query.
+		Select(periodExpr+" as period, "+r.dialect.ExtractYear("start_time")+" as year, "+efficiencyExpr+" as efficiency").
+		Where(periodExpr+" IN ?", periods).
+		Where(efficiencyExpr + " IS NOT NULL").
+		Scan(&rows).Error; err != nil {
+		return fmt.Errorf("failed to get efficiencies for farm: %w", err)
+	}
+
+	samples := make(map[bucketKey][]float64, len(results))
+	for _, row := range rows {
+		key := bucketKey{row.Period, row.Year}
+		samples[key] = append(samples[key], row.Efficiency)
+	}
+	for i := range results {
+		results[i].StdDevEfficiency = populationStdDev(samples[bucketKey{results[i].Period, results[i].Year}])
+	}
+	return nil
+}
+
+// populationStdDev is the population standard deviation of values, nil when fewer than two
+func populationStdDev(values []float64) *float64 {
+	if len(values) < 2 {
+		return nil
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	var squares float64
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+	stdDev := math.Sqrt(squares / float64(len(values)))
+	return &stdDev
+}
+
+// YoYAnalyticsData represents year-over-year aggregated data
+type YoYAnalyticsData struct {
+	Year               int      `gorm:"column:year"`
+	TotalRealAmount    float64  `gorm:"column:total_real_amount"`
+	TotalNominalAmount float64  `gorm:"column:total_nominal_amount"`
+	EventCount         int      `gorm:"column:event_count"`
+	AvgEfficiency      *float64 `gorm:"column:avg_efficiency"`
+	MinEfficiency      *float64 `gorm:"column:min_efficiency"`
+	MaxEfficiency      *float64 `gorm:"column:max_efficiency"`
+	EfficiencyCount    int      `gorm:"column:efficiency_count"`
+}
+
+// yoyYears is how many years GetYoYComparison covers: the current year and the ones before it
+const yoyYears = 3
+
+// GetYoYComparison retrieves year-over-year data for the same date range across 3 years
+// Uses single SQL UNION ALL query for efficiency (follows DatabaseOptimization.md best practices),
+// or one concurrent query per year with WithParallelYoY
+// Returns data for all 3 years, archived days included; caller handles year-specific extraction
+func (r *AnalyticsRepository) GetYoYComparison(
+	ctx context.Context,
+	farmID uint,
+	startTime, endTime time.Time,
+	aggregation model.Aggregation,
+) (map[int]YoYAnalyticsData, error) {
+	startTime, endTime = startTime.UTC(), endTime.UTC()
+
+	ranges := yoyRanges(startTime, endTime, time.Now().UTC().Year())
+
+	// One SELECT per year's range
+	amountCondition, amountArgs := rawRealAmountCondition(ctx, "")
+	yearSelect := `
+	SELECT
+		` + r.dialect.ExtractYear("start_time") + ` as year,
+		` + r.sumExpr("real_amount") + ` as total_real_amount,
+		` + r.sumExpr("nominal_amount") + ` as total_nominal_amount,
+		COUNT(*) as event_count,
+		` + r.efficiencyAggExpr("AVG", "") + ` as avg_efficiency,
+		` + r.efficiencyAggExpr("MIN", "") + ` as min_efficiency,
+		` + r.efficiencyAggExpr("MAX", "") + ` as max_efficiency,
+		` + r.efficiencyCountExpr() + ` as efficiency_count
+	FROM irrigation_data
+	WHERE farm_id = ? AND start_time >= ? AND start_time <= ?` + amountCondition + `
+	GROUP BY ` + r.dialect.ExtractYear("start_time")
+
+	var results []YoYAnalyticsData
+	var err error
+	if r.parallelYoY {
+		results, err = r.getYoYPerYear(ctx, yearSelect, farmID, ranges, amountArgs)
+	} else {
+		results, err = r.getYoYUnion(ctx, yearSelect, farmID, ranges, amountArgs)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get YoY comparison: %w", err)
+	}
+
+	// Convert results to map indexed by year
+	resultMap := make(map[int]YoYAnalyticsData)
+	for _, result := range results {
+		resultMap[result.Year] = result
+	}
+
+	archived, err := r.getArchivedYears(ctx, farmID, ranges)
+	if err != nil {
+		return nil, err
+	}
+	r.mergeArchivedYears(resultMap, archived)
+
+	return resultMap, nil
+}
+
+// yoyRanges calculates the range compared in each year, current year first
+// The range's month and day are moved into every year; Feb 29 becomes Feb 28 in years without it,
+// since time.Date would normalize it to Mar 1 and shift that year's window by a day
+func yoyRanges(startTime, endTime time.Time, currentYear int) [][2]time.Time {
+	ranges := make([][2]time.Time, yoyYears)
+	for i := range ranges {
+		year := currentYear - i
+		ranges[i] = [2]time.Time{
+			time.Date(year, startTime.Month(), clampLeapDay(year, startTime.Month(), startTime.Day()), 0, 0, 0, 0, time.UTC),
+			time.Date(year, endTime.Month(), clampLeapDay(year, endTime.Month(), endTime.Day()), 23, 59, 59, 0, time.UTC),
+		}
+	}
+	return ranges
+}
+
+// clampLeapDay returns Feb 28 for Feb 29 in a non-leap year and day unchanged otherwise
+func clampLeapDay(year int, month time.Month, day int) int {
+	if month == time.February && day == 29 && !isLeapYear(year) {
+		return 28
+	}
+	return day
+}
+
+func isLeapYear(year int) bool {
+	return year%4 == 0 && (year%100 != 0 || year%400 == 0)
+}
+
+// getYoYUnion runs yearSelect for every range as branches of a single UNION ALL query
+// extraArgs follow each branch's farm and range arguments
+func (r *AnalyticsRepository) getYoYUnion(ctx context.Context, yearSelect string, farmID uint, ranges [][2]time.Time, extraArgs []any) ([]YoYAnalyticsData, error) {
+	selects := make([]string, 0, len(ranges))
+	args := make([]any, 0, (3+len(extraArgs))*len(ranges))
+	for _, yearRange := range ranges {
+		selects = append(selects, yearSelect)
+		args = append(args, farmID, yearRange[0], yearRange[1])
+		args = append(args, extraArgs...)
+	}
+
+	var results []YoYAnalyticsData
+	if err := r.conn(ctx).Raw(strings.Join(selects, "\n\tUNION ALL\n"), args...).Scan(&results).Error; err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// getYoYPerYear runs yearSelect once per range, concurrently; the first failure cancels the others
+// Each query uses its own pooled connection, so wide year spans don't build one large UNION
+func (r *AnalyticsRepository) getYoYPerYear(ctx context.Context, yearSelect string, farmID uint, ranges [][2]time.Time, extraArgs []any) ([]YoYAnalyticsData, error) {
+	perYear := make([][]YoYAnalyticsData, len(ranges))
+
+	group, groupCtx := errgroup.WithContext(ctx)
+	for i, yearRange := range ranges {
+		args := append([]any{farmID, yearRange[0], yearRange[1]}, extraArgs...)
+		group.Go(func() error {
+			return r.conn(groupCtx).Raw(yearSelect, args...).Scan(&perYear[i]).Error
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
+
+	var results []YoYAnalyticsData
+	for _, rows := range perYear {
+		results = append(results, rows...)
+	}
+	return results, nil
+}
+
+// SectorAnalyticsData represents aggregated data by sector
+type SectorAnalyticsData struct {
+	SectorID           uint     `gorm:"column:sector_id"`
+	SectorName         string   `gorm:"column:sector_name"`
+	TargetEfficiency   *float64 `gorm:"column:target_efficiency"`
+	TotalRealAmount    float64  `gorm:"column:total_real_amount"`
+	TotalNominalAmount float64  `gorm:"column:total_nominal_amount"`
+	AvgEfficiency      *float64 `gorm:"column:avg_efficiency"`
+	EventCount         int      `gorm:"column:event_count"`
+}
+
+// GetSectorBreakdownForFarm retrieves aggregated metrics by irrigation sector
+// Optionally filters by specific sector_id for better performance
+// A positive limit returns one page of sectors (ordered by sector ID unless the context sets a sort; see
+// WithSectorSort); limit <= 0 returns them all
+// Also returns the total number of sectors with data in the range
+func (r *AnalyticsRepository) GetSectorBreakdownForFarm(
+	ctx context.Context,
+	farmID uint,
+	sectorID *uint,
+	startTime, endTime time.Time,
+	limit, offset int,
+) ([]SectorAnalyticsData, int64, error) {
+	return r.sectorBreakdown(ctx, farmID, sectorID, startTime, endTime, limit, offset, r.sectorSortOrder(ctx))
+}
+
+// GetSectorRanking ranks a farm's sectors by average efficiency, best first, for leaderboards
+// Ties are broken by total real volume (largest first) and then by sector ID so pages stay stable
+// across requests; sectors without a measurable efficiency rank last
+// Pagination and the returned total follow GetSectorBreakdownForFarm
+func (r *AnalyticsRepository) GetSectorRanking(
+	ctx context.Context,
+	farmID uint,
+	startTime, endTime time.Time,
+	limit, offset int,
+) ([]SectorAnalyticsData, int64, error) {
+	// Output aliases cannot appear inside ORDER BY expressions on PostgreSQL, so the NULL check repeats the aggregate
+	order := fmt.Sprintf(
+		"CASE WHEN %s IS NULL THEN 1 ELSE 0 END ASC, avg_efficiency DESC, total_real_amount DESC",
+		r.efficiencyAggExpr("AVG", "irrigation_data."),
+	)
+	return r.sectorBreakdown(ctx, farmID, nil, startTime, endTime, limit, offset, order)
+}
+
+// sectorBreakdown aggregates per-sector metrics for a farm, ordered by order and then by sector ID
+// When the context groups by zone (see WithSectorGroupBy), rows and sectorID refer to zones instead
+func (r *AnalyticsRepository) sectorBreakdown(
+	ctx context.Context,
+	farmID uint,
+	sectorID *uint,
+	startTime, endTime time.Time,
+	limit, offset int,
+	order string,
+) ([]SectorAnalyticsData, int64, error) {
+	startTime, endTime = startTime.UTC(), endTime.UTC()
+
+	var results []SectorAnalyticsData
+	var totalCount int64
+
+	sectorColumn := sectorGroupColumn(ctx)
+	baseQuery := func() *gorm.DB {
+		query := joinSectorZones(ctx, r.conn(ctx).Table("irrigation_data"), farmID).
+			Where("irrigation_data.farm_id = ? AND irrigation_data.start_time >= ? AND irrigation_data.start_time <= ?", farmID, startTime, endTime)
+
+		// Filter by specific sector if provided
+		if sectorID != nil {
+			query = query.Where(sectorColumn+" = ?", *sectorID)
+		}
+		return whereRealAmount(ctx, query, "irrigation_data.")
+	}
+
+	orderBy := sectorColumn + " ASC"
+	if order != "" {
+		orderBy = order + ", " + orderBy
+	}
+	query := baseQuery().
+		Select(`
+			` + sectorColumn + ` as sector_id,
+			irrigation_sectors.name as sector_name,
+			irrigation_sectors.target_efficiency as target_efficiency,
+			` + r.sumExpr("irrigation_data.real_amount") + ` as total_real_amount,
+			` + r.sumExpr("irrigation_data.nominal_amount") + ` as total_nominal_amount,
+			` + r.efficiencyAggExpr("AVG", "irrigation_data.") + ` as avg_efficiency,
+			COUNT(*) as event_count
+		`).
+		Joins("JOIN irrigation_sectors ON irrigation_sectors.id = " + sectorColumn).
+		Group(sectorColumn + ", irrigation_sectors.name, irrigation_sectors.target_efficiency").
+		Order(orderBy)
+
+	if limit > 0 {
+		// Count distinct sectors for pagination
+		if err := baseQuery().Select("COUNT(DISTINCT " + sectorColumn + ")").Scan(&totalCount).Error; err != nil {
+			return nil, 0, fmt.Errorf("failed to count sectors: %w", err)
+		}
+		query = query.Limit(limit).Offset(offset)
+	}
+
+	if err := query.Scan(&results).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to get sector breakdown: %w", err)
+	}
+
+	if limit <= 0 {
+		totalCount = int64(len(results))
+	}
+
+	return results, totalCount, nil
+}
+
+// FarmSectorAnalyticsData is a sector's aggregated metrics annotated with the farm it belongs to
+type FarmSectorAnalyticsData struct {
+	FarmID   uint   `gorm:"column:farm_id"`
+	FarmName string `gorm:"column:farm_name"`
+	SectorAnalyticsData
+}
+
+// GetSectorBreakdownForFarms aggregates per-sector metrics across several farms at once for regional views
+// Rows are ordered by farm ID, then sector ID; farms without events in the range contribute no rows
+func (r *AnalyticsRepository) GetSectorBreakdownForFarms(
+	ctx context.Context,
+	farmIDs []uint,
+	startTime, endTime time.Time,
+) ([]FarmSectorAnalyticsData, error) {
+	if len(farmIDs) == 0 {
+		return []FarmSectorAnalyticsData{}, nil
+	}
+	startTime, endTime = startTime.UTC(), endTime.UTC()
+
+	var results []FarmSectorAnalyticsData
+
+	query := r.conn(ctx).
+		Table("irrigation_data").
+		Select(`
+			irrigation_data.farm_id as farm_id,
+			farms.name as farm_name,
+			irrigation_data.irrigation_sector_id as sector_id,
+			irrigation_sectors.name as sector_name,
+			irrigation_sectors.target_efficiency as target_efficiency,
+			`+r.sumExpr("irrigation_data.real_amount")+` as total_real_amount,
+			`+r.sumExpr("irrigation_data.nominal_amount")+` as total_nominal_amount,
+			`+r.efficiencyAggExpr("AVG", "irrigation_data.")+` as avg_efficiency,
+			COUNT(*) as event_count
+		`).
+		Joins("JOIN irrigation_sectors ON irrigation_sectors.id = irrigation_data.irrigation_sector_id").
+		Joins("JOIN farms ON farms.id = irrigation_data.farm_id").
+		Where("irrigation_data.farm_id IN ? AND irrigation_data.start_time >= ? AND irrigation_data.start_time <= ?", farmIDs, startTime, endTime).
+		Group("irrigation_data.farm_id, farms.name, irrigation_data.irrigation_sector_id, irrigation_sectors.name, irrigation_sectors.target_efficiency").
+		Order("irrigation_data.farm_id ASC, irrigation_data.irrigation_sector_id ASC")
+
+	if err := whereRealAmount(ctx, query, "irrigation_data.").Scan(&results).Error; err != nil {
+		return nil, fmt.Errorf("failed to get sector breakdown for farms: %w", err)
+	}
+
+	return results, nil
+}
+
+// CountActiveSectors returns the number of distinct sectors with at least one event for a farm in a time range
+// Sectors without events in the range are not counted, unlike a plain sector count
+func (r *AnalyticsRepository) CountActiveSectors(ctx context.Context, farmID uint, startTime, endTime time.Time) (int, error) {
+	startTime, endTime = startTime.UTC(), endTime.UTC()
+
+	var count int64
+	query := r.conn(ctx).
+		Model(&model.IrrigationData{}).
+		Where("farm_id = ? AND start_time >= ? AND start_time <= ?", farmID, startTime, endTime)
+	if err := whereRealAmount(ctx, query, "").
+		Distinct("irrigation_sector_id").
+		Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count active sectors: %w", err)
+	}
+	return int(count), nil
+}
+
+// DataQualityData holds the counts behind a farm's data-quality summary for a time range
+type DataQualityData struct {
+	EventCount            int `gorm:"column:event_count"`
+	ZeroNominalCount      int `gorm:"column:zero_nominal_count"`
+	OverIrrigationCount   int `gorm:"column:over_irrigation_count"`
+	DaysWithData          int `gorm:"column:days_with_data"`
+	DuplicateSuspectCount int `gorm:"-"`
+}
+
+// GetDataQualityForFarm counts a farm's events in a time range that weaken the analytics:
+// events without a positive nominal amount, events delivering more than their nominal amount,
+// and extra events sharing a sector and start time with another one (likely submitted twice)
+// Also counts the distinct UTC days with at least one event
+func (r *AnalyticsRepository) GetDataQualityForFarm(ctx context.Context, farmID uint, startTime, endTime time.Time) (DataQualityData, error) {
+	startTime, endTime = startTime.UTC(), endTime.UTC()
+
+	var data DataQualityData
+	if err := whereRealAmount(ctx, r.conn(ctx), "").
+		Model(&model.IrrigationData{}).
+		Select(`
+			COUNT(*) as event_count,
+			COALESCE(SUM(CASE WHEN nominal_amount <= 0 THEN 1 ELSE 0 END), 0) as zero_nominal_count,
+			COALESCE(SUM(CASE WHEN nominal_amount > 0 AND real_amount > nominal_amount THEN 1 ELSE 0 END), 0) as over_irrigation_count,
+			COUNT(DISTINCT `+r.dialect.TruncExpr(model.AggregationDaily, "start_time")+`) as days_with_data
+		`).
+		Where("farm_id = ? AND start_time >= ? AND start_time <= ?", farmID, startTime, endTime).
+		Scan(&data).Error; err != nil {
+		return DataQualityData{}, fmt.Errorf("failed to get data quality counts: %w", err)
+	}
+
+	// Every copy beyond the first of a (sector, start_time) pair is a suspect
+	var duplicates int64
+	amountCondition, amountArgs := rawRealAmountCondition(ctx, "")
+	if err := r.conn(ctx).Raw(`
+		SELECT COALESCE(SUM(copies - 1), 0)
+		FROM (
+			SELECT COUNT(*) as copies
+			FROM irrigation_data
+			WHERE farm_id = ? AND start_time >= ? AND start_time <= ?`+amountCondition+`
+			GROUP BY irrigation_sector_id, start_time
+			HAVING COUNT(*) > 1
+		) duplicate_groups
+	`, append([]any{farmID, startTime, endTime}, amountArgs...)...).Scan(&duplicates).Error; err != nil {
+		return DataQualityData{}, fmt.Errorf("failed to count duplicate irrigation events: %w", err)
+	}
+	data.DuplicateSuspectCount = int(duplicates)
+
+	return data, nil
+}
+
+// SectorTimeSeriesData represents aggregated data for one sector within one time bucket
+type SectorTimeSeriesData struct {
+	SectorID           uint     `gorm:"column:sector_id"`
+	SectorName         string   `gorm:"column:sector_name"`
+	Period             string   `gorm:"column:period"`
+	TotalRealAmount    float64  `gorm:"column:total_real_amount"`
+	TotalNominalAmount float64  `gorm:"column:total_nominal_amount"`
+	EventCount         int      `gorm:"column:event_count"`
+	AvgEfficiency      *float64 `gorm:"column:avg_efficiency"`
+}
+
+// GetSectorTimeSeriesForFarm retrieves metrics grouped by (sector_id, period) in a single query
+// Period is the bucket start formatted as YYYY-MM-DD; buckets without events are not returned
+// Sectors are rolled up into zones like the sector breakdown when the context asks for it
+func (r *AnalyticsRepository) GetSectorTimeSeriesForFarm(
+	ctx context.Context,
+	farmID uint,
+	startTime, endTime time.Time,
+	aggregation model.Aggregation,
+) ([]SectorTimeSeriesData, error) {
+	startTime, endTime = startTime.UTC(), endTime.UTC()
+
+	var results []SectorTimeSeriesData
+
+	periodExpr := r.dialect.TruncExpr(aggregation, "irrigation_data.start_time")
+	sectorColumn := sectorGroupColumn(ctx)
+
+	if err := joinSectorZones(ctx, whereRealAmount(ctx, r.conn(ctx), "irrigation_data.").Table("irrigation_data"), farmID).
+		Select(`
+			`+sectorColumn+` as sector_id,
+			irrigation_sectors.name as sector_name,
+			`+periodExpr+` as period,
+			`+r.sumExpr("irrigation_data.real_amount")+` as total_real_amount,
+			`+r.sumExpr("irrigation_data.nominal_amount")+` as total_nominal_amount,
+			COUNT(*) as event_count,
+			`+r.efficiencyAggExpr("AVG", "irrigation_data.")+` as avg_efficiency
+		`).
+		Joins("JOIN irrigation_sectors ON irrigation_sectors.id = "+sectorColumn).
+		Where("irrigation_data.farm_id = ? AND irrigation_data.start_time >= ? AND irrigation_data.start_time <= ?", farmID, startTime, endTime).
+		Group(sectorColumn + ", irrigation_sectors.name, " + periodExpr).
+		Order(sectorColumn + " ASC, period ASC").
+		Scan(&results).Error; err != nil {
+		return nil, fmt.Errorf("failed to get sector time series: %w", err)
+	}
+
+	return results, nil
+}
+
+// PeriodSectorData holds one sector's irrigation totals within one time bucket
+type PeriodSectorData struct {
+	Period             string  `gorm:"column:period"`
+	SectorID           uint    `gorm:"column:sector_id"`
+	SectorName         string  `gorm:"column:sector_name"`
+	TotalRealAmount    float64 `gorm:"column:total_real_amount"`
+	TotalNominalAmount float64 `gorm:"column:total_nominal_amount"`
+}
+
+// GetFarmTimeSeriesBySector retrieves real and nominal sums grouped by (period, sector_id) in a single query
+// Unlike GetSectorTimeSeriesForFarm, rows are ordered by period first, so each bucket's sectors come together
+// Period is the bucket start formatted as YYYY-MM-DD; sectors without events in a bucket are not returned
+func (r *AnalyticsRepository) GetFarmTimeSeriesBySector(
+	ctx context.Context,
+	farmID uint,
+	startTime, endTime time.Time,
+	aggregation model.Aggregation,
+) ([]PeriodSectorData, error) {
+	startTime, endTime = startTime.UTC(), endTime.UTC()
+
+	var results []PeriodSectorData
+
+	periodExpr := r.dialect.TruncExpr(aggregation, "irrigation_data.start_time")
+	sectorColumn := sectorGroupColumn(ctx)
+
+	if err := joinSectorZones(ctx, whereRealAmount(ctx, r.conn(ctx), "irrigation_data.").Table("irrigation_data"), farmID).
+		Select(`
+			`+periodExpr+` as period,
+			`+sectorColumn+` as sector_id,
+			irrigation_sectors.name as sector_name,
+			`+r.sumExpr("irrigation_data.real_amount")+` as total_real_amount,
+			`+r.sumExpr("irrigation_data.nominal_amount")+` as total_nominal_amount
+		`).
+		Joins("JOIN irrigation_sectors ON irrigation_sectors.id = "+sectorColumn).
+		Where("irrigation_data.farm_id = ? AND irrigation_data.start_time >= ? AND irrigation_data.start_time <= ?", farmID, startTime, endTime).
+		Group(periodExpr + ", " + sectorColumn + ", irrigation_sectors.name").
+		Order("period ASC, " + sectorColumn + " ASC").
+		Scan(&results).Error; err != nil {
+		return nil, fmt.Errorf("failed to get farm time series by sector: %w", err)
+	}
+
+	return results, nil
+}
+
+// DailyTotalData represents irrigation totals for a single UTC day
+type DailyTotalData struct {
+	Day                string  `gorm:"column:day"`
+	TotalRealAmount    float64 `gorm:"column:total_real_amount"`
+	TotalNominalAmount float64 `gorm:"column:total_nominal_amount"`
+	EventCount         int     `gorm:"column:event_count"`
+}
+
+// GetTopIrrigationDays returns the n days with the highest total real_amount, largest first
+// Ties are broken by the earlier day; Day is formatted as YYYY-MM-DD
+func (r *AnalyticsRepository) GetTopIrrigationDays(
+	ctx context.Context,
+	farmID uint,
+	startTime, endTime time.Time,
+	n int,
+) ([]DailyTotalData, error) {
+	startTime, endTime = startTime.UTC(), endTime.UTC()
+
+	var results []DailyTotalData
+
+	dayExpr := r.dialect.TruncExpr(model.AggregationDaily, "start_time")
+
+	if err := whereRealAmount(ctx, r.conn(ctx), "").
+		Table("irrigation_data").
+		Select(`
+			`+dayExpr+` as day,
+			`+r.sumExpr("real_amount")+` as total_real_amount,
+			`+r.sumExpr("nominal_amount")+` as total_nominal_amount,
+			COUNT(*) as event_count
+		`).
+		Where("farm_id = ? AND start_time >= ? AND start_time <= ?", farmID, startTime, endTime).
+		Group(dayExpr).
+		Order("total_real_amount DESC, day ASC").
+		Limit(n).
+		Scan(&results).Error; err != nil {
+		return nil, fmt.Errorf("failed to get top irrigation days: %w", err)
+	}
+
+	return results, nil
+}
+
+// DayOfWeekData holds the totals for one weekday (0 = Sunday through 6 = Saturday)
+type DayOfWeekData struct {
+	DayOfWeek          int     `gorm:"column:day_of_week"`
+	TotalRealAmount    float64 `gorm:"column:total_real_amount"`
+	TotalNominalAmount float64 `gorm:"column:total_nominal_amount"`
+	EventCount         int     `gorm:"column:event_count"`
+}
+
+// GetDayOfWeekDistribution returns event counts and sums per UTC weekday of start_time
+// All seven days are returned in order from Sunday; days without events are zero-filled
+func (r *AnalyticsRepository) GetDayOfWeekDistribution(
+	ctx context.Context,
+	farmID uint,
+	startTime, endTime time.Time,
+) ([]DayOfWeekData, error) {
+	startTime, endTime = startTime.UTC(), endTime.UTC()
+
+	var rows []DayOfWeekData
+
+	dowExpr := r.dialect.ExtractDayOfWeek("start_time")
+
+	if err := whereRealAmount(ctx, r.conn(ctx), "").
+		Table("irrigation_data").
+		Select(`
+			`+dowExpr+` as day_of_week,
+			`+r.sumExpr("real_amount")+` as total_real_amount,
+			`+r.sumExpr("nominal_amount")+` as total_nominal_amount,
+			COUNT(*) as event_count
+		`).
+		Where("farm_id = ? AND start_time >= ? AND start_time <= ?", farmID, startTime, endTime).
+		Group(dowExpr).
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to get day-of-week distribution: %w", err)
+	}
+
+	results := make([]DayOfWeekData, 7)
+	for day := range results {
+		results[day].DayOfWeek = day
+	}
+	for _, row := range rows {
+		if row.DayOfWeek >= 0 && row.DayOfWeek < len(results) {
+			results[row.DayOfWeek] = row
+		}
+	}
+
+	return results, nil
+}
+
+// AmountBinData holds the events whose real_amount falls in one of equal-width bins over the range's amounts
+// Bins are numbered from 1; the last one includes MaxRealAmount, the others exclude it
+type AmountBinData struct {
+	Bin           int
+	MinRealAmount float64
+	MaxRealAmount float64
+	EventCount    int
+	AvgRealAmount float64
+	AvgEfficiency *float64
+}
+
+// amountBinRow is one bin as aggregated by the database or in Go
+type amountBinRow struct {
+	Bin           int      `gorm:"column:bin"`
+	EventCount    int      `gorm:"column:event_count"`
+	AvgRealAmount float64  `gorm:"column:avg_real_amount"`
+	AvgEfficiency *float64 `gorm:"column:avg_efficiency"`
+}
+
+// GetEfficiencyByAmount bins a farm's events by real_amount into bins equal-width bins spanning the
+// smallest to the largest amount in the range, with each bin's average efficiency
+// All bins are returned in order, empty ones with no efficiency; a range whose events share one amount
+// is a single bin, and a range without events returns nothing
+// PostgreSQL bins with WIDTH_BUCKET; drivers without it read per-event values and bin them in Go
+func (r *AnalyticsRepository) GetEfficiencyByAmount(
+	ctx context.Context,
+	farmID uint,
+	startTime, endTime time.Time,
+	bins int,
+) ([]AmountBinData, error) {
+	startTime, endTime = startTime.UTC(), endTime.UTC()
+
+	baseQuery := func() *gorm.DB {
+		return whereRealAmount(ctx, r.conn(ctx), "").
+			Table("irrigation_data").
+			Where("farm_id = ? AND start_time >= ? AND start_time <= ?", farmID, startTime, endTime)
+	}
+
+	var bounds struct {
+		EventCount int     `gorm:"column:event_count"`
+		MinAmount  float64 `gorm:"column:min_amount"`
+		MaxAmount  float64 `gorm:"column:max_amount"`
+	}
+	if err := baseQuery().
+		Select("COUNT(*) as event_count, COALESCE(MIN(real_amount), 0) as min_amount, COALESCE(MAX(real_amount), 0) as max_amount").
+		Scan(&bounds).Error; err != nil {
+		return nil, fmt.Errorf("failed to get real amount bounds: %w", err)
+	}
+	if bounds.EventCount == 0 {
+		return nil, nil
+	}
+	if bounds.MinAmount == bounds.MaxAmount {
+		bins = 1
+	}
+
+	binExpr := "1"
+	if bins > 1 {
+		binExpr = r.dialect.WidthBucket("real_amount", bounds.MinAmount, bounds.MaxAmount, bins)
+	}
+
+	var rows []amountBinRow
+	if binExpr != "" {
+		if err := baseQuery().
+			Select(binExpr + " as bin, COUNT(*) as event_count, AVG(real_amount) as avg_real_amount, " + r.efficiencyAggExpr("AVG", "") + " as avg_efficiency").
+			Group("bin").
+			Scan(&rows).Error; err != nil {
+			return nil, fmt.Errorf("failed to get efficiency by amount: %w", err)
+		}
+	} else {
+		var err error
+		if rows, err = r.binEfficiencyByAmount(baseQuery(), bounds.MinAmount, bounds.MaxAmount, bins); err != nil {
+			return nil, err
+		}
+	}
+
+	width := (bounds.MaxAmount - bounds.MinAmount) / float64(bins)
+	results := make([]AmountBinData, bins)
+	for i := range results {
+		results[i] = AmountBinData{
+			Bin:           i + 1,
+			MinRealAmount: bounds.MinAmount + float64(i)*width,
+			MaxRealAmount: bounds.MinAmount + float64(i+1)*width,
+		}
+	}
+	results[bins-1].MaxRealAmount = bounds.MaxAmount
+	for _, row := range rows {
+		if row.Bin >= 1 && row.Bin <= bins {
+			bin := &results[row.Bin-1]
+			bin.EventCount, bin.AvgRealAmount, bin.AvgEfficiency = row.EventCount, row.AvgRealAmount, row.AvgEfficiency
+		}
+	}
+
+	return results, nil
+}
+
+// binEfficiencyByAmount is GetEfficiencyByAmount's binning for dialects without WIDTH_BUCKET,
+// reading each event's real amount and efficiency from query and averaging them per bin in Go
+func (r *AnalyticsRepository) binEfficiencyByAmount(query *gorm.DB, low, high float64, bins int) ([]amountBinRow, error) {
+	var events []struct {
+		RealAmount float64  `gorm:"column:real_amount"`
+		Efficiency *float64 `gorm:"column:efficiency"`
+	}
+	if err := query.Select("real_amount, " + r.efficiencyAggExpr("", "") + " as efficiency").Scan(&events).Error; err != nil {
+		return nil, fmt.Errorf("failed to get efficiencies by amount: %w", err)
+	}
+
+	rows := make([]amountBinRow, bins)
+	efficiencySums := make([]float64, bins)
+	efficiencyCounts := make([]int, bins)
+	for _, event := range events {
+		i := min(int((event.RealAmount-low)/(high-low)*float64(bins)), bins-1)
+		rows[i].EventCount++
+		rows[i].AvgRealAmount += event.RealAmount
+		if event.Efficiency != nil {
+			efficiencySums[i] += *event.Efficiency
+			efficiencyCounts[i]++
+		}
+	}
+	for i := range rows {
+		rows[i].Bin = i + 1
+		if rows[i].EventCount > 0 {
+			rows[i].AvgRealAmount /= float64(rows[i].EventCount)
+		}
+		if efficiencyCounts[i] > 0 {
+			avg := efficiencySums[i] / float64(efficiencyCounts[i])
+			rows[i].AvgEfficiency = &avg
+		}
+	}
+	return rows, nil
+}
+
+// SectorScheduleData summarizes event timing for one sector with an expected watering frequency
+// Times are Unix seconds; FirstStartUnix and LastStartUnix are nil without events,
+// and MaxGapSeconds (largest gap between consecutive events) is nil with fewer than two
+type SectorScheduleData struct {
+	SectorID              uint     `gorm:"column:sector_id"`
+	SectorName            string   `gorm:"column:sector_name"`
+	ExpectedFrequencyDays int      `gorm:"column:expected_frequency_days"`
+	EventCount            int      `gorm:"column:event_count"`
+	FirstStartUnix        *float64 `gorm:"column:first_start_unix"`
+	LastStartUnix         *float64 `gorm:"column:last_start_unix"`
+	MaxGapSeconds         *float64 `gorm:"column:max_gap_seconds"`
+}
+
+// GetSectorScheduleForFarm returns event timing for each of the farm's sectors with a positive expected frequency
+// Gaps between consecutive events are computed in SQL with LAG; sectors without events in the range are included
+func (r *AnalyticsRepository) GetSectorScheduleForFarm(
+	ctx context.Context,
+	farmID uint,
+	startTime, endTime time.Time,
+) ([]SectorScheduleData, error) {
+	startTime, endTime = startTime.UTC(), endTime.UTC()
+
+	var results []SectorScheduleData
+
+	startUnix := r.dialect.UnixSeconds("start_time")
+
+	query := `
+	SELECT
+		irrigation_sectors.id as sector_id,
+		irrigation_sectors.name as sector_name,
+		irrigation_sectors.expected_frequency_days as expected_frequency_days,
+		COUNT(events.start_unix) as event_count,
+		MIN(events.start_unix) as first_start_unix,
+		MAX(events.start_unix) as last_start_unix,
+		MAX(events.gap_seconds) as max_gap_seconds
+	FROM irrigation_sectors
+	LEFT JOIN (
+		SELECT
+			irrigation_sector_id,
+			` + startUnix + ` as start_unix,
+			` + startUnix + ` - LAG(` + startUnix + `) OVER (PARTITION BY irrigation_sector_id ORDER BY start_time) as gap_seconds
+		FROM irrigation_data
+		WHERE farm_id = ? AND start_time >= ? AND start_time <= ?
+	) events ON events.irrigation_sector_id = irrigation_sectors.id
+	WHERE irrigation_sectors.farm_id = ? AND irrigation_sectors.expected_frequency_days > 0
+	GROUP BY irrigation_sectors.id, irrigation_sectors.name, irrigation_sectors.expected_frequency_days
+	ORDER BY irrigation_sectors.id ASC`
+
+	if err := r.conn(ctx).Raw(query, farmID, startTime, endTime, farmID).Scan(&results).Error; err != nil {
+		return nil, fmt.Errorf("failed to get sector schedule: %w", err)
+	}
+
+	return results, nil
+}
+
+// GetFirstEventTimeForFarm returns the start time of the farm's earliest irrigation event, or nil without events
+func (r *AnalyticsRepository) GetFirstEventTimeForFarm(ctx context.Context, farmID uint) (*time.Time, error) {
+	var firstUnix *float64
+	if err := r.conn(ctx).
+		Model(&model.IrrigationData{}).
+		Select("MIN("+r.dialect.UnixSeconds("start_time")+")").
+		Where("farm_id = ?", farmID).
+		Scan(&firstUnix).Error; err != nil {
+		return nil, fmt.Errorf("failed to get first event time: %w", err)
+	}
+	if firstUnix == nil {
+		return nil, nil
+	}
+
+	first := time.Unix(0, 0).Add(time.Duration(*firstUnix * float64(time.Second))).UTC()
+	return &first, nil
+}
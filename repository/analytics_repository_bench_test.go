@@ -0,0 +1,172 @@
+//go:build postgres
+
+package repository
+
+// Benchmarks for the analytics aggregation against a real PostgreSQL instance.
+// SQLite plans differ too much to say anything about production, so these only build with the postgres tag:
+//
+//	BENCH_DATABASE_DSN="host=localhost port=5432 user=irrigationuser password=irrigationpass dbname=irrigation_db sslmode=disable" \
+//		go test -tags postgres -run '^$' -bench GetAnalyticsForFarmByDateRange -benchmem ./repository
+//
+// Each aggregation runs twice: "heap" rebuilds idx_irrigation_farm_time on (farm_id, start_time) alone,
+// so nominal_amount and real_amount are fetched from the table, and "covering" rebuilds it with both
+// columns INCLUDEd, as database.Initialize does, so PostgreSQL can answer with an index-only scan.
+// The covering index is restored when the benchmark ends.
+//
+// BenchmarkGetYoYComparison (-bench GetYoYComparison) compares the single UNION ALL query with one
+// concurrent query per year (ANALYTICS_YOY_PARALLEL); the per-year variant needs DB_MAX_OPEN_CONNS of at
+// least the year count to actually run in parallel.
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sebaespinosa/test_NF/model"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	benchFarmID      = 900001
+	benchSectorCount = 10
+	benchRows        = 100_000
+
+	benchPlainIndex    = "CREATE INDEX idx_irrigation_farm_time ON irrigation_data (farm_id, start_time)"
+	benchCoveringIndex = "CREATE INDEX idx_irrigation_farm_time ON irrigation_data (farm_id, start_time) INCLUDE (nominal_amount, real_amount)"
+)
+
+// openBenchDB connects to BENCH_DATABASE_DSN and seeds benchRows events for benchFarmID
+// The seeded farm is removed (cascading to its sectors and events) when the benchmark ends
+func openBenchDB(b *testing.B) *gorm.DB {
+	b.Helper()
+
+	dsn := os.Getenv("BENCH_DATABASE_DSN")
+	if dsn == "" {
+		b.Skip("BENCH_DATABASE_DSN not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		b.Fatalf("failed to connect to benchmark database: %v", err)
+	}
+	if err := db.AutoMigrate(&model.Farm{}, &model.IrrigationSector{}, &model.IrrigationData{}); err != nil {
+		b.Fatalf("failed to migrate benchmark database: %v", err)
+	}
+
+	cleanup := func() {
+		db.Exec("DROP INDEX IF EXISTS idx_irrigation_farm_time")
+		db.Exec(benchCoveringIndex)
+		db.Where("farm_id = ?", benchFarmID).Delete(&model.IrrigationData{})
+		db.Where("farm_id = ?", benchFarmID).Delete(&model.IrrigationSector{})
+		db.Delete(&model.Farm{}, benchFarmID)
+	}
+	cleanup()
+	b.Cleanup(cleanup)
+
+	if err := db.Create(&model.Farm{ID: benchFarmID, Name: "Benchmark Farm"}).Error; err != nil {
+		b.Fatalf("failed to seed farm: %v", err)
+	}
+	sectors := make([]model.IrrigationSector, benchSectorCount)
+	for i := range sectors {
+		sectors[i] = model.IrrigationSector{ID: uint(benchFarmID*100 + i), FarmID: benchFarmID, Name: "Benchmark Sector"}
+	}
+	if err := db.Create(&sectors).Error; err != nil {
+		b.Fatalf("failed to seed sectors: %v", err)
+	}
+
+	// Spread events evenly over two years so a one-year range reads about half of them
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	step := 2 * 365 * 24 * time.Hour / benchRows
+	data := make([]model.IrrigationData, benchRows)
+	for i := range data {
+		eventStart := start.Add(time.Duration(i) * step)
+		data[i] = model.IrrigationData{
+			FarmID:             benchFarmID,
+			IrrigationSectorID: sectors[i%benchSectorCount].ID,
+			StartTime:          eventStart,
+			EndTime:            eventStart.Add(time.Hour),
+			NominalAmount:      float64(10 + i%15),
+			RealAmount:         float64(8 + i%13),
+		}
+	}
+	if err := db.CreateInBatches(&data, 1000).Error; err != nil {
+		b.Fatalf("failed to seed irrigation data: %v", err)
+	}
+	if err := db.Exec("ANALYZE irrigation_data").Error; err != nil {
+		b.Fatalf("failed to analyze irrigation_data: %v", err)
+	}
+
+	return db
+}
+
+func BenchmarkGetAnalyticsForFarmByDateRange(b *testing.B) {
+	db := openBenchDB(b)
+	repo := NewAnalyticsRepository(db)
+	ctx := context.Background()
+
+	startTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	endTime := time.Date(2024, 12, 31, 23, 59, 59, 0, time.UTC)
+	aggregations := []model.Aggregation{model.AggregationDaily, model.AggregationWeekly, model.AggregationMonthly}
+
+	run := func(variant string) {
+		for _, aggregation := range aggregations {
+			b.Run(string(aggregation)+"/"+variant, func(b *testing.B) {
+				b.ReportAllocs()
+				for b.Loop() {
+					if _, _, _, err := repo.GetAnalyticsForFarmByDateRange(ctx, benchFarmID, startTime, endTime, aggregation, 1000, 0); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+
+	// VACUUM sets the visibility map bits an index-only scan needs to skip the heap
+	useIndex := func(definition string) {
+		if err := db.Exec("DROP INDEX IF EXISTS idx_irrigation_farm_time").Error; err != nil {
+			b.Fatalf("failed to drop idx_irrigation_farm_time: %v", err)
+		}
+		if err := db.Exec(definition).Error; err != nil {
+			b.Fatalf("failed to create idx_irrigation_farm_time: %v", err)
+		}
+		if err := db.Exec("VACUUM ANALYZE irrigation_data").Error; err != nil {
+			b.Fatalf("failed to vacuum irrigation_data: %v", err)
+		}
+	}
+
+	useIndex(benchPlainIndex)
+	run("heap")
+
+	useIndex(benchCoveringIndex)
+	run("covering")
+}
+
+func BenchmarkGetYoYComparison(b *testing.B) {
+	db := openBenchDB(b)
+	ctx := context.Background()
+
+	// The YoY window is anchored on the current year, so only the years it shares with the seeded 2023-2024 events scan rows
+	year := time.Now().UTC().Year()
+	startTime := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+	endTime := time.Date(year, 6, 30, 23, 59, 59, 0, time.UTC)
+
+	for _, variant := range []struct {
+		name string
+		repo *AnalyticsRepository
+	}{
+		{name: "union", repo: NewAnalyticsRepository(db)},
+		{name: "parallel", repo: NewAnalyticsRepository(db).WithParallelYoY(true)},
+	} {
+		b.Run(variant.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := variant.repo.GetYoYComparison(ctx, benchFarmID, startTime, endTime, model.AggregationDaily); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
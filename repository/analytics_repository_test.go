@@ -0,0 +1,1088 @@
+package repository
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/sebaespinosa/test_NF/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetSectorTimeSeriesForFarm(t *testing.T) {
+	db := setupTestDB(t)
+	seedBasicData(t, db)
+
+	require.NoError(t, db.Create(&model.IrrigationSector{ID: 2, FarmID: 1, Name: "Sector B"}).Error)
+	require.NoError(t, db.Create(&model.IrrigationData{
+		FarmID:             1,
+		IrrigationSectorID: 2,
+		StartTime:          time.Date(2024, 3, 2, 8, 0, 0, 0, time.UTC),
+		EndTime:            time.Date(2024, 3, 2, 9, 0, 0, 0, time.UTC),
+		NominalAmount:      10,
+		RealAmount:         5,
+	}).Error)
+
+	repo := NewAnalyticsRepository(db)
+	ctx := context.Background()
+
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 2, 23, 59, 59, 0, time.UTC)
+
+	results, err := repo.GetSectorTimeSeriesForFarm(ctx, 1, start, end, model.AggregationDaily)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	assert.Equal(t, uint(1), results[0].SectorID)
+	assert.Equal(t, "2024-03-01", results[0].Period)
+	assert.Equal(t, 2, results[0].EventCount)
+	assert.InDelta(t, 30.0, results[0].TotalRealAmount, 0.001)
+	require.NotNil(t, results[0].AvgEfficiency)
+	assert.InDelta(t, 0.85, *results[0].AvgEfficiency, 0.001)
+
+	assert.Equal(t, uint(1), results[1].SectorID)
+	assert.Equal(t, "2024-03-02", results[1].Period)
+
+	assert.Equal(t, uint(2), results[2].SectorID)
+	assert.Equal(t, "Sector B", results[2].SectorName)
+	require.NotNil(t, results[2].AvgEfficiency)
+	assert.InDelta(t, 0.5, *results[2].AvgEfficiency, 0.001)
+
+	// Weekly buckets start on Monday (2024-02-26 for both days)
+	weekly, err := repo.GetSectorTimeSeriesForFarm(ctx, 1, start, end, model.AggregationWeekly)
+	require.NoError(t, err)
+	require.Len(t, weekly, 2)
+	assert.Equal(t, "2024-02-26", weekly[0].Period)
+	assert.Equal(t, 3, weekly[0].EventCount)
+}
+
+func TestGetFarmTimeSeriesBySector(t *testing.T) {
+	db := setupTestDB(t)
+	seedBasicData(t, db)
+
+	require.NoError(t, db.Create(&model.IrrigationSector{ID: 2, FarmID: 1, Name: "Sector B"}).Error)
+	require.NoError(t, db.Create(&[]model.IrrigationData{
+		{FarmID: 1, IrrigationSectorID: 2, StartTime: time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC), EndTime: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC), NominalAmount: 12, RealAmount: 9},
+		{FarmID: 1, IrrigationSectorID: 2, StartTime: time.Date(2024, 3, 2, 8, 0, 0, 0, time.UTC), EndTime: time.Date(2024, 3, 2, 9, 0, 0, 0, time.UTC), NominalAmount: 10, RealAmount: 5},
+	}).Error)
+
+	repo := NewAnalyticsRepository(db)
+	ctx := context.Background()
+
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 2, 23, 59, 59, 0, time.UTC)
+
+	results, err := repo.GetFarmTimeSeriesBySector(ctx, 1, start, end, model.AggregationDaily)
+	require.NoError(t, err)
+
+	// Ordered by bucket first, then by sector within each bucket
+	assert.Equal(t, []PeriodSectorData{
+		{Period: "2024-03-01", SectorID: 1, SectorName: "Sector A", TotalRealAmount: 30, TotalNominalAmount: 35},
+		{Period: "2024-03-01", SectorID: 2, SectorName: "Sector B", TotalRealAmount: 9, TotalNominalAmount: 12},
+		{Period: "2024-03-02", SectorID: 1, SectorName: "Sector A", TotalRealAmount: 20, TotalNominalAmount: 25},
+		{Period: "2024-03-02", SectorID: 2, SectorName: "Sector B", TotalRealAmount: 5, TotalNominalAmount: 10},
+	}, results)
+
+	// Both days fall in the week starting Monday 2024-02-26
+	weekly, err := repo.GetFarmTimeSeriesBySector(ctx, 1, start, end, model.AggregationWeekly)
+	require.NoError(t, err)
+	require.Len(t, weekly, 2)
+	assert.Equal(t, "2024-02-26", weekly[0].Period)
+	assert.InDelta(t, 50.0, weekly[0].TotalRealAmount, 0.001)
+	assert.InDelta(t, 14.0, weekly[1].TotalRealAmount, 0.001)
+
+	empty, err := repo.GetFarmTimeSeriesBySector(ctx, 99, start, end, model.AggregationDaily)
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+}
+
+func TestGetTopIrrigationDays(t *testing.T) {
+	db := setupTestDB(t)
+	seedBasicData(t, db)
+
+	extra := model.IrrigationData{
+		FarmID:             1,
+		IrrigationSectorID: 1,
+		StartTime:          time.Date(2024, 3, 3, 6, 0, 0, 0, time.UTC),
+		EndTime:            time.Date(2024, 3, 3, 7, 0, 0, 0, time.UTC),
+		NominalAmount:      50,
+		RealAmount:         45,
+	}
+	require.NoError(t, db.Create(&extra).Error)
+
+	repo := NewAnalyticsRepository(db)
+	ctx := context.Background()
+
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 31, 23, 59, 59, 0, time.UTC)
+
+	results, err := repo.GetTopIrrigationDays(ctx, 1, start, end, 2)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	assert.Equal(t, "2024-03-03", results[0].Day)
+	assert.InDelta(t, 45.0, results[0].TotalRealAmount, 0.001)
+	assert.Equal(t, "2024-03-01", results[1].Day)
+	assert.InDelta(t, 30.0, results[1].TotalRealAmount, 0.001)
+	assert.Equal(t, 2, results[1].EventCount)
+
+	all, err := repo.GetTopIrrigationDays(ctx, 1, start, end, 10)
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	assert.Equal(t, "2024-03-02", all[2].Day)
+}
+
+func TestZeroNominalPolicy(t *testing.T) {
+	db := setupTestDB(t)
+
+	year := time.Now().Year()
+	require.NoError(t, db.Create(&model.Farm{ID: 1, Name: "Farm A"}).Error)
+	require.NoError(t, db.Create(&model.IrrigationSector{ID: 1, FarmID: 1, Name: "Sector A"}).Error)
+	require.NoError(t, db.Create(&[]model.IrrigationData{
+		{FarmID: 1, IrrigationSectorID: 1, StartTime: time.Date(year, 3, 1, 6, 0, 0, 0, time.UTC), EndTime: time.Date(year, 3, 1, 7, 0, 0, 0, time.UTC), NominalAmount: 20, RealAmount: 18},
+		{FarmID: 1, IrrigationSectorID: 1, StartTime: time.Date(year, 3, 1, 12, 0, 0, 0, time.UTC), EndTime: time.Date(year, 3, 1, 13, 0, 0, 0, time.UTC), NominalAmount: 15, RealAmount: 12},
+		// No nominal amount: excluded from efficiency, or 0% under the zero policy
+		{FarmID: 1, IrrigationSectorID: 1, StartTime: time.Date(year, 3, 1, 18, 0, 0, 0, time.UTC), EndTime: time.Date(year, 3, 1, 19, 0, 0, 0, time.UTC), NominalAmount: 0, RealAmount: 5},
+	}).Error)
+
+	ctx := context.Background()
+	start := time.Date(year, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(year, 3, 1, 23, 59, 59, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		repo    *AnalyticsRepository
+		wantAvg float64
+		wantMin float64
+	}{
+		{"exclude by default", NewAnalyticsRepository(db), (0.9 + 0.8) / 2, 0.8},
+		{"exclude", NewAnalyticsRepository(db).WithZeroNominalPolicy(model.ZeroNominalExclude), (0.9 + 0.8) / 2, 0.8},
+		{"zero", NewAnalyticsRepository(db).WithZeroNominalPolicy(model.ZeroNominalZero), (0.9 + 0.8 + 0) / 3, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, _, _, err := tt.repo.GetAnalyticsForFarmByDateRange(ctx, 1, start, end, model.AggregationDaily, 50, 0)
+			require.NoError(t, err)
+			require.Len(t, results, 1)
+			require.NotNil(t, results[0].AvgEfficiency)
+			assert.InDelta(t, tt.wantAvg, *results[0].AvgEfficiency, 0.0001)
+			require.NotNil(t, results[0].MinEfficiency)
+			assert.InDelta(t, tt.wantMin, *results[0].MinEfficiency, 0.0001)
+			require.NotNil(t, results[0].MaxEfficiency)
+			assert.InDelta(t, 0.9, *results[0].MaxEfficiency, 0.0001)
+
+			sectors, _, err := tt.repo.GetSectorBreakdownForFarm(ctx, 1, nil, start, end, 0, 0)
+			require.NoError(t, err)
+			require.Len(t, sectors, 1)
+			require.NotNil(t, sectors[0].AvgEfficiency)
+			assert.InDelta(t, tt.wantAvg, *sectors[0].AvgEfficiency, 0.0001)
+
+			yoy, err := tt.repo.GetYoYComparison(ctx, 1, start, end, model.AggregationDaily)
+			require.NoError(t, err)
+			require.Contains(t, yoy, year)
+			require.NotNil(t, yoy[year].MinEfficiency)
+			assert.InDelta(t, tt.wantMin, *yoy[year].MinEfficiency, 0.0001)
+		})
+	}
+}
+
+func TestYoYRanges_LeapDay(t *testing.T) {
+	start := time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 2, 29, 23, 59, 59, 0, time.UTC)
+
+	ranges := yoyRanges(start, end, 2025)
+	assert.Equal(t, [][2]time.Time{
+		{time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC), time.Date(2025, 2, 28, 23, 59, 59, 0, time.UTC)},
+		{time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 29, 23, 59, 59, 0, time.UTC)},
+		{time.Date(2023, 2, 28, 0, 0, 0, 0, time.UTC), time.Date(2023, 2, 28, 23, 59, 59, 0, time.UTC)},
+	}, ranges)
+
+	// A range ending on Feb 29 stops at Feb 28 in non-leap years instead of spilling into March
+	ranges = yoyRanges(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), end, 2101)
+	assert.Equal(t, time.Date(2101, 2, 28, 23, 59, 59, 0, time.UTC), ranges[0][1])
+	assert.Equal(t, time.Date(2100, 2, 28, 23, 59, 59, 0, time.UTC), ranges[1][1], "2100 is not a leap year")
+	assert.Equal(t, time.Date(2099, 2, 28, 23, 59, 59, 0, time.UTC), ranges[2][1])
+	assert.Equal(t, time.Date(2000, 2, 29, 0, 0, 0, 0, time.UTC), yoyRanges(start, end, 2000)[0][0], "2000 is a leap year")
+}
+
+func TestGetYoYComparison_LeapDay(t *testing.T) {
+	db := setupTestDB(t)
+	require.NoError(t, db.Create(&model.Farm{ID: 1, Name: "Farm A"}).Error)
+	require.NoError(t, db.Create(&model.IrrigationSector{ID: 1, FarmID: 1, Name: "Sector A"}).Error)
+
+	// The compared years come from the current date, so check whichever of them lack Feb 29
+	currentYear := time.Now().UTC().Year()
+	var nonLeapYears []int
+	var events []model.IrrigationData
+	for y := currentYear; y > currentYear-yoyYears; y-- {
+		if isLeapYear(y) {
+			continue
+		}
+		nonLeapYears = append(nonLeapYears, y)
+		for _, day := range []time.Time{time.Date(y, 2, 28, 6, 0, 0, 0, time.UTC), time.Date(y, 3, 1, 6, 0, 0, 0, time.UTC)} {
+			events = append(events, model.IrrigationData{
+				FarmID: 1, IrrigationSectorID: 1, StartTime: day, EndTime: day.Add(time.Hour),
+				NominalAmount: 10, RealAmount: float64(day.Month()),
+			})
+		}
+	}
+	require.NotEmpty(t, nonLeapYears)
+	require.NoError(t, db.Create(&events).Error)
+
+	start := time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 2, 29, 23, 59, 59, 0, time.UTC)
+	yoy, err := NewAnalyticsRepository(db).GetYoYComparison(context.Background(), 1, start, end, model.AggregationDaily)
+	require.NoError(t, err)
+
+	for _, y := range nonLeapYears {
+		require.Contains(t, yoy, y)
+		assert.Equal(t, 1, yoy[y].EventCount, "year %d compares Feb 28 only", y)
+		assert.InDelta(t, float64(time.February), yoy[y].TotalRealAmount, 0.001, "year %d must not include Mar 1", y)
+	}
+}
+
+func TestGetYoYComparison_ParallelMatchesUnion(t *testing.T) {
+	db := setupTestDB(t)
+	// Every :memory: connection is a separate database, so the concurrent queries must share one
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+
+	year := time.Now().Year()
+	require.NoError(t, db.Create(&model.Farm{ID: 1, Name: "Farm A"}).Error)
+	require.NoError(t, db.Create(&model.IrrigationSector{ID: 1, FarmID: 1, Name: "Sector A"}).Error)
+	var events []model.IrrigationData
+	for i, y := range []int{year, year, year - 1, year - 2, year - 3} {
+		start := time.Date(y, 3, 1+i, 6, 0, 0, 0, time.UTC)
+		events = append(events, model.IrrigationData{
+			FarmID: 1, IrrigationSectorID: 1, StartTime: start, EndTime: start.Add(time.Hour),
+			NominalAmount: float64(10 + i), RealAmount: float64(8 + i),
+		})
+	}
+	// Outside the March range in every year
+	events = append(events, model.IrrigationData{
+		FarmID: 1, IrrigationSectorID: 1, StartTime: time.Date(year-1, 6, 1, 6, 0, 0, 0, time.UTC), EndTime: time.Date(year-1, 6, 1, 7, 0, 0, 0, time.UTC),
+		NominalAmount: 50, RealAmount: 50,
+	})
+	require.NoError(t, db.Create(&events).Error)
+
+	ctx := context.Background()
+	start := time.Date(year, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(year, 3, 31, 23, 59, 59, 0, time.UTC)
+
+	union, err := NewAnalyticsRepository(db).GetYoYComparison(ctx, 1, start, end, model.AggregationDaily)
+	require.NoError(t, err)
+	parallel, err := NewAnalyticsRepository(db).WithParallelYoY(true).GetYoYComparison(ctx, 1, start, end, model.AggregationDaily)
+	require.NoError(t, err)
+
+	assert.Equal(t, union, parallel)
+	require.Len(t, union, 3)
+	assert.Equal(t, 2, union[year].EventCount)
+	assert.InDelta(t, 8+9, union[year].TotalRealAmount, 0.001)
+	assert.NotContains(t, union, year-3)
+}
+
+func TestGetSectorBreakdownForFarm_Pagination(t *testing.T) {
+	db := setupTestDB(t)
+
+	require.NoError(t, db.Create(&model.Farm{ID: 1, Name: "Farm A"}).Error)
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	for id := uint(1); id <= 5; id++ {
+		require.NoError(t, db.Create(&model.IrrigationSector{ID: id, FarmID: 1, Name: "Sector"}).Error)
+		// Two events per sector so the count is of sectors, not events
+		require.NoError(t, db.Create(&[]model.IrrigationData{
+			{FarmID: 1, IrrigationSectorID: id, StartTime: start.Add(6 * time.Hour), EndTime: start.Add(7 * time.Hour), NominalAmount: 10, RealAmount: 9},
+			{FarmID: 1, IrrigationSectorID: id, StartTime: start.Add(18 * time.Hour), EndTime: start.Add(19 * time.Hour), NominalAmount: 10, RealAmount: 8},
+		}).Error)
+	}
+
+	repo := NewAnalyticsRepository(db)
+	ctx := context.Background()
+	end := start.AddDate(0, 0, 1)
+
+	sectors, total, err := repo.GetSectorBreakdownForFarm(ctx, 1, nil, start, end, 2, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), total)
+	require.Len(t, sectors, 2)
+	assert.Equal(t, uint(3), sectors[0].SectorID)
+	assert.Equal(t, uint(4), sectors[1].SectorID)
+	assert.Equal(t, 2, sectors[0].EventCount)
+
+	sectors, total, err = repo.GetSectorBreakdownForFarm(ctx, 1, nil, start, end, 2, 4)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), total)
+	require.Len(t, sectors, 1)
+	assert.Equal(t, uint(5), sectors[0].SectorID)
+
+	// Without a limit every sector is returned
+	sectors, total, err = repo.GetSectorBreakdownForFarm(ctx, 1, nil, start, end, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), total)
+	assert.Len(t, sectors, 5)
+
+	sectorID := uint(2)
+	sectors, total, err = repo.GetSectorBreakdownForFarm(ctx, 1, &sectorID, start, end, 2, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	require.Len(t, sectors, 1)
+	assert.Equal(t, uint(2), sectors[0].SectorID)
+}
+
+func TestGetSectorRanking_TieBreaks(t *testing.T) {
+	db := setupTestDB(t)
+
+	require.NoError(t, db.Create(&model.Farm{ID: 1, Name: "Farm A"}).Error)
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	amounts := map[uint][2]float64{ // nominal, real
+		1: {10, 8},  // 0.8 efficiency
+		2: {10, 9},  // 0.9, smaller volume
+		3: {20, 18}, // 0.9, tied with sector 4 on volume too
+		4: {20, 18},
+		5: {0, 50}, // no measurable efficiency despite the largest volume
+	}
+	for id := uint(1); id <= 5; id++ {
+		require.NoError(t, db.Create(&model.IrrigationSector{ID: id, FarmID: 1, Name: "Sector"}).Error)
+		require.NoError(t, db.Create(&model.IrrigationData{
+			FarmID: 1, IrrigationSectorID: id, StartTime: start.Add(6 * time.Hour), EndTime: start.Add(7 * time.Hour),
+			NominalAmount: amounts[id][0], RealAmount: amounts[id][1],
+		}).Error)
+	}
+
+	repo := NewAnalyticsRepository(db)
+	ctx := context.Background()
+	end := start.AddDate(0, 0, 1)
+
+	sectorIDs := func(sectors []SectorAnalyticsData) []uint {
+		ids := make([]uint, 0, len(sectors))
+		for _, sector := range sectors {
+			ids = append(ids, sector.SectorID)
+		}
+		return ids
+	}
+
+	sectors, total, err := repo.GetSectorRanking(ctx, 1, start, end, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), total)
+	assert.Equal(t, []uint{3, 4, 2, 1, 5}, sectorIDs(sectors))
+	assert.Nil(t, sectors[4].AvgEfficiency)
+
+	// Pages follow the same order, so no sector repeats or goes missing across them
+	sectors, total, err = repo.GetSectorRanking(ctx, 1, start, end, 2, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), total)
+	assert.Equal(t, []uint{2, 1}, sectorIDs(sectors))
+}
+
+func TestGetSectorBreakdownForFarm_Sort(t *testing.T) {
+	db := setupTestDB(t)
+
+	require.NoError(t, db.Create(&model.Farm{ID: 1, Name: "Farm A"}).Error)
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	sectors := []struct {
+		name          string
+		nominal, real float64
+	}{
+		1: {"Delta", 10, 8},    // 0.8 efficiency, 8 mm
+		2: {"Alpha", 10, 9},    // 0.9, 9 mm
+		3: {"Charlie", 40, 30}, // 0.75, 30 mm
+		4: {"Bravo", 0, 12},    // no measurable efficiency, 12 mm
+	}
+	for id := uint(1); id <= 4; id++ {
+		require.NoError(t, db.Create(&model.IrrigationSector{ID: id, FarmID: 1, Name: sectors[id].name}).Error)
+		require.NoError(t, db.Create(&model.IrrigationData{
+			FarmID: 1, IrrigationSectorID: id, StartTime: start.Add(6 * time.Hour), EndTime: start.Add(7 * time.Hour),
+			NominalAmount: sectors[id].nominal, RealAmount: sectors[id].real,
+		}).Error)
+	}
+
+	repo := NewAnalyticsRepository(db)
+	end := start.AddDate(0, 0, 1)
+
+	tests := []struct {
+		name string
+		sort model.SectorSort
+		want []uint
+	}{
+		{"default", model.SectorSort{}, []uint{1, 2, 3, 4}},
+		{"id desc", model.SectorSort{Field: model.SectorSortID, Descending: true}, []uint{4, 3, 2, 1}},
+		{"name asc", model.SectorSort{Field: model.SectorSortName}, []uint{2, 4, 3, 1}},
+		{"name desc", model.SectorSort{Field: model.SectorSortName, Descending: true}, []uint{1, 3, 4, 2}},
+		{"volume desc", model.SectorSort{Field: model.SectorSortVolume, Descending: true}, []uint{3, 4, 2, 1}},
+		{"volume asc", model.SectorSort{Field: model.SectorSortVolume}, []uint{1, 2, 4, 3}},
+		// Sectors without an efficiency come last in both directions
+		{"efficiency desc", model.SectorSort{Field: model.SectorSortEfficiency, Descending: true}, []uint{2, 1, 3, 4}},
+		{"efficiency asc", model.SectorSort{Field: model.SectorSortEfficiency}, []uint{3, 1, 2, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := WithSectorSort(context.Background(), tt.sort)
+			results, total, err := repo.GetSectorBreakdownForFarm(ctx, 1, nil, start, end, 0, 0)
+			require.NoError(t, err)
+			assert.Equal(t, int64(4), total)
+
+			ids := make([]uint, 0, len(results))
+			for _, result := range results {
+				ids = append(ids, result.SectorID)
+			}
+			assert.Equal(t, tt.want, ids)
+		})
+	}
+
+	// Pages follow the requested order
+	ctx := WithSectorSort(context.Background(), model.SectorSort{Field: model.SectorSortVolume, Descending: true})
+	page, _, err := repo.GetSectorBreakdownForFarm(ctx, 1, nil, start, end, 2, 2)
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, uint(2), page[0].SectorID)
+	assert.Equal(t, uint(1), page[1].SectorID)
+}
+
+func TestGetSectorBreakdownForFarm_GroupByZone(t *testing.T) {
+	db := setupTestDB(t)
+	// Sector 1 irrigates 50 mm over three events; sector 2 belongs to its zone, sector 3 stands alone
+	seedBasicData(t, db)
+	zone := uint(1)
+	require.NoError(t, db.Create(&[]model.IrrigationSector{
+		{ID: 2, FarmID: 1, Name: "Zone Child", ParentSectorID: &zone},
+		{ID: 3, FarmID: 1, Name: "Standalone"},
+	}).Error)
+	require.NoError(t, db.Create(&[]model.IrrigationData{
+		{FarmID: 1, IrrigationSectorID: 2, StartTime: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC), EndTime: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC), NominalAmount: 10, RealAmount: 10},
+		{FarmID: 1, IrrigationSectorID: 3, StartTime: time.Date(2024, 3, 2, 9, 0, 0, 0, time.UTC), EndTime: time.Date(2024, 3, 2, 10, 0, 0, 0, time.UTC), NominalAmount: 10, RealAmount: 5},
+	}).Error)
+
+	repo := NewAnalyticsRepository(db)
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 31, 23, 59, 59, 0, time.UTC)
+
+	sectors, total, err := repo.GetSectorBreakdownForFarm(context.Background(), 1, nil, start, end, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), total)
+
+	ctx := WithSectorGroupBy(context.Background(), model.SectorGroupByZone)
+	zones, total, err := repo.GetSectorBreakdownForFarm(ctx, 1, nil, start, end, 10, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	require.Len(t, zones, 2)
+	assert.Equal(t, uint(1), zones[0].SectorID)
+	assert.Equal(t, "Sector A", zones[0].SectorName)
+	assert.InDelta(t, 60, zones[0].TotalRealAmount, 0.001)
+	assert.Equal(t, 4, zones[0].EventCount)
+	assert.Equal(t, uint(3), zones[1].SectorID)
+	assert.InDelta(t, 5, zones[1].TotalRealAmount, 0.001)
+	assert.Len(t, sectors, 3)
+
+	// sector_id selects a whole zone
+	zones, _, err = repo.GetSectorBreakdownForFarm(ctx, 1, &zone, start, end, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, zones, 1)
+	assert.Equal(t, 4, zones[0].EventCount)
+
+	series, err := repo.GetSectorTimeSeriesForFarm(ctx, 1, start, end, model.AggregationDaily)
+	require.NoError(t, err)
+	require.Len(t, series, 3)
+	assert.Equal(t, uint(1), series[0].SectorID)
+	assert.Equal(t, "2024-03-01", series[0].Period)
+	assert.Equal(t, 3, series[0].EventCount)
+	assert.Equal(t, uint(1), series[1].SectorID)
+	assert.Equal(t, uint(3), series[2].SectorID)
+}
+
+func TestGetSectorBreakdownForFarms(t *testing.T) {
+	db := setupTestDB(t)
+	seedBasicData(t, db)
+
+	require.NoError(t, db.Create(&[]model.Farm{{ID: 2, Name: "Farm B"}, {ID: 3, Name: "Farm C"}}).Error)
+	require.NoError(t, db.Create(&[]model.IrrigationSector{
+		{ID: 2, FarmID: 2, Name: "Sector B1"},
+		{ID: 3, FarmID: 2, Name: "Sector B2"},
+		{ID: 4, FarmID: 3, Name: "Sector C"},
+	}).Error)
+	day := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	require.NoError(t, db.Create(&[]model.IrrigationData{
+		{FarmID: 2, IrrigationSectorID: 3, StartTime: day, EndTime: day.Add(time.Hour), NominalAmount: 10, RealAmount: 5},
+		{FarmID: 2, IrrigationSectorID: 2, StartTime: day, EndTime: day.Add(time.Hour), NominalAmount: 10, RealAmount: 9},
+		{FarmID: 2, IrrigationSectorID: 2, StartTime: day.Add(2 * time.Hour), EndTime: day.Add(3 * time.Hour), NominalAmount: 10, RealAmount: 7},
+		// Farm 3 is not requested
+		{FarmID: 3, IrrigationSectorID: 4, StartTime: day, EndTime: day.Add(time.Hour), NominalAmount: 10, RealAmount: 10},
+	}).Error)
+
+	repo := NewAnalyticsRepository(db)
+	ctx := context.Background()
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+
+	rows, err := repo.GetSectorBreakdownForFarms(ctx, []uint{2, 1}, start, end)
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+
+	assert.Equal(t, uint(1), rows[0].FarmID)
+	assert.Equal(t, "Farm A", rows[0].FarmName)
+	assert.Equal(t, uint(1), rows[0].SectorID)
+	assert.Equal(t, 3, rows[0].EventCount)
+	assert.InDelta(t, 50, rows[0].TotalRealAmount, 0.001)
+
+	assert.Equal(t, uint(2), rows[1].FarmID)
+	assert.Equal(t, "Farm B", rows[1].FarmName)
+	assert.Equal(t, uint(2), rows[1].SectorID)
+	assert.Equal(t, "Sector B1", rows[1].SectorName)
+	assert.Equal(t, 2, rows[1].EventCount)
+	assert.InDelta(t, 16, rows[1].TotalRealAmount, 0.001)
+	require.NotNil(t, rows[1].AvgEfficiency)
+	assert.InDelta(t, 0.8, *rows[1].AvgEfficiency, 0.001)
+
+	assert.Equal(t, uint(2), rows[2].FarmID)
+	assert.Equal(t, uint(3), rows[2].SectorID)
+
+	rows, err = repo.GetSectorBreakdownForFarms(ctx, nil, start, end)
+	require.NoError(t, err)
+	assert.Empty(t, rows)
+}
+
+func TestCountActiveSectors(t *testing.T) {
+	db := setupTestDB(t)
+
+	require.NoError(t, db.Create(&[]model.Farm{{ID: 1, Name: "Farm A"}, {ID: 2, Name: "Farm B"}}).Error)
+	require.NoError(t, db.Create(&[]model.IrrigationSector{
+		{ID: 1, FarmID: 1, Name: "Active twice"},
+		{ID: 2, FarmID: 1, Name: "Active once"},
+		{ID: 3, FarmID: 1, Name: "Idle"},
+		{ID: 4, FarmID: 1, Name: "Active before the range"},
+		{ID: 5, FarmID: 2, Name: "Other farm"},
+	}).Error)
+
+	day := time.Date(2024, 3, 10, 6, 0, 0, 0, time.UTC)
+	event := func(farmID, sectorID uint, start time.Time) model.IrrigationData {
+		return model.IrrigationData{FarmID: farmID, IrrigationSectorID: sectorID, StartTime: start, EndTime: start.Add(time.Hour), NominalAmount: 10, RealAmount: 9}
+	}
+	require.NoError(t, db.Create(&[]model.IrrigationData{
+		event(1, 1, day),
+		event(1, 1, day.AddDate(0, 0, 1)),
+		event(1, 2, day),
+		event(1, 4, day.AddDate(0, -1, 0)),
+		event(2, 5, day),
+	}).Error)
+
+	repo := NewAnalyticsRepository(db)
+	ctx := context.Background()
+
+	count, err := repo.CountActiveSectors(ctx, 1, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	count, err = repo.CountActiveSectors(ctx, 1, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
+
+func TestGetSectorScheduleForFarm(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewAnalyticsRepository(db)
+	ctx := context.Background()
+
+	every := func(days int) *int { return &days }
+	require.NoError(t, db.Create(&model.Farm{ID: 1, Name: "Farm A"}).Error)
+	require.NoError(t, db.Create(&[]model.IrrigationSector{
+		{ID: 1, FarmID: 1, Name: "On schedule", ExpectedFrequencyDays: every(3)},
+		{ID: 2, FarmID: 1, Name: "Skipped", ExpectedFrequencyDays: every(3)},
+		{ID: 3, FarmID: 1, Name: "Dry", ExpectedFrequencyDays: every(5)},
+		{ID: 4, FarmID: 1, Name: "Unscheduled"},
+	}).Error)
+
+	event := func(sectorID uint, day int) model.IrrigationData {
+		start := time.Date(2024, 3, day, 6, 0, 0, 0, time.UTC)
+		return model.IrrigationData{FarmID: 1, IrrigationSectorID: sectorID, StartTime: start, EndTime: start.Add(time.Hour), NominalAmount: 10, RealAmount: 9}
+	}
+	require.NoError(t, db.Create(&[]model.IrrigationData{
+		event(1, 1), event(1, 3), event(1, 5), event(1, 7), event(1, 9),
+		event(2, 2), event(2, 9),
+		event(4, 4),
+	}).Error)
+
+	results, err := repo.GetSectorScheduleForFarm(ctx, 1, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	assert.Equal(t, "On schedule", results[0].SectorName)
+	assert.Equal(t, 5, results[0].EventCount)
+	require.NotNil(t, results[0].MaxGapSeconds)
+	assert.InDelta(t, 2*24*60*60, *results[0].MaxGapSeconds, 1)
+	require.NotNil(t, results[0].FirstStartUnix)
+	assert.InDelta(t, float64(time.Date(2024, 3, 1, 6, 0, 0, 0, time.UTC).Unix()), *results[0].FirstStartUnix, 1)
+
+	// The skipped sector shows its week-long gap
+	assert.Equal(t, 3, results[1].ExpectedFrequencyDays)
+	require.NotNil(t, results[1].MaxGapSeconds)
+	assert.InDelta(t, 7*24*60*60, *results[1].MaxGapSeconds, 1)
+
+	// A scheduled sector without events is still listed
+	assert.Equal(t, uint(3), results[2].SectorID)
+	assert.Zero(t, results[2].EventCount)
+	assert.Nil(t, results[2].FirstStartUnix)
+	assert.Nil(t, results[2].MaxGapSeconds)
+}
+
+func TestGetFirstEventTimeForFarm(t *testing.T) {
+	db := setupTestDB(t)
+	seedBasicData(t, db)
+
+	repo := NewAnalyticsRepository(db)
+	ctx := context.Background()
+
+	first, err := repo.GetFirstEventTimeForFarm(ctx, 1)
+	require.NoError(t, err)
+	require.NotNil(t, first)
+	assert.WithinDuration(t, time.Date(2024, 3, 1, 6, 0, 0, 0, time.UTC), *first, time.Millisecond)
+
+	first, err = repo.GetFirstEventTimeForFarm(ctx, 99)
+	require.NoError(t, err)
+	assert.Nil(t, first)
+}
+
+func TestGetDataQualityForFarm(t *testing.T) {
+	db := setupTestDB(t)
+	seedBasicData(t, db)
+
+	// One zero-nominal event, one over-irrigation and a copy of the first seeded event
+	require.NoError(t, db.Create(&[]model.IrrigationData{
+		{FarmID: 1, IrrigationSectorID: 1, StartTime: time.Date(2024, 3, 4, 6, 0, 0, 0, time.UTC), EndTime: time.Date(2024, 3, 4, 7, 0, 0, 0, time.UTC), NominalAmount: 0, RealAmount: 5},
+		{FarmID: 1, IrrigationSectorID: 1, StartTime: time.Date(2024, 3, 4, 18, 0, 0, 0, time.UTC), EndTime: time.Date(2024, 3, 4, 19, 0, 0, 0, time.UTC), NominalAmount: 10, RealAmount: 12},
+		{FarmID: 1, IrrigationSectorID: 1, StartTime: time.Date(2024, 3, 1, 6, 0, 0, 0, time.UTC), EndTime: time.Date(2024, 3, 1, 7, 0, 0, 0, time.UTC), NominalAmount: 20, RealAmount: 18},
+	}).Error)
+
+	repo := NewAnalyticsRepository(db)
+	ctx := context.Background()
+
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 5, 23, 59, 59, 0, time.UTC)
+
+	quality, err := repo.GetDataQualityForFarm(ctx, 1, start, end)
+	require.NoError(t, err)
+	assert.Equal(t, 6, quality.EventCount)
+	assert.Equal(t, 1, quality.ZeroNominalCount)
+	assert.Equal(t, 1, quality.OverIrrigationCount)
+	assert.Equal(t, 1, quality.DuplicateSuspectCount)
+	assert.Equal(t, 3, quality.DaysWithData)
+
+	empty, err := repo.GetDataQualityForFarm(ctx, 99, start, end)
+	require.NoError(t, err)
+	assert.Equal(t, DataQualityData{}, empty)
+}
+
+func TestGetEfficiencyByAmount(t *testing.T) {
+	db := setupTestDB(t)
+	require.NoError(t, db.Create(&model.Farm{ID: 1, Name: "Farm A"}).Error)
+	require.NoError(t, db.Create(&model.IrrigationSector{ID: 1, FarmID: 1, Name: "Sector A"}).Error)
+
+	// Real amounts 10..100 mm with efficiency 1 / (1 + real/100), falling from 0.91 to 0.5
+	var events []model.IrrigationData
+	for i := 1; i <= 10; i++ {
+		amount := float64(10 * i)
+		start := time.Date(2024, 3, i, 6, 0, 0, 0, time.UTC)
+		events = append(events, model.IrrigationData{
+			FarmID: 1, IrrigationSectorID: 1, StartTime: start, EndTime: start.Add(time.Hour),
+			RealAmount: amount, NominalAmount: amount + amount*amount/100,
+		})
+	}
+	require.NoError(t, db.Create(&events).Error)
+
+	repo := NewAnalyticsRepository(db)
+	ctx := context.Background()
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 31, 23, 59, 59, 0, time.UTC)
+
+	bins, err := repo.GetEfficiencyByAmount(ctx, 1, start, end, 5)
+	require.NoError(t, err)
+	require.Len(t, bins, 5)
+
+	// Five 18 mm wide bins over 10..100, two events each; the largest amount lands in the last bin
+	for i, bin := range bins {
+		assert.Equal(t, i+1, bin.Bin)
+		assert.InDelta(t, 10+18*float64(i), bin.MinRealAmount, 0.001)
+		assert.InDelta(t, 28+18*float64(i), bin.MaxRealAmount, 0.001)
+		assert.Equal(t, 2, bin.EventCount, "bin %d", bin.Bin)
+		assert.InDelta(t, 15+20*float64(i), bin.AvgRealAmount, 0.001)
+		require.NotNil(t, bin.AvgEfficiency)
+		if i > 0 {
+			assert.Less(t, *bin.AvgEfficiency, *bins[i-1].AvgEfficiency, "efficiency must fall as amounts grow")
+		}
+	}
+	assert.InDelta(t, (1/1.1+1/1.2)/2, *bins[0].AvgEfficiency, 0.0001)
+	assert.InDelta(t, (1/1.9+1/2.0)/2, *bins[4].AvgEfficiency, 0.0001)
+
+	// More bins than events leaves empty bins without an efficiency
+	sparse, err := repo.GetEfficiencyByAmount(ctx, 1, start, end, 20)
+	require.NoError(t, err)
+	require.Len(t, sparse, 20)
+	assert.Equal(t, 1, sparse[0].EventCount)
+	assert.Zero(t, sparse[1].EventCount)
+	assert.Nil(t, sparse[1].AvgEfficiency)
+	assert.Equal(t, 1, sparse[19].EventCount)
+
+	// A range whose events share one amount is a single bin
+	single, err := repo.GetEfficiencyByAmount(ctx, 1, start, time.Date(2024, 3, 1, 23, 59, 59, 0, time.UTC), 5)
+	require.NoError(t, err)
+	require.Len(t, single, 1)
+	assert.Equal(t, 1, single[0].EventCount)
+	assert.InDelta(t, 10.0, single[0].MinRealAmount, 0.001)
+	assert.InDelta(t, 10.0, single[0].MaxRealAmount, 0.001)
+
+	empty, err := repo.GetEfficiencyByAmount(ctx, 99, start, end, 5)
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+}
+
+func TestGetDayOfWeekDistribution(t *testing.T) {
+	db := setupTestDB(t)
+	seedBasicData(t, db)
+
+	// Seeded events fall on Friday 2024-03-01 (two) and Saturday 2024-03-02; add Sunday and the next Friday
+	require.NoError(t, db.Create(&[]model.IrrigationData{
+		{FarmID: 1, IrrigationSectorID: 1, StartTime: time.Date(2024, 3, 3, 6, 0, 0, 0, time.UTC), EndTime: time.Date(2024, 3, 3, 7, 0, 0, 0, time.UTC), NominalAmount: 10, RealAmount: 9},
+		{FarmID: 1, IrrigationSectorID: 1, StartTime: time.Date(2024, 3, 8, 6, 0, 0, 0, time.UTC), EndTime: time.Date(2024, 3, 8, 7, 0, 0, 0, time.UTC), NominalAmount: 5, RealAmount: 4},
+	}).Error)
+
+	repo := NewAnalyticsRepository(db)
+	ctx := context.Background()
+
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 31, 23, 59, 59, 0, time.UTC)
+
+	days, err := repo.GetDayOfWeekDistribution(ctx, 1, start, end)
+	require.NoError(t, err)
+	require.Len(t, days, 7)
+	for day, data := range days {
+		assert.Equal(t, day, data.DayOfWeek)
+	}
+
+	assert.Equal(t, DayOfWeekData{DayOfWeek: 0, TotalRealAmount: 9, TotalNominalAmount: 10, EventCount: 1}, days[time.Sunday])
+	assert.Equal(t, DayOfWeekData{DayOfWeek: 5, TotalRealAmount: 34, TotalNominalAmount: 40, EventCount: 3}, days[time.Friday])
+	assert.Equal(t, DayOfWeekData{DayOfWeek: 6, TotalRealAmount: 20, TotalNominalAmount: 25, EventCount: 1}, days[time.Saturday])
+	assert.Equal(t, DayOfWeekData{DayOfWeek: 3}, days[time.Wednesday])
+
+	empty, err := repo.GetDayOfWeekDistribution(ctx, 99, start, end)
+	require.NoError(t, err)
+	require.Len(t, empty, 7)
+	assert.Zero(t, empty[time.Friday].EventCount)
+}
+
+func TestGetAnalyticsForFarmByDateRange_MaxBuckets(t *testing.T) {
+	db := setupTestDB(t)
+	seedBasicData(t, db)
+
+	// Seeded events cover March 1 (two events) and 2; add March 3 and 4 for four daily buckets of five events
+	require.NoError(t, db.Create(&[]model.IrrigationData{
+		{FarmID: 1, IrrigationSectorID: 1, StartTime: time.Date(2024, 3, 3, 6, 0, 0, 0, time.UTC), EndTime: time.Date(2024, 3, 3, 7, 0, 0, 0, time.UTC), NominalAmount: 10, RealAmount: 9},
+		{FarmID: 1, IrrigationSectorID: 1, StartTime: time.Date(2024, 3, 4, 6, 0, 0, 0, time.UTC), EndTime: time.Date(2024, 3, 4, 7, 0, 0, 0, time.UTC), NominalAmount: 10, RealAmount: 8},
+	}).Error)
+
+	ctx := context.Background()
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 31, 23, 59, 59, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		maxBuckets    int
+		limit, offset int
+		periods       []string
+		total         int64
+		truncated     bool
+	}{
+		{name: "no cap", maxBuckets: 0, limit: 50, periods: []string{"2024-03-01", "2024-03-02", "2024-03-03", "2024-03-04"}, total: 4},
+		{name: "cap above the data", maxBuckets: 10, limit: 50, periods: []string{"2024-03-01", "2024-03-02", "2024-03-03", "2024-03-04"}, total: 4},
+		{name: "cap exactly at the data", maxBuckets: 4, limit: 50, periods: []string{"2024-03-01", "2024-03-02", "2024-03-03", "2024-03-04"}, total: 4},
+		{name: "cap cuts the page", maxBuckets: 2, limit: 50, periods: []string{"2024-03-01", "2024-03-02"}, total: 2, truncated: true},
+		{name: "page within the cap", maxBuckets: 2, limit: 2, periods: []string{"2024-03-01", "2024-03-02"}, total: 2},
+		{name: "page past the cap", maxBuckets: 2, limit: 2, offset: 2, periods: nil, total: 2, truncated: true},
+		// Three buckets fit the cap: two pages of two, the last one cut short
+		{name: "last page within the cap", maxBuckets: 3, limit: 2, offset: 2, periods: []string{"2024-03-03"}, total: 3, truncated: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := NewAnalyticsRepository(db).WithMaxBuckets(tt.maxBuckets)
+
+			results, total, truncated, err := repo.GetAnalyticsForFarmByDateRange(ctx, 1, start, end, model.AggregationDaily, tt.limit, tt.offset)
+			require.NoError(t, err)
+			assert.Equal(t, tt.total, total)
+			assert.Equal(t, tt.truncated, truncated)
+
+			var periods []string
+			for _, result := range results {
+				periods = append(periods, result.Period)
+			}
+			assert.Equal(t, tt.periods, periods)
+		})
+	}
+}
+
+func TestRealAmountRange(t *testing.T) {
+	db := setupTestDB(t)
+	// Seeded real amounts: 18 and 12 on March 1, 20 on March 2
+	seedBasicData(t, db)
+	require.NoError(t, db.Create(&model.IrrigationData{
+		FarmID: 1, IrrigationSectorID: 1, StartTime: time.Date(2024, 3, 2, 18, 0, 0, 0, time.UTC), EndTime: time.Date(2024, 3, 2, 19, 0, 0, 0, time.UTC),
+		NominalAmount: 10, RealAmount: 5,
+	}).Error)
+
+	repo := NewAnalyticsRepository(db)
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 31, 23, 59, 59, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		rng      RealAmountRange
+		events   int
+		real     float64
+		perDay   map[string]int
+		topDay   string
+		topTotal float64
+	}{
+		{name: "no bounds", rng: RealAmountRange{}, events: 4, real: 55, perDay: map[string]int{"2024-03-01": 2, "2024-03-02": 2}, topDay: "2024-03-01", topTotal: 30},
+		{name: "minimum only", rng: RealAmountRange{Min: floatPtr(12)}, events: 3, real: 50, perDay: map[string]int{"2024-03-01": 2, "2024-03-02": 1}, topDay: "2024-03-01", topTotal: 30},
+		{name: "maximum only", rng: RealAmountRange{Max: floatPtr(18)}, events: 3, real: 35, perDay: map[string]int{"2024-03-01": 2, "2024-03-02": 1}, topDay: "2024-03-01", topTotal: 30},
+		{name: "both bounds", rng: RealAmountRange{Min: floatPtr(15), Max: floatPtr(20)}, events: 2, real: 38, perDay: map[string]int{"2024-03-01": 1, "2024-03-02": 1}, topDay: "2024-03-02", topTotal: 20},
+		{name: "nothing in range", rng: RealAmountRange{Min: floatPtr(21)}, events: 0, real: 0, perDay: map[string]int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := WithRealAmountRange(context.Background(), tt.rng)
+
+			series, _, _, err := repo.GetAnalyticsForFarmByDateRange(ctx, 1, start, end, model.AggregationDaily, 50, 0)
+			require.NoError(t, err)
+			perDay := map[string]int{}
+			var real float64
+			for _, bucket := range series {
+				perDay[bucket.Period] = bucket.EventCount
+				real += bucket.TotalRealAmount
+			}
+			assert.Equal(t, tt.perDay, perDay)
+			assert.InDelta(t, tt.real, real, 0.001)
+
+			sectors, _, err := repo.GetSectorBreakdownForFarm(ctx, 1, nil, start, end, 0, 0)
+			require.NoError(t, err)
+			var sectorEvents int
+			for _, sector := range sectors {
+				sectorEvents += sector.EventCount
+			}
+			assert.Equal(t, tt.events, sectorEvents)
+
+			quality, err := repo.GetDataQualityForFarm(ctx, 1, start, end)
+			require.NoError(t, err)
+			assert.Equal(t, tt.events, quality.EventCount)
+
+			top, err := repo.GetTopIrrigationDays(ctx, 1, start, end, 1)
+			require.NoError(t, err)
+			if tt.events == 0 {
+				assert.Empty(t, top)
+				return
+			}
+			require.Len(t, top, 1)
+			assert.Equal(t, tt.topDay, top[0].Day)
+			assert.InDelta(t, tt.topTotal, top[0].TotalRealAmount, 0.001)
+		})
+	}
+}
+
+func TestRealAmountRange_YoY(t *testing.T) {
+	db := setupTestDB(t)
+	// Every :memory: connection is a separate database, so the concurrent queries must share one
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+
+	year := time.Now().Year()
+	require.NoError(t, db.Create(&model.Farm{ID: 1, Name: "Farm A"}).Error)
+	require.NoError(t, db.Create(&model.IrrigationSector{ID: 1, FarmID: 1, Name: "Sector A"}).Error)
+	var events []model.IrrigationData
+	for i, y := range []int{year, year, year - 1, year - 1} {
+		start := time.Date(y, 3, 1+i, 6, 0, 0, 0, time.UTC)
+		events = append(events, model.IrrigationData{
+			FarmID: 1, IrrigationSectorID: 1, StartTime: start, EndTime: start.Add(time.Hour),
+			NominalAmount: 30, RealAmount: float64(5 + 10*(i%2)),
+		})
+	}
+	require.NoError(t, db.Create(&events).Error)
+
+	ctx := WithRealAmountRange(context.Background(), RealAmountRange{Min: floatPtr(10)})
+	start := time.Date(year, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(year, 3, 31, 23, 59, 59, 0, time.UTC)
+
+	// Both query strategies bind the bound after each year's range
+	for _, parallel := range []bool{false, true} {
+		yoy, err := NewAnalyticsRepository(db).WithParallelYoY(parallel).GetYoYComparison(ctx, 1, start, end, model.AggregationDaily)
+		require.NoError(t, err)
+		require.Len(t, yoy, 2)
+		assert.Equal(t, 1, yoy[year].EventCount)
+		assert.InDelta(t, 15, yoy[year].TotalRealAmount, 0.001)
+		assert.Equal(t, 1, yoy[year-1].EventCount)
+	}
+}
+
+func floatPtr(v float64) *float64 { return &v }
+
+func TestGetAnalyticsForFarmByDateRange_Aggregations(t *testing.T) {
+	db := setupTestDB(t)
+	// March 1 (Friday) and 2 (Saturday) 2024, plus Monday March 4 in the next week
+	seedBasicData(t, db)
+	require.NoError(t, db.Create(&model.IrrigationData{
+		FarmID: 1, IrrigationSectorID: 1, StartTime: time.Date(2024, 3, 4, 6, 0, 0, 0, time.UTC), EndTime: time.Date(2024, 3, 4, 7, 0, 0, 0, time.UTC),
+		NominalAmount: 10, RealAmount: 5,
+	}).Error)
+
+	repo := NewAnalyticsRepository(db)
+	ctx := context.Background()
+	start := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 31, 23, 59, 59, 0, time.UTC)
+
+	tests := []struct {
+		aggregation model.Aggregation
+		periods     []string
+		events      []int
+		real        []float64
+	}{
+		{model.AggregationDaily, []string{"2024-03-01", "2024-03-02", "2024-03-04"}, []int{2, 1, 1}, []float64{30, 20, 5}},
+		{model.AggregationWeekly, []string{"2024-02-26", "2024-03-04"}, []int{3, 1}, []float64{50, 5}},
+		{model.AggregationMonthly, []string{"2024-03-01"}, []int{4}, []float64{55}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.aggregation), func(t *testing.T) {
+			results, total, truncated, err := repo.GetAnalyticsForFarmByDateRange(ctx, 1, start, end, tt.aggregation, 50, 0)
+			require.NoError(t, err)
+			// The total counts buckets, not the four events in them
+			assert.Equal(t, int64(len(tt.periods)), total)
+			assert.False(t, truncated)
+			require.Len(t, results, len(tt.periods))
+
+			for i, result := range results {
+				assert.Equal(t, tt.periods[i], result.Period)
+				assert.Equal(t, 2024, result.Year)
+				assert.Equal(t, tt.events[i], result.EventCount)
+				assert.InDelta(t, tt.real[i], result.TotalRealAmount, 0.001)
+				require.NotNil(t, result.AvgEfficiency)
+				require.NotNil(t, result.MinEfficiency)
+				require.NotNil(t, result.MaxEfficiency)
+				assert.LessOrEqual(t, *result.MinEfficiency, *result.AvgEfficiency)
+				assert.LessOrEqual(t, *result.AvgEfficiency, *result.MaxEfficiency)
+			}
+		})
+	}
+
+	// The week of March 1 holds efficiencies 0.9, 0.8 and 0.8
+	weekly, _, _, err := repo.GetAnalyticsForFarmByDateRange(ctx, 1, start, end, model.AggregationWeekly, 1, 0)
+	require.NoError(t, err)
+	require.Len(t, weekly, 1)
+	assert.InDelta(t, (0.9+0.8+0.8)/3, *weekly[0].AvgEfficiency, 0.001)
+	assert.InDelta(t, 0.8, *weekly[0].MinEfficiency, 0.001)
+	assert.InDelta(t, 0.9, *weekly[0].MaxEfficiency, 0.001)
+}
+
+func TestGetAnalyticsForFarmByDateRange_StdDevEfficiency(t *testing.T) {
+	db := setupTestDB(t)
+	// March 1 holds efficiencies 0.9 and 0.8, March 2 holds 0.8 plus an event without nominal amount
+	seedBasicData(t, db)
+	require.NoError(t, db.Create(&model.IrrigationData{
+		FarmID: 1, IrrigationSectorID: 1, StartTime: time.Date(2024, 3, 2, 18, 0, 0, 0, time.UTC), EndTime: time.Date(2024, 3, 2, 19, 0, 0, 0, time.UTC),
+		NominalAmount: 0, RealAmount: 5,
+	}).Error)
+
+	ctx := context.Background()
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 31, 23, 59, 59, 0, time.UTC)
+
+	daily, _, _, err := NewAnalyticsRepository(db).GetAnalyticsForFarmByDateRange(ctx, 1, start, end, model.AggregationDaily, 50, 0)
+	require.NoError(t, err)
+	require.Len(t, daily, 2)
+	assert.Equal(t, 2, daily[0].EfficiencyCount)
+	require.NotNil(t, daily[0].StdDevEfficiency)
+	assert.InDelta(t, 0.05, *daily[0].StdDevEfficiency, 0.0001)
+	// A single valid sample has no spread to report
+	assert.Equal(t, 1, daily[1].EfficiencyCount)
+	assert.Nil(t, daily[1].StdDevEfficiency)
+
+	// Under the zero policy March 2 holds 0.8 and 0
+	zero := NewAnalyticsRepository(db).WithZeroNominalPolicy(model.ZeroNominalZero)
+	daily, _, _, err = zero.GetAnalyticsForFarmByDateRange(ctx, 1, start, end, model.AggregationDaily, 50, 0)
+	require.NoError(t, err)
+	require.Len(t, daily, 2)
+	assert.Equal(t, 2, daily[1].EfficiencyCount)
+	require.NotNil(t, daily[1].StdDevEfficiency)
+	assert.InDelta(t, 0.4, *daily[1].StdDevEfficiency, 0.0001)
+
+	monthly, _, _, err := NewAnalyticsRepository(db).GetAnalyticsForFarmByDateRange(ctx, 1, start, end, model.AggregationMonthly, 50, 0)
+	require.NoError(t, err)
+	require.Len(t, monthly, 1)
+	assert.Equal(t, 3, monthly[0].EfficiencyCount)
+	require.NotNil(t, monthly[0].StdDevEfficiency)
+	// Population variance of 0.9, 0.8 and 0.8 is 0.02/9
+	assert.InDelta(t, math.Sqrt(0.02/9), *monthly[0].StdDevEfficiency, 0.0001)
+}
+
+func TestGetAnalyticsForFarmByDateRange_ExactSums(t *testing.T) {
+	db := setupTestDB(t)
+	require.NoError(t, db.Create(&model.Farm{ID: 1, Name: "Farm A"}).Error)
+	require.NoError(t, db.Create(&model.IrrigationSector{ID: 1, FarmID: 1, Name: "Sector A"}).Error)
+
+	// A thousand events of 0.1 mm delivered out of 0.3 mm: exactly 100 and 300 mm
+	events := make([]model.IrrigationData, 1000)
+	var naiveReal, naiveNominal float64
+	for i := range events {
+		start := time.Date(2024, 3, 1, 0, 0, i, 0, time.UTC)
+		events[i] = model.IrrigationData{FarmID: 1, IrrigationSectorID: 1, StartTime: start, EndTime: start.Add(time.Second), NominalAmount: 0.3, RealAmount: 0.1}
+		naiveReal += events[i].RealAmount
+		naiveNominal += events[i].NominalAmount
+	}
+	require.NoError(t, db.CreateInBatches(&events, 200).Error)
+	// Adding the values as float64 drifts away from the exact totals
+	require.NotEqual(t, 100.0, naiveReal)
+	require.NotEqual(t, 300.0, naiveNominal)
+
+	ctx := context.Background()
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 1, 23, 59, 59, 0, time.UTC)
+
+	results, _, _, err := NewAnalyticsRepository(db).GetAnalyticsForFarmByDateRange(ctx, 1, start, end, model.AggregationDaily, 50, 0)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, 100.0, results[0].TotalRealAmount)
+	assert.Equal(t, 300.0, results[0].TotalNominalAmount)
+
+	sectors, _, err := NewAnalyticsRepository(db).GetSectorBreakdownForFarm(ctx, 1, nil, start, end, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, sectors, 1)
+	assert.Equal(t, 100.0, sectors[0].TotalRealAmount)
+
+	yoy, err := NewAnalyticsRepository(db).GetYoYComparison(ctx, 1, start, end, model.AggregationDaily)
+	require.NoError(t, err)
+	require.Contains(t, yoy, 2024)
+	assert.Equal(t, 300.0, yoy[2024].TotalNominalAmount)
+
+	// The aggregate endpoints and the daily summaries sum amounts the same way
+	dataRepo := NewIrrigationDataRepository(db)
+	farms, err := dataRepo.AggregateByFarm(ctx, start, end)
+	require.NoError(t, err)
+	require.Len(t, farms, 1)
+	assert.Equal(t, 100.0, farms[0].TotalRealAmount)
+	bySector, err := dataRepo.AggregateBySector(ctx, start, end)
+	require.NoError(t, err)
+	require.Len(t, bySector, 1)
+	assert.Equal(t, 300.0, bySector[0].TotalNominalAmount)
+	sector, err := dataRepo.AggregateForSector(ctx, 1, start, end)
+	require.NoError(t, err)
+	assert.Equal(t, 100.0, sector.TotalRealAmount)
+
+	summaryRepo := NewDailySummaryRepository(db)
+	_, err = summaryRepo.RebuildSummaries(ctx, 1, start, start)
+	require.NoError(t, err)
+	summaries, err := summaryRepo.FindByFarmAndDayRange(ctx, 1, start, start)
+	require.NoError(t, err)
+	require.Len(t, summaries, 1)
+	assert.Equal(t, 100.0, summaries[0].TotalRealAmount)
+	assert.Equal(t, 300.0, summaries[0].TotalNominalAmount)
+}
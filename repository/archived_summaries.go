@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sebaespinosa/test_NF/model"
+)
+
+// Archived days live in irrigation_daily_summaries once retention archival has deleted their raw events
+// (see DailySummaryRepository.ArchiveDay); the farm time-series and YoY queries add them back so totals,
+// event counts and average efficiency cover the whole history. Summaries hold no per-event values, so
+// minimum, maximum and standard deviation of efficiency only cover the events still stored, and a
+// real amount range (WithRealAmountRange) leaves archived days out since it cannot be applied to them.
+// Rebuilt summaries repeat raw events that still exist and are never added.
+
+// archivedAggregation is the archived share of one time-series bucket, or of one year for YoY
+type archivedAggregation struct {
+	Period             string  `gorm:"column:period"`
+	Year               int     `gorm:"column:year"`
+	TotalRealAmount    float64 `gorm:"column:total_real_amount"`
+	TotalNominalAmount float64 `gorm:"column:total_nominal_amount"`
+	EventCount         int     `gorm:"column:event_count"`
+	EfficiencySum      float64 `gorm:"column:efficiency_sum"`
+	EfficiencyCount    int     `gorm:"column:efficiency_count"`
+}
+
+// archivedColumns selects the summed archived totals; under model.ZeroNominalZero every archived event
+// counts towards efficiency, contributing 0 when it had no positive nominal amount
+func (r *AnalyticsRepository) archivedColumns() string {
+	efficiencyCount := "SUM(efficiency_event_count)"
+	if r.zeroNominalPolicy == model.ZeroNominalZero {
+		efficiencyCount = "SUM(event_count)"
+	}
+	return `
+		` + r.sumExpr("total_real_amount") + ` as total_real_amount,
+		` + r.sumExpr("total_nominal_amount") + ` as total_nominal_amount,
+		SUM(event_count) as event_count,
+		SUM(efficiency_sum) as efficiency_sum,
+		` + efficiencyCount + ` as efficiency_count
+	`
+}
+
+// includesArchived reports whether the context's queries can add archived days
+func includesArchived(ctx context.Context) bool {
+	condition, _ := realAmountCondition(ctx, "")
+	return condition == ""
+}
+
+// getArchivedBuckets sums the farm's archived summaries per time-series bucket, for the days whose
+// midnight falls in [startTime, endTime]
+func (r *AnalyticsRepository) getArchivedBuckets(
+	ctx context.Context,
+	farmID uint,
+	startTime, endTime time.Time,
+	aggregation model.Aggregation,
+) ([]archivedAggregation, error) {
+	if !includesArchived(ctx) {
+		return nil, nil
+	}
+
+	periodExpr := r.dialect.TruncExpr(aggregation, "day")
+	query := r.conn(ctx).
+		Model(&model.IrrigationDailySummary{}).
+		Select(periodExpr+" as period, "+r.dialect.ExtractYear("day")+" as year,"+r.archivedColumns()).
+		Where("farm_id = ? AND day >= ? AND day <= ? AND rebuilt = ?", farmID, startTime, endTime, false)
+
+	var rows []archivedAggregation
+	if err := query.Group(periodExpr + ", year").Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to get archived analytics for farm: %w", err)
+	}
+	return rows, nil
+}
+
+// getArchivedYears sums the farm's archived summaries per year over the YoY ranges
+func (r *AnalyticsRepository) getArchivedYears(ctx context.Context, farmID uint, ranges [][2]time.Time) ([]archivedAggregation, error) {
+	if !includesArchived(ctx) {
+		return nil, nil
+	}
+
+	conditions := make([]string, 0, len(ranges))
+	args := make([]any, 0, 2*len(ranges))
+	for _, yearRange := range ranges {
+		conditions = append(conditions, "(day >= ? AND day <= ?)")
+		args = append(args, yearRange[0], yearRange[1])
+	}
+
+	yearExpr := r.dialect.ExtractYear("day")
+	var rows []archivedAggregation
+	if err := r.conn(ctx).
+		Model(&model.IrrigationDailySummary{}).
+		Select(yearExpr+" as year,"+r.archivedColumns()).
+		Where("farm_id = ? AND rebuilt = ?", farmID, false).
+		Where("("+strings.Join(conditions, " OR ")+")", args...).
+		Group(yearExpr).
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to get archived YoY data: %w", err)
+	}
+	return rows, nil
+}
+
+// mergeArchivedBuckets adds the archived share of each bucket to raw, the farm's stored buckets,
+// and returns every bucket ordered by period
+func (r *AnalyticsRepository) mergeArchivedBuckets(raw []AnalyticsAggregation, archived []archivedAggregation) []AnalyticsAggregation {
+	type bucketKey struct {
+		period string
+		year   int
+	}
+	index := make(map[bucketKey]int, len(raw))
+	for i, bucket := range raw {
+		index[bucketKey{bucket.Period, bucket.Year}] = i
+	}
+
+	for _, share := range archived {
+		i, ok := index[bucketKey{share.Period, share.Year}]
+		if !ok {
+			raw = append(raw, AnalyticsAggregation{Period: share.Period, Year: share.Year})
+			i = len(raw) - 1
+		}
+		bucket := &raw[i]
+		bucket.TotalRealAmount = r.addAmounts(bucket.TotalRealAmount, share.TotalRealAmount)
+		bucket.TotalNominalAmount = r.addAmounts(bucket.TotalNominalAmount, share.TotalNominalAmount)
+		bucket.EventCount += share.EventCount
+		bucket.AvgEfficiency = mergeAverage(bucket.AvgEfficiency, bucket.EfficiencyCount, share)
+		bucket.EfficiencyCount += share.EfficiencyCount
+	}
+
+	slices.SortFunc(raw, func(a, b AnalyticsAggregation) int {
+		return cmp.Or(strings.Compare(a.Period, b.Period), cmp.Compare(a.Year, b.Year))
+	})
+	return raw
+}
+
+// mergeArchivedYears adds the archived share of each year to the YoY results
+func (r *AnalyticsRepository) mergeArchivedYears(results map[int]YoYAnalyticsData, archived []archivedAggregation) {
+	for _, share := range archived {
+		year := results[share.Year]
+		year.Year = share.Year
+		year.TotalRealAmount = r.addAmounts(year.TotalRealAmount, share.TotalRealAmount)
+		year.TotalNominalAmount = r.addAmounts(year.TotalNominalAmount, share.TotalNominalAmount)
+		year.EventCount += share.EventCount
+		year.AvgEfficiency = mergeAverage(year.AvgEfficiency, year.EfficiencyCount, share)
+		year.EfficiencyCount += share.EfficiencyCount
+		results[share.Year] = year
+	}
+}
+
+// addAmounts adds an archived total to a stored one, rounded to the columns' two decimals like ExactSum
+func (r *AnalyticsRepository) addAmounts(stored, archived float64) float64 {
+	if !r.exactSums {
+		return stored + archived
+	}
+	return math.Round((stored+archived)*100) / 100
+}
+
+// mergeAverage weights the stored average efficiency over count events with the archived share's
+func mergeAverage(average *float64, count int, share archivedAggregation) *float64 {
+	total := count + share.EfficiencyCount
+	if total == 0 {
+		return average
+	}
+	sum := share.EfficiencySum
+	if average != nil {
+		sum += *average * float64(count)
+	}
+	merged := sum / float64(total)
+	return &merged
+}
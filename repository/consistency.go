@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/sebaespinosa/test_NF/model"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+type consistencyKey struct{}
+
+// WithConsistency returns a context whose analytics queries read from the primary when consistency is
+// ConsistencyStrong, even if a replica is configured; any other value leaves reads on the replica
+func WithConsistency(ctx context.Context, consistency model.Consistency) context.Context {
+	return context.WithValue(ctx, consistencyKey{}, consistency)
+}
+
+// conn returns the handle for ctx's queries, pinned to the primary for strong consistency
+// Without a replica configured, both resolve to the same database
+func (r *AnalyticsRepository) conn(ctx context.Context) *gorm.DB {
+	db := r.withSQLLogging(r.db.WithContext(ctx))
+	if consistency, _ := ctx.Value(consistencyKey{}).(model.Consistency); consistency == model.ConsistencyStrong {
+		db = db.Clauses(dbresolver.Write)
+	}
+	return db
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sebaespinosa/test_NF/config"
+	"github.com/sebaespinosa/test_NF/internal/database"
+	"github.com/sebaespinosa/test_NF/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestAnalyticsRepository_Consistency(t *testing.T) {
+	// The replica lags: it has the farm and sector but none of the primary's events yet
+	replicaDSN := "file:" + t.Name() + "?mode=memory&cache=shared"
+	replica, err := gorm.Open(sqlite.Open(replicaDSN), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, replica.AutoMigrate(&model.Farm{}, &model.IrrigationSector{}, &model.IrrigationData{}, &model.IrrigationDailySummary{}))
+	require.NoError(t, replica.Create(&model.Farm{ID: 1, Name: "Farm A"}).Error)
+
+	primary := setupTestDB(t)
+	seedBasicData(t, primary)
+	primaryPool, err := primary.DB()
+	require.NoError(t, err)
+	analyticsDB, err := database.UseReplica(sqlite.Dialector{Conn: primaryPool}, sqlite.Open(replicaDSN), &config.DatabaseConfig{MaxOpenConns: 1, MaxIdleConns: 1, ConnMaxLifetime: time.Minute})
+	require.NoError(t, err)
+
+	repo := NewAnalyticsRepository(analyticsDB)
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 31, 23, 59, 59, 0, time.UTC)
+
+	// Only the analytics handle routes to the replica; other repositories keep reading the primary
+	events, err := NewIrrigationDataRepository(primary).FindByFarmIDAndTimeRange(context.Background(), 1, start, end)
+	require.NoError(t, err)
+	assert.Len(t, events, 3)
+
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		wantBuckets int
+	}{
+		{name: "default reads the replica", ctx: context.Background(), wantBuckets: 0},
+		{name: "replica reads the replica", ctx: WithConsistency(context.Background(), model.ConsistencyReplica), wantBuckets: 0},
+		{name: "strong reads the primary", ctx: WithConsistency(context.Background(), model.ConsistencyStrong), wantBuckets: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, _, _, err := repo.GetAnalyticsForFarmByDateRange(tt.ctx, 1, start, end, model.AggregationDaily, 50, 0)
+			require.NoError(t, err)
+			assert.Len(t, results, tt.wantBuckets)
+
+			// Raw SQL follows the same routing
+			yoy, err := repo.GetYoYComparison(tt.ctx, 1, start, end, model.AggregationDaily)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantBuckets > 0, yoy[2024].EventCount > 0)
+		})
+	}
+}
@@ -0,0 +1,298 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sebaespinosa/test_NF/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DailySummaryRepository rolls raw irrigation data up into per-sector daily summaries
+// Days are UTC calendar days, identified by their midnight
+type DailySummaryRepository struct {
+	db        *gorm.DB
+	dialect   Dialect
+	exactSums bool
+}
+
+// NewDailySummaryRepository creates a new DailySummaryRepository instance
+// Amounts are summed exactly; see WithExactSums
+func NewDailySummaryRepository(db *gorm.DB) *DailySummaryRepository {
+	return &DailySummaryRepository{db: db, dialect: dialectFor(db), exactSums: true}
+}
+
+// WithExactSums returns a copy of the repository that, when exact is false, sums amounts with a plain SUM
+// instead of rounding the numeric sum to the columns' two decimals; see Dialect.ExactSum
+func (r *DailySummaryRepository) WithExactSums(exact bool) *DailySummaryRepository {
+	clone := *r
+	clone.exactSums = exact
+	return &clone
+}
+
+// ArchiveDayResult reports what archiving one day did
+type ArchiveDayResult struct {
+	Summaries  int
+	RawDeleted int64
+}
+
+// FindRawDaysBefore returns the UTC days, oldest first, that still have raw events starting before cutoff
+func (r *DailySummaryRepository) FindRawDaysBefore(ctx context.Context, cutoff time.Time) ([]time.Time, error) {
+	var keys []string
+
+	dayExpr := r.dialect.TruncExpr(model.AggregationDaily, "start_time")
+
+	if err := r.db.WithContext(ctx).
+		Model(&model.IrrigationData{}).
+		Where("start_time < ?", cutoff.UTC()).
+		Distinct(dayExpr).
+		Order(dayExpr+" ASC").
+		Pluck(dayExpr, &keys).Error; err != nil {
+		return nil, fmt.Errorf("failed to find raw irrigation days: %w", err)
+	}
+
+	days := make([]time.Time, 0, len(keys))
+	for _, key := range keys {
+		day, err := time.Parse("2006-01-02", key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse irrigation day %q: %w", key, err)
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+// rebuildChunkDays is how many days RebuildSummaries recomputes per transaction, keeping each lock short
+const rebuildChunkDays = 7
+
+// RebuildSummariesResult reports the progress of a summary rebuild
+type RebuildSummariesResult struct {
+	Days      int   // days recomputed
+	Chunks    int   // transactions committed
+	Summaries int   // summaries written
+	Events    int64 // raw events summarized
+}
+
+// dailySummaryColumns selects a sector's summary aggregates over the raw events in scope
+func (r *DailySummaryRepository) dailySummaryColumns() string {
+	return `
+		farm_id,
+		irrigation_sector_id,
+		` + sumAmountExpr(r.dialect, r.exactSums, "nominal_amount") + ` as total_nominal_amount,
+		` + sumAmountExpr(r.dialect, r.exactSums, "real_amount") + ` as total_real_amount,
+		COUNT(*) as event_count,
+		COALESCE(` + efficiencyAggExpr(r.dialect, model.ZeroNominalExclude, "SUM", "") + `, 0) as efficiency_sum,
+		SUM(CASE WHEN nominal_amount > 0 THEN 1 ELSE 0 END) as efficiency_event_count
+	`
+}
+
+// dailySummaryRow is one sector's aggregate for the day being archived
+type dailySummaryRow struct {
+	FarmID               uint    `gorm:"column:farm_id"`
+	IrrigationSectorID   uint    `gorm:"column:irrigation_sector_id"`
+	TotalNominalAmount   float64 `gorm:"column:total_nominal_amount"`
+	TotalRealAmount      float64 `gorm:"column:total_real_amount"`
+	EventCount           int     `gorm:"column:event_count"`
+	EfficiencySum        float64 `gorm:"column:efficiency_sum"`
+	EfficiencyEventCount int     `gorm:"column:efficiency_event_count"`
+}
+
+// dailySummaryDayRow is one sector's aggregate for a day of the chunk being rebuilt
+type dailySummaryDayRow struct {
+	DayKey               string  `gorm:"column:day_key"`
+	FarmID               uint    `gorm:"column:farm_id"`
+	IrrigationSectorID   uint    `gorm:"column:irrigation_sector_id"`
+	TotalNominalAmount   float64 `gorm:"column:total_nominal_amount"`
+	TotalRealAmount      float64 `gorm:"column:total_real_amount"`
+	EventCount           int     `gorm:"column:event_count"`
+	EfficiencySum        float64 `gorm:"column:efficiency_sum"`
+	EfficiencyEventCount int     `gorm:"column:efficiency_event_count"`
+}
+
+// ArchiveDay summarizes the raw events of one UTC day per sector, then deletes them, in a single transaction
+// A summary that already exists for a sector and day (late events archived earlier) is added to, not replaced,
+// so running the archival again never loses totals; a rebuilt summary only counted the same raw events, so it
+// is replaced instead. Either both steps commit or neither does
+func (r *DailySummaryRepository) ArchiveDay(ctx context.Context, day time.Time) (ArchiveDayResult, error) {
+	day = day.UTC()
+	dayStart := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	dayEnd := dayStart.AddDate(0, 0, 1)
+
+	var result ArchiveDayResult
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var rows []dailySummaryRow
+		if err := tx.Model(&model.IrrigationData{}).
+			Select(r.dailySummaryColumns()).
+			Where("start_time >= ? AND start_time < ?", dayStart, dayEnd).
+			Group("farm_id, irrigation_sector_id").
+			Scan(&rows).Error; err != nil {
+			return fmt.Errorf("failed to summarize irrigation data: %w", err)
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+
+		summaries := make([]model.IrrigationDailySummary, 0, len(rows))
+		for _, row := range rows {
+			summaries = append(summaries, model.IrrigationDailySummary{
+				FarmID:               row.FarmID,
+				IrrigationSectorID:   row.IrrigationSectorID,
+				Day:                  dayStart,
+				TotalNominalAmount:   row.TotalNominalAmount,
+				TotalRealAmount:      row.TotalRealAmount,
+				EventCount:           row.EventCount,
+				EfficiencySum:        row.EfficiencySum,
+				EfficiencyEventCount: row.EfficiencyEventCount,
+			})
+		}
+
+		accumulate := func(column string) clause.Expr {
+			return gorm.Expr(fmt.Sprintf(
+				"CASE WHEN irrigation_daily_summaries.rebuilt THEN excluded.%[1]s ELSE irrigation_daily_summaries.%[1]s + excluded.%[1]s END",
+				column,
+			))
+		}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "irrigation_sector_id"}, {Name: "day"}},
+			DoUpdates: clause.Assignments(map[string]any{
+				"total_nominal_amount":   accumulate("total_nominal_amount"),
+				"total_real_amount":      accumulate("total_real_amount"),
+				"event_count":            accumulate("event_count"),
+				"efficiency_sum":         accumulate("efficiency_sum"),
+				"efficiency_event_count": accumulate("efficiency_event_count"),
+				"rebuilt":                false,
+				"updated_at":             time.Now().UTC(),
+			}),
+		}).Create(&summaries).Error; err != nil {
+			return fmt.Errorf("failed to save daily summaries: %w", err)
+		}
+
+		deleted := tx.Where("start_time >= ? AND start_time < ?", dayStart, dayEnd).Delete(&model.IrrigationData{})
+		if deleted.Error != nil {
+			return fmt.Errorf("failed to delete archived irrigation data: %w", deleted.Error)
+		}
+
+		result = ArchiveDayResult{Summaries: len(summaries), RawDeleted: deleted.RowsAffected}
+		return nil
+	})
+	if err != nil {
+		return ArchiveDayResult{}, fmt.Errorf("failed to archive irrigation day %s: %w", dayStart.Format("2006-01-02"), err)
+	}
+	return result, nil
+}
+
+// FindByFarmAndDayRange returns a farm's daily summaries for days in [startDay, endDay], ordered by day then sector
+func (r *DailySummaryRepository) FindByFarmAndDayRange(ctx context.Context, farmID uint, startDay, endDay time.Time) ([]model.IrrigationDailySummary, error) {
+	var summaries []model.IrrigationDailySummary
+	if err := r.db.WithContext(ctx).
+		Where("farm_id = ? AND day >= ? AND day <= ?", farmID, startDay.UTC(), endDay.UTC()).
+		Order("day ASC, irrigation_sector_id ASC").
+		Find(&summaries).Error; err != nil {
+		return nil, fmt.Errorf("failed to find daily summaries: %w", err)
+	}
+	return summaries, nil
+}
+
+// RebuildSummaries recomputes a farm's daily summaries for the UTC days from startDay through endDay out of the
+// raw events still stored, rebuildChunkDays days per transaction so no lock is held for the whole range
+// Summaries holding archived events are left as they are, since their raw events are gone; archiving folds any
+// later raw events into them. On failure the result counts the chunks already committed
+func (r *DailySummaryRepository) RebuildSummaries(ctx context.Context, farmID uint, startDay, endDay time.Time) (RebuildSummariesResult, error) {
+	startDay, endDay = startDay.UTC(), endDay.UTC()
+	first := time.Date(startDay.Year(), startDay.Month(), startDay.Day(), 0, 0, 0, 0, time.UTC)
+	stop := time.Date(endDay.Year(), endDay.Month(), endDay.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1)
+
+	var result RebuildSummariesResult
+	for chunkStart := first; chunkStart.Before(stop); chunkStart = chunkStart.AddDate(0, 0, rebuildChunkDays) {
+		chunkEnd := chunkStart.AddDate(0, 0, rebuildChunkDays)
+		if chunkEnd.After(stop) {
+			chunkEnd = stop
+		}
+
+		summaries, events, err := r.rebuildChunk(ctx, farmID, chunkStart, chunkEnd)
+		if err != nil {
+			return result, fmt.Errorf("failed to rebuild daily summaries from %s: %w", chunkStart.Format("2006-01-02"), err)
+		}
+		result.Days += int(chunkEnd.Sub(chunkStart).Hours() / 24)
+		result.Chunks++
+		result.Summaries += summaries
+		result.Events += events
+	}
+	return result, nil
+}
+
+// rebuildChunk replaces the farm's rebuilt summaries for days in [chunkStart, chunkEnd) in one transaction
+// and returns how many summaries it wrote and how many raw events they cover
+func (r *DailySummaryRepository) rebuildChunk(ctx context.Context, farmID uint, chunkStart, chunkEnd time.Time) (int, int64, error) {
+	var written int
+	var events int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("farm_id = ? AND day >= ? AND day < ? AND rebuilt = ?", farmID, chunkStart, chunkEnd, true).
+			Delete(&model.IrrigationDailySummary{}).Error; err != nil {
+			return fmt.Errorf("failed to delete rebuilt summaries: %w", err)
+		}
+
+		var archived []model.IrrigationDailySummary
+		if err := tx.Select("irrigation_sector_id", "day").
+			Where("farm_id = ? AND day >= ? AND day < ?", farmID, chunkStart, chunkEnd).
+			Find(&archived).Error; err != nil {
+			return fmt.Errorf("failed to find archived summaries: %w", err)
+		}
+		type sectorDay struct {
+			sectorID uint
+			day      int64
+		}
+		keep := make(map[sectorDay]bool, len(archived))
+		for _, summary := range archived {
+			keep[sectorDay{summary.IrrigationSectorID, summary.Day.UTC().Unix()}] = true
+		}
+
+		dayExpr := r.dialect.TruncExpr(model.AggregationDaily, "start_time")
+		var rows []dailySummaryDayRow
+		if err := tx.Model(&model.IrrigationData{}).
+			Select(dayExpr+" as day_key,"+r.dailySummaryColumns()).
+			Where("farm_id = ? AND start_time >= ? AND start_time < ?", farmID, chunkStart, chunkEnd).
+			Group(dayExpr + ", farm_id, irrigation_sector_id").
+			Scan(&rows).Error; err != nil {
+			return fmt.Errorf("failed to summarize irrigation data: %w", err)
+		}
+
+		summaries := make([]model.IrrigationDailySummary, 0, len(rows))
+		for _, row := range rows {
+			day, err := time.Parse("2006-01-02", row.DayKey)
+			if err != nil {
+				return fmt.Errorf("failed to parse irrigation day %q: %w", row.DayKey, err)
+			}
+			if keep[sectorDay{row.IrrigationSectorID, day.Unix()}] {
+				continue
+			}
+			summaries = append(summaries, model.IrrigationDailySummary{
+				FarmID:               row.FarmID,
+				IrrigationSectorID:   row.IrrigationSectorID,
+				Day:                  day,
+				TotalNominalAmount:   row.TotalNominalAmount,
+				TotalRealAmount:      row.TotalRealAmount,
+				EventCount:           row.EventCount,
+				EfficiencySum:        row.EfficiencySum,
+				EfficiencyEventCount: row.EfficiencyEventCount,
+				Rebuilt:              true,
+			})
+			events += int64(row.EventCount)
+		}
+		if len(summaries) == 0 {
+			return nil
+		}
+
+		if err := tx.Create(&summaries).Error; err != nil {
+			return fmt.Errorf("failed to save daily summaries: %w", err)
+		}
+		written = len(summaries)
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return written, events, nil
+}
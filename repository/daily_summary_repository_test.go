@@ -0,0 +1,232 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sebaespinosa/test_NF/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchiveDay(t *testing.T) {
+	db := setupTestDB(t)
+	seedBasicData(t, db)
+
+	repo := NewDailySummaryRepository(db)
+	dataRepo := NewIrrigationDataRepository(db)
+	ctx := context.Background()
+
+	march1 := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	march2 := time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)
+
+	// Only March 1 has raw events before a March 2 cutoff
+	days, err := repo.FindRawDaysBefore(ctx, march2)
+	require.NoError(t, err)
+	assert.Equal(t, []time.Time{march1}, days)
+
+	result, err := repo.ArchiveDay(ctx, march1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Summaries)
+	assert.Equal(t, int64(2), result.RawDeleted)
+
+	summaries, err := repo.FindByFarmAndDayRange(ctx, 1, march1, march2)
+	require.NoError(t, err)
+	require.Len(t, summaries, 1)
+	assert.Equal(t, uint(1), summaries[0].IrrigationSectorID)
+	assert.True(t, march1.Equal(summaries[0].Day))
+	assert.InDelta(t, 35, summaries[0].TotalNominalAmount, 0.001)
+	assert.InDelta(t, 30, summaries[0].TotalRealAmount, 0.001)
+	assert.Equal(t, 2, summaries[0].EventCount)
+	assert.InDelta(t, 0.9+0.8, summaries[0].EfficiencySum, 0.001)
+	assert.Equal(t, 2, summaries[0].EfficiencyEventCount)
+
+	// The archived day's raw events are gone; March 2 is untouched
+	remaining, err := dataRepo.FindByFarmIDAndTimeRange(ctx, 1, march1, march2.Add(24*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, remaining, 1)
+	assert.Equal(t, time.Date(2024, 3, 2, 6, 0, 0, 0, time.UTC), remaining[0].StartTime.UTC())
+
+	days, err = repo.FindRawDaysBefore(ctx, march2)
+	require.NoError(t, err)
+	assert.Empty(t, days)
+}
+
+func TestArchiveDay_AddsToExistingSummary(t *testing.T) {
+	db := setupTestDB(t)
+	seedBasicData(t, db)
+
+	repo := NewDailySummaryRepository(db)
+	dataRepo := NewIrrigationDataRepository(db)
+	ctx := context.Background()
+
+	march1 := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	_, err := repo.ArchiveDay(ctx, march1)
+	require.NoError(t, err)
+
+	// A late event without a nominal amount arrives for the archived day
+	require.NoError(t, dataRepo.Create(ctx, &model.IrrigationData{
+		FarmID:             1,
+		IrrigationSectorID: 1,
+		StartTime:          time.Date(2024, 3, 1, 21, 0, 0, 0, time.UTC),
+		EndTime:            time.Date(2024, 3, 1, 22, 0, 0, 0, time.UTC),
+		NominalAmount:      0,
+		RealAmount:         4,
+	}))
+
+	result, err := repo.ArchiveDay(ctx, march1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), result.RawDeleted)
+
+	summaries, err := repo.FindByFarmAndDayRange(ctx, 1, march1, march1)
+	require.NoError(t, err)
+	require.Len(t, summaries, 1)
+	assert.Equal(t, 3, summaries[0].EventCount)
+	assert.InDelta(t, 34, summaries[0].TotalRealAmount, 0.001)
+	assert.InDelta(t, 0.9+0.8, summaries[0].EfficiencySum, 0.001)
+	assert.Equal(t, 2, summaries[0].EfficiencyEventCount)
+}
+
+func TestArchiveDay_NoEvents(t *testing.T) {
+	db := setupTestDB(t)
+	seedBasicData(t, db)
+
+	repo := NewDailySummaryRepository(db)
+	result, err := repo.ArchiveDay(context.Background(), time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Zero(t, result.Summaries)
+	assert.Zero(t, result.RawDeleted)
+}
+
+func TestRebuildSummaries(t *testing.T) {
+	db := setupTestDB(t)
+	seedBasicData(t, db)
+
+	repo := NewDailySummaryRepository(db)
+	dataRepo := NewIrrigationDataRepository(db)
+	ctx := context.Background()
+
+	start := time.Date(2024, 2, 25, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	march1 := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	// assertMatchesRaw checks each summary against the raw events of its sector and day
+	assertMatchesRaw := func(summaries []model.IrrigationDailySummary) {
+		t.Helper()
+		for _, summary := range summaries {
+			raw, err := dataRepo.FindByFarmIDAndTimeRange(ctx, 1, summary.Day, summary.Day.Add(24*time.Hour-time.Second))
+			require.NoError(t, err)
+			var nominal, real float64
+			for _, event := range raw {
+				nominal += event.NominalAmount
+				real += event.RealAmount
+			}
+			assert.Equal(t, len(raw), summary.EventCount)
+			assert.InDelta(t, nominal, summary.TotalNominalAmount, 0.001)
+			assert.InDelta(t, real, summary.TotalRealAmount, 0.001)
+		}
+	}
+
+	// 15 days take three chunks; only March 1 and 2 have events
+	result, err := repo.RebuildSummaries(ctx, 1, start, end)
+	require.NoError(t, err)
+	assert.Equal(t, RebuildSummariesResult{Days: 15, Chunks: 3, Summaries: 2, Events: 3}, result)
+
+	summaries, err := repo.FindByFarmAndDayRange(ctx, 1, start, end)
+	require.NoError(t, err)
+	require.Len(t, summaries, 2)
+	assert.True(t, march1.Equal(summaries[0].Day))
+	assert.True(t, summaries[0].Rebuilt)
+	assert.InDelta(t, 0.9+0.8, summaries[0].EfficiencySum, 0.001)
+	assertMatchesRaw(summaries)
+
+	// Rebuilding again replaces the summaries instead of adding to them
+	_, err = repo.RebuildSummaries(ctx, 1, start, end)
+	require.NoError(t, err)
+	summaries, err = repo.FindByFarmAndDayRange(ctx, 1, start, end)
+	require.NoError(t, err)
+	require.Len(t, summaries, 2)
+	assertMatchesRaw(summaries)
+
+	// Archiving a rebuilt day replaces its summary, and a later rebuild keeps the archived one
+	_, err = repo.ArchiveDay(ctx, march1)
+	require.NoError(t, err)
+	result, err = repo.RebuildSummaries(ctx, 1, start, end)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Summaries)
+
+	summaries, err = repo.FindByFarmAndDayRange(ctx, 1, start, end)
+	require.NoError(t, err)
+	require.Len(t, summaries, 2)
+	assert.False(t, summaries[0].Rebuilt)
+	assert.Equal(t, 2, summaries[0].EventCount)
+	assert.InDelta(t, 30, summaries[0].TotalRealAmount, 0.001)
+	assert.True(t, summaries[1].Rebuilt)
+	assertMatchesRaw(summaries[1:])
+}
+
+func TestArchiveDay_AnalyticsUnchanged(t *testing.T) {
+	db := setupTestDB(t)
+	seedBasicData(t, db)
+
+	repo := NewDailySummaryRepository(db)
+	analyticsRepo := NewAnalyticsRepository(db)
+	ctx := context.Background()
+
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 31, 23, 59, 59, 999999999, time.UTC)
+
+	// Rebuilt summaries repeat raw events and must never be added on top of them
+	_, err := repo.RebuildSummaries(ctx, 1, start, end)
+	require.NoError(t, err)
+
+	type snapshot struct {
+		buckets    []AnalyticsAggregation
+		firstPage  []AnalyticsAggregation
+		totalCount int64
+		yoy        map[int]YoYAnalyticsData
+	}
+	take := func() snapshot {
+		t.Helper()
+		var s snapshot
+		var err error
+		s.buckets, s.totalCount, _, err = analyticsRepo.GetAnalyticsForFarmByDateRange(ctx, 1, start, end, model.AggregationDaily, 50, 0)
+		require.NoError(t, err)
+		s.firstPage, _, _, err = analyticsRepo.GetAnalyticsForFarmByDateRange(ctx, 1, start, end, model.AggregationDaily, 1, 0)
+		require.NoError(t, err)
+		s.yoy, err = analyticsRepo.GetYoYComparison(ctx, 1, start, end, model.AggregationDaily)
+		require.NoError(t, err)
+		return s
+	}
+
+	before := take()
+	require.Len(t, before.buckets, 2)
+
+	_, err = repo.ArchiveDay(ctx, start)
+	require.NoError(t, err)
+	after := take()
+
+	assert.Equal(t, before.totalCount, after.totalCount)
+	require.Len(t, after.buckets, len(before.buckets))
+	for i := range before.buckets {
+		assert.Equal(t, before.buckets[i].Period, after.buckets[i].Period)
+		assert.InDelta(t, before.buckets[i].TotalRealAmount, after.buckets[i].TotalRealAmount, 0.001)
+		assert.InDelta(t, before.buckets[i].TotalNominalAmount, after.buckets[i].TotalNominalAmount, 0.001)
+		assert.Equal(t, before.buckets[i].EventCount, after.buckets[i].EventCount)
+		require.NotNil(t, after.buckets[i].AvgEfficiency)
+		assert.InDelta(t, *before.buckets[i].AvgEfficiency, *after.buckets[i].AvgEfficiency, 0.0001)
+	}
+
+	// The archived day still pages like any other bucket
+	require.Len(t, after.firstPage, 1)
+	assert.Equal(t, "2024-03-01", after.firstPage[0].Period)
+
+	require.Len(t, after.yoy, len(before.yoy))
+	for year, data := range before.yoy {
+		assert.InDelta(t, data.TotalRealAmount, after.yoy[year].TotalRealAmount, 0.001)
+		assert.Equal(t, data.EventCount, after.yoy[year].EventCount)
+		require.NotNil(t, after.yoy[year].AvgEfficiency)
+		assert.InDelta(t, *data.AvgEfficiency, *after.yoy[year].AvgEfficiency, 0.0001)
+	}
+}
@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sebaespinosa/test_NF/model"
+	"gorm.io/gorm"
+)
+
+// Dialect renders the SQL fragments that differ between PostgreSQL (production) and SQLite (unit tests)
+// so every analytics query runs unchanged on both; SQLite lacks DATE_TRUNC, EXTRACT and :: casts
+// Expressions are built from trusted column names only, never from user input
+type Dialect interface {
+	// TruncExpr truncates column to the aggregation bucket start, formatted as YYYY-MM-DD
+	// so buckets compare identically across drivers; weeks start on Monday
+	TruncExpr(aggregation model.Aggregation, column string) string
+	// ExtractYear extracts the calendar year of column as an integer
+	ExtractYear(column string) string
+	// ExtractDayOfWeek extracts the UTC weekday of column as an integer (0 = Sunday)
+	ExtractDayOfWeek(column string) string
+	// UnixSeconds converts column to (fractional) Unix seconds
+	// Aggregates over it scan as plain numbers on every driver, unlike MIN/MAX of a timestamp on SQLite
+	UnixSeconds(column string) string
+	// EfficiencyAgg applies an aggregate (AVG, MIN, MAX, SUM) to per-event efficiency (real / nominal),
+	// with fallback standing in for events without a positive nominal amount
+	// An empty fn yields the per-event efficiency itself
+	EfficiencyAgg(fn, table, fallback string) string
+	// EfficiencyStdDev is the population standard deviation of per-event efficiency,
+	// or "" when the driver has no STDDEV_POP and callers must compute it themselves
+	EfficiencyStdDev(table, fallback string) string
+	// ExactSum sums a two-decimal amount column as numeric and rounds to two decimals server-side,
+	// so the scanned float64 is the one nearest the exact sum of the stored values rather than carrying
+	// the rounding error floating-point accumulation picks up over many rows
+	ExactSum(column string) string
+	// CaseInsensitiveLike is the operator matching a LIKE pattern regardless of case
+	CaseInsensitiveLike() string
+	// WidthBucket numbers count equal-width bins over [low, high] from 1 and places column in one,
+	// with high itself in the last bin; "" when the driver has no WIDTH_BUCKET and callers must bin in Go
+	WidthBucket(column string, low, high float64, count int) string
+}
+
+// dialectFor selects the Dialect matching the connection's driver; anything but SQLite is treated as PostgreSQL
+func dialectFor(db *gorm.DB) Dialect {
+	if db != nil && db.Dialector != nil && db.Dialector.Name() == "sqlite" {
+		return sqliteDialect{}
+	}
+	return postgresDialect{}
+}
+
+// postgresDialect is the production Dialect
+type postgresDialect struct{}
+
+func (postgresDialect) TruncExpr(aggregation model.Aggregation, column string) string {
+	return fmt.Sprintf("TO_CHAR(DATE_TRUNC('%s', %s), 'YYYY-MM-DD')", aggregation.TruncFormat(), column)
+}
+
+func (postgresDialect) ExtractYear(column string) string {
+	return fmt.Sprintf("EXTRACT(YEAR FROM %s)::int", column)
+}
+
+func (postgresDialect) ExtractDayOfWeek(column string) string {
+	return fmt.Sprintf("EXTRACT(DOW FROM %s)::int", column)
+}
+
+func (postgresDialect) UnixSeconds(column string) string {
+	return fmt.Sprintf("EXTRACT(EPOCH FROM %s)::float", column)
+}
+
+func (postgresDialect) EfficiencyAgg(fn, table, fallback string) string {
+	return fmt.Sprintf(
+		"%s(CASE WHEN %[2]snominal_amount > 0 THEN %[2]sreal_amount::numeric / %[2]snominal_amount::numeric ELSE %[3]s END)::float",
+		fn, table, fallback,
+	)
+}
+
+func (d postgresDialect) EfficiencyStdDev(table, fallback string) string {
+	return d.EfficiencyAgg("STDDEV_POP", table, fallback)
+}
+
+func (postgresDialect) ExactSum(column string) string {
+	return fmt.Sprintf("ROUND(SUM(%s)::numeric, 2)", column)
+}
+
+func (postgresDialect) CaseInsensitiveLike() string {
+	return "ILIKE"
+}
+
+// WidthBucket clamps to count, as WIDTH_BUCKET puts a value equal to high in an overflow bin count+1
+func (postgresDialect) WidthBucket(column string, low, high float64, count int) string {
+	return fmt.Sprintf("LEAST(WIDTH_BUCKET(%s::float8, %s, %s, %d), %[4]d)",
+		column, strconv.FormatFloat(low, 'f', -1, 64), strconv.FormatFloat(high, 'f', -1, 64), count)
+}
+
+// sqliteDialect is the Dialect of the in-memory SQLite databases used by unit tests
+type sqliteDialect struct{}
+
+// TruncExpr matches PostgreSQL DATE_TRUNC('week') by moving back to the Monday on or before the day
+func (sqliteDialect) TruncExpr(aggregation model.Aggregation, column string) string {
+	switch aggregation {
+	case model.AggregationWeekly:
+		return fmt.Sprintf("DATE(%s, '-6 days', 'weekday 1')", column)
+	case model.AggregationMonthly:
+		return fmt.Sprintf("STRFTIME('%%Y-%%m-01', %s)", column)
+	default:
+		return fmt.Sprintf("DATE(%s)", column)
+	}
+}
+
+func (sqliteDialect) ExtractYear(column string) string {
+	return fmt.Sprintf("CAST(STRFTIME('%%Y', %s) AS INTEGER)", column)
+}
+
+func (sqliteDialect) ExtractDayOfWeek(column string) string {
+	return fmt.Sprintf("CAST(STRFTIME('%%w', %s) AS INTEGER)", column)
+}
+
+func (sqliteDialect) UnixSeconds(column string) string {
+	return fmt.Sprintf("((JULIANDAY(%s) - 2440587.5) * 86400.0)", column)
+}
+
+func (sqliteDialect) EfficiencyAgg(fn, table, fallback string) string {
+	return fmt.Sprintf(
+		"%s(CASE WHEN %[2]snominal_amount > 0 THEN CAST(%[2]sreal_amount AS REAL) / %[2]snominal_amount ELSE %[3]s END)",
+		fn, table, fallback,
+	)
+}
+
+// EfficiencyStdDev is empty: SQLite has no STDDEV_POP, so the repository derives it from per-event values in Go
+func (sqliteDialect) EfficiencyStdDev(table, fallback string) string {
+	return ""
+}
+
+// ExactSum rounds the sum, as SQLite stores numeric(10,2) values as REAL and adds them as doubles
+func (sqliteDialect) ExactSum(column string) string {
+	return fmt.Sprintf("ROUND(SUM(%s), 2)", column)
+}
+
+// CaseInsensitiveLike relies on SQLite's LIKE ignoring case for ASCII
+func (sqliteDialect) CaseInsensitiveLike() string {
+	return "LIKE"
+}
+
+// WidthBucket is empty: SQLite has no WIDTH_BUCKET, so the repository bins per-event values in Go
+func (sqliteDialect) WidthBucket(column string, low, high float64, count int) string {
+	return ""
+}
+
+// efficiencyAggExpr applies an aggregate (AVG, MIN, MAX) to per-event efficiency (real / nominal)
+// Events without a positive nominal amount yield NULL and are skipped by the aggregate,
+// or count as 0 efficiency under model.ZeroNominalZero
+func efficiencyAggExpr(dialect Dialect, policy model.ZeroNominalPolicy, fn, table string) string {
+	return dialect.EfficiencyAgg(fn, table, efficiencyFallback(policy))
+}
+
+// sumAmountExpr sums a two-decimal amount column with Dialect.ExactSum, or with a plain SUM when exact is
+// false (ANALYTICS_EXACT_SUMS=false)
+func sumAmountExpr(dialect Dialect, exact bool, column string) string {
+	if !exact {
+		return "SUM(" + column + ")"
+	}
+	return dialect.ExactSum(column)
+}
+
+// efficiencyStdDevExpr is the population standard deviation of per-event efficiency under policy,
+// or "" when the dialect cannot compute it in SQL
+func efficiencyStdDevExpr(dialect Dialect, policy model.ZeroNominalPolicy, table string) string {
+	return dialect.EfficiencyStdDev(table, efficiencyFallback(policy))
+}
+
+// efficiencyFallback is the efficiency an event without a positive nominal amount contributes under policy
+func efficiencyFallback(policy model.ZeroNominalPolicy) string {
+	if policy == model.ZeroNominalZero {
+		return "0"
+	}
+	return "NULL"
+}
+
+// likeEscaper escapes LIKE wildcards so user input matches literally (use with ESCAPE '\')
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
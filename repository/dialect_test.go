@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/sebaespinosa/test_NF/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDialectFor(t *testing.T) {
+	assert.Equal(t, sqliteDialect{}, dialectFor(setupTestDB(t)))
+	assert.Equal(t, postgresDialect{}, dialectFor(nil))
+}
+
+func TestPostgresDialect(t *testing.T) {
+	d := postgresDialect{}
+
+	assert.Equal(t, "TO_CHAR(DATE_TRUNC('week', start_time), 'YYYY-MM-DD')", d.TruncExpr(model.AggregationWeekly, "start_time"))
+	assert.Equal(t, "EXTRACT(YEAR FROM start_time)::int", d.ExtractYear("start_time"))
+	assert.Equal(t, "EXTRACT(DOW FROM start_time)::int", d.ExtractDayOfWeek("start_time"))
+	assert.Equal(t, "EXTRACT(EPOCH FROM start_time)::float", d.UnixSeconds("start_time"))
+	assert.Equal(t,
+		"AVG(CASE WHEN irrigation_data.nominal_amount > 0 THEN irrigation_data.real_amount::numeric / irrigation_data.nominal_amount::numeric ELSE NULL END)::float",
+		efficiencyAggExpr(d, model.ZeroNominalExclude, "AVG", "irrigation_data."),
+	)
+	assert.Equal(t,
+		"STDDEV_POP(CASE WHEN nominal_amount > 0 THEN real_amount::numeric / nominal_amount::numeric ELSE 0 END)::float",
+		efficiencyStdDevExpr(d, model.ZeroNominalZero, ""),
+	)
+	assert.Equal(t, "ROUND(SUM(real_amount)::numeric, 2)", d.ExactSum("real_amount"))
+	assert.Equal(t, "ILIKE", d.CaseInsensitiveLike())
+	assert.Equal(t, "LEAST(WIDTH_BUCKET(real_amount::float8, 0.5, 120, 10), 10)", d.WidthBucket("real_amount", 0.5, 120, 10))
+}
+
+// TestSQLiteDialect evaluates every expression on SQLite against a known timestamp
+func TestSQLiteDialect(t *testing.T) {
+	db := setupTestDB(t)
+	d := sqliteDialect{}
+	// Thursday, 2024-02-29 18:30 UTC
+	const ts = "'2024-02-29 18:30:00'"
+
+	scalar := func(expr string) string {
+		var out string
+		assert.NoError(t, db.Raw("SELECT CAST("+expr+" AS TEXT)").Scan(&out).Error)
+		return out
+	}
+
+	assert.Equal(t, "2024-02-29", scalar(d.TruncExpr(model.AggregationDaily, ts)))
+	assert.Equal(t, "2024-02-26", scalar(d.TruncExpr(model.AggregationWeekly, ts)))
+	assert.Equal(t, "2024-02-01", scalar(d.TruncExpr(model.AggregationMonthly, ts)))
+	assert.Equal(t, "2024", scalar(d.ExtractYear(ts)))
+	assert.Equal(t, "4", scalar(d.ExtractDayOfWeek(ts)))
+	assert.Empty(t, d.EfficiencyStdDev("", "NULL"))
+	assert.Empty(t, d.WidthBucket("real_amount", 0, 10, 5))
+
+	// JULIANDAY arithmetic leaves sub-millisecond noise
+	var unix float64
+	assert.NoError(t, db.Raw("SELECT "+d.UnixSeconds(ts)).Scan(&unix).Error)
+	assert.InDelta(t, 1709231400, unix, 0.001)
+}
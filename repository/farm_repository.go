@@ -43,6 +43,19 @@ func (r *FarmRepository) FindByID(ctx context.Context, id uint) (*model.Farm, er
 	return &farm, nil
 }
 
+// UpdateHealthyThreshold sets the farm's healthy efficiency threshold, or clears it when threshold is nil
+// Returns gorm.ErrRecordNotFound (wrapped) when the farm does not exist
+func (r *FarmRepository) UpdateHealthyThreshold(ctx context.Context, id uint, threshold *float64) error {
+	result := r.db.WithContext(ctx).Model(&model.Farm{}).Where("id = ?", id).Update("healthy_efficiency_threshold", threshold)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update farm healthy efficiency threshold: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("failed to update farm healthy efficiency threshold: %w", gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
 // FindAll retrieves all farms
 func (r *FarmRepository) FindAll(ctx context.Context) ([]model.Farm, error) {
 	var farms []model.Farm
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -10,25 +11,66 @@ import (
 )
 
 // IrrigationDataRepository handles database operations for IrrigationData entities
+// Analytics reads live in AnalyticsRepository so they can run against a separate *gorm.DB
+// All times are UTC at this boundary: query bounds and written event times are converted to UTC
+// before reaching SQL, so callers may pass any location and buckets still follow UTC days
 type IrrigationDataRepository struct {
-	db *gorm.DB
+	db        *gorm.DB
+	dialect   Dialect
+	exactSums bool
 }
 
 // NewIrrigationDataRepository creates a new IrrigationDataRepository instance
+// Amounts are summed exactly; see WithExactSums
 func NewIrrigationDataRepository(db *gorm.DB) *IrrigationDataRepository {
-	return &IrrigationDataRepository{db: db}
+	return &IrrigationDataRepository{db: db, dialect: dialectFor(db), exactSums: true}
+}
+
+// WithExactSums returns a copy of the repository that, when exact is false, sums amounts with a plain SUM
+// instead of rounding the numeric sum to the columns' two decimals; see Dialect.ExactSum
+func (r *IrrigationDataRepository) WithExactSums(exact bool) *IrrigationDataRepository {
+	clone := *r
+	clone.exactSums = exact
+	return &clone
+}
+
+// sumExpr sums an amount column, exactly unless disabled with WithExactSums
+func (r *IrrigationDataRepository) sumExpr(column string) string {
+	return sumAmountExpr(r.dialect, r.exactSums, column)
+}
+
+// eventTimesToUTC converts an event's start and end times to UTC before it is written
+func eventTimesToUTC(data *model.IrrigationData) {
+	data.StartTime = data.StartTime.UTC()
+	data.EndTime = data.EndTime.UTC()
 }
 
 // Create creates a new irrigation data record
 func (r *IrrigationDataRepository) Create(ctx context.Context, data *model.IrrigationData) error {
+	eventTimesToUTC(data)
 	if err := r.db.WithContext(ctx).Create(data).Error; err != nil {
 		return fmt.Errorf("failed to create irrigation data: %w", err)
 	}
 	return nil
 }
 
+// CreateBatch inserts multiple irrigation data records in a single transaction
+// Either all records are inserted or none are
+func (r *IrrigationDataRepository) CreateBatch(ctx context.Context, data []model.IrrigationData) error {
+	for i := range data {
+		eventTimesToUTC(&data[i])
+	}
+	if err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return tx.CreateInBatches(&data, 500).Error
+	}); err != nil {
+		return fmt.Errorf("failed to create irrigation data batch: %w", err)
+	}
+	return nil
+}
+
 // Save saves or updates an irrigation data record (upsert based on primary key)
 func (r *IrrigationDataRepository) Save(ctx context.Context, data *model.IrrigationData) error {
+	eventTimesToUTC(data)
 	if err := r.db.WithContext(ctx).Save(data).Error; err != nil {
 		return fmt.Errorf("failed to save irrigation data: %w", err)
 	}
@@ -44,11 +86,26 @@ func (r *IrrigationDataRepository) FindByID(ctx context.Context, id uint) (*mode
 	return &data, nil
 }
 
+// preloadExpansions preloads the associations named in expand, so the nested Farm and IrrigationSector
+// of each event are populated; each association costs one extra query regardless of the result size
+func preloadExpansions(query *gorm.DB, expand []model.EventExpansion) *gorm.DB {
+	if model.EventExpansions(expand).Has(model.EventExpandFarm) {
+		query = query.Preload("Farm")
+	}
+	if model.EventExpansions(expand).Has(model.EventExpandSector) {
+		query = query.Preload("IrrigationSector")
+	}
+	return query
+}
+
 // FindByFarmIDAndTimeRange retrieves irrigation data for a farm within a time range
 // Uses composite index (farm_id, start_time) for optimal performance
-func (r *IrrigationDataRepository) FindByFarmIDAndTimeRange(ctx context.Context, farmID uint, startTime, endTime time.Time) ([]model.IrrigationData, error) {
+// Associations named in expand are preloaded
+func (r *IrrigationDataRepository) FindByFarmIDAndTimeRange(ctx context.Context, farmID uint, startTime, endTime time.Time, expand ...model.EventExpansion) ([]model.IrrigationData, error) {
+	startTime, endTime = startTime.UTC(), endTime.UTC()
+
 	var data []model.IrrigationData
-	if err := r.db.WithContext(ctx).
+	if err := preloadExpansions(r.db.WithContext(ctx), expand).
 		Where("farm_id = ? AND start_time >= ? AND start_time <= ?", farmID, startTime, endTime).
 		Order("start_time ASC").
 		Find(&data).Error; err != nil {
@@ -57,11 +114,92 @@ func (r *IrrigationDataRepository) FindByFarmIDAndTimeRange(ctx context.Context,
 	return data, nil
 }
 
+// FindPageByFarmIDAndTimeRange retrieves one page of irrigation data for a farm within a time range
+// Returns the page along with the total number of matching records; associations named in expand are preloaded
+func (r *IrrigationDataRepository) FindPageByFarmIDAndTimeRange(ctx context.Context, farmID uint, startTime, endTime time.Time, limit, offset int, expand ...model.EventExpansion) ([]model.IrrigationData, int64, error) {
+	startTime, endTime = startTime.UTC(), endTime.UTC()
+
+	var data []model.IrrigationData
+	var totalCount int64
+
+	query := r.db.WithContext(ctx).
+		Model(&model.IrrigationData{}).
+		Where("farm_id = ? AND start_time >= ? AND start_time <= ?", farmID, startTime, endTime)
+
+	if err := query.Count(&totalCount).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count irrigation data by farm and time range: %w", err)
+	}
+
+	if err := preloadExpansions(query, expand).
+		Order("start_time ASC").
+		Limit(limit).
+		Offset(offset).
+		Find(&data).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to find irrigation data page by farm and time range: %w", err)
+	}
+	return data, totalCount, nil
+}
+
+// GetLastModifiedForFarm returns the most recent updated_at among a farm's events in a time range,
+// along with how many events match; the count changes when an event is deleted, which updated_at cannot show
+// Returns nil when no events match
+func (r *IrrigationDataRepository) GetLastModifiedForFarm(ctx context.Context, farmID uint, startTime, endTime time.Time) (*time.Time, int64, error) {
+	startTime, endTime = startTime.UTC(), endTime.UTC()
+
+	query := func() *gorm.DB {
+		return r.db.WithContext(ctx).
+			Model(&model.IrrigationData{}).
+			Where("farm_id = ? AND start_time >= ? AND start_time <= ?", farmID, startTime, endTime)
+	}
+
+	var latest model.IrrigationData
+	err := query().Select("updated_at").Order("updated_at DESC").Take(&latest).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get last modified time for farm: %w", err)
+	}
+
+	var count int64
+	if err := query().Count(&count).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count events for farm: %w", err)
+	}
+	return &latest.UpdatedAt, count, nil
+}
+
+// FindModifiedSince retrieves up to limit events created or updated after since, ordered by (updated_at, id)
+// so a feed can resume from the last event returned; a cursor with ID 0 matches updated_at strictly after its time
+// Uses index (updated_at, id). Deleted events do not appear
+func (r *IrrigationDataRepository) FindModifiedSince(ctx context.Context, since model.ChangeCursor, limit int) ([]model.IrrigationData, error) {
+	sinceTime := since.UpdatedAt.UTC()
+
+	query := r.db.WithContext(ctx)
+	if since.ID == 0 {
+		query = query.Where("updated_at > ?", sinceTime)
+	} else {
+		query = query.Where("updated_at > ? OR (updated_at = ? AND id > ?)", sinceTime, sinceTime, since.ID)
+	}
+
+	var data []model.IrrigationData
+	if err := query.
+		Order("updated_at ASC").
+		Order("id ASC").
+		Limit(limit).
+		Find(&data).Error; err != nil {
+		return nil, fmt.Errorf("failed to find irrigation data modified since: %w", err)
+	}
+	return data, nil
+}
+
 // FindBySectorIDAndTimeRange retrieves irrigation data for a sector within a time range
 // Uses composite index (irrigation_sector_id, start_time) for optimal performance
-func (r *IrrigationDataRepository) FindBySectorIDAndTimeRange(ctx context.Context, sectorID uint, startTime, endTime time.Time) ([]model.IrrigationData, error) {
+// Associations named in expand are preloaded
+func (r *IrrigationDataRepository) FindBySectorIDAndTimeRange(ctx context.Context, sectorID uint, startTime, endTime time.Time, expand ...model.EventExpansion) ([]model.IrrigationData, error) {
+	startTime, endTime = startTime.UTC(), endTime.UTC()
+
 	var data []model.IrrigationData
-	if err := r.db.WithContext(ctx).
+	if err := preloadExpansions(r.db.WithContext(ctx), expand).
 		Where("irrigation_sector_id = ? AND start_time >= ? AND start_time <= ?", sectorID, startTime, endTime).
 		Order("start_time ASC").
 		Find(&data).Error; err != nil {
@@ -70,19 +208,47 @@ func (r *IrrigationDataRepository) FindBySectorIDAndTimeRange(ctx context.Contex
 	return data, nil
 }
 
+// FindPageBySectorIDAndTimeRange retrieves one page of irrigation data for a sector within a time range
+// Returns the page along with the total number of matching records; associations named in expand are preloaded
+func (r *IrrigationDataRepository) FindPageBySectorIDAndTimeRange(ctx context.Context, sectorID uint, startTime, endTime time.Time, limit, offset int, expand ...model.EventExpansion) ([]model.IrrigationData, int64, error) {
+	startTime, endTime = startTime.UTC(), endTime.UTC()
+
+	var data []model.IrrigationData
+	var totalCount int64
+
+	query := r.db.WithContext(ctx).
+		Model(&model.IrrigationData{}).
+		Where("irrigation_sector_id = ? AND start_time >= ? AND start_time <= ?", sectorID, startTime, endTime)
+
+	if err := query.Count(&totalCount).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count irrigation data by sector and time range: %w", err)
+	}
+
+	if err := preloadExpansions(query, expand).
+		Order("start_time ASC").
+		Limit(limit).
+		Offset(offset).
+		Find(&data).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to find irrigation data page by sector and time range: %w", err)
+	}
+	return data, totalCount, nil
+}
+
 // AggregateByFarm aggregates irrigation data by farm within a time range
 // Performs SQL-level aggregation to avoid N+1 queries and reduce memory overhead
 type FarmAggregation struct {
-	FarmID             uint    `json:"farm_id"`
-	FarmName           string  `json:"farm_name"`
-	TotalEvents        int64   `json:"total_events"`
-	TotalNominalAmount float64 `json:"total_nominal_amount"`
-	TotalRealAmount    float64 `json:"total_real_amount"`
-	AvgNominalAmount   float64 `json:"avg_nominal_amount"`
-	AvgRealAmount      float64 `json:"avg_real_amount"`
+	FarmID             uint    `gorm:"column:farm_id"`
+	FarmName           string  `gorm:"column:farm_name"`
+	TotalEvents        int64   `gorm:"column:total_events"`
+	TotalNominalAmount float64 `gorm:"column:total_nominal_amount"`
+	TotalRealAmount    float64 `gorm:"column:total_real_amount"`
+	AvgNominalAmount   float64 `gorm:"column:avg_nominal_amount"`
+	AvgRealAmount      float64 `gorm:"column:avg_real_amount"`
 }
 
 func (r *IrrigationDataRepository) AggregateByFarm(ctx context.Context, startTime, endTime time.Time) ([]FarmAggregation, error) {
+	startTime, endTime = startTime.UTC(), endTime.UTC()
+
 	var results []FarmAggregation
 	if err := r.db.WithContext(ctx).
 		Table("irrigation_data").
@@ -90,8 +256,8 @@ func (r *IrrigationDataRepository) AggregateByFarm(ctx context.Context, startTim
 			irrigation_data.farm_id,
 			farms.name as farm_name,
 			COUNT(*) as total_events,
-			SUM(irrigation_data.nominal_amount) as total_nominal_amount,
-			SUM(irrigation_data.real_amount) as total_real_amount,
+			`+r.sumExpr("irrigation_data.nominal_amount")+` as total_nominal_amount,
+			`+r.sumExpr("irrigation_data.real_amount")+` as total_real_amount,
 			AVG(irrigation_data.nominal_amount) as avg_nominal_amount,
 			AVG(irrigation_data.real_amount) as avg_real_amount
 		`).
@@ -108,18 +274,20 @@ func (r *IrrigationDataRepository) AggregateByFarm(ctx context.Context, startTim
 // AggregateBySector aggregates irrigation data by sector within a time range
 // Performs SQL-level aggregation to avoid N+1 queries and reduce memory overhead
 type SectorAggregation struct {
-	FarmID             uint    `json:"farm_id"`
-	FarmName           string  `json:"farm_name"`
-	SectorID           uint    `json:"sector_id"`
-	SectorName         string  `json:"sector_name"`
-	TotalEvents        int64   `json:"total_events"`
-	TotalNominalAmount float64 `json:"total_nominal_amount"`
-	TotalRealAmount    float64 `json:"total_real_amount"`
-	AvgNominalAmount   float64 `json:"avg_nominal_amount"`
-	AvgRealAmount      float64 `json:"avg_real_amount"`
+	FarmID             uint    `gorm:"column:farm_id"`
+	FarmName           string  `gorm:"column:farm_name"`
+	SectorID           uint    `gorm:"column:sector_id"`
+	SectorName         string  `gorm:"column:sector_name"`
+	TotalEvents        int64   `gorm:"column:total_events"`
+	TotalNominalAmount float64 `gorm:"column:total_nominal_amount"`
+	TotalRealAmount    float64 `gorm:"column:total_real_amount"`
+	AvgNominalAmount   float64 `gorm:"column:avg_nominal_amount"`
+	AvgRealAmount      float64 `gorm:"column:avg_real_amount"`
 }
 
 func (r *IrrigationDataRepository) AggregateBySector(ctx context.Context, startTime, endTime time.Time) ([]SectorAggregation, error) {
+	startTime, endTime = startTime.UTC(), endTime.UTC()
+
 	var results []SectorAggregation
 	if err := r.db.WithContext(ctx).
 		Table("irrigation_data").
@@ -129,8 +297,8 @@ func (r *IrrigationDataRepository) AggregateBySector(ctx context.Context, startT
 			irrigation_data.irrigation_sector_id as sector_id,
 			irrigation_sectors.name as sector_name,
 			COUNT(*) as total_events,
-			SUM(irrigation_data.nominal_amount) as total_nominal_amount,
-			SUM(irrigation_data.real_amount) as total_real_amount,
+			`+r.sumExpr("irrigation_data.nominal_amount")+` as total_nominal_amount,
+			`+r.sumExpr("irrigation_data.real_amount")+` as total_real_amount,
 			AVG(irrigation_data.nominal_amount) as avg_nominal_amount,
 			AVG(irrigation_data.real_amount) as avg_real_amount
 		`).
@@ -145,221 +313,63 @@ func (r *IrrigationDataRepository) AggregateBySector(ctx context.Context, startT
 	return results, nil
 }
 
-// Delete deletes an irrigation data record by ID
-func (r *IrrigationDataRepository) Delete(ctx context.Context, id uint) error {
-	if err := r.db.WithContext(ctx).Delete(&model.IrrigationData{}, id).Error; err != nil {
-		return fmt.Errorf("failed to delete irrigation data: %w", err)
-	}
-	return nil
-}
-
-// DeleteAll deletes all irrigation data records
-func (r *IrrigationDataRepository) DeleteAll(ctx context.Context) error {
-	if err := r.db.WithContext(ctx).Exec("DELETE FROM irrigation_data").Error; err != nil {
-		return fmt.Errorf("failed to delete all irrigation data: %w", err)
-	}
-	return nil
-}
-
-// AnalyticsAggregation represents aggregated analytics data for a time period
-type AnalyticsAggregation struct {
-	Period             time.Time `gorm:"column:period"`
-	Year               int       `gorm:"column:year"`
-	TotalRealAmount    float64   `gorm:"column:total_real_amount"`
-	TotalNominalAmount float64   `gorm:"column:total_nominal_amount"`
-	EventCount         int       `gorm:"column:event_count"`
-	AvgEfficiency      *float64  `gorm:"column:avg_efficiency"`
-	MinEfficiency      *float64  `gorm:"column:min_efficiency"`
-	MaxEfficiency      *float64  `gorm:"column:max_efficiency"`
-}
-
-// GetAnalyticsForFarmByDateRange retrieves aggregated analytics for a farm within a time range
-// Uses SQL GROUP BY with DATE_TRUNC for efficient aggregation at database level
-// Leverages composite index (farm_id, start_time) for optimal performance
-func (r *IrrigationDataRepository) GetAnalyticsForFarmByDateRange(
-	ctx context.Context,
-	farmID uint,
-	startTime, endTime time.Time,
-	aggregation string,
-	limit, offset int,
-) ([]AnalyticsAggregation, int64, error) {
-	var results []AnalyticsAggregation
-	var totalCount int64
+// AggregateForSector aggregates one sector's irrigation data within a time range
+// The sector is filtered in the WHERE clause, so no other sector is aggregated; a sector without events
+// in the range comes back with zero totals, and a missing sector returns gorm.ErrRecordNotFound
+func (r *IrrigationDataRepository) AggregateForSector(ctx context.Context, sectorID uint, startTime, endTime time.Time) (*SectorAggregation, error) {
+	startTime, endTime = startTime.UTC(), endTime.UTC()
 
-	// Determine DATE_TRUNC format based on aggregation type
-	truncFormat := "'day'"
-	if aggregation == "weekly" {
-		truncFormat = "'week'"
-	} else if aggregation == "monthly" {
-		truncFormat = "'month'"
-	}
-
-	// Count total records for pagination
-	countQuery := r.db.WithContext(ctx).
-		Table("irrigation_data").
-		Where("farm_id = ? AND start_time >= ? AND start_time <= ?", farmID, startTime, endTime)
-	if err := countQuery.Count(&totalCount).Error; err != nil {
-		return nil, 0, fmt.Errorf("failed to count irrigation data: %w", err)
-	}
-
-	// Fetch aggregated data using DATE_TRUNC
+	var results []SectorAggregation
 	if err := r.db.WithContext(ctx).
-		Table("irrigation_data").
+		Table("irrigation_sectors").
 		Select(`
-			DATE_TRUNC(`+truncFormat+`, start_time) as period,
-			EXTRACT(YEAR FROM start_time)::int as year,
-			SUM(real_amount) as total_real_amount,
-			SUM(nominal_amount) as total_nominal_amount,
-			COUNT(*) as event_count,
-			AVG(CASE WHEN nominal_amount > 0 THEN real_amount::numeric / nominal_amount::numeric ELSE NULL END)::float as avg_efficiency,
-			MIN(CASE WHEN nominal_amount > 0 THEN real_amount::numeric / nominal_amount::numeric ELSE NULL END)::float as min_efficiency,
-			MAX(CASE WHEN nominal_amount > 0 THEN real_amount::numeric / nominal_amount::numeric ELSE NULL END)::float as max_efficiency
+			irrigation_sectors.farm_id,
+			farms.name as farm_name,
+			irrigation_sectors.id as sector_id,
+			irrigation_sectors.name as sector_name,
+			COUNT(irrigation_data.id) as total_events,
+			COALESCE(`+r.sumExpr("irrigation_data.nominal_amount")+`, 0) as total_nominal_amount,
+			COALESCE(`+r.sumExpr("irrigation_data.real_amount")+`, 0) as total_real_amount,
+			COALESCE(AVG(irrigation_data.nominal_amount), 0) as avg_nominal_amount,
+			COALESCE(AVG(irrigation_data.real_amount), 0) as avg_real_amount
 		`).
-		Where("farm_id = ? AND start_time >= ? AND start_time <= ?", farmID, startTime, endTime).
-		Group("DATE_TRUNC(" + truncFormat + ", start_time), year").
-		Order("period ASC").
-		Limit(limit).
-		Offset(offset).
+		Joins("JOIN farms ON farms.id = irrigation_sectors.farm_id").
+		Joins("LEFT JOIN irrigation_data ON irrigation_data.irrigation_sector_id = irrigation_sectors.id AND irrigation_data.start_time >= ? AND irrigation_data.start_time <= ?", startTime, endTime).
+		Where("irrigation_sectors.id = ?", sectorID).
+		Group("irrigation_sectors.farm_id, farms.name, irrigation_sectors.id, irrigation_sectors.name").
 		Scan(&results).Error; err != nil {
-		return nil, 0, fmt.Errorf("failed to get analytics for farm: %w", err)
+		return nil, fmt.Errorf("failed to aggregate irrigation data for sector: %w", err)
 	}
-
-	return results, totalCount, nil
-}
-
-// YoYAnalyticsData represents year-over-year aggregated data
-type YoYAnalyticsData struct {
-	Year               int      `gorm:"column:year"`
-	TotalRealAmount    float64  `gorm:"column:total_real_amount"`
-	TotalNominalAmount float64  `gorm:"column:total_nominal_amount"`
-	EventCount         int      `gorm:"column:event_count"`
-	AvgEfficiency      *float64 `gorm:"column:avg_efficiency"`
-	MinEfficiency      *float64 `gorm:"column:min_efficiency"`
-	MaxEfficiency      *float64 `gorm:"column:max_efficiency"`
-}
-
-// GetYoYComparison retrieves year-over-year data for the same date range across 3 years
-// Uses single SQL UNION ALL query for efficiency (follows DatabaseOptimization.md best practices)
-// Returns data for all 3 years; caller handles year-specific extraction
-func (r *IrrigationDataRepository) GetYoYComparison(
-	ctx context.Context,
-	farmID uint,
-	startTime, endTime time.Time,
-	aggregation string,
-) (map[int]YoYAnalyticsData, error) {
-	var results []YoYAnalyticsData
-
-	// Calculate date ranges for each year
-	currentYear := time.Now().Year()
-	year1Start := time.Date(currentYear, startTime.Month(), startTime.Day(), 0, 0, 0, 0, time.UTC)
-	year1End := time.Date(currentYear, endTime.Month(), endTime.Day(), 23, 59, 59, 0, time.UTC)
-	year2Start := time.Date(currentYear-1, startTime.Month(), startTime.Day(), 0, 0, 0, 0, time.UTC)
-	year2End := time.Date(currentYear-1, endTime.Month(), endTime.Day(), 23, 59, 59, 0, time.UTC)
-	year3Start := time.Date(currentYear-2, startTime.Month(), startTime.Day(), 0, 0, 0, 0, time.UTC)
-	year3End := time.Date(currentYear-2, endTime.Month(), endTime.Day(), 23, 59, 59, 0, time.UTC)
-
-	// Build UNION ALL query using raw SQL for efficiency
-	unionQuery := `
-	SELECT
-		EXTRACT(YEAR FROM start_time)::int as year,
-		SUM(real_amount) as total_real_amount,
-		SUM(nominal_amount) as total_nominal_amount,
-		COUNT(*) as event_count,
-		AVG(CASE WHEN nominal_amount > 0 THEN real_amount::numeric / nominal_amount::numeric ELSE NULL END)::float as avg_efficiency,
-		MIN(CASE WHEN nominal_amount > 0 THEN real_amount::numeric / nominal_amount::numeric ELSE NULL END)::float as min_efficiency,
-		MAX(CASE WHEN nominal_amount > 0 THEN real_amount::numeric / nominal_amount::numeric ELSE NULL END)::float as max_efficiency
-	FROM irrigation_data
-	WHERE farm_id = ? AND start_time >= ? AND start_time <= ?
-	GROUP BY EXTRACT(YEAR FROM start_time)
-	
-	UNION ALL
-	
-	SELECT
-		EXTRACT(YEAR FROM start_time)::int as year,
-		SUM(real_amount) as total_real_amount,
-		SUM(nominal_amount) as total_nominal_amount,
-		COUNT(*) as event_count,
-		AVG(CASE WHEN nominal_amount > 0 THEN real_amount::numeric / nominal_amount::numeric ELSE NULL END)::float as avg_efficiency,
-		MIN(CASE WHEN nominal_amount > 0 THEN real_amount::numeric / nominal_amount::numeric ELSE NULL END)::float as min_efficiency,
-		MAX(CASE WHEN nominal_amount > 0 THEN real_amount::numeric / nominal_amount::numeric ELSE NULL END)::float as max_efficiency
-	FROM irrigation_data
-	WHERE farm_id = ? AND start_time >= ? AND start_time <= ?
-	GROUP BY EXTRACT(YEAR FROM start_time)
-	
-	UNION ALL
-	
-	SELECT
-		EXTRACT(YEAR FROM start_time)::int as year,
-		SUM(real_amount) as total_real_amount,
-		SUM(nominal_amount) as total_nominal_amount,
-		COUNT(*) as event_count,
-		AVG(CASE WHEN nominal_amount > 0 THEN real_amount::numeric / nominal_amount::numeric ELSE NULL END)::float as avg_efficiency,
-		MIN(CASE WHEN nominal_amount > 0 THEN real_amount::numeric / nominal_amount::numeric ELSE NULL END)::float as min_efficiency,
-		MAX(CASE WHEN nominal_amount > 0 THEN real_amount::numeric / nominal_amount::numeric ELSE NULL END)::float as max_efficiency
-	FROM irrigation_data
-	WHERE farm_id = ? AND start_time >= ? AND start_time <= ?
-	GROUP BY EXTRACT(YEAR FROM start_time)
-	`
-
-	if err := r.db.WithContext(ctx).Raw(unionQuery,
-		farmID, year1Start, year1End,
-		farmID, year2Start, year2End,
-		farmID, year3Start, year3End,
-	).Scan(&results).Error; err != nil {
-		return nil, fmt.Errorf("failed to get YoY comparison: %w", err)
-	}
-
-	// Convert results to map indexed by year
-	resultMap := make(map[int]YoYAnalyticsData)
-	for _, result := range results {
-		resultMap[result.Year] = result
+	if len(results) == 0 {
+		return nil, fmt.Errorf("failed to aggregate irrigation data for sector: %w", gorm.ErrRecordNotFound)
 	}
-
-	return resultMap, nil
+	return &results[0], nil
 }
 
-// SectorAnalyticsData represents aggregated data by sector
-type SectorAnalyticsData struct {
-	SectorID           uint     `gorm:"column:sector_id"`
-	SectorName         string   `gorm:"column:sector_name"`
-	TotalRealAmount    float64  `gorm:"column:total_real_amount"`
-	TotalNominalAmount float64  `gorm:"column:total_nominal_amount"`
-	AvgEfficiency      *float64 `gorm:"column:avg_efficiency"`
+// Delete deletes an irrigation data record by ID
+func (r *IrrigationDataRepository) Delete(ctx context.Context, id uint) error {
+	if err := r.db.WithContext(ctx).Delete(&model.IrrigationData{}, id).Error; err != nil {
+		return fmt.Errorf("failed to delete irrigation data: %w", err)
+	}
+	return nil
 }
 
-// GetSectorBreakdownForFarm retrieves aggregated metrics by irrigation sector
-// Optionally filters by specific sector_id for better performance
-func (r *IrrigationDataRepository) GetSectorBreakdownForFarm(
-	ctx context.Context,
-	farmID uint,
-	sectorID *uint,
-	startTime, endTime time.Time,
-) ([]SectorAnalyticsData, error) {
-	var results []SectorAnalyticsData
-
-	query := r.db.WithContext(ctx).
-		Table("irrigation_data").
-		Select(`
-			irrigation_data.irrigation_sector_id as sector_id,
-			irrigation_sectors.name as sector_name,
-			SUM(irrigation_data.real_amount) as total_real_amount,
-			SUM(irrigation_data.nominal_amount) as total_nominal_amount,
-			AVG(CASE WHEN irrigation_data.nominal_amount > 0 THEN irrigation_data.real_amount::numeric / irrigation_data.nominal_amount::numeric ELSE NULL END)::float as avg_efficiency
-		`).
-		Joins("JOIN irrigation_sectors ON irrigation_sectors.id = irrigation_data.irrigation_sector_id").
-		Where("irrigation_data.farm_id = ? AND irrigation_data.start_time >= ? AND irrigation_data.start_time <= ?", farmID, startTime, endTime)
-
-	// Filter by specific sector if provided
-	if sectorID != nil {
-		query = query.Where("irrigation_data.irrigation_sector_id = ?", *sectorID)
+// DeleteOlderThan deletes irrigation data whose start_time is strictly before cutoff
+// Events starting exactly at cutoff are kept; returns the number of deleted records
+func (r *IrrigationDataRepository) DeleteOlderThan(ctx context.Context, cutoff time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Where("start_time < ?", cutoff.UTC()).
+		Delete(&model.IrrigationData{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete irrigation data older than cutoff: %w", result.Error)
 	}
+	return result.RowsAffected, nil
+}
 
-	if err := query.
-		Group("irrigation_data.irrigation_sector_id, irrigation_sectors.name").
-		Order("irrigation_data.irrigation_sector_id ASC").
-		Scan(&results).Error; err != nil {
-		return nil, fmt.Errorf("failed to get sector breakdown: %w", err)
+// DeleteAll deletes all irrigation data records
+func (r *IrrigationDataRepository) DeleteAll(ctx context.Context) error {
+	if err := r.db.WithContext(ctx).Exec("DELETE FROM irrigation_data").Error; err != nil {
+		return fmt.Errorf("failed to delete all irrigation data: %w", err)
 	}
-
-	return results, nil
+	return nil
 }
@@ -18,7 +18,7 @@ func setupTestDB(t *testing.T) *gorm.DB {
 	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
 	require.NoError(t, err)
 
-	err = db.AutoMigrate(&model.Farm{}, &model.IrrigationSector{}, &model.IrrigationData{})
+	err = db.AutoMigrate(&model.Farm{}, &model.IrrigationSector{}, &model.IrrigationData{}, &model.IrrigationDailySummary{})
 	require.NoError(t, err)
 
 	return db
@@ -82,6 +82,58 @@ func TestFindByFarmIDAndTimeRange(t *testing.T) {
 	assert.True(t, results[1].StartTime.Before(results[2].StartTime))
 }
 
+func TestTimeRangeQueries_Expand(t *testing.T) {
+	db := setupTestDB(t)
+	seedBasicData(t, db)
+
+	repo := NewIrrigationDataRepository(db)
+	ctx := context.Background()
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 2, 23, 59, 59, 0, time.UTC)
+
+	queries := map[string]func(expand ...model.EventExpansion) ([]model.IrrigationData, error){
+		"farm": func(expand ...model.EventExpansion) ([]model.IrrigationData, error) {
+			return repo.FindByFarmIDAndTimeRange(ctx, 1, start, end, expand...)
+		},
+		"farm page": func(expand ...model.EventExpansion) ([]model.IrrigationData, error) {
+			data, _, err := repo.FindPageByFarmIDAndTimeRange(ctx, 1, start, end, 2, 1, expand...)
+			return data, err
+		},
+		"sector": func(expand ...model.EventExpansion) ([]model.IrrigationData, error) {
+			return repo.FindBySectorIDAndTimeRange(ctx, 1, start, end, expand...)
+		},
+		"sector page": func(expand ...model.EventExpansion) ([]model.IrrigationData, error) {
+			data, _, err := repo.FindPageBySectorIDAndTimeRange(ctx, 1, start, end, 2, 1, expand...)
+			return data, err
+		},
+	}
+
+	for name, query := range queries {
+		t.Run(name, func(t *testing.T) {
+			plain, err := query()
+			require.NoError(t, err)
+			require.NotEmpty(t, plain)
+			assert.Zero(t, plain[0].Farm, "associations are not loaded unless expanded")
+			assert.Zero(t, plain[0].IrrigationSector)
+
+			farmOnly, err := query(model.EventExpandFarm)
+			require.NoError(t, err)
+			assert.Equal(t, "Farm A", farmOnly[0].Farm.Name)
+			assert.Zero(t, farmOnly[0].IrrigationSector)
+
+			expanded, err := query(model.EventExpandFarm, model.EventExpandSector)
+			require.NoError(t, err)
+			require.Len(t, expanded, len(plain))
+			for _, event := range expanded {
+				assert.Equal(t, uint(1), event.Farm.ID)
+				assert.Equal(t, "Farm A", event.Farm.Name)
+				assert.Equal(t, uint(1), event.IrrigationSector.ID)
+				assert.Equal(t, "Sector A", event.IrrigationSector.Name)
+			}
+		})
+	}
+}
+
 // TestCreate tests creating irrigation records
 func TestCreate(t *testing.T) {
 	db := setupTestDB(t)
@@ -112,3 +164,228 @@ func TestCreate(t *testing.T) {
 	db.Model(&model.IrrigationData{}).Where("farm_id = ?", 2).Count(&count)
 	assert.Equal(t, int64(1), count)
 }
+
+// TestCreate_AmountRoundTrip checks two-decimal amounts read back exactly, as float32 could not represent them
+func TestCreate_AmountRoundTrip(t *testing.T) {
+	db := setupTestDB(t)
+	seedBasicData(t, db)
+
+	repo := NewIrrigationDataRepository(db)
+	ctx := context.Background()
+
+	created := &model.IrrigationData{
+		FarmID:             1,
+		IrrigationSectorID: 1,
+		StartTime:          time.Date(2024, 4, 1, 6, 0, 0, 0, time.UTC),
+		EndTime:            time.Date(2024, 4, 1, 7, 0, 0, 0, time.UTC),
+		NominalAmount:      12.34,
+		RealAmount:         10.07,
+	}
+	require.NoError(t, repo.Create(ctx, created))
+
+	read, err := repo.FindByID(ctx, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 12.34, read.NominalAmount)
+	assert.Equal(t, 10.07, read.RealAmount)
+}
+
+// TestGetSectorTimeSeriesForFarm tests (sector, period) grouping on the SQLite dialect
+// TestGetLastModifiedForFarm tests the latest updated_at lookup for a farm/time window
+func TestDeleteOlderThan(t *testing.T) {
+	db := setupTestDB(t)
+	seedBasicData(t, db)
+
+	repo := NewIrrigationDataRepository(db)
+	ctx := context.Background()
+
+	// The cutoff equals the second event's start: only the earlier event is deleted
+	deleted, err := repo.DeleteOlderThan(ctx, time.Date(2024, 3, 1, 18, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	remaining, err := repo.FindByFarmIDAndTimeRange(ctx, 1, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.Len(t, remaining, 2)
+	assert.Equal(t, time.Date(2024, 3, 1, 18, 0, 0, 0, time.UTC), remaining[0].StartTime.UTC())
+
+	// A cutoff in another location is compared in UTC; nothing else is old enough
+	local := time.FixedZone("UTC-5", -5*60*60)
+	deleted, err = repo.DeleteOlderThan(ctx, time.Date(2024, 3, 1, 13, 0, 0, 0, local))
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+}
+
+func TestGetLastModifiedForFarm(t *testing.T) {
+	db := setupTestDB(t)
+	seedBasicData(t, db)
+
+	repo := NewIrrigationDataRepository(db)
+	ctx := context.Background()
+
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 1, 23, 59, 59, 0, time.UTC)
+
+	touched := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, db.Model(&model.IrrigationData{}).
+		Where("start_time = ?", time.Date(2024, 3, 1, 18, 0, 0, 0, time.UTC)).
+		UpdateColumn("updated_at", touched).Error)
+
+	lastModified, count, err := repo.GetLastModifiedForFarm(ctx, 1, start, end)
+	require.NoError(t, err)
+	require.NotNil(t, lastModified)
+	assert.True(t, lastModified.Equal(touched))
+	assert.Equal(t, int64(2), count)
+
+	// Deleting an event that is not the latest leaves updated_at alone but changes the count
+	require.NoError(t, db.Where("start_time = ?", time.Date(2024, 3, 1, 6, 0, 0, 0, time.UTC)).Delete(&model.IrrigationData{}).Error)
+	lastModified, count, err = repo.GetLastModifiedForFarm(ctx, 1, start, end)
+	require.NoError(t, err)
+	require.NotNil(t, lastModified)
+	assert.True(t, lastModified.Equal(touched))
+	assert.Equal(t, int64(1), count)
+
+	// No events in range
+	lastModified, count, err = repo.GetLastModifiedForFarm(ctx, 1, start.AddDate(1, 0, 0), end.AddDate(1, 0, 0))
+	require.NoError(t, err)
+	assert.Nil(t, lastModified)
+	assert.Zero(t, count)
+}
+
+// TestGetTopIrrigationDays verifies days are ranked by total real amount and limited to n
+func TestRepositoryNormalizesTimesToUTC(t *testing.T) {
+	db := setupTestDB(t)
+	seedBasicData(t, db)
+
+	repo := NewIrrigationDataRepository(db)
+	analyticsRepo := NewAnalyticsRepository(db)
+	ctx := context.Background()
+
+	// 2024-03-01 00:00 UTC expressed in UTC-05:00; the local calendar day is still February 29
+	local := time.FixedZone("UTC-5", -5*60*60)
+	start := time.Date(2024, 2, 29, 19, 0, 0, 0, local)
+	end := time.Date(2024, 3, 2, 18, 59, 59, 0, local)
+
+	results, total, _, err := analyticsRepo.GetAnalyticsForFarmByDateRange(ctx, 1, start, end, model.AggregationDaily, 50, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	require.Len(t, results, 2)
+	assert.Equal(t, "2024-03-01", results[0].Period)
+	assert.Equal(t, 2, results[0].EventCount)
+	assert.Equal(t, "2024-03-02", results[1].Period)
+	assert.Equal(t, 1, results[1].EventCount)
+
+	// A bound just before 18:00 UTC on March 1 excludes the evening event regardless of location
+	data, err := repo.FindByFarmIDAndTimeRange(ctx, 1, start, time.Date(2024, 3, 1, 12, 59, 0, 0, local))
+	require.NoError(t, err)
+	require.Len(t, data, 1)
+	assert.Equal(t, time.Date(2024, 3, 1, 6, 0, 0, 0, time.UTC), data[0].StartTime.UTC())
+
+	// Writes are stored in UTC as well
+	event := model.IrrigationData{
+		FarmID:             1,
+		IrrigationSectorID: 1,
+		StartTime:          time.Date(2024, 3, 2, 22, 0, 0, 0, local),
+		EndTime:            time.Date(2024, 3, 2, 23, 0, 0, 0, local),
+		NominalAmount:      10,
+		RealAmount:         9,
+	}
+	require.NoError(t, repo.Create(ctx, &event))
+	assert.Equal(t, time.UTC, event.StartTime.Location())
+
+	results, _, _, err = analyticsRepo.GetAnalyticsForFarmByDateRange(ctx, 1, start, end.Add(24*time.Hour), model.AggregationDaily, 50, 0)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.Equal(t, "2024-03-03", results[2].Period)
+}
+
+func TestAggregateForSector(t *testing.T) {
+	db := setupTestDB(t)
+	seedBasicData(t, db)
+
+	require.NoError(t, db.Create(&model.IrrigationSector{ID: 2, FarmID: 1, Name: "Sector B"}).Error)
+	require.NoError(t, db.Create(&model.IrrigationData{
+		FarmID: 1, IrrigationSectorID: 2,
+		StartTime: time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC), EndTime: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC),
+		NominalAmount: 100, RealAmount: 100,
+	}).Error)
+
+	repo := NewIrrigationDataRepository(db)
+	ctx := context.Background()
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 1, 23, 59, 59, 0, time.UTC)
+
+	// Sector 2's event on the same day is left out
+	result, err := repo.AggregateForSector(ctx, 1, start, end)
+	require.NoError(t, err)
+	assert.Equal(t, uint(1), result.FarmID)
+	assert.Equal(t, "Farm A", result.FarmName)
+	assert.Equal(t, "Sector A", result.SectorName)
+	assert.Equal(t, int64(2), result.TotalEvents)
+	assert.InDelta(t, 35, result.TotalNominalAmount, 0.001)
+	assert.InDelta(t, 30, result.TotalRealAmount, 0.001)
+	assert.InDelta(t, 15, result.AvgRealAmount, 0.001)
+
+	// A sector without events in the range has zero totals
+	result, err = repo.AggregateForSector(ctx, 2, start.AddDate(0, 0, 1), end.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	assert.Equal(t, "Sector B", result.SectorName)
+	assert.Zero(t, result.TotalEvents)
+	assert.Zero(t, result.TotalRealAmount)
+
+	_, err = repo.AggregateForSector(ctx, 99, start, end)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestFindModifiedSince(t *testing.T) {
+	db := setupTestDB(t)
+	seedBasicData(t, db)
+	repo := NewIrrigationDataRepository(db)
+	ctx := context.Background()
+
+	ids := func(data []model.IrrigationData) []uint {
+		var result []uint
+		for _, d := range data {
+			result = append(result, d.ID)
+		}
+		return result
+	}
+	cursorAfter := func(data []model.IrrigationData) model.ChangeCursor {
+		last := data[len(data)-1]
+		return model.ChangeCursor{UpdatedAt: last.UpdatedAt, ID: last.ID}
+	}
+
+	// Page through every event from the beginning
+	firstPage, err := repo.FindModifiedSince(ctx, model.ChangeCursor{}, 2)
+	require.NoError(t, err)
+	require.Len(t, firstPage, 2)
+	secondPage, err := repo.FindModifiedSince(ctx, cursorAfter(firstPage), 2)
+	require.NoError(t, err)
+	require.Len(t, secondPage, 1)
+	assert.ElementsMatch(t, []uint{1, 2, 3}, append(ids(firstPage), ids(secondPage)...))
+
+	checkpoint := cursorAfter(secondPage)
+	unchanged, err := repo.FindModifiedSince(ctx, checkpoint, 10)
+	require.NoError(t, err)
+	assert.Empty(t, unchanged)
+
+	// Updating an event and creating another both show up after the checkpoint, in that order
+	time.Sleep(2 * time.Millisecond)
+	require.NoError(t, db.Model(&model.IrrigationData{ID: 1}).Update("real_amount", 19).Error)
+	time.Sleep(2 * time.Millisecond)
+	created := model.IrrigationData{
+		FarmID: 1, IrrigationSectorID: 1,
+		StartTime: time.Date(2024, 3, 3, 6, 0, 0, 0, time.UTC), EndTime: time.Date(2024, 3, 3, 7, 0, 0, 0, time.UTC),
+		NominalAmount: 10, RealAmount: 9,
+	}
+	require.NoError(t, repo.Create(ctx, &created))
+
+	changed, err := repo.FindModifiedSince(ctx, checkpoint, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []uint{1, created.ID}, ids(changed))
+	assert.Equal(t, 19.0, changed[0].RealAmount)
+
+	// A bare timestamp only returns events updated strictly after it
+	afterUpdate, err := repo.FindModifiedSince(ctx, model.ChangeCursor{UpdatedAt: changed[0].UpdatedAt}, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []uint{created.ID}, ids(afterUpdate))
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/sebaespinosa/test_NF/model"
 	"gorm.io/gorm"
@@ -10,12 +11,13 @@ import (
 
 // IrrigationSectorRepository handles database operations for IrrigationSector entities
 type IrrigationSectorRepository struct {
-	db *gorm.DB
+	db      *gorm.DB
+	dialect Dialect
 }
 
 // NewIrrigationSectorRepository creates a new IrrigationSectorRepository instance
 func NewIrrigationSectorRepository(db *gorm.DB) *IrrigationSectorRepository {
-	return &IrrigationSectorRepository{db: db}
+	return &IrrigationSectorRepository{db: db, dialect: dialectFor(db)}
 }
 
 // Create creates a new irrigation sector
@@ -52,6 +54,42 @@ func (r *IrrigationSectorRepository) FindByFarmID(ctx context.Context, farmID ui
 	return sectors, nil
 }
 
+// FindByFarmIDAndNameLike retrieves a farm's irrigation sectors whose name contains query, ignoring case
+// Uses ILIKE on PostgreSQL and LIKE on SQLite (case-insensitive for ASCII); % and _ in query match literally
+func (r *IrrigationSectorRepository) FindByFarmIDAndNameLike(ctx context.Context, farmID uint, query string) ([]model.IrrigationSector, error) {
+	operator := r.dialect.CaseInsensitiveLike()
+	pattern := "%" + likeEscaper.Replace(query) + "%"
+
+	var sectors []model.IrrigationSector
+	if err := r.db.WithContext(ctx).
+		Where("farm_id = ? AND name "+operator+" ? ESCAPE '\\'", farmID, pattern).
+		Order("name ASC").
+		Find(&sectors).Error; err != nil {
+		return nil, fmt.Errorf("failed to find irrigation sectors by name: %w", err)
+	}
+	return sectors, nil
+}
+
+// FindInactiveByFarmID retrieves a farm's irrigation sectors without any event starting within the time range,
+// ordered by ID; the anti-join uses the (irrigation_sector_id, start_time) index
+func (r *IrrigationSectorRepository) FindInactiveByFarmID(ctx context.Context, farmID uint, startTime, endTime time.Time) ([]model.IrrigationSector, error) {
+	startTime, endTime = startTime.UTC(), endTime.UTC()
+
+	var sectors []model.IrrigationSector
+	if err := r.db.WithContext(ctx).
+		Where("farm_id = ?", farmID).
+		Where(`NOT EXISTS (
+			SELECT 1 FROM irrigation_data
+			WHERE irrigation_data.irrigation_sector_id = irrigation_sectors.id
+				AND irrigation_data.start_time >= ? AND irrigation_data.start_time <= ?
+		)`, startTime, endTime).
+		Order("id ASC").
+		Find(&sectors).Error; err != nil {
+		return nil, fmt.Errorf("failed to find inactive irrigation sectors: %w", err)
+	}
+	return sectors, nil
+}
+
 // FindAll retrieves all irrigation sectors
 func (r *IrrigationSectorRepository) FindAll(ctx context.Context) ([]model.IrrigationSector, error) {
 	var sectors []model.IrrigationSector
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sebaespinosa/test_NF/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindByFarmIDAndNameLike(t *testing.T) {
+	db := setupTestDB(t)
+
+	require.NoError(t, db.Create(&[]model.Farm{{ID: 1, Name: "Farm A"}, {ID: 2, Name: "Farm B"}}).Error)
+	require.NoError(t, db.Create(&[]model.IrrigationSector{
+		{ID: 1, FarmID: 1, Name: "North Field"},
+		{ID: 2, FarmID: 1, Name: "South Field"},
+		{ID: 3, FarmID: 1, Name: "Northeast 100% drip"},
+		{ID: 4, FarmID: 2, Name: "North Grove"},
+	}).Error)
+
+	repo := NewIrrigationSectorRepository(db)
+	ctx := context.Background()
+
+	sectors, err := repo.FindByFarmIDAndNameLike(ctx, 1, "NORTH")
+	require.NoError(t, err)
+	require.Len(t, sectors, 2)
+	assert.Equal(t, "North Field", sectors[0].Name)
+	assert.Equal(t, "Northeast 100% drip", sectors[1].Name)
+
+	// Wildcards in the query are matched literally
+	sectors, err = repo.FindByFarmIDAndNameLike(ctx, 1, "0%")
+	require.NoError(t, err)
+	require.Len(t, sectors, 1)
+	assert.Equal(t, uint(3), sectors[0].ID)
+
+	sectors, err = repo.FindByFarmIDAndNameLike(ctx, 1, "_")
+	require.NoError(t, err)
+	assert.Empty(t, sectors)
+}
+
+func TestFindInactiveByFarmID(t *testing.T) {
+	db := setupTestDB(t)
+	// Sector 1 of farm 1 has events on March 1 and 2
+	seedBasicData(t, db)
+
+	require.NoError(t, db.Create(&model.Farm{ID: 2, Name: "Farm B"}).Error)
+	require.NoError(t, db.Create(&[]model.IrrigationSector{
+		{ID: 2, FarmID: 1, Name: "Never Irrigated"},
+		{ID: 3, FarmID: 1, Name: "Irrigated Later"},
+		{ID: 4, FarmID: 2, Name: "Other Farm"},
+	}).Error)
+	require.NoError(t, db.Create(&model.IrrigationData{
+		FarmID: 1, IrrigationSectorID: 3,
+		StartTime: time.Date(2024, 4, 10, 6, 0, 0, 0, time.UTC), EndTime: time.Date(2024, 4, 10, 7, 0, 0, 0, time.UTC),
+		NominalAmount: 10, RealAmount: 9,
+	}).Error)
+
+	repo := NewIrrigationSectorRepository(db)
+	ctx := context.Background()
+
+	march := [2]time.Time{time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 31, 23, 59, 59, 0, time.UTC)}
+	sectors, err := repo.FindInactiveByFarmID(ctx, 1, march[0], march[1])
+	require.NoError(t, err)
+	require.Len(t, sectors, 2)
+	assert.Equal(t, uint(2), sectors[0].ID)
+	assert.Equal(t, uint(3), sectors[1].ID)
+
+	// In April only the sector with the April event is active
+	sectors, err = repo.FindInactiveByFarmID(ctx, 1, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 4, 30, 23, 59, 59, 0, time.UTC))
+	require.NoError(t, err)
+	require.Len(t, sectors, 2)
+	assert.Equal(t, uint(1), sectors[0].ID)
+	assert.Equal(t, uint(2), sectors[1].ID)
+
+	// An event exactly on the range end still counts as activity
+	sectors, err = repo.FindInactiveByFarmID(ctx, 1, march[0], time.Date(2024, 3, 1, 6, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Len(t, sectors, 2)
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/sebaespinosa/test_NF/model"
+	"gorm.io/gorm"
+)
+
+type sectorGroupByKey struct{}
+
+// WithSectorGroupBy returns a context whose sector breakdown and sector time-series queries aggregate by
+// groupBy; with model.SectorGroupByZone every sector's events count towards its top-level ancestor
+func WithSectorGroupBy(ctx context.Context, groupBy model.SectorGroupBy) context.Context {
+	return context.WithValue(ctx, sectorGroupByKey{}, groupBy)
+}
+
+// sectorZonesQuery maps each of a farm's sectors to its zone, the top-level ancestor reached through
+// parent_sector_id; the walk starts at the roots and stays within the farm, so sectors caught in a parent
+// cycle or under another farm's sector are left out
+// It takes the farm ID twice, for the roots and for the recursive step
+const sectorZonesQuery = `WITH RECURSIVE sector_zones(sector_id, zone_id) AS (
+	SELECT id, id FROM irrigation_sectors WHERE parent_sector_id IS NULL AND farm_id = ?
+	UNION ALL
+	SELECT irrigation_sectors.id, sector_zones.zone_id
+	FROM irrigation_sectors JOIN sector_zones ON irrigation_sectors.parent_sector_id = sector_zones.sector_id
+	WHERE irrigation_sectors.farm_id = ?
+) SELECT sector_id, zone_id FROM sector_zones`
+
+// groupsByZone reports whether the context's sector queries roll sectors up into zones
+func groupsByZone(ctx context.Context) bool {
+	groupBy, _ := ctx.Value(sectorGroupByKey{}).(model.SectorGroupBy)
+	return groupBy == model.SectorGroupByZone
+}
+
+// sectorGroupColumn is the expression the context's sector queries group irrigation_data by
+// Grouping by zone needs joinSectorZones; sectors without a zone (parent cycles) stay on their own
+func sectorGroupColumn(ctx context.Context) string {
+	if groupsByZone(ctx) {
+		return "COALESCE(sector_zones.zone_id, irrigation_data.irrigation_sector_id)"
+	}
+	return "irrigation_data.irrigation_sector_id"
+}
+
+// joinSectorZones joins the farm's zone mapping into query when the context groups by zone
+func joinSectorZones(ctx context.Context, query *gorm.DB, farmID uint) *gorm.DB {
+	if !groupsByZone(ctx) {
+		return query
+	}
+	return query.Joins("LEFT JOIN ("+sectorZonesQuery+") sector_zones ON sector_zones.sector_id = irrigation_data.irrigation_sector_id", farmID, farmID)
+}
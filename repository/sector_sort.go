@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sebaespinosa/test_NF/model"
+)
+
+type sectorSortKey struct{}
+
+// WithSectorSort returns a context whose sector breakdown is ordered by sort instead of by sector ID
+func WithSectorSort(ctx context.Context, sort model.SectorSort) context.Context {
+	return context.WithValue(ctx, sectorSortKey{}, sort)
+}
+
+// sectorSortOrder renders the context's sector sort as an ORDER BY prefix for sectorBreakdown, which
+// appends the ascending sector ID tie-break; empty for the default ID order
+// Sectors without an efficiency sort last in both directions, as NULL placement differs between drivers
+func (r *AnalyticsRepository) sectorSortOrder(ctx context.Context) string {
+	sort, _ := ctx.Value(sectorSortKey{}).(model.SectorSort)
+	direction := "ASC"
+	if sort.Descending {
+		direction = "DESC"
+	}
+
+	switch sort.Field {
+	case model.SectorSortName:
+		return "irrigation_sectors.name " + direction
+	case model.SectorSortVolume:
+		return "total_real_amount " + direction
+	case model.SectorSortEfficiency:
+		// Output aliases cannot appear inside ORDER BY expressions on PostgreSQL, so the NULL check repeats the aggregate
+		return fmt.Sprintf("CASE WHEN %s IS NULL THEN 1 ELSE 0 END ASC, avg_efficiency %s",
+			r.efficiencyAggExpr("AVG", "irrigation_data."), direction)
+	case model.SectorSortID:
+		if sort.Descending {
+			return sectorGroupColumn(ctx) + " DESC"
+		}
+	}
+	return ""
+}
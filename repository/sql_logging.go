@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sebaespinosa/test_NF/internal/logging"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// maxLoggedInValues caps the values written out for each IN list of a logged statement
+const maxLoggedInValues = 10
+
+// inListPattern matches an IN list of interpolated values, capturing the values
+var inListPattern = regexp.MustCompile(`(?i)\bIN \(([^()]*)\)`)
+
+// WithSQLLogging returns a copy of the repository that logs every statement it runs at debug level,
+// with bound values interpolated the way gorm.DB.ToSQL renders them, to diagnose aggregation discrepancies
+// It is a no-op in production; IN lists past maxLoggedInValues values are shortened to a count
+func (r *AnalyticsRepository) WithSQLLogging(logger *logging.Logger, env string) *AnalyticsRepository {
+	clone := *r
+	clone.sqlLogger = nil
+	if env != "production" {
+		clone.sqlLogger = &sqlLogger{Interface: r.db.Logger, logger: logger}
+	}
+	return &clone
+}
+
+// withSQLLogging attaches the repository's SQL logger, when there is one, to db
+func (r *AnalyticsRepository) withSQLLogging(db *gorm.DB) *gorm.DB {
+	if r.sqlLogger == nil {
+		return db
+	}
+	return db.Session(&gorm.Session{Logger: r.sqlLogger})
+}
+
+// sqlLogger logs each traced statement through zap, then hands it on to the GORM logger it wraps
+type sqlLogger struct {
+	gormlogger.Interface
+	logger *logging.Logger
+}
+
+func (l *sqlLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	return &sqlLogger{Interface: l.Interface.LogMode(level), logger: l.logger}
+}
+
+func (l *sqlLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	l.Interface.Trace(ctx, begin, fc, err)
+
+	sql, rows := fc()
+	fields := []zap.Field{
+		zap.String("sql", redactInLists(sql)),
+		zap.Int64("rows", rows),
+		zap.Duration("duration", time.Since(begin)),
+	}
+	if err != nil {
+		fields = append(fields, zap.Error(err))
+	}
+	l.logger.WithContext(ctx).Debug("analytics sql", fields...)
+}
+
+// redactInLists shortens each IN list of sql longer than maxLoggedInValues to its first values and a count
+func redactInLists(sql string) string {
+	return inListPattern.ReplaceAllStringFunc(sql, func(match string) string {
+		values := strings.Split(inListPattern.FindStringSubmatch(match)[1], ",")
+		if len(values) <= maxLoggedInValues {
+			return match
+		}
+		shown := strings.Join(values[:maxLoggedInValues], ",")
+		return fmt.Sprintf("%s(%s, ... %d more)", match[:len("IN ")], shown, len(values)-maxLoggedInValues)
+	})
+}
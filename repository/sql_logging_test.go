@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sebaespinosa/test_NF/internal/logging"
+	"github.com/sebaespinosa/test_NF/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestAnalyticsRepository_SQLLogging(t *testing.T) {
+	db := setupTestDB(t)
+	seedBasicData(t, db)
+
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 31, 23, 59, 59, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		logging bool
+		env     string
+		logged  bool
+	}{
+		{name: "on", logging: true, env: "development", logged: true},
+		{name: "off", logging: false, env: "development", logged: false},
+		{name: "on in production", logging: true, env: "production", logged: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core, logs := observer.New(zap.DebugLevel)
+			repo := NewAnalyticsRepository(db)
+			if tt.logging {
+				repo = repo.WithSQLLogging(&logging.Logger{Logger: zap.New(core)}, tt.env)
+			}
+
+			_, _, _, err := repo.GetAnalyticsForFarmByDateRange(context.Background(), 1, start, end, model.AggregationDaily, 10, 0)
+			require.NoError(t, err)
+
+			entries := logs.FilterMessage("analytics sql").All()
+			if !tt.logged {
+				assert.Empty(t, entries)
+				return
+			}
+			// The count, the archived summaries, the aggregation and SQLite's per-event efficiencies
+			// for the standard deviation
+			require.Len(t, entries, 4)
+			sql := entries[2].ContextMap()["sql"].(string)
+			assert.Contains(t, sql, "GROUP BY")
+			// Bound values are interpolated, not left as placeholders
+			assert.Contains(t, sql, "farm_id = 1")
+			assert.NotContains(t, sql, "?")
+			assert.Equal(t, int64(2), entries[2].ContextMap()["rows"])
+		})
+	}
+}
+
+func TestRedactInLists(t *testing.T) {
+	ids := make([]string, maxLoggedInValues+2)
+	for i := range ids {
+		ids[i] = strconv.Itoa(i + 1)
+	}
+
+	tests := []struct {
+		name     string
+		sql      string
+		expected string
+	}{
+		{name: "short list kept", sql: "SELECT * FROM farms WHERE id IN (1,2,3)", expected: "SELECT * FROM farms WHERE id IN (1,2,3)"},
+		{
+			name:     "long list shortened",
+			sql:      "SELECT * FROM farms WHERE id IN (" + strings.Join(ids, ",") + ") AND name = 'a'",
+			expected: "SELECT * FROM farms WHERE id IN (" + strings.Join(ids[:maxLoggedInValues], ",") + ", ... 2 more) AND name = 'a'",
+		},
+		{name: "no list", sql: "SELECT COUNT(*) FROM irrigation_data", expected: "SELECT COUNT(*) FROM irrigation_data"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, redactInLists(tt.sql))
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sebaespinosa/test_NF/model"
+	"gorm.io/gorm"
+)
+
+// TransferRepository persists imported farm data across tables in a single transaction
+type TransferRepository struct {
+	db *gorm.DB
+}
+
+// NewTransferRepository creates a new TransferRepository instance
+func NewTransferRepository(db *gorm.DB) *TransferRepository {
+	return &TransferRepository{db: db}
+}
+
+// ImportConflictError reports IDs that already exist when an import must not overwrite
+type ImportConflictError struct {
+	Table string
+	IDs   []uint
+}
+
+func (e *ImportConflictError) Error() string {
+	return fmt.Sprintf("%s already contains id(s) %v", e.Table, e.IDs)
+}
+
+// Import inserts farms, sectors and irrigation data atomically, in dependency order
+// With overwrite, rows sharing a primary key are updated; without it, any existing ID
+// aborts the whole import with an *ImportConflictError
+// Irrigation data without an ID is always inserted with a generated one
+func (r *TransferRepository) Import(
+	ctx context.Context,
+	farms []model.Farm,
+	sectors []model.IrrigationSector,
+	data []model.IrrigationData,
+	overwrite bool,
+) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if !overwrite {
+			if err := checkExistingIDs(tx, &model.Farm{}, "farms", farmIDs(farms)); err != nil {
+				return err
+			}
+			if err := checkExistingIDs(tx, &model.IrrigationSector{}, "irrigation_sectors", sectorIDs(sectors)); err != nil {
+				return err
+			}
+			if err := checkExistingIDs(tx, &model.IrrigationData{}, "irrigation_data", dataIDs(data)); err != nil {
+				return err
+			}
+		}
+
+		if len(farms) > 0 {
+			if err := tx.Save(&farms).Error; err != nil {
+				return fmt.Errorf("failed to import farms: %w", err)
+			}
+		}
+		if len(sectors) > 0 {
+			if err := tx.Save(&sectors).Error; err != nil {
+				return fmt.Errorf("failed to import irrigation sectors: %w", err)
+			}
+		}
+		if len(data) > 0 {
+			if err := tx.Session(&gorm.Session{CreateBatchSize: 500}).Save(&data).Error; err != nil {
+				return fmt.Errorf("failed to import irrigation data: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to import seed data: %w", err)
+	}
+	return nil
+}
+
+// checkExistingIDs returns an *ImportConflictError listing the ids already present in the table
+func checkExistingIDs(tx *gorm.DB, entity any, table string, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var existing []uint
+	if err := tx.Model(entity).Where("id IN ?", ids).Order("id ASC").Pluck("id", &existing).Error; err != nil {
+		return fmt.Errorf("failed to check existing %s: %w", table, err)
+	}
+	if len(existing) > 0 {
+		return &ImportConflictError{Table: table, IDs: existing}
+	}
+	return nil
+}
+
+func farmIDs(farms []model.Farm) []uint {
+	ids := make([]uint, 0, len(farms))
+	for _, farm := range farms {
+		ids = append(ids, farm.ID)
+	}
+	return ids
+}
+
+func sectorIDs(sectors []model.IrrigationSector) []uint {
+	ids := make([]uint, 0, len(sectors))
+	for _, sector := range sectors {
+		ids = append(ids, sector.ID)
+	}
+	return ids
+}
+
+func dataIDs(data []model.IrrigationData) []uint {
+	ids := make([]uint, 0, len(data))
+	for _, item := range data {
+		if item.ID != 0 {
+			ids = append(ids, item.ID)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sebaespinosa/test_NF/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func importFixture() ([]model.Farm, []model.IrrigationSector, []model.IrrigationData) {
+	farms := []model.Farm{{ID: 1, Name: "Imported Farm"}}
+	sectors := []model.IrrigationSector{{ID: 1, FarmID: 1, Name: "Imported Sector"}}
+	data := []model.IrrigationData{{
+		ID:                 10,
+		FarmID:             1,
+		IrrigationSectorID: 1,
+		StartTime:          time.Date(2024, 4, 1, 6, 0, 0, 0, time.UTC),
+		EndTime:            time.Date(2024, 4, 1, 7, 0, 0, 0, time.UTC),
+		NominalAmount:      10,
+		RealAmount:         9,
+	}}
+	return farms, sectors, data
+}
+
+func TestImport_ConflictRollsBack(t *testing.T) {
+	db := setupTestDB(t)
+	seedBasicData(t, db)
+
+	repo := NewTransferRepository(db)
+	farms, sectors, data := importFixture()
+
+	err := repo.Import(context.Background(), farms, sectors, data, false)
+	var conflictErr *ImportConflictError
+	require.ErrorAs(t, err, &conflictErr)
+	assert.Equal(t, "farms", conflictErr.Table)
+	assert.Equal(t, []uint{1}, conflictErr.IDs)
+
+	var farm model.Farm
+	require.NoError(t, db.First(&farm, 1).Error)
+	assert.Equal(t, "Farm A", farm.Name)
+
+	var count int64
+	require.NoError(t, db.Model(&model.IrrigationData{}).Count(&count).Error)
+	assert.Equal(t, int64(3), count)
+}
+
+func TestImport_Overwrite(t *testing.T) {
+	db := setupTestDB(t)
+	seedBasicData(t, db)
+
+	repo := NewTransferRepository(db)
+	farms, sectors, data := importFixture()
+
+	require.NoError(t, repo.Import(context.Background(), farms, sectors, data, true))
+
+	var farm model.Farm
+	require.NoError(t, db.First(&farm, 1).Error)
+	assert.Equal(t, "Imported Farm", farm.Name)
+
+	var count int64
+	require.NoError(t, db.Model(&model.IrrigationData{}).Count(&count).Error)
+	assert.Equal(t, int64(4), count)
+}
+
+func TestImport_EmptyDatabase(t *testing.T) {
+	db := setupTestDB(t)
+
+	repo := NewTransferRepository(db)
+	farms, sectors, data := importFixture()
+
+	require.NoError(t, repo.Import(context.Background(), farms, sectors, data, false))
+
+	var imported model.IrrigationData
+	require.NoError(t, db.First(&imported, 10).Error)
+	assert.Equal(t, uint(1), imported.IrrigationSectorID)
+}
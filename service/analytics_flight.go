@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sebaespinosa/test_NF/model"
+)
+
+// GetAnalytics returns comprehensive irrigation analytics for a farm with year-over-year comparison
+// Identical concurrent requests share one computation: the first starts it and the rest wait for its
+// result, so a burst of dashboard refreshes costs the database a single set of queries. Nothing is kept
+// once the computation finishes, errors included; the next request computes afresh.
+// The shared computation is detached from the first caller's cancellation but keeps its deadline, so a
+// client hanging up does not fail the others; each caller still stops waiting when its own context ends.
+// The response is shared between the callers and must not be modified.
+func (s *IrrigationAnalyticsService) GetAnalytics(
+	ctx context.Context,
+	farmID uint,
+	startDate, endDate *time.Time,
+	sectorID *uint,
+	aggregation model.Aggregation,
+	page, limit int,
+	opts model.AnalyticsOptions,
+) (*model.IrrigationAnalyticsResponse, error) {
+	key := analyticsFlightKey(farmID, startDate, endDate, sectorID, aggregation, page, limit, opts)
+	results := s.flight.DoChan(key, func() (any, error) {
+		sharedCtx, cancel := detachedContext(ctx)
+		defer cancel()
+		return s.getAnalytics(sharedCtx, farmID, startDate, endDate, sectorID, aggregation, page, limit, opts)
+	})
+
+	select {
+	case result := <-results:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		return result.Val.(*model.IrrigationAnalyticsResponse), nil
+	case <-ctx.Done():
+		return nil, context.Cause(ctx)
+	}
+}
+
+// detachedContext keeps ctx's values (logger fields, trace) and deadline but not its cancellation
+func detachedContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	detached := context.WithoutCancel(ctx)
+	if deadline, ok := ctx.Deadline(); ok {
+		return context.WithDeadline(detached, deadline)
+	}
+	return context.WithCancel(detached)
+}
+
+// analyticsFlightKey identifies a GetAnalytics request; requests with equal keys get the same response
+func analyticsFlightKey(
+	farmID uint,
+	startDate, endDate *time.Time,
+	sectorID *uint,
+	aggregation model.Aggregation,
+	page, limit int,
+	opts model.AnalyticsOptions,
+) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "farm=%d|start=%s|end=%s|sector=%s|agg=%s|page=%d|limit=%d",
+		farmID, formatKeyTime(startDate), formatKeyTime(endDate), formatKeyValue(sectorID), aggregation, page, limit)
+	fmt.Fprintf(&b, "|whole=%t|sector_page=%d|sector_limit=%d|forecast=%t|compare=%s|efficiency_basis=%s|fields=%v|cumulative=%t|exclude_today=%t|anomalies_only=%t|quality=%t|stacked=%t|min_real=%s|max_real=%s|unbounded=%t|consistency=%s|group_by=%s|sector_sort=%s|sector_desc=%t",
+		opts.WholeDaysOnly, opts.SectorPage, opts.SectorLimit, opts.Forecast, opts.Compare, opts.EfficiencyBasis, opts.Fields, opts.Cumulative,
+		opts.ExcludeToday, opts.AnomaliesOnly, opts.IncludeQuality, opts.IncludeStackedTimeSeries, formatKeyValue(opts.MinReal), formatKeyValue(opts.MaxReal), opts.UnboundedLimit, opts.Consistency, opts.SectorGroupBy,
+		opts.SectorSort.Field, opts.SectorSort.Descending)
+	return b.String()
+}
+
+func formatKeyTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func formatKeyValue[T any](v *T) string {
+	if v == nil {
+		return "-"
+	}
+	return fmt.Sprint(*v)
+}
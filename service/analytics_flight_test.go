@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sebaespinosa/test_NF/model"
+	"github.com/sebaespinosa/test_NF/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newBlockingRepo returns a repository whose analytics query counts its calls, reports on started and
+// blocks until release is closed
+func newBlockingRepo(calls *atomic.Int32, started chan<- struct{}, release <-chan struct{}, err error) *mockAnalyticsRepo {
+	return &mockAnalyticsRepo{
+		getAnalyticsFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation, limit, offset int) ([]repository.AnalyticsAggregation, int64, error) {
+			calls.Add(1)
+			started <- struct{}{}
+			<-release
+			if err != nil {
+				return nil, 0, err
+			}
+			return []repository.AnalyticsAggregation{{Period: "2024-03-01", TotalRealAmount: 10, TotalNominalAmount: 12, EventCount: 1}}, 1, nil
+		},
+		getYoYFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) (map[int]repository.YoYAnalyticsData, error) {
+			return map[int]repository.YoYAnalyticsData{}, nil
+		},
+		getSectorFn: func(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time, limit, offset int) ([]repository.SectorAnalyticsData, int64, error) {
+			return nil, 0, nil
+		},
+	}
+}
+
+func TestGetAnalytics_ConcurrentIdenticalRequestsShareQuery(t *testing.T) {
+	const requests = 20
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+
+	var calls atomic.Int32
+	started := make(chan struct{}, requests)
+	release := make(chan struct{})
+	svc := NewIrrigationAnalyticsService(newBlockingRepo(&calls, started, release, nil), newTestLogger(t), newTestAnalyticsConfig())
+
+	var wg sync.WaitGroup
+	responses := make([]*model.IrrigationAnalyticsResponse, requests)
+	errs := make([]error, requests)
+	get := func(i int) {
+		defer wg.Done()
+		responses[i], errs[i] = svc.GetAnalytics(context.Background(), 1, &start, &end, nil, model.AggregationDaily, 1, 50, model.AnalyticsOptions{})
+	}
+
+	// Hold the first request in the repository while the rest arrive
+	wg.Add(requests)
+	go get(0)
+	<-started
+	for i := 1; i < requests; i++ {
+		go get(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+	for i := range requests {
+		require.NoError(t, errs[i])
+		assert.Same(t, responses[0], responses[i])
+	}
+
+	// Requests with different parameters do not share
+	otherEnd := end.AddDate(0, 0, -1)
+	_, err := svc.GetAnalytics(context.Background(), 1, &start, &otherEnd, nil, model.AggregationDaily, 1, 50, model.AnalyticsOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestGetAnalytics_SharedErrorNotRetained(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+	errExpected := errors.New("db error")
+
+	var calls atomic.Int32
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	close(release)
+	svc := NewIrrigationAnalyticsService(newBlockingRepo(&calls, started, release, errExpected), newTestLogger(t), newTestAnalyticsConfig())
+
+	for range 2 {
+		_, err := svc.GetAnalytics(context.Background(), 1, &start, &end, nil, model.AggregationDaily, 1, 50, model.AnalyticsOptions{})
+		require.ErrorIs(t, err, errExpected)
+	}
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestGetAnalytics_LeaderCancellationDoesNotFailFollowers(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+
+	var calls atomic.Int32
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	svc := NewIrrigationAnalyticsService(newBlockingRepo(&calls, started, release, nil), newTestLogger(t), newTestAnalyticsConfig())
+
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := svc.GetAnalytics(leaderCtx, 1, &start, &end, nil, model.AggregationDaily, 1, 50, model.AnalyticsOptions{})
+		leaderErr <- err
+	}()
+	<-started
+
+	followerResp := make(chan *model.IrrigationAnalyticsResponse, 1)
+	go func() {
+		resp, err := svc.GetAnalytics(context.Background(), 1, &start, &end, nil, model.AggregationDaily, 1, 50, model.AnalyticsOptions{})
+		assert.NoError(t, err)
+		followerResp <- resp
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// The leader gives up; the follower still gets the shared result
+	cancelLeader()
+	require.ErrorIs(t, <-leaderErr, context.Canceled)
+	close(release)
+	assert.NotNil(t, <-followerResp)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestAnalyticsFlightKey(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	minReal := 5.0
+	otherMinReal := 5.0
+
+	base := analyticsFlightKey(1, &start, nil, nil, model.AggregationDaily, 1, 50, model.AnalyticsOptions{MinReal: &minReal})
+	assert.Equal(t, base, analyticsFlightKey(1, &start, nil, nil, model.AggregationDaily, 1, 50, model.AnalyticsOptions{MinReal: &otherMinReal}), "pointers compare by value")
+	assert.NotEqual(t, base, analyticsFlightKey(1, &start, nil, nil, model.AggregationDaily, 1, 50, model.AnalyticsOptions{}))
+	assert.NotEqual(t, base, analyticsFlightKey(1, &start, nil, uintPtr(2), model.AggregationDaily, 1, 50, model.AnalyticsOptions{MinReal: &minReal}))
+	assert.NotEqual(t, base, analyticsFlightKey(1, &start, nil, nil, model.AggregationDaily, 2, 50, model.AnalyticsOptions{MinReal: &minReal}))
+}
@@ -1,15 +1,19 @@
 package service
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/sebaespinosa/test_NF/internal/logging"
 	"github.com/sebaespinosa/test_NF/model"
 	"github.com/sebaespinosa/test_NF/repository"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 // FarmService handles business logic for farm operations
@@ -44,6 +48,20 @@ func (s *FarmService) Create(ctx context.Context, farm *model.Farm) error {
 	return s.repo.Create(ctx, farm)
 }
 
+// SetHealthyThreshold sets or clears (nil) the farm's healthy efficiency threshold and returns the updated farm
+// Returns ErrFarmNotFound when the farm does not exist
+func (s *FarmService) SetHealthyThreshold(ctx context.Context, id uint, threshold *float64) (*model.Farm, error) {
+	s.logger.WithContext(ctx).Info("setting farm healthy efficiency threshold", zap.Uint("farm_id", id))
+	if err := s.repo.UpdateHealthyThreshold(ctx, id, threshold); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrFarmNotFound
+		}
+		s.logger.WithContext(ctx).Error("failed to update farm", zap.Error(err))
+		return nil, err
+	}
+	return s.repo.FindByID(ctx, id)
+}
+
 // Delete deletes a farm by ID
 func (s *FarmService) Delete(ctx context.Context, id uint) error {
 	s.logger.WithContext(ctx).Info("deleting farm", zap.Uint("farm_id", id))
@@ -57,7 +75,196 @@ type SeedData struct {
 	IrrigationData    []model.IrrigationData   `json:"irrigation_data"`
 }
 
+// SeedTooLargeError is returned by DecodeSeedData when a section holds more records than allowed
+type SeedTooLargeError struct {
+	Section string
+	Limit   int
+}
+
+func (e *SeedTooLargeError) Error() string {
+	return fmt.Sprintf("%s has more than %d records; split the import into smaller parts", e.Section, e.Limit)
+}
+
+// DecodeSeedData decodes a body in the seed file format, rejecting unknown fields like LoadSeedData
+// Records are read one at a time, so a section over maxRecordsPerSection is rejected with a
+// *SeedTooLargeError before the rest of it is materialized; 0 means no limit
+func DecodeSeedData(r io.Reader, maxRecordsPerSection int) (*SeedData, error) {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+
+	var seed SeedData
+	if err := expectDelim(decoder, '{'); err != nil {
+		return nil, err
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		section, _ := token.(string)
+		switch section {
+		case "farms":
+			err = decodeSeedSection(decoder, section, maxRecordsPerSection, &seed.Farms)
+		case "irrigation_sectors":
+			err = decodeSeedSection(decoder, section, maxRecordsPerSection, &seed.IrrigationSectors)
+		case "irrigation_data":
+			err = decodeSeedSection(decoder, section, maxRecordsPerSection, &seed.IrrigationData)
+		default:
+			err = fmt.Errorf("json: unknown field %q", section)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := expectDelim(decoder, '}'); err != nil {
+		return nil, err
+	}
+	return &seed, nil
+}
+
+// decodeSeedSection appends the records of one array section (or nothing for null) to records
+func decodeSeedSection[T any](decoder *json.Decoder, section string, maxRecords int, records *[]T) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		return nil
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("json: %s must be an array", section)
+	}
+	for decoder.More() {
+		if maxRecords > 0 && len(*records) >= maxRecords {
+			return &SeedTooLargeError{Section: section, Limit: maxRecords}
+		}
+		var record T
+		if err := decoder.Decode(&record); err != nil {
+			return err
+		}
+		*records = append(*records, record)
+	}
+	return expectDelim(decoder, ']')
+}
+
+// expectDelim consumes the next token and fails unless it is want
+func expectDelim(decoder *json.Decoder, want json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != want {
+		return fmt.Errorf("json: expected %q, got %v", want, token)
+	}
+	return nil
+}
+
+// SeedValidationError lists every problem found in seed data; nothing is imported when returned
+// Field names are prefixed with their collection, e.g. "irrigation_sectors.farm_id"
+type SeedValidationError struct {
+	Violations []model.ValidationViolation
+}
+
+func (e *SeedValidationError) Error() string {
+	return fmt.Sprintf("seed data contains %d invalid field(s)", len(e.Violations))
+}
+
+// Validate checks seed data for required fields, duplicate IDs and referential integrity
+// Sectors must reference a farm in the same payload and a parent sector of that farm, and irrigation data
+// a sector of its own farm
+// Returns a *SeedValidationError listing every violation, or nil when the data is consistent
+func (d *SeedData) Validate() error {
+	var violations []model.ValidationViolation
+	add := func(index int, field, reason string) {
+		violations = append(violations, model.ValidationViolation{Index: index, Field: field, Reason: reason})
+	}
+
+	farms := make(map[uint]bool, len(d.Farms))
+	for i, farm := range d.Farms {
+		switch {
+		case farm.ID == 0:
+			add(i, "farms.id", "is required")
+		case farms[farm.ID]:
+			add(i, "farms.id", fmt.Sprintf("duplicate id %d", farm.ID))
+		}
+		farms[farm.ID] = true
+		if farm.Name == "" {
+			add(i, "farms.name", "is required")
+		}
+	}
+
+	sectorFarms := make(map[uint]uint, len(d.IrrigationSectors))
+	for i, sector := range d.IrrigationSectors {
+		_, seen := sectorFarms[sector.ID]
+		switch {
+		case sector.ID == 0:
+			add(i, "irrigation_sectors.id", "is required")
+		case seen:
+			add(i, "irrigation_sectors.id", fmt.Sprintf("duplicate id %d", sector.ID))
+		}
+		if sector.Name == "" {
+			add(i, "irrigation_sectors.name", "is required")
+		}
+		if !farms[sector.FarmID] {
+			add(i, "irrigation_sectors.farm_id", fmt.Sprintf("references unknown farm %d", sector.FarmID))
+		}
+		if sector.ID != 0 {
+			sectorFarms[sector.ID] = sector.FarmID
+		}
+	}
+
+	// A zone groups sectors of one farm, so a parent must be another sector of the same farm
+	for i, sector := range d.IrrigationSectors {
+		if sector.ParentSectorID == nil {
+			continue
+		}
+		parent := *sector.ParentSectorID
+		if parentFarm, ok := sectorFarms[parent]; !ok {
+			add(i, "irrigation_sectors.parent_sector_id", fmt.Sprintf("references unknown sector %d", parent))
+		} else if parent == sector.ID {
+			add(i, "irrigation_sectors.parent_sector_id", "must not be the sector itself")
+		} else if parentFarm != sector.FarmID {
+			add(i, "irrigation_sectors.parent_sector_id", fmt.Sprintf("sector %d belongs to farm %d", parent, parentFarm))
+		}
+	}
+
+	dataIDs := make(map[uint]bool, len(d.IrrigationData))
+	for i, item := range d.IrrigationData {
+		if item.ID != 0 {
+			if dataIDs[item.ID] {
+				add(i, "irrigation_data.id", fmt.Sprintf("duplicate id %d", item.ID))
+			}
+			dataIDs[item.ID] = true
+		}
+		if !farms[item.FarmID] {
+			add(i, "irrigation_data.farm_id", fmt.Sprintf("references unknown farm %d", item.FarmID))
+		}
+		if sectorFarm, ok := sectorFarms[item.IrrigationSectorID]; !ok {
+			add(i, "irrigation_data.irrigation_sector_id", fmt.Sprintf("references unknown sector %d", item.IrrigationSectorID))
+		} else if sectorFarm != item.FarmID {
+			add(i, "irrigation_data.irrigation_sector_id", fmt.Sprintf("sector %d belongs to farm %d", item.IrrigationSectorID, sectorFarm))
+		}
+		if item.StartTime.IsZero() || item.EndTime.IsZero() {
+			add(i, "irrigation_data.start_time", "start_time and end_time are required")
+		} else if !item.EndTime.After(item.StartTime) {
+			add(i, "irrigation_data.end_time", "must be after start_time")
+		}
+		if item.NominalAmount < 0 {
+			add(i, "irrigation_data.nominal_amount", "must be >= 0")
+		}
+		if item.RealAmount < 0 {
+			add(i, "irrigation_data.real_amount", "must be >= 0")
+		}
+	}
+
+	if len(violations) > 0 {
+		return &SeedValidationError{Violations: violations}
+	}
+	return nil
+}
+
 // LoadSeedData loads seed data from a JSON file
+// Unknown fields are rejected so typos in seed files (e.g. "nominal_ammount") surface as errors
 func (s *FarmService) LoadSeedData(filePath string) (*SeedData, error) {
 	s.logger.Info("loading seed data", zap.String("file_path", filePath))
 
@@ -67,8 +274,10 @@ func (s *FarmService) LoadSeedData(filePath string) (*SeedData, error) {
 	}
 
 	var seedData SeedData
-	if err := json.Unmarshal(data, &seedData); err != nil {
-		return nil, fmt.Errorf("failed to parse seed data: %w", err)
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&seedData); err != nil {
+		return nil, fmt.Errorf("failed to parse seed data %s: %w", filePath, err)
 	}
 
 	s.logger.Info("seed data loaded",
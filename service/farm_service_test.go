@@ -0,0 +1,91 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeSeedFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "seed.json")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadSeedData_Valid(t *testing.T) {
+	svc := NewFarmService(nil, newTestLogger(t))
+	path := writeSeedFile(t, `{
+		"farms": [{"id": 1, "name": "Farm A"}],
+		"irrigation_sectors": [{"id": 1, "farm_id": 1, "name": "Sector A"}],
+		"irrigation_data": [{"id": 1, "farm_id": 1, "irrigation_sector_id": 1, "start_time": "2024-03-01T06:00:00Z", "end_time": "2024-03-01T07:00:00Z", "nominal_amount": 20, "real_amount": 18}]
+	}`)
+
+	seedData, err := svc.LoadSeedData(path)
+	require.NoError(t, err)
+	assert.Len(t, seedData.Farms, 1)
+	assert.Len(t, seedData.IrrigationSectors, 1)
+	assert.Len(t, seedData.IrrigationData, 1)
+}
+
+func TestLoadSeedData_UnknownField(t *testing.T) {
+	svc := NewFarmService(nil, newTestLogger(t))
+	path := writeSeedFile(t, `{
+		"farms": [{"id": 1, "name": "Farm A"}],
+		"irrigation_data": [{"id": 1, "farm_id": 1, "irrigation_sector_id": 1, "start_time": "2024-03-01T06:00:00Z", "end_time": "2024-03-01T07:00:00Z", "nominal_ammount": 20, "real_amount": 18}]
+	}`)
+
+	_, err := svc.LoadSeedData(path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown field "nominal_ammount"`)
+}
+
+func TestSeedData_Validate_BundledSeed(t *testing.T) {
+	svc := NewFarmService(nil, newTestLogger(t))
+
+	seedData, err := svc.LoadSeedData("../internal/seeds/irrigation_seed.json")
+	require.NoError(t, err)
+	assert.NoError(t, seedData.Validate())
+}
+
+func TestSeedData_Validate_ReferentialIntegrity(t *testing.T) {
+	svc := NewFarmService(nil, newTestLogger(t))
+	path := writeSeedFile(t, `{
+		"farms": [{"id": 1, "name": "Farm A"}, {"id": 2, "name": "Farm B"}],
+		"irrigation_sectors": [
+			{"id": 1, "farm_id": 1, "name": "Sector A"},
+			{"id": 2, "farm_id": 9, "name": "Orphan"},
+			{"id": 3, "farm_id": 2, "name": "Cross-farm child", "parent_sector_id": 1},
+			{"id": 4, "farm_id": 1, "name": "Unknown parent", "parent_sector_id": 99},
+			{"id": 5, "farm_id": 1, "name": "Own parent", "parent_sector_id": 5},
+			{"id": 6, "farm_id": 1, "name": "Zone child", "parent_sector_id": 1}
+		],
+		"irrigation_data": [
+			{"id": 1, "farm_id": 2, "irrigation_sector_id": 1, "start_time": "2024-03-01T06:00:00Z", "end_time": "2024-03-01T07:00:00Z", "nominal_amount": 20, "real_amount": 18},
+			{"id": 1, "farm_id": 1, "irrigation_sector_id": 1, "start_time": "2024-03-01T06:00:00Z", "end_time": "2024-03-01T05:00:00Z", "nominal_amount": 20, "real_amount": 18}
+		]
+	}`)
+
+	seedData, err := svc.LoadSeedData(path)
+	require.NoError(t, err)
+
+	var validationErr *SeedValidationError
+	require.ErrorAs(t, seedData.Validate(), &validationErr)
+
+	fields := make([]string, 0, len(validationErr.Violations))
+	for _, v := range validationErr.Violations {
+		fields = append(fields, v.Field)
+	}
+	assert.ElementsMatch(t, []string{
+		"irrigation_sectors.farm_id",
+		"irrigation_sectors.parent_sector_id",
+		"irrigation_sectors.parent_sector_id",
+		"irrigation_sectors.parent_sector_id",
+		"irrigation_data.irrigation_sector_id",
+		"irrigation_data.id",
+		"irrigation_data.end_time",
+	}, fields)
+}
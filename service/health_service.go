@@ -2,47 +2,181 @@ package service
 
 import (
 	"context"
+	"fmt"
+	"sync"
+	"time"
 
 	"github.com/sebaespinosa/test_NF/internal/logging"
 	"github.com/sebaespinosa/test_NF/model"
-	"github.com/sebaespinosa/test_NF/repository"
 	"go.uber.org/zap"
 )
 
+// HealthChecker defines the data access contract for health checks.
+type HealthChecker interface {
+	CheckDatabaseHealth(ctx context.Context) error
+}
+
+// CachePinger reports whether a cache backend is reachable
+// A Redis-backed cache pings the server; an in-memory cache always returns nil
+type CachePinger interface {
+	Ping(ctx context.Context) error
+}
+
 // HealthService handles business logic for health checks
+// Readiness results are cached for cacheTTL so frequent load-balancer probes don't each hit the database
+// Readiness turns unhealthy only after failureThreshold consecutive failed checks, so a brief database
+// blip doesn't flap the pod out of rotation; the first successful check recovers it
 type HealthService struct {
-	repo    *repository.HealthRepository
-	logger  *logging.Logger
-	version string
+	repo             HealthChecker
+	cache            CachePinger
+	logger           *logging.Logger
+	version          string
+	cacheTTL         time.Duration
+	failureThreshold int
+	now              func() time.Time
+
+	mu        sync.Mutex
+	cached    *model.HealthResponse
+	checkedAt time.Time
+	failures  int
 }
 
 // NewHealthService creates a new instance of HealthService
-func NewHealthService(repo *repository.HealthRepository, logger *logging.Logger, version string) *HealthService {
+// A cacheTTL of zero or less checks the database on every call
+func NewHealthService(repo HealthChecker, logger *logging.Logger, version string, cacheTTL time.Duration) *HealthService {
+	return &HealthService{
+		repo:             repo,
+		logger:           logger,
+		version:          version,
+		cacheTTL:         cacheTTL,
+		failureThreshold: 1,
+		now:              time.Now,
+	}
+}
+
+// WithCache returns a copy of the service that also reports the cache in GetComponents
+func (s *HealthService) WithCache(cache CachePinger) *HealthService {
+	return &HealthService{
+		repo:             s.repo,
+		cache:            cache,
+		logger:           s.logger,
+		version:          s.version,
+		cacheTTL:         s.cacheTTL,
+		failureThreshold: s.failureThreshold,
+		now:              s.now,
+	}
+}
+
+// WithFailureThreshold returns a copy of the service whose readiness turns unhealthy only after
+// threshold consecutive failed database checks; values below 1 mean the first failure
+func (s *HealthService) WithFailureThreshold(threshold int) *HealthService {
 	return &HealthService{
-		repo:    repo,
-		logger:  logger,
-		version: version,
+		repo:             s.repo,
+		cache:            s.cache,
+		logger:           s.logger,
+		version:          s.version,
+		cacheTTL:         s.cacheTTL,
+		failureThreshold: max(threshold, 1),
+		now:              s.now,
+	}
+}
+
+// GetLiveness reports that the process is up without touching any dependency
+func (s *HealthService) GetLiveness() *model.HealthResponse {
+	return &model.HealthResponse{
+		Status:  "alive",
+		Message: "service is running",
+		Version: s.version,
 	}
 }
 
-// GetHealth returns the health status of the service
+// GetHealth returns the readiness status of the service, including the database
+// Results (healthy or not) are reused for up to cacheTTL, so a state change shows within one TTL
 func (s *HealthService) GetHealth(ctx context.Context) (*model.HealthResponse, error) {
+	s.mu.Lock()
+	if s.cached != nil && s.now().Sub(s.checkedAt) < s.cacheTTL {
+		cached := *s.cached
+		s.mu.Unlock()
+		return &cached, nil
+	}
+	s.mu.Unlock()
+
 	s.logger.WithContext(ctx).Info("checking service health")
 
 	// Check database health
-	if err := s.repo.CheckDatabaseHealth(ctx); err != nil {
-		s.logger.WithContext(ctx).Error("database health check failed", zap.Error(err))
-		return &model.HealthResponse{
+	err := s.repo.CheckDatabaseHealth(ctx)
+
+	s.mu.Lock()
+	var health *model.HealthResponse
+	switch {
+	case err == nil:
+		s.failures = 0
+		s.logger.WithContext(ctx).Info("health check passed")
+		health = &model.HealthResponse{
+			Status:  "healthy",
+			Message: "service is running",
+			Version: s.version,
+		}
+	case s.failures+1 < s.failureThreshold:
+		s.failures++
+		s.logger.WithContext(ctx).Warn("database health check failed; still reporting ready",
+			zap.Error(err), zap.Int("consecutive_failures", s.failures), zap.Int("failure_threshold", s.failureThreshold))
+		health = &model.HealthResponse{
+			Status:  "healthy",
+			Message: fmt.Sprintf("database check failed (%d of %d consecutive failures tolerated)", s.failures, s.failureThreshold-1),
+			Version: s.version,
+		}
+	default:
+		s.failures++
+		s.logger.WithContext(ctx).Error("database health check failed", zap.Error(err), zap.Int("consecutive_failures", s.failures))
+		health = &model.HealthResponse{
 			Status:  "unhealthy",
 			Message: "database connection failed",
 			Version: s.version,
-		}, nil
+		}
 	}
+	s.cached = health
+	s.checkedAt = s.now()
+	s.mu.Unlock()
 
-	s.logger.WithContext(ctx).Info("health check passed")
-	return &model.HealthResponse{
-		Status:  "healthy",
-		Message: "service is running",
-		Version: s.version,
-	}, nil
+	result := *health
+	return &result, nil
+}
+
+// GetComponents checks each dependency on every call, bypassing the readiness cache
+// The database is required, so its failure makes the service unhealthy; the cache is optional
+// because analytics fall back to direct computation, so its failure only degrades the service
+func (s *HealthService) GetComponents(ctx context.Context) *model.HealthComponentsResponse {
+	components := []model.HealthComponent{{Name: "database", Status: "healthy", Message: "database reachable", Required: true}}
+	if err := s.repo.CheckDatabaseHealth(ctx); err != nil {
+		s.logger.WithContext(ctx).Error("database health check failed", zap.Error(err))
+		components[0].Status = "unhealthy"
+		components[0].Message = "database connection failed"
+	}
+
+	if s.cache != nil {
+		cache := model.HealthComponent{Name: "cache", Status: "healthy", Message: "cache reachable"}
+		if err := s.cache.Ping(ctx); err != nil {
+			s.logger.WithContext(ctx).Warn("cache health check failed", zap.Error(err))
+			cache.Status = "degraded"
+			cache.Message = "cache unreachable; analytics computed directly"
+		}
+		components = append(components, cache)
+	}
+
+	status := "healthy"
+	for _, component := range components {
+		switch {
+		case component.Status == "unhealthy":
+			status = "unhealthy"
+		case component.Status == "degraded" && status == "healthy":
+			status = "degraded"
+		}
+	}
+
+	return &model.HealthComponentsResponse{
+		Status:     status,
+		Version:    s.version,
+		Components: components,
+	}
 }
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubHealthChecker struct {
+	err   error
+	calls int
+}
+
+func (s *stubHealthChecker) CheckDatabaseHealth(ctx context.Context) error {
+	s.calls++
+	return s.err
+}
+
+func TestGetHealth_CacheReflectsFailureWithinTTL(t *testing.T) {
+	repo := &stubHealthChecker{}
+	svc := NewHealthService(repo, newTestLogger(t), "1.0.0", 5*time.Second)
+
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	health, err := svc.GetHealth(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "healthy", health.Status)
+	assert.Equal(t, 1, repo.calls)
+
+	// The database fails mid-window; the cached result is still served
+	repo.err = errors.New("connection refused")
+	now = now.Add(3 * time.Second)
+	health, err = svc.GetHealth(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "healthy", health.Status)
+	assert.Equal(t, 1, repo.calls)
+
+	// Once the TTL elapses the failure is visible
+	now = now.Add(2 * time.Second)
+	health, err = svc.GetHealth(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "unhealthy", health.Status)
+	assert.Equal(t, 2, repo.calls)
+}
+
+func TestGetHealth_FailureThreshold(t *testing.T) {
+	repo := &stubHealthChecker{}
+	svc := NewHealthService(repo, newTestLogger(t), "1.0.0", 0).WithFailureThreshold(3)
+	ctx := context.Background()
+	status := func() string {
+		health, err := svc.GetHealth(ctx)
+		require.NoError(t, err)
+		return health.Status
+	}
+
+	// Two transient failures stay below the threshold
+	repo.err = errors.New("connection refused")
+	assert.Equal(t, "healthy", status())
+	assert.Equal(t, "healthy", status())
+
+	// A success resets the count, so two more failures are tolerated again
+	repo.err = nil
+	assert.Equal(t, "healthy", status())
+	repo.err = errors.New("connection refused")
+	assert.Equal(t, "healthy", status())
+	assert.Equal(t, "healthy", status())
+
+	// The third consecutive failure reaches the threshold
+	assert.Equal(t, "unhealthy", status())
+	assert.Equal(t, "unhealthy", status())
+
+	// Recovery is immediate on the first success
+	repo.err = nil
+	assert.Equal(t, "healthy", status())
+	assert.Equal(t, 8, repo.calls)
+}
+
+func TestGetHealth_DefaultThresholdFailsImmediately(t *testing.T) {
+	svc := NewHealthService(&stubHealthChecker{err: errors.New("connection refused")}, newTestLogger(t), "1.0.0", 0)
+
+	health, err := svc.GetHealth(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "unhealthy", health.Status)
+}
+
+func TestGetHealth_NoCache(t *testing.T) {
+	repo := &stubHealthChecker{}
+	svc := NewHealthService(repo, newTestLogger(t), "1.0.0", 0)
+	ctx := context.Background()
+
+	_, err := svc.GetHealth(ctx)
+	require.NoError(t, err)
+	_, err = svc.GetHealth(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, repo.calls)
+}
+
+func TestGetLiveness_SkipsDatabase(t *testing.T) {
+	repo := &stubHealthChecker{err: errors.New("connection refused")}
+	svc := NewHealthService(repo, newTestLogger(t), "1.0.0", 5*time.Second)
+
+	health := svc.GetLiveness()
+	assert.Equal(t, "alive", health.Status)
+	assert.Equal(t, "1.0.0", health.Version)
+	assert.Zero(t, repo.calls)
+}
+
+type stubCachePinger struct {
+	err error
+}
+
+func (s *stubCachePinger) Ping(ctx context.Context) error {
+	return s.err
+}
+
+func TestGetComponents(t *testing.T) {
+	tests := []struct {
+		name        string
+		dbErr       error
+		cache       CachePinger
+		status      string
+		cacheStatus string
+	}{
+		{name: "no cache configured", status: "healthy"},
+		{name: "reachable cache", cache: &stubCachePinger{}, status: "healthy", cacheStatus: "healthy"},
+		{name: "YoY cache", cache: NewYoYCache(), status: "healthy", cacheStatus: "healthy"},
+		{name: "unreachable cache", cache: &stubCachePinger{err: errors.New("dial tcp: connection refused")}, status: "degraded", cacheStatus: "degraded"},
+		{name: "database down", dbErr: errors.New("connection refused"), cache: &stubCachePinger{err: errors.New("timeout")}, status: "unhealthy", cacheStatus: "degraded"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := NewHealthService(&stubHealthChecker{err: tt.dbErr}, newTestLogger(t), "1.0.0", 5*time.Second)
+			if tt.cache != nil {
+				svc = svc.WithCache(tt.cache)
+			}
+
+			health := svc.GetComponents(context.Background())
+			assert.Equal(t, tt.status, health.Status)
+			require.NotEmpty(t, health.Components)
+			assert.Equal(t, "database", health.Components[0].Name)
+			assert.True(t, health.Components[0].Required)
+
+			if tt.cacheStatus == "" {
+				assert.Len(t, health.Components, 1)
+				return
+			}
+			require.Len(t, health.Components, 2)
+			assert.Equal(t, "cache", health.Components[1].Name)
+			assert.Equal(t, tt.cacheStatus, health.Components[1].Status)
+			assert.False(t, health.Components[1].Required)
+		})
+	}
+}
@@ -2,136 +2,1277 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
+	"slices"
 	"time"
 
+	"github.com/sebaespinosa/test_NF/config"
 	"github.com/sebaespinosa/test_NF/internal/logging"
 	"github.com/sebaespinosa/test_NF/model"
 	"github.com/sebaespinosa/test_NF/repository"
 	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+	"gorm.io/gorm"
 )
 
 // IrrigationAnalyticsService handles business logic for irrigation analytics
 type IrrigationAnalyticsService struct {
-	repo   AnalyticsRepository
-	logger *logging.Logger
+	repo       AnalyticsRepository
+	sectors    SectorFinder
+	farms      FarmFinder
+	thresholds *thresholdCache
+	yoy        *YoYCache
+	flight     *singleflight.Group
+	logger     *logging.Logger
+	cfg        *config.AnalyticsConfig
+	now        func() time.Time
 }
 
+// SectorFinder looks up a single irrigation sector; implemented by repository.IrrigationSectorRepository
+type SectorFinder interface {
+	FindByID(ctx context.Context, id uint) (*model.IrrigationSector, error)
+}
+
+// FarmFinder looks up a single farm; implemented by repository.FarmRepository
+type FarmFinder interface {
+	FindByID(ctx context.Context, id uint) (*model.Farm, error)
+}
+
+// SectorNotInFarmError is returned when a sector_id filter names a sector of another farm
+type SectorNotInFarmError struct {
+	SectorID uint
+	FarmID   uint
+}
+
+func (e *SectorNotInFarmError) Error() string {
+	return fmt.Sprintf("irrigation sector %d does not belong to farm %d", e.SectorID, e.FarmID)
+}
+
+// ErrFarmExcluded is returned for farms listed in ANALYTICS_EXCLUDED_FARMS, such as decommissioned or test farms
+var ErrFarmExcluded = errors.New("farm is excluded from analytics")
+
 // AnalyticsRepository defines the data access contract for analytics operations.
 type AnalyticsRepository interface {
-	GetAnalyticsForFarmByDateRange(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation string, limit, offset int) ([]repository.AnalyticsAggregation, int64, error)
-	GetYoYComparison(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation string) (map[int]repository.YoYAnalyticsData, error)
-	GetSectorBreakdownForFarm(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time) ([]repository.SectorAnalyticsData, error)
+	GetAnalyticsForFarmByDateRange(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation, limit, offset int) ([]repository.AnalyticsAggregation, int64, bool, error)
+	GetYoYComparison(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) (map[int]repository.YoYAnalyticsData, error)
+	GetSectorBreakdownForFarm(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time, limit, offset int) ([]repository.SectorAnalyticsData, int64, error)
+	GetSectorRanking(ctx context.Context, farmID uint, startTime, endTime time.Time, limit, offset int) ([]repository.SectorAnalyticsData, int64, error)
+	GetSectorBreakdownForFarms(ctx context.Context, farmIDs []uint, startTime, endTime time.Time) ([]repository.FarmSectorAnalyticsData, error)
+	GetSectorTimeSeriesForFarm(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) ([]repository.SectorTimeSeriesData, error)
+	GetFarmTimeSeriesBySector(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) ([]repository.PeriodSectorData, error)
+	GetTopIrrigationDays(ctx context.Context, farmID uint, startTime, endTime time.Time, n int) ([]repository.DailyTotalData, error)
+	GetDayOfWeekDistribution(ctx context.Context, farmID uint, startTime, endTime time.Time) ([]repository.DayOfWeekData, error)
+	GetEfficiencyByAmount(ctx context.Context, farmID uint, startTime, endTime time.Time, bins int) ([]repository.AmountBinData, error)
+	CountActiveSectors(ctx context.Context, farmID uint, startTime, endTime time.Time) (int, error)
+	GetSectorScheduleForFarm(ctx context.Context, farmID uint, startTime, endTime time.Time) ([]repository.SectorScheduleData, error)
+	GetFirstEventTimeForFarm(ctx context.Context, farmID uint) (*time.Time, error)
+	GetDataQualityForFarm(ctx context.Context, farmID uint, startTime, endTime time.Time) (repository.DataQualityData, error)
+}
+
+// Approximate serialized sizes used by checkResponseSize, with headroom for long numbers and names
+const (
+	estimatedTimeSeriesEntryBytes = 160 // one TimeSeriesEntry
+	estimatedSectorEntryBytes     = 220 // one SectorBreakdown without its sparkline
+	estimatedSparklinePointBytes  = 20  // one sparkline value
+	estimatedStackedEntryBytes    = 40  // one StackedTimeSeriesEntry without its sectors
+	estimatedStackedSectorBytes   = 130 // one StackedSectorAmounts
+)
+
+// ResponseTooLargeError is returned when the estimated response payload exceeds the configured limit
+type ResponseTooLargeError struct {
+	EstimatedBytes int
+	LimitBytes     int
+}
+
+func (e *ResponseTooLargeError) Error() string {
+	return fmt.Sprintf(
+		"estimated analytics response of %d bytes exceeds the %d byte limit; use a coarser aggregation, a shorter date range, a smaller limit, or fewer sections",
+		e.EstimatedBytes, e.LimitBytes,
+	)
 }
 
 // NewIrrigationAnalyticsService creates a new IrrigationAnalyticsService instance
 func NewIrrigationAnalyticsService(
 	repo AnalyticsRepository,
 	logger *logging.Logger,
+	cfg *config.AnalyticsConfig,
 ) *IrrigationAnalyticsService {
 	return &IrrigationAnalyticsService{
 		repo:   repo,
+		flight: &singleflight.Group{},
 		logger: logger,
+		cfg:    cfg,
+		now:    time.Now,
+	}
+}
+
+// WithSectorFinder returns a copy of the service that checks a sector_id filter belongs to the farm
+// before running any analytics query
+func (s *IrrigationAnalyticsService) WithSectorFinder(sectors SectorFinder) *IrrigationAnalyticsService {
+	clone := *s
+	clone.sectors = sectors
+	return &clone
+}
+
+// WithFarmFinder returns a copy of the service that looks up the farm's healthy efficiency threshold
+// to set the analytics status; without it only ANALYTICS_HEALTHY_EFFICIENCY_THRESHOLD applies
+func (s *IrrigationAnalyticsService) WithFarmFinder(farms FarmFinder) *IrrigationAnalyticsService {
+	clone := *s
+	clone.farms = farms
+	clone.thresholds = newThresholdCache()
+	return &clone
+}
+
+// WithYoYCache returns a copy of the service that answers default-range YoY comparisons from cache
+// when YoYCacheJob has precomputed them, falling back to the query otherwise
+func (s *IrrigationAnalyticsService) WithYoYCache(cache *YoYCache) *IrrigationAnalyticsService {
+	clone := *s
+	clone.yoy = cache
+	return &clone
+}
+
+// farmExcluded reports whether the farm is configured to be left out of analytics
+func (s *IrrigationAnalyticsService) farmExcluded(farmID uint) bool {
+	return slices.Contains(s.cfg.ExcludedFarmIDs, farmID)
+}
+
+// healthyThreshold returns the farm's healthy efficiency threshold, falling back to
+// ANALYTICS_HEALTHY_EFFICIENCY_THRESHOLD; nil when neither is set
+func (s *IrrigationAnalyticsService) healthyThreshold(ctx context.Context, farmID uint) (*float64, error) {
+	if s.farms != nil {
+		own, err := s.farmThreshold(ctx, farmID)
+		if err != nil {
+			return nil, err
+		}
+		if own != nil {
+			return own, nil
+		}
+	}
+	if s.cfg.HealthyEfficiency > 0 {
+		threshold := s.cfg.HealthyEfficiency
+		return &threshold, nil
 	}
+	return nil, nil
 }
 
-// GetAnalytics returns comprehensive irrigation analytics for a farm with year-over-year comparison
-func (s *IrrigationAnalyticsService) GetAnalytics(
+// farmThreshold returns the farm's own healthy efficiency threshold, reusing a lookup made within
+// farmThresholdTTL so analytics requests do not each read the farm
+// A farm that does not exist has no threshold, so analytics of unknown farms still answer as before
+func (s *IrrigationAnalyticsService) farmThreshold(ctx context.Context, farmID uint) (*float64, error) {
+	now := s.now()
+	if threshold, ok := s.thresholds.get(farmID, now); ok {
+		return threshold, nil
+	}
+
+	var threshold *float64
+	farm, err := s.farms.FindByID(ctx, farmID)
+	switch {
+	case err == nil:
+		threshold = farm.HealthyEfficiencyThreshold
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		s.logger.WithContext(ctx).Error("failed to look up farm", zap.Error(err))
+		return nil, err
+	}
+	s.thresholds.set(farmID, threshold, now.Add(farmThresholdTTL))
+	return threshold, nil
+}
+
+// farmStatus compares the weighted efficiency (total real / total nominal) of every bucket of the period
+// with threshold; it is empty when they have no nominal amount to weigh against
+func farmStatus(data []repository.AnalyticsAggregation, threshold float64) model.FarmStatus {
+	var totalReal, totalNominal float64
+	for _, item := range data {
+		totalReal += item.TotalRealAmount
+		totalNominal += item.TotalNominalAmount
+	}
+	efficiency := weightedEfficiency(totalReal, totalNominal)
+	if efficiency == nil {
+		return ""
+	}
+	if *efficiency >= threshold {
+		return model.FarmStatusHealthy
+	}
+	return model.FarmStatusNeedsAttention
+}
+
+// weightedEfficiency is totalReal / totalNominal, or nil when there is no nominal amount to weigh against
+func weightedEfficiency(totalReal, totalNominal float64) *float64 {
+	if totalNominal <= 0 {
+		return nil
+	}
+	efficiency := totalReal / totalNominal
+	return &efficiency
+}
+
+// checkSectorInFarm returns ErrSectorNotFound or a *SectorNotInFarmError when sectorID is not one of the farm's sectors
+// It runs once per request, before the analytics queries, so the sector is looked up a single time
+func (s *IrrigationAnalyticsService) checkSectorInFarm(ctx context.Context, farmID, sectorID uint) error {
+	sector, err := s.sectors.FindByID(ctx, sectorID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSectorNotFound
+		}
+		s.logger.WithContext(ctx).Error("failed to look up irrigation sector", zap.Error(err))
+		return err
+	}
+	if sector.FarmID != farmID {
+		return &SectorNotInFarmError{SectorID: sectorID, FarmID: farmID}
+	}
+	return nil
+}
+
+// getAnalytics computes the analytics GetAnalytics shares between identical concurrent requests
+func (s *IrrigationAnalyticsService) getAnalytics(
 	ctx context.Context,
 	farmID uint,
 	startDate, endDate *time.Time,
 	sectorID *uint,
-	aggregation string,
+	aggregation model.Aggregation,
 	page, limit int,
+	opts model.AnalyticsOptions,
 ) (*model.IrrigationAnalyticsResponse, error) {
 	s.logger.WithContext(ctx).Info(
 		"fetching irrigation analytics",
 		zap.Uint("farm_id", farmID),
-		zap.String("aggregation", aggregation),
+		zap.String("aggregation", string(aggregation)),
+		zap.Bool("whole_days_only", opts.WholeDaysOnly),
 	)
 
+	if s.farmExcluded(farmID) {
+		return nil, ErrFarmExcluded
+	}
+
 	// Calculate date range (default to last 90 days if not provided)
-	now := time.Now().UTC()
-	var start, end time.Time
+	start, end := resolveDateRange(startDate, endDate)
+	if opts.ExcludeToday {
+		end = endBeforeCurrentBucket(end, s.now(), aggregation)
+	}
+	// Date-only ranges always cover whole days except the in-progress UTC day, so whole_days_only drops
+	// it from every section
+	if opts.WholeDaysOnly {
+		end = endBeforeCurrentBucket(end, s.now(), model.AggregationDaily)
+	}
 
-	if startDate == nil || endDate == nil {
-		// Default: last 90 days
-		end = now
-		start = now.AddDate(0, 0, -90)
-		start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
-	} else {
-		start = time.Date(startDate.Year(), startDate.Month(), startDate.Day(), 0, 0, 0, 0, time.UTC)
-		end = time.Date(endDate.Year(), endDate.Month(), endDate.Day(), 23, 59, 59, 999999999, time.UTC)
+	// A sector of another farm (or a typo) would otherwise silently filter everything out
+	if sectorID != nil && s.sectors != nil {
+		if err := s.checkSectorInFarm(ctx, farmID, *sectorID); err != nil {
+			return nil, err
+		}
+	}
+
+	// Every query below, comparison windows included, only sees events within the real amount bounds
+	if opts.MinReal != nil || opts.MaxReal != nil {
+		ctx = repository.WithRealAmountRange(ctx, repository.RealAmountRange{Min: opts.MinReal, Max: opts.MaxReal})
+	}
+	if opts.Consistency == model.ConsistencyStrong {
+		ctx = repository.WithConsistency(ctx, opts.Consistency)
+	}
+	// The sector breakdown and its sparklines roll child sectors up into their zone
+	if opts.SectorGroupBy == model.SectorGroupByZone {
+		ctx = repository.WithSectorGroupBy(ctx, opts.SectorGroupBy)
+	}
+	if opts.SectorSort.Field != "" {
+		ctx = repository.WithSectorSort(ctx, opts.SectorSort)
+	}
+
+	// limit=all is a single page, whatever page was asked for
+	if opts.UnboundedLimit {
+		page = 1
+	}
+
+	// Sections not requested are not queried; the request overrides the deployment default
+	fields := opts.Fields
+	if fields == nil {
+		fields = s.cfg.DefaultFields
+	}
+	if fields == nil {
+		fields = model.AllAnalyticsFields
+	}
+
+	// Count sectors that irrigated in the period
+	activeSectors, err := s.repo.CountActiveSectors(ctx, farmID, start, end)
+	if err != nil {
+		s.logger.WithContext(ctx).Error("failed to count active sectors", zap.Error(err))
+		return nil, err
+	}
+
+	// Refuse oversized responses before running the heavy queries; the sector count sizes the sector sections
+	if err := s.checkResponseSize(start, end, aggregation, limit, activeSectors, fields, opts); err != nil {
+		s.logger.WithContext(ctx).Warn("analytics response too large", zap.Error(err))
+		return nil, err
 	}
 
 	// Fetch current period analytics
-	timeSeries, totalCount, err := s.repo.GetAnalyticsForFarmByDateRange(ctx, farmID, start, end, aggregation, limit, (page-1)*limit)
+	timeSeries, totalCount, truncated, err := s.repo.GetAnalyticsForFarmByDateRange(ctx, farmID, start, end, aggregation, limit, (page-1)*limit)
 	if err != nil {
 		s.logger.WithContext(ctx).Error("failed to get analytics for farm", zap.Error(err))
 		return nil, err
 	}
 
 	// Fetch YoY comparison data
-	yoyData, err := s.repo.GetYoYComparison(ctx, farmID, start, end, aggregation)
-	if err != nil {
-		s.logger.WithContext(ctx).Error("failed to get YoY comparison", zap.Error(err))
-		return nil, err
+	var yoyData map[int]repository.YoYAnalyticsData
+	if fields.Has(model.AnalyticsFieldYoY) {
+		yoyData, err = s.getYoYComparison(ctx, farmID, start, end, aggregation, startDate == nil && endDate == nil, opts)
+		if err != nil {
+			s.logger.WithContext(ctx).Error("failed to get YoY comparison", zap.Error(err))
+			return nil, err
+		}
 	}
 
 	// Fetch sector breakdown
-	sectorBreakdown, err := s.repo.GetSectorBreakdownForFarm(ctx, farmID, sectorID, start, end)
+	var sectorBreakdown []repository.SectorAnalyticsData
+	var sectorCount int64
+	if fields.Has(model.AnalyticsFieldSectors) {
+		sectorBreakdown, sectorCount, err = s.repo.GetSectorBreakdownForFarm(ctx, farmID, sectorID, start, end, opts.SectorLimit, (opts.SectorPage-1)*opts.SectorLimit)
+		if err != nil {
+			s.logger.WithContext(ctx).Error("failed to get sector breakdown", zap.Error(err))
+			return nil, err
+		}
+	}
+
+	// Fetch per-sector buckets for the sparklines
+	var sectorTimeSeries []repository.SectorTimeSeriesData
+	if fields.Has(model.AnalyticsFieldSectors) {
+		sectorTimeSeries, err = s.repo.GetSectorTimeSeriesForFarm(ctx, farmID, start, end, aggregation)
+		if err != nil {
+			s.logger.WithContext(ctx).Error("failed to get sector time series", zap.Error(err))
+			return nil, err
+		}
+	}
+
+	// Judge the period against the farm's healthy efficiency threshold
+	threshold, err := s.healthyThreshold(ctx, farmID)
 	if err != nil {
-		s.logger.WithContext(ctx).Error("failed to get sector breakdown", zap.Error(err))
 		return nil, err
 	}
 
+	// Metrics, anomaly flags, the forecast, status and the previous-window comparison cover the whole
+	// period, not just the requested page
+	periodSeries := timeSeries
+	keys := bucketKeys(start, end, aggregation)
+	if page > 1 || len(timeSeries) >= limit {
+		periodSeries, _, _, err = s.repo.GetAnalyticsForFarmByDateRange(ctx, farmID, start, end, aggregation, len(keys), 0)
+		if err != nil {
+			s.logger.WithContext(ctx).Error("failed to get time series for the whole period", zap.Error(err))
+			return nil, err
+		}
+	}
+
+	// Project the next bucket
+	var forecast *model.Forecast
+	var forecastNote string
+	if opts.Forecast {
+		forecast, forecastNote = forecastNextBucket(periodSeries, keys, aggregation)
+	}
+
 	// Convert time-series data to response format
-	timeSeriesEntries := s.convertTimeSeriesData(timeSeries)
-	sectorBreakdownEntries := s.convertSectorBreakdownData(sectorBreakdown)
+	// anomalies_only judges every bucket of the period and pages through the anomalous ones, so the
+	// time-series pagination counts anomalous buckets only
+	var timeSeriesEntries []model.TimeSeriesEntry
+	timeSeriesCount := totalCount
+	if opts.AnomaliesOnly {
+		timeSeriesEntries, timeSeriesCount = s.anomalousPage(periodSeries, opts.Cumulative, page, limit)
+	} else {
+		timeSeriesEntries = s.convertTimeSeriesData(timeSeries)
+
+		// Running totals start at the period start, so later pages carry over the buckets before them
+		if opts.Cumulative {
+			var carriedNominal, carriedReal float64
+			if page > 1 {
+				earlier, _, _, err := s.repo.GetAnalyticsForFarmByDateRange(ctx, farmID, start, end, aggregation, (page-1)*limit, 0)
+				if err != nil {
+					s.logger.WithContext(ctx).Error("failed to get earlier buckets for cumulative totals", zap.Error(err))
+					return nil, err
+				}
+				for _, item := range earlier {
+					carriedNominal += item.TotalNominalAmount
+					carriedReal += item.TotalRealAmount
+				}
+			}
+			accumulateTimeSeries(timeSeriesEntries, carriedNominal, carriedReal)
+		}
 
-	// Calculate metrics for current period
-	currentMetrics := s.calculateMetrics(timeSeries)
+		// Flag buckets whose efficiency stands out from the whole period, as anomalies_only does
+		anomalous := anomalousPeriods(s.convertTimeSeriesData(periodSeries))
+		for i := range timeSeriesEntries {
+			timeSeriesEntries[i].Anomalous = anomalous[timeSeriesEntries[i].Date]
+		}
+	}
+
+	var sectorBreakdownEntries []model.SectorBreakdown
+	if fields.Has(model.AnalyticsFieldSectors) {
+		sectorBreakdownEntries = s.convertSectorBreakdownData(sectorBreakdown)
+	}
+
+	// Attach each sector's efficiency series, downsampled for inline charts
+	sparklines := make(map[uint][]*float64)
+	for _, row := range buildEfficiencyRows(sectorTimeSeries, bucketKeys(start, end, aggregation)) {
+		sparklines[row.SectorID] = downsampleSeries(row.Cells, s.cfg.SparklineMaxPoints)
+	}
+	for i := range sectorBreakdownEntries {
+		sectorBreakdownEntries[i].EfficiencySparkline = sparklines[sectorBreakdownEntries[i].SectorID]
+	}
+
+	// Calculate metrics for the whole current period
+	currentMetrics := s.calculateMetrics(periodSeries)
+	currentMetrics.ActiveSectorCount = activeSectors
 
 	// Calculate YoY comparison metrics
-	currentYear := time.Now().Year()
-	yoY1 := s.getYoYMetrics(yoyData, currentYear-1, "previous year")
-	yoY2 := s.getYoYMetrics(yoyData, currentYear-2, "two years ago")
+	currentYear := s.now().Year()
+	var yoY1, yoY2 *model.YoYComparison
+	if fields.Has(model.AnalyticsFieldYoY) {
+		yoY1 = s.getYoYMetrics(yoyData, currentYear-1, "previous year")
+		yoY2 = s.getYoYMetrics(yoyData, currentYear-2, "two years ago")
+	}
 
 	// Calculate period comparison percentages
-	periodComparison := s.calculatePeriodComparison(currentMetrics, yoY1, yoY2)
+	periodComparison := s.calculatePeriodComparison(currentMetrics, yoY1, yoY2, opts.EfficiencyBasis)
+
+	// Compare with the preceding window of equal length when requested
+	var prevWindow *model.PreviousWindow
+	if opts.Compare == model.ComparisonPrevWindow {
+		prevWindow, periodComparison.VsPrevWindow, err = s.comparePreviousWindow(ctx, farmID, start, end, aggregation, currentMetrics, opts.EfficiencyBasis)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Summarize data quality when requested
+	var dataQuality *model.DataQuality
+	if opts.IncludeQuality {
+		dataQuality, err = s.getDataQuality(ctx, farmID, start, end)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	// Calculate pagination metadata
-	totalPages := int(math.Ceil(float64(totalCount) / float64(limit)))
+	// Break the time-series down by sector for stacked charts when requested
+	var stacked []model.StackedTimeSeriesEntry
+	if opts.IncludeStackedTimeSeries {
+		stacked, err = s.getStackedTimeSeries(ctx, farmID, start, end, aggregation)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Calculate pagination metadata; limit=all reports its single page as holding every bucket
+	pageLimit := limit
+	totalPages := int(math.Ceil(float64(timeSeriesCount) / float64(limit)))
+	if opts.UnboundedLimit {
+		pageLimit, totalPages = int(timeSeriesCount), 1
+	}
 
 	// Build response
 	response := &model.IrrigationAnalyticsResponse{
 		FarmID:       farmID,
 		FarmName:     "", // Will be populated if needed
 		Period:       model.IrrigationAnalyticsPeriod{Start: start, End: end},
+		HasData:      totalCount > 0,
 		Aggregation:  aggregation,
 		Metrics:      currentMetrics,
 		SamePeriod1Y: yoY1,
 		SamePeriod2Y: yoY2,
 		PeriodComparison: &model.PeriodComparisonSet{
-			VsPeriod1Y: periodComparison.VsPeriod1Y,
-			VsPeriod2Y: periodComparison.VsPeriod2Y,
+			VsPeriod1Y:   periodComparison.VsPeriod1Y,
+			VsPeriod2Y:   periodComparison.VsPeriod2Y,
+			VsPrevWindow: periodComparison.VsPrevWindow,
 		},
+		PrevWindow: prevWindow,
 		TimeSeries: model.TimeSeries{
-			Data: timeSeriesEntries,
+			Data:      timeSeriesEntries,
+			Truncated: truncated,
 			Pagination: model.PaginationMetadata{
 				Page:       page,
-				Limit:      limit,
-				TotalCount: int(totalCount),
+				Limit:      pageLimit,
+				TotalCount: int(timeSeriesCount),
 				TotalPages: totalPages,
 			},
 		},
-		SectorBreakdown: sectorBreakdownEntries,
+		SectorBreakdown:   sectorBreakdownEntries,
+		Forecast:          forecast,
+		ForecastNote:      forecastNote,
+		DataQuality:       dataQuality,
+		StackedTimeSeries: stacked,
+	}
+	if threshold != nil {
+		response.Status = farmStatus(periodSeries, *threshold)
+		if response.Status != "" {
+			response.HealthyThreshold = threshold
+		}
+	}
+
+	// limit=all may return a very large time-series; say how large instead of answering silently
+	if opts.UnboundedLimit && s.cfg.WarnUnboundedLimit {
+		response.Warnings = append(response.Warnings, fmt.Sprintf("unbounded limit requested; %d buckets returned", len(timeSeriesEntries)))
+	}
+
+	if opts.SectorLimit > 0 && fields.Has(model.AnalyticsFieldSectors) {
+		response.SectorPagination = &model.PaginationMetadata{
+			Page:       opts.SectorPage,
+			Limit:      opts.SectorLimit,
+			TotalCount: int(sectorCount),
+			TotalPages: int(math.Ceil(float64(sectorCount) / float64(opts.SectorLimit))),
+		}
 	}
 
 	return response, nil
 }
 
+// getYoYComparison serves the YoY comparison from the cache when the request uses the default range
+// unchanged (no exclude_today, no real amount bounds, no strong consistency), and queries it otherwise
+func (s *IrrigationAnalyticsService) getYoYComparison(
+	ctx context.Context,
+	farmID uint,
+	start, end time.Time,
+	aggregation model.Aggregation,
+	defaultRange bool,
+	opts model.AnalyticsOptions,
+) (map[int]repository.YoYAnalyticsData, error) {
+	if s.yoy != nil && defaultRange && !opts.ExcludeToday && !opts.WholeDaysOnly && opts.MinReal == nil && opts.MaxReal == nil && opts.Consistency != model.ConsistencyStrong {
+		if data, ok := s.yoy.Get(farmID, aggregation, start); ok {
+			s.logger.WithContext(ctx).Debug("serving cached YoY comparison", zap.Uint("farm_id", farmID))
+			return data, nil
+		}
+	}
+	return s.repo.GetYoYComparison(ctx, farmID, start, end, aggregation)
+}
+
+// getStackedTimeSeries groups the (period, sector) sums into one entry per bucket
+// The repository returns rows ordered by period, so each bucket's sectors are contiguous
+func (s *IrrigationAnalyticsService) getStackedTimeSeries(ctx context.Context, farmID uint, start, end time.Time, aggregation model.Aggregation) ([]model.StackedTimeSeriesEntry, error) {
+	rows, err := s.repo.GetFarmTimeSeriesBySector(ctx, farmID, start, end, aggregation)
+	if err != nil {
+		s.logger.WithContext(ctx).Error("failed to get farm time series by sector", zap.Error(err))
+		return nil, err
+	}
+
+	entries := make([]model.StackedTimeSeriesEntry, 0)
+	for _, row := range rows {
+		if len(entries) == 0 || entries[len(entries)-1].Date != row.Period {
+			entries = append(entries, model.StackedTimeSeriesEntry{Date: row.Period})
+		}
+		last := &entries[len(entries)-1]
+		last.Sectors = append(last.Sectors, model.StackedSectorAmounts{
+			SectorID:        row.SectorID,
+			SectorName:      row.SectorName,
+			NominalAmountMM: row.TotalNominalAmount,
+			RealAmountMM:    row.TotalRealAmount,
+		})
+	}
+	return entries, nil
+}
+
+// getDataQuality assembles the data-quality summary; completeness only counts days up to now,
+// so a range ending in the future is not penalized for days that cannot have data yet
+func (s *IrrigationAnalyticsService) getDataQuality(ctx context.Context, farmID uint, start, end time.Time) (*model.DataQuality, error) {
+	data, err := s.repo.GetDataQualityForFarm(ctx, farmID, start, end)
+	if err != nil {
+		s.logger.WithContext(ctx).Error("failed to get data quality", zap.Error(err))
+		return nil, err
+	}
+
+	if now := s.now().UTC(); end.After(now) {
+		end = now
+	}
+	daysInRange := len(bucketKeys(start, end, model.AggregationDaily))
+
+	quality := &model.DataQuality{
+		EventCount:             data.EventCount,
+		ZeroNominalEvents:      data.ZeroNominalCount,
+		OverIrrigationEvents:   data.OverIrrigationCount,
+		DuplicateSuspectEvents: data.DuplicateSuspectCount,
+		DaysWithData:           data.DaysWithData,
+		DaysInRange:            daysInRange,
+	}
+	if daysInRange > 0 {
+		quality.CompletenessPercent = math.Min(100, float64(data.DaysWithData)/float64(daysInRange)*100)
+	}
+	return quality, nil
+}
+
+// forecastMinDataPoints is the fewest buckets with data a forecast is fitted on
+const forecastMinDataPoints = 4
+
+// forecastBandZ scales the prediction standard error into a ~95% band (normal approximation)
+const forecastBandZ = 1.96
+
+// forecastNextBucket fits real_amount = intercept + slope*x by least squares, where x is each
+// bucket's position in keys, and projects the bucket following the last key
+// Buckets without data are left out of the fit rather than counted as zero
+// Returns a note instead of a forecast when fewer than forecastMinDataPoints buckets have data
+func forecastNextBucket(series []repository.AnalyticsAggregation, keys []string, aggregation model.Aggregation) (*model.Forecast, string) {
+	positions := make(map[string]int, len(keys))
+	for i, key := range keys {
+		positions[key] = i
+	}
+
+	var xs, ys []float64
+	for _, item := range series {
+		if x, ok := positions[item.Period]; ok {
+			xs = append(xs, float64(x))
+			ys = append(ys, item.TotalRealAmount)
+		}
+	}
+
+	n := float64(len(xs))
+	if len(xs) < forecastMinDataPoints {
+		return nil, fmt.Sprintf("forecast needs at least %d buckets with data; got %d", forecastMinDataPoints, len(xs))
+	}
+
+	var meanX, meanY float64
+	for i := range xs {
+		meanX += xs[i]
+		meanY += ys[i]
+	}
+	meanX /= n
+	meanY /= n
+
+	var sxx, sxy float64
+	for i := range xs {
+		sxx += (xs[i] - meanX) * (xs[i] - meanX)
+		sxy += (xs[i] - meanX) * (ys[i] - meanY)
+	}
+	slope := sxy / sxx
+	intercept := meanY - slope*meanX
+
+	// Residual standard error with n-2 degrees of freedom
+	var sse float64
+	for i := range xs {
+		residual := ys[i] - (intercept + slope*xs[i])
+		sse += residual * residual
+	}
+	residualStdErr := math.Sqrt(sse / (n - 2))
+
+	nextX := float64(len(keys))
+	projected := intercept + slope*nextX
+	margin := forecastBandZ * residualStdErr * math.Sqrt(1+1/n+(nextX-meanX)*(nextX-meanX)/sxx)
+
+	last, _ := time.Parse("2006-01-02", keys[len(keys)-1])
+
+	return &model.Forecast{
+		Period:                aggregation.NextBucket(last).Format("2006-01-02"),
+		ProjectedRealAmountMM: math.Max(projected, 0),
+		LowerBoundMM:          math.Max(projected-margin, 0),
+		UpperBoundMM:          math.Max(projected+margin, 0),
+		SlopeMMPerBucket:      slope,
+		DataPoints:            len(xs),
+	}, ""
+}
+
+// GetEfficiencyHeatmap returns a sector x time-bucket efficiency matrix for a farm
+// Every bucket in the period becomes a column; cells without data are null
+func (s *IrrigationAnalyticsService) GetEfficiencyHeatmap(
+	ctx context.Context,
+	farmID uint,
+	startDate, endDate *time.Time,
+	aggregation model.Aggregation,
+) (*model.EfficiencyHeatmapResponse, error) {
+	s.logger.WithContext(ctx).Info(
+		"fetching efficiency heatmap",
+		zap.Uint("farm_id", farmID),
+		zap.String("aggregation", string(aggregation)),
+	)
+
+	if s.farmExcluded(farmID) {
+		return nil, ErrFarmExcluded
+	}
+
+	start, end := resolveDateRange(startDate, endDate)
+
+	data, err := s.repo.GetSectorTimeSeriesForFarm(ctx, farmID, start, end, aggregation)
+	if err != nil {
+		s.logger.WithContext(ctx).Error("failed to get sector time series", zap.Error(err))
+		return nil, err
+	}
+
+	buckets := bucketKeys(start, end, aggregation)
+
+	return &model.EfficiencyHeatmapResponse{
+		FarmID:      farmID,
+		Period:      model.IrrigationAnalyticsPeriod{Start: start, End: end},
+		Aggregation: aggregation,
+		Buckets:     buckets,
+		Rows:        buildEfficiencyRows(data, buckets),
+	}, nil
+}
+
+// buildEfficiencyRows lays sector time-series data out as one row per sector with a cell per bucket,
+// alongside the bucket's deficit (nominal - real); cells and deficits for buckets without data are nil
+func buildEfficiencyRows(data []repository.SectorTimeSeriesData, buckets []string) []model.HeatmapRow {
+	columns := make(map[string]int, len(buckets))
+	for i, bucket := range buckets {
+		columns[bucket] = i
+	}
+
+	// Rows arrive ordered by sector, so a new sector always starts a new row
+	rows := make([]model.HeatmapRow, 0)
+	for _, item := range data {
+		if len(rows) == 0 || rows[len(rows)-1].SectorID != item.SectorID {
+			rows = append(rows, model.HeatmapRow{
+				SectorID:   item.SectorID,
+				SectorName: item.SectorName,
+				Cells:      make([]*float64, len(buckets)),
+				DeficitMM:  make([]*float64, len(buckets)),
+			})
+		}
+		if col, ok := columns[item.Period]; ok {
+			deficit := item.TotalNominalAmount - item.TotalRealAmount
+			rows[len(rows)-1].Cells[col] = item.AvgEfficiency
+			rows[len(rows)-1].DeficitMM[col] = &deficit
+		}
+	}
+	return rows
+}
+
+// downsampleSeries reduces series to at most maxPoints values by averaging consecutive buckets
+// Nil entries are ignored when averaging; a group with no values stays nil
+// A maxPoints of zero or less leaves the series untouched
+func downsampleSeries(series []*float64, maxPoints int) []*float64 {
+	if maxPoints <= 0 || len(series) <= maxPoints {
+		return series
+	}
+
+	result := make([]*float64, maxPoints)
+	for i := range result {
+		from := i * len(series) / maxPoints
+		to := (i + 1) * len(series) / maxPoints
+
+		sum, count := 0.0, 0
+		for _, value := range series[from:to] {
+			if value != nil {
+				sum += *value
+				count++
+			}
+		}
+		if count > 0 {
+			avg := sum / float64(count)
+			result[i] = &avg
+		}
+	}
+	return result
+}
+
+// GetAlerts returns sectors whose efficiency fell below their target or whose deficit exceeded the threshold
+// Severity escalates to critical when efficiency misses the target by more than the configured gap,
+// or when the deficit reaches twice the configured threshold
+func (s *IrrigationAnalyticsService) GetAlerts(
+	ctx context.Context,
+	farmID uint,
+	startDate, endDate *time.Time,
+) (*model.IrrigationAlertsResponse, error) {
+	s.logger.WithContext(ctx).Info("evaluating irrigation alerts", zap.Uint("farm_id", farmID))
+
+	if s.farmExcluded(farmID) {
+		return nil, ErrFarmExcluded
+	}
+
+	start, end := resolveDateRange(startDate, endDate)
+
+	sectors, _, err := s.repo.GetSectorBreakdownForFarm(ctx, farmID, nil, start, end, 0, 0)
+	if err != nil {
+		s.logger.WithContext(ctx).Error("failed to get sector breakdown", zap.Error(err))
+		return nil, err
+	}
+
+	alerts := make([]model.IrrigationAlert, 0)
+	for _, sector := range sectors {
+		deficit := sector.TotalNominalAmount - sector.TotalRealAmount
+
+		if sector.TargetEfficiency != nil && sector.AvgEfficiency != nil && *sector.AvgEfficiency < *sector.TargetEfficiency {
+			severity := "warning"
+			if *sector.TargetEfficiency-*sector.AvgEfficiency > s.cfg.AlertCriticalEfficiencyGap {
+				severity = "critical"
+			}
+			alerts = append(alerts, model.IrrigationAlert{
+				SectorID:          sector.SectorID,
+				SectorName:        sector.SectorName,
+				Type:              "efficiency_below_target",
+				Severity:          severity,
+				AverageEfficiency: sector.AvgEfficiency,
+				TargetEfficiency:  sector.TargetEfficiency,
+				DeficitMM:         deficit,
+				Message:           fmt.Sprintf("average efficiency %.2f below target %.2f", *sector.AvgEfficiency, *sector.TargetEfficiency),
+			})
+		}
+
+		if deficit > s.cfg.AlertDeficitThresholdMM {
+			severity := "warning"
+			if deficit >= 2*s.cfg.AlertDeficitThresholdMM {
+				severity = "critical"
+			}
+			alerts = append(alerts, model.IrrigationAlert{
+				SectorID:          sector.SectorID,
+				SectorName:        sector.SectorName,
+				Type:              "deficit_exceeded",
+				Severity:          severity,
+				AverageEfficiency: sector.AvgEfficiency,
+				TargetEfficiency:  sector.TargetEfficiency,
+				DeficitMM:         deficit,
+				Message:           fmt.Sprintf("deficit %.1fmm exceeds threshold %.1fmm", deficit, s.cfg.AlertDeficitThresholdMM),
+			})
+		}
+	}
+
+	return &model.IrrigationAlertsResponse{
+		FarmID: farmID,
+		Period: model.IrrigationAnalyticsPeriod{Start: start, End: end},
+		Alerts: alerts,
+	}, nil
+}
+
+// GetWaterSavings estimates the water a farm would have saved had every sector hit its target efficiency
+// Efficiency is the useful share of the water applied, so a sector at efficiency e would have needed
+// real * e / target at its target: the savings are real * (1 - e/target), and 0 for sectors already at target
+// Sectors without a target or without valid efficiency data are listed with a note but left out of the total
+func (s *IrrigationAnalyticsService) GetWaterSavings(
+	ctx context.Context,
+	farmID uint,
+	startDate, endDate *time.Time,
+) (*model.WaterSavingsResponse, error) {
+	s.logger.WithContext(ctx).Info("estimating water savings", zap.Uint("farm_id", farmID))
+
+	if s.farmExcluded(farmID) {
+		return nil, ErrFarmExcluded
+	}
+
+	start, end := resolveDateRange(startDate, endDate)
+
+	sectors, _, err := s.repo.GetSectorBreakdownForFarm(ctx, farmID, nil, start, end, 0, 0)
+	if err != nil {
+		s.logger.WithContext(ctx).Error("failed to get sector breakdown", zap.Error(err))
+		return nil, err
+	}
+
+	response := &model.WaterSavingsResponse{
+		FarmID:  farmID,
+		Period:  model.IrrigationAnalyticsPeriod{Start: start, End: end},
+		Sectors: make([]model.SectorWaterSavings, 0, len(sectors)),
+	}
+	for _, sector := range sectors {
+		entry := model.SectorWaterSavings{
+			SectorID:          sector.SectorID,
+			SectorName:        sector.SectorName,
+			TotalRealAmount:   sector.TotalRealAmount,
+			AverageEfficiency: sector.AvgEfficiency,
+			TargetEfficiency:  sector.TargetEfficiency,
+		}
+
+		switch {
+		case sector.TargetEfficiency == nil || *sector.TargetEfficiency <= 0:
+			entry.Note = "no target efficiency configured"
+			response.ExcludedSectors++
+		case sector.AvgEfficiency == nil:
+			entry.Note = "no valid efficiency data in the period"
+			response.ExcludedSectors++
+		default:
+			savings := 0.0
+			if *sector.AvgEfficiency < *sector.TargetEfficiency {
+				savings = sector.TotalRealAmount * (1 - *sector.AvgEfficiency / *sector.TargetEfficiency)
+			}
+			entry.PotentialSavingsMM = &savings
+			response.PotentialSavingsMM += savings
+		}
+
+		response.Sectors = append(response.Sectors, entry)
+	}
+
+	return response, nil
+}
+
+// GetTopIrrigationDays returns the n days with the highest total real irrigation volume
+func (s *IrrigationAnalyticsService) GetTopIrrigationDays(
+	ctx context.Context,
+	farmID uint,
+	startDate, endDate *time.Time,
+	n int,
+) (*model.TopIrrigationDaysResponse, error) {
+	s.logger.WithContext(ctx).Info("fetching top irrigation days", zap.Uint("farm_id", farmID), zap.Int("n", n))
+
+	if s.farmExcluded(farmID) {
+		return nil, ErrFarmExcluded
+	}
+
+	start, end := resolveDateRange(startDate, endDate)
+
+	data, err := s.repo.GetTopIrrigationDays(ctx, farmID, start, end, n)
+	if err != nil {
+		s.logger.WithContext(ctx).Error("failed to get top irrigation days", zap.Error(err))
+		return nil, err
+	}
+
+	days := make([]model.TopIrrigationDay, 0, len(data))
+	for _, item := range data {
+		days = append(days, model.TopIrrigationDay{
+			Date:            item.Day,
+			RealAmountMM:    item.TotalRealAmount,
+			NominalAmountMM: item.TotalNominalAmount,
+			EventCount:      item.EventCount,
+		})
+	}
+
+	return &model.TopIrrigationDaysResponse{
+		FarmID: farmID,
+		Period: model.IrrigationAnalyticsPeriod{Start: start, End: end},
+		Days:   days,
+	}, nil
+}
+
+// GetSectorRanking returns one page of the farm's sectors ranked by average efficiency
+// The repository breaks ties by volume and sector ID, so consecutive pages never repeat or skip a sector
+func (s *IrrigationAnalyticsService) GetSectorRanking(
+	ctx context.Context,
+	farmID uint,
+	startDate, endDate *time.Time,
+	page, limit int,
+) (*model.SectorRankingResponse, error) {
+	s.logger.WithContext(ctx).Info("fetching sector ranking", zap.Uint("farm_id", farmID), zap.Int("page", page), zap.Int("limit", limit))
+
+	if s.farmExcluded(farmID) {
+		return nil, ErrFarmExcluded
+	}
+
+	start, end := resolveDateRange(startDate, endDate)
+	offset := (page - 1) * limit
+
+	data, total, err := s.repo.GetSectorRanking(ctx, farmID, start, end, limit, offset)
+	if err != nil {
+		s.logger.WithContext(ctx).Error("failed to get sector ranking", zap.Error(err))
+		return nil, err
+	}
+
+	sectors := make([]model.SectorRankingEntry, 0, len(data))
+	for i, item := range data {
+		sectors = append(sectors, model.SectorRankingEntry{
+			Rank:              offset + i + 1,
+			SectorID:          item.SectorID,
+			SectorName:        item.SectorName,
+			AverageEfficiency: item.AvgEfficiency,
+			RealAmountMM:      item.TotalRealAmount,
+			NominalAmountMM:   item.TotalNominalAmount,
+			EventCount:        item.EventCount,
+		})
+	}
+
+	return &model.SectorRankingResponse{
+		FarmID:  farmID,
+		Period:  model.IrrigationAnalyticsPeriod{Start: start, End: end},
+		Sectors: sectors,
+		Pagination: model.PaginationMetadata{
+			Page:       page,
+			Limit:      limit,
+			TotalCount: int(total),
+			TotalPages: int(math.Ceil(float64(total) / float64(limit))),
+		},
+	}, nil
+}
+
+// GetMultiFarmSectorBreakdown returns sector metrics for several farms at once, each row tagged with its farm
+func (s *IrrigationAnalyticsService) GetMultiFarmSectorBreakdown(
+	ctx context.Context,
+	farmIDs []uint,
+	startDate, endDate *time.Time,
+) (*model.MultiFarmSectorBreakdownResponse, error) {
+	s.logger.WithContext(ctx).Info("fetching multi-farm sector breakdown", zap.Uints("farm_ids", farmIDs))
+
+	// Excluded farms are left out rather than failing the whole request
+	farmIDs = slices.DeleteFunc(slices.Clone(farmIDs), s.farmExcluded)
+
+	start, end := resolveDateRange(startDate, endDate)
+
+	data, err := s.repo.GetSectorBreakdownForFarms(ctx, farmIDs, start, end)
+	if err != nil {
+		s.logger.WithContext(ctx).Error("failed to get multi-farm sector breakdown", zap.Error(err))
+		return nil, err
+	}
+
+	sectors := make([]model.FarmSectorBreakdown, 0, len(data))
+	for _, item := range data {
+		sectors = append(sectors, model.FarmSectorBreakdown{
+			FarmID:            item.FarmID,
+			FarmName:          item.FarmName,
+			SectorID:          item.SectorID,
+			SectorName:        item.SectorName,
+			TotalVolumeMM:     item.TotalRealAmount,
+			NominalVolumeMM:   item.TotalNominalAmount,
+			AverageEfficiency: item.AvgEfficiency,
+			SampleSize:        item.EventCount,
+			Confidence:        s.confidenceFor(item.EventCount),
+		})
+	}
+
+	return &model.MultiFarmSectorBreakdownResponse{
+		FarmIDs: farmIDs,
+		Period:  model.IrrigationAnalyticsPeriod{Start: start, End: end},
+		Sectors: sectors,
+	}, nil
+}
+
+// GetDayOfWeekDistribution returns the farm's irrigation totals for each weekday (UTC)
+func (s *IrrigationAnalyticsService) GetDayOfWeekDistribution(
+	ctx context.Context,
+	farmID uint,
+	startDate, endDate *time.Time,
+) (*model.DayOfWeekDistributionResponse, error) {
+	s.logger.WithContext(ctx).Info("fetching day-of-week distribution", zap.Uint("farm_id", farmID))
+
+	if s.farmExcluded(farmID) {
+		return nil, ErrFarmExcluded
+	}
+
+	start, end := resolveDateRange(startDate, endDate)
+
+	data, err := s.repo.GetDayOfWeekDistribution(ctx, farmID, start, end)
+	if err != nil {
+		s.logger.WithContext(ctx).Error("failed to get day-of-week distribution", zap.Error(err))
+		return nil, err
+	}
+
+	days := make([]model.DayOfWeekTotal, 0, len(data))
+	for _, item := range data {
+		days = append(days, model.DayOfWeekTotal{
+			DayOfWeek:       item.DayOfWeek,
+			Day:             time.Weekday(item.DayOfWeek).String(),
+			RealAmountMM:    item.TotalRealAmount,
+			NominalAmountMM: item.TotalNominalAmount,
+			EventCount:      item.EventCount,
+		})
+	}
+
+	return &model.DayOfWeekDistributionResponse{
+		FarmID: farmID,
+		Period: model.IrrigationAnalyticsPeriod{Start: start, End: end},
+		Days:   days,
+	}, nil
+}
+
+// GetEfficiencyByAmount returns the farm's average efficiency per real-amount bin, to show whether
+// larger waterings are less efficient
+func (s *IrrigationAnalyticsService) GetEfficiencyByAmount(
+	ctx context.Context,
+	farmID uint,
+	startDate, endDate *time.Time,
+	bins int,
+) (*model.EfficiencyByAmountResponse, error) {
+	s.logger.WithContext(ctx).Info("fetching efficiency by amount", zap.Uint("farm_id", farmID), zap.Int("bins", bins))
+
+	if s.farmExcluded(farmID) {
+		return nil, ErrFarmExcluded
+	}
+
+	start, end := resolveDateRange(startDate, endDate)
+
+	data, err := s.repo.GetEfficiencyByAmount(ctx, farmID, start, end, bins)
+	if err != nil {
+		s.logger.WithContext(ctx).Error("failed to get efficiency by amount", zap.Error(err))
+		return nil, err
+	}
+
+	amountBins := make([]model.EfficiencyAmountBin, 0, len(data))
+	for _, item := range data {
+		amountBins = append(amountBins, model.EfficiencyAmountBin{
+			Bin:               item.Bin,
+			MinRealAmountMM:   item.MinRealAmount,
+			MaxRealAmountMM:   item.MaxRealAmount,
+			EventCount:        item.EventCount,
+			AvgRealAmountMM:   item.AvgRealAmount,
+			AverageEfficiency: item.AvgEfficiency,
+		})
+	}
+
+	return &model.EfficiencyByAmountResponse{
+		FarmID: farmID,
+		Period: model.IrrigationAnalyticsPeriod{Start: start, End: end},
+		Bins:   amountBins,
+	}, nil
+}
+
+// GetScheduleAdherence flags sectors whose longest stretch without irrigation exceeds their expected frequency
+// The stretches before the first and after the last event count, so a sector never watered in the range is flagged
+// once the range is longer than its frequency; the range end is capped at now so future days are not counted as missed
+func (s *IrrigationAnalyticsService) GetScheduleAdherence(
+	ctx context.Context,
+	farmID uint,
+	startDate, endDate *time.Time,
+) (*model.ScheduleAdherenceResponse, error) {
+	s.logger.WithContext(ctx).Info("evaluating schedule adherence", zap.Uint("farm_id", farmID))
+
+	if s.farmExcluded(farmID) {
+		return nil, ErrFarmExcluded
+	}
+
+	start, end := resolveDateRange(startDate, endDate)
+	if now := s.now().UTC(); end.After(now) {
+		end = now
+	}
+
+	data, err := s.repo.GetSectorScheduleForFarm(ctx, farmID, start, end)
+	if err != nil {
+		s.logger.WithContext(ctx).Error("failed to get sector schedule", zap.Error(err))
+		return nil, err
+	}
+
+	sectors := make([]model.SectorScheduleAdherence, 0, len(data))
+	missed := 0
+	for _, item := range data {
+		gap := longestScheduleGap(item, start, end)
+		adherence := model.SectorScheduleAdherence{
+			SectorID:              item.SectorID,
+			SectorName:            item.SectorName,
+			ExpectedFrequencyDays: item.ExpectedFrequencyDays,
+			EventCount:            item.EventCount,
+			LongestGapDays:        math.Round(gap.Hours()/24*100) / 100,
+			MissedSchedule:        gap > time.Duration(item.ExpectedFrequencyDays)*24*time.Hour,
+		}
+		if adherence.MissedSchedule {
+			missed++
+		}
+		sectors = append(sectors, adherence)
+	}
+
+	return &model.ScheduleAdherenceResponse{
+		FarmID:      farmID,
+		Period:      model.IrrigationAnalyticsPeriod{Start: start, End: end},
+		MissedCount: missed,
+		Sectors:     sectors,
+	}, nil
+}
+
+// longestScheduleGap returns the longest stretch in [start, end] without an event for one sector
+// It covers start to the first event, gaps between events, and the last event to end; without events it is the whole range
+func longestScheduleGap(data repository.SectorScheduleData, start, end time.Time) time.Duration {
+	if data.EventCount == 0 || data.FirstStartUnix == nil || data.LastStartUnix == nil {
+		return end.Sub(start)
+	}
+
+	seconds := func(unix float64) time.Duration {
+		return time.Duration(unix * float64(time.Second))
+	}
+	first := time.Unix(0, 0).Add(seconds(*data.FirstStartUnix))
+	last := time.Unix(0, 0).Add(seconds(*data.LastStartUnix))
+
+	longest := max(first.Sub(start), end.Sub(last))
+	if data.MaxGapSeconds != nil {
+		longest = max(longest, seconds(*data.MaxGapSeconds))
+	}
+	return longest
+}
+
+// resolveDateRange normalizes the requested dates to full UTC days
+// A missing end defaults to now and a missing start to 90 days before the end, so a single bound is kept
+func resolveDateRange(startDate, endDate *time.Time) (time.Time, time.Time) {
+	end := time.Now().UTC()
+	if endDate != nil {
+		end = time.Date(endDate.Year(), endDate.Month(), endDate.Day(), 23, 59, 59, 999999999, time.UTC)
+	}
+	if startDate == nil {
+		start := end.AddDate(0, 0, -90)
+		return time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC), end
+	}
+	return time.Date(startDate.Year(), startDate.Month(), startDate.Day(), 0, 0, 0, 0, time.UTC), end
+}
+
+// endBeforeCurrentBucket moves end to just before the aggregation bucket containing now when the
+// range reaches into it, so an in-progress day, week or month does not drag trend lines down
+func endBeforeCurrentBucket(end, now time.Time, aggregation model.Aggregation) time.Time {
+	current := aggregation.BucketStart(now.UTC())
+	if end.Before(current) {
+		return end
+	}
+	return current.Add(-time.Nanosecond)
+}
+
+// checkResponseSize estimates the serialized size of the requested sections and rejects it with a
+// *ResponseTooLargeError when it exceeds the configured limit (zero or less disables the check)
+// The time-series page is bounded by limit; the sector breakdown, its sparklines and the stacked
+// time-series grow with the number of sectors, so sectors (those with events in the range) sizes them,
+// assuming every sector has events in every bucket
+func (s *IrrigationAnalyticsService) checkResponseSize(
+	start, end time.Time,
+	aggregation model.Aggregation,
+	limit, sectors int,
+	fields model.AnalyticsFields,
+	opts model.AnalyticsOptions,
+) error {
+	if s.cfg.MaxResponseBytes <= 0 {
+		return nil
+	}
+
+	buckets := len(bucketKeys(start, end, aggregation))
+	estimated := min(buckets, limit) * estimatedTimeSeriesEntryBytes
+
+	if fields.Has(model.AnalyticsFieldSectors) {
+		sectorEntries := sectors
+		if opts.SectorLimit > 0 {
+			sectorEntries = min(sectorEntries, opts.SectorLimit)
+		}
+		sparklinePoints := buckets
+		if s.cfg.SparklineMaxPoints > 0 {
+			sparklinePoints = min(sparklinePoints, s.cfg.SparklineMaxPoints)
+		}
+		estimated += sectorEntries * (estimatedSectorEntryBytes + sparklinePoints*estimatedSparklinePointBytes)
+	}
+	if opts.IncludeStackedTimeSeries {
+		estimated += buckets * (estimatedStackedEntryBytes + sectors*estimatedStackedSectorBytes)
+	}
+
+	if estimated > s.cfg.MaxResponseBytes {
+		return &ResponseTooLargeError{EstimatedBytes: estimated, LimitBytes: s.cfg.MaxResponseBytes}
+	}
+	return nil
+}
+
+// recommendedMaxBuckets is the most time-series buckets a recommended aggregation produces;
+// about what a chart can show legibly (120 days stays daily, a longer range goes weekly)
+const recommendedMaxBuckets = 120
+
+// RecommendAggregation suggests the finest aggregation whose bucket count for the range stays within
+// recommendedMaxBuckets, falling back to monthly; it only looks at the dates and never queries the database
+func (s *IrrigationAnalyticsService) RecommendAggregation(
+	ctx context.Context,
+	farmID uint,
+	startDate, endDate *time.Time,
+) *model.AggregationRecommendationResponse {
+	start, end := resolveDateRange(startDate, endDate)
+
+	aggregations := []model.Aggregation{model.AggregationDaily, model.AggregationWeekly, model.AggregationMonthly}
+	options := make([]model.AggregationOption, 0, len(aggregations))
+	var recommended model.Aggregation
+	for _, aggregation := range aggregations {
+		count := len(bucketKeys(start, end, aggregation))
+		options = append(options, model.AggregationOption{Aggregation: aggregation, BucketCount: count})
+		if recommended == "" && count <= recommendedMaxBuckets {
+			recommended = aggregation
+		}
+	}
+	if recommended == "" {
+		recommended = model.AggregationMonthly
+	}
+
+	s.logger.WithContext(ctx).Info("recommended aggregation",
+		zap.Uint("farm_id", farmID),
+		zap.String("aggregation", string(recommended)),
+	)
+
+	return &model.AggregationRecommendationResponse{
+		FarmID:      farmID,
+		Period:      model.IrrigationAnalyticsPeriod{Start: start, End: end},
+		Recommended: recommended,
+		MaxBuckets:  recommendedMaxBuckets,
+		Options:     options,
+	}
+}
+
+// bucketKeys lists every bucket start (YYYY-MM-DD) overlapping [start, end]
+func bucketKeys(start, end time.Time, aggregation model.Aggregation) []string {
+	keys := make([]string, 0)
+	for bucket := aggregation.BucketStart(start); !bucket.After(end); bucket = aggregation.NextBucket(bucket) {
+		keys = append(keys, bucket.Format("2006-01-02"))
+	}
+	return keys
+}
+
 // calculateMetrics calculates aggregated metrics from time-series data
 func (s *IrrigationAnalyticsService) calculateMetrics(data []repository.AnalyticsAggregation) model.AnalyticsMetrics {
 	if len(data) == 0 {
@@ -143,13 +1284,14 @@ func (s *IrrigationAnalyticsService) calculateMetrics(data []repository.Analytic
 		}
 	}
 
-	var totalVolume float64
+	var totalVolume, totalNominal float64
 	var totalEvents int
 	var efficiencies []float64
 	var minEfficiency, maxEfficiency *float64
 
 	for _, entry := range data {
 		totalVolume += entry.TotalRealAmount
+		totalNominal += entry.TotalNominalAmount
 		totalEvents += entry.EventCount
 
 		if entry.AvgEfficiency != nil {
@@ -170,6 +1312,7 @@ func (s *IrrigationAnalyticsService) calculateMetrics(data []repository.Analytic
 	metrics := model.AnalyticsMetrics{
 		TotalIrrigationVolumeMM: totalVolume,
 		TotalIrrigationEvents:   totalEvents,
+		WeightedEfficiency:      weightedEfficiency(totalVolume, totalNominal),
 	}
 
 	// Calculate average efficiency from valid values
@@ -189,9 +1332,37 @@ func (s *IrrigationAnalyticsService) calculateMetrics(data []repository.Analytic
 		}
 	}
 
+	metrics.EfficiencyStdDev = combinedEfficiencyStdDev(data)
+
 	return metrics
 }
 
+// combinedEfficiencyStdDev pools the per-bucket efficiency counts, means and standard deviations
+// into the population standard deviation of every event; nil with fewer than two valid events
+func combinedEfficiencyStdDev(data []repository.AnalyticsAggregation) *float64 {
+	var n, sum, sumSquares float64
+	for _, entry := range data {
+		if entry.EfficiencyCount == 0 || entry.AvgEfficiency == nil {
+			continue
+		}
+		count, mean := float64(entry.EfficiencyCount), *entry.AvgEfficiency
+		var variance float64
+		if entry.StdDevEfficiency != nil {
+			variance = *entry.StdDevEfficiency * *entry.StdDevEfficiency
+		}
+		n += count
+		sum += count * mean
+		sumSquares += count * (variance + mean*mean)
+	}
+	if n < 2 {
+		return nil
+	}
+
+	mean := sum / n
+	stdDev := math.Sqrt(max(sumSquares/n-mean*mean, 0))
+	return &stdDev
+}
+
 // getYoYMetrics converts YoY data to response format with null handling
 func (s *IrrigationAnalyticsService) getYoYMetrics(
 	yoyData map[int]repository.YoYAnalyticsData,
@@ -203,6 +1374,7 @@ func (s *IrrigationAnalyticsService) getYoYMetrics(
 		return &model.YoYComparison{
 			DataIncomplete: true,
 			Note:           fmt.Sprintf("No data available for %s (%d)", yearLabel, year),
+			Reason:         model.YoYReasonNoData,
 		}
 	}
 
@@ -222,12 +1394,17 @@ func (s *IrrigationAnalyticsService) getYoYMetrics(
 		return &model.YoYComparison{
 			DataIncomplete: true,
 			Note:           fmt.Sprintf("No events found for %s (%d)", yearLabel, year),
+			Reason:         model.YoYReasonNoEvents,
 		}
 	}
 
-	// Set efficiency metrics
+	// Set efficiency metrics; events without a positive nominal amount leave them null
+	comparison.WeightedEfficiency = weightedEfficiency(data.TotalRealAmount, data.TotalNominalAmount)
 	if data.AvgEfficiency != nil {
 		comparison.AverageEfficiency = data.AvgEfficiency
+	} else {
+		comparison.Note = fmt.Sprintf("No efficiency data for %s (%d): no events with a positive nominal amount", yearLabel, year)
+		comparison.Reason = model.YoYReasonPartial
 	}
 
 	if data.MinEfficiency != nil && data.MaxEfficiency != nil {
@@ -241,62 +1418,142 @@ func (s *IrrigationAnalyticsService) getYoYMetrics(
 }
 
 // calculatePeriodComparison calculates year-over-year percentage changes
+// basis selects whether the efficiency change compares average or weighted efficiencies
 func (s *IrrigationAnalyticsService) calculatePeriodComparison(
 	current model.AnalyticsMetrics,
 	yoY1, yoY2 *model.YoYComparison,
+	basis model.EfficiencyBasis,
 ) *model.PeriodComparisonSet {
 	result := &model.PeriodComparisonSet{}
 
 	// Compare with previous year
 	if yoY1 != nil && !yoY1.DataIncomplete && yoY1.TotalIrrigationVolumeMM != nil {
-		result.VsPeriod1Y = s.calculatePercentageChanges(current, *yoY1.TotalIrrigationVolumeMM, *yoY1.TotalIrrigationEvents, yoY1.AverageEfficiency)
+		prevEfficiency := efficiencyForBasis(basis, yoY1.AverageEfficiency, yoY1.WeightedEfficiency)
+		result.VsPeriod1Y = s.calculatePercentageChanges(current, *yoY1.TotalIrrigationVolumeMM, *yoY1.TotalIrrigationEvents, prevEfficiency, basis)
 	}
 
 	// Compare with two years ago
 	if yoY2 != nil && !yoY2.DataIncomplete && yoY2.TotalIrrigationVolumeMM != nil {
-		result.VsPeriod2Y = s.calculatePercentageChanges(current, *yoY2.TotalIrrigationVolumeMM, *yoY2.TotalIrrigationEvents, yoY2.AverageEfficiency)
+		prevEfficiency := efficiencyForBasis(basis, yoY2.AverageEfficiency, yoY2.WeightedEfficiency)
+		result.VsPeriod2Y = s.calculatePercentageChanges(current, *yoY2.TotalIrrigationVolumeMM, *yoY2.TotalIrrigationEvents, prevEfficiency, basis)
 	}
 
 	return result
 }
 
+// efficiencyForBasis picks the efficiency a period comparison measures; an empty basis means average
+func efficiencyForBasis(basis model.EfficiencyBasis, average, weighted *float64) *float64 {
+	if basis == model.EfficiencyBasisWeighted {
+		return weighted
+	}
+	return average
+}
+
+// previousWindow returns the window of the same length as [start, end] that ends just before start
+func previousWindow(start, end time.Time) (time.Time, time.Time) {
+	length := end.Sub(start) + time.Nanosecond
+	return start.Add(-length), start.Add(-time.Nanosecond)
+}
+
+// comparePreviousWindow measures the preceding window of equal length and compares the current metrics with it
+// The comparison is skipped (DataIncomplete) when the farm's recorded history starts after the window start,
+// since a partially covered window would understate the baseline, or when the window has no events
+func (s *IrrigationAnalyticsService) comparePreviousWindow(
+	ctx context.Context,
+	farmID uint,
+	start, end time.Time,
+	aggregation model.Aggregation,
+	current model.AnalyticsMetrics,
+	basis model.EfficiencyBasis,
+) (*model.PreviousWindow, *model.PeriodComparison, error) {
+	prevStart, prevEnd := previousWindow(start, end)
+	window := &model.PreviousWindow{Period: model.IrrigationAnalyticsPeriod{Start: prevStart, End: prevEnd}}
+
+	first, err := s.repo.GetFirstEventTimeForFarm(ctx, farmID)
+	if err != nil {
+		s.logger.WithContext(ctx).Error("failed to get first event time", zap.Error(err))
+		return nil, nil, err
+	}
+	if first == nil || first.After(prevStart) {
+		window.DataIncomplete = true
+		window.Note = "Not enough history for the previous window: the farm has no irrigation events"
+		if first != nil {
+			window.Note = fmt.Sprintf("Not enough history for the previous window: history starts %s, after the window start %s",
+				first.Format("2006-01-02"), prevStart.Format("2006-01-02"))
+		}
+		return window, nil, nil
+	}
+
+	data, _, _, err := s.repo.GetAnalyticsForFarmByDateRange(ctx, farmID, prevStart, prevEnd, aggregation, len(bucketKeys(prevStart, prevEnd, aggregation)), 0)
+	if err != nil {
+		s.logger.WithContext(ctx).Error("failed to get analytics for previous window", zap.Error(err))
+		return nil, nil, err
+	}
+
+	previous := s.calculateMetrics(data)
+	if previous.TotalIrrigationEvents == 0 {
+		window.DataIncomplete = true
+		window.Note = "No events found in the previous window"
+		return window, nil, nil
+	}
+
+	window.TotalIrrigationVolumeMM = &previous.TotalIrrigationVolumeMM
+	window.TotalIrrigationEvents = &previous.TotalIrrigationEvents
+	window.AverageEfficiency = previous.AverageEfficiency
+	window.WeightedEfficiency = previous.WeightedEfficiency
+
+	prevEfficiency := efficiencyForBasis(basis, previous.AverageEfficiency, previous.WeightedEfficiency)
+	return window, s.calculatePercentageChanges(current, previous.TotalIrrigationVolumeMM, previous.TotalIrrigationEvents, prevEfficiency, basis), nil
+}
+
 // Calculate percentage changes between two periods
+// A drop to zero is a -100% change; a rise from zero has no defined percentage and is null
+// prevEfficiency must be on basis, which selects the current efficiency it is compared with
 func (s *IrrigationAnalyticsService) calculatePercentageChanges(
 	current model.AnalyticsMetrics,
 	prevVolume float64,
 	prevEvents int,
 	prevEfficiency *float64,
+	basis model.EfficiencyBasis,
 ) *model.PeriodComparison {
 	comparison := &model.PeriodComparison{}
 
 	// Volume change
-	if prevVolume > 0 {
-		change := ((current.TotalIrrigationVolumeMM - prevVolume) / prevVolume) * 100
-		comparison.VolumeChangePercent = &change
-	}
+	comparison.VolumeChangePercent = percentChange(current.TotalIrrigationVolumeMM, prevVolume)
 
 	// Events change
-	if prevEvents > 0 {
-		change := ((float64(current.TotalIrrigationEvents) - float64(prevEvents)) / float64(prevEvents)) * 100
-		comparison.EventsChangePercent = &change
-	}
+	comparison.EventsChangePercent = percentChange(float64(current.TotalIrrigationEvents), float64(prevEvents))
 
-	// Efficiency change
-	if prevEfficiency != nil && current.AverageEfficiency != nil && *prevEfficiency > 0 {
-		change := ((*current.AverageEfficiency - *prevEfficiency) / *prevEfficiency) * 100
-		comparison.EfficiencyChangePercent = &change
+	// Efficiency change; a period without valid efficiencies has nothing to compare, which is not a drop to zero
+	currentEfficiency := efficiencyForBasis(basis, current.AverageEfficiency, current.WeightedEfficiency)
+	if prevEfficiency != nil && currentEfficiency != nil {
+		comparison.EfficiencyChangePercent = percentChange(*currentEfficiency, *prevEfficiency)
 	}
 
 	return comparison
 }
 
+// percentChange returns ((current - previous) / previous) * 100, or nil when previous is zero or negative
+// A current value of zero against a positive previous value reports exactly -100
+func percentChange(current, previous float64) *float64 {
+	if previous <= 0 {
+		return nil
+	}
+	if current == 0 {
+		change := -100.0
+		return &change
+	}
+	change := ((current - previous) / previous) * 100
+	return &change
+}
+
 // convertTimeSeriesData converts repository data to response format
 func (s *IrrigationAnalyticsService) convertTimeSeriesData(data []repository.AnalyticsAggregation) []model.TimeSeriesEntry {
 	entries := make([]model.TimeSeriesEntry, 0, len(data))
 
 	for _, item := range data {
 		entry := model.TimeSeriesEntry{
-			Date:            item.Period.Format("2006-01-02"),
+			Date:            item.Period,
 			NominalAmountMM: item.TotalNominalAmount,
 			RealAmountMM:    item.TotalRealAmount,
 			Efficiency:      item.AvgEfficiency,
@@ -308,6 +1565,93 @@ func (s *IrrigationAnalyticsService) convertTimeSeriesData(data []repository.Ana
 	return entries
 }
 
+// anomalyZScore is how many standard deviations a bucket's efficiency must lie from the mean to be anomalous
+const anomalyZScore = 2.0
+
+// flagAnomalies marks the entries whose efficiency lies more than anomalyZScore population standard deviations
+// from the mean efficiency of entries; nothing is flagged with fewer than three efficiencies or no spread
+func flagAnomalies(entries []model.TimeSeriesEntry) {
+	var efficiencies []float64
+	for _, entry := range entries {
+		if entry.Efficiency != nil {
+			efficiencies = append(efficiencies, *entry.Efficiency)
+		}
+	}
+	if len(efficiencies) < 3 {
+		return
+	}
+
+	var sum float64
+	for _, e := range efficiencies {
+		sum += e
+	}
+	mean := sum / float64(len(efficiencies))
+	var squares float64
+	for _, e := range efficiencies {
+		squares += (e - mean) * (e - mean)
+	}
+	stdDev := math.Sqrt(squares / float64(len(efficiencies)))
+	if stdDev == 0 {
+		return
+	}
+
+	for i := range entries {
+		if entries[i].Efficiency != nil && math.Abs(*entries[i].Efficiency-mean) > anomalyZScore*stdDev {
+			entries[i].Anomalous = true
+		}
+	}
+}
+
+// anomalousPage flags the anomalies among every bucket of the period and returns the requested page of
+// the anomalous buckets along with how many there are; cumulative running totals still include every bucket
+func (s *IrrigationAnalyticsService) anomalousPage(series []repository.AnalyticsAggregation, cumulative bool, page, limit int) ([]model.TimeSeriesEntry, int64) {
+	entries := s.convertTimeSeriesData(series)
+	if cumulative {
+		accumulateTimeSeries(entries, 0, 0)
+	}
+	flagAnomalies(entries)
+	anomalies := anomalousEntries(entries)
+
+	from := min((page-1)*limit, len(anomalies))
+	to := min(from+limit, len(anomalies))
+	return anomalies[from:to], int64(len(anomalies))
+}
+
+// anomalousPeriods flags the anomalies among entries and returns the dates of the flagged ones
+func anomalousPeriods(entries []model.TimeSeriesEntry) map[string]bool {
+	flagAnomalies(entries)
+	anomalous := make(map[string]bool)
+	for _, entry := range anomalousEntries(entries) {
+		anomalous[entry.Date] = true
+	}
+	return anomalous
+}
+
+// anomalousEntries keeps the entries flagged by flagAnomalies, in order
+func anomalousEntries(entries []model.TimeSeriesEntry) []model.TimeSeriesEntry {
+	anomalies := make([]model.TimeSeriesEntry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Anomalous {
+			anomalies = append(anomalies, entry)
+		}
+	}
+	return anomalies
+}
+
+// accumulateTimeSeries replaces each bucket's amounts with running totals, starting from the given
+// carried-over totals, and keeps the bucket's own amounts in the Bucket* fields; entries must be in period order
+func accumulateTimeSeries(entries []model.TimeSeriesEntry, nominal, real float64) {
+	for i := range entries {
+		bucketNominal, bucketReal := entries[i].NominalAmountMM, entries[i].RealAmountMM
+		nominal += bucketNominal
+		real += bucketReal
+		entries[i].BucketNominalAmountMM = &bucketNominal
+		entries[i].BucketRealAmountMM = &bucketReal
+		entries[i].NominalAmountMM = nominal
+		entries[i].RealAmountMM = real
+	}
+}
+
 // convertSectorBreakdownData converts repository data to response format
 func (s *IrrigationAnalyticsService) convertSectorBreakdownData(data []repository.SectorAnalyticsData) []model.SectorBreakdown {
 	breakdown := make([]model.SectorBreakdown, 0, len(data))
@@ -318,8 +1662,22 @@ func (s *IrrigationAnalyticsService) convertSectorBreakdownData(data []repositor
 			SectorName:        item.SectorName,
 			TotalVolumeMM:     item.TotalRealAmount,
 			AverageEfficiency: item.AvgEfficiency,
+			SampleSize:        item.EventCount,
+			Confidence:        s.confidenceFor(item.EventCount),
 		})
 	}
 
 	return breakdown
 }
+
+// confidenceFor grades how far sector metrics can be trusted given the number of events behind them
+func (s *IrrigationAnalyticsService) confidenceFor(sampleSize int) string {
+	switch {
+	case sampleSize >= s.cfg.ConfidenceHighMinEvents:
+		return model.ConfidenceHigh
+	case sampleSize >= s.cfg.ConfidenceMediumMinEvents:
+		return model.ConfidenceMedium
+	default:
+		return model.ConfidenceLow
+	}
+}
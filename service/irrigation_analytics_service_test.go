@@ -3,31 +3,126 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"math"
 	"testing"
 	"time"
 
+	"github.com/sebaespinosa/test_NF/config"
 	"github.com/sebaespinosa/test_NF/internal/logging"
+	"github.com/sebaespinosa/test_NF/model"
 	"github.com/sebaespinosa/test_NF/repository"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
 )
 
 type mockAnalyticsRepo struct {
-	getAnalyticsFn func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation string, limit, offset int) ([]repository.AnalyticsAggregation, int64, error)
-	getYoYFn       func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation string) (map[int]repository.YoYAnalyticsData, error)
-	getSectorFn    func(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time) ([]repository.SectorAnalyticsData, error)
+	getAnalyticsFn func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation, limit, offset int) ([]repository.AnalyticsAggregation, int64, error)
+	getYoYFn       func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) (map[int]repository.YoYAnalyticsData, error)
+	getSectorFn    func(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time, limit, offset int) ([]repository.SectorAnalyticsData, int64, error)
+	getSectorTSFn  func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) ([]repository.SectorTimeSeriesData, error)
+	stackedFn      func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) ([]repository.PeriodSectorData, error)
+	getTopDaysFn   func(ctx context.Context, farmID uint, startTime, endTime time.Time, n int) ([]repository.DailyTotalData, error)
+	countActiveFn  func(ctx context.Context, farmID uint, startTime, endTime time.Time) (int, error)
+	getScheduleFn  func(ctx context.Context, farmID uint, startTime, endTime time.Time) ([]repository.SectorScheduleData, error)
+	firstEventFn   func(ctx context.Context, farmID uint) (*time.Time, error)
+	dowFn          func(ctx context.Context, farmID uint, startTime, endTime time.Time) ([]repository.DayOfWeekData, error)
+	byAmountFn     func(ctx context.Context, farmID uint, startTime, endTime time.Time, bins int) ([]repository.AmountBinData, error)
+	qualityFn      func(ctx context.Context, farmID uint, startTime, endTime time.Time) (repository.DataQualityData, error)
+	rankingFn      func(ctx context.Context, farmID uint, startTime, endTime time.Time, limit, offset int) ([]repository.SectorAnalyticsData, int64, error)
+	multiFarmFn    func(ctx context.Context, farmIDs []uint, startTime, endTime time.Time) ([]repository.FarmSectorAnalyticsData, error)
+	// truncated is reported by GetAnalyticsForFarmByDateRange alongside getAnalyticsFn's result
+	truncated bool
 }
 
-func (m *mockAnalyticsRepo) GetAnalyticsForFarmByDateRange(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation string, limit, offset int) ([]repository.AnalyticsAggregation, int64, error) {
-	return m.getAnalyticsFn(ctx, farmID, startTime, endTime, aggregation, limit, offset)
+func (m *mockAnalyticsRepo) GetAnalyticsForFarmByDateRange(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation, limit, offset int) ([]repository.AnalyticsAggregation, int64, bool, error) {
+	data, total, err := m.getAnalyticsFn(ctx, farmID, startTime, endTime, aggregation, limit, offset)
+	return data, total, m.truncated, err
 }
 
-func (m *mockAnalyticsRepo) GetYoYComparison(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation string) (map[int]repository.YoYAnalyticsData, error) {
+func (m *mockAnalyticsRepo) GetYoYComparison(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) (map[int]repository.YoYAnalyticsData, error) {
 	return m.getYoYFn(ctx, farmID, startTime, endTime, aggregation)
 }
 
-func (m *mockAnalyticsRepo) GetSectorBreakdownForFarm(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time) ([]repository.SectorAnalyticsData, error) {
-	return m.getSectorFn(ctx, farmID, sectorID, startTime, endTime)
+func (m *mockAnalyticsRepo) GetSectorBreakdownForFarm(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time, limit, offset int) ([]repository.SectorAnalyticsData, int64, error) {
+	return m.getSectorFn(ctx, farmID, sectorID, startTime, endTime, limit, offset)
+}
+
+func (m *mockAnalyticsRepo) GetSectorRanking(ctx context.Context, farmID uint, startTime, endTime time.Time, limit, offset int) ([]repository.SectorAnalyticsData, int64, error) {
+	return m.rankingFn(ctx, farmID, startTime, endTime, limit, offset)
+}
+
+func (m *mockAnalyticsRepo) GetSectorBreakdownForFarms(ctx context.Context, farmIDs []uint, startTime, endTime time.Time) ([]repository.FarmSectorAnalyticsData, error) {
+	return m.multiFarmFn(ctx, farmIDs, startTime, endTime)
+}
+
+func (m *mockAnalyticsRepo) GetSectorTimeSeriesForFarm(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) ([]repository.SectorTimeSeriesData, error) {
+	if m.getSectorTSFn == nil {
+		return nil, nil
+	}
+	return m.getSectorTSFn(ctx, farmID, startTime, endTime, aggregation)
+}
+
+func (m *mockAnalyticsRepo) GetFarmTimeSeriesBySector(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) ([]repository.PeriodSectorData, error) {
+	if m.stackedFn == nil {
+		return nil, nil
+	}
+	return m.stackedFn(ctx, farmID, startTime, endTime, aggregation)
+}
+
+func (m *mockAnalyticsRepo) GetTopIrrigationDays(ctx context.Context, farmID uint, startTime, endTime time.Time, n int) ([]repository.DailyTotalData, error) {
+	return m.getTopDaysFn(ctx, farmID, startTime, endTime, n)
+}
+
+func (m *mockAnalyticsRepo) CountActiveSectors(ctx context.Context, farmID uint, startTime, endTime time.Time) (int, error) {
+	if m.countActiveFn == nil {
+		return 0, nil
+	}
+	return m.countActiveFn(ctx, farmID, startTime, endTime)
+}
+
+func (m *mockAnalyticsRepo) GetFirstEventTimeForFarm(ctx context.Context, farmID uint) (*time.Time, error) {
+	if m.firstEventFn == nil {
+		return nil, nil
+	}
+	return m.firstEventFn(ctx, farmID)
+}
+
+func (m *mockAnalyticsRepo) GetDayOfWeekDistribution(ctx context.Context, farmID uint, startTime, endTime time.Time) ([]repository.DayOfWeekData, error) {
+	if m.dowFn == nil {
+		return nil, nil
+	}
+	return m.dowFn(ctx, farmID, startTime, endTime)
+}
+
+func (m *mockAnalyticsRepo) GetEfficiencyByAmount(ctx context.Context, farmID uint, startTime, endTime time.Time, bins int) ([]repository.AmountBinData, error) {
+	if m.byAmountFn == nil {
+		return nil, nil
+	}
+	return m.byAmountFn(ctx, farmID, startTime, endTime, bins)
+}
+
+func (m *mockAnalyticsRepo) GetDataQualityForFarm(ctx context.Context, farmID uint, startTime, endTime time.Time) (repository.DataQualityData, error) {
+	if m.qualityFn == nil {
+		return repository.DataQualityData{}, nil
+	}
+	return m.qualityFn(ctx, farmID, startTime, endTime)
+}
+
+func (m *mockAnalyticsRepo) GetSectorScheduleForFarm(ctx context.Context, farmID uint, startTime, endTime time.Time) ([]repository.SectorScheduleData, error) {
+	return m.getScheduleFn(ctx, farmID, startTime, endTime)
+}
+
+func newTestAnalyticsConfig() *config.AnalyticsConfig {
+	return &config.AnalyticsConfig{
+		DefaultAggregation:         model.AggregationDaily,
+		AlertDeficitThresholdMM:    50,
+		AlertCriticalEfficiencyGap: 0.1,
+		SparklineMaxPoints:         30,
+		ConfidenceMediumMinEvents:  10,
+		ConfidenceHighMinEvents:    50,
+	}
 }
 
 func newTestLogger(t *testing.T) *logging.Logger {
@@ -37,19 +132,28 @@ func newTestLogger(t *testing.T) *logging.Logger {
 	return logger
 }
 
+// The read-only repository must stay usable wherever the service expects an AnalyticsRepository
+var _ AnalyticsRepository = (*repository.AnalyticsRepository)(nil)
+
+func TestAnalyticsRepository_SatisfiesInterface(t *testing.T) {
+	assert.Implements(t, (*AnalyticsRepository)(nil), repository.NewAnalyticsRepository(nil))
+	assert.Implements(t, (*AnalyticsRepository)(nil), repository.NewAnalyticsRepository(nil).WithZeroNominalPolicy(model.ZeroNominalZero))
+}
+
 func TestGetAnalytics_Success(t *testing.T) {
 	logger := newTestLogger(t)
 	ctx := context.Background()
 
-	currentYear := time.Now().Year()
+	now := time.Date(2024, 4, 2, 9, 0, 0, 0, time.UTC)
+	currentYear := now.Year()
 	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
 	end := time.Date(2024, 3, 31, 23, 59, 59, 0, time.UTC)
 
 	repo := &mockAnalyticsRepo{
-		getAnalyticsFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation string, limit, offset int) ([]repository.AnalyticsAggregation, int64, error) {
+		getAnalyticsFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation, limit, offset int) ([]repository.AnalyticsAggregation, int64, error) {
 			return []repository.AnalyticsAggregation{
 				{
-					Period:             time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+					Period:             "2024-03-01",
 					Year:               2024,
 					TotalRealAmount:    30,
 					TotalNominalAmount: 40,
@@ -60,7 +164,7 @@ func TestGetAnalytics_Success(t *testing.T) {
 				},
 			}, 1, nil
 		},
-		getYoYFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation string) (map[int]repository.YoYAnalyticsData, error) {
+		getYoYFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) (map[int]repository.YoYAnalyticsData, error) {
 			return map[int]repository.YoYAnalyticsData{
 				currentYear - 1: {
 					Year:            currentYear - 1,
@@ -80,48 +184,1870 @@ func TestGetAnalytics_Success(t *testing.T) {
 				},
 			}, nil
 		},
-		getSectorFn: func(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time) ([]repository.SectorAnalyticsData, error) {
+		getSectorFn: func(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time, limit, offset int) ([]repository.SectorAnalyticsData, int64, error) {
 			return []repository.SectorAnalyticsData{
 				{SectorID: 1, SectorName: "S1", TotalRealAmount: 30, AvgEfficiency: floatPtr(0.75)},
-			}, nil
+			}, 1, nil
+		},
+		countActiveFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time) (int, error) {
+			return 1, nil
 		},
 	}
 
-	svc := NewIrrigationAnalyticsService(repo, logger)
-	resp, err := svc.GetAnalytics(ctx, 1, &start, &end, nil, "daily", 1, 10)
+	svc := NewIrrigationAnalyticsService(repo, logger, newTestAnalyticsConfig())
+	svc.now = func() time.Time { return now }
+	resp, err := svc.GetAnalytics(ctx, 1, &start, &end, nil, model.AggregationDaily, 1, 10, model.AnalyticsOptions{})
 	require.NoError(t, err)
 
 	assert.Equal(t, 1, resp.TimeSeries.Pagination.TotalPages)
 	assert.Equal(t, 1, resp.TimeSeries.Pagination.TotalCount)
 	require.NotNil(t, resp.PeriodComparison)
 	require.NotNil(t, resp.PeriodComparison.VsPeriod1Y)
+	// The comparison years follow the service clock
+	require.NotNil(t, resp.SamePeriod1Y)
+	assert.Equal(t, 25.0, *resp.SamePeriod1Y.TotalIrrigationVolumeMM)
 	assert.NotNil(t, resp.PeriodComparison.VsPeriod1Y.VolumeChangePercent)
 	assert.Equal(t, 30.0, resp.Metrics.TotalIrrigationVolumeMM)
+	assert.Equal(t, 1, resp.Metrics.ActiveSectorCount)
+	assert.True(t, resp.HasData)
 	assert.Len(t, resp.SectorBreakdown, 1)
 }
 
+func TestGetAnalytics_DefaultFieldsSkipQueries(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 31, 23, 59, 59, 0, time.UTC)
+
+	var called []string
+	repo := &mockAnalyticsRepo{
+		getAnalyticsFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation, limit, offset int) ([]repository.AnalyticsAggregation, int64, error) {
+			called = append(called, "analytics")
+			return []repository.AnalyticsAggregation{{Period: "2024-03-01", TotalRealAmount: 30, TotalNominalAmount: 40, EventCount: 2}}, 1, nil
+		},
+		getYoYFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) (map[int]repository.YoYAnalyticsData, error) {
+			called = append(called, "yoy")
+			return nil, nil
+		},
+		getSectorFn: func(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time, limit, offset int) ([]repository.SectorAnalyticsData, int64, error) {
+			called = append(called, "sectors")
+			return nil, 0, nil
+		},
+		getSectorTSFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) ([]repository.SectorTimeSeriesData, error) {
+			called = append(called, "sector_time_series")
+			return nil, nil
+		},
+	}
+
+	// A metrics-only deployment queries neither the YoY nor the sector data
+	cfg := newTestAnalyticsConfig()
+	cfg.DefaultFields = model.AnalyticsFields{model.AnalyticsFieldMetrics}
+	svc := NewIrrigationAnalyticsService(repo, newTestLogger(t), cfg)
+
+	resp, err := svc.GetAnalytics(context.Background(), 1, &start, &end, nil, model.AggregationDaily, 1, 10, model.AnalyticsOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"analytics"}, called)
+	assert.Equal(t, 30.0, resp.Metrics.TotalIrrigationVolumeMM)
+	assert.Nil(t, resp.SamePeriod1Y)
+	assert.Nil(t, resp.SamePeriod2Y)
+	assert.Nil(t, resp.SectorBreakdown)
+
+	// Sections asked for explicitly override the default
+	called = nil
+	_, err = svc.GetAnalytics(context.Background(), 1, &start, &end, nil, model.AggregationDaily, 1, 10,
+		model.AnalyticsOptions{Fields: model.AnalyticsFields{model.AnalyticsFieldMetrics, model.AnalyticsFieldYoY}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"analytics", "yoy"}, called)
+}
+
+func TestGetAnalytics_Cumulative(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 5, 23, 59, 59, 0, time.UTC)
+	buckets := []repository.AnalyticsAggregation{
+		{Period: "2024-03-01", TotalNominalAmount: 10, TotalRealAmount: 8, EventCount: 1},
+		{Period: "2024-03-02", TotalNominalAmount: 0, TotalRealAmount: 0, EventCount: 0},
+		{Period: "2024-03-03", TotalNominalAmount: 12, TotalRealAmount: 11, EventCount: 1},
+		{Period: "2024-03-04", TotalNominalAmount: 5, TotalRealAmount: 4, EventCount: 1},
+	}
+
+	repo := &mockAnalyticsRepo{
+		getAnalyticsFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation, limit, offset int) ([]repository.AnalyticsAggregation, int64, error) {
+			return buckets[offset:min(offset+limit, len(buckets))], int64(len(buckets)), nil
+		},
+		getYoYFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) (map[int]repository.YoYAnalyticsData, error) {
+			return nil, nil
+		},
+		getSectorFn: func(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time, limit, offset int) ([]repository.SectorAnalyticsData, int64, error) {
+			return nil, 0, nil
+		},
+	}
+	svc := NewIrrigationAnalyticsService(repo, newTestLogger(t), newTestAnalyticsConfig())
+	opts := model.AnalyticsOptions{Cumulative: true}
+
+	resp, err := svc.GetAnalytics(context.Background(), 1, &start, &end, nil, model.AggregationDaily, 1, 10, opts)
+	require.NoError(t, err)
+	entries := resp.TimeSeries.Data
+	require.Len(t, entries, 4)
+	for i, entry := range entries {
+		require.NotNil(t, entry.BucketRealAmountMM)
+		require.NotNil(t, entry.BucketNominalAmountMM)
+		assert.Equal(t, buckets[i].TotalRealAmount, *entry.BucketRealAmountMM)
+		assert.Equal(t, buckets[i].TotalNominalAmount, *entry.BucketNominalAmountMM)
+		if i > 0 {
+			assert.GreaterOrEqual(t, entry.RealAmountMM, entries[i-1].RealAmountMM)
+			assert.GreaterOrEqual(t, entry.NominalAmountMM, entries[i-1].NominalAmountMM)
+		}
+	}
+	assert.Equal(t, []float64{8, 8, 19, 23}, []float64{entries[0].RealAmountMM, entries[1].RealAmountMM, entries[2].RealAmountMM, entries[3].RealAmountMM})
+	assert.Equal(t, 27.0, entries[3].NominalAmountMM)
+	// Period metrics are unaffected
+	assert.Equal(t, 23.0, resp.Metrics.TotalIrrigationVolumeMM)
+
+	// Later pages continue from the totals of the buckets before them
+	resp, err = svc.GetAnalytics(context.Background(), 1, &start, &end, nil, model.AggregationDaily, 2, 2, opts)
+	require.NoError(t, err)
+	require.Len(t, resp.TimeSeries.Data, 2)
+	assert.Equal(t, 19.0, resp.TimeSeries.Data[0].RealAmountMM)
+	assert.Equal(t, 23.0, resp.TimeSeries.Data[1].RealAmountMM)
+	assert.Equal(t, 4.0, *resp.TimeSeries.Data[1].BucketRealAmountMM)
+
+	// Without the option the amounts stay per bucket
+	resp, err = svc.GetAnalytics(context.Background(), 1, &start, &end, nil, model.AggregationDaily, 1, 10, model.AnalyticsOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 11.0, resp.TimeSeries.Data[2].RealAmountMM)
+	assert.Nil(t, resp.TimeSeries.Data[2].BucketRealAmountMM)
+}
+
+type stubSectorFinder struct {
+	sectors map[uint]model.IrrigationSector
+	lookups int
+}
+
+func (s *stubSectorFinder) FindByID(ctx context.Context, id uint) (*model.IrrigationSector, error) {
+	s.lookups++
+	sector, ok := s.sectors[id]
+	if !ok {
+		return nil, fmt.Errorf("failed to find irrigation sector by ID: %w", gorm.ErrRecordNotFound)
+	}
+	return &sector, nil
+}
+
+func TestGetAnalytics_SectorBelongsToFarm(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 31, 23, 59, 59, 0, time.UTC)
+
+	queried := 0
+	repo := &mockAnalyticsRepo{
+		getAnalyticsFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation, limit, offset int) ([]repository.AnalyticsAggregation, int64, error) {
+			queried++
+			return nil, 0, nil
+		},
+		getYoYFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) (map[int]repository.YoYAnalyticsData, error) {
+			return nil, nil
+		},
+		getSectorFn: func(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time, limit, offset int) ([]repository.SectorAnalyticsData, int64, error) {
+			return nil, 0, nil
+		},
+	}
+	finder := &stubSectorFinder{sectors: map[uint]model.IrrigationSector{
+		1: {ID: 1, FarmID: 1},
+		2: {ID: 2, FarmID: 2},
+	}}
+	svc := NewIrrigationAnalyticsService(repo, newTestLogger(t), newTestAnalyticsConfig()).WithSectorFinder(finder)
+	ctx := context.Background()
+
+	// Matching sector: looked up once, then the analytics run
+	_, err := svc.GetAnalytics(ctx, 1, &start, &end, uintPtr(1), model.AggregationDaily, 1, 10, model.AnalyticsOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, finder.lookups)
+	assert.Equal(t, 1, queried)
+
+	// Sector of another farm: rejected before any analytics query
+	_, err = svc.GetAnalytics(ctx, 1, &start, &end, uintPtr(2), model.AggregationDaily, 1, 10, model.AnalyticsOptions{})
+	var notInFarmErr *SectorNotInFarmError
+	require.ErrorAs(t, err, &notInFarmErr)
+	assert.Equal(t, uint(2), notInFarmErr.SectorID)
+	assert.Equal(t, uint(1), notInFarmErr.FarmID)
+	assert.Equal(t, 1, queried)
+
+	// Unknown sector
+	_, err = svc.GetAnalytics(ctx, 1, &start, &end, uintPtr(99), model.AggregationDaily, 1, 10, model.AnalyticsOptions{})
+	assert.ErrorIs(t, err, ErrSectorNotFound)
+	assert.Equal(t, 1, queried)
+
+	// No filter, no lookup
+	_, err = svc.GetAnalytics(ctx, 1, &start, &end, nil, model.AggregationDaily, 1, 10, model.AnalyticsOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 3, finder.lookups)
+}
+
+func TestGetAnalytics_EmptyFarm(t *testing.T) {
+	logger := newTestLogger(t)
+	ctx := context.Background()
+
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+
+	repo := &mockAnalyticsRepo{
+		getAnalyticsFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation, limit, offset int) ([]repository.AnalyticsAggregation, int64, error) {
+			return nil, 0, nil
+		},
+		getYoYFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) (map[int]repository.YoYAnalyticsData, error) {
+			return nil, nil
+		},
+		getSectorFn: func(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time, limit, offset int) ([]repository.SectorAnalyticsData, int64, error) {
+			return nil, 0, nil
+		},
+	}
+
+	svc := NewIrrigationAnalyticsService(repo, logger, newTestAnalyticsConfig())
+	resp, err := svc.GetAnalytics(ctx, 1, &start, &end, nil, model.AggregationDaily, 1, 10, model.AnalyticsOptions{})
+	require.NoError(t, err)
+
+	assert.False(t, resp.HasData)
+	assert.Zero(t, resp.Metrics.TotalIrrigationVolumeMM)
+	assert.Empty(t, resp.TimeSeries.Data)
+}
+
 func TestGetAnalytics_RepoError(t *testing.T) {
 	logger := newTestLogger(t)
 	ctx := context.Background()
 	errExpected := errors.New("db error")
 
 	repo := &mockAnalyticsRepo{
-		getAnalyticsFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation string, limit, offset int) ([]repository.AnalyticsAggregation, int64, error) {
+		getAnalyticsFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation, limit, offset int) ([]repository.AnalyticsAggregation, int64, error) {
 			return nil, 0, errExpected
 		},
-		getYoYFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation string) (map[int]repository.YoYAnalyticsData, error) {
+		getYoYFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) (map[int]repository.YoYAnalyticsData, error) {
 			return nil, nil
 		},
-		getSectorFn: func(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time) ([]repository.SectorAnalyticsData, error) {
-			return nil, nil
+		getSectorFn: func(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time, limit, offset int) ([]repository.SectorAnalyticsData, int64, error) {
+			return nil, 0, nil
 		},
 	}
 
-	svc := NewIrrigationAnalyticsService(repo, logger)
+	svc := NewIrrigationAnalyticsService(repo, logger, newTestAnalyticsConfig())
 	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
 	end := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
-	_, err := svc.GetAnalytics(ctx, 1, &start, &end, nil, "daily", 1, 10)
+	_, err := svc.GetAnalytics(ctx, 1, &start, &end, nil, model.AggregationDaily, 1, 10, model.AnalyticsOptions{})
 	require.ErrorIs(t, err, errExpected)
 }
 
-func floatPtr(v float64) *float64 { return &v }
+func TestGetAnalytics_ExcludedFarm(t *testing.T) {
+	ctx := context.Background()
+	var queriedFarms []uint
+	repo := &mockAnalyticsRepo{
+		getAnalyticsFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation, limit, offset int) ([]repository.AnalyticsAggregation, int64, error) {
+			queriedFarms = append(queriedFarms, farmID)
+			return nil, 0, nil
+		},
+		getYoYFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) (map[int]repository.YoYAnalyticsData, error) {
+			return nil, nil
+		},
+		getSectorFn: func(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time, limit, offset int) ([]repository.SectorAnalyticsData, int64, error) {
+			return nil, 0, nil
+		},
+		multiFarmFn: func(ctx context.Context, farmIDs []uint, startTime, endTime time.Time) ([]repository.FarmSectorAnalyticsData, error) {
+			queriedFarms = append(queriedFarms, farmIDs...)
+			return nil, nil
+		},
+	}
+	cfg := newTestAnalyticsConfig()
+	cfg.ExcludedFarmIDs = []uint{2}
+	svc := NewIrrigationAnalyticsService(repo, newTestLogger(t), cfg)
+
+	_, err := svc.GetAnalytics(ctx, 2, nil, nil, nil, model.AggregationDaily, 1, 10, model.AnalyticsOptions{})
+	require.ErrorIs(t, err, ErrFarmExcluded)
+	_, err = svc.GetAlerts(ctx, 2, nil, nil)
+	require.ErrorIs(t, err, ErrFarmExcluded)
+	_, err = svc.GetWaterSavings(ctx, 2, nil, nil)
+	require.ErrorIs(t, err, ErrFarmExcluded)
+	assert.Empty(t, queriedFarms, "excluded farm must not be queried")
+
+	resp, err := svc.GetAnalytics(ctx, 1, nil, nil, nil, model.AggregationDaily, 1, 10, model.AnalyticsOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, uint(1), resp.FarmID)
+	assert.Equal(t, []uint{1}, queriedFarms)
+
+	// The multi-farm breakdown drops excluded farms instead of failing
+	queriedFarms = nil
+	farmIDs := []uint{1, 2, 3}
+	breakdown, err := svc.GetMultiFarmSectorBreakdown(ctx, farmIDs, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []uint{1, 3}, breakdown.FarmIDs)
+	assert.Equal(t, []uint{1, 3}, queriedFarms)
+	assert.Equal(t, []uint{1, 2, 3}, farmIDs, "caller's slice is left intact")
+}
+
+func TestGetAnalytics_SectorSparkline(t *testing.T) {
+	logger := newTestLogger(t)
+	ctx := context.Background()
+
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+
+	repo := &mockAnalyticsRepo{
+		getAnalyticsFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation, limit, offset int) ([]repository.AnalyticsAggregation, int64, error) {
+			return nil, 0, nil
+		},
+		getYoYFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) (map[int]repository.YoYAnalyticsData, error) {
+			return nil, nil
+		},
+		getSectorFn: func(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time, limit, offset int) ([]repository.SectorAnalyticsData, int64, error) {
+			return []repository.SectorAnalyticsData{
+				{SectorID: 1, SectorName: "S1", AvgEfficiency: floatPtr(0.8)},
+			}, 1, nil
+		},
+		getSectorTSFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) ([]repository.SectorTimeSeriesData, error) {
+			return []repository.SectorTimeSeriesData{
+				{SectorID: 1, SectorName: "S1", Period: "2024-03-01", AvgEfficiency: floatPtr(0.9)},
+				{SectorID: 1, SectorName: "S1", Period: "2024-03-02", AvgEfficiency: floatPtr(0.7)},
+				{SectorID: 1, SectorName: "S1", Period: "2024-03-04", AvgEfficiency: floatPtr(0.8)},
+			}, nil
+		},
+	}
+
+	svc := NewIrrigationAnalyticsService(repo, logger, newTestAnalyticsConfig())
+	resp, err := svc.GetAnalytics(ctx, 1, &start, &end, nil, model.AggregationDaily, 1, 10, model.AnalyticsOptions{})
+	require.NoError(t, err)
+
+	require.Len(t, resp.SectorBreakdown, 1)
+	sparkline := resp.SectorBreakdown[0].EfficiencySparkline
+	require.Len(t, sparkline, 4)
+	assert.InDelta(t, 0.9, *sparkline[0], 0.0001)
+	assert.InDelta(t, 0.7, *sparkline[1], 0.0001)
+	assert.Nil(t, sparkline[2])
+	assert.InDelta(t, 0.8, *sparkline[3], 0.0001)
+}
+
+func TestGetAnalytics_SectorConfidence(t *testing.T) {
+	logger := newTestLogger(t)
+	ctx := context.Background()
+
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+
+	repo := &mockAnalyticsRepo{
+		getAnalyticsFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation, limit, offset int) ([]repository.AnalyticsAggregation, int64, error) {
+			return nil, 0, nil
+		},
+		getYoYFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) (map[int]repository.YoYAnalyticsData, error) {
+			return nil, nil
+		},
+		getSectorFn: func(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time, limit, offset int) ([]repository.SectorAnalyticsData, int64, error) {
+			return []repository.SectorAnalyticsData{
+				{SectorID: 1, SectorName: "Sparse", EventCount: 2},
+				{SectorID: 2, SectorName: "Below medium", EventCount: 9},
+				{SectorID: 3, SectorName: "Medium", EventCount: 10},
+				{SectorID: 4, SectorName: "Below high", EventCount: 49},
+				{SectorID: 5, SectorName: "High", EventCount: 200},
+			}, 5, nil
+		},
+	}
+
+	svc := NewIrrigationAnalyticsService(repo, logger, newTestAnalyticsConfig())
+	resp, err := svc.GetAnalytics(ctx, 1, &start, &end, nil, model.AggregationMonthly, 1, 10, model.AnalyticsOptions{})
+	require.NoError(t, err)
+
+	require.Len(t, resp.SectorBreakdown, 5)
+	expected := []struct {
+		sampleSize int
+		confidence string
+	}{
+		{2, model.ConfidenceLow},
+		{9, model.ConfidenceLow},
+		{10, model.ConfidenceMedium},
+		{49, model.ConfidenceMedium},
+		{200, model.ConfidenceHigh},
+	}
+	for i, want := range expected {
+		assert.Equal(t, want.sampleSize, resp.SectorBreakdown[i].SampleSize, resp.SectorBreakdown[i].SectorName)
+		assert.Equal(t, want.confidence, resp.SectorBreakdown[i].Confidence, resp.SectorBreakdown[i].SectorName)
+	}
+}
+
+func TestGetAnalytics_SectorPagination(t *testing.T) {
+	logger := newTestLogger(t)
+	ctx := context.Background()
+
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+
+	var gotLimit, gotOffset int
+	repo := &mockAnalyticsRepo{
+		getAnalyticsFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation, limit, offset int) ([]repository.AnalyticsAggregation, int64, error) {
+			return nil, 0, nil
+		},
+		getYoYFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) (map[int]repository.YoYAnalyticsData, error) {
+			return nil, nil
+		},
+		getSectorFn: func(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time, limit, offset int) ([]repository.SectorAnalyticsData, int64, error) {
+			gotLimit, gotOffset = limit, offset
+			if limit == 0 {
+				return []repository.SectorAnalyticsData{{SectorID: 1}, {SectorID: 2}, {SectorID: 3}}, 3, nil
+			}
+			return []repository.SectorAnalyticsData{{SectorID: 21}, {SectorID: 22}}, 45, nil
+		},
+	}
+	svc := NewIrrigationAnalyticsService(repo, logger, newTestAnalyticsConfig())
+
+	resp, err := svc.GetAnalytics(ctx, 1, &start, &end, nil, model.AggregationMonthly, 1, 10, model.AnalyticsOptions{SectorPage: 3, SectorLimit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 10, gotLimit)
+	assert.Equal(t, 20, gotOffset)
+	assert.Len(t, resp.SectorBreakdown, 2)
+	require.NotNil(t, resp.SectorPagination)
+	assert.Equal(t, model.PaginationMetadata{Page: 3, Limit: 10, TotalCount: 45, TotalPages: 5}, *resp.SectorPagination)
+
+	// Without sector pagination every sector is returned and no metadata is attached
+	resp, err = svc.GetAnalytics(ctx, 1, &start, &end, nil, model.AggregationMonthly, 1, 10, model.AnalyticsOptions{})
+	require.NoError(t, err)
+	assert.Zero(t, gotLimit)
+	assert.Len(t, resp.SectorBreakdown, 3)
+	assert.Nil(t, resp.SectorPagination)
+}
+
+func TestGetAnalytics_Forecast(t *testing.T) {
+	logger := newTestLogger(t)
+	ctx := context.Background()
+
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 6, 0, 0, 0, 0, time.UTC)
+
+	// A clear upward trend of 10mm per day
+	series := []repository.AnalyticsAggregation{
+		{Period: "2024-03-01", TotalRealAmount: 10},
+		{Period: "2024-03-02", TotalRealAmount: 20},
+		{Period: "2024-03-03", TotalRealAmount: 30},
+		{Period: "2024-03-04", TotalRealAmount: 40},
+		{Period: "2024-03-05", TotalRealAmount: 50},
+		{Period: "2024-03-06", TotalRealAmount: 60},
+	}
+
+	var calls []int
+	repo := &mockAnalyticsRepo{
+		getAnalyticsFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation, limit, offset int) ([]repository.AnalyticsAggregation, int64, error) {
+			calls = append(calls, limit)
+			if offset+limit > len(series) {
+				return series[offset:], int64(len(series)), nil
+			}
+			return series[offset : offset+limit], int64(len(series)), nil
+		},
+		getYoYFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) (map[int]repository.YoYAnalyticsData, error) {
+			return nil, nil
+		},
+		getSectorFn: func(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time, limit, offset int) ([]repository.SectorAnalyticsData, int64, error) {
+			return nil, 0, nil
+		},
+	}
+	svc := NewIrrigationAnalyticsService(repo, logger, newTestAnalyticsConfig())
+
+	// A page smaller than the period still forecasts over every bucket
+	resp, err := svc.GetAnalytics(ctx, 1, &start, &end, nil, model.AggregationDaily, 1, 2, model.AnalyticsOptions{Forecast: true})
+	require.NoError(t, err)
+	assert.Equal(t, []int{2, 6}, calls)
+	assert.Len(t, resp.TimeSeries.Data, 2)
+	require.NotNil(t, resp.Forecast)
+	assert.Empty(t, resp.ForecastNote)
+	assert.Equal(t, "2024-03-07", resp.Forecast.Period)
+	assert.Equal(t, 6, resp.Forecast.DataPoints)
+	assert.InDelta(t, 10.0, resp.Forecast.SlopeMMPerBucket, 0.0001)
+	assert.InDelta(t, 70.0, resp.Forecast.ProjectedRealAmountMM, 0.0001)
+	// A perfect fit leaves no residual spread
+	assert.InDelta(t, 70.0, resp.Forecast.LowerBoundMM, 0.0001)
+	assert.InDelta(t, 70.0, resp.Forecast.UpperBoundMM, 0.0001)
+
+	resp, err = svc.GetAnalytics(ctx, 1, &start, &end, nil, model.AggregationDaily, 1, 2, model.AnalyticsOptions{})
+	require.NoError(t, err)
+	assert.Nil(t, resp.Forecast)
+	assert.Empty(t, resp.ForecastNote)
+}
+
+func TestForecastNextBucket(t *testing.T) {
+	keys := []string{"2024-03-04", "2024-03-11", "2024-03-18", "2024-03-25", "2024-04-01", "2024-04-08"}
+
+	// Too few buckets with data
+	forecast, note := forecastNextBucket([]repository.AnalyticsAggregation{
+		{Period: "2024-03-04", TotalRealAmount: 10},
+		{Period: "2024-03-18", TotalRealAmount: 30},
+		{Period: "2024-04-08", TotalRealAmount: 60},
+	}, keys, model.AggregationWeekly)
+	assert.Nil(t, forecast)
+	assert.Equal(t, "forecast needs at least 4 buckets with data; got 3", note)
+
+	// Noisy upward trend with an empty bucket: the gap keeps its position on the x axis
+	forecast, note = forecastNextBucket([]repository.AnalyticsAggregation{
+		{Period: "2024-03-04", TotalRealAmount: 12},
+		{Period: "2024-03-11", TotalRealAmount: 18},
+		{Period: "2024-03-25", TotalRealAmount: 41},
+		{Period: "2024-04-01", TotalRealAmount: 49},
+		{Period: "2024-04-08", TotalRealAmount: 58},
+	}, keys, model.AggregationWeekly)
+	require.NotNil(t, forecast)
+	assert.Empty(t, note)
+	assert.Equal(t, "2024-04-15", forecast.Period)
+	assert.Equal(t, 5, forecast.DataPoints)
+	assert.Greater(t, forecast.SlopeMMPerBucket, 8.0)
+	assert.Greater(t, forecast.ProjectedRealAmountMM, 58.0)
+	assert.Less(t, forecast.LowerBoundMM, forecast.ProjectedRealAmountMM)
+	assert.Greater(t, forecast.UpperBoundMM, forecast.ProjectedRealAmountMM)
+}
+
+func TestDownsampleSeries(t *testing.T) {
+	series := []*float64{floatPtr(0.9), floatPtr(0.7), nil, nil, floatPtr(0.6), nil}
+
+	assert.Equal(t, series, downsampleSeries(series, 10))
+	assert.Equal(t, series, downsampleSeries(series, 0))
+
+	reduced := downsampleSeries(series, 3)
+	require.Len(t, reduced, 3)
+	assert.InDelta(t, 0.8, *reduced[0], 0.0001)
+	assert.Nil(t, reduced[1])
+	assert.InDelta(t, 0.6, *reduced[2], 0.0001)
+}
+
+func TestGetAnalytics_ResponseTooLarge(t *testing.T) {
+	logger := newTestLogger(t)
+	ctx := context.Background()
+
+	queried := false
+	repo := &mockAnalyticsRepo{
+		getAnalyticsFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation, limit, offset int) ([]repository.AnalyticsAggregation, int64, error) {
+			queried = true
+			return nil, 0, nil
+		},
+		countActiveFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time) (int, error) {
+			return 40, nil
+		},
+	}
+
+	cfg := newTestAnalyticsConfig()
+	cfg.MaxResponseBytes = 5 * 1024 * 1024
+	svc := NewIrrigationAnalyticsService(repo, logger, cfg)
+
+	// Ten years of daily buckets for 40 sectors, stacked by sector, with limit=all (ANALYTICS_MAX_BUCKETS)
+	start := time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC)
+	opts := model.AnalyticsOptions{UnboundedLimit: true, IncludeStackedTimeSeries: true}
+	_, err := svc.GetAnalytics(ctx, 1, &start, &end, nil, model.AggregationDaily, 1, 10000, opts)
+
+	var tooLargeErr *ResponseTooLargeError
+	require.ErrorAs(t, err, &tooLargeErr)
+	timeSeries := 3652 * estimatedTimeSeriesEntryBytes
+	sectors := 40 * (estimatedSectorEntryBytes + 30*estimatedSparklinePointBytes)
+	stacked := 3652 * (estimatedStackedEntryBytes + 40*estimatedStackedSectorBytes)
+	assert.Equal(t, timeSeries+sectors+stacked, tooLargeErr.EstimatedBytes)
+	assert.Equal(t, cfg.MaxResponseBytes, tooLargeErr.LimitBytes)
+	assert.Contains(t, err.Error(), "coarser aggregation")
+	assert.False(t, queried)
+
+	all := model.AllAnalyticsFields
+	// Without the stacked time-series every bucket fits
+	require.NoError(t, svc.checkResponseSize(start, end, model.AggregationDaily, 10000, 40, all, model.AnalyticsOptions{}))
+	// So do monthly stacked buckets over the same range
+	require.NoError(t, svc.checkResponseSize(start, end, model.AggregationMonthly, 10000, 40, all, opts))
+	// A farm with thousands of sectors outgrows the limit through the sector breakdown alone
+	require.Error(t, svc.checkResponseSize(start, end, model.AggregationDaily, 50, 10000, all, model.AnalyticsOptions{}))
+	// Unless it is paged
+	require.NoError(t, svc.checkResponseSize(start, end, model.AggregationDaily, 50, 10000, all, model.AnalyticsOptions{SectorLimit: 100, SectorPage: 1}))
+}
+
+func TestGetEfficiencyHeatmap_NullCells(t *testing.T) {
+	logger := newTestLogger(t)
+	ctx := context.Background()
+
+	repo := &mockAnalyticsRepo{
+		getSectorTSFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) ([]repository.SectorTimeSeriesData, error) {
+			return []repository.SectorTimeSeriesData{
+				{SectorID: 1, SectorName: "S1", Period: "2024-03-01", AvgEfficiency: floatPtr(0.9)},
+				{SectorID: 1, SectorName: "S1", Period: "2024-03-03", AvgEfficiency: floatPtr(0.7)},
+				{SectorID: 2, SectorName: "S2", Period: "2024-03-02", AvgEfficiency: floatPtr(0.8)},
+			}, nil
+		},
+	}
+
+	svc := NewIrrigationAnalyticsService(repo, logger, newTestAnalyticsConfig())
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)
+	heatmap, err := svc.GetEfficiencyHeatmap(ctx, 1, &start, &end, model.AggregationDaily)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"2024-03-01", "2024-03-02", "2024-03-03"}, heatmap.Buckets)
+	require.Len(t, heatmap.Rows, 2)
+
+	s1 := heatmap.Rows[0]
+	assert.Equal(t, uint(1), s1.SectorID)
+	require.Len(t, s1.Cells, 3)
+	assert.InDelta(t, 0.9, *s1.Cells[0], 0.0001)
+	assert.Nil(t, s1.Cells[1])
+	assert.InDelta(t, 0.7, *s1.Cells[2], 0.0001)
+
+	s2 := heatmap.Rows[1]
+	assert.Nil(t, s2.Cells[0])
+	assert.InDelta(t, 0.8, *s2.Cells[1], 0.0001)
+	assert.Nil(t, s2.Cells[2])
+}
+
+func TestGetEfficiencyHeatmap_DeficitSpike(t *testing.T) {
+	repo := &mockAnalyticsRepo{
+		getSectorTSFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) ([]repository.SectorTimeSeriesData, error) {
+			return []repository.SectorTimeSeriesData{
+				{SectorID: 1, SectorName: "S1", Period: "2024-03-04", TotalNominalAmount: 40, TotalRealAmount: 38, AvgEfficiency: floatPtr(0.95)},
+				{SectorID: 1, SectorName: "S1", Period: "2024-03-11", TotalNominalAmount: 40, TotalRealAmount: 10, AvgEfficiency: floatPtr(0.25)},
+				{SectorID: 1, SectorName: "S1", Period: "2024-03-18", TotalNominalAmount: 40, TotalRealAmount: 44, AvgEfficiency: floatPtr(1.1)},
+				{SectorID: 2, SectorName: "S2", Period: "2024-03-11", TotalNominalAmount: 20, TotalRealAmount: 20, AvgEfficiency: floatPtr(1)},
+			}, nil
+		},
+	}
+	svc := NewIrrigationAnalyticsService(repo, newTestLogger(t), newTestAnalyticsConfig())
+
+	start := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 24, 0, 0, 0, 0, time.UTC)
+	heatmap, err := svc.GetEfficiencyHeatmap(context.Background(), 1, &start, &end, model.AggregationWeekly)
+	require.NoError(t, err)
+	require.Equal(t, []string{"2024-03-04", "2024-03-11", "2024-03-18"}, heatmap.Buckets)
+	require.Len(t, heatmap.Rows, 2)
+
+	// The spike sits in the second week of sector 1; over-irrigation shows as a negative deficit
+	s1 := heatmap.Rows[0].DeficitMM
+	require.Len(t, s1, 3)
+	assert.InDelta(t, 2.0, *s1[0], 0.0001)
+	assert.InDelta(t, 30.0, *s1[1], 0.0001)
+	assert.InDelta(t, -4.0, *s1[2], 0.0001)
+
+	s2 := heatmap.Rows[1].DeficitMM
+	assert.Nil(t, s2[0])
+	assert.InDelta(t, 0.0, *s2[1], 0.0001)
+	assert.Nil(t, s2[2])
+}
+
+func TestCalculatePercentageChanges_DropToZero(t *testing.T) {
+	svc := NewIrrigationAnalyticsService(&mockAnalyticsRepo{}, newTestLogger(t), newTestAnalyticsConfig())
+	prevEfficiency := 0.8
+	zeroEfficiency := 0.0
+
+	current := model.AnalyticsMetrics{TotalIrrigationVolumeMM: 0, TotalIrrigationEvents: 0, AverageEfficiency: &zeroEfficiency}
+	comparison := svc.calculatePercentageChanges(current, 120, 6, &prevEfficiency, model.EfficiencyBasisAverage)
+
+	require.NotNil(t, comparison.VolumeChangePercent)
+	assert.Equal(t, -100.0, *comparison.VolumeChangePercent)
+	require.NotNil(t, comparison.EventsChangePercent)
+	assert.Equal(t, -100.0, *comparison.EventsChangePercent)
+	require.NotNil(t, comparison.EfficiencyChangePercent)
+	assert.Equal(t, -100.0, *comparison.EfficiencyChangePercent)
+
+	// No events at all means no efficiency to compare, not an efficiency of zero
+	comparison = svc.calculatePercentageChanges(model.AnalyticsMetrics{}, 120, 6, &prevEfficiency, model.EfficiencyBasisAverage)
+	assert.Equal(t, -100.0, *comparison.VolumeChangePercent)
+	assert.Nil(t, comparison.EfficiencyChangePercent)
+}
+
+func TestCalculatePercentageChanges_RiseFromZero(t *testing.T) {
+	svc := NewIrrigationAnalyticsService(&mockAnalyticsRepo{}, newTestLogger(t), newTestAnalyticsConfig())
+	prevEfficiency := 0.0
+	currentEfficiency := 0.85
+
+	current := model.AnalyticsMetrics{TotalIrrigationVolumeMM: 50, TotalIrrigationEvents: 3, AverageEfficiency: &currentEfficiency}
+	comparison := svc.calculatePercentageChanges(current, 0, 0, &prevEfficiency, model.EfficiencyBasisAverage)
+
+	assert.Nil(t, comparison.VolumeChangePercent)
+	assert.Nil(t, comparison.EventsChangePercent)
+	assert.Nil(t, comparison.EfficiencyChangePercent)
+
+	// Zero against zero is no change in either direction, so it is null as well
+	comparison = svc.calculatePercentageChanges(model.AnalyticsMetrics{}, 0, 0, nil, model.EfficiencyBasisAverage)
+	assert.Nil(t, comparison.VolumeChangePercent)
+	assert.Nil(t, comparison.EventsChangePercent)
+	assert.Nil(t, comparison.EfficiencyChangePercent)
+}
+
+func TestRecommendAggregation(t *testing.T) {
+	svc := NewIrrigationAnalyticsService(&mockAnalyticsRepo{}, newTestLogger(t), newTestAnalyticsConfig())
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		days     int
+		expected model.Aggregation
+	}{
+		{name: "one week", days: 7, expected: model.AggregationDaily},
+		{name: "120 days", days: 120, expected: model.AggregationDaily},
+		{name: "121 days", days: 121, expected: model.AggregationWeekly},
+		{name: "one year", days: 366, expected: model.AggregationWeekly},
+		{name: "two years", days: 731, expected: model.AggregationWeekly},
+		{name: "three years", days: 1096, expected: model.AggregationMonthly},
+		{name: "twenty years", days: 7305, expected: model.AggregationMonthly},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			end := start.AddDate(0, 0, tt.days-1)
+			resp := svc.RecommendAggregation(context.Background(), 1, &start, &end)
+
+			assert.Equal(t, tt.expected, resp.Recommended)
+			require.Len(t, resp.Options, 3)
+			assert.Equal(t, model.AggregationDaily, resp.Options[0].Aggregation)
+			assert.Equal(t, tt.days, resp.Options[0].BucketCount)
+			assert.Equal(t, recommendedMaxBuckets, resp.MaxBuckets)
+		})
+	}
+}
+
+func TestBucketKeys(t *testing.T) {
+	start := time.Date(2024, 2, 28, 0, 0, 0, 0, time.UTC) // Wednesday
+	end := time.Date(2024, 3, 12, 23, 59, 59, 0, time.UTC)
+
+	assert.Equal(t, []string{"2024-02-26", "2024-03-04", "2024-03-11"}, bucketKeys(start, end, model.AggregationWeekly))
+	assert.Equal(t, []string{"2024-02-01", "2024-03-01"}, bucketKeys(start, end, model.AggregationMonthly))
+	assert.Len(t, bucketKeys(start, end, model.AggregationDaily), 14)
+}
+
+func TestResolveDateRange(t *testing.T) {
+	start := time.Date(2024, 3, 1, 15, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 31, 8, 0, 0, 0, time.UTC)
+	dayStart := func(d time.Time) time.Time { return time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, time.UTC) }
+
+	t.Run("both provided", func(t *testing.T) {
+		gotStart, gotEnd := resolveDateRange(&start, &end)
+		assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), gotStart)
+		assert.Equal(t, time.Date(2024, 3, 31, 23, 59, 59, 999999999, time.UTC), gotEnd)
+	})
+
+	t.Run("start only ends now", func(t *testing.T) {
+		before := time.Now().UTC()
+		gotStart, gotEnd := resolveDateRange(&start, nil)
+		assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), gotStart)
+		assert.False(t, gotEnd.Before(before))
+		assert.WithinDuration(t, time.Now().UTC(), gotEnd, time.Minute)
+	})
+
+	t.Run("end only starts 90 days earlier", func(t *testing.T) {
+		gotStart, gotEnd := resolveDateRange(nil, &end)
+		assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), gotStart)
+		assert.Equal(t, time.Date(2024, 3, 31, 23, 59, 59, 999999999, time.UTC), gotEnd)
+	})
+
+	t.Run("both missing covers the last 90 days", func(t *testing.T) {
+		gotStart, gotEnd := resolveDateRange(nil, nil)
+		assert.WithinDuration(t, time.Now().UTC(), gotEnd, time.Minute)
+		assert.Equal(t, dayStart(gotEnd.AddDate(0, 0, -90)), gotStart)
+	})
+}
+
+func TestGetAlerts_SingleDateBound(t *testing.T) {
+	var gotStart, gotEnd time.Time
+	repo := &mockAnalyticsRepo{
+		getSectorFn: func(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time, limit, offset int) ([]repository.SectorAnalyticsData, int64, error) {
+			gotStart, gotEnd = startTime, endTime
+			return nil, 0, nil
+		},
+	}
+	svc := NewIrrigationAnalyticsService(repo, newTestLogger(t), newTestAnalyticsConfig())
+
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	resp, err := svc.GetAlerts(context.Background(), 1, &start, nil)
+	require.NoError(t, err)
+	assert.Equal(t, start, gotStart, "a provided start must not be replaced by the default range")
+	assert.WithinDuration(t, time.Now().UTC(), gotEnd, time.Minute)
+	assert.Equal(t, start, resp.Period.Start)
+
+	end := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+	_, err = svc.GetAlerts(context.Background(), 1, nil, &end)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), gotStart)
+	assert.Equal(t, time.Date(2024, 3, 31, 23, 59, 59, 999999999, time.UTC), gotEnd)
+}
+
+func TestGetAlerts_Triggered(t *testing.T) {
+	logger := newTestLogger(t)
+	ctx := context.Background()
+
+	repo := &mockAnalyticsRepo{
+		getSectorFn: func(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time, limit, offset int) ([]repository.SectorAnalyticsData, int64, error) {
+			return []repository.SectorAnalyticsData{
+				// Slightly below target: warning
+				{SectorID: 1, SectorName: "S1", TargetEfficiency: floatPtr(0.9), AvgEfficiency: floatPtr(0.85), TotalNominalAmount: 100, TotalRealAmount: 85},
+				// Far below target and large deficit: critical on both
+				{SectorID: 2, SectorName: "S2", TargetEfficiency: floatPtr(0.9), AvgEfficiency: floatPtr(0.6), TotalNominalAmount: 300, TotalRealAmount: 180},
+			}, 2, nil
+		},
+	}
+
+	svc := NewIrrigationAnalyticsService(repo, logger, newTestAnalyticsConfig())
+	resp, err := svc.GetAlerts(ctx, 1, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, resp.Alerts, 3)
+
+	assert.Equal(t, uint(1), resp.Alerts[0].SectorID)
+	assert.Equal(t, "efficiency_below_target", resp.Alerts[0].Type)
+	assert.Equal(t, "warning", resp.Alerts[0].Severity)
+
+	assert.Equal(t, uint(2), resp.Alerts[1].SectorID)
+	assert.Equal(t, "efficiency_below_target", resp.Alerts[1].Type)
+	assert.Equal(t, "critical", resp.Alerts[1].Severity)
+
+	assert.Equal(t, "deficit_exceeded", resp.Alerts[2].Type)
+	assert.Equal(t, "critical", resp.Alerts[2].Severity)
+	assert.InDelta(t, 120.0, resp.Alerts[2].DeficitMM, 0.0001)
+}
+
+func TestGetAlerts_NotTriggered(t *testing.T) {
+	logger := newTestLogger(t)
+	ctx := context.Background()
+
+	repo := &mockAnalyticsRepo{
+		getSectorFn: func(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time, limit, offset int) ([]repository.SectorAnalyticsData, int64, error) {
+			return []repository.SectorAnalyticsData{
+				// Meets target with a small deficit
+				{SectorID: 1, SectorName: "S1", TargetEfficiency: floatPtr(0.9), AvgEfficiency: floatPtr(0.95), TotalNominalAmount: 100, TotalRealAmount: 95},
+				// No target configured and deficit under threshold
+				{SectorID: 2, SectorName: "S2", AvgEfficiency: floatPtr(0.5), TotalNominalAmount: 80, TotalRealAmount: 40},
+			}, 1, nil
+		},
+	}
+
+	svc := NewIrrigationAnalyticsService(repo, logger, newTestAnalyticsConfig())
+	resp, err := svc.GetAlerts(ctx, 1, nil, nil)
+	require.NoError(t, err)
+	assert.Empty(t, resp.Alerts)
+	assert.NotNil(t, resp.Alerts)
+}
+
+func TestGetWaterSavings_MixedTargets(t *testing.T) {
+	logger := newTestLogger(t)
+	ctx := context.Background()
+
+	repo := &mockAnalyticsRepo{
+		getSectorFn: func(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time, limit, offset int) ([]repository.SectorAnalyticsData, int64, error) {
+			return []repository.SectorAnalyticsData{
+				// Below target: 100 * (1 - 0.6/0.8) = 25 saved
+				{SectorID: 1, SectorName: "S1", TargetEfficiency: floatPtr(0.8), AvgEfficiency: floatPtr(0.6), TotalNominalAmount: 160, TotalRealAmount: 100},
+				// Above target: nothing to save
+				{SectorID: 2, SectorName: "S2", TargetEfficiency: floatPtr(0.7), AvgEfficiency: floatPtr(0.9), TotalNominalAmount: 50, TotalRealAmount: 45},
+				// No target configured: excluded
+				{SectorID: 3, SectorName: "S3", AvgEfficiency: floatPtr(0.5), TotalNominalAmount: 80, TotalRealAmount: 40},
+				// Target but no valid efficiency data: excluded
+				{SectorID: 4, SectorName: "S4", TargetEfficiency: floatPtr(0.9), TotalNominalAmount: 0, TotalRealAmount: 30},
+				// Far below target: 200 * (1 - 0.45/0.9) = 100 saved
+				{SectorID: 5, SectorName: "S5", TargetEfficiency: floatPtr(0.9), AvgEfficiency: floatPtr(0.45), TotalNominalAmount: 400, TotalRealAmount: 200},
+			}, 5, nil
+		},
+	}
+
+	svc := NewIrrigationAnalyticsService(repo, logger, newTestAnalyticsConfig())
+	resp, err := svc.GetWaterSavings(ctx, 1, nil, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, uint(1), resp.FarmID)
+	assert.InDelta(t, 125.0, resp.PotentialSavingsMM, 0.0001)
+	assert.Equal(t, 2, resp.ExcludedSectors)
+	require.Len(t, resp.Sectors, 5)
+
+	require.NotNil(t, resp.Sectors[0].PotentialSavingsMM)
+	assert.InDelta(t, 25.0, *resp.Sectors[0].PotentialSavingsMM, 0.0001)
+	require.NotNil(t, resp.Sectors[1].PotentialSavingsMM)
+	assert.Equal(t, 0.0, *resp.Sectors[1].PotentialSavingsMM)
+	assert.Empty(t, resp.Sectors[1].Note)
+
+	assert.Nil(t, resp.Sectors[2].PotentialSavingsMM)
+	assert.Equal(t, "no target efficiency configured", resp.Sectors[2].Note)
+	assert.Nil(t, resp.Sectors[3].PotentialSavingsMM)
+	assert.Equal(t, "no valid efficiency data in the period", resp.Sectors[3].Note)
+
+	require.NotNil(t, resp.Sectors[4].PotentialSavingsMM)
+	assert.InDelta(t, 100.0, *resp.Sectors[4].PotentialSavingsMM, 0.0001)
+}
+
+func TestGetWaterSavings_NoTargets(t *testing.T) {
+	repo := &mockAnalyticsRepo{
+		getSectorFn: func(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time, limit, offset int) ([]repository.SectorAnalyticsData, int64, error) {
+			return []repository.SectorAnalyticsData{
+				{SectorID: 1, SectorName: "S1", AvgEfficiency: floatPtr(0.6), TotalNominalAmount: 100, TotalRealAmount: 60},
+			}, 1, nil
+		},
+	}
+
+	svc := NewIrrigationAnalyticsService(repo, newTestLogger(t), newTestAnalyticsConfig())
+	resp, err := svc.GetWaterSavings(context.Background(), 1, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, resp.PotentialSavingsMM)
+	assert.Equal(t, 1, resp.ExcludedSectors)
+	require.Len(t, resp.Sectors, 1)
+	assert.Nil(t, resp.Sectors[0].PotentialSavingsMM)
+}
+
+func floatPtr(v float64) *float64 { return &v }
+
+func uintPtr(v uint) *uint { return &v }
+
+func TestGetScheduleAdherence(t *testing.T) {
+	unix := func(tm time.Time) *float64 {
+		v := float64(tm.Unix())
+		return &v
+	}
+	days := func(n float64) *float64 {
+		v := n * 24 * 60 * 60
+		return &v
+	}
+
+	repo := &mockAnalyticsRepo{
+		getScheduleFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time) ([]repository.SectorScheduleData, error) {
+			return []repository.SectorScheduleData{
+				// Watered every 2 days from March 1 to March 9
+				{SectorID: 1, SectorName: "On schedule", ExpectedFrequencyDays: 3, EventCount: 5,
+					FirstStartUnix: unix(time.Date(2024, 3, 1, 6, 0, 0, 0, time.UTC)), LastStartUnix: unix(time.Date(2024, 3, 9, 6, 0, 0, 0, time.UTC)), MaxGapSeconds: days(2)},
+				// Skipped a week between March 2 and March 9
+				{SectorID: 2, SectorName: "Skipped", ExpectedFrequencyDays: 3, EventCount: 2,
+					FirstStartUnix: unix(time.Date(2024, 3, 2, 6, 0, 0, 0, time.UTC)), LastStartUnix: unix(time.Date(2024, 3, 9, 6, 0, 0, 0, time.UTC)), MaxGapSeconds: days(7)},
+				// Never watered in the range
+				{SectorID: 3, SectorName: "Dry", ExpectedFrequencyDays: 5},
+			}, nil
+		},
+	}
+	svc := NewIrrigationAnalyticsService(repo, newTestLogger(t), newTestAnalyticsConfig())
+
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	resp, err := svc.GetScheduleAdherence(context.Background(), 1, &start, &end)
+	require.NoError(t, err)
+	require.Len(t, resp.Sectors, 3)
+	assert.Equal(t, 2, resp.MissedCount)
+
+	assert.False(t, resp.Sectors[0].MissedSchedule)
+	assert.InDelta(t, 2, resp.Sectors[0].LongestGapDays, 0.01)
+
+	assert.True(t, resp.Sectors[1].MissedSchedule)
+	assert.InDelta(t, 7, resp.Sectors[1].LongestGapDays, 0.01)
+
+	// The whole range (March 1 through the end of March 10) counts as one gap
+	assert.True(t, resp.Sectors[2].MissedSchedule)
+	assert.InDelta(t, 10, resp.Sectors[2].LongestGapDays, 0.01)
+
+	// A range running past the service clock is cut at now
+	svc.now = func() time.Time { return time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC) }
+	resp, err = svc.GetScheduleAdherence(context.Background(), 1, &start, &end)
+	require.NoError(t, err)
+	assert.InDelta(t, 4.5, resp.Sectors[2].LongestGapDays, 0.01)
+}
+
+func TestLongestScheduleGap_Edges(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	only := float64(time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC).Unix())
+
+	// A single event leaves the trailing stretch to the range end as the longest gap
+	gap := longestScheduleGap(repository.SectorScheduleData{EventCount: 1, FirstStartUnix: &only, LastStartUnix: &only}, start, end)
+	assert.Equal(t, 8*24*time.Hour, gap)
+}
+
+func TestGetAnalytics_PrevWindow(t *testing.T) {
+	ctx := context.Background()
+	eff := func(v float64) *float64 { return &v }
+
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	firstEvent := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	var prevStart, prevEnd time.Time
+	repo := &mockAnalyticsRepo{
+		getAnalyticsFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation, limit, offset int) ([]repository.AnalyticsAggregation, int64, error) {
+			if startTime.Before(start) {
+				// The preceding window: 80mm over 8 events
+				prevStart, prevEnd = startTime, endTime
+				return []repository.AnalyticsAggregation{
+					{Period: "2024-02-20", TotalRealAmount: 80, EventCount: 8, AvgEfficiency: eff(0.8), MinEfficiency: eff(0.8), MaxEfficiency: eff(0.8)},
+				}, 8, nil
+			}
+			return []repository.AnalyticsAggregation{
+				{Period: "2024-03-05", TotalRealAmount: 100, EventCount: 10, AvgEfficiency: eff(0.9), MinEfficiency: eff(0.9), MaxEfficiency: eff(0.9)},
+			}, 10, nil
+		},
+		getYoYFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) (map[int]repository.YoYAnalyticsData, error) {
+			return nil, nil
+		},
+		getSectorFn: func(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time, limit, offset int) ([]repository.SectorAnalyticsData, int64, error) {
+			return nil, 0, nil
+		},
+		firstEventFn: func(ctx context.Context, farmID uint) (*time.Time, error) {
+			return &firstEvent, nil
+		},
+	}
+
+	svc := NewIrrigationAnalyticsService(repo, newTestLogger(t), newTestAnalyticsConfig())
+	resp, err := svc.GetAnalytics(ctx, 1, &start, &end, nil, model.AggregationDaily, 1, 50, model.AnalyticsOptions{Compare: model.ComparisonPrevWindow})
+	require.NoError(t, err)
+
+	// March 1-10 is compared with the 10 days before it, February 20-29
+	assert.Equal(t, time.Date(2024, 2, 20, 0, 0, 0, 0, time.UTC), prevStart)
+	assert.Equal(t, time.Date(2024, 2, 29, 23, 59, 59, 999999999, time.UTC), prevEnd)
+
+	require.NotNil(t, resp.PrevWindow)
+	assert.False(t, resp.PrevWindow.DataIncomplete)
+	assert.Equal(t, prevStart, resp.PrevWindow.Period.Start)
+	require.NotNil(t, resp.PrevWindow.TotalIrrigationVolumeMM)
+	assert.InDelta(t, 80, *resp.PrevWindow.TotalIrrigationVolumeMM, 0.001)
+
+	vs := resp.PeriodComparison.VsPrevWindow
+	require.NotNil(t, vs)
+	assert.InDelta(t, 25, *vs.VolumeChangePercent, 0.001)
+	assert.InDelta(t, 25, *vs.EventsChangePercent, 0.001)
+	assert.InDelta(t, 12.5, *vs.EfficiencyChangePercent, 0.001)
+}
+
+func TestGetAnalytics_PrevWindowAcrossPages(t *testing.T) {
+	ctx := context.Background()
+	eff := func(v float64) *float64 { return &v }
+
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 4, 23, 59, 59, 0, time.UTC)
+	firstEvent := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	// Four daily buckets of 20mm each; the preceding window holds 80mm as well
+	current := []repository.AnalyticsAggregation{
+		{Period: "2024-03-01", TotalRealAmount: 20, EventCount: 2, AvgEfficiency: eff(0.8)},
+		{Period: "2024-03-02", TotalRealAmount: 20, EventCount: 2, AvgEfficiency: eff(0.8)},
+		{Period: "2024-03-03", TotalRealAmount: 20, EventCount: 2, AvgEfficiency: eff(0.8)},
+		{Period: "2024-03-04", TotalRealAmount: 20, EventCount: 2, AvgEfficiency: eff(0.8)},
+	}
+	repo := &mockAnalyticsRepo{
+		getAnalyticsFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation, limit, offset int) ([]repository.AnalyticsAggregation, int64, error) {
+			if startTime.Before(start) {
+				return []repository.AnalyticsAggregation{
+					{Period: "2024-02-26", TotalRealAmount: 80, EventCount: 8, AvgEfficiency: eff(0.8)},
+				}, 1, nil
+			}
+			from := min(offset, len(current))
+			return current[from:min(from+limit, len(current))], int64(len(current)), nil
+		},
+		getYoYFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) (map[int]repository.YoYAnalyticsData, error) {
+			return nil, nil
+		},
+		getSectorFn: func(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time, limit, offset int) ([]repository.SectorAnalyticsData, int64, error) {
+			return nil, 0, nil
+		},
+		firstEventFn: func(ctx context.Context, farmID uint) (*time.Time, error) {
+			return &firstEvent, nil
+		},
+	}
+	svc := NewIrrigationAnalyticsService(repo, newTestLogger(t), newTestAnalyticsConfig())
+
+	// Each page holds two of the four buckets, yet the whole period is compared with the whole window
+	for _, page := range []int{1, 2} {
+		resp, err := svc.GetAnalytics(ctx, 1, &start, &end, nil, model.AggregationDaily, page, 2, model.AnalyticsOptions{Compare: model.ComparisonPrevWindow})
+		require.NoError(t, err)
+		require.Len(t, resp.TimeSeries.Data, 2)
+
+		vs := resp.PeriodComparison.VsPrevWindow
+		require.NotNil(t, vs)
+		assert.InDelta(t, 0, *vs.VolumeChangePercent, 0.001, "page %d", page)
+		assert.InDelta(t, 0, *vs.EventsChangePercent, 0.001, "page %d", page)
+	}
+}
+
+func TestGetAnalytics_EfficiencyBasis(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 31, 23, 59, 59, 0, time.UTC)
+	currentYear := time.Now().Year()
+
+	// The small, efficient bucket lifts the average well above the weighted efficiency of 100/190
+	repo := &mockAnalyticsRepo{
+		getAnalyticsFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation, limit, offset int) ([]repository.AnalyticsAggregation, int64, error) {
+			return []repository.AnalyticsAggregation{
+				{Period: "2024-03-01", TotalRealAmount: 10, TotalNominalAmount: 10, EventCount: 1, AvgEfficiency: floatPtr(1.0)},
+				{Period: "2024-03-02", TotalRealAmount: 90, TotalNominalAmount: 180, EventCount: 3, AvgEfficiency: floatPtr(0.5)},
+			}, 2, nil
+		},
+		getYoYFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) (map[int]repository.YoYAnalyticsData, error) {
+			return map[int]repository.YoYAnalyticsData{
+				currentYear - 1: {Year: currentYear - 1, TotalRealAmount: 60, TotalNominalAmount: 80, EventCount: 4, AvgEfficiency: floatPtr(0.6)},
+				currentYear - 2: {Year: currentYear - 2, TotalRealAmount: 50, TotalNominalAmount: 50, EventCount: 4, AvgEfficiency: floatPtr(0.8)},
+			}, nil
+		},
+		getSectorFn: func(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time, limit, offset int) ([]repository.SectorAnalyticsData, int64, error) {
+			return nil, 0, nil
+		},
+	}
+	svc := NewIrrigationAnalyticsService(repo, newTestLogger(t), newTestAnalyticsConfig())
+
+	tests := []struct {
+		name     string
+		basis    model.EfficiencyBasis
+		change1Y float64
+		change2Y float64
+	}{
+		// Average: 0.75 vs 0.6 and 0.8
+		{name: "default is average", basis: "", change1Y: 25, change2Y: -6.25},
+		{name: "average", basis: model.EfficiencyBasisAverage, change1Y: 25, change2Y: -6.25},
+		// Weighted: 100/190 vs 60/80 and 50/50
+		{name: "weighted", basis: model.EfficiencyBasisWeighted, change1Y: (100.0/190/0.75 - 1) * 100, change2Y: (100.0/190 - 1) * 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := svc.GetAnalytics(context.Background(), 1, &start, &end, nil, model.AggregationDaily, 1, 10, model.AnalyticsOptions{EfficiencyBasis: tt.basis})
+			require.NoError(t, err)
+
+			require.NotNil(t, resp.Metrics.AverageEfficiency)
+			assert.InDelta(t, 0.75, *resp.Metrics.AverageEfficiency, 0.0001)
+			require.NotNil(t, resp.Metrics.WeightedEfficiency)
+			assert.InDelta(t, 100.0/190, *resp.Metrics.WeightedEfficiency, 0.0001)
+			require.NotNil(t, resp.SamePeriod1Y.WeightedEfficiency)
+			assert.InDelta(t, 0.75, *resp.SamePeriod1Y.WeightedEfficiency, 0.0001)
+
+			vs1Y, vs2Y := resp.PeriodComparison.VsPeriod1Y, resp.PeriodComparison.VsPeriod2Y
+			require.NotNil(t, vs1Y)
+			require.NotNil(t, vs2Y)
+			assert.InDelta(t, tt.change1Y, *vs1Y.EfficiencyChangePercent, 0.0001)
+			assert.InDelta(t, tt.change2Y, *vs2Y.EfficiencyChangePercent, 0.0001)
+			// Only the efficiency change depends on the basis
+			assert.InDelta(t, (100.0/60-1)*100, *vs1Y.VolumeChangePercent, 0.0001)
+		})
+	}
+}
+
+func TestGetAnalytics_PrevWindowNotEnoughHistory(t *testing.T) {
+	ctx := context.Background()
+
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	firstEvent := time.Date(2024, 2, 25, 0, 0, 0, 0, time.UTC)
+
+	calls := 0
+	repo := &mockAnalyticsRepo{
+		getAnalyticsFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation, limit, offset int) ([]repository.AnalyticsAggregation, int64, error) {
+			calls++
+			return []repository.AnalyticsAggregation{{Period: "2024-03-05", TotalRealAmount: 100, EventCount: 10}}, 10, nil
+		},
+		getYoYFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) (map[int]repository.YoYAnalyticsData, error) {
+			return nil, nil
+		},
+		getSectorFn: func(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time, limit, offset int) ([]repository.SectorAnalyticsData, int64, error) {
+			return nil, 0, nil
+		},
+		firstEventFn: func(ctx context.Context, farmID uint) (*time.Time, error) {
+			return &firstEvent, nil
+		},
+	}
+
+	svc := NewIrrigationAnalyticsService(repo, newTestLogger(t), newTestAnalyticsConfig())
+	resp, err := svc.GetAnalytics(ctx, 1, &start, &end, nil, model.AggregationDaily, 1, 50, model.AnalyticsOptions{Compare: model.ComparisonPrevWindow})
+	require.NoError(t, err)
+
+	// History starts inside the previous window, so it is not queried or compared
+	assert.Equal(t, 1, calls)
+	require.NotNil(t, resp.PrevWindow)
+	assert.True(t, resp.PrevWindow.DataIncomplete)
+	assert.Contains(t, resp.PrevWindow.Note, "2024-02-25")
+	assert.Nil(t, resp.PeriodComparison.VsPrevWindow)
+
+	// Without compare=prev_window nothing about the previous window is added
+	resp, err = svc.GetAnalytics(ctx, 1, &start, &end, nil, model.AggregationDaily, 1, 50, model.AnalyticsOptions{})
+	require.NoError(t, err)
+	assert.Nil(t, resp.PrevWindow)
+}
+
+func TestGetAnalytics_DataQuality(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	qualityCalls := 0
+	repo := &mockAnalyticsRepo{
+		getAnalyticsFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation, limit, offset int) ([]repository.AnalyticsAggregation, int64, error) {
+			return nil, 0, nil
+		},
+		getYoYFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) (map[int]repository.YoYAnalyticsData, error) {
+			return map[int]repository.YoYAnalyticsData{}, nil
+		},
+		getSectorFn: func(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time, limit, offset int) ([]repository.SectorAnalyticsData, int64, error) {
+			return nil, 0, nil
+		},
+		qualityFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time) (repository.DataQualityData, error) {
+			qualityCalls++
+			return repository.DataQualityData{
+				EventCount:            20,
+				ZeroNominalCount:      2,
+				OverIrrigationCount:   3,
+				DuplicateSuspectCount: 1,
+				DaysWithData:          8,
+			}, nil
+		},
+	}
+	svc := NewIrrigationAnalyticsService(repo, newTestLogger(t), newTestAnalyticsConfig())
+
+	// Not queried unless asked for
+	resp, err := svc.GetAnalytics(context.Background(), 1, &start, &end, nil, model.AggregationDaily, 1, 10, model.AnalyticsOptions{})
+	require.NoError(t, err)
+	assert.Nil(t, resp.DataQuality)
+	assert.Zero(t, qualityCalls)
+
+	resp, err = svc.GetAnalytics(context.Background(), 1, &start, &end, nil, model.AggregationDaily, 1, 10, model.AnalyticsOptions{IncludeQuality: true})
+	require.NoError(t, err)
+	require.NotNil(t, resp.DataQuality)
+	assert.Equal(t, model.DataQuality{
+		EventCount:             20,
+		ZeroNominalEvents:      2,
+		OverIrrigationEvents:   3,
+		DuplicateSuspectEvents: 1,
+		DaysWithData:           8,
+		DaysInRange:            10,
+		CompletenessPercent:    80,
+	}, *resp.DataQuality)
+
+	// Days after the service clock do not count against completeness
+	svc.now = func() time.Time { return time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC) }
+	futureEnd := time.Date(2024, 3, 30, 0, 0, 0, 0, time.UTC)
+	resp, err = svc.GetAnalytics(context.Background(), 1, &start, &futureEnd, nil, model.AggregationDaily, 1, 10, model.AnalyticsOptions{IncludeQuality: true})
+	require.NoError(t, err)
+	require.NotNil(t, resp.DataQuality)
+	assert.Equal(t, 5, resp.DataQuality.DaysInRange)
+}
+
+func TestGetAnalytics_StackedTimeSeries(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)
+
+	stackedCalls := 0
+	repo := &mockAnalyticsRepo{
+		getAnalyticsFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation, limit, offset int) ([]repository.AnalyticsAggregation, int64, error) {
+			return nil, 0, nil
+		},
+		getYoYFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) (map[int]repository.YoYAnalyticsData, error) {
+			return map[int]repository.YoYAnalyticsData{}, nil
+		},
+		getSectorFn: func(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time, limit, offset int) ([]repository.SectorAnalyticsData, int64, error) {
+			return nil, 0, nil
+		},
+		stackedFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) ([]repository.PeriodSectorData, error) {
+			stackedCalls++
+			return []repository.PeriodSectorData{
+				{Period: "2024-03-01", SectorID: 1, SectorName: "S1", TotalRealAmount: 30, TotalNominalAmount: 35},
+				{Period: "2024-03-01", SectorID: 2, SectorName: "S2", TotalRealAmount: 9, TotalNominalAmount: 12},
+				{Period: "2024-03-02", SectorID: 2, SectorName: "S2", TotalRealAmount: 5, TotalNominalAmount: 10},
+			}, nil
+		},
+	}
+	svc := NewIrrigationAnalyticsService(repo, newTestLogger(t), newTestAnalyticsConfig())
+
+	// Not queried unless asked for
+	resp, err := svc.GetAnalytics(context.Background(), 1, &start, &end, nil, model.AggregationDaily, 1, 10, model.AnalyticsOptions{})
+	require.NoError(t, err)
+	assert.Nil(t, resp.StackedTimeSeries)
+	assert.Zero(t, stackedCalls)
+
+	resp, err = svc.GetAnalytics(context.Background(), 1, &start, &end, nil, model.AggregationDaily, 1, 10, model.AnalyticsOptions{IncludeStackedTimeSeries: true})
+	require.NoError(t, err)
+	assert.Equal(t, []model.StackedTimeSeriesEntry{
+		{Date: "2024-03-01", Sectors: []model.StackedSectorAmounts{
+			{SectorID: 1, SectorName: "S1", NominalAmountMM: 35, RealAmountMM: 30},
+			{SectorID: 2, SectorName: "S2", NominalAmountMM: 12, RealAmountMM: 9},
+		}},
+		{Date: "2024-03-02", Sectors: []model.StackedSectorAmounts{
+			{SectorID: 2, SectorName: "S2", NominalAmountMM: 10, RealAmountMM: 5},
+		}},
+	}, resp.StackedTimeSeries)
+}
+
+func TestGetEfficiencyByAmount(t *testing.T) {
+	var gotBins int
+	repo := &mockAnalyticsRepo{
+		byAmountFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, bins int) ([]repository.AmountBinData, error) {
+			gotBins = bins
+			return []repository.AmountBinData{
+				{Bin: 1, MinRealAmount: 10, MaxRealAmount: 55, EventCount: 3, AvgRealAmount: 20, AvgEfficiency: floatPtr(0.9)},
+				{Bin: 2, MinRealAmount: 55, MaxRealAmount: 100},
+			}, nil
+		},
+	}
+	svc := NewIrrigationAnalyticsService(repo, newTestLogger(t), newTestAnalyticsConfig())
+
+	resp, err := svc.GetEfficiencyByAmount(context.Background(), 1, nil, nil, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 2, gotBins)
+	assert.Equal(t, []model.EfficiencyAmountBin{
+		{Bin: 1, MinRealAmountMM: 10, MaxRealAmountMM: 55, EventCount: 3, AvgRealAmountMM: 20, AverageEfficiency: floatPtr(0.9)},
+		{Bin: 2, MinRealAmountMM: 55, MaxRealAmountMM: 100},
+	}, resp.Bins)
+}
+
+func TestGetDayOfWeekDistribution(t *testing.T) {
+	repo := &mockAnalyticsRepo{
+		dowFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time) ([]repository.DayOfWeekData, error) {
+			days := make([]repository.DayOfWeekData, 7)
+			for day := range days {
+				days[day].DayOfWeek = day
+			}
+			days[1] = repository.DayOfWeekData{DayOfWeek: 1, TotalRealAmount: 18, TotalNominalAmount: 20, EventCount: 2}
+			return days, nil
+		},
+	}
+	svc := NewIrrigationAnalyticsService(repo, newTestLogger(t), newTestAnalyticsConfig())
+
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+	resp, err := svc.GetDayOfWeekDistribution(context.Background(), 1, &start, &end)
+	require.NoError(t, err)
+	require.Len(t, resp.Days, 7)
+	assert.Equal(t, "Sunday", resp.Days[0].Day)
+	assert.Equal(t, model.DayOfWeekTotal{DayOfWeek: 1, Day: "Monday", RealAmountMM: 18, NominalAmountMM: 20, EventCount: 2}, resp.Days[1])
+	assert.Equal(t, "Saturday", resp.Days[6].Day)
+	assert.Zero(t, resp.Days[6].EventCount)
+}
+
+func TestGetSectorRanking_RanksAcrossPages(t *testing.T) {
+	var gotLimit, gotOffset int
+	repo := &mockAnalyticsRepo{
+		rankingFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, limit, offset int) ([]repository.SectorAnalyticsData, int64, error) {
+			gotLimit, gotOffset = limit, offset
+			return []repository.SectorAnalyticsData{
+				{SectorID: 7, SectorName: "North", AvgEfficiency: floatPtr(0.9), TotalRealAmount: 18, TotalNominalAmount: 20, EventCount: 2},
+				{SectorID: 3, SectorName: "South", TotalRealAmount: 5, EventCount: 1},
+			}, 5, nil
+		},
+	}
+	svc := NewIrrigationAnalyticsService(repo, newTestLogger(t), newTestAnalyticsConfig())
+
+	resp, err := svc.GetSectorRanking(context.Background(), 1, nil, nil, 2, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 2, gotLimit)
+	assert.Equal(t, 2, gotOffset)
+	require.Len(t, resp.Sectors, 2)
+	assert.Equal(t, 3, resp.Sectors[0].Rank)
+	assert.Equal(t, uint(7), resp.Sectors[0].SectorID)
+	assert.Equal(t, 4, resp.Sectors[1].Rank)
+	assert.Nil(t, resp.Sectors[1].AverageEfficiency)
+	assert.Equal(t, model.PaginationMetadata{Page: 2, Limit: 2, TotalCount: 5, TotalPages: 3}, resp.Pagination)
+}
+
+func TestGetAnalytics_TruncatedTimeSeries(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+
+	repo := &mockAnalyticsRepo{
+		getAnalyticsFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation, limit, offset int) ([]repository.AnalyticsAggregation, int64, error) {
+			return []repository.AnalyticsAggregation{{Period: "2024-03-01", TotalRealAmount: 10, TotalNominalAmount: 12, EventCount: 1}}, 31, nil
+		},
+		getYoYFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) (map[int]repository.YoYAnalyticsData, error) {
+			return map[int]repository.YoYAnalyticsData{}, nil
+		},
+		getSectorFn: func(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time, limit, offset int) ([]repository.SectorAnalyticsData, int64, error) {
+			return nil, 0, nil
+		},
+	}
+	svc := NewIrrigationAnalyticsService(repo, newTestLogger(t), newTestAnalyticsConfig())
+
+	resp, err := svc.GetAnalytics(context.Background(), 1, &start, &end, nil, model.AggregationDaily, 1, 50, model.AnalyticsOptions{})
+	require.NoError(t, err)
+	assert.False(t, resp.TimeSeries.Truncated)
+
+	repo.truncated = true
+	resp, err = svc.GetAnalytics(context.Background(), 1, &start, &end, nil, model.AggregationDaily, 1, 50, model.AnalyticsOptions{})
+	require.NoError(t, err)
+	assert.True(t, resp.TimeSeries.Truncated)
+	assert.Len(t, resp.TimeSeries.Data, 1)
+}
+
+func TestGetAnalytics_UnboundedLimitWarning(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)
+
+	repo := &mockAnalyticsRepo{
+		getAnalyticsFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation, limit, offset int) ([]repository.AnalyticsAggregation, int64, error) {
+			return []repository.AnalyticsAggregation{
+				{Period: "2024-03-01", TotalRealAmount: 10, TotalNominalAmount: 12, EventCount: 1},
+				{Period: "2024-03-02", TotalRealAmount: 8, TotalNominalAmount: 10, EventCount: 1},
+			}, 2, nil
+		},
+		getYoYFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) (map[int]repository.YoYAnalyticsData, error) {
+			return map[int]repository.YoYAnalyticsData{}, nil
+		},
+		getSectorFn: func(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time, limit, offset int) ([]repository.SectorAnalyticsData, int64, error) {
+			return nil, 0, nil
+		},
+	}
+	cfg := newTestAnalyticsConfig()
+	cfg.WarnUnboundedLimit = true
+	svc := NewIrrigationAnalyticsService(repo, newTestLogger(t), cfg)
+
+	resp, err := svc.GetAnalytics(context.Background(), 1, &start, &end, nil, model.AggregationDaily, 1, 10000, model.AnalyticsOptions{UnboundedLimit: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"unbounded limit requested; 2 buckets returned"}, resp.Warnings)
+
+	// A regular page size never warns
+	resp, err = svc.GetAnalytics(context.Background(), 1, &start, &end, nil, model.AggregationDaily, 1, 50, model.AnalyticsOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, resp.Warnings)
+
+	// The warning can be switched off
+	cfg.WarnUnboundedLimit = false
+	resp, err = svc.GetAnalytics(context.Background(), 1, &start, &end, nil, model.AggregationDaily, 1, 10000, model.AnalyticsOptions{UnboundedLimit: true})
+	require.NoError(t, err)
+	assert.Empty(t, resp.Warnings)
+}
+
+func TestGetAnalytics_UnboundedLimitPagination(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)
+
+	var gotOffset int
+	repo := &mockAnalyticsRepo{
+		getAnalyticsFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation, limit, offset int) ([]repository.AnalyticsAggregation, int64, error) {
+			gotOffset = offset
+			// The total counts the three buckets, not the nine events in them
+			return []repository.AnalyticsAggregation{
+				{Period: "2024-03-01", TotalRealAmount: 10, TotalNominalAmount: 12, EventCount: 4},
+				{Period: "2024-03-02", TotalRealAmount: 8, TotalNominalAmount: 10, EventCount: 3},
+				{Period: "2024-03-03", TotalRealAmount: 6, TotalNominalAmount: 8, EventCount: 2},
+			}, 3, nil
+		},
+		getYoYFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) (map[int]repository.YoYAnalyticsData, error) {
+			return map[int]repository.YoYAnalyticsData{}, nil
+		},
+		getSectorFn: func(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time, limit, offset int) ([]repository.SectorAnalyticsData, int64, error) {
+			return nil, 0, nil
+		},
+	}
+	svc := NewIrrigationAnalyticsService(repo, newTestLogger(t), newTestAnalyticsConfig())
+
+	// A page past the first still returns the single page limit=all stands for
+	resp, err := svc.GetAnalytics(context.Background(), 1, &start, &end, nil, model.AggregationDaily, 3, math.MaxInt32, model.AnalyticsOptions{UnboundedLimit: true})
+	require.NoError(t, err)
+	assert.Equal(t, 0, gotOffset)
+	assert.Equal(t, model.PaginationMetadata{Page: 1, Limit: 3, TotalCount: 3, TotalPages: 1}, resp.TimeSeries.Pagination)
+	assert.Len(t, resp.TimeSeries.Data, 3)
+	assert.Equal(t, 9, resp.Metrics.TotalIrrigationEvents)
+
+	// A regular page size keeps the usual metadata
+	resp, err = svc.GetAnalytics(context.Background(), 1, &start, &end, nil, model.AggregationDaily, 1, 2, model.AnalyticsOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, model.PaginationMetadata{Page: 1, Limit: 2, TotalCount: 3, TotalPages: 2}, resp.TimeSeries.Pagination)
+}
+
+func TestGetAnalytics_ExcludeToday(t *testing.T) {
+	// Wednesday afternoon; the week started Monday 2024-03-11
+	now := time.Date(2024, 3, 13, 15, 0, 0, 0, time.UTC)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	today := time.Date(2024, 3, 13, 0, 0, 0, 0, time.UTC)
+	lastNanosecond := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 23, 59, 59, 999999999, time.UTC)
+	}
+
+	tests := []struct {
+		name         string
+		aggregation  model.Aggregation
+		end          time.Time
+		excludeToday bool
+		wantEnd      time.Time
+	}{
+		{name: "daily", aggregation: model.AggregationDaily, end: today, excludeToday: true, wantEnd: lastNanosecond(2024, 3, 12)},
+		{name: "weekly", aggregation: model.AggregationWeekly, end: today, excludeToday: true, wantEnd: lastNanosecond(2024, 3, 10)},
+		{name: "monthly", aggregation: model.AggregationMonthly, end: today, excludeToday: true, wantEnd: lastNanosecond(2024, 2, 29)},
+		{name: "flag off", aggregation: model.AggregationDaily, end: today, wantEnd: lastNanosecond(2024, 3, 13)},
+		{name: "range ends before the current bucket", aggregation: model.AggregationMonthly, end: time.Date(2024, 2, 15, 0, 0, 0, 0, time.UTC), excludeToday: true, wantEnd: lastNanosecond(2024, 2, 15)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var queriedEnd time.Time
+			repo := &mockAnalyticsRepo{
+				getAnalyticsFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation, limit, offset int) ([]repository.AnalyticsAggregation, int64, error) {
+					queriedEnd = endTime
+					return nil, 0, nil
+				},
+				getYoYFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) (map[int]repository.YoYAnalyticsData, error) {
+					return map[int]repository.YoYAnalyticsData{}, nil
+				},
+				getSectorFn: func(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time, limit, offset int) ([]repository.SectorAnalyticsData, int64, error) {
+					return nil, 0, nil
+				},
+			}
+			svc := NewIrrigationAnalyticsService(repo, newTestLogger(t), newTestAnalyticsConfig())
+			svc.now = func() time.Time { return now }
+
+			resp, err := svc.GetAnalytics(context.Background(), 1, &start, &tt.end, nil, tt.aggregation, 1, 50, model.AnalyticsOptions{ExcludeToday: tt.excludeToday})
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantEnd, queriedEnd)
+			assert.Equal(t, tt.wantEnd, resp.Period.End)
+		})
+	}
+}
+
+func TestGetAnalytics_WholeDaysOnly(t *testing.T) {
+	now := time.Date(2024, 3, 13, 15, 0, 0, 0, time.UTC)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	today := time.Date(2024, 3, 13, 0, 0, 0, 0, time.UTC)
+
+	var timeSeriesEnd, yoyEnd, sectorEnd time.Time
+	repo := &mockAnalyticsRepo{
+		getAnalyticsFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation, limit, offset int) ([]repository.AnalyticsAggregation, int64, error) {
+			timeSeriesEnd = endTime
+			return nil, 0, nil
+		},
+		getYoYFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) (map[int]repository.YoYAnalyticsData, error) {
+			yoyEnd = endTime
+			return map[int]repository.YoYAnalyticsData{}, nil
+		},
+		getSectorFn: func(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time, limit, offset int) ([]repository.SectorAnalyticsData, int64, error) {
+			sectorEnd = endTime
+			return nil, 0, nil
+		},
+	}
+	svc := NewIrrigationAnalyticsService(repo, newTestLogger(t), newTestAnalyticsConfig())
+	svc.now = func() time.Time { return now }
+
+	// The in-progress day is dropped from every section, even with weekly buckets
+	resp, err := svc.GetAnalytics(context.Background(), 1, &start, &today, nil, model.AggregationWeekly, 1, 50, model.AnalyticsOptions{WholeDaysOnly: true})
+	require.NoError(t, err)
+	wantEnd := time.Date(2024, 3, 12, 23, 59, 59, 999999999, time.UTC)
+	assert.Equal(t, wantEnd, timeSeriesEnd)
+	assert.Equal(t, wantEnd, yoyEnd)
+	assert.Equal(t, wantEnd, sectorEnd)
+	assert.Equal(t, wantEnd, resp.Period.End)
+
+	// A range that ends before today is already made of whole days
+	end := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	_, err = svc.GetAnalytics(context.Background(), 1, &start, &end, nil, model.AggregationWeekly, 1, 50, model.AnalyticsOptions{WholeDaysOnly: true})
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 3, 1, 23, 59, 59, 999999999, time.UTC), timeSeriesEnd)
+}
+
+type stubFarmFinder struct {
+	farms   map[uint]model.Farm
+	err     error
+	lookups int
+}
+
+func (s *stubFarmFinder) FindByID(ctx context.Context, id uint) (*model.Farm, error) {
+	s.lookups++
+	if s.err != nil {
+		return nil, s.err
+	}
+	farm, ok := s.farms[id]
+	if !ok {
+		return nil, fmt.Errorf("failed to find farm by ID: %w", gorm.ErrRecordNotFound)
+	}
+	return &farm, nil
+}
+
+func TestGetAnalytics_HealthyStatus(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)
+	threshold := func(v float64) *float64 { return &v }
+
+	// Weighted efficiency is (10+8)/(12+10) ≈ 0.818
+	newRepo := func() *mockAnalyticsRepo {
+		return &mockAnalyticsRepo{
+			getAnalyticsFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation, limit, offset int) ([]repository.AnalyticsAggregation, int64, error) {
+				return []repository.AnalyticsAggregation{
+					{Period: "2024-03-01", TotalRealAmount: 10, TotalNominalAmount: 12, EventCount: 1},
+					{Period: "2024-03-02", TotalRealAmount: 8, TotalNominalAmount: 10, EventCount: 1},
+				}, 2, nil
+			},
+			getYoYFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) (map[int]repository.YoYAnalyticsData, error) {
+				return map[int]repository.YoYAnalyticsData{}, nil
+			},
+			getSectorFn: func(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time, limit, offset int) ([]repository.SectorAnalyticsData, int64, error) {
+				return nil, 0, nil
+			},
+		}
+	}
+
+	tests := []struct {
+		name          string
+		farmThreshold *float64
+		cfgThreshold  float64
+		wantStatus    model.FarmStatus
+		wantThreshold *float64
+	}{
+		{name: "above farm threshold", farmThreshold: threshold(0.8), wantStatus: model.FarmStatusHealthy, wantThreshold: threshold(0.8)},
+		{name: "below farm threshold", farmThreshold: threshold(0.85), wantStatus: model.FarmStatusNeedsAttention, wantThreshold: threshold(0.85)},
+		{name: "farm threshold overrides config", farmThreshold: threshold(0.8), cfgThreshold: 0.9, wantStatus: model.FarmStatusHealthy, wantThreshold: threshold(0.8)},
+		{name: "config fallback below", cfgThreshold: 0.9, wantStatus: model.FarmStatusNeedsAttention, wantThreshold: threshold(0.9)},
+		{name: "no threshold", wantStatus: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestAnalyticsConfig()
+			cfg.HealthyEfficiency = tt.cfgThreshold
+			farms := &stubFarmFinder{farms: map[uint]model.Farm{1: {ID: 1, Name: "Farm A", HealthyEfficiencyThreshold: tt.farmThreshold}}}
+			svc := NewIrrigationAnalyticsService(newRepo(), newTestLogger(t), cfg).WithFarmFinder(farms)
+
+			resp, err := svc.GetAnalytics(context.Background(), 1, &start, &end, nil, model.AggregationDaily, 1, 50, model.AnalyticsOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantStatus, resp.Status)
+			assert.Equal(t, tt.wantThreshold, resp.HealthyThreshold)
+		})
+	}
+
+	t.Run("unknown farm uses config", func(t *testing.T) {
+		cfg := newTestAnalyticsConfig()
+		cfg.HealthyEfficiency = 0.8
+		svc := NewIrrigationAnalyticsService(newRepo(), newTestLogger(t), cfg).WithFarmFinder(&stubFarmFinder{})
+
+		resp, err := svc.GetAnalytics(context.Background(), 1, &start, &end, nil, model.AggregationDaily, 1, 50, model.AnalyticsOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, model.FarmStatusHealthy, resp.Status)
+	})
+
+	t.Run("lookup error", func(t *testing.T) {
+		errExpected := errors.New("db error")
+		svc := NewIrrigationAnalyticsService(newRepo(), newTestLogger(t), newTestAnalyticsConfig()).WithFarmFinder(&stubFarmFinder{err: errExpected})
+
+		_, err := svc.GetAnalytics(context.Background(), 1, &start, &end, nil, model.AggregationDaily, 1, 50, model.AnalyticsOptions{})
+		require.ErrorIs(t, err, errExpected)
+	})
+
+	t.Run("status covers every page", func(t *testing.T) {
+		// The first bucket alone (≈ 0.833) would meet the threshold; the whole period (≈ 0.818) does not
+		buckets := []repository.AnalyticsAggregation{
+			{Period: "2024-03-01", TotalRealAmount: 10, TotalNominalAmount: 12, EventCount: 1},
+			{Period: "2024-03-02", TotalRealAmount: 8, TotalNominalAmount: 10, EventCount: 1},
+		}
+		repo := newRepo()
+		repo.getAnalyticsFn = func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation, limit, offset int) ([]repository.AnalyticsAggregation, int64, error) {
+			return buckets[offset:min(offset+limit, len(buckets))], int64(len(buckets)), nil
+		}
+		cfg := newTestAnalyticsConfig()
+		cfg.HealthyEfficiency = 0.82
+		svc := NewIrrigationAnalyticsService(repo, newTestLogger(t), cfg)
+
+		for page := 1; page <= 2; page++ {
+			resp, err := svc.GetAnalytics(context.Background(), 1, &start, &end, nil, model.AggregationDaily, page, 1, model.AnalyticsOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, model.FarmStatusNeedsAttention, resp.Status, "page %d", page)
+		}
+	})
+
+	t.Run("farm threshold lookup is reused", func(t *testing.T) {
+		now := time.Date(2024, 3, 13, 15, 0, 0, 0, time.UTC)
+		farms := &stubFarmFinder{farms: map[uint]model.Farm{1: {ID: 1, Name: "Farm A", HealthyEfficiencyThreshold: threshold(0.8)}}}
+		svc := NewIrrigationAnalyticsService(newRepo(), newTestLogger(t), newTestAnalyticsConfig()).WithFarmFinder(farms)
+		svc.now = func() time.Time { return now }
+
+		for range 3 {
+			_, err := svc.GetAnalytics(context.Background(), 1, &start, &end, nil, model.AggregationDaily, 1, 50, model.AnalyticsOptions{})
+			require.NoError(t, err)
+		}
+		assert.Equal(t, 1, farms.lookups)
+
+		now = now.Add(farmThresholdTTL)
+		_, err := svc.GetAnalytics(context.Background(), 1, &start, &end, nil, model.AggregationDaily, 1, 50, model.AnalyticsOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, 2, farms.lookups)
+	})
+}
+
+func TestGetYoYMetrics_Reason(t *testing.T) {
+	efficiency := 0.85
+	svc := NewIrrigationAnalyticsService(&mockAnalyticsRepo{}, newTestLogger(t), newTestAnalyticsConfig())
+
+	tests := []struct {
+		name           string
+		data           map[int]repository.YoYAnalyticsData
+		wantReason     model.YoYReason
+		wantIncomplete bool
+		wantNote       string
+	}{
+		{
+			name:           "year missing",
+			data:           map[int]repository.YoYAnalyticsData{},
+			wantReason:     model.YoYReasonNoData,
+			wantIncomplete: true,
+			wantNote:       "No data available for previous year (2023)",
+		},
+		{
+			name:           "no events",
+			data:           map[int]repository.YoYAnalyticsData{2023: {Year: 2023}},
+			wantReason:     model.YoYReasonNoEvents,
+			wantIncomplete: true,
+			wantNote:       "No events found for previous year (2023)",
+		},
+		{
+			name:       "events without efficiency",
+			data:       map[int]repository.YoYAnalyticsData{2023: {Year: 2023, TotalRealAmount: 40, EventCount: 3}},
+			wantReason: model.YoYReasonPartial,
+			wantNote:   "No efficiency data for previous year (2023): no events with a positive nominal amount",
+		},
+		{
+			name: "complete",
+			data: map[int]repository.YoYAnalyticsData{2023: {Year: 2023, TotalRealAmount: 40, EventCount: 3, AvgEfficiency: &efficiency}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comparison := svc.getYoYMetrics(tt.data, 2023, "previous year")
+			assert.Equal(t, tt.wantReason, comparison.Reason)
+			assert.Equal(t, tt.wantIncomplete, comparison.DataIncomplete)
+			assert.Equal(t, tt.wantNote, comparison.Note)
+		})
+	}
+}
+
+func TestGetAnalytics_EfficiencyStdDev(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)
+	f := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name    string
+		buckets []repository.AnalyticsAggregation
+		want    *float64
+	}{
+		{
+			// 0.9 and 0.8 on March 1, 0.8 on March 2
+			name: "pooled across buckets",
+			buckets: []repository.AnalyticsAggregation{
+				{Period: "2024-03-01", EventCount: 2, EfficiencyCount: 2, AvgEfficiency: f(0.85), StdDevEfficiency: f(0.05)},
+				{Period: "2024-03-02", EventCount: 1, EfficiencyCount: 1, AvgEfficiency: f(0.8)},
+			},
+			want: f(math.Sqrt(0.02 / 9)),
+		},
+		{
+			name: "single sample per bucket",
+			buckets: []repository.AnalyticsAggregation{
+				{Period: "2024-03-01", EventCount: 1, EfficiencyCount: 1, AvgEfficiency: f(0.9)},
+				{Period: "2024-03-02", EventCount: 1, EfficiencyCount: 1, AvgEfficiency: f(0.7)},
+			},
+			want: f(0.1),
+		},
+		{
+			name: "fewer than two valid samples",
+			buckets: []repository.AnalyticsAggregation{
+				{Period: "2024-03-01", EventCount: 2, EfficiencyCount: 1, AvgEfficiency: f(0.9)},
+				{Period: "2024-03-02", EventCount: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &mockAnalyticsRepo{
+				getAnalyticsFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation, limit, offset int) ([]repository.AnalyticsAggregation, int64, error) {
+					return tt.buckets, int64(len(tt.buckets)), nil
+				},
+				getYoYFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) (map[int]repository.YoYAnalyticsData, error) {
+					return map[int]repository.YoYAnalyticsData{}, nil
+				},
+				getSectorFn: func(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time, limit, offset int) ([]repository.SectorAnalyticsData, int64, error) {
+					return nil, 0, nil
+				},
+			}
+			svc := NewIrrigationAnalyticsService(repo, newTestLogger(t), newTestAnalyticsConfig())
+
+			resp, err := svc.GetAnalytics(context.Background(), 1, &start, &end, nil, model.AggregationDaily, 1, 50, model.AnalyticsOptions{})
+			require.NoError(t, err)
+			if tt.want == nil {
+				assert.Nil(t, resp.Metrics.EfficiencyStdDev)
+				return
+			}
+			require.NotNil(t, resp.Metrics.EfficiencyStdDev)
+			assert.InDelta(t, *tt.want, *resp.Metrics.EfficiencyStdDev, 0.0001)
+		})
+	}
+}
+
+func TestGetAnalytics_AnomaliesOnly(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 6, 23, 59, 59, 0, time.UTC)
+	f := func(v float64) *float64 { return &v }
+
+	// March 6 delivered far less than scheduled; the other days hover around 0.8
+	repo := &mockAnalyticsRepo{
+		getAnalyticsFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation, limit, offset int) ([]repository.AnalyticsAggregation, int64, error) {
+			return []repository.AnalyticsAggregation{
+				{Period: "2024-03-01", TotalRealAmount: 8, TotalNominalAmount: 10, EventCount: 1, AvgEfficiency: f(0.8)},
+				{Period: "2024-03-02", TotalRealAmount: 8.2, TotalNominalAmount: 10, EventCount: 1, AvgEfficiency: f(0.82)},
+				{Period: "2024-03-03", TotalRealAmount: 7.9, TotalNominalAmount: 10, EventCount: 1, AvgEfficiency: f(0.79)},
+				{Period: "2024-03-04", TotalRealAmount: 8.1, TotalNominalAmount: 10, EventCount: 1, AvgEfficiency: f(0.81)},
+				{Period: "2024-03-05", TotalRealAmount: 8, TotalNominalAmount: 10, EventCount: 1, AvgEfficiency: f(0.8)},
+				{Period: "2024-03-06", TotalRealAmount: 3, TotalNominalAmount: 10, EventCount: 1, AvgEfficiency: f(0.3)},
+			}, 6, nil
+		},
+		getYoYFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) (map[int]repository.YoYAnalyticsData, error) {
+			return map[int]repository.YoYAnalyticsData{}, nil
+		},
+		getSectorFn: func(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time, limit, offset int) ([]repository.SectorAnalyticsData, int64, error) {
+			return nil, 0, nil
+		},
+	}
+	svc := NewIrrigationAnalyticsService(repo, newTestLogger(t), newTestAnalyticsConfig())
+
+	all, err := svc.GetAnalytics(context.Background(), 1, &start, &end, nil, model.AggregationDaily, 1, 50, model.AnalyticsOptions{})
+	require.NoError(t, err)
+	require.Len(t, all.TimeSeries.Data, 6)
+	for _, entry := range all.TimeSeries.Data {
+		assert.Equal(t, entry.Date == "2024-03-06", entry.Anomalous, entry.Date)
+	}
+
+	anomalies, err := svc.GetAnalytics(context.Background(), 1, &start, &end, nil, model.AggregationDaily, 1, 50, model.AnalyticsOptions{AnomaliesOnly: true})
+	require.NoError(t, err)
+	require.Len(t, anomalies.TimeSeries.Data, 1)
+	assert.Equal(t, "2024-03-06", anomalies.TimeSeries.Data[0].Date)
+	// Metrics still cover every bucket
+	assert.InDelta(t, 43.2, anomalies.Metrics.TotalIrrigationVolumeMM, 0.001)
+	assert.Equal(t, 6, anomalies.Metrics.TotalIrrigationEvents)
+
+	// Pagination counts the anomalous buckets, judged against the whole period
+	paged, err := svc.GetAnalytics(context.Background(), 1, &start, &end, nil, model.AggregationDaily, 1, 2, model.AnalyticsOptions{AnomaliesOnly: true})
+	require.NoError(t, err)
+	require.Len(t, paged.TimeSeries.Data, 1)
+	assert.Equal(t, "2024-03-06", paged.TimeSeries.Data[0].Date)
+	assert.Equal(t, 1, paged.TimeSeries.Pagination.TotalCount)
+	assert.Equal(t, 1, paged.TimeSeries.Pagination.TotalPages)
+	assert.True(t, paged.HasData)
+}
+
+func TestGetAnalytics_WholePeriodAcrossPages(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 6, 23, 59, 59, 0, time.UTC)
+	f := func(v float64) *float64 { return &v }
+
+	// March 6 stands out from the whole period, but not from March 4-6 alone
+	buckets := []repository.AnalyticsAggregation{
+		{Period: "2024-03-01", TotalRealAmount: 8, TotalNominalAmount: 10, EventCount: 1, AvgEfficiency: f(0.8)},
+		{Period: "2024-03-02", TotalRealAmount: 8.2, TotalNominalAmount: 10, EventCount: 1, AvgEfficiency: f(0.82)},
+		{Period: "2024-03-03", TotalRealAmount: 7.9, TotalNominalAmount: 10, EventCount: 1, AvgEfficiency: f(0.79)},
+		{Period: "2024-03-04", TotalRealAmount: 8.1, TotalNominalAmount: 10, EventCount: 1, AvgEfficiency: f(0.81)},
+		{Period: "2024-03-05", TotalRealAmount: 8, TotalNominalAmount: 10, EventCount: 1, AvgEfficiency: f(0.8)},
+		{Period: "2024-03-06", TotalRealAmount: 3, TotalNominalAmount: 10, EventCount: 1, AvgEfficiency: f(0.3)},
+	}
+	repo := &mockAnalyticsRepo{
+		getAnalyticsFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation, limit, offset int) ([]repository.AnalyticsAggregation, int64, error) {
+			from := min(offset, len(buckets))
+			return buckets[from:min(from+limit, len(buckets))], int64(len(buckets)), nil
+		},
+		getYoYFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) (map[int]repository.YoYAnalyticsData, error) {
+			return map[int]repository.YoYAnalyticsData{}, nil
+		},
+		getSectorFn: func(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time, limit, offset int) ([]repository.SectorAnalyticsData, int64, error) {
+			return nil, 0, nil
+		},
+	}
+	svc := NewIrrigationAnalyticsService(repo, newTestLogger(t), newTestAnalyticsConfig())
+
+	resp, err := svc.GetAnalytics(context.Background(), 1, &start, &end, nil, model.AggregationDaily, 2, 3, model.AnalyticsOptions{})
+	require.NoError(t, err)
+	require.Len(t, resp.TimeSeries.Data, 3)
+	for _, entry := range resp.TimeSeries.Data {
+		assert.Equal(t, entry.Date == "2024-03-06", entry.Anomalous, entry.Date)
+	}
+	assert.InDelta(t, 43.2, resp.Metrics.TotalIrrigationVolumeMM, 0.001)
+	assert.Equal(t, 6, resp.Metrics.TotalIrrigationEvents)
+
+	// Past the only anomalous bucket, anomalies_only still reports the whole period's metrics
+	resp, err = svc.GetAnalytics(context.Background(), 1, &start, &end, nil, model.AggregationDaily, 2, 3, model.AnalyticsOptions{AnomaliesOnly: true})
+	require.NoError(t, err)
+	assert.Empty(t, resp.TimeSeries.Data)
+	assert.Equal(t, 1, resp.TimeSeries.Pagination.TotalCount)
+	assert.InDelta(t, 43.2, resp.Metrics.TotalIrrigationVolumeMM, 0.001)
+	assert.Equal(t, 6, resp.Metrics.TotalIrrigationEvents)
+}
+
+func TestFlagAnomalies_TooFewBuckets(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	entries := []model.TimeSeriesEntry{{Efficiency: f(0.9)}, {Efficiency: f(0.1)}, {}}
+
+	flagAnomalies(entries)
+	for _, entry := range entries {
+		assert.False(t, entry.Anomalous)
+	}
+}
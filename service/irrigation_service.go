@@ -2,15 +2,21 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
 	"time"
 
 	"github.com/sebaespinosa/test_NF/internal/logging"
 	"github.com/sebaespinosa/test_NF/model"
 	"github.com/sebaespinosa/test_NF/repository"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
+// ErrSectorNotFound is returned when the requested irrigation sector does not exist
+var ErrSectorNotFound = errors.New("irrigation sector not found")
+
 // IrrigationSectorService handles business logic for irrigation sector operations
 type IrrigationSectorService struct {
 	repo   *repository.IrrigationSectorRepository
@@ -37,6 +43,58 @@ func (s *IrrigationSectorService) GetByFarmID(ctx context.Context, farmID uint)
 	return s.repo.FindByFarmID(ctx, farmID)
 }
 
+// SearchByFarm lists a farm's irrigation sectors whose name contains query (case-insensitive)
+// An empty query returns every sector of the farm
+func (s *IrrigationSectorService) SearchByFarm(ctx context.Context, farmID uint, query string) (*model.IrrigationSectorsResponse, error) {
+	s.logger.WithContext(ctx).Info("searching irrigation sectors",
+		zap.Uint("farm_id", farmID),
+		zap.String("query", query),
+	)
+
+	var (
+		sectors []model.IrrigationSector
+		err     error
+	)
+	if query == "" {
+		sectors, err = s.repo.FindByFarmID(ctx, farmID)
+	} else {
+		sectors, err = s.repo.FindByFarmIDAndNameLike(ctx, farmID, query)
+	}
+	if err != nil {
+		s.logger.WithContext(ctx).Error("failed to search irrigation sectors", zap.Error(err))
+		return nil, err
+	}
+
+	return &model.IrrigationSectorsResponse{
+		FarmID: farmID,
+		Query:  query,
+		Data:   sectors,
+	}, nil
+}
+
+// ListInactiveByFarm lists a farm's irrigation sectors that have no events in the date range
+// The range defaults like the analytics endpoints (last 90 days)
+func (s *IrrigationSectorService) ListInactiveByFarm(ctx context.Context, farmID uint, startDate, endDate *time.Time) (*model.InactiveSectorsResponse, error) {
+	start, end := resolveDateRange(startDate, endDate)
+	s.logger.WithContext(ctx).Info("listing inactive irrigation sectors",
+		zap.Uint("farm_id", farmID),
+		zap.Time("start_time", start),
+		zap.Time("end_time", end),
+	)
+
+	sectors, err := s.repo.FindInactiveByFarmID(ctx, farmID, start, end)
+	if err != nil {
+		s.logger.WithContext(ctx).Error("failed to list inactive irrigation sectors", zap.Error(err))
+		return nil, err
+	}
+
+	return &model.InactiveSectorsResponse{
+		FarmID: farmID,
+		Period: model.IrrigationAnalyticsPeriod{Start: start, End: end},
+		Data:   sectors,
+	}, nil
+}
+
 // Create creates a new irrigation sector
 func (s *IrrigationSectorService) Create(ctx context.Context, sector *model.IrrigationSector) error {
 	s.logger.WithContext(ctx).Info("creating irrigation sector",
@@ -83,15 +141,17 @@ func (s *IrrigationSectorService) RemoveSeedSectors(ctx context.Context) error {
 
 // IrrigationDataService handles business logic for irrigation data operations
 type IrrigationDataService struct {
-	repo   *repository.IrrigationDataRepository
-	logger *logging.Logger
+	repo       *repository.IrrigationDataRepository
+	sectorRepo *repository.IrrigationSectorRepository
+	logger     *logging.Logger
 }
 
 // NewIrrigationDataService creates a new IrrigationDataService instance
-func NewIrrigationDataService(repo *repository.IrrigationDataRepository, logger *logging.Logger) *IrrigationDataService {
+func NewIrrigationDataService(repo *repository.IrrigationDataRepository, sectorRepo *repository.IrrigationSectorRepository, logger *logging.Logger) *IrrigationDataService {
 	return &IrrigationDataService{
-		repo:   repo,
-		logger: logger,
+		repo:       repo,
+		sectorRepo: sectorRepo,
+		logger:     logger,
 	}
 }
 
@@ -121,22 +181,215 @@ func (s *IrrigationDataService) GetBySectorAndTimeRange(ctx context.Context, sec
 	return s.repo.FindBySectorIDAndTimeRange(ctx, sectorID, startTime, endTime)
 }
 
+// ListSectorEvents returns one page of raw irrigation events for a sector, regardless of its farm
+// Dates default to the last 90 days when not provided; ErrSectorNotFound when the sector does not exist
+// Associations named in expand are embedded in each event
+func (s *IrrigationDataService) ListSectorEvents(ctx context.Context, sectorID uint, startDate, endDate *time.Time, page, limit int, expand model.EventExpansions) (*model.IrrigationEventsResponse, error) {
+	start, end := resolveDateRange(startDate, endDate)
+	s.logger.WithContext(ctx).Info("listing irrigation events for sector",
+		zap.Uint("sector_id", sectorID),
+		zap.Time("start_time", start),
+		zap.Time("end_time", end),
+	)
+
+	if _, err := s.sectorRepo.FindByID(ctx, sectorID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSectorNotFound
+		}
+		s.logger.WithContext(ctx).Error("failed to look up irrigation sector", zap.Error(err))
+		return nil, err
+	}
+
+	data, totalCount, err := s.repo.FindPageBySectorIDAndTimeRange(ctx, sectorID, start, end, limit, (page-1)*limit, expand...)
+	if err != nil {
+		s.logger.WithContext(ctx).Error("failed to list irrigation events", zap.Error(err))
+		return nil, err
+	}
+
+	return &model.IrrigationEventsResponse{
+		Period: model.IrrigationAnalyticsPeriod{Start: start, End: end},
+		Data:   data,
+		Pagination: model.PaginationMetadata{
+			Page:       page,
+			Limit:      limit,
+			TotalCount: int(totalCount),
+			TotalPages: int(math.Ceil(float64(totalCount) / float64(limit))),
+		},
+	}, nil
+}
+
+// ListFarmEvents returns one page of raw irrigation events for a farm
+// Dates default to the last 90 days when not provided, like the analytics endpoint
+// Associations named in expand are embedded in each event
+func (s *IrrigationDataService) ListFarmEvents(ctx context.Context, farmID uint, startDate, endDate *time.Time, page, limit int, expand model.EventExpansions) (*model.IrrigationEventsResponse, error) {
+	start, end := resolveDateRange(startDate, endDate)
+	s.logger.WithContext(ctx).Info("listing irrigation events for farm",
+		zap.Uint("farm_id", farmID),
+		zap.Time("start_time", start),
+		zap.Time("end_time", end),
+	)
+
+	data, totalCount, err := s.repo.FindPageByFarmIDAndTimeRange(ctx, farmID, start, end, limit, (page-1)*limit, expand...)
+	if err != nil {
+		s.logger.WithContext(ctx).Error("failed to list irrigation events", zap.Error(err))
+		return nil, err
+	}
+
+	return &model.IrrigationEventsResponse{
+		Period: model.IrrigationAnalyticsPeriod{Start: start, End: end},
+		Data:   data,
+		Pagination: model.PaginationMetadata{
+			Page:       page,
+			Limit:      limit,
+			TotalCount: int(totalCount),
+			TotalPages: int(math.Ceil(float64(totalCount) / float64(limit))),
+		},
+	}, nil
+}
+
+// ListChanges returns up to limit events created or updated after the cursor, for incremental sync
+// One extra event is fetched to tell whether more follow; NextCursor points after the last event returned,
+// or repeats the cursor when nothing changed, so clients can poll with it
+func (s *IrrigationDataService) ListChanges(ctx context.Context, since model.ChangeCursor, limit int) (*model.IrrigationChangesResponse, error) {
+	s.logger.WithContext(ctx).Info("listing changed irrigation events",
+		zap.Time("since", since.UpdatedAt),
+		zap.Uint("after_id", since.ID),
+		zap.Int("limit", limit),
+	)
+
+	data, err := s.repo.FindModifiedSince(ctx, since, limit+1)
+	if err != nil {
+		s.logger.WithContext(ctx).Error("failed to list changed irrigation events", zap.Error(err))
+		return nil, err
+	}
+
+	hasMore := len(data) > limit
+	if hasMore {
+		data = data[:limit]
+	}
+	next := since
+	if len(data) > 0 {
+		last := data[len(data)-1]
+		next = model.ChangeCursor{UpdatedAt: last.UpdatedAt, ID: last.ID}
+	}
+
+	return &model.IrrigationChangesResponse{
+		Data:       data,
+		NextCursor: next.String(),
+		HasMore:    hasMore,
+	}, nil
+}
+
+// GetFarmEventsLastModified returns the latest updated_at of a farm's events in the requested range and
+// how many events it has
+// Returns nil when the range has no events
+func (s *IrrigationDataService) GetFarmEventsLastModified(ctx context.Context, farmID uint, startDate, endDate *time.Time) (*time.Time, int64, error) {
+	start, end := resolveDateRange(startDate, endDate)
+	return s.repo.GetLastModifiedForFarm(ctx, farmID, start, end)
+}
+
 // AggregateByFarm aggregates irrigation data by farm within a time range
-func (s *IrrigationDataService) AggregateByFarm(ctx context.Context, startTime, endTime time.Time) ([]repository.FarmAggregation, error) {
+func (s *IrrigationDataService) AggregateByFarm(ctx context.Context, startDate, endDate *time.Time) (*model.FarmAggregatesResponse, error) {
+	start, end := resolveDateRange(startDate, endDate)
 	s.logger.WithContext(ctx).Info("aggregating irrigation data by farm",
-		zap.Time("start_time", startTime),
-		zap.Time("end_time", endTime),
+		zap.Time("start_time", start),
+		zap.Time("end_time", end),
 	)
-	return s.repo.AggregateByFarm(ctx, startTime, endTime)
+
+	data, err := s.repo.AggregateByFarm(ctx, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.FarmAggregatesResponse{
+		Period: model.IrrigationAnalyticsPeriod{Start: start, End: end},
+		Farms:  toFarmAggregates(data),
+	}, nil
 }
 
 // AggregateBySector aggregates irrigation data by sector within a time range
-func (s *IrrigationDataService) AggregateBySector(ctx context.Context, startTime, endTime time.Time) ([]repository.SectorAggregation, error) {
+func (s *IrrigationDataService) AggregateBySector(ctx context.Context, startDate, endDate *time.Time) (*model.SectorAggregatesResponse, error) {
+	start, end := resolveDateRange(startDate, endDate)
 	s.logger.WithContext(ctx).Info("aggregating irrigation data by sector",
-		zap.Time("start_time", startTime),
-		zap.Time("end_time", endTime),
+		zap.Time("start_time", start),
+		zap.Time("end_time", end),
 	)
-	return s.repo.AggregateBySector(ctx, startTime, endTime)
+
+	data, err := s.repo.AggregateBySector(ctx, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.SectorAggregatesResponse{
+		Period:  model.IrrigationAnalyticsPeriod{Start: start, End: end},
+		Sectors: toSectorAggregates(data),
+	}, nil
+}
+
+// AggregateSector aggregates one sector's irrigation data within a time range
+// Returns ErrSectorNotFound when the sector does not exist
+func (s *IrrigationDataService) AggregateSector(ctx context.Context, sectorID uint, startDate, endDate *time.Time) (*model.SectorAggregateResponse, error) {
+	start, end := resolveDateRange(startDate, endDate)
+	s.logger.WithContext(ctx).Info("aggregating irrigation data for sector",
+		zap.Uint("sector_id", sectorID),
+		zap.Time("start_time", start),
+		zap.Time("end_time", end),
+	)
+
+	data, err := s.repo.AggregateForSector(ctx, sectorID, start, end)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSectorNotFound
+		}
+		s.logger.WithContext(ctx).Error("failed to aggregate irrigation data for sector", zap.Error(err))
+		return nil, err
+	}
+
+	return &model.SectorAggregateResponse{
+		Period: model.IrrigationAnalyticsPeriod{Start: start, End: end},
+		Sector: toSectorAggregate(*data),
+	}, nil
+}
+
+// toFarmAggregates maps repository rows to the API type so the response does not depend on the query shape
+func toFarmAggregates(data []repository.FarmAggregation) []model.FarmAggregate {
+	farms := make([]model.FarmAggregate, 0, len(data))
+	for _, item := range data {
+		farms = append(farms, model.FarmAggregate{
+			FarmID:           item.FarmID,
+			FarmName:         item.FarmName,
+			EventCount:       item.TotalEvents,
+			NominalAmountMM:  item.TotalNominalAmount,
+			RealAmountMM:     item.TotalRealAmount,
+			AvgNominalAmount: item.AvgNominalAmount,
+			AvgRealAmount:    item.AvgRealAmount,
+		})
+	}
+	return farms
+}
+
+// toSectorAggregates maps repository rows to the API type so the response does not depend on the query shape
+func toSectorAggregates(data []repository.SectorAggregation) []model.SectorAggregate {
+	sectors := make([]model.SectorAggregate, 0, len(data))
+	for _, item := range data {
+		sectors = append(sectors, toSectorAggregate(item))
+	}
+	return sectors
+}
+
+// toSectorAggregate maps a single repository row to the API type
+func toSectorAggregate(item repository.SectorAggregation) model.SectorAggregate {
+	return model.SectorAggregate{
+		FarmID:           item.FarmID,
+		FarmName:         item.FarmName,
+		SectorID:         item.SectorID,
+		SectorName:       item.SectorName,
+		EventCount:       item.TotalEvents,
+		NominalAmountMM:  item.TotalNominalAmount,
+		RealAmountMM:     item.TotalRealAmount,
+		AvgNominalAmount: item.AvgNominalAmount,
+		AvgRealAmount:    item.AvgRealAmount,
+	}
 }
 
 // Create creates a new irrigation data record
@@ -149,6 +402,108 @@ func (s *IrrigationDataService) Create(ctx context.Context, data *model.Irrigati
 	return s.repo.Create(ctx, data)
 }
 
+// BatchValidationError reports every invalid record in a batch; nothing is inserted when returned
+type BatchValidationError struct {
+	Violations []model.ValidationViolation
+}
+
+func (e *BatchValidationError) Error() string {
+	return fmt.Sprintf("batch contains %d invalid field(s)", len(e.Violations))
+}
+
+// CreateBatch validates and inserts irrigation events for a farm
+// All records are validated first; if any fails, a *BatchValidationError lists them and nothing is inserted
+// Once every record's fields are valid, each irrigation_sector_id must name a sector of farmID
+func (s *IrrigationDataService) CreateBatch(ctx context.Context, farmID uint, inputs []model.IrrigationEventInput) (int, error) {
+	s.logger.WithContext(ctx).Info("creating irrigation data batch",
+		zap.Uint("farm_id", farmID),
+		zap.Int("count", len(inputs)),
+	)
+
+	violations := validateEventInputs(inputs)
+	if len(violations) == 0 {
+		farmSectors, err := s.sectorRepo.FindByFarmID(ctx, farmID)
+		if err != nil {
+			s.logger.WithContext(ctx).Error("failed to look up farm sectors", zap.Error(err))
+			return 0, err
+		}
+		violations = validateEventSectors(inputs, farmID, farmSectors)
+	}
+	if len(violations) > 0 {
+		s.logger.WithContext(ctx).Warn("irrigation data batch rejected", zap.Int("violations", len(violations)))
+		return 0, &BatchValidationError{Violations: violations}
+	}
+
+	records := make([]model.IrrigationData, 0, len(inputs))
+	for _, input := range inputs {
+		records = append(records, model.IrrigationData{
+			FarmID:             farmID,
+			IrrigationSectorID: input.IrrigationSectorID,
+			StartTime:          input.StartTime.UTC(),
+			EndTime:            input.EndTime.UTC(),
+			NominalAmount:      input.NominalAmount,
+			RealAmount:         input.RealAmount,
+		})
+	}
+
+	if err := s.repo.CreateBatch(ctx, records); err != nil {
+		s.logger.WithContext(ctx).Error("failed to create irrigation data batch", zap.Error(err))
+		return 0, err
+	}
+
+	return len(records), nil
+}
+
+// validateEventInputs collects field-level violations for every record in the batch
+func validateEventInputs(inputs []model.IrrigationEventInput) []model.ValidationViolation {
+	var violations []model.ValidationViolation
+	add := func(index int, field, reason string) {
+		violations = append(violations, model.ValidationViolation{Index: index, Field: field, Reason: reason})
+	}
+
+	for i, input := range inputs {
+		if input.IrrigationSectorID == 0 {
+			add(i, "irrigation_sector_id", "is required")
+		}
+		if input.StartTime.IsZero() {
+			add(i, "start_time", "is required")
+		}
+		if input.EndTime.IsZero() {
+			add(i, "end_time", "is required")
+		} else if !input.StartTime.IsZero() && !input.EndTime.After(input.StartTime) {
+			add(i, "end_time", "must be after start_time")
+		}
+		if input.NominalAmount < 0 {
+			add(i, "nominal_amount", "must be >= 0")
+		}
+		if input.RealAmount < 0 {
+			add(i, "real_amount", "must be >= 0")
+		}
+	}
+
+	return violations
+}
+
+// validateEventSectors reports every record whose sector is not one of the farm's sectors
+func validateEventSectors(inputs []model.IrrigationEventInput, farmID uint, farmSectors []model.IrrigationSector) []model.ValidationViolation {
+	owned := make(map[uint]bool, len(farmSectors))
+	for _, sector := range farmSectors {
+		owned[sector.ID] = true
+	}
+
+	var violations []model.ValidationViolation
+	for i, input := range inputs {
+		if !owned[input.IrrigationSectorID] {
+			violations = append(violations, model.ValidationViolation{
+				Index:  i,
+				Field:  "irrigation_sector_id",
+				Reason: fmt.Sprintf("is not a sector of farm %d", farmID),
+			})
+		}
+	}
+	return violations
+}
+
 // Delete deletes irrigation data by ID
 func (s *IrrigationDataService) Delete(ctx context.Context, id uint) error {
 	s.logger.WithContext(ctx).Info("deleting irrigation data", zap.Uint("data_id", id))
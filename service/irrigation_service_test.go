@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sebaespinosa/test_NF/model"
+	"github.com/sebaespinosa/test_NF/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestCreateBatch_ReportsInvalidIndices(t *testing.T) {
+	// A nil repository proves nothing is inserted when validation fails
+	svc := NewIrrigationDataService(nil, nil, newTestLogger(t))
+	start := time.Date(2024, 3, 1, 6, 0, 0, 0, time.UTC)
+
+	inputs := []model.IrrigationEventInput{
+		{IrrigationSectorID: 1, StartTime: start, EndTime: start.Add(time.Hour), NominalAmount: 20, RealAmount: 18},
+		{IrrigationSectorID: 1, StartTime: start, EndTime: start.Add(-time.Hour), NominalAmount: 20, RealAmount: 18},
+		{IrrigationSectorID: 1, StartTime: start, EndTime: start.Add(time.Hour), NominalAmount: 20, RealAmount: 18},
+		{StartTime: start, EndTime: start.Add(time.Hour), NominalAmount: -1, RealAmount: 18},
+	}
+
+	created, err := svc.CreateBatch(context.Background(), 1, inputs)
+	require.Error(t, err)
+	assert.Zero(t, created)
+
+	var validationErr *BatchValidationError
+	require.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, []model.ValidationViolation{
+		{Index: 1, Field: "end_time", Reason: "must be after start_time"},
+		{Index: 3, Field: "irrigation_sector_id", Reason: "is required"},
+		{Index: 3, Field: "nominal_amount", Reason: "must be >= 0"},
+	}, validationErr.Violations)
+}
+
+func TestCreateBatch_RejectsSectorsOfOtherFarms(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&model.Farm{}, &model.IrrigationSector{}, &model.IrrigationData{}))
+	require.NoError(t, db.Create(&[]model.Farm{{ID: 1, Name: "Farm A"}, {ID: 2, Name: "Farm B"}}).Error)
+	require.NoError(t, db.Create(&[]model.IrrigationSector{{ID: 1, FarmID: 1, Name: "North"}, {ID: 2, FarmID: 2, Name: "South"}}).Error)
+
+	dataRepo := repository.NewIrrigationDataRepository(db)
+	svc := NewIrrigationDataService(dataRepo, repository.NewIrrigationSectorRepository(db), newTestLogger(t))
+	ctx := context.Background()
+	start := time.Date(2024, 3, 1, 6, 0, 0, 0, time.UTC)
+
+	inputs := []model.IrrigationEventInput{
+		{IrrigationSectorID: 1, StartTime: start, EndTime: start.Add(time.Hour), NominalAmount: 20, RealAmount: 18},
+		{IrrigationSectorID: 2, StartTime: start, EndTime: start.Add(time.Hour), NominalAmount: 20, RealAmount: 18},
+		{IrrigationSectorID: 99, StartTime: start, EndTime: start.Add(time.Hour), NominalAmount: 20, RealAmount: 18},
+	}
+
+	created, err := svc.CreateBatch(ctx, 1, inputs)
+	assert.Zero(t, created)
+	var validationErr *BatchValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []model.ValidationViolation{
+		{Index: 1, Field: "irrigation_sector_id", Reason: "is not a sector of farm 1"},
+		{Index: 2, Field: "irrigation_sector_id", Reason: "is not a sector of farm 1"},
+	}, validationErr.Violations)
+
+	var stored int64
+	require.NoError(t, db.Model(&model.IrrigationData{}).Count(&stored).Error)
+	assert.Zero(t, stored)
+
+	// The farm's own sectors are accepted
+	created, err = svc.CreateBatch(ctx, 1, inputs[:1])
+	require.NoError(t, err)
+	assert.Equal(t, 1, created)
+}
+
+func TestAggregateMapping(t *testing.T) {
+	farms := toFarmAggregates([]repository.FarmAggregation{{
+		FarmID: 1, FarmName: "Green Valley", TotalEvents: 3,
+		TotalNominalAmount: 60, TotalRealAmount: 50, AvgNominalAmount: 20, AvgRealAmount: 50.0 / 3,
+	}})
+	assert.Equal(t, []model.FarmAggregate{{
+		FarmID: 1, FarmName: "Green Valley", EventCount: 3,
+		NominalAmountMM: 60, RealAmountMM: 50, AvgNominalAmount: 20, AvgRealAmount: 50.0 / 3,
+	}}, farms)
+
+	sectors := toSectorAggregates([]repository.SectorAggregation{{
+		FarmID: 1, FarmName: "Green Valley", SectorID: 2, SectorName: "North", TotalEvents: 2,
+		TotalNominalAmount: 40, TotalRealAmount: 30, AvgNominalAmount: 20, AvgRealAmount: 15,
+	}})
+	assert.Equal(t, []model.SectorAggregate{{
+		FarmID: 1, FarmName: "Green Valley", SectorID: 2, SectorName: "North", EventCount: 2,
+		NominalAmountMM: 40, RealAmountMM: 30, AvgNominalAmount: 20, AvgRealAmount: 15,
+	}}, sectors)
+
+	// Empty results serialize as [] rather than null
+	assert.NotNil(t, toFarmAggregates(nil))
+	assert.NotNil(t, toSectorAggregates(nil))
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sebaespinosa/test_NF/model"
+	"github.com/sebaespinosa/test_NF/repository"
+)
+
+// ObservedAnalyticsRepository decorates an AnalyticsRepository, observing the duration of every call in a
+// histogram labeled by method and outcome (ok or error); results and errors are passed through unchanged
+// Metrics, caching or tracing can be layered this way without touching the repository methods
+type ObservedAnalyticsRepository struct {
+	next     AnalyticsRepository
+	duration *prometheus.HistogramVec
+}
+
+// NewObservedAnalyticsRepository wraps next, recording call durations in duration (labels: method, outcome)
+func NewObservedAnalyticsRepository(next AnalyticsRepository, duration *prometheus.HistogramVec) *ObservedAnalyticsRepository {
+	return &ObservedAnalyticsRepository{next: next, duration: duration}
+}
+
+// observe records the time since start for method; meant to be deferred with a pointer to the call's error
+func (r *ObservedAnalyticsRepository) observe(method string, start time.Time, err *error) {
+	outcome := "ok"
+	if *err != nil {
+		outcome = "error"
+	}
+	r.duration.WithLabelValues(method, outcome).Observe(time.Since(start).Seconds())
+}
+
+func (r *ObservedAnalyticsRepository) GetAnalyticsForFarmByDateRange(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation, limit, offset int) (results []repository.AnalyticsAggregation, total int64, truncated bool, err error) {
+	defer r.observe("GetAnalyticsForFarmByDateRange", time.Now(), &err)
+	return r.next.GetAnalyticsForFarmByDateRange(ctx, farmID, startTime, endTime, aggregation, limit, offset)
+}
+
+func (r *ObservedAnalyticsRepository) GetYoYComparison(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) (results map[int]repository.YoYAnalyticsData, err error) {
+	defer r.observe("GetYoYComparison", time.Now(), &err)
+	return r.next.GetYoYComparison(ctx, farmID, startTime, endTime, aggregation)
+}
+
+func (r *ObservedAnalyticsRepository) GetSectorBreakdownForFarm(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time, limit, offset int) (results []repository.SectorAnalyticsData, total int64, err error) {
+	defer r.observe("GetSectorBreakdownForFarm", time.Now(), &err)
+	return r.next.GetSectorBreakdownForFarm(ctx, farmID, sectorID, startTime, endTime, limit, offset)
+}
+
+func (r *ObservedAnalyticsRepository) GetSectorRanking(ctx context.Context, farmID uint, startTime, endTime time.Time, limit, offset int) (results []repository.SectorAnalyticsData, total int64, err error) {
+	defer r.observe("GetSectorRanking", time.Now(), &err)
+	return r.next.GetSectorRanking(ctx, farmID, startTime, endTime, limit, offset)
+}
+
+func (r *ObservedAnalyticsRepository) GetSectorBreakdownForFarms(ctx context.Context, farmIDs []uint, startTime, endTime time.Time) (results []repository.FarmSectorAnalyticsData, err error) {
+	defer r.observe("GetSectorBreakdownForFarms", time.Now(), &err)
+	return r.next.GetSectorBreakdownForFarms(ctx, farmIDs, startTime, endTime)
+}
+
+func (r *ObservedAnalyticsRepository) GetSectorTimeSeriesForFarm(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) (results []repository.SectorTimeSeriesData, err error) {
+	defer r.observe("GetSectorTimeSeriesForFarm", time.Now(), &err)
+	return r.next.GetSectorTimeSeriesForFarm(ctx, farmID, startTime, endTime, aggregation)
+}
+
+func (r *ObservedAnalyticsRepository) GetFarmTimeSeriesBySector(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) (results []repository.PeriodSectorData, err error) {
+	defer r.observe("GetFarmTimeSeriesBySector", time.Now(), &err)
+	return r.next.GetFarmTimeSeriesBySector(ctx, farmID, startTime, endTime, aggregation)
+}
+
+func (r *ObservedAnalyticsRepository) GetTopIrrigationDays(ctx context.Context, farmID uint, startTime, endTime time.Time, n int) (results []repository.DailyTotalData, err error) {
+	defer r.observe("GetTopIrrigationDays", time.Now(), &err)
+	return r.next.GetTopIrrigationDays(ctx, farmID, startTime, endTime, n)
+}
+
+func (r *ObservedAnalyticsRepository) GetDayOfWeekDistribution(ctx context.Context, farmID uint, startTime, endTime time.Time) (results []repository.DayOfWeekData, err error) {
+	defer r.observe("GetDayOfWeekDistribution", time.Now(), &err)
+	return r.next.GetDayOfWeekDistribution(ctx, farmID, startTime, endTime)
+}
+
+func (r *ObservedAnalyticsRepository) GetEfficiencyByAmount(ctx context.Context, farmID uint, startTime, endTime time.Time, bins int) (results []repository.AmountBinData, err error) {
+	defer r.observe("GetEfficiencyByAmount", time.Now(), &err)
+	return r.next.GetEfficiencyByAmount(ctx, farmID, startTime, endTime, bins)
+}
+
+func (r *ObservedAnalyticsRepository) CountActiveSectors(ctx context.Context, farmID uint, startTime, endTime time.Time) (count int, err error) {
+	defer r.observe("CountActiveSectors", time.Now(), &err)
+	return r.next.CountActiveSectors(ctx, farmID, startTime, endTime)
+}
+
+func (r *ObservedAnalyticsRepository) GetSectorScheduleForFarm(ctx context.Context, farmID uint, startTime, endTime time.Time) (results []repository.SectorScheduleData, err error) {
+	defer r.observe("GetSectorScheduleForFarm", time.Now(), &err)
+	return r.next.GetSectorScheduleForFarm(ctx, farmID, startTime, endTime)
+}
+
+func (r *ObservedAnalyticsRepository) GetFirstEventTimeForFarm(ctx context.Context, farmID uint) (first *time.Time, err error) {
+	defer r.observe("GetFirstEventTimeForFarm", time.Now(), &err)
+	return r.next.GetFirstEventTimeForFarm(ctx, farmID)
+}
+
+func (r *ObservedAnalyticsRepository) GetDataQualityForFarm(ctx context.Context, farmID uint, startTime, endTime time.Time) (quality repository.DataQualityData, err error) {
+	defer r.observe("GetDataQualityForFarm", time.Now(), &err)
+	return r.next.GetDataQualityForFarm(ctx, farmID, startTime, endTime)
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/sebaespinosa/test_NF/model"
+	"github.com/sebaespinosa/test_NF/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ AnalyticsRepository = (*ObservedAnalyticsRepository)(nil)
+
+func sampleCount(t *testing.T, histogram *prometheus.HistogramVec, labels ...string) uint64 {
+	t.Helper()
+	var metric dto.Metric
+	require.NoError(t, histogram.WithLabelValues(labels...).(prometheus.Metric).Write(&metric))
+	return metric.GetHistogram().GetSampleCount()
+}
+
+func TestObservedAnalyticsRepository_ForwardsAndRecords(t *testing.T) {
+	errExpected := errors.New("db error")
+	var gotFarmID uint
+	var gotLimit int
+	next := &mockAnalyticsRepo{
+		getAnalyticsFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation, limit, offset int) ([]repository.AnalyticsAggregation, int64, error) {
+			gotFarmID, gotLimit = farmID, limit
+			return []repository.AnalyticsAggregation{{Period: "2024-03-01"}}, 7, nil
+		},
+		getYoYFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) (map[int]repository.YoYAnalyticsData, error) {
+			return nil, errExpected
+		},
+		truncated: true,
+	}
+	histogram := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "test_repository_call_duration_seconds"}, []string{"method", "outcome"})
+	repo := NewObservedAnalyticsRepository(next, histogram)
+
+	ctx := context.Background()
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+
+	results, total, truncated, err := repo.GetAnalyticsForFarmByDateRange(ctx, 4, start, end, model.AggregationDaily, 25, 0)
+	require.NoError(t, err)
+	assert.Equal(t, uint(4), gotFarmID)
+	assert.Equal(t, 25, gotLimit)
+	assert.Equal(t, []repository.AnalyticsAggregation{{Period: "2024-03-01"}}, results)
+	assert.Equal(t, int64(7), total)
+	assert.True(t, truncated)
+
+	_, err = repo.GetYoYComparison(ctx, 4, start, end, model.AggregationDaily)
+	require.ErrorIs(t, err, errExpected)
+	_, err = repo.GetYoYComparison(ctx, 4, start, end, model.AggregationDaily)
+	require.ErrorIs(t, err, errExpected)
+
+	assert.Equal(t, uint64(1), sampleCount(t, histogram, "GetAnalyticsForFarmByDateRange", "ok"))
+	assert.Equal(t, uint64(0), sampleCount(t, histogram, "GetAnalyticsForFarmByDateRange", "error"))
+	assert.Equal(t, uint64(2), sampleCount(t, histogram, "GetYoYComparison", "error"))
+}
+
+func TestObservedAnalyticsRepository_WrapsService(t *testing.T) {
+	repo := &mockAnalyticsRepo{
+		getAnalyticsFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation, limit, offset int) ([]repository.AnalyticsAggregation, int64, error) {
+			return nil, 0, nil
+		},
+		getYoYFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) (map[int]repository.YoYAnalyticsData, error) {
+			return map[int]repository.YoYAnalyticsData{}, nil
+		},
+		getSectorFn: func(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time, limit, offset int) ([]repository.SectorAnalyticsData, int64, error) {
+			return nil, 0, nil
+		},
+	}
+	histogram := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "test_repository_call_duration_seconds"}, []string{"method", "outcome"})
+	svc := NewIrrigationAnalyticsService(NewObservedAnalyticsRepository(repo, histogram), newTestLogger(t), newTestAnalyticsConfig())
+
+	_, err := svc.GetAnalytics(context.Background(), 1, nil, nil, nil, model.AggregationDaily, 1, 10, model.AnalyticsOptions{})
+	require.NoError(t, err)
+
+	for _, method := range []string{"GetAnalyticsForFarmByDateRange", "GetYoYComparison", "GetSectorBreakdownForFarm"} {
+		assert.Equal(t, uint64(1), sampleCount(t, histogram, method, "ok"), method)
+	}
+}
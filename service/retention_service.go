@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/sebaespinosa/test_NF/internal/logging"
+	"github.com/sebaespinosa/test_NF/repository"
+	"go.uber.org/zap"
+)
+
+// RetentionRepository defines the data access contract for the retention purge.
+type RetentionRepository interface {
+	DeleteOlderThan(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// DailyArchiver defines the data access contract for archiving raw events into daily summaries.
+type DailyArchiver interface {
+	FindRawDaysBefore(ctx context.Context, cutoff time.Time) ([]time.Time, error)
+	ArchiveDay(ctx context.Context, day time.Time) (repository.ArchiveDayResult, error)
+}
+
+// RetentionService deletes raw irrigation events older than the retention window
+// A retentionDays of zero or less disables the purge
+// With an archiver, each expired day is rolled up into daily summaries before its raw events are deleted
+type RetentionService struct {
+	repo          RetentionRepository
+	archiver      DailyArchiver
+	logger        *logging.Logger
+	retentionDays int
+	now           func() time.Time
+}
+
+// NewRetentionService creates a new instance of RetentionService
+func NewRetentionService(repo RetentionRepository, logger *logging.Logger, retentionDays int) *RetentionService {
+	return &RetentionService{
+		repo:          repo,
+		logger:        logger,
+		retentionDays: retentionDays,
+		now:           time.Now,
+	}
+}
+
+// WithArchive returns a copy of the service that archives each expired day into daily summaries
+// instead of deleting raw events outright
+func (s *RetentionService) WithArchive(archiver DailyArchiver) *RetentionService {
+	clone := *s
+	clone.archiver = archiver
+	return &clone
+}
+
+// Enabled reports whether the purge deletes anything
+func (s *RetentionService) Enabled() bool {
+	return s.retentionDays > 0
+}
+
+// Cutoff returns the start_time before which events are purged
+// It is aligned to UTC midnight so a run never removes part of a day
+func (s *RetentionService) Cutoff() time.Time {
+	now := s.now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return today.AddDate(0, 0, -s.retentionDays)
+}
+
+// PurgeOnce deletes events older than the cutoff and returns how many were removed
+// With retention disabled it does nothing and returns 0
+func (s *RetentionService) PurgeOnce(ctx context.Context) (int64, error) {
+	if !s.Enabled() {
+		return 0, nil
+	}
+
+	cutoff := s.Cutoff()
+	if s.archiver != nil {
+		return s.archiveOlderThan(ctx, cutoff)
+	}
+
+	deleted, err := s.repo.DeleteOlderThan(ctx, cutoff)
+	if err != nil {
+		s.logger.WithContext(ctx).Error("retention purge failed", zap.Time("cutoff", cutoff), zap.Error(err))
+		return 0, err
+	}
+
+	s.logger.WithContext(ctx).Info("retention purge completed",
+		zap.Time("cutoff", cutoff),
+		zap.Int("retention_days", s.retentionDays),
+		zap.Int64("deleted", deleted),
+	)
+	return deleted, nil
+}
+
+// archiveOlderThan archives every day before cutoff that still has raw events, oldest first
+// Each day commits on its own, so a failure keeps the days already archived and the rest are retried on the next run
+func (s *RetentionService) archiveOlderThan(ctx context.Context, cutoff time.Time) (int64, error) {
+	days, err := s.archiver.FindRawDaysBefore(ctx, cutoff)
+	if err != nil {
+		s.logger.WithContext(ctx).Error("retention archival failed", zap.Time("cutoff", cutoff), zap.Error(err))
+		return 0, err
+	}
+
+	var deleted int64
+	summaries := 0
+	for i, day := range days {
+		result, err := s.archiver.ArchiveDay(ctx, day)
+		if err != nil {
+			s.logger.WithContext(ctx).Error("retention archival failed",
+				zap.Time("cutoff", cutoff),
+				zap.Time("day", day),
+				zap.Int("days_archived", i),
+				zap.Error(err),
+			)
+			return deleted, err
+		}
+		deleted += result.RawDeleted
+		summaries += result.Summaries
+	}
+
+	s.logger.WithContext(ctx).Info("retention archival completed",
+		zap.Time("cutoff", cutoff),
+		zap.Int("retention_days", s.retentionDays),
+		zap.Int("days", len(days)),
+		zap.Int("summaries", summaries),
+		zap.Int64("deleted", deleted),
+	)
+	return deleted, nil
+}
+
+// Run purges once immediately and then every interval until ctx is cancelled
+// It returns at once when retention is disabled; a failed run is logged and retried on the next tick
+func (s *RetentionService) Run(ctx context.Context, interval time.Duration) {
+	if !s.Enabled() {
+		s.logger.Info("retention purge disabled")
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		_, _ = s.PurgeOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			s.logger.Info("retention purge stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sebaespinosa/test_NF/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubRetentionRepo struct {
+	mu      sync.Mutex
+	cutoffs []time.Time
+	deleted int64
+	err     error
+}
+
+func (s *stubRetentionRepo) DeleteOlderThan(ctx context.Context, cutoff time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cutoffs = append(s.cutoffs, cutoff)
+	return s.deleted, s.err
+}
+
+func (s *stubRetentionRepo) calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.cutoffs)
+}
+
+type stubDailyArchiver struct {
+	days     []time.Time
+	archived []time.Time
+	failOn   *time.Time
+}
+
+func (s *stubDailyArchiver) FindRawDaysBefore(ctx context.Context, cutoff time.Time) ([]time.Time, error) {
+	return s.days, nil
+}
+
+func (s *stubDailyArchiver) ArchiveDay(ctx context.Context, day time.Time) (repository.ArchiveDayResult, error) {
+	if s.failOn != nil && day.Equal(*s.failOn) {
+		return repository.ArchiveDayResult{}, errors.New("db down")
+	}
+	s.archived = append(s.archived, day)
+	return repository.ArchiveDayResult{Summaries: 2, RawDeleted: 5}, nil
+}
+
+func TestRetention_DisabledSkipsDeletion(t *testing.T) {
+	repo := &stubRetentionRepo{deleted: 10}
+	svc := NewRetentionService(repo, newTestLogger(t), 0)
+
+	deleted, err := svc.PurgeOnce(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+
+	// Run returns immediately instead of scheduling purges
+	svc.Run(context.Background(), time.Millisecond)
+	assert.Zero(t, repo.calls())
+}
+
+func TestRetention_PurgeUsesMidnightCutoff(t *testing.T) {
+	repo := &stubRetentionRepo{deleted: 3}
+	svc := NewRetentionService(repo, newTestLogger(t), 30)
+	svc.now = func() time.Time { return time.Date(2024, 3, 31, 15, 30, 0, 0, time.UTC) }
+
+	deleted, err := svc.PurgeOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), deleted)
+	require.Len(t, repo.cutoffs, 1)
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), repo.cutoffs[0])
+}
+
+func TestRetention_PurgeError(t *testing.T) {
+	repo := &stubRetentionRepo{err: errors.New("db down")}
+	svc := NewRetentionService(repo, newTestLogger(t), 30)
+
+	_, err := svc.PurgeOnce(context.Background())
+	assert.Error(t, err)
+}
+
+func TestRetention_RunStopsOnCancel(t *testing.T) {
+	repo := &stubRetentionRepo{}
+	svc := NewRetentionService(repo, newTestLogger(t), 30)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		svc.Run(ctx, time.Millisecond)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool { return repo.calls() >= 2 }, time.Second, time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not stop after cancellation")
+	}
+}
+
+func TestRetention_ArchivesEachDayInsteadOfDeleting(t *testing.T) {
+	repo := &stubRetentionRepo{}
+	archiver := &stubDailyArchiver{days: []time.Time{
+		time.Date(2024, 1, 30, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC),
+	}}
+	svc := NewRetentionService(repo, newTestLogger(t), 30).WithArchive(archiver)
+	svc.now = func() time.Time { return time.Date(2024, 3, 31, 15, 30, 0, 0, time.UTC) }
+
+	deleted, err := svc.PurgeOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), deleted)
+	assert.Equal(t, archiver.days, archiver.archived)
+	assert.Zero(t, repo.calls())
+}
+
+func TestRetention_ArchiveStopsAtFailedDay(t *testing.T) {
+	failOn := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+	archiver := &stubDailyArchiver{
+		days:   []time.Time{time.Date(2024, 1, 30, 0, 0, 0, 0, time.UTC), failOn, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		failOn: &failOn,
+	}
+	svc := NewRetentionService(&stubRetentionRepo{}, newTestLogger(t), 30).WithArchive(archiver)
+
+	deleted, err := svc.PurgeOnce(context.Background())
+	require.Error(t, err)
+	assert.Equal(t, int64(5), deleted)
+	assert.Equal(t, archiver.days[:1], archiver.archived)
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sebaespinosa/test_NF/internal/logging"
+	"github.com/sebaespinosa/test_NF/model"
+	"github.com/sebaespinosa/test_NF/repository"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// SummaryRebuilder defines the data access contract for rebuilding daily summaries from raw events.
+type SummaryRebuilder interface {
+	RebuildSummaries(ctx context.Context, farmID uint, startDay, endDay time.Time) (repository.RebuildSummariesResult, error)
+}
+
+// SummaryService maintains the per-sector daily summaries outside of the retention archival
+type SummaryService struct {
+	rebuilder SummaryRebuilder
+	farms     FarmFinder
+	logger    *logging.Logger
+}
+
+// NewSummaryService creates a new SummaryService instance
+func NewSummaryService(rebuilder SummaryRebuilder, farms FarmFinder, logger *logging.Logger) *SummaryService {
+	return &SummaryService{rebuilder: rebuilder, farms: farms, logger: logger}
+}
+
+// RebuildSummaries recomputes a farm's daily summaries for the UTC days from startDay through endDay
+// It returns ErrFarmNotFound for an unknown farm; a failure part way keeps the chunks already committed,
+// so the rebuild can simply be run again
+func (s *SummaryService) RebuildSummaries(ctx context.Context, farmID uint, startDay, endDay time.Time) (*model.SummaryRebuildResponse, error) {
+	if _, err := s.farms.FindByID(ctx, farmID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrFarmNotFound
+		}
+		return nil, fmt.Errorf("failed to find farm: %w", err)
+	}
+
+	result, err := s.rebuilder.RebuildSummaries(ctx, farmID, startDay, endDay)
+	if err != nil {
+		s.logger.WithContext(ctx).Error("summary rebuild failed",
+			zap.Uint("farm_id", farmID),
+			zap.Time("start_day", startDay),
+			zap.Time("end_day", endDay),
+			zap.Int("days_rebuilt", result.Days),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+
+	s.logger.WithContext(ctx).Info("summary rebuild completed",
+		zap.Uint("farm_id", farmID),
+		zap.Time("start_day", startDay),
+		zap.Time("end_day", endDay),
+		zap.Int("days", result.Days),
+		zap.Int("chunks", result.Chunks),
+		zap.Int("summaries", result.Summaries),
+		zap.Int64("events", result.Events),
+	)
+
+	return &model.SummaryRebuildResponse{
+		FarmID:    farmID,
+		StartDate: startDay.UTC().Format("2006-01-02"),
+		EndDate:   endDay.UTC().Format("2006-01-02"),
+		Days:      result.Days,
+		Chunks:    result.Chunks,
+		Summaries: result.Summaries,
+		Events:    result.Events,
+	}, nil
+}
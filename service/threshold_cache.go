@@ -0,0 +1,47 @@
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+// farmThresholdTTL is how long a farm's own healthy efficiency threshold is reused before it is looked
+// up again; a threshold changed through the API applies to analytics within this time
+const farmThresholdTTL = time.Minute
+
+// thresholdEntry is a farm's own threshold (nil when it has none) and when it stops being reused
+type thresholdEntry struct {
+	threshold *float64
+	expires   time.Time
+}
+
+// thresholdCache remembers the farms' own healthy efficiency thresholds
+// It is safe for concurrent use
+type thresholdCache struct {
+	mu      sync.Mutex
+	entries map[uint]thresholdEntry
+}
+
+func newThresholdCache() *thresholdCache {
+	return &thresholdCache{entries: make(map[uint]thresholdEntry)}
+}
+
+// get returns the farm's threshold if it was stored and has not expired at now
+func (c *thresholdCache) get(farmID uint, now time.Time) (*float64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[farmID]
+	if !ok || !now.Before(entry.expires) {
+		return nil, false
+	}
+	return entry.threshold, true
+}
+
+// set stores the farm's threshold until expires
+func (c *thresholdCache) set(farmID uint, threshold *float64, expires time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[farmID] = thresholdEntry{threshold: threshold, expires: expires}
+}
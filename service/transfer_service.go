@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sebaespinosa/test_NF/internal/logging"
+	"github.com/sebaespinosa/test_NF/model"
+	"github.com/sebaespinosa/test_NF/repository"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ErrFarmNotFound is returned when the requested farm does not exist
+var ErrFarmNotFound = errors.New("farm not found")
+
+// TransferService exports and imports farm data in the seed file format (SeedData)
+type TransferService struct {
+	farmRepo     *repository.FarmRepository
+	sectorRepo   *repository.IrrigationSectorRepository
+	dataRepo     *repository.IrrigationDataRepository
+	transferRepo *repository.TransferRepository
+	logger       *logging.Logger
+}
+
+// NewTransferService creates a new TransferService instance
+func NewTransferService(
+	farmRepo *repository.FarmRepository,
+	sectorRepo *repository.IrrigationSectorRepository,
+	dataRepo *repository.IrrigationDataRepository,
+	transferRepo *repository.TransferRepository,
+	logger *logging.Logger,
+) *TransferService {
+	return &TransferService{
+		farmRepo:     farmRepo,
+		sectorRepo:   sectorRepo,
+		dataRepo:     dataRepo,
+		transferRepo: transferRepo,
+		logger:       logger,
+	}
+}
+
+// ExportFarm assembles a farm, its sectors and optionally its irrigation data as SeedData
+// so the result can be re-imported or used as a seed file
+// Irrigation data is limited to the requested range (default: last 90 days) and only included when includeData is set
+func (s *TransferService) ExportFarm(
+	ctx context.Context,
+	farmID uint,
+	includeData bool,
+	startDate, endDate *time.Time,
+) (*SeedData, error) {
+	s.logger.WithContext(ctx).Info("exporting farm",
+		zap.Uint("farm_id", farmID),
+		zap.Bool("include_data", includeData),
+	)
+
+	farm, err := s.farmRepo.FindByID(ctx, farmID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrFarmNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to export farm: %w", err)
+	}
+
+	sectors, err := s.sectorRepo.FindByFarmID(ctx, farmID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export farm sectors: %w", err)
+	}
+
+	data := make([]model.IrrigationData, 0)
+	if includeData {
+		start, end := resolveDateRange(startDate, endDate)
+		data, err = s.dataRepo.FindByFarmIDAndTimeRange(ctx, farmID, start, end)
+		if err != nil {
+			return nil, fmt.Errorf("failed to export farm irrigation data: %w", err)
+		}
+	}
+
+	s.logger.WithContext(ctx).Info("farm exported",
+		zap.Uint("farm_id", farmID),
+		zap.Int("sectors", len(sectors)),
+		zap.Int("irrigation_data", len(data)),
+	)
+
+	return &SeedData{
+		Farms:             []model.Farm{*farm},
+		IrrigationSectors: sectors,
+		IrrigationData:    data,
+	}, nil
+}
+
+// ErrImportConflict is returned when an import without overwrite hits existing IDs
+var ErrImportConflict = errors.New("import conflicts with existing records")
+
+// Import validates seed data and persists it in a single transaction
+// Without overwrite, existing IDs abort the import with ErrImportConflict; with overwrite they are updated
+// Invalid data yields a *SeedValidationError and nothing is written
+func (s *TransferService) Import(ctx context.Context, seed *SeedData, overwrite bool) (*model.ImportResponse, error) {
+	s.logger.WithContext(ctx).Info("importing seed data",
+		zap.Int("farms", len(seed.Farms)),
+		zap.Int("sectors", len(seed.IrrigationSectors)),
+		zap.Int("irrigation_data", len(seed.IrrigationData)),
+		zap.Bool("overwrite", overwrite),
+	)
+
+	if err := seed.Validate(); err != nil {
+		s.logger.WithContext(ctx).Warn("seed data rejected", zap.Error(err))
+		return nil, err
+	}
+
+	if err := s.transferRepo.Import(ctx, seed.Farms, seed.IrrigationSectors, seed.IrrigationData, overwrite); err != nil {
+		var conflictErr *repository.ImportConflictError
+		if errors.As(err, &conflictErr) {
+			s.logger.WithContext(ctx).Warn("seed data conflicts with existing records", zap.Error(err))
+			return nil, fmt.Errorf("%w: %s", ErrImportConflict, conflictErr.Error())
+		}
+		s.logger.WithContext(ctx).Error("failed to import seed data", zap.Error(err))
+		return nil, err
+	}
+
+	return &model.ImportResponse{
+		Farms:             len(seed.Farms),
+		IrrigationSectors: len(seed.IrrigationSectors),
+		IrrigationData:    len(seed.IrrigationData),
+	}, nil
+}
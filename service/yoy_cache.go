@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/sebaespinosa/test_NF/internal/logging"
+	"github.com/sebaespinosa/test_NF/model"
+	"github.com/sebaespinosa/test_NF/repository"
+	"go.uber.org/zap"
+)
+
+// yoyCacheKey identifies a farm's YoY comparison at one aggregation
+type yoyCacheKey struct {
+	farmID      uint
+	aggregation model.Aggregation
+}
+
+// yoyCacheEntry is a YoY comparison computed for the default range starting at start
+type yoyCacheEntry struct {
+	start time.Time
+	data  map[int]repository.YoYAnalyticsData
+}
+
+// YoYCache holds precomputed YoY comparisons for the default analytics range (the last 90 days)
+// Each farm and aggregation keeps only its latest entry; an entry stops matching once the default
+// range moves on to the next UTC day, so a missed refresh falls back to querying instead of serving stale data
+// It is safe for concurrent use
+type YoYCache struct {
+	mu      sync.RWMutex
+	entries map[yoyCacheKey]yoyCacheEntry
+}
+
+// NewYoYCache creates an empty YoYCache
+func NewYoYCache() *YoYCache {
+	return &YoYCache{entries: make(map[yoyCacheKey]yoyCacheEntry)}
+}
+
+// Get returns the comparison cached for the farm and aggregation if it was computed for the range starting at start
+func (c *YoYCache) Get(farmID uint, aggregation model.Aggregation, start time.Time) (map[int]repository.YoYAnalyticsData, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entry, ok := c.entries[yoyCacheKey{farmID: farmID, aggregation: aggregation}]
+	if !ok || !entry.start.Equal(start) {
+		return nil, false
+	}
+	return entry.data, true
+}
+
+// Ping implements CachePinger; the cache is held in memory, so it is always reachable
+func (c *YoYCache) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Set stores the comparison for the farm and aggregation, replacing any earlier one
+func (c *YoYCache) Set(farmID uint, aggregation model.Aggregation, start time.Time, data map[int]repository.YoYAnalyticsData) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[yoyCacheKey{farmID: farmID, aggregation: aggregation}] = yoyCacheEntry{start: start, data: data}
+}
+
+// YoYRepository defines the data access contract for precomputing YoY comparisons.
+type YoYRepository interface {
+	GetYoYComparison(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) (map[int]repository.YoYAnalyticsData, error)
+}
+
+// YoYCacheJob precomputes the default-range YoY comparison of configured farms into a YoYCache,
+// so dashboards showing "vs last year" are answered without running the comparison query
+// No farms disables the job
+type YoYCacheJob struct {
+	repo        YoYRepository
+	cache       *YoYCache
+	logger      *logging.Logger
+	farmIDs     []uint
+	aggregation model.Aggregation
+	jitter      func(maxJitter time.Duration) time.Duration
+}
+
+// NewYoYCacheJob creates a job refreshing the farms' comparisons at the given aggregation
+func NewYoYCacheJob(repo YoYRepository, cache *YoYCache, logger *logging.Logger, farmIDs []uint, aggregation model.Aggregation) *YoYCacheJob {
+	return &YoYCacheJob{
+		repo:        repo,
+		cache:       cache,
+		logger:      logger,
+		farmIDs:     farmIDs,
+		aggregation: aggregation,
+		jitter: func(maxJitter time.Duration) time.Duration {
+			if maxJitter <= 0 {
+				return 0
+			}
+			return time.Duration(rand.Int63n(int64(maxJitter)))
+		},
+	}
+}
+
+// Enabled reports whether any farm is configured
+func (j *YoYCacheJob) Enabled() bool {
+	return len(j.farmIDs) > 0
+}
+
+// RefreshOnce computes and caches the comparison of every configured farm and returns how many were cached
+// A failing farm is logged and skipped so the others are still refreshed; the first error is returned
+func (j *YoYCacheJob) RefreshOnce(ctx context.Context) (int, error) {
+	start, end := resolveDateRange(nil, nil)
+
+	var firstErr error
+	cached := 0
+	for _, farmID := range j.farmIDs {
+		if ctx.Err() != nil {
+			return cached, ctx.Err()
+		}
+
+		data, err := j.repo.GetYoYComparison(ctx, farmID, start, end, j.aggregation)
+		if err != nil {
+			j.logger.WithContext(ctx).Error("YoY cache refresh failed", zap.Uint("farm_id", farmID), zap.Error(err))
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		j.cache.Set(farmID, j.aggregation, start, data)
+		cached++
+	}
+
+	j.logger.WithContext(ctx).Info("YoY cache refreshed",
+		zap.Int("farms", len(j.farmIDs)),
+		zap.Int("cached", cached),
+		zap.String("aggregation", string(j.aggregation)),
+	)
+	return cached, firstErr
+}
+
+// Run refreshes once immediately and then every interval, each later run delayed by a random jitter
+// up to maxJitter so several instances do not query at the same moment; it stops when ctx is cancelled
+// It returns at once when no farms are configured
+func (j *YoYCacheJob) Run(ctx context.Context, interval, maxJitter time.Duration) {
+	if !j.Enabled() {
+		j.logger.Info("YoY cache refresh disabled")
+		return
+	}
+
+	for {
+		_, _ = j.RefreshOnce(ctx)
+
+		timer := time.NewTimer(interval + j.jitter(maxJitter))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			j.logger.Info("YoY cache refresh stopped")
+			return
+		case <-timer.C:
+		}
+	}
+}
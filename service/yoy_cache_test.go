@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sebaespinosa/test_NF/model"
+	"github.com/sebaespinosa/test_NF/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubYoYRepo struct {
+	mu      sync.Mutex
+	farmIDs []uint
+	failFor uint
+}
+
+func (s *stubYoYRepo) GetYoYComparison(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) (map[int]repository.YoYAnalyticsData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.farmIDs = append(s.farmIDs, farmID)
+	if farmID == s.failFor {
+		return nil, errors.New("db down")
+	}
+	return map[int]repository.YoYAnalyticsData{2023: {Year: 2023, EventCount: int(farmID)}}, nil
+}
+
+func (s *stubYoYRepo) calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.farmIDs)
+}
+
+func TestYoYCacheJob_PopulatesConfiguredFarms(t *testing.T) {
+	repo := &stubYoYRepo{failFor: 3}
+	cache := NewYoYCache()
+	job := NewYoYCacheJob(repo, cache, newTestLogger(t), []uint{1, 2, 3}, model.AggregationWeekly)
+
+	cached, err := job.RefreshOnce(context.Background())
+	require.Error(t, err, "a failing farm is reported")
+	assert.Equal(t, 2, cached)
+	assert.Equal(t, []uint{1, 2, 3}, repo.farmIDs, "the failure does not stop the other farms")
+
+	start, _ := resolveDateRange(nil, nil)
+	for _, farmID := range []uint{1, 2} {
+		data, ok := cache.Get(farmID, model.AggregationWeekly, start)
+		require.True(t, ok, "farm %d", farmID)
+		assert.Equal(t, int(farmID), data[2023].EventCount)
+	}
+
+	_, ok := cache.Get(3, model.AggregationWeekly, start)
+	assert.False(t, ok, "failed farm is not cached")
+	_, ok = cache.Get(4, model.AggregationWeekly, start)
+	assert.False(t, ok, "farm not configured")
+	_, ok = cache.Get(1, model.AggregationDaily, start)
+	assert.False(t, ok, "other aggregation")
+	_, ok = cache.Get(1, model.AggregationWeekly, start.AddDate(0, 0, 1))
+	assert.False(t, ok, "range starting on a different day")
+}
+
+func TestYoYCacheJob_RunStopsOnCancel(t *testing.T) {
+	repo := &stubYoYRepo{}
+	job := NewYoYCacheJob(repo, NewYoYCache(), newTestLogger(t), []uint{1}, model.AggregationDaily)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		job.Run(ctx, time.Millisecond, time.Millisecond)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool { return repo.calls() >= 2 }, time.Second, time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not stop after cancellation")
+	}
+}
+
+func TestYoYCacheJob_DisabledWithoutFarms(t *testing.T) {
+	repo := &stubYoYRepo{}
+	job := NewYoYCacheJob(repo, NewYoYCache(), newTestLogger(t), nil, model.AggregationDaily)
+
+	assert.False(t, job.Enabled())
+	job.Run(context.Background(), time.Millisecond, 0)
+	assert.Zero(t, repo.calls())
+}
+
+func TestGetAnalytics_ServesCachedYoY(t *testing.T) {
+	yoyQueries := 0
+	repo := &mockAnalyticsRepo{
+		getAnalyticsFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation, limit, offset int) ([]repository.AnalyticsAggregation, int64, error) {
+			return nil, 0, nil
+		},
+		getYoYFn: func(ctx context.Context, farmID uint, startTime, endTime time.Time, aggregation model.Aggregation) (map[int]repository.YoYAnalyticsData, error) {
+			yoyQueries++
+			return map[int]repository.YoYAnalyticsData{}, nil
+		},
+		getSectorFn: func(ctx context.Context, farmID uint, sectorID *uint, startTime, endTime time.Time, limit, offset int) ([]repository.SectorAnalyticsData, int64, error) {
+			return nil, 0, nil
+		},
+	}
+	cache := NewYoYCache()
+	start, _ := resolveDateRange(nil, nil)
+	lastYear := time.Now().Year() - 1
+	cache.Set(1, model.AggregationDaily, start, map[int]repository.YoYAnalyticsData{
+		lastYear: {Year: lastYear, TotalRealAmount: 90, TotalNominalAmount: 100, EventCount: 4},
+	})
+	svc := NewIrrigationAnalyticsService(repo, newTestLogger(t), newTestAnalyticsConfig()).WithYoYCache(cache)
+
+	resp, err := svc.GetAnalytics(context.Background(), 1, nil, nil, nil, model.AggregationDaily, 1, 50, model.AnalyticsOptions{})
+	require.NoError(t, err)
+	assert.Zero(t, yoyQueries)
+	require.NotNil(t, resp.SamePeriod1Y)
+
+	// Explicit ranges, other farms and real amount bounds are not what the job precomputed
+	rangeEnd := time.Now().UTC()
+	rangeStart := rangeEnd.AddDate(0, 0, -7)
+	minReal := 5.0
+	_, err = svc.GetAnalytics(context.Background(), 1, &rangeStart, &rangeEnd, nil, model.AggregationDaily, 1, 50, model.AnalyticsOptions{})
+	require.NoError(t, err)
+	_, err = svc.GetAnalytics(context.Background(), 2, nil, nil, nil, model.AggregationDaily, 1, 50, model.AnalyticsOptions{})
+	require.NoError(t, err)
+	_, err = svc.GetAnalytics(context.Background(), 1, nil, nil, nil, model.AggregationDaily, 1, 50, model.AnalyticsOptions{MinReal: &minReal})
+	require.NoError(t, err)
+	assert.Equal(t, 3, yoyQueries)
+}